@@ -0,0 +1,101 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Deletion policies of PostgresDatabase and PostgresRole
+const (
+	DeletionPolicyRetain = "retain"
+	DeletionPolicyDrop   = "drop"
+)
+
+// ConditionSynced is reported in PostgresDatabase and PostgresRole status,
+// it's True when the object in PostgreSQL matches the spec
+const ConditionSynced = "Synced"
+
+// PostgresDatabaseSpec defines database managed by the operator
+type PostgresDatabaseSpec struct {
+	// Name of the database, metadata.name is used if it's empty
+	// +kubebuilder:validation:MaxLength=63
+	Name string `json:"name,omitempty"`
+	// Owner is a role which owns the database, admin user by default
+	Owner string `json:"owner,omitempty"`
+	// Encoding, LcCollate and LcCtype are applied on creation only, the database is created from template0 if they are set
+	Encoding   string              `json:"encoding,omitempty"`
+	LcCollate  string              `json:"lcCollate,omitempty"`
+	LcCtype    string              `json:"lcCtype,omitempty"`
+	Extensions []DatabaseExtension `json:"extensions,omitempty"`
+	// DeletionPolicy defines if the database is dropped when PostgresDatabase is deleted
+	// +kubebuilder:validation:Enum=retain;drop
+	// +kubebuilder:default=retain
+	DeletionPolicy string `json:"deletionPolicy,omitempty"`
+}
+
+// DatabaseExtension is an extension created in the database, Schema and Version are optional
+type DatabaseExtension struct {
+	Name    string `json:"name"`
+	Schema  string `json:"schema,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+// PostgresDatabaseStatus contains result of the last synchronization
+type PostgresDatabaseStatus struct {
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// +listType=map
+	// +listMapKey=type
+	Conditions   []metav1.Condition `json:"conditions,omitempty"`
+	LastSyncTime *metav1.Time       `json:"lastSyncTime,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:shortName=pgdb
+//+kubebuilder:printcolumn:name="Database",type=string,JSONPath=`.spec.name`
+//+kubebuilder:printcolumn:name="Owner",type=string,JSONPath=`.spec.owner`
+//+kubebuilder:printcolumn:name="Synced",type=string,JSONPath=`.status.conditions[?(@.type=="Synced")].status`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// PostgresDatabase is the Schema for the postgresdatabases API
+type PostgresDatabase struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PostgresDatabaseSpec   `json:"spec,omitempty"`
+	Status PostgresDatabaseStatus `json:"status,omitempty"`
+}
+
+// GetDatabaseName returns name of the database in PostgreSQL
+func (db *PostgresDatabase) GetDatabaseName() string {
+	if db.Spec.Name != "" {
+		return db.Spec.Name
+	}
+	return db.Name
+}
+
+//+kubebuilder:object:root=true
+
+// PostgresDatabaseList contains a list of PostgresDatabase
+type PostgresDatabaseList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PostgresDatabase `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PostgresDatabase{}, &PostgresDatabaseList{})
+}
@@ -0,0 +1,119 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PostgresRoleSpec defines role managed by the operator
+type PostgresRoleSpec struct {
+	// Name of the role, metadata.name is used if it's empty
+	// +kubebuilder:validation:MaxLength=63
+	Name  string `json:"name,omitempty"`
+	Login bool   `json:"login,omitempty"`
+	// ConnectionLimit is not limited (-1) by default
+	// +kubebuilder:validation:Minimum=-1
+	ConnectionLimit *int32 `json:"connectionLimit,omitempty"`
+	// MemberOf is a full list of roles granted to the role, other memberships are revoked
+	MemberOf []string `json:"memberOf,omitempty"`
+	// PasswordSecret is a Secret with password of the role
+	PasswordSecret    *RolePasswordSecret `json:"passwordSecret,omitempty"`
+	SchemaGrants      []SchemaGrant       `json:"schemaGrants,omitempty"`
+	DefaultPrivileges []DefaultPrivilege  `json:"defaultPrivileges,omitempty"`
+	// DeletionPolicy defines if the role is dropped when PostgresRole is deleted,
+	// objects owned by the role are reassigned to admin user before drop
+	// +kubebuilder:validation:Enum=retain;drop
+	// +kubebuilder:default=retain
+	DeletionPolicy string `json:"deletionPolicy,omitempty"`
+}
+
+// RolePasswordSecret refers to Secret in the namespace of the operator
+type RolePasswordSecret struct {
+	Name string `json:"name"`
+	// Key of the password in the Secret, "password" by default
+	Key string `json:"key,omitempty"`
+	// Generate creates the Secret with random password and username if it doesn't exist
+	Generate bool `json:"generate,omitempty"`
+}
+
+// SchemaGrant is a full list of privileges of the role on the schema, other privileges are revoked
+type SchemaGrant struct {
+	Database string `json:"database"`
+	Schema   string `json:"schema"`
+	// +kubebuilder:validation:items:Enum=USAGE;CREATE
+	Privileges []string `json:"privileges"`
+}
+
+// DefaultPrivilege is a full list of privileges the role gets on objects created by ForRole,
+// in the Schema or in the whole database if Schema is empty
+type DefaultPrivilege struct {
+	Database string `json:"database"`
+	Schema   string `json:"schema,omitempty"`
+	ForRole  string `json:"forRole"`
+	// +kubebuilder:validation:Enum=tables;sequences;functions;types
+	ObjectType string `json:"objectType"`
+	// +kubebuilder:validation:items:Enum=SELECT;INSERT;UPDATE;DELETE;TRUNCATE;REFERENCES;TRIGGER;USAGE;EXECUTE
+	Privileges []string `json:"privileges"`
+}
+
+// PostgresRoleStatus contains result of the last synchronization
+type PostgresRoleStatus struct {
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// PasswordSecretVersion is resourceVersion of the Secret with the password set to the role
+	PasswordSecretVersion string `json:"passwordSecretVersion,omitempty"`
+	// +listType=map
+	// +listMapKey=type
+	Conditions   []metav1.Condition `json:"conditions,omitempty"`
+	LastSyncTime *metav1.Time       `json:"lastSyncTime,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:shortName=pgrole
+//+kubebuilder:printcolumn:name="Role",type=string,JSONPath=`.spec.name`
+//+kubebuilder:printcolumn:name="Login",type=boolean,JSONPath=`.spec.login`
+//+kubebuilder:printcolumn:name="Synced",type=string,JSONPath=`.status.conditions[?(@.type=="Synced")].status`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// PostgresRole is the Schema for the postgresroles API
+type PostgresRole struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PostgresRoleSpec   `json:"spec,omitempty"`
+	Status PostgresRoleStatus `json:"status,omitempty"`
+}
+
+// GetRoleName returns name of the role in PostgreSQL
+func (r *PostgresRole) GetRoleName() string {
+	if r.Spec.Name != "" {
+		return r.Spec.Name
+	}
+	return r.Name
+}
+
+//+kubebuilder:object:root=true
+
+// PostgresRoleList contains a list of PostgresRole
+type PostgresRoleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PostgresRole `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PostgresRole{}, &PostgresRoleList{})
+}
@@ -44,6 +44,7 @@ type PatroniServicesSpec struct {
 	PgBackRest            *PgBackRest              `json:"pgBackRest,omitempty"`
 	InstallationTimestamp string                   `json:"installationTimestamp,omitempty"`
 	PrivateRegistry       PrivateRegistry          `json:"privateRegistry,omitempty"`
+	CredentialsRotation   *CredentialsRotation     `json:"credentialsRotation,omitempty"`
 }
 
 type PrivateRegistry struct {
@@ -51,21 +52,31 @@ type PrivateRegistry struct {
 	Names   []string `json:"names,omitempty"`
 }
 
-// PatroniServicesStatusConditions contains description of status of PatroniServices
-// +k8s:openapi-gen=true
-type PatroniServicesStatusCondition struct {
-	Type               string `json:"type,omitempty"`
-	Status             bool   `json:"status,omitempty"`
-	Reason             string `json:"reason,omitempty"`
-	Message            string `json:"message,omitempty"`
-	LastTransitionTime string `json:"lastTransitionTime,omitempty"`
-}
+// Condition types reported in PatroniServices status
+const (
+	ConditionReady                      = "Ready"
+	ConditionExternalDatabaseReady      = "ExternalDatabaseReady"
+	ConditionPoolerReady                = "PoolerReady"
+	ConditionBackupDaemonReady          = "BackupDaemonReady"
+	ConditionMetricCollectorReady       = "MetricCollectorReady"
+	ConditionSiteManagerReady           = "SiteManagerReady"
+	ConditionPowaUIReady                = "PowaUIReady"
+	ConditionExportersReady             = "ExportersReady"
+	ConditionReplicationControllerReady = "ReplicationControllerReady"
+	ConditionIntegrationTestsReady      = "IntegrationTestsReady"
+)
 
 // PatroniServicesStatus defines the observed state of PatroniServices
 // +k8s:openapi-gen=true
 type PatroniServicesStatus struct {
-	SiteManagerStatus SiteManagerStatus                `json:"siteManagerStatus,omitempty"`
-	Conditions        []PatroniServicesStatusCondition `json:"conditions,omitempty"`
+	SiteManagerStatus  SiteManagerStatus `json:"siteManagerStatus,omitempty"`
+	ObservedGeneration int64             `json:"observedGeneration,omitempty"`
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// +listType=map
+	// +listMapKey=secretName
+	CredentialsRotation []CredentialsRotationStatus `json:"credentialsRotation,omitempty"`
 }
 
 // SiteManagerStatus defines the observed state of Postgres SiteManager
@@ -145,6 +156,27 @@ type Pooler struct {
 	Replicas        *int32                       `json:"replicas,omitempty"`
 	PodLabels       map[string]string            `json:"podLabels,omitempty"`
 	Config          map[string]map[string]string `json:"config,omitempty"`
+	// ReadOnly is a pooler in front of pg-<cluster>-ro service
+	ReadOnly *ReadOnlyPooler `json:"readOnly,omitempty"`
+	// Metrics is an exporter container of PgBouncer admin console statistics
+	Metrics *PoolerMetrics `json:"metrics,omitempty"`
+}
+
+// PoolerMetrics is a sidecar of the pooler pods which exposes SHOW POOLS, SHOW STATS, SHOW LISTS
+// and SHOW DATABASES of PgBouncer admin console as Prometheus metrics
+type PoolerMetrics struct {
+	Install bool `json:"install,omitempty"`
+	// Image of the exporter, it's included in the operator image
+	Image     string                  `json:"image,omitempty"`
+	Resources v1.ResourceRequirements `json:"resources,omitempty"`
+}
+
+// ReadOnlyPooler is a PgBouncer Deployment which connects to replicas through pg-<cluster>-ro service,
+// it uses image, resources and config of the main pooler
+type ReadOnlyPooler struct {
+	Install bool `json:"install,omitempty"`
+	// Replicas of the read-only pooler, replicas of the main pooler are used if empty
+	Replicas *int32 `json:"replicas,omitempty"`
 }
 
 type Tracing struct {
@@ -154,6 +186,9 @@ type Tracing struct {
 type SiteManager struct {
 	ActiveClusterHost         string                     `json:"activeClusterHost,omitempty"`
 	ActiveClusterPort         int                        `json:"activeClusterPort,omitempty"`
+	UpstreamClusterHost       string                     `json:"upstreamClusterHost,omitempty"`
+	UpstreamClusterPort       int                        `json:"upstreamClusterPort,omitempty"`
+	MaxLagOnPromotion         int64                      `json:"maxLagOnPromotion,omitempty"`
 	StandbyClusterHealthCheck *StandbyClusterHealthCheck `json:"standbyClusterHealthCheck,omitempty"`
 }
 
@@ -218,6 +253,7 @@ type ExternalDataBase struct {
 	Instance       string                       `json:"instance,omitempty"`
 	Port           int                          `json:"port,omitempty"`
 	Region         string                       `json:"region,omitempty"`
+	PeerRegion     string                       `json:"peerRegion,omitempty"`
 	AuthSecretName string                       `json:"authSecretName,omitempty"`
 	ConnectionName string                       `json:"connectionName,omitempty"`
 	RestoreConfig  map[string]map[string]string `json:"restoreConfig,omitempty"`
@@ -252,6 +288,45 @@ type S3 struct {
 	VerifySsl bool   `json:"verifySsl,omitempty"`
 }
 
+// Results of credentials rotation
+const (
+	RotationSucceeded = "Succeeded"
+	RotationFailed    = "Failed"
+)
+
+// CredentialsRotation defines scheduled rotation of passwords of operator-managed users
+type CredentialsRotation struct {
+	Policies []CredentialsRotationPolicy `json:"policies,omitempty"`
+}
+
+// CredentialsRotationPolicy defines when and how the password in the Secret is rotated,
+// Schedule is a cron expression, Interval is used if Schedule is empty
+type CredentialsRotationPolicy struct {
+	// +kubebuilder:validation:Enum=postgres-credentials;replicator-credentials;postgres-exporter-user-credentials;query-exporter-user-credentials;pgbouncer-secret
+	SecretName string           `json:"secretName"`
+	Schedule   string           `json:"schedule,omitempty"`
+	Interval   *metav1.Duration `json:"interval,omitempty"`
+	// +kubebuilder:validation:Minimum=16
+	// +kubebuilder:validation:Maximum=128
+	// +kubebuilder:default=32
+	PasswordLength int `json:"passwordLength,omitempty"`
+	// Charset is alphanumeric or alphanumericSymbols, symbols are limited to ones which don't need escaping in connection strings
+	// +kubebuilder:validation:Enum=alphanumeric;alphanumericSymbols
+	// +kubebuilder:default=alphanumeric
+	Charset string `json:"charset,omitempty"`
+}
+
+// CredentialsRotationStatus contains time and result of the last rotation of the Secret
+type CredentialsRotationStatus struct {
+	SecretName       string       `json:"secretName"`
+	LastRotationTime *metav1.Time `json:"lastRotationTime,omitempty"`
+	NextRotationTime *metav1.Time `json:"nextRotationTime,omitempty"`
+	// Policy is schedule or interval NextRotationTime was calculated for
+	Policy  string `json:"policy,omitempty"`
+	Result  string `json:"result,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
 func init() {
 	SchemeBuilder.Register(&PatroniServices{}, &PatroniServicesList{})
 }
@@ -17,6 +17,7 @@ package v1
 import (
 	types "github.com/Netcracker/pgskipper-operator-core/api/v1"
 	v1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -43,18 +44,14 @@ type PrivateRegistry struct {
 	Names   []string `json:"names,omitempty"`
 }
 
-// PatroniCoreStatusCondition contains description of status of PatroniCore
-// +k8s:openapi-gen=true
-type PatroniCoreStatusCondition struct {
-	Type               string `json:"type,omitempty"`
-	Status             bool   `json:"status,omitempty"`
-	Reason             string `json:"reason,omitempty"`
-	Message            string `json:"message,omitempty"`
-	LastTransitionTime string `json:"lastTransitionTime,omitempty"`
-}
-
-// PatroniCoreStatus defines the observed state of PatroniCore
-// +k8s:openapi-gen=true
+// Condition types reported in PatroniCore status
+const (
+	ConditionReady                   = "Ready"
+	ConditionPatroniReady            = "PatroniReady"
+	ConditionConsulRegistrationReady = "ConsulRegistrationReady"
+	ConditionPgBackRestReady         = "PgBackRestReady"
+	ConditionIntegrationTestsReady   = "IntegrationTestsReady"
+)
 
 //+kubebuilder:object:root=true
 
@@ -81,36 +78,132 @@ type PatroniCoreList struct {
 
 // Patroni contains Patroni-specific configuration
 type Patroni struct {
-	Resources                    *v1.ResourceRequirements `json:"resources,omitempty"`
-	Replicas                     int                      `json:"replicas,omitempty"`
-	DockerImage                  string                   `json:"image,omitempty"`
-	Storage                      *types.Storage           `json:"storage,omitempty"`
-	Affinity                     v1.Affinity              `json:"affinity,omitempty"`
-	PostgreSQLParams             []string                 `json:"postgreSQLParams,omitempty"`
-	PatroniParams                []string                 `json:"patroniParams,omitempty"`
-	StandbyCluster               *StandbyCluster          `json:"standbyCluster,omitempty"`
-	EnableShmVolume              bool                     `json:"enableShmVolume,omitempty"`
-	PriorityClassName            string                   `json:"priorityClassName,omitempty"`
-	CreateEndpoint               bool                     `json:"createEndpoint,omitempty"`
-	SynchronousMode              bool                     `json:"synchronousMode,omitempty"`
-	Dcs                          Dcs                      `json:"dcs,omitempty"`
-	Scope                        string                   `json:"scope,omitempty"`
-	Tags                         map[string]string        `json:"tags,omitempty"`
-	PodLabels                    map[string]string        `json:"podLabels,omitempty"`
-	PgHba                        []string                 `json:"pgHba,omitempty"`
-	Powa                         Powa                     `json:"powa,omitempty"`
-	VaultRegistration            *types.VaultRegistration `json:"vaultRegistration,omitempty"`
-	SecurityContext              *v1.PodSecurityContext   `json:"securityContext,omitempty"`
-	Unlimited                    bool                     `json:"unlimited,omitempty"`
-	PgWalStorageAutoManage       bool                     `json:"pgWalStorageAutoManage,omitempty"`
-	ForceCollationVersionUpgrade bool                     `json:"forceCollationVersionUpgrade,omitempty"`
-	PgWalStorage                 *types.Storage           `json:"pgWalStorage,omitempty"`
-	ClusterName                  string                   `json:"clusterName,omitempty"`
-	IgnoreSlots                  bool                     `json:"ignoreSlots,omitempty"`
-	IgnoreSlotsPrefix            string                   `json:"ignoreSlotsPrefix,omitempty"`
-	External                     *External                `json:"external,omitempty"`
-	PodAnnotations               map[string]string        `json:"podAnnotations,omitempty"`
-	ConfigMapAnnotations         map[string]string        `json:"configMapAnnotations,omitempty"`
+	Resources        *v1.ResourceRequirements `json:"resources,omitempty"`
+	Replicas         int                      `json:"replicas,omitempty"`
+	DockerImage      string                   `json:"image,omitempty"`
+	Storage          *types.Storage           `json:"storage,omitempty"`
+	Affinity         v1.Affinity              `json:"affinity,omitempty"`
+	PostgreSQLParams []string                 `json:"postgreSQLParams,omitempty"`
+	PatroniParams    []string                 `json:"patroniParams,omitempty"`
+	// PostgreSQLParameters and PatroniParameters take precedence over values from the list format,
+	// values are strings, numbers or booleans
+	PostgreSQLParameters         map[string]apiextensionsv1.JSON `json:"postgreSQLParameters,omitempty"`
+	PatroniParameters            map[string]apiextensionsv1.JSON `json:"patroniParameters,omitempty"`
+	StandbyCluster               *StandbyCluster                 `json:"standbyCluster,omitempty"`
+	EnableShmVolume              bool                            `json:"enableShmVolume,omitempty"`
+	PriorityClassName            string                          `json:"priorityClassName,omitempty"`
+	CreateEndpoint               bool                            `json:"createEndpoint,omitempty"`
+	SynchronousMode              bool                            `json:"synchronousMode,omitempty"`
+	Dcs                          Dcs                             `json:"dcs,omitempty"`
+	Scope                        string                          `json:"scope,omitempty"`
+	Tags                         map[string]string               `json:"tags,omitempty"`
+	PodLabels                    map[string]string               `json:"podLabels,omitempty"`
+	PgHba                        []string                        `json:"pgHba,omitempty"`
+	Powa                         Powa                            `json:"powa,omitempty"`
+	VaultRegistration            *types.VaultRegistration        `json:"vaultRegistration,omitempty"`
+	SecurityContext              *v1.PodSecurityContext          `json:"securityContext,omitempty"`
+	Unlimited                    bool                            `json:"unlimited,omitempty"`
+	PgWalStorageAutoManage       bool                            `json:"pgWalStorageAutoManage,omitempty"`
+	ForceCollationVersionUpgrade bool                            `json:"forceCollationVersionUpgrade,omitempty"`
+	PgWalStorage                 *types.Storage                  `json:"pgWalStorage,omitempty"`
+	ClusterName                  string                          `json:"clusterName,omitempty"`
+	IgnoreSlots                  bool                            `json:"ignoreSlots,omitempty"`
+	IgnoreSlotsPrefix            string                          `json:"ignoreSlotsPrefix,omitempty"`
+	External                     *External                       `json:"external,omitempty"`
+	PodAnnotations               map[string]string               `json:"podAnnotations,omitempty"`
+	ConfigMapAnnotations         map[string]string               `json:"configMapAnnotations,omitempty"`
+	Switchover                   *Switchover                     `json:"switchover,omitempty"`
+	RestApi                      *RestApi                        `json:"restApi,omitempty"`
+	// AuthMethod is a password authentication method of pg_hba entries generated by the operator,
+	// pg_hba is switched to scram-sha-256 only when stored md5 hashes are migrated
+	// +kubebuilder:validation:Enum=md5;scram-sha-256
+	AuthMethod    string         `json:"authMethod,omitempty"`
+	AuthMigration *AuthMigration `json:"authMigration,omitempty"`
+	// PgHbaConfig defines structured pg_hba rules and the entries generated by the operator
+	PgHbaConfig *PgHbaConfig `json:"pgHbaConfig,omitempty"`
+	// ReadOnlyService makes the operator manage endpoints of pg-<cluster>-ro service instead of pgtype selector
+	ReadOnlyService *ReadOnlyService `json:"readOnlyService,omitempty"`
+}
+
+// Sets of pg_hba entries generated by the operator
+const (
+	PgHbaDefaultsStandard = "standard"
+	PgHbaDefaultsStrict   = "strict"
+	PgHbaDefaultsNone     = "none"
+)
+
+// Priorities of pg_hba entries which are not defined by rules, entries are rendered in ascending order of priority
+const (
+	PgHbaPriorityLegacy   = 100
+	PgHbaPriorityTrust    = 300
+	PgHbaPriorityLdap     = 400
+	PgHbaPriorityPassword = 500
+)
+
+// PgHbaConfig defines pg_hba of the cluster. Entries of rules, spec.patroni.pgHba, LDAP and defaults
+// are ordered by priority, entries with the same priority keep the order they are defined in.
+type PgHbaConfig struct {
+	// Defaults selects entries generated by the operator: standard trusts local connections of postgres
+	// and replication, strict requires password for all connections, none leaves only rules
+	// +kubebuilder:validation:Enum=standard;strict;none
+	Defaults string `json:"defaults,omitempty"`
+	// Networks replace 0.0.0.0/0 and ::0/0 in the default password entries
+	Networks []string    `json:"networks,omitempty"`
+	Rules    []PgHbaRule `json:"rules,omitempty"`
+}
+
+// PgHbaRule is a pg_hba entry
+type PgHbaRule struct {
+	// +kubebuilder:validation:Enum=local;host;hostssl;hostnossl;hostgssenc;hostnogssenc
+	Type string `json:"type"`
+	// Database is a comma separated list of databases, all by default
+	Database string `json:"database,omitempty"`
+	// User is a comma separated list of users, all by default
+	User string `json:"user,omitempty"`
+	// Address is CIDR, host name or one of all, samehost, samenet, it's required for all types except local
+	Address string `json:"address,omitempty"`
+	Method  string `json:"method"`
+	// Options are authentication options of the method, e.g. ldapserver for ldap
+	Options map[string]string `json:"options,omitempty"`
+	// +kubebuilder:validation:Minimum=0
+	Priority int32 `json:"priority"`
+}
+
+// Strategies of migration to scram-sha-256 authentication
+const (
+	AuthMigrationWaitForRoles = "WaitForRoles"
+	AuthMigrationForce        = "Force"
+)
+
+// AuthMigration configures migration of the cluster from md5 to scram-sha-256 authentication
+type AuthMigration struct {
+	// Strategy defines what is done with roles whose md5 hashes can't be migrated by the operator:
+	// WaitForRoles keeps md5 in pg_hba until passwords of these roles are reset,
+	// Force switches pg_hba anyway and these roles can't log in with password
+	// +kubebuilder:validation:Enum=WaitForRoles;Force
+	Strategy string `json:"strategy,omitempty"`
+}
+
+// Switchover describes planned change of Patroni leader
+type Switchover struct {
+	// Candidate is a member which should become the leader, Patroni chooses the healthiest replica if empty
+	Candidate string `json:"candidate,omitempty"`
+	// ScheduledAt is a time when switchover should be performed, it's performed immediately if empty
+	ScheduledAt *metav1.Time `json:"scheduledAt,omitempty"`
+}
+
+// ReadOnlyService defines replicas which receive connections of pg-<cluster>-ro service. Replicas which lag
+// more than MaxLagBytes or have nofailover or noloadbalance tag are excluded.
+type ReadOnlyService struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// MaxLagBytes is a replication lag reported by Patroni /cluster, default is 16 MiB
+	// +kubebuilder:validation:Minimum=0
+	MaxLagBytes *int64 `json:"maxLagBytes,omitempty"`
+	// FallbackToLeader adds the leader to the endpoints when no replica is available
+	FallbackToLeader bool `json:"fallbackToLeader,omitempty"`
+	// CheckIntervalSeconds is how often the endpoints are updated, default is 30
+	// +kubebuilder:validation:Minimum=5
+	CheckIntervalSeconds int32 `json:"checkIntervalSeconds,omitempty"`
 }
 
 type External struct {
@@ -146,9 +239,32 @@ type ConsulRegistration struct {
 }
 
 type Upgrade struct {
-	Enabled            bool   `json:"enabled,omitempty"`
-	InitDbParams       string `json:"initDbParams,omitempty"`
-	DockerUpgradeImage string `json:"dockerUpgradeImage,omitempty"`
+	Enabled bool `json:"enabled,omitempty"`
+	// DryRun runs preflight checks of major upgrade to the version of spec.patroni.image and reports them
+	// in status.upgradeCheck, the cluster is not changed
+	DryRun bool `json:"dryRun,omitempty"`
+	// Rollback restores the leader volumes from snapshots taken before the last upgrade
+	// and starts the cluster on the previous image
+	Rollback           bool             `json:"rollback,omitempty"`
+	InitDbParams       string           `json:"initDbParams,omitempty"`
+	DockerUpgradeImage string           `json:"dockerUpgradeImage,omitempty"`
+	Snapshot           *UpgradeSnapshot `json:"snapshot,omitempty"`
+	Standby            *StandbyUpgrade  `json:"standby,omitempty"`
+}
+
+// StandbyUpgrade configures the upgrade of standby cluster of DR scheme,
+// it's deferred until the active site reports the target version and standby is re-seeded from it
+type StandbyUpgrade struct {
+	// ActiveSiteManagerUrl is URL of site manager of the active site, e.g. http://postgres-operator.postgres.svc.cluster-1.local:8080
+	ActiveSiteManagerUrl string `json:"activeSiteManagerUrl,omitempty"`
+	// TokenSecretName is a Secret with "token" key, it's used when site manager authentication is enabled
+	TokenSecretName string `json:"tokenSecretName,omitempty"`
+}
+
+// UpgradeSnapshot enables VolumeSnapshots of the leader volumes, they are taken when the cluster is stopped before the upgrade
+type UpgradeSnapshot struct {
+	Enabled                 bool   `json:"enabled,omitempty"`
+	VolumeSnapshotClassName string `json:"volumeSnapshotClassName,omitempty"`
 }
 
 type Powa struct {
@@ -199,8 +315,158 @@ type Tls struct {
 	CertificateSecretName string `json:"certificateSecretName,omitempty"`
 }
 
+// RestApi configures authentication and TLS of Patroni REST API
+type RestApi struct {
+	// AuthSecret is a name of Secret with username and password keys, basic authentication is enabled if it's set
+	AuthSecret string `json:"authSecret,omitempty"`
+	// Tls enables HTTPS for Patroni REST API with the certificate from spec.tls.certificateSecretName
+	Tls bool `json:"tls,omitempty"`
+	// +kubebuilder:validation:Enum=none;optional;required
+	VerifyClient string `json:"verifyClient,omitempty"`
+}
+
+// PatroniCoreStatus defines the observed state of PatroniCore
+// +k8s:openapi-gen=true
 type PatroniCoreStatus struct {
-	Conditions []PatroniCoreStatusCondition `json:"conditions,omitempty"`
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	Switchover *SwitchoverStatus  `json:"switchover,omitempty"`
+	// UpgradeCheck is a report of the last major upgrade dry run
+	UpgradeCheck *UpgradeCheckStatus `json:"upgradeCheck,omitempty"`
+	// UpgradeSnapshot is a rollback point of the last major upgrade
+	UpgradeSnapshot *UpgradeSnapshotStatus `json:"upgradeSnapshot,omitempty"`
+	// StandbyUpgrade is a state of the last major upgrade of standby cluster
+	StandbyUpgrade *StandbyUpgradeStatus `json:"standbyUpgrade,omitempty"`
+	// AuthMigration is a state of migration to scram-sha-256 authentication
+	AuthMigration *AuthMigrationStatus `json:"authMigration,omitempty"`
+	// LdapGroupSync is a result of the last LDAP group synchronization
+	LdapGroupSync *LdapGroupSyncStatus `json:"ldapGroupSync,omitempty"`
+	// ReadOnlyService is a state of endpoints of pg-<cluster>-ro service managed by the operator
+	ReadOnlyService *ReadOnlyServiceStatus `json:"readOnlyService,omitempty"`
+}
+
+// ReadOnlyServiceStatus lists members which receive connections of pg-<cluster>-ro service
+type ReadOnlyServiceStatus struct {
+	Members        []string     `json:"members,omitempty"`
+	LastUpdateTime *metav1.Time `json:"lastUpdateTime,omitempty"`
+	Message        string       `json:"message,omitempty"`
+}
+
+// Switchover phases
+const (
+	SwitchoverScheduled = "Scheduled"
+	SwitchoverRunning   = "Running"
+	SwitchoverSucceeded = "Succeeded"
+	SwitchoverFailed    = "Failed"
+)
+
+// SwitchoverStatus describes progress of the requested switchover
+type SwitchoverStatus struct {
+	Phase          string       `json:"phase,omitempty"`
+	Candidate      string       `json:"candidate,omitempty"`
+	ScheduledAt    *metav1.Time `json:"scheduledAt,omitempty"`
+	PreviousLeader string       `json:"previousLeader,omitempty"`
+	Leader         string       `json:"leader,omitempty"`
+	Message        string       `json:"message,omitempty"`
+	StartTime      *metav1.Time `json:"startTime,omitempty"`
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+// Results of major upgrade preflight checks
+const (
+	UpgradeCheckPass = "Pass"
+	UpgradeCheckWarn = "Warn"
+	UpgradeCheckFail = "Fail"
+)
+
+// UpgradeCheckStatus is a report of major upgrade preflight checks, Result is the worst result of Checks
+type UpgradeCheckStatus struct {
+	Result         string         `json:"result,omitempty"`
+	CurrentVersion string         `json:"currentVersion,omitempty"`
+	TargetVersion  string         `json:"targetVersion,omitempty"`
+	TargetImage    string         `json:"targetImage,omitempty"`
+	StartTime      *metav1.Time   `json:"startTime,omitempty"`
+	CompletionTime *metav1.Time   `json:"completionTime,omitempty"`
+	Checks         []UpgradeCheck `json:"checks,omitempty"`
+}
+
+// UpgradeCheck is a result of single preflight check
+type UpgradeCheck struct {
+	Name string `json:"name"`
+	// +kubebuilder:validation:Enum=Pass;Warn;Fail
+	Result  string `json:"result"`
+	Message string `json:"message,omitempty"`
+}
+
+// Phases of major upgrade rollback point
+const (
+	UpgradeSnapshotReady          = "Ready"
+	UpgradeSnapshotRollingBack    = "RollingBack"
+	UpgradeSnapshotRolledBack     = "RolledBack"
+	UpgradeSnapshotRollbackFailed = "RollbackFailed"
+)
+
+// UpgradeSnapshotStatus describes snapshots of the leader volumes and the cluster state before the upgrade
+type UpgradeSnapshotStatus struct {
+	Phase string `json:"phase,omitempty"`
+	// Image and Version are the Patroni image and PostgreSQL major version before the upgrade
+	Image   string `json:"image,omitempty"`
+	Version string `json:"version,omitempty"`
+	// Leader is a name of the leader statefulset, its volumes are snapshotted
+	Leader       string              `json:"leader,omitempty"`
+	Snapshots    []VolumeSnapshotRef `json:"snapshots,omitempty"`
+	CreationTime *metav1.Time        `json:"creationTime,omitempty"`
+	Message      string              `json:"message,omitempty"`
+}
+
+// VolumeSnapshotRef is a VolumeSnapshot of the PersistentVolumeClaim
+type VolumeSnapshotRef struct {
+	PersistentVolumeClaim string `json:"persistentVolumeClaim"`
+	VolumeSnapshot        string `json:"volumeSnapshot"`
+}
+
+// Phases of major upgrade of standby cluster
+const (
+	StandbyUpgradeWaitingForActive = "WaitingForActive"
+	StandbyUpgradeReseeding        = "Reseeding"
+	StandbyUpgradeCompleted        = "Completed"
+	StandbyUpgradeFailed           = "Failed"
+)
+
+// StandbyUpgradeStatus describes coordination of standby cluster upgrade with the active site
+type StandbyUpgradeStatus struct {
+	Phase string `json:"phase,omitempty"`
+	// Source is host and port of the active cluster standby replicates from
+	Source string `json:"source,omitempty"`
+	// ActiveVersion is PostgreSQL major version reported by site manager of the active site
+	ActiveVersion string `json:"activeVersion,omitempty"`
+	// TargetVersion is PostgreSQL major version of the target image, it's cached while standby waits for the active site
+	TargetVersion string `json:"targetVersion,omitempty"`
+	// TargetImage is the image TargetVersion is read from, the version is read again when the image is changed
+	TargetImage        string       `json:"targetImage,omitempty"`
+	LastTransitionTime *metav1.Time `json:"lastTransitionTime,omitempty"`
+	Message            string       `json:"message,omitempty"`
+}
+
+// Phases of migration to scram-sha-256 authentication
+const (
+	AuthMigrationInProgress      = "InProgress"
+	AuthMigrationWaitingForRoles = "WaitingForRoles"
+	AuthMigrationCompleted       = "Completed"
+	AuthMigrationFailed          = "Failed"
+)
+
+// AuthMigrationStatus describes progress of migration from md5 to scram-sha-256
+type AuthMigrationStatus struct {
+	Phase string `json:"phase,omitempty"`
+	// MigratedRoles are roles re-hashed by the operator with passwords from their Secrets
+	MigratedRoles []string `json:"migratedRoles,omitempty"`
+	// Md5Roles are roles which still have md5 hashes, their passwords are unknown to the operator
+	Md5Roles           []string     `json:"md5Roles,omitempty"`
+	LastTransitionTime *metav1.Time `json:"lastTransitionTime,omitempty"`
+	Message            string       `json:"message,omitempty"`
 }
 
 type PgBackRest struct {
@@ -216,11 +482,65 @@ type PgBackRest struct {
 	DiffRetention     int                      `json:"diffRetention,omitempty"`
 	BackupFromStandby bool                     `json:"backupFromStandby,omitempty"`
 	ConfigParams      []string                 `json:"configParams,omitempty"`
+	// CredentialsSecret is the name of the Secret with repository credentials, supported keys are
+	// s3-key, s3-key-secret, s3-token, azure-account, azure-key, gcs-key and cipher-pass
+	CredentialsSecret string `json:"credentialsSecret,omitempty"`
+	// Repositories are rendered as repo1...repo4 in order, if they are set, RepoType, RepoPath, S3, Rwx,
+	// retention and CredentialsSecret above are ignored
+	// +kubebuilder:validation:MaxItems=4
+	Repositories []PgBackRestRepository `json:"repositories,omitempty"`
 }
 
-type S3 struct {
-	Bucket    string `json:"bucket,omitempty"`
+// PgBackRestRepository describes one pgBackRest repository
+type PgBackRestRepository struct {
+	// +kubebuilder:validation:Enum=rwx;s3;azure;gcs
+	Type string `json:"type"`
+	// Path is the repository path, for rwx it's the mount path of the repository volume
+	Path  string         `json:"path,omitempty"`
+	S3    *S3            `json:"s3,omitempty"`
+	Azure *AzureRepo     `json:"azure,omitempty"`
+	GCS   *GCSRepo       `json:"gcs,omitempty"`
+	Rwx   *types.Storage `json:"rwx,omitempty"`
+	// +kubebuilder:validation:Minimum=0
+	FullRetention int `json:"fullRetention,omitempty"`
+	// +kubebuilder:validation:Minimum=0
+	DiffRetention int `json:"diffRetention,omitempty"`
+	// CipherType enables repository encryption, the passphrase is taken from cipher-pass key of CredentialsSecret
+	// +kubebuilder:validation:Enum=none;aes-256-cbc
+	CipherType string `json:"cipherType,omitempty"`
+	// CredentialsSecret has the same keys as PgBackRest.CredentialsSecret
+	CredentialsSecret string `json:"credentialsSecret,omitempty"`
+	// Schedules are cron expressions, operator creates PostgresBackup of the repository by them
+	FullSchedule string `json:"fullSchedule,omitempty"`
+	DiffSchedule string `json:"diffSchedule,omitempty"`
+	IncrSchedule string `json:"incrSchedule,omitempty"`
+	// SuccessfulBackupsHistoryLimit is the number of succeeded scheduled PostgresBackups of the repository to keep, 3 by default
+	// +kubebuilder:validation:Minimum=0
+	SuccessfulBackupsHistoryLimit *int32 `json:"successfulBackupsHistoryLimit,omitempty"`
+	// FailedBackupsHistoryLimit is the number of failed scheduled PostgresBackups of the repository to keep, 1 by default
+	// +kubebuilder:validation:Minimum=0
+	FailedBackupsHistoryLimit *int32 `json:"failedBackupsHistoryLimit,omitempty"`
+}
+
+type AzureRepo struct {
+	Container string `json:"container,omitempty"`
 	Endpoint  string `json:"endpoint,omitempty"`
+	// +kubebuilder:validation:Enum=shared;sas
+	KeyType string `json:"keyType,omitempty"`
+}
+
+type GCSRepo struct {
+	Bucket   string `json:"bucket,omitempty"`
+	Endpoint string `json:"endpoint,omitempty"`
+	// +kubebuilder:validation:Enum=service;token;auto
+	KeyType string `json:"keyType,omitempty"`
+}
+
+type S3 struct {
+	Bucket   string `json:"bucket,omitempty"`
+	Endpoint string `json:"endpoint,omitempty"`
+	// Deprecated: Key and Secret are moved by operator to pgbackrest-credentials Secret,
+	// use PgBackRest.CredentialsSecret instead
 	Key       string `json:"key,omitempty"`
 	Secret    string `json:"secret,omitempty"`
 	Region    string `json:"region,omitempty"`
@@ -228,13 +548,55 @@ type S3 struct {
 }
 
 type LdapConfig struct {
-	Enabled        bool   `json:"enabled,omitempty"`
-	Server         string `json:"server,omitempty"`
-	Port           int    `json:"port,omitempty"`
-	BaseDN         string `json:"basedn,omitempty"`
-	BindDN         string `json:"binddn,omitempty"`
-	BindPasswd     string `json:"bindpasswd,omitempty"`
-	LdapSearchAttr string `json:"ldapsearchattribute,omitempty"`
+	Enabled bool   `json:"enabled,omitempty"`
+	Server  string `json:"server,omitempty"`
+	Port    int    `json:"port,omitempty"`
+	BaseDN  string `json:"basedn,omitempty"`
+	BindDN  string `json:"binddn,omitempty"`
+	// Deprecated: the password is kept in the CR in plaintext, use BindPasswordSecretName instead
+	BindPasswd string `json:"bindpasswd,omitempty"`
+	// BindPasswordSecretName is a Secret with "password" key, it takes precedence over bindpasswd.
+	// PostgreSQL 16 and newer includes LDAP entry of pg_hba from patroni-ldap-pg-hba Secret. For PostgreSQL 15
+	// and older the password is written to pg_hba in Patroni configuration in DCS, it's readable by anyone
+	// who can read the configuration of the cluster.
+	BindPasswordSecretName string `json:"bindPasswordSecretName,omitempty"`
+	LdapSearchAttr         string `json:"ldapsearchattribute,omitempty"`
+	// Scheme is ldaps to connect to LDAP server over TLS
+	// +kubebuilder:validation:Enum=ldap;ldaps
+	Scheme string `json:"scheme,omitempty"`
+	// StartTLS encrypts connections of ldap scheme with StartTLS
+	StartTLS bool `json:"startTLS,omitempty"`
+	// CASecretName is a Secret with "ca.crt" key, CA bundle is mounted into Patroni pods
+	// to verify certificate of LDAP server
+	CASecretName string         `json:"caSecretName,omitempty"`
+	GroupSync    *LdapGroupSync `json:"groupSync,omitempty"`
+}
+
+// LdapGroupSync defines synchronization of LDAP group members to PostgreSQL roles
+type LdapGroupSync struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// Schedule is a cron expression of the synchronization, every 15 minutes by default
+	Schedule string `json:"schedule,omitempty"`
+	// MemberAttribute is an attribute of group entry with members, member by default.
+	// Values are DNs of the members or usernames, e.g. for memberUid
+	MemberAttribute string             `json:"memberAttribute,omitempty"`
+	Mappings        []LdapGroupMapping `json:"mappings,omitempty"`
+}
+
+// LdapGroupMapping grants PostgreSQL role to members of LDAP group
+type LdapGroupMapping struct {
+	// Group is DN of LDAP group
+	Group string `json:"group"`
+	Role  string `json:"role"`
+}
+
+// LdapGroupSyncStatus is a result of the last LDAP group synchronization
+type LdapGroupSyncStatus struct {
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+	// Members is a number of synchronized members of mapped roles
+	Members int32 `json:"members,omitempty"`
+	// Message is an error of the last synchronization
+	Message string `json:"message,omitempty"`
 }
 
 func init() {
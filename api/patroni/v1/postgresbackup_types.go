@@ -0,0 +1,97 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Phases of PostgresBackup and PostgresRestore
+const (
+	PhasePending   = "Pending"
+	PhaseRunning   = "Running"
+	PhaseSucceeded = "Succeeded"
+	PhaseFailed    = "Failed"
+)
+
+// ConditionCompleted is reported in PostgresBackup and PostgresRestore status,
+// it's Unknown while the operation is running
+const ConditionCompleted = "Completed"
+
+// PostgresBackupSpec defines on-demand pgBackRest backup
+type PostgresBackupSpec struct {
+	// +kubebuilder:validation:Enum=full;diff;incr
+	// +kubebuilder:default=full
+	Type string `json:"type,omitempty"`
+	// Repo is the number of pgBackRest repository, by default pgBackRest uses the first one
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=4
+	Repo int `json:"repo,omitempty"`
+}
+
+// PostgresBackupStatus contains progress and result of the backup
+type PostgresBackupStatus struct {
+	Phase string `json:"phase,omitempty"`
+	// Label is pgBackRest backup set label, e.g. 20240919-000001F
+	Label string `json:"label,omitempty"`
+	// Repo is the number of pgBackRest repository which contains the backup
+	Repo int `json:"repo,omitempty"`
+	// Pod is the Patroni member where backup was started
+	Pod string `json:"pod,omitempty"`
+	// Size is the size of the database, RepoSize is the size of the backup in repository, in bytes
+	Size     int64  `json:"size,omitempty"`
+	RepoSize int64  `json:"repoSize,omitempty"`
+	WalStart string `json:"walStart,omitempty"`
+	WalStop  string `json:"walStop,omitempty"`
+	LsnStart string `json:"lsnStart,omitempty"`
+	LsnStop  string `json:"lsnStop,omitempty"`
+	Duration string `json:"duration,omitempty"`
+	// +listType=map
+	// +listMapKey=type
+	Conditions     []metav1.Condition `json:"conditions,omitempty"`
+	StartTime      *metav1.Time       `json:"startTime,omitempty"`
+	CompletionTime *metav1.Time       `json:"completionTime,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Type",type=string,JSONPath=`.spec.type`
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Label",type=string,JSONPath=`.status.label`
+//+kubebuilder:printcolumn:name="Repo",type=integer,JSONPath=`.status.repo`
+//+kubebuilder:printcolumn:name="Duration",type=string,JSONPath=`.status.duration`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// PostgresBackup is the Schema for the postgresbackups API
+type PostgresBackup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PostgresBackupSpec   `json:"spec,omitempty"`
+	Status PostgresBackupStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// PostgresBackupList contains a list of PostgresBackup
+type PostgresBackupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PostgresBackup `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PostgresBackup{}, &PostgresBackupList{})
+}
@@ -0,0 +1,100 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PostgresRestoreSpec defines restore of the cluster from pgBackRest repository.
+// Backup is chosen by BackupName or BackupLabel, if none is set pgBackRest chooses
+// the latest backup suitable for the Target.
+type PostgresRestoreSpec struct {
+	// BackupName is the name of succeeded PostgresBackup in the same namespace
+	BackupName string `json:"backupName,omitempty"`
+	// BackupLabel is pgBackRest backup set label, e.g. 20240919-000001F
+	// +kubebuilder:validation:Pattern=`^\d{8}-\d{6}F(_\d{8}-\d{6}[DI])?$`
+	BackupLabel string `json:"backupLabel,omitempty"`
+	// Target is the point in time to recover to, without it the backup is restored
+	// to the consistent state reached at the end of the backup
+	Target *RestoreTarget `json:"target,omitempty"`
+	// Repo is the number of pgBackRest repository to restore from, for BackupName
+	// the repository of the backup is used
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=4
+	Repo int `json:"repo,omitempty"`
+}
+
+// RestoreTarget defines point in time recovery target
+type RestoreTarget struct {
+	// Type is time for a timestamp, lsn for a WAL location or name for a restore point
+	// +kubebuilder:validation:Enum=time;lsn;name
+	Type string `json:"type"`
+	// Value is the timestamp (e.g. 2024-10-23 14:11:04+00), LSN or restore point name
+	Value string `json:"value"`
+	// Exclusive stops recovery just before the target
+	Exclusive bool `json:"exclusive,omitempty"`
+}
+
+// PostgresRestoreStatus contains progress and result of the restore
+type PostgresRestoreStatus struct {
+	Phase string `json:"phase,omitempty"`
+	// Step is the step of running restore: Stopping, Restoring, StartingLeader or StartingReplicas
+	Step string `json:"step,omitempty"`
+	// StepStartTime is the time when the current step is started
+	StepStartTime *metav1.Time `json:"stepStartTime,omitempty"`
+	// BackupLabel is the backup set used for restore
+	BackupLabel string `json:"backupLabel,omitempty"`
+	// Repo is the repository used for restore
+	Repo int `json:"repo,omitempty"`
+	// Leader is the Patroni member restored from the backup, other members are reinitialized from it
+	Leader string `json:"leader,omitempty"`
+	// RestorePod is the pod which restores data directory of the leader
+	RestorePod string `json:"restorePod,omitempty"`
+	// +listType=map
+	// +listMapKey=type
+	Conditions     []metav1.Condition `json:"conditions,omitempty"`
+	StartTime      *metav1.Time       `json:"startTime,omitempty"`
+	CompletionTime *metav1.Time       `json:"completionTime,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Step",type=string,JSONPath=`.status.step`
+//+kubebuilder:printcolumn:name="Backup",type=string,JSONPath=`.status.backupLabel`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// PostgresRestore is the Schema for the postgresrestores API
+type PostgresRestore struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PostgresRestoreSpec   `json:"spec,omitempty"`
+	Status PostgresRestoreStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// PostgresRestoreList contains a list of PostgresRestore
+type PostgresRestoreList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PostgresRestore `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PostgresRestore{}, &PostgresRestoreList{})
+}
@@ -21,9 +21,70 @@ package v1
 import (
 	apiv1 "github.com/Netcracker/pgskipper-operator-core/api/v1"
 	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AuthMigration) DeepCopyInto(out *AuthMigration) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AuthMigration.
+func (in *AuthMigration) DeepCopy() *AuthMigration {
+	if in == nil {
+		return nil
+	}
+	out := new(AuthMigration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AuthMigrationStatus) DeepCopyInto(out *AuthMigrationStatus) {
+	*out = *in
+	if in.MigratedRoles != nil {
+		in, out := &in.MigratedRoles, &out.MigratedRoles
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Md5Roles != nil {
+		in, out := &in.Md5Roles, &out.Md5Roles
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastTransitionTime != nil {
+		in, out := &in.LastTransitionTime, &out.LastTransitionTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AuthMigrationStatus.
+func (in *AuthMigrationStatus) DeepCopy() *AuthMigrationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(AuthMigrationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AzureRepo) DeepCopyInto(out *AzureRepo) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AzureRepo.
+func (in *AzureRepo) DeepCopy() *AzureRepo {
+	if in == nil {
+		return nil
+	}
+	out := new(AzureRepo)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ConsulRegistration) DeepCopyInto(out *ConsulRegistration) {
 	*out = *in
@@ -103,6 +164,21 @@ func (in *External) DeepCopy() *External {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GCSRepo) DeepCopyInto(out *GCSRepo) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GCSRepo.
+func (in *GCSRepo) DeepCopy() *GCSRepo {
+	if in == nil {
+		return nil
+	}
+	out := new(GCSRepo)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *IntegrationTests) DeepCopyInto(out *IntegrationTests) {
 	*out = *in
@@ -139,6 +215,11 @@ func (in *IntegrationTests) DeepCopy() *IntegrationTests {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *LdapConfig) DeepCopyInto(out *LdapConfig) {
 	*out = *in
+	if in.GroupSync != nil {
+		in, out := &in.GroupSync, &out.GroupSync
+		*out = new(LdapGroupSync)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LdapConfig.
@@ -151,6 +232,60 @@ func (in *LdapConfig) DeepCopy() *LdapConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LdapGroupMapping) DeepCopyInto(out *LdapGroupMapping) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LdapGroupMapping.
+func (in *LdapGroupMapping) DeepCopy() *LdapGroupMapping {
+	if in == nil {
+		return nil
+	}
+	out := new(LdapGroupMapping)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LdapGroupSync) DeepCopyInto(out *LdapGroupSync) {
+	*out = *in
+	if in.Mappings != nil {
+		in, out := &in.Mappings, &out.Mappings
+		*out = make([]LdapGroupMapping, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LdapGroupSync.
+func (in *LdapGroupSync) DeepCopy() *LdapGroupSync {
+	if in == nil {
+		return nil
+	}
+	out := new(LdapGroupSync)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LdapGroupSyncStatus) DeepCopyInto(out *LdapGroupSyncStatus) {
+	*out = *in
+	if in.LastSyncTime != nil {
+		in, out := &in.LastSyncTime, &out.LastSyncTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LdapGroupSyncStatus.
+func (in *LdapGroupSyncStatus) DeepCopy() *LdapGroupSyncStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(LdapGroupSyncStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PVC) DeepCopyInto(out *PVC) {
 	*out = *in
@@ -190,6 +325,20 @@ func (in *Patroni) DeepCopyInto(out *Patroni) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.PostgreSQLParameters != nil {
+		in, out := &in.PostgreSQLParameters, &out.PostgreSQLParameters
+		*out = make(map[string]apiextensionsv1.JSON, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.PatroniParameters != nil {
+		in, out := &in.PatroniParameters, &out.PatroniParameters
+		*out = make(map[string]apiextensionsv1.JSON, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
 	if in.StandbyCluster != nil {
 		in, out := &in.StandbyCluster, &out.StandbyCluster
 		*out = new(StandbyCluster)
@@ -250,6 +399,31 @@ func (in *Patroni) DeepCopyInto(out *Patroni) {
 			(*out)[key] = val
 		}
 	}
+	if in.Switchover != nil {
+		in, out := &in.Switchover, &out.Switchover
+		*out = new(Switchover)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RestApi != nil {
+		in, out := &in.RestApi, &out.RestApi
+		*out = new(RestApi)
+		**out = **in
+	}
+	if in.AuthMigration != nil {
+		in, out := &in.AuthMigration, &out.AuthMigration
+		*out = new(AuthMigration)
+		**out = **in
+	}
+	if in.PgHbaConfig != nil {
+		in, out := &in.PgHbaConfig, &out.PgHbaConfig
+		*out = new(PgHbaConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ReadOnlyService != nil {
+		in, out := &in.ReadOnlyService, &out.ReadOnlyService
+		*out = new(ReadOnlyService)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Patroni.
@@ -419,7 +593,7 @@ func (in *PatroniCoreSpec) DeepCopyInto(out *PatroniCoreSpec) {
 	if in.Ldap != nil {
 		in, out := &in.Ldap, &out.Ldap
 		*out = new(LdapConfig)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	in.PrivateRegistry.DeepCopyInto(&out.PrivateRegistry)
 }
@@ -439,8 +613,45 @@ func (in *PatroniCoreStatus) DeepCopyInto(out *PatroniCoreStatus) {
 	*out = *in
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
-		*out = make([]PatroniCoreStatusCondition, len(*in))
-		copy(*out, *in)
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Switchover != nil {
+		in, out := &in.Switchover, &out.Switchover
+		*out = new(SwitchoverStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.UpgradeCheck != nil {
+		in, out := &in.UpgradeCheck, &out.UpgradeCheck
+		*out = new(UpgradeCheckStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.UpgradeSnapshot != nil {
+		in, out := &in.UpgradeSnapshot, &out.UpgradeSnapshot
+		*out = new(UpgradeSnapshotStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.StandbyUpgrade != nil {
+		in, out := &in.StandbyUpgrade, &out.StandbyUpgrade
+		*out = new(StandbyUpgradeStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AuthMigration != nil {
+		in, out := &in.AuthMigration, &out.AuthMigration
+		*out = new(AuthMigrationStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LdapGroupSync != nil {
+		in, out := &in.LdapGroupSync, &out.LdapGroupSync
+		*out = new(LdapGroupSyncStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ReadOnlyService != nil {
+		in, out := &in.ReadOnlyService, &out.ReadOnlyService
+		*out = new(ReadOnlyServiceStatus)
+		(*in).DeepCopyInto(*out)
 	}
 }
 
@@ -454,21 +665,6 @@ func (in *PatroniCoreStatus) DeepCopy() *PatroniCoreStatus {
 	return out
 }
 
-// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PatroniCoreStatusCondition) DeepCopyInto(out *PatroniCoreStatusCondition) {
-	*out = *in
-}
-
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PatroniCoreStatusCondition.
-func (in *PatroniCoreStatusCondition) DeepCopy() *PatroniCoreStatusCondition {
-	if in == nil {
-		return nil
-	}
-	out := new(PatroniCoreStatusCondition)
-	in.DeepCopyInto(out)
-	return out
-}
-
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PgBackRest) DeepCopyInto(out *PgBackRest) {
 	*out = *in
@@ -488,6 +684,13 @@ func (in *PgBackRest) DeepCopyInto(out *PgBackRest) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.Repositories != nil {
+		in, out := &in.Repositories, &out.Repositories
+		*out = make([]PgBackRestRepository, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PgBackRest.
@@ -501,133 +704,705 @@ func (in *PgBackRest) DeepCopy() *PgBackRest {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Policies) DeepCopyInto(out *Policies) {
+func (in *PgBackRestRepository) DeepCopyInto(out *PgBackRestRepository) {
 	*out = *in
-	if in.Tolerations != nil {
-		in, out := &in.Tolerations, &out.Tolerations
-		*out = make([]corev1.Toleration, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
+	if in.S3 != nil {
+		in, out := &in.S3, &out.S3
+		*out = new(S3)
+		**out = **in
+	}
+	if in.Azure != nil {
+		in, out := &in.Azure, &out.Azure
+		*out = new(AzureRepo)
+		**out = **in
+	}
+	if in.GCS != nil {
+		in, out := &in.GCS, &out.GCS
+		*out = new(GCSRepo)
+		**out = **in
+	}
+	if in.Rwx != nil {
+		in, out := &in.Rwx, &out.Rwx
+		*out = new(apiv1.Storage)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SuccessfulBackupsHistoryLimit != nil {
+		in, out := &in.SuccessfulBackupsHistoryLimit, &out.SuccessfulBackupsHistoryLimit
+		*out = new(int32)
+		**out = **in
+	}
+	if in.FailedBackupsHistoryLimit != nil {
+		in, out := &in.FailedBackupsHistoryLimit, &out.FailedBackupsHistoryLimit
+		*out = new(int32)
+		**out = **in
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Policies.
-func (in *Policies) DeepCopy() *Policies {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PgBackRestRepository.
+func (in *PgBackRestRepository) DeepCopy() *PgBackRestRepository {
 	if in == nil {
 		return nil
 	}
-	out := new(Policies)
+	out := new(PgBackRestRepository)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Powa) DeepCopyInto(out *Powa) {
+func (in *PgHbaConfig) DeepCopyInto(out *PgHbaConfig) {
 	*out = *in
+	if in.Networks != nil {
+		in, out := &in.Networks, &out.Networks
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Rules != nil {
+		in, out := &in.Rules, &out.Rules
+		*out = make([]PgHbaRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Powa.
-func (in *Powa) DeepCopy() *Powa {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PgHbaConfig.
+func (in *PgHbaConfig) DeepCopy() *PgHbaConfig {
 	if in == nil {
 		return nil
 	}
-	out := new(Powa)
+	out := new(PgHbaConfig)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PrivateRegistry) DeepCopyInto(out *PrivateRegistry) {
+func (in *PgHbaRule) DeepCopyInto(out *PgHbaRule) {
 	*out = *in
-	if in.Names != nil {
-		in, out := &in.Names, &out.Names
-		*out = make([]string, len(*in))
-		copy(*out, *in)
+	if in.Options != nil {
+		in, out := &in.Options, &out.Options
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrivateRegistry.
-func (in *PrivateRegistry) DeepCopy() *PrivateRegistry {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PgHbaRule.
+func (in *PgHbaRule) DeepCopy() *PgHbaRule {
 	if in == nil {
 		return nil
 	}
-	out := new(PrivateRegistry)
+	out := new(PgHbaRule)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *S3) DeepCopyInto(out *S3) {
+func (in *Policies) DeepCopyInto(out *Policies) {
 	*out = *in
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]corev1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new S3.
-func (in *S3) DeepCopy() *S3 {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Policies.
+func (in *Policies) DeepCopy() *Policies {
 	if in == nil {
 		return nil
 	}
-	out := new(S3)
+	out := new(Policies)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *StandbyCluster) DeepCopyInto(out *StandbyCluster) {
+func (in *PostgresBackup) DeepCopyInto(out *PostgresBackup) {
 	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StandbyCluster.
-func (in *StandbyCluster) DeepCopy() *StandbyCluster {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresBackup.
+func (in *PostgresBackup) DeepCopy() *PostgresBackup {
 	if in == nil {
 		return nil
 	}
-	out := new(StandbyCluster)
+	out := new(PostgresBackup)
 	in.DeepCopyInto(out)
 	return out
 }
 
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PostgresBackup) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *StandbyClusterHealthCheck) DeepCopyInto(out *StandbyClusterHealthCheck) {
+func (in *PostgresBackupList) DeepCopyInto(out *PostgresBackupList) {
 	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]PostgresBackup, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StandbyClusterHealthCheck.
-func (in *StandbyClusterHealthCheck) DeepCopy() *StandbyClusterHealthCheck {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresBackupList.
+func (in *PostgresBackupList) DeepCopy() *PostgresBackupList {
 	if in == nil {
 		return nil
 	}
-	out := new(StandbyClusterHealthCheck)
+	out := new(PostgresBackupList)
 	in.DeepCopyInto(out)
 	return out
 }
 
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PostgresBackupList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Tls) DeepCopyInto(out *Tls) {
+func (in *PostgresBackupSpec) DeepCopyInto(out *PostgresBackupSpec) {
 	*out = *in
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Tls.
-func (in *Tls) DeepCopy() *Tls {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresBackupSpec.
+func (in *PostgresBackupSpec) DeepCopy() *PostgresBackupSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(Tls)
+	out := new(PostgresBackupSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Upgrade) DeepCopyInto(out *Upgrade) {
+func (in *PostgresBackupStatus) DeepCopyInto(out *PostgresBackupStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Upgrade.
-func (in *Upgrade) DeepCopy() *Upgrade {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresBackupStatus.
+func (in *PostgresBackupStatus) DeepCopy() *PostgresBackupStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(Upgrade)
+	out := new(PostgresBackupStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresRestore) DeepCopyInto(out *PostgresRestore) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresRestore.
+func (in *PostgresRestore) DeepCopy() *PostgresRestore {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresRestore)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PostgresRestore) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresRestoreList) DeepCopyInto(out *PostgresRestoreList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]PostgresRestore, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresRestoreList.
+func (in *PostgresRestoreList) DeepCopy() *PostgresRestoreList {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresRestoreList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PostgresRestoreList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresRestoreSpec) DeepCopyInto(out *PostgresRestoreSpec) {
+	*out = *in
+	if in.Target != nil {
+		in, out := &in.Target, &out.Target
+		*out = new(RestoreTarget)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresRestoreSpec.
+func (in *PostgresRestoreSpec) DeepCopy() *PostgresRestoreSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresRestoreSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresRestoreStatus) DeepCopyInto(out *PostgresRestoreStatus) {
+	*out = *in
+	if in.StepStartTime != nil {
+		in, out := &in.StepStartTime, &out.StepStartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresRestoreStatus.
+func (in *PostgresRestoreStatus) DeepCopy() *PostgresRestoreStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresRestoreStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Powa) DeepCopyInto(out *Powa) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Powa.
+func (in *Powa) DeepCopy() *Powa {
+	if in == nil {
+		return nil
+	}
+	out := new(Powa)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrivateRegistry) DeepCopyInto(out *PrivateRegistry) {
+	*out = *in
+	if in.Names != nil {
+		in, out := &in.Names, &out.Names
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrivateRegistry.
+func (in *PrivateRegistry) DeepCopy() *PrivateRegistry {
+	if in == nil {
+		return nil
+	}
+	out := new(PrivateRegistry)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReadOnlyService) DeepCopyInto(out *ReadOnlyService) {
+	*out = *in
+	if in.MaxLagBytes != nil {
+		in, out := &in.MaxLagBytes, &out.MaxLagBytes
+		*out = new(int64)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReadOnlyService.
+func (in *ReadOnlyService) DeepCopy() *ReadOnlyService {
+	if in == nil {
+		return nil
+	}
+	out := new(ReadOnlyService)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReadOnlyServiceStatus) DeepCopyInto(out *ReadOnlyServiceStatus) {
+	*out = *in
+	if in.Members != nil {
+		in, out := &in.Members, &out.Members
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastUpdateTime != nil {
+		in, out := &in.LastUpdateTime, &out.LastUpdateTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReadOnlyServiceStatus.
+func (in *ReadOnlyServiceStatus) DeepCopy() *ReadOnlyServiceStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ReadOnlyServiceStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RestApi) DeepCopyInto(out *RestApi) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RestApi.
+func (in *RestApi) DeepCopy() *RestApi {
+	if in == nil {
+		return nil
+	}
+	out := new(RestApi)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RestoreTarget) DeepCopyInto(out *RestoreTarget) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RestoreTarget.
+func (in *RestoreTarget) DeepCopy() *RestoreTarget {
+	if in == nil {
+		return nil
+	}
+	out := new(RestoreTarget)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *S3) DeepCopyInto(out *S3) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new S3.
+func (in *S3) DeepCopy() *S3 {
+	if in == nil {
+		return nil
+	}
+	out := new(S3)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StandbyCluster) DeepCopyInto(out *StandbyCluster) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StandbyCluster.
+func (in *StandbyCluster) DeepCopy() *StandbyCluster {
+	if in == nil {
+		return nil
+	}
+	out := new(StandbyCluster)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StandbyClusterHealthCheck) DeepCopyInto(out *StandbyClusterHealthCheck) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StandbyClusterHealthCheck.
+func (in *StandbyClusterHealthCheck) DeepCopy() *StandbyClusterHealthCheck {
+	if in == nil {
+		return nil
+	}
+	out := new(StandbyClusterHealthCheck)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StandbyUpgrade) DeepCopyInto(out *StandbyUpgrade) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StandbyUpgrade.
+func (in *StandbyUpgrade) DeepCopy() *StandbyUpgrade {
+	if in == nil {
+		return nil
+	}
+	out := new(StandbyUpgrade)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StandbyUpgradeStatus) DeepCopyInto(out *StandbyUpgradeStatus) {
+	*out = *in
+	if in.LastTransitionTime != nil {
+		in, out := &in.LastTransitionTime, &out.LastTransitionTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StandbyUpgradeStatus.
+func (in *StandbyUpgradeStatus) DeepCopy() *StandbyUpgradeStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(StandbyUpgradeStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Switchover) DeepCopyInto(out *Switchover) {
+	*out = *in
+	if in.ScheduledAt != nil {
+		in, out := &in.ScheduledAt, &out.ScheduledAt
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Switchover.
+func (in *Switchover) DeepCopy() *Switchover {
+	if in == nil {
+		return nil
+	}
+	out := new(Switchover)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SwitchoverStatus) DeepCopyInto(out *SwitchoverStatus) {
+	*out = *in
+	if in.ScheduledAt != nil {
+		in, out := &in.ScheduledAt, &out.ScheduledAt
+		*out = (*in).DeepCopy()
+	}
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SwitchoverStatus.
+func (in *SwitchoverStatus) DeepCopy() *SwitchoverStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SwitchoverStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Tls) DeepCopyInto(out *Tls) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Tls.
+func (in *Tls) DeepCopy() *Tls {
+	if in == nil {
+		return nil
+	}
+	out := new(Tls)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Upgrade) DeepCopyInto(out *Upgrade) {
+	*out = *in
+	if in.Snapshot != nil {
+		in, out := &in.Snapshot, &out.Snapshot
+		*out = new(UpgradeSnapshot)
+		**out = **in
+	}
+	if in.Standby != nil {
+		in, out := &in.Standby, &out.Standby
+		*out = new(StandbyUpgrade)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Upgrade.
+func (in *Upgrade) DeepCopy() *Upgrade {
+	if in == nil {
+		return nil
+	}
+	out := new(Upgrade)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UpgradeCheck) DeepCopyInto(out *UpgradeCheck) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UpgradeCheck.
+func (in *UpgradeCheck) DeepCopy() *UpgradeCheck {
+	if in == nil {
+		return nil
+	}
+	out := new(UpgradeCheck)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UpgradeCheckStatus) DeepCopyInto(out *UpgradeCheckStatus) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Checks != nil {
+		in, out := &in.Checks, &out.Checks
+		*out = make([]UpgradeCheck, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UpgradeCheckStatus.
+func (in *UpgradeCheckStatus) DeepCopy() *UpgradeCheckStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(UpgradeCheckStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UpgradeSnapshot) DeepCopyInto(out *UpgradeSnapshot) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UpgradeSnapshot.
+func (in *UpgradeSnapshot) DeepCopy() *UpgradeSnapshot {
+	if in == nil {
+		return nil
+	}
+	out := new(UpgradeSnapshot)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UpgradeSnapshotStatus) DeepCopyInto(out *UpgradeSnapshotStatus) {
+	*out = *in
+	if in.Snapshots != nil {
+		in, out := &in.Snapshots, &out.Snapshots
+		*out = make([]VolumeSnapshotRef, len(*in))
+		copy(*out, *in)
+	}
+	if in.CreationTime != nil {
+		in, out := &in.CreationTime, &out.CreationTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UpgradeSnapshotStatus.
+func (in *UpgradeSnapshotStatus) DeepCopy() *UpgradeSnapshotStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(UpgradeSnapshotStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VolumeSnapshotRef) DeepCopyInto(out *VolumeSnapshotRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeSnapshotRef.
+func (in *VolumeSnapshotRef) DeepCopy() *VolumeSnapshotRef {
+	if in == nil {
+		return nil
+	}
+	out := new(VolumeSnapshotRef)
 	in.DeepCopyInto(out)
 	return out
 }
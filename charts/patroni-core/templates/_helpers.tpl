@@ -149,6 +149,8 @@ DNS names used to generate SSL certificate with "Subject Alternative Name" field
 */}}
 {{- define "postgres.certDnsNames" -}}
   {{- $dnsNames := list "localhost" "pg-patroni" (printf "%s.%s" "pg-patroni" .Release.Namespace)  (printf "%s.%s.svc" "pg-patroni" .Release.Namespace) -}}
+  {{- $apiService := printf "pg-%s-api" (default "patroni" .Values.patroni.clusterName) -}}
+  {{- $dnsNames = concat $dnsNames (list $apiService (printf "%s.%s" $apiService .Release.Namespace) (printf "%s.%s.svc" $apiService .Release.Namespace)) -}}
   {{- $dnsNames = concat $dnsNames .Values.tls.generateCerts.subjectAlternativeName.additionalDnsNames -}}
   {{- $dnsNames | toYaml -}}
 {{- end -}}
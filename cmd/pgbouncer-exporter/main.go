@@ -0,0 +1,64 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/Netcracker/pgskipper-operator/pkg/pooler/exporter"
+	"github.com/Netcracker/pgskipper-operator/pkg/util"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/zap"
+)
+
+var logger = util.GetLogger()
+
+func main() {
+	var listenAddress string
+	var timeout time.Duration
+	flag.StringVar(&listenAddress, "web.listen-address", fmt.Sprintf(":%d", exporter.DefaultPort), "The address the metric endpoint binds to.")
+	flag.DurationVar(&timeout, "scrape-timeout", 5*time.Second, "Timeout of PgBouncer admin console queries per scrape.")
+	flag.Parse()
+
+	console := &exporter.PgConsole{
+		Host:         util.GetEnv("PGBOUNCER_HOST", "127.0.0.1"),
+		Port:         util.GetEnvAsInt("PGBOUNCER_PORT", 6432),
+		UsernameFile: util.GetEnv("POSTGRESQL_USERNAME_FILE", "/opt/pgbouncer/credentials/username"),
+		PasswordFile: util.GetEnv("POSTGRESQL_PASSWORD_FILE", "/opt/pgbouncer/credentials/password"),
+	}
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(exporter.NewCollector(console, timeout))
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	server := &http.Server{
+		Addr:              listenAddress,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	logger.Info(fmt.Sprintf("Starting PgBouncer exporter on %s", listenAddress))
+	if err := server.ListenAndServe(); err != nil {
+		logger.Error("PgBouncer exporter stopped", zap.Error(err))
+		os.Exit(1)
+	}
+}
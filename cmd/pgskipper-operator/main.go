@@ -20,7 +20,9 @@ import (
 
 	site "github.com/Netcracker/pgskipper-operator/pkg/disasterrecovery"
 	"github.com/Netcracker/pgskipper-operator/pkg/helper"
+	"github.com/Netcracker/pgskipper-operator/pkg/metrics"
 	"github.com/Netcracker/pgskipper-operator/pkg/vault"
+	"github.com/Netcracker/pgskipper-operator/pkg/webhook"
 	"github.com/Netcracker/qubership-credential-manager/pkg/hook"
 
 	"net/http"
@@ -30,6 +32,7 @@ import (
 	qubershipv1 "github.com/Netcracker/pgskipper-operator/api/apps/v1"
 	patroniv1 "github.com/Netcracker/pgskipper-operator/api/patroni/v1"
 	"github.com/Netcracker/pgskipper-operator/pkg/util"
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -58,6 +61,7 @@ func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 	utilruntime.Must(patroniv1.AddToScheme(scheme))
 	utilruntime.Must(qubershipv1.AddToScheme(scheme))
+	utilruntime.Must(snapshotv1.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
 }
 
@@ -66,7 +70,8 @@ func main() {
 	var enableLeaderElection bool
 	var probeAddr string
 	operatorRole := strings.ToLower(os.Getenv("OPERATOR_ROLE"))
-	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8383", "The address the metric endpoint binds to.")
+	flag.StringVar(&metricsAddr, "metrics-bind-address", metrics.DefaultBindAddress, "The address the metric endpoint binds to. "+
+		"Metric endpoint is started only if OPERATOR_METRICS_ENABLED is set to true.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
@@ -89,16 +94,23 @@ func main() {
 		os.Exit(1)
 	}
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+	if !metrics.IsEnabled() {
+		metricsAddr = "0"
+	}
+	mgrOptions := ctrl.Options{
 		Scheme:                 scheme,
-		Metrics:                metricsserver.Options{BindAddress: "0"},
+		Metrics:                metricsserver.Options{BindAddress: metricsAddr},
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "3100713b.qubership.org",
 		Cache: cache.Options{
 			DefaultNamespaces: map[string]cache.Config{util.GetNameSpace(): {}},
 		},
-	})
+	}
+	if webhook.IsEnabled() {
+		mgrOptions.WebhookServer = webhook.NewServer()
+	}
+	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), mgrOptions)
 
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
@@ -110,6 +122,20 @@ func main() {
 			setupLog.Error(err, "unable to create controller", "controller", "PatroniCore")
 			os.Exit(1)
 		}
+		if err = controllers.NewPostgresBackupReconciler(mgr.GetClient(), mgr.GetScheme()).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "PostgresBackup")
+			os.Exit(1)
+		}
+		if err = controllers.NewPostgresRestoreReconciler(mgr.GetClient(), mgr.GetScheme()).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "PostgresRestore")
+			os.Exit(1)
+		}
+		if webhook.IsEnabled() {
+			if err = webhook.SetupPatroniCoreWebhook(mgr); err != nil {
+				setupLog.Error(err, "unable to create webhook", "webhook", "PatroniCore")
+				os.Exit(1)
+			}
+		}
 	} else {
 		setupLog.Info("Creating new PatroniServices controller ")
 		if err = (controllers.NewPostgresServiceReconciler(mgr.GetClient(), mgr.GetScheme())).SetupWithManager(mgr); err != nil {
@@ -117,6 +143,20 @@ func main() {
 			os.Exit(1)
 
 		}
+		if err = controllers.NewPostgresDatabaseReconciler(mgr.GetClient(), mgr.GetScheme()).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "PostgresDatabase")
+			os.Exit(1)
+		}
+		if err = controllers.NewPostgresRoleReconciler(mgr.GetClient(), mgr.GetScheme()).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "PostgresRole")
+			os.Exit(1)
+		}
+		if webhook.IsEnabled() {
+			if err = webhook.SetupPatroniServicesWebhook(mgr); err != nil {
+				setupLog.Error(err, "unable to create webhook", "webhook", "PatroniServices")
+				os.Exit(1)
+			}
+		}
 		//Init section
 		vault.Init()
 		site.InitDRManager()
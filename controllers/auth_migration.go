@@ -0,0 +1,154 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	qubershipv1 "github.com/Netcracker/pgskipper-operator/api/patroni/v1"
+	"github.com/Netcracker/pgskipper-operator/pkg/credentials"
+	"github.com/Netcracker/pgskipper-operator/pkg/helper"
+	"github.com/Netcracker/pgskipper-operator/pkg/patroni"
+	utils "github.com/Netcracker/pgskipper-operator/pkg/util"
+	"github.com/Netcracker/pgskipper-operator/pkg/util/constants"
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// authMigrationRecheckInterval is how often roles with md5 hashes are checked while migration waits for them
+const authMigrationRecheckInterval = 5 * time.Minute
+
+// scramMigration migrates password hashes of the roles and switches pg_hba of the cluster, it's replaced in tests
+type scramMigration interface {
+	migrateRoles(pgHost string, migrate bool) ([]string, []string, error)
+	switchPgHba(cr *qubershipv1.PatroniCore, cluster *qubershipv1.PatroniClusterSettings) error
+}
+
+// clusterScramMigration migrates roles of running Patroni cluster
+type clusterScramMigration struct {
+	helper *helper.PatroniHelper
+}
+
+func (m clusterScramMigration) migrateRoles(pgHost string, migrate bool) ([]string, []string, error) {
+	return credentials.MigrateToScram(&m.helper.ResourceManager, pgHost, migrate)
+}
+
+func (m clusterScramMigration) switchPgHba(cr *qubershipv1.PatroniCore, cluster *qubershipv1.PatroniClusterSettings) error {
+	ldapPgHba, err := m.helper.GetLdapPgHba(cr, cluster)
+	if err != nil {
+		return err
+	}
+	pgHba, err := patroni.RenderPgHba(cr, constants.ScramSHA256, ldapPgHba)
+	if err != nil {
+		return err
+	}
+	return patroni.UpdatePgHba(cluster.PatroniUrl, pgHba)
+}
+
+// processAuthMigration migrates md5 password hashes when spec.patroni.authMethod is scram-sha-256
+// and switches pg_hba when no md5 hashes are left. It returns the interval to check the roles again.
+func (pr *PatroniCoreReconciler) processAuthMigration(cr *qubershipv1.PatroniCore) time.Duration {
+	current := cr.Status.AuthMigration
+	if cr.Spec.Patroni == nil || cr.Spec.Patroni.AuthMethod != constants.ScramSHA256 {
+		if current != nil {
+			// pg_hba is switched back to md5 by reconcile of Patroni
+			pr.updateAuthMigrationStatus(nil)
+		}
+		return 0
+	}
+	if current != nil && current.Phase == qubershipv1.AuthMigrationCompleted {
+		return 0
+	}
+
+	status := &qubershipv1.AuthMigrationStatus{}
+	if current != nil {
+		status = current.DeepCopy()
+	} else {
+		pr.setAuthMigrationStatus(status, qubershipv1.AuthMigrationInProgress, "")
+	}
+	cluster := utils.GetPatroniClusterSettings(cr.Spec.Patroni.ClusterName)
+	standby := patroni.IsStandbyClusterConfigurationExist(cr)
+	migrated, md5Roles, err := pr.scram.migrateRoles(cluster.PgHost, !standby)
+	for _, role := range migrated {
+		if !slices.Contains(status.MigratedRoles, role) {
+			status.MigratedRoles = append(status.MigratedRoles, role)
+		}
+	}
+	if err != nil {
+		pr.logger.Error("Migration to scram-sha-256 failed", zap.Error(err))
+		pr.setAuthMigrationStatus(status, qubershipv1.AuthMigrationFailed, err.Error())
+		return authMigrationRecheckInterval
+	}
+	status.Md5Roles = md5Roles
+
+	strategy := qubershipv1.AuthMigrationWaitForRoles
+	if cr.Spec.Patroni.AuthMigration != nil && cr.Spec.Patroni.AuthMigration.Strategy != "" {
+		strategy = cr.Spec.Patroni.AuthMigration.Strategy
+	}
+	if len(md5Roles) > 0 && strategy != qubershipv1.AuthMigrationForce {
+		message := fmt.Sprintf("Roles %s have md5 password hashes, reset their passwords to continue migration",
+			strings.Join(md5Roles, ", "))
+		if standby {
+			message = fmt.Sprintf("Roles %s have md5 password hashes, they are migrated on the active cluster",
+				strings.Join(md5Roles, ", "))
+		}
+		pr.logger.Info(message)
+		pr.setAuthMigrationStatus(status, qubershipv1.AuthMigrationWaitingForRoles, message)
+		return authMigrationRecheckInterval
+	}
+
+	if err := pr.scram.switchPgHba(cr, cluster); err != nil {
+		pr.logger.Error("Cannot switch pg_hba to scram-sha-256", zap.Error(err))
+		pr.setAuthMigrationStatus(status, qubershipv1.AuthMigrationFailed, err.Error())
+		return authMigrationRecheckInterval
+	}
+	message := ""
+	if len(md5Roles) > 0 {
+		message = fmt.Sprintf("pg_hba is switched by %s strategy, roles %s can't log in with password until it's reset",
+			strategy, strings.Join(md5Roles, ", "))
+	}
+	pr.logger.Info("Migration to scram-sha-256 is completed")
+	pr.setAuthMigrationStatus(status, qubershipv1.AuthMigrationCompleted, message)
+	return 0
+}
+
+func (pr *PatroniCoreReconciler) setAuthMigrationStatus(status *qubershipv1.AuthMigrationStatus, phase string, message string) {
+	if status.Phase != phase || status.LastTransitionTime == nil {
+		status.LastTransitionTime = &metav1.Time{Time: time.Now()}
+	}
+	status.Phase = phase
+	status.Message = message
+	pr.updateAuthMigrationStatus(status)
+}
+
+func (pr *PatroniCoreReconciler) updateAuthMigrationStatus(status *qubershipv1.AuthMigrationStatus) {
+	if err := pr.helper.UpdatePatroniCoreStatus(func(crStatus *qubershipv1.PatroniCoreStatus) {
+		crStatus.AuthMigration = status
+	}); err != nil {
+		pr.logger.Error("Can't update auth migration status", zap.Error(err))
+	}
+}
+
+// requeueSooner sets RequeueAfter to the given interval if reconcile is not requeued earlier
+func requeueSooner(result ctrl.Result, after time.Duration) ctrl.Result {
+	if after > 0 && !result.Requeue && (result.RequeueAfter == 0 || after < result.RequeueAfter) {
+		result.RequeueAfter = after
+	}
+	return result
+}
@@ -0,0 +1,253 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	qubershipv1 "github.com/Netcracker/pgskipper-operator/api/patroni/v1"
+	"github.com/Netcracker/pgskipper-operator/pkg/helper"
+	"github.com/Netcracker/pgskipper-operator/pkg/util/constants"
+	"github.com/Netcracker/pgskipper-operator/pkg/util/testenv"
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// fakeScramMigration keeps md5 roles of the cluster, migrateRoles migrates the roles with known passwords
+type fakeScramMigration struct {
+	md5Roles     []string
+	knownRoles   []string
+	migrateErr   error
+	switchErr    error
+	migrateCalls []bool
+	switched     int
+}
+
+func (f *fakeScramMigration) migrateRoles(pgHost string, migrate bool) ([]string, []string, error) {
+	f.migrateCalls = append(f.migrateCalls, migrate)
+	if f.migrateErr != nil {
+		return nil, nil, f.migrateErr
+	}
+	var migrated, remaining []string
+	for _, role := range f.md5Roles {
+		if migrate && slices.Contains(f.knownRoles, role) {
+			migrated = append(migrated, role)
+		} else {
+			remaining = append(remaining, role)
+		}
+	}
+	f.md5Roles = remaining
+	return migrated, remaining, nil
+}
+
+func (f *fakeScramMigration) switchPgHba(cr *qubershipv1.PatroniCore, cluster *qubershipv1.PatroniClusterSettings) error {
+	if f.switchErr != nil {
+		return f.switchErr
+	}
+	f.switched++
+	return nil
+}
+
+func newAuthMigrationReconciler(t *testing.T, cr *qubershipv1.PatroniCore, migration *fakeScramMigration) *PatroniCoreReconciler {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := qubershipv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(cr).
+		WithStatusSubresource(&qubershipv1.PatroniCore{}).Build()
+	return &PatroniCoreReconciler{
+		Client: kubeClient,
+		helper: helper.NewPatroniHelper(kubeClient),
+		logger: *zap.NewNop(),
+		scram:  migration,
+	}
+}
+
+func scramPatroniCore(strategy string) *qubershipv1.PatroniCore {
+	cr := &qubershipv1.PatroniCore{
+		ObjectMeta: metav1.ObjectMeta{Name: "patroni-core", Namespace: testenv.Namespace},
+		Spec: &qubershipv1.PatroniCoreSpec{Patroni: &qubershipv1.Patroni{
+			ClusterName: "patroni",
+			AuthMethod:  constants.ScramSHA256,
+		}},
+	}
+	if strategy != "" {
+		cr.Spec.Patroni.AuthMigration = &qubershipv1.AuthMigration{Strategy: strategy}
+	}
+	return cr
+}
+
+// processAuthMigration runs migration with the current PatroniCore, the same as reconcile does
+func processAuthMigration(t *testing.T, pr *PatroniCoreReconciler) (time.Duration, *qubershipv1.AuthMigrationStatus) {
+	t.Helper()
+	cr := getPatroniCore(t, pr.Client)
+	interval := pr.processAuthMigration(cr)
+	return interval, getPatroniCore(t, pr.Client).Status.AuthMigration
+}
+
+func getPatroniCore(t *testing.T, kubeClient client.Client) *qubershipv1.PatroniCore {
+	t.Helper()
+	cr := &qubershipv1.PatroniCore{}
+	if err := kubeClient.Get(context.Background(), types.NamespacedName{Name: "patroni-core", Namespace: testenv.Namespace}, cr); err != nil {
+		t.Fatal(err)
+	}
+	return cr
+}
+
+func assertAuthMigration(t *testing.T, status *qubershipv1.AuthMigrationStatus, phase string, md5Roles []string, migratedRoles []string) {
+	t.Helper()
+	if status == nil {
+		t.Fatalf("auth migration status is not set, expected %s", phase)
+	}
+	if status.Phase != phase || !reflect.DeepEqual(status.Md5Roles, md5Roles) || !reflect.DeepEqual(status.MigratedRoles, migratedRoles) {
+		t.Errorf("auth migration status is %+v, expected %s with md5 roles %v and migrated roles %v", status, phase, md5Roles, migratedRoles)
+	}
+	if status.LastTransitionTime == nil {
+		t.Errorf("last transition time is not set")
+	}
+}
+
+func TestAuthMigrationWaitsForRoles(t *testing.T) {
+	migration := &fakeScramMigration{md5Roles: []string{"app", "postgres"}, knownRoles: []string{"postgres"}}
+	pr := newAuthMigrationReconciler(t, scramPatroniCore(""), migration)
+
+	interval, status := processAuthMigration(t, pr)
+	assertAuthMigration(t, status, qubershipv1.AuthMigrationWaitingForRoles, []string{"app"}, []string{"postgres"})
+	if interval != authMigrationRecheckInterval {
+		t.Errorf("roles are checked again in %v", interval)
+	}
+	if !strings.Contains(status.Message, "app") || !strings.Contains(status.Message, "reset their passwords") {
+		t.Errorf("message is %q", status.Message)
+	}
+	if migration.switched != 0 {
+		t.Errorf("pg_hba is switched while roles have md5 hashes")
+	}
+	transitionTime := status.LastTransitionTime
+
+	// nothing is changed, the phase keeps its transition time
+	_, status = processAuthMigration(t, pr)
+	assertAuthMigration(t, status, qubershipv1.AuthMigrationWaitingForRoles, []string{"app"}, []string{"postgres"})
+	if !status.LastTransitionTime.Equal(transitionTime) {
+		t.Errorf("transition time is changed without change of phase")
+	}
+
+	// password of app is reset by its owner
+	migration.md5Roles = nil
+	interval, status = processAuthMigration(t, pr)
+	assertAuthMigration(t, status, qubershipv1.AuthMigrationCompleted, nil, []string{"postgres"})
+	if interval != 0 || migration.switched != 1 || status.Message != "" {
+		t.Errorf("completed migration: interval %v, pg_hba switched %d times, message %q", interval, migration.switched, status.Message)
+	}
+
+	// completed migration is not repeated
+	processAuthMigration(t, pr)
+	if len(migration.migrateCalls) != 3 || migration.switched != 1 {
+		t.Errorf("completed migration is repeated: %v, switched %d times", migration.migrateCalls, migration.switched)
+	}
+}
+
+func TestAuthMigrationForce(t *testing.T) {
+	migration := &fakeScramMigration{md5Roles: []string{"app", "reporter"}}
+	pr := newAuthMigrationReconciler(t, scramPatroniCore(qubershipv1.AuthMigrationForce), migration)
+
+	interval, status := processAuthMigration(t, pr)
+	assertAuthMigration(t, status, qubershipv1.AuthMigrationCompleted, []string{"app", "reporter"}, nil)
+	if interval != 0 || migration.switched != 1 {
+		t.Errorf("forced migration: interval %v, pg_hba switched %d times", interval, migration.switched)
+	}
+	if !strings.Contains(status.Message, "Force") || !strings.Contains(status.Message, "app, reporter") {
+		t.Errorf("roles which can't log in are not reported: %q", status.Message)
+	}
+}
+
+func TestAuthMigrationFailures(t *testing.T) {
+	tests := []struct {
+		name       string
+		migrateErr error
+		switchErr  error
+	}{
+		{name: "roles are not migrated", migrateErr: fmt.Errorf("postgresql pg-patroni is not available")},
+		{name: "pg_hba is not switched", switchErr: fmt.Errorf("patroni is not available")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migration := &fakeScramMigration{migrateErr: tt.migrateErr, switchErr: tt.switchErr}
+			pr := newAuthMigrationReconciler(t, scramPatroniCore(""), migration)
+
+			interval, status := processAuthMigration(t, pr)
+			if status == nil || status.Phase != qubershipv1.AuthMigrationFailed {
+				t.Fatalf("auth migration status is %+v, expected Failed", status)
+			}
+			if interval != authMigrationRecheckInterval {
+				t.Errorf("failed migration is retried in %v", interval)
+			}
+			if !strings.Contains(status.Message, "not available") {
+				t.Errorf("error is not reported: %q", status.Message)
+			}
+
+			// failed migration is retried
+			migration.migrateErr, migration.switchErr = nil, nil
+			_, status = processAuthMigration(t, pr)
+			assertAuthMigration(t, status, qubershipv1.AuthMigrationCompleted, nil, nil)
+		})
+	}
+}
+
+func TestAuthMigrationOnStandbyCluster(t *testing.T) {
+	cr := scramPatroniCore("")
+	cr.Spec.Patroni.StandbyCluster = &qubershipv1.StandbyCluster{Host: "pg-patroni.active"}
+	migration := &fakeScramMigration{md5Roles: []string{"postgres"}, knownRoles: []string{"postgres"}}
+	pr := newAuthMigrationReconciler(t, cr, migration)
+
+	_, status := processAuthMigration(t, pr)
+	assertAuthMigration(t, status, qubershipv1.AuthMigrationWaitingForRoles, []string{"postgres"}, nil)
+	if !reflect.DeepEqual(migration.migrateCalls, []bool{false}) {
+		t.Errorf("roles are migrated on standby cluster: %v", migration.migrateCalls)
+	}
+	if !strings.Contains(status.Message, "migrated on the active cluster") {
+		t.Errorf("message is %q", status.Message)
+	}
+}
+
+func TestAuthMigrationIsResetForMd5(t *testing.T) {
+	cr := scramPatroniCore("")
+	cr.Spec.Patroni.AuthMethod = "md5"
+	cr.Status.AuthMigration = &qubershipv1.AuthMigrationStatus{Phase: qubershipv1.AuthMigrationCompleted}
+	migration := &fakeScramMigration{}
+	pr := newAuthMigrationReconciler(t, cr, migration)
+
+	interval, status := processAuthMigration(t, pr)
+	if status != nil || interval != 0 {
+		t.Errorf("auth migration status is %+v, interval %v, expected reset", status, interval)
+	}
+	if len(migration.migrateCalls) != 0 {
+		t.Errorf("roles are migrated with md5 auth method")
+	}
+}
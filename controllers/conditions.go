@@ -17,12 +17,19 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	v1 "github.com/Netcracker/pgskipper-operator/api/apps/v1"
 	patroniv1 "github.com/Netcracker/pgskipper-operator/api/patroni/v1"
+	"github.com/Netcracker/pgskipper-operator/pkg/metrics"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 const (
@@ -31,25 +38,129 @@ const (
 	Failed     string = "Failed"
 )
 
-func (r *PostgresServiceReconciler) forceUpdateStatus(cr *v1.PatroniServices, statusType string, reason string, message string) bool {
-	transitionTime := metav1.Now()
-	newCondition := v1.PatroniServicesStatusCondition{
-		Type:               statusType,
-		Status:             true,
+const (
+	reasonReconcileSucceeded = "ReconcileSucceeded"
+	reasonReconcileFailed    = "ReconcileFailed"
+)
+
+// componentConditions collects per-component conditions during a reconcile cycle,
+// they are written to the CR together with the aggregate Ready condition.
+type componentConditions struct {
+	kind       string
+	generation int64
+	set        []metav1.Condition
+	removed    []string
+}
+
+func (c *componentConditions) reset(generation int64) {
+	c.generation = generation
+	c.set = nil
+	c.removed = nil
+}
+
+// track runs reconcile of the component, records its outcome and returns the error unchanged.
+func (c *componentConditions) track(conditionType string, reconcile func() error) error {
+	start := time.Now()
+	err := reconcile()
+	metrics.ObserveReconcile(c.kind, strings.TrimSuffix(conditionType, "Ready"), time.Since(start), err)
+	condition := metav1.Condition{
+		Type:               conditionType,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: c.generation,
+		Reason:             reasonReconcileSucceeded,
+		Message:            fmt.Sprintf("%s reconcile succeeded", conditionType),
+	}
+	if err != nil {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = reasonReconcileFailed
+		condition.Message = err.Error()
+	}
+	c.set = append(c.set, condition)
+	return err
+}
+
+// skip drops the condition of a component which is not installed.
+func (c *componentConditions) skip(conditionType string) {
+	c.removed = append(c.removed, conditionType)
+}
+
+func (c *componentConditions) apply(conditions *[]metav1.Condition) {
+	for _, conditionType := range c.removed {
+		meta.RemoveStatusCondition(conditions, conditionType)
+	}
+	for _, condition := range c.set {
+		meta.SetStatusCondition(conditions, condition)
+	}
+}
+
+func newReadyCondition(statusType string, reason string, message string, generation int64) metav1.Condition {
+	status := metav1.ConditionUnknown
+	switch statusType {
+	case Successful:
+		status = metav1.ConditionTrue
+	case Failed:
+		status = metav1.ConditionFalse
+	}
+	return metav1.Condition{
+		Type:               v1.ConditionReady,
+		Status:             status,
+		ObservedGeneration: generation,
 		Reason:             reason,
 		Message:            message,
-		LastTransitionTime: transitionTime.String(),
 	}
-	if len(cr.Status.Conditions) == 0 {
-		cr.Status.Conditions = append(cr.Status.Conditions, newCondition)
-	} else {
-		cr.Status.Conditions[0] = newCondition
+}
+
+// isReconcileSucceeded returns true if Ready condition exists and last reconcile didn't fail
+func isReconcileSucceeded(conditions []metav1.Condition) bool {
+	ready := meta.FindStatusCondition(conditions, v1.ConditionReady)
+	return ready != nil && ready.Status != metav1.ConditionFalse
+}
+
+// dropLegacyConditions removes conditions stored in the old format (bool status,
+// string timestamp), such CR can't be decoded until they are gone.
+func dropLegacyConditions(c client.Client, gvk schema.GroupVersionKind, key types.NamespacedName) (bool, error) {
+	cr := &unstructured.Unstructured{}
+	cr.SetGroupVersionKind(gvk)
+	if err := c.Get(context.TODO(), key, cr); err != nil {
+		return false, err
+	}
+	conditions, found, err := unstructured.NestedSlice(cr.Object, "status", "conditions")
+	if err != nil || !found {
+		return false, err
+	}
+	legacy := false
+	for _, condition := range conditions {
+		if fields, ok := condition.(map[string]interface{}); ok {
+			if _, isBool := fields["status"].(bool); isBool {
+				legacy = true
+			}
+		}
+	}
+	if !legacy {
+		return false, nil
+	}
+	unstructured.RemoveNestedField(cr.Object, "status", "conditions")
+	return true, c.Status().Update(context.TODO(), cr)
+}
+
+func (r *PostgresServiceReconciler) forceUpdateStatus(cr *v1.PatroniServices, statusType string, reason string, message string) bool {
+	generation := r.conditions.generation
+	if generation == 0 {
+		generation = cr.Generation
+	}
+	r.conditions.apply(&cr.Status.Conditions)
+	meta.SetStatusCondition(&cr.Status.Conditions, newReadyCondition(statusType, reason, message, generation))
+	if statusType != InProgress {
+		cr.Status.ObservedGeneration = generation
 	}
 	return true
 }
 
 func (r *PostgresServiceReconciler) updateStatus(statusType string, reason string, message string) error {
 	newCr, err := r.helper.GetPostgresServiceCR()
+	if err != nil {
+		return err
+	}
 	if r.forceUpdateStatus(newCr, statusType, reason, message) {
 		// Update status if not equal to the last one
 		r.logger.Info(fmt.Sprintf("Update operator status. statusType: %s, reason: %s, message: %s", statusType, reason, message))
@@ -68,6 +179,9 @@ func (r *PostgresServiceReconciler) updateStatus(statusType string, reason strin
 
 func (pr *PatroniCoreReconciler) updateStatus(statusType string, reason string, message string) error {
 	newCr, err := pr.helper.GetPatroniCoreCR()
+	if err != nil {
+		return err
+	}
 	if pr.forceUpdateStatus(newCr, statusType, reason, message) {
 		// Update status if not equal to the last one
 		pr.logger.Info(fmt.Sprintf("Update operator status. statusType: %s, reason: %s, message: %s", statusType, reason, message))
@@ -85,18 +199,14 @@ func (pr *PatroniCoreReconciler) updateStatus(statusType string, reason string,
 }
 
 func (p *PatroniCoreReconciler) forceUpdateStatus(cr *patroniv1.PatroniCore, statusType string, reason string, message string) bool {
-	transitionTime := metav1.Now()
-	newCondition := patroniv1.PatroniCoreStatusCondition{
-		Type:               statusType,
-		Status:             true,
-		Reason:             reason,
-		Message:            message,
-		LastTransitionTime: transitionTime.String(),
+	generation := p.conditions.generation
+	if generation == 0 {
+		generation = cr.Generation
 	}
-	if len(cr.Status.Conditions) == 0 {
-		cr.Status.Conditions = append(cr.Status.Conditions, newCondition)
-	} else {
-		cr.Status.Conditions[0] = newCondition
+	p.conditions.apply(&cr.Status.Conditions)
+	meta.SetStatusCondition(&cr.Status.Conditions, newReadyCondition(statusType, reason, message, generation))
+	if statusType != InProgress {
+		cr.Status.ObservedGeneration = generation
 	}
 	return true
 }
@@ -0,0 +1,78 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	qubershipv1 "github.com/Netcracker/pgskipper-operator/api/apps/v1"
+	pgClient "github.com/Netcracker/pgskipper-operator/pkg/client"
+	"github.com/Netcracker/pgskipper-operator/pkg/helper"
+	utils "github.com/Netcracker/pgskipper-operator/pkg/util"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// dbObjectsSyncInterval is the period of drift correction of PostgresDatabase and PostgresRole
+	dbObjectsSyncInterval  = 5 * time.Minute
+	dbObjectsRetryInterval = time.Minute
+
+	reasonSynced                = "Synced"
+	reasonSyncFailed            = "SyncFailed"
+	reasonPostgreSQLUnavailable = "PostgreSQLUnavailable"
+)
+
+// dbObjectsClientFunc returns client of PostgreSQL cluster, tests use a client of local PostgreSQL instead
+type dbObjectsClientFunc func() (*pgClient.PostgresClient, error)
+
+// getDBObjectsClient returns client of PostgreSQL cluster managed by PatroniServices
+func getDBObjectsClient(h *helper.Helper) (*pgClient.PostgresClient, error) {
+	cr, err := h.GetPostgresServiceCR()
+	if err != nil {
+		return nil, err
+	}
+	clusterName := "patroni"
+	if cr.Spec.Patroni != nil && cr.Spec.Patroni.ClusterName != "" {
+		clusterName = cr.Spec.Patroni.ClusterName
+	}
+	pgC := pgClient.GetPostgresClient(utils.GetPatroniClusterSettings(clusterName).PgHost)
+	if pgC == nil {
+		return nil, fmt.Errorf("postgresql of cluster %s is not available", clusterName)
+	}
+	return pgC, nil
+}
+
+// setSyncedCondition sets Synced condition and returns result of the reconcile,
+// failed synchronization is retried sooner than periodic drift correction
+func setSyncedCondition(conditions *[]metav1.Condition, generation int64, reason string, err error) (*metav1.Time, time.Duration) {
+	condition := metav1.Condition{
+		Type:               qubershipv1.ConditionSynced,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: generation,
+		Reason:             reason,
+		Message:            "Object in PostgreSQL matches the spec",
+	}
+	requeueAfter := dbObjectsSyncInterval
+	if err != nil {
+		condition.Status = metav1.ConditionFalse
+		condition.Message = err.Error()
+		requeueAfter = dbObjectsRetryInterval
+	}
+	meta.SetStatusCondition(conditions, condition)
+	now := metav1.Now()
+	return &now, requeueAfter
+}
@@ -0,0 +1,240 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	qubershipv1 "github.com/Netcracker/pgskipper-operator/api/apps/v1"
+	pgClient "github.com/Netcracker/pgskipper-operator/pkg/client"
+	"github.com/Netcracker/pgskipper-operator/pkg/util/testenv"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newDBObjectsFakeClient(t *testing.T, objects ...client.Object) (client.Client, *runtime.Scheme) {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := qubershipv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).
+		WithStatusSubresource(&qubershipv1.PostgresRole{}, &qubershipv1.PostgresDatabase{}).Build()
+	return kubeClient, scheme
+}
+
+func queryExists(t *testing.T, pgC *pgClient.PostgresClient, query string, name string) bool {
+	t.Helper()
+	conn, err := pgC.GetConnection()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Release()
+	var exists bool
+	if err := conn.QueryRow(context.Background(), query, name).Scan(&exists); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	return exists
+}
+
+func roleCanLogin(t *testing.T, pgC *pgClient.PostgresClient, name string) bool {
+	t.Helper()
+	return queryExists(t, pgC, "SELECT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = $1 AND rolcanlogin)", name)
+}
+
+func roleExists(t *testing.T, pgC *pgClient.PostgresClient, name string) bool {
+	t.Helper()
+	return queryExists(t, pgC, "SELECT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = $1)", name)
+}
+
+func databaseExists(t *testing.T, pgC *pgClient.PostgresClient, name string) bool {
+	t.Helper()
+	return queryExists(t, pgC, "SELECT EXISTS (SELECT 1 FROM pg_database WHERE datname = $1)", name)
+}
+
+func reconcileRequest(name string) ctrl.Request {
+	return ctrl.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: testenv.Namespace}}
+}
+
+func assertSynced(t *testing.T, conditions []metav1.Condition) {
+	t.Helper()
+	condition := meta.FindStatusCondition(conditions, qubershipv1.ConditionSynced)
+	if condition == nil || condition.Status != metav1.ConditionTrue {
+		t.Errorf("Synced condition = %+v, want True", condition)
+	}
+}
+
+func TestPostgresRoleReconcile(t *testing.T) {
+	pgC := requirePostgres(t)
+	for _, policy := range []string{qubershipv1.DeletionPolicyRetain, qubershipv1.DeletionPolicyDrop} {
+		t.Run(policy, func(t *testing.T) {
+			roleName := "test_reconciled_" + policy
+			t.Cleanup(func() { _ = pgC.Execute("DROP ROLE IF EXISTS " + roleName) })
+			role := &qubershipv1.PostgresRole{
+				ObjectMeta: metav1.ObjectMeta{Name: "app-" + policy, Namespace: testenv.Namespace},
+				Spec: qubershipv1.PostgresRoleSpec{
+					Name:           roleName,
+					Login:          true,
+					PasswordSecret: &qubershipv1.RolePasswordSecret{Name: "app-" + policy + "-password", Generate: true},
+					DeletionPolicy: policy,
+				},
+			}
+			kubeClient, scheme := newDBObjectsFakeClient(t, role)
+			r := &PostgresRoleReconciler{
+				Client:      kubeClient,
+				Scheme:      scheme,
+				logger:      *zap.NewNop(),
+				getPgClient: func() (*pgClient.PostgresClient, error) { return pgC, nil },
+			}
+			ctx := context.Background()
+			request := reconcileRequest(role.Name)
+
+			result, err := r.Reconcile(ctx, request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.RequeueAfter != dbObjectsSyncInterval {
+				t.Errorf("RequeueAfter = %s, want drift correction after %s", result.RequeueAfter, dbObjectsSyncInterval)
+			}
+			if !roleCanLogin(t, pgC, roleName) {
+				t.Fatal("role with login is not created")
+			}
+			secret := &corev1.Secret{}
+			if err := kubeClient.Get(ctx, types.NamespacedName{Name: role.Spec.PasswordSecret.Name, Namespace: testenv.Namespace}, secret); err != nil {
+				t.Fatalf("password secret is not generated: %v", err)
+			}
+			if err := kubeClient.Get(ctx, request.NamespacedName, role); err != nil {
+				t.Fatal(err)
+			}
+			assertSynced(t, role.Status.Conditions)
+			if role.Status.PasswordSecretVersion != secret.ResourceVersion {
+				t.Errorf("PasswordSecretVersion = %q, want %q", role.Status.PasswordSecretVersion, secret.ResourceVersion)
+			}
+
+			if err := pgC.Execute("ALTER ROLE " + roleName + " NOLOGIN"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := r.Reconcile(ctx, request); err != nil {
+				t.Fatal(err)
+			}
+			if !roleCanLogin(t, pgC, roleName) {
+				t.Error("drift of login attribute is not corrected")
+			}
+
+			if err := kubeClient.Delete(ctx, role); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := r.Reconcile(ctx, request); err != nil {
+				t.Fatal(err)
+			}
+			if err := kubeClient.Get(ctx, request.NamespacedName, role); !errors.IsNotFound(err) {
+				t.Errorf("PostgresRole is not deleted after finalization: %v", err)
+			}
+			if exists := roleExists(t, pgC, roleName); exists != (policy == qubershipv1.DeletionPolicyRetain) {
+				t.Errorf("role exists = %t after deletion with %s policy", exists, policy)
+			}
+		})
+	}
+}
+
+func TestPostgresDatabaseReconcile(t *testing.T) {
+	pgC := requirePostgres(t)
+	if err := pgC.Execute("CREATE ROLE test_reconciled_owner"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = pgC.Execute("DROP ROLE IF EXISTS test_reconciled_owner") })
+	for _, policy := range []string{qubershipv1.DeletionPolicyRetain, qubershipv1.DeletionPolicyDrop} {
+		t.Run(policy, func(t *testing.T) {
+			dbName := "test_reconciled_" + policy
+			t.Cleanup(func() { _ = pgC.Execute("DROP DATABASE IF EXISTS " + dbName) })
+			db := &qubershipv1.PostgresDatabase{
+				ObjectMeta: metav1.ObjectMeta{Name: "app-" + policy, Namespace: testenv.Namespace},
+				Spec:       qubershipv1.PostgresDatabaseSpec{Name: dbName, Owner: "test_reconciled_owner", DeletionPolicy: policy},
+			}
+			kubeClient, scheme := newDBObjectsFakeClient(t, db)
+			r := &PostgresDatabaseReconciler{
+				Client:      kubeClient,
+				Scheme:      scheme,
+				logger:      *zap.NewNop(),
+				getPgClient: func() (*pgClient.PostgresClient, error) { return pgC, nil },
+			}
+			ctx := context.Background()
+			request := reconcileRequest(db.Name)
+
+			if _, err := r.Reconcile(ctx, request); err != nil {
+				t.Fatal(err)
+			}
+			ownedBy := "SELECT EXISTS (SELECT 1 FROM pg_database WHERE datname = $1 AND pg_get_userbyid(datdba) = 'test_reconciled_owner')"
+			if !queryExists(t, pgC, ownedBy, dbName) {
+				t.Fatal("database of the owner is not created")
+			}
+			if err := kubeClient.Get(ctx, request.NamespacedName, db); err != nil {
+				t.Fatal(err)
+			}
+			assertSynced(t, db.Status.Conditions)
+
+			if err := pgC.Execute("ALTER DATABASE " + dbName + " OWNER TO postgres"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := r.Reconcile(ctx, request); err != nil {
+				t.Fatal(err)
+			}
+			if !queryExists(t, pgC, ownedBy, dbName) {
+				t.Error("drift of owner is not corrected")
+			}
+
+			if err := kubeClient.Delete(ctx, db); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := r.Reconcile(ctx, request); err != nil {
+				t.Fatal(err)
+			}
+			if err := kubeClient.Get(ctx, request.NamespacedName, db); !errors.IsNotFound(err) {
+				t.Errorf("PostgresDatabase is not deleted after finalization: %v", err)
+			}
+			if exists := databaseExists(t, pgC, dbName); exists != (policy == qubershipv1.DeletionPolicyRetain) {
+				t.Errorf("database exists = %t after deletion with %s policy", exists, policy)
+			}
+		})
+	}
+}
+
+func TestSetSyncedCondition(t *testing.T) {
+	var conditions []metav1.Condition
+	if _, requeueAfter := setSyncedCondition(&conditions, 2, reasonSyncFailed, errors.NewBadRequest("broken")); requeueAfter != dbObjectsRetryInterval {
+		t.Errorf("failed sync is requeued after %s, want %s", requeueAfter, dbObjectsRetryInterval)
+	}
+	condition := meta.FindStatusCondition(conditions, qubershipv1.ConditionSynced)
+	if condition == nil || condition.Status != metav1.ConditionFalse || condition.Reason != reasonSyncFailed || condition.ObservedGeneration != 2 {
+		t.Errorf("condition of failed sync = %+v", condition)
+	}
+	if _, requeueAfter := setSyncedCondition(&conditions, 3, reasonSynced, nil); requeueAfter != dbObjectsSyncInterval {
+		t.Errorf("successful sync is requeued after %s, want %s", requeueAfter, dbObjectsSyncInterval)
+	}
+	assertSynced(t, conditions)
+}
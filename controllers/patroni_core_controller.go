@@ -16,6 +16,8 @@ package controllers
 
 import (
 	"context"
+	stderrors "errors"
+	"slices"
 	"strconv"
 	"time"
 
@@ -27,6 +29,7 @@ import (
 	"github.com/Netcracker/pgskipper-operator/pkg/consul"
 	"github.com/Netcracker/pgskipper-operator/pkg/deployerrors"
 	"github.com/Netcracker/pgskipper-operator/pkg/helper"
+	"github.com/Netcracker/pgskipper-operator/pkg/metrics"
 	"github.com/Netcracker/pgskipper-operator/pkg/patroni"
 	"github.com/Netcracker/pgskipper-operator/pkg/reconciler"
 	"github.com/Netcracker/pgskipper-operator/pkg/scheduler"
@@ -52,6 +55,9 @@ import (
 
 // PatroniCoreReconciler reconciles a PatroniCore object
 
+// standbyUpgradeRecheckInterval is how often standby asks the active site if it's upgraded
+const standbyUpgradeRecheckInterval = 5 * time.Minute
+
 var (
 	MasterLabel                   = map[string]string{"pgtype": "master"}
 	patroniCoreOperatorLockCmName = "patroni-core-operator-lock"
@@ -78,22 +84,33 @@ type PatroniCoreReconciler struct {
 	logger       zap.Logger
 	resVersions  map[string]string
 	crHash       string
+	conditions   componentConditions
+	patroniStats *metrics.PatroniCollector
+	scram        scramMigration
 }
 
 func NewPatroniCoreReconciler(client client.Client, scheme *runtime.Scheme) *PatroniCoreReconciler {
 	namespace := util.GetNameSpace()
 	logger := util.GetLogger()
+	patroniHelper := helper.GetPatroniHelper()
+	patroniStats := metrics.NewPatroniCollector(&patroniHelper.ResourceManager)
+	if metrics.IsEnabled() {
+		metrics.Register(patroniStats)
+	}
 	return &PatroniCoreReconciler{
-		Client:      client,
-		Scheme:      scheme,
-		helper:      helper.GetPatroniHelper(),
-		cluster:     qubershipv1.PatroniCore{},
-		appsCluster: appsv1.PatroniServices{},
-		upgrade:     upgrade.Init(client),
-		vaultClient: vault.NewClient(),
-		namespace:   namespace,
-		logger:      *logger,
-		resVersions: map[string]string{},
+		Client:       client,
+		Scheme:       scheme,
+		helper:       patroniHelper,
+		cluster:      qubershipv1.PatroniCore{},
+		appsCluster:  appsv1.PatroniServices{},
+		upgrade:      upgrade.Init(client),
+		vaultClient:  vault.NewClient(),
+		namespace:    namespace,
+		logger:       *logger,
+		resVersions:  map[string]string{},
+		conditions:   componentConditions{kind: "PatroniCore"},
+		patroniStats: patroniStats,
+		scram:        clusterScramMigration{helper: patroniHelper},
 	}
 
 }
@@ -104,6 +121,8 @@ func NewPatroniCoreReconciler(client client.Client, scheme *runtime.Scheme) *Pat
 //+kubebuilder:rbac:groups=qubership.org,resources=postgresservices,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=qubership.org,resources=postgresservices/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=qubership.org,resources=postgresservices/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=endpoints,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=discovery.k8s.io,resources=endpointslices,verbs=get;list;create;update;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -114,12 +133,34 @@ func NewPatroniCoreReconciler(client client.Client, scheme *runtime.Scheme) *Pat
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.10.0/pkg/reconcile
 func (pr *PatroniCoreReconciler) Reconcile(ctx context.Context, request ctrl.Request) (ctrl.Result, error) {
+	clusterMutex.Lock()
+	defer clusterMutex.Unlock()
+	if running, err := isRestoreRunning(ctx, pr.Client, request.Namespace); err != nil {
+		pr.logger.Error("Cannot check running restores", zap.Error(err))
+		return reconcile.Result{RequeueAfter: time.Minute}, err
+	} else if running {
+		pr.logger.Info("Patroni cluster is being restored, reconcile is postponed")
+		return reconcile.Result{RequeueAfter: time.Minute}, nil
+	}
+	start := time.Now()
+	result, err := pr.reconcile(ctx, request)
+	metrics.ObserveReconcile(pr.conditions.kind, metrics.ComponentAll, time.Since(start), err)
+	return result, err
+}
+
+func (pr *PatroniCoreReconciler) reconcile(ctx context.Context, request ctrl.Request) (ctrl.Result, error) {
 	// Fetch the PatroniCore instance
 	cr := &qubershipv1.PatroniCore{}
 	if err := pr.Client.Get(context.TODO(), request.NamespacedName, cr); err != nil {
 		if errors.IsNotFound(err) {
 			return reconcile.Result{}, nil
 		}
+		if dropped, dropErr := dropLegacyConditions(pr.Client, qubershipv1.GroupVersion.WithKind("PatroniCore"), request.NamespacedName); dropErr != nil {
+			pr.logger.Error("Cannot check CR for legacy status conditions", zap.Error(dropErr))
+		} else if dropped {
+			pr.logger.Info("Legacy status conditions were removed from CR")
+			return reconcile.Result{Requeue: true}, nil
+		}
 		pr.logger.Error("Cannot fetch CR status", zap.Error(err))
 		if err := pr.updateStatus(Failed, "CannotFetchCrStatus",
 			fmt.Sprintf("Cannot fetch CR status. Error: %s", err.Error())); err != nil {
@@ -151,11 +192,15 @@ func (pr *PatroniCoreReconciler) Reconcile(ctx context.Context, request ctrl.Req
 	if err := pr.helper.SetCustomResource(cr); err != nil {
 		return reconcile.Result{}, err
 	}
+	if err := pr.helper.ConfigurePatroniClient(cr); err != nil {
+		pr.logger.Error("Cannot configure Patroni REST API client", zap.Error(err))
+		return reconcile.Result{RequeueAfter: time.Minute}, err
+	}
 
 	newResVersion := cr.ResourceVersion
 	newCrHash := util.HashJson(cr.Spec)
 	if (pr.resVersions[cr.Name] == newResVersion ||
-		pr.crHash == newCrHash) && len(cr.Status.Conditions) != 0 && cr.Status.Conditions[0].Type != Failed {
+		pr.crHash == newCrHash) && isReconcileSucceeded(cr.Status.Conditions) {
 		areCredsChanged, err := manager.AreCredsChanged(credentials.PostgresSecretNames)
 		if err != nil {
 			return reconcile.Result{}, err
@@ -165,7 +210,10 @@ func (pr *PatroniCoreReconciler) Reconcile(ctx context.Context, request ctrl.Req
 			if err := pr.registerInConsul(cr); err != nil {
 				return reconcile.Result{RequeueAfter: time.Minute}, err
 			}
-			return reconcile.Result{}, nil
+			migrationRecheck := pr.processAuthMigration(cr)
+			readOnlyRecheck := pr.processReadOnlyService(cr)
+			result, err := pr.processSwitchover(cr)
+			return requeueSooner(requeueSooner(result, migrationRecheck), readOnlyRecheck), err
 		}
 	}
 
@@ -174,6 +222,7 @@ func (pr *PatroniCoreReconciler) Reconcile(ctx context.Context, request ctrl.Req
 		pr.message = "Start Patroni Core cluster reconcile cycle"
 	}
 
+	pr.conditions.reset(cr.Generation)
 	pr.logger.Info(fmt.Sprintf("CR newResVersion is set to: %s Local ResVersion is set to: %s", newResVersion, pr.resVersions))
 	if err := pr.updateStatus(InProgress, "StartPostgresServiceClusterReconcile",
 		"Start Postgres Service cluster reconcile cycle"); err != nil {
@@ -190,7 +239,7 @@ func (pr *PatroniCoreReconciler) Reconcile(ctx context.Context, request ctrl.Req
 	}
 
 	//Adding condition to skip reconcile retries when majorUpgrade is enabled
-	if cr.Upgrade != nil && cr.Upgrade.Enabled {
+	if cr.Upgrade != nil && (cr.Upgrade.Enabled || cr.Upgrade.Rollback) {
 		pr.logger.Info("Skipping reconciliation retries due to major upgrade enabled")
 		maxReconcileAttempts = 1 // Set to 1 to skip retries
 	}
@@ -249,6 +298,13 @@ func (pr *PatroniCoreReconciler) Reconcile(ctx context.Context, request ctrl.Req
 	// update Cr for Vault client
 	pr.vaultClient.UpdateCr(cr.Kind)
 	if err := pr.reconcilePatroniCoreCluster(cr); err != nil {
+		if stderrors.Is(err, upgrade.ErrStandbyWaitingForActive) {
+			// upgrade of standby is resumed on requeue when the active site is upgraded, it's not a failure
+			if err := pr.updateStatus(InProgress, "StandbyUpgradeWaitingForActive", err.Error()); err != nil {
+				pr.logger.Error("Cannot update CR status", zap.Error(err))
+			}
+			return reconcile.Result{RequeueAfter: standbyUpgradeRecheckInterval}, nil
+		}
 		switch err.(type) {
 		case *deployerrors.TestsError:
 			{
@@ -302,7 +358,22 @@ func (pr *PatroniCoreReconciler) Reconcile(ctx context.Context, request ctrl.Req
 		}
 	}
 
-	err := informer.Watch(credentials.PostgresSecretNames, reconcFunc)
+	watchedSecrets := append([]string{}, credentials.PostgresSecretNames...)
+	if cr.Spec.PgBackRest != nil && cr.Spec.PgBackRest.CredentialsSecret != "" && len(cr.Spec.PgBackRest.Repositories) == 0 {
+		watchedSecrets = append(watchedSecrets, cr.Spec.PgBackRest.CredentialsSecret)
+	}
+	if cr.Spec.PgBackRest != nil {
+		for _, repository := range cr.Spec.PgBackRest.Repositories {
+			if repository.CredentialsSecret != "" && !slices.Contains(watchedSecrets, repository.CredentialsSecret) {
+				watchedSecrets = append(watchedSecrets, repository.CredentialsSecret)
+			}
+		}
+	}
+	if cr.Spec.Ldap != nil && cr.Spec.Ldap.Enabled && cr.Spec.Ldap.BindPasswordSecretName != "" &&
+		!slices.Contains(watchedSecrets, cr.Spec.Ldap.BindPasswordSecretName) {
+		watchedSecrets = append(watchedSecrets, cr.Spec.Ldap.BindPasswordSecretName)
+	}
+	err := informer.Watch(watchedSecrets, reconcFunc)
 	if err != nil {
 		pr.logger.Error("cannot start watcher", zap.Error(err))
 		return reconcile.Result{RequeueAfter: time.Minute}, err
@@ -317,15 +388,27 @@ func (pr *PatroniCoreReconciler) Reconcile(ctx context.Context, request ctrl.Req
 		if patroni.IsStandbyClusterConfigurationExist(cr) {
 			pr.logger.Info("It's standby cluster, stanza upgrade will be skipped...")
 		} else {
-			if err := pr.stanzaUpgrade(); err != nil {
+			if err := pr.conditions.track(qubershipv1.ConditionPgBackRestReady, func() error { return pr.stanzaUpgrade() }); err != nil {
+				if err := pr.updateStatus(Failed, "StanzaUpgradeFailed",
+					fmt.Sprintf("Patroni core reconcile cycle failed. Error: %s", err.Error())); err != nil {
+					pr.logger.Error("Cannot update CR status", zap.Error(err))
+				}
 				return reconcile.Result{RequeueAfter: time.Minute}, err
 			}
 		}
+	} else {
+		pr.conditions.skip(qubershipv1.ConditionPgBackRestReady)
 	}
 
 	if cr.Spec.Patroni != nil && cr.Spec.Patroni.IgnoreSlots {
 		scheduler.StartScheduler(cr)
 	}
+	if err := scheduler.ScheduleRepositoryBackups(cr); err != nil {
+		pr.logger.Error("Cannot schedule pgBackRest backups", zap.Error(err))
+	}
+	if err := scheduler.ScheduleLdapGroupSync(cr); err != nil {
+		pr.logger.Error("Cannot schedule LDAP group sync", zap.Error(err))
+	}
 	pr.errorCounter = 0
 	pr.logger.Info("Reconcile cycle succeeded")
 	pr.resVersions[cr.Name] = newResVersion
@@ -335,24 +418,31 @@ func (pr *PatroniCoreReconciler) Reconcile(ctx context.Context, request ctrl.Req
 		return reconcile.Result{RequeueAfter: time.Minute}, err
 	}
 	pr.resVersions[cr.Name] = newResVersion
-	return reconcile.Result{}, nil
+	migrationRecheck := pr.processAuthMigration(cr)
+	readOnlyRecheck := pr.processReadOnlyService(cr)
+	result, err := pr.processSwitchover(cr)
+	return requeueSooner(requeueSooner(result, migrationRecheck), readOnlyRecheck), err
 }
 
 func (pr *PatroniCoreReconciler) stanzaUpgrade() error {
 	masterPod, err := pr.helper.ResourceManager.GetPodsByLabel(MasterLabel)
-	if err != nil || len(masterPod.Items) == 0 {
+	if err != nil {
 		pr.logger.Error("Can't get Patroni Leader for stanza upgrade execution", zap.Error(err))
 		return err
 	}
+	if len(masterPod.Items) == 0 {
+		return fmt.Errorf("patroni leader pod is not found, stanza upgrade can't be executed")
+	}
 	masterPodName := masterPod.Items[0].Name
 	namespace := util.GetNameSpace()
 	pr.logger.Info("executing command to upgrade pgBackRest stanza")
 	stdout, stderr, err := pr.helper.ExecCmdOnPod(masterPodName, namespace, backRestcontainerName, stanzaUpgradeCommand)
 	if err != nil {
-		fmt.Printf("Failed to execute stanza-upgrade command: %v\nStderr: %s\n", err, stderr)
-	} else {
-		fmt.Printf("stanza-upgrade command succeeded:\n%s\n", stdout)
+		pr.logger.Error(fmt.Sprintf("Failed to execute stanza-upgrade command, stderr: %s", stderr), zap.Error(err))
+		return fmt.Errorf("stanza-upgrade failed: %w", err)
 	}
+	pr.logger.Info(fmt.Sprintf("stanza-upgrade command succeeded: %s", stdout))
+	metrics.StanzaUpgradeSucceeded()
 	return nil
 }
 
@@ -377,7 +467,7 @@ func (pr *PatroniCoreReconciler) reconcilePatroniCoreCluster(cr *qubershipv1.Pat
 	consulRegistrationRequired := true
 	// reconcile Patroni
 	if cr.Spec.Patroni != nil {
-		if err := pr.reconcilePatroni(cr); err != nil {
+		if err := pr.conditions.track(qubershipv1.ConditionPatroniReady, func() error { return pr.reconcilePatroni(cr) }); err != nil {
 			return err
 		}
 		if patroni.IsStandbyClusterConfigurationExist(cr) {
@@ -385,25 +475,31 @@ func (pr *PatroniCoreReconciler) reconcilePatroniCoreCluster(cr *qubershipv1.Pat
 		}
 	} else {
 		pr.logger.Info("Patroni Spec is empty. Skip Patroni reconcilation")
+		pr.conditions.skip(qubershipv1.ConditionPatroniReady)
 	}
 
 	if consulRegistrationRequired {
 		// if everything is OK proceed with registration in Consul
-		if err := pr.registerInConsul(cr); err != nil {
+		if err := pr.conditions.track(qubershipv1.ConditionConsulRegistrationReady, func() error { return pr.registerInConsul(cr) }); err != nil {
 			return err
 		}
+	} else {
+		pr.conditions.skip(qubershipv1.ConditionConsulRegistrationReady)
 	}
 
 	if cr.Spec.IntegrationTests != nil {
 		if cr.Spec.Patroni.StandbyCluster == nil {
 			pr.logger.Info("Tests Spec is not empty, proceeding with reconcile")
-			if err := pr.createTestsPods(cr); err != nil {
+			if err := pr.conditions.track(qubershipv1.ConditionIntegrationTestsReady, func() error { return pr.createTestsPods(cr) }); err != nil {
 				pr.logger.Error("Can not synchronize Tests state to cluster", zap.Error(err))
 				return err
 			}
 		} else {
 			pr.logger.Info("StandbyCluster is configured, skipping integration tests reconciliation")
+			pr.conditions.skip(qubershipv1.ConditionIntegrationTestsReady)
 		}
+	} else {
+		pr.conditions.skip(qubershipv1.ConditionIntegrationTestsReady)
 	}
 	return nil
 }
@@ -418,11 +514,13 @@ func (pr *PatroniCoreReconciler) reconcilePatroni(cr *qubershipv1.PatroniCore) e
 			return nil
 		}
 	}
-	pRec := reconciler.NewPatroniReconciler(cr, pr.helper, pr.vaultClient, pr.upgrade, pr.Scheme, utils.GetPatroniClusterSettings(cr.Spec.Patroni.ClusterName))
+	clusterSettings := utils.GetPatroniClusterSettings(cr.Spec.Patroni.ClusterName)
+	pRec := reconciler.NewPatroniReconciler(cr, pr.helper, pr.vaultClient, pr.upgrade, pr.Scheme, clusterSettings)
 	if err := pRec.Reconcile(); err != nil {
 		pr.logger.Error("Can not synchronize desired Patroni state to cluster", zap.Error(err))
 		return err
 	}
+	pr.patroniStats.SetCluster(clusterSettings.ClusterName, clusterSettings.PatroniUrl)
 	return nil
 }
 func (pr *PatroniCoreReconciler) registerInConsul(cr *qubershipv1.PatroniCore) error {
@@ -0,0 +1,233 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Netcracker/pgskipper-operator-core/pkg/util"
+	qubershipv1 "github.com/Netcracker/pgskipper-operator/api/patroni/v1"
+	"github.com/Netcracker/pgskipper-operator/pkg/helper"
+	"github.com/Netcracker/pgskipper-operator/pkg/patroni"
+	"github.com/Netcracker/pgskipper-operator/pkg/pgbackrest"
+	utils "github.com/Netcracker/pgskipper-operator/pkg/util"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	backupPollInterval = 30 * time.Second
+	// backupStartTimeout is the time given to pgBackRest to take the stanza lock
+	backupStartTimeout = 2 * time.Minute
+)
+
+// PostgresBackupReconciler performs on-demand pgBackRest backups
+type PostgresBackupReconciler struct {
+	Client   client.Client
+	Scheme   *runtime.Scheme
+	helper   *helper.PatroniHelper
+	sidecar  *pgbackrest.Sidecar
+	executor pgbackrest.Executor
+	logger   zap.Logger
+}
+
+func NewPostgresBackupReconciler(client client.Client, scheme *runtime.Scheme) *PostgresBackupReconciler {
+	patroniHelper := helper.GetPatroniHelper()
+	return &PostgresBackupReconciler{
+		Client:   client,
+		Scheme:   scheme,
+		helper:   patroniHelper,
+		sidecar:  pgbackrest.NewSidecar(pgbackrest.SidecarUrl),
+		executor: patroniHelper,
+		logger:   *util.GetLogger(),
+	}
+}
+
+//+kubebuilder:rbac:groups=qubership.org,resources=postgresbackups,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=qubership.org,resources=postgresbackups/status,verbs=get;update;patch
+
+func (r *PostgresBackupReconciler) Reconcile(ctx context.Context, request ctrl.Request) (ctrl.Result, error) {
+	backup := &qubershipv1.PostgresBackup{}
+	if err := r.Client.Get(ctx, request.NamespacedName, backup); err != nil {
+		if errors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{RequeueAfter: time.Minute}, err
+	}
+	switch backup.Status.Phase {
+	case qubershipv1.PhaseSucceeded, qubershipv1.PhaseFailed:
+		return reconcile.Result{}, nil
+	case qubershipv1.PhaseRunning:
+		return r.checkBackup(ctx, backup)
+	default:
+		return r.startBackup(ctx, backup)
+	}
+}
+
+func (r *PostgresBackupReconciler) startBackup(ctx context.Context, backup *qubershipv1.PostgresBackup) (ctrl.Result, error) {
+	cr, err := r.helper.GetPatroniCoreCR()
+	if err != nil {
+		return reconcile.Result{RequeueAfter: time.Minute}, err
+	}
+	if cr.Spec == nil || cr.Spec.Patroni == nil || cr.Spec.PgBackRest == nil {
+		return reconcile.Result{}, r.finish(ctx, backup, qubershipv1.PhaseFailed, "PgBackRestDisabled",
+			"pgBackRest is not enabled in PatroniCore")
+	}
+	if patroni.IsStandbyClusterConfigurationExist(cr) {
+		return reconcile.Result{}, r.finish(ctx, backup, qubershipv1.PhaseFailed, "StandbyCluster",
+			"Backups are not performed on standby cluster")
+	}
+	leader, err := r.getLeaderPod(cr)
+	if err != nil {
+		return r.wait(ctx, backup, "WaitingForLeader", err.Error())
+	}
+	info, err := pgbackrest.GetInfo(r.executor, leader)
+	if err != nil {
+		return r.wait(ctx, backup, "PgBackRestUnavailable", err.Error())
+	}
+	if info.IsBackupRunning() {
+		return r.wait(ctx, backup, "AnotherBackupRunning", "Another backup is running, waiting for it to finish")
+	}
+
+	backupType := backup.Spec.Type
+	if backupType == "" {
+		backupType = "full"
+	}
+	if err := r.sidecar.StartBackup(leader, backupType, backup.Spec.Repo, string(backup.UID)); err != nil {
+		return r.wait(ctx, backup, "BackupNotStarted", err.Error())
+	}
+	startTime := metav1.Now()
+	backup.Status.Phase = qubershipv1.PhaseRunning
+	backup.Status.Pod = leader
+	backup.Status.StartTime = &startTime
+	r.setCondition(backup, metav1.ConditionUnknown, "BackupRunning", fmt.Sprintf("%s backup is running on %s", backupType, leader))
+	return reconcile.Result{RequeueAfter: backupPollInterval}, r.Client.Status().Update(ctx, backup)
+}
+
+func (r *PostgresBackupReconciler) checkBackup(ctx context.Context, backup *qubershipv1.PostgresBackup) (ctrl.Result, error) {
+	id := string(backup.UID)
+	podName := backup.Status.Pod
+	state, err := r.sidecar.GetBackupState(podName, id)
+	if err != nil {
+		// pod is possibly restarted, the result is looked for in repository
+		r.logger.Info(fmt.Sprintf("Cannot get backup state from %s", podName), zap.Error(err))
+		cr, crErr := r.helper.GetPatroniCoreCR()
+		if crErr != nil {
+			return reconcile.Result{RequeueAfter: backupPollInterval}, crErr
+		}
+		if podName, err = r.getLeaderPod(cr); err != nil {
+			return reconcile.Result{RequeueAfter: backupPollInterval}, nil
+		}
+	} else if state.Finished && state.ExitCode != 0 {
+		return reconcile.Result{}, r.finish(ctx, backup, qubershipv1.PhaseFailed, "BackupFailed",
+			fmt.Sprintf("pgbackrest exited with code %d: %s", state.ExitCode, state.Output))
+	}
+
+	info, err := pgbackrest.GetInfo(r.executor, podName)
+	if err != nil {
+		r.logger.Error("Cannot get pgBackRest info", zap.Error(err))
+		return reconcile.Result{RequeueAfter: backupPollInterval}, nil
+	}
+	if result := info.FindBackup(id); result != nil {
+		fillBackupStatus(&backup.Status, result)
+		return reconcile.Result{}, r.finish(ctx, backup, qubershipv1.PhaseSucceeded, "BackupSucceeded",
+			fmt.Sprintf("Backup %s is completed", result.Label))
+	}
+	if state.Finished {
+		return reconcile.Result{}, r.finish(ctx, backup, qubershipv1.PhaseFailed, "BackupNotFound",
+			"pgbackrest is finished, but the backup is not found in repository")
+	}
+	if !info.IsBackupRunning() && time.Since(backup.Status.StartTime.Time) > backupStartTimeout {
+		return reconcile.Result{}, r.finish(ctx, backup, qubershipv1.PhaseFailed, "BackupLost",
+			fmt.Sprintf("Backup is not running anymore, probably %s was restarted", backup.Status.Pod))
+	}
+	return reconcile.Result{RequeueAfter: backupPollInterval}, nil
+}
+
+func fillBackupStatus(status *qubershipv1.PostgresBackupStatus, result *pgbackrest.BackupInfo) {
+	status.Label = result.Label
+	status.Repo = result.Database.RepoKey
+	status.Size = result.Info.Size
+	status.RepoSize = result.Info.Repository.Size
+	status.WalStart = result.Archive.Start
+	status.WalStop = result.Archive.Stop
+	status.LsnStart = result.Lsn.Start
+	status.LsnStop = result.Lsn.Stop
+	status.Duration = (time.Duration(result.Timestamp.Stop-result.Timestamp.Start) * time.Second).String()
+	completionTime := metav1.NewTime(time.Unix(result.Timestamp.Stop, 0))
+	status.CompletionTime = &completionTime
+}
+
+func (r *PostgresBackupReconciler) getLeaderPod(cr *qubershipv1.PatroniCore) (string, error) {
+	clusterSettings := utils.GetPatroniClusterSettings(cr.Spec.Patroni.ClusterName)
+	pods, err := r.helper.GetPodsByLabel(clusterSettings.PatroniMasterSelectors)
+	if err != nil {
+		return "", err
+	}
+	if len(pods.Items) == 0 {
+		return "", fmt.Errorf("patroni cluster %s has no leader", clusterSettings.ClusterName)
+	}
+	return pods.Items[0].Name, nil
+}
+
+// wait keeps backup pending and retries it later
+func (r *PostgresBackupReconciler) wait(ctx context.Context, backup *qubershipv1.PostgresBackup, reason string, message string) (ctrl.Result, error) {
+	r.logger.Info(fmt.Sprintf("Backup %s is pending: %s", backup.Name, message))
+	backup.Status.Phase = qubershipv1.PhasePending
+	r.setCondition(backup, metav1.ConditionUnknown, reason, message)
+	return reconcile.Result{RequeueAfter: backupPollInterval}, r.Client.Status().Update(ctx, backup)
+}
+
+func (r *PostgresBackupReconciler) finish(ctx context.Context, backup *qubershipv1.PostgresBackup, phase string, reason string, message string) error {
+	status := metav1.ConditionTrue
+	if phase == qubershipv1.PhaseFailed {
+		status = metav1.ConditionFalse
+		r.logger.Error(fmt.Sprintf("Backup %s failed: %s", backup.Name, message))
+	} else {
+		r.logger.Info(fmt.Sprintf("Backup %s succeeded: %s", backup.Name, message))
+	}
+	backup.Status.Phase = phase
+	if backup.Status.CompletionTime == nil {
+		completionTime := metav1.Now()
+		backup.Status.CompletionTime = &completionTime
+	}
+	r.setCondition(backup, status, reason, message)
+	return r.Client.Status().Update(ctx, backup)
+}
+
+func (r *PostgresBackupReconciler) setCondition(backup *qubershipv1.PostgresBackup, status metav1.ConditionStatus, reason string, message string) {
+	meta.SetStatusCondition(&backup.Status.Conditions, metav1.Condition{
+		Type:               qubershipv1.ConditionCompleted,
+		Status:             status,
+		ObservedGeneration: backup.Generation,
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PostgresBackupReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&qubershipv1.PostgresBackup{}).
+		Complete(r)
+}
@@ -0,0 +1,334 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	qubershipv1 "github.com/Netcracker/pgskipper-operator/api/patroni/v1"
+	"github.com/Netcracker/pgskipper-operator/pkg/helper"
+	"github.com/Netcracker/pgskipper-operator/pkg/pgbackrest"
+	"github.com/Netcracker/pgskipper-operator/pkg/util/testenv"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const (
+	emptyInfo  = `[{"name":"patroni","backup":[],"status":{"code":0}}]`
+	lockedInfo = `[{"name":"patroni","backup":[],"status":{"code":0,"lock":{"backup":{"held":true}}}}]`
+)
+
+// backupInfo returns pgBackRest info with a full backup made for PostgresBackup with the UID
+func backupInfo(id string, label string, repo int) string {
+	return fmt.Sprintf(`[{"name":"patroni","backup":[{"label":%q,"type":"full","error":false,
+"annotation":{"postgres-backup":%q},"database":{"repo-key":%d},"archive":{"start":"000000010000000000000003","stop":"000000010000000000000004"},
+"info":{"size":1024,"repository":{"size":512}},"timestamp":{"start":1726704000,"stop":1726704060}}],"status":{"code":0}}]`, label, id, repo)
+}
+
+// fakeExecutor answers `pgbackrest info` executed in the sidecar with the current info
+type fakeExecutor struct {
+	mu   sync.Mutex
+	info string
+	pods []string
+}
+
+func (f *fakeExecutor) ExecCmdOnPod(podName string, namespace string, container string, command string) (string, string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !strings.Contains(command, " info --output=json") {
+		return "", "", fmt.Errorf("unexpected command %s", command)
+	}
+	f.pods = append(f.pods, podName)
+	return f.info, "", nil
+}
+
+func (f *fakeExecutor) setInfo(info string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.info = info
+}
+
+// fakeBackupSidecar emulates REST API of pgbackrest-sidecar containers, pods are distinguished by the path prefix
+type fakeBackupSidecar struct {
+	mu       sync.Mutex
+	server   *httptest.Server
+	requests map[string][]pgbackrest.BackupRequest
+	backups  map[string]pgbackrest.BackupStatus
+}
+
+func newFakeBackupSidecar(t *testing.T) *fakeBackupSidecar {
+	f := &fakeBackupSidecar{requests: map[string][]pgbackrest.BackupRequest{}, backups: map[string]pgbackrest.BackupStatus{}}
+	f.server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+func (f *fakeBackupSidecar) url(podName string) string {
+	return f.server.URL + "/" + podName
+}
+
+func (f *fakeBackupSidecar) serve(w http.ResponseWriter, req *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	podName, path, _ := strings.Cut(strings.TrimPrefix(req.URL.Path, "/"), "/")
+	switch {
+	case req.Method == http.MethodPost && path == "backup":
+		var request pgbackrest.BackupRequest
+		if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.requests[podName] = append(f.requests[podName], request)
+		f.backups[podName+"/"+request.Id] = pgbackrest.BackupStatus{Id: request.Id, Status: "running"}
+		w.WriteHeader(http.StatusAccepted)
+	case req.Method == http.MethodGet && strings.HasPrefix(path, "backup/"):
+		status, ok := f.backups[podName+"/"+strings.TrimPrefix(path, "backup/")]
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(status)
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+func (f *fakeBackupSidecar) finish(podName string, id string, exitCode int, output string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.backups[podName+"/"+id] = pgbackrest.BackupStatus{Id: id, Status: "finished", ExitCode: exitCode, Output: output}
+}
+
+// restart forgets backups of the pod, the same as restart of the sidecar does
+func (f *fakeBackupSidecar) restart(podName string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for key := range f.backups {
+		if strings.HasPrefix(key, podName+"/") {
+			delete(f.backups, key)
+		}
+	}
+}
+
+func (f *fakeBackupSidecar) backupRequests(podName string) []pgbackrest.BackupRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests[podName]
+}
+
+func newPgBackRestFakeClient(t *testing.T, objects ...client.Object) client.Client {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := qubershipv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).
+		WithStatusSubresource(&qubershipv1.PostgresBackup{}, &qubershipv1.PostgresRestore{}).Build()
+}
+
+func pgBackRestPatroniCore() *qubershipv1.PatroniCore {
+	return &qubershipv1.PatroniCore{
+		ObjectMeta: metav1.ObjectMeta{Name: "patroni-core", Namespace: testenv.Namespace},
+		Spec: &qubershipv1.PatroniCoreSpec{
+			Patroni: &qubershipv1.Patroni{
+				ClusterName: "patroni",
+				Replicas:    2,
+				DockerImage: "patroni:new",
+				Dcs:         qubershipv1.Dcs{Type: "kubernetes"},
+			},
+			PgBackRest: &qubershipv1.PgBackRest{},
+		},
+	}
+}
+
+// patroniPod returns pod of Patroni member with the role, empty role means the member is not started yet
+func patroniPod(name string, role string, phase corev1.PodPhase) *corev1.Pod {
+	labels := map[string]string{"app": "patroni", "pgcluster": "patroni"}
+	if role != "" {
+		labels["pgtype"] = role
+	}
+	member := strings.TrimSuffix(name, "-0")
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testenv.Namespace, Labels: labels},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: member}}},
+		Status:     corev1.PodStatus{Phase: phase},
+	}
+}
+
+func newTestBackupReconciler(kubeClient client.Client, sidecar *fakeBackupSidecar, executor *fakeExecutor) *PostgresBackupReconciler {
+	return &PostgresBackupReconciler{
+		Client:   kubeClient,
+		helper:   helper.NewPatroniHelper(kubeClient),
+		sidecar:  pgbackrest.NewSidecar(sidecar.url),
+		executor: executor,
+		logger:   *zap.NewNop(),
+	}
+}
+
+func newPostgresBackup(name string, uid string) *qubershipv1.PostgresBackup {
+	return &qubershipv1.PostgresBackup{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testenv.Namespace, UID: types.UID(uid)},
+		Spec:       qubershipv1.PostgresBackupSpec{Repo: 2},
+	}
+}
+
+func reconcileBackup(t *testing.T, r *PostgresBackupReconciler, name string) *qubershipv1.PostgresBackup {
+	t.Helper()
+	if _, err := r.Reconcile(context.Background(), reconcileRequest(name)); err != nil {
+		t.Fatalf("reconcile of %s failed: %v", name, err)
+	}
+	backup := &qubershipv1.PostgresBackup{}
+	if err := r.Client.Get(context.Background(), types.NamespacedName{Name: name, Namespace: testenv.Namespace}, backup); err != nil {
+		t.Fatal(err)
+	}
+	return backup
+}
+
+func assertCompleted(t *testing.T, conditions []metav1.Condition, status metav1.ConditionStatus, reason string) {
+	t.Helper()
+	condition := meta.FindStatusCondition(conditions, qubershipv1.ConditionCompleted)
+	if condition == nil || condition.Status != status || condition.Reason != reason {
+		t.Errorf("Completed condition = %+v, want %s with reason %s", condition, status, reason)
+	}
+}
+
+func TestPostgresBackupSucceeded(t *testing.T) {
+	kubeClient := newPgBackRestFakeClient(t, pgBackRestPatroniCore(),
+		patroniPod("pg-patroni-node1-0", "master", corev1.PodRunning),
+		patroniPod("pg-patroni-node2-0", "replica", corev1.PodRunning),
+		newPostgresBackup("backup-1", "uid-1"))
+	sidecar := newFakeBackupSidecar(t)
+	executor := &fakeExecutor{info: emptyInfo}
+	r := newTestBackupReconciler(kubeClient, sidecar, executor)
+
+	backup := reconcileBackup(t, r, "backup-1")
+	if backup.Status.Phase != qubershipv1.PhaseRunning || backup.Status.Pod != "pg-patroni-node1-0" {
+		t.Fatalf("backup is %s on %s, expected running on the leader", backup.Status.Phase, backup.Status.Pod)
+	}
+	requests := sidecar.backupRequests("pg-patroni-node1-0")
+	expected := pgbackrest.BackupRequest{Id: "uid-1", Type: "full", Repo: 2, Annotation: map[string]string{"postgres-backup": "uid-1"}}
+	if len(requests) != 1 || !reflect.DeepEqual(requests[0], expected) {
+		t.Errorf("sidecar received %+v, expected %+v", requests, expected)
+	}
+
+	if backup = reconcileBackup(t, r, "backup-1"); backup.Status.Phase != qubershipv1.PhaseRunning {
+		t.Errorf("running backup is %s", backup.Status.Phase)
+	}
+
+	sidecar.finish("pg-patroni-node1-0", "uid-1", 0, "backup command end: completed successfully")
+	executor.setInfo(backupInfo("uid-1", "20240919-000000F", 2))
+	backup = reconcileBackup(t, r, "backup-1")
+	if backup.Status.Phase != qubershipv1.PhaseSucceeded {
+		t.Fatalf("finished backup is %s", backup.Status.Phase)
+	}
+	if backup.Status.Label != "20240919-000000F" || backup.Status.Repo != 2 || backup.Status.Size != 1024 ||
+		backup.Status.WalStop != "000000010000000000000004" || backup.Status.Duration != "1m0s" {
+		t.Errorf("backup status is %+v", backup.Status)
+	}
+	assertCompleted(t, backup.Status.Conditions, metav1.ConditionTrue, "BackupSucceeded")
+	if len(sidecar.backupRequests("pg-patroni-node1-0")) != 1 {
+		t.Errorf("backup is started more than once")
+	}
+}
+
+func TestPostgresBackupFailed(t *testing.T) {
+	kubeClient := newPgBackRestFakeClient(t, pgBackRestPatroniCore(),
+		patroniPod("pg-patroni-node1-0", "master", corev1.PodRunning),
+		newPostgresBackup("backup-1", "uid-1"))
+	sidecar := newFakeBackupSidecar(t)
+	r := newTestBackupReconciler(kubeClient, sidecar, &fakeExecutor{info: emptyInfo})
+
+	reconcileBackup(t, r, "backup-1")
+	sidecar.finish("pg-patroni-node1-0", "uid-1", 56, "ERROR: [056]: unable to find primary cluster")
+	backup := reconcileBackup(t, r, "backup-1")
+	if backup.Status.Phase != qubershipv1.PhaseFailed {
+		t.Fatalf("failed backup is %s", backup.Status.Phase)
+	}
+	assertCompleted(t, backup.Status.Conditions, metav1.ConditionFalse, "BackupFailed")
+	if condition := meta.FindStatusCondition(backup.Status.Conditions, qubershipv1.ConditionCompleted); !strings.Contains(condition.Message, "unable to find primary") {
+		t.Errorf("output of pgbackrest is not reported: %s", condition.Message)
+	}
+}
+
+func TestPostgresBackupWaitsForRunningBackup(t *testing.T) {
+	kubeClient := newPgBackRestFakeClient(t, pgBackRestPatroniCore(),
+		patroniPod("pg-patroni-node1-0", "master", corev1.PodRunning),
+		newPostgresBackup("backup-1", "uid-1"))
+	sidecar := newFakeBackupSidecar(t)
+	executor := &fakeExecutor{info: lockedInfo}
+	r := newTestBackupReconciler(kubeClient, sidecar, executor)
+
+	backup := reconcileBackup(t, r, "backup-1")
+	if backup.Status.Phase != qubershipv1.PhasePending {
+		t.Fatalf("backup is %s while another backup is running", backup.Status.Phase)
+	}
+	assertCompleted(t, backup.Status.Conditions, metav1.ConditionUnknown, "AnotherBackupRunning")
+	if len(sidecar.backupRequests("pg-patroni-node1-0")) != 0 {
+		t.Errorf("backup is started while another backup is running")
+	}
+
+	executor.setInfo(emptyInfo)
+	if backup = reconcileBackup(t, r, "backup-1"); backup.Status.Phase != qubershipv1.PhaseRunning {
+		t.Errorf("backup is %s after another backup is finished", backup.Status.Phase)
+	}
+}
+
+func TestPostgresBackupIsFoundAfterSidecarRestart(t *testing.T) {
+	kubeClient := newPgBackRestFakeClient(t, pgBackRestPatroniCore(),
+		patroniPod("pg-patroni-node1-0", "master", corev1.PodRunning),
+		newPostgresBackup("backup-1", "uid-1"))
+	sidecar := newFakeBackupSidecar(t)
+	executor := &fakeExecutor{info: emptyInfo}
+	r := newTestBackupReconciler(kubeClient, sidecar, executor)
+
+	reconcileBackup(t, r, "backup-1")
+	sidecar.restart("pg-patroni-node1-0")
+	executor.setInfo(backupInfo("uid-1", "20240919-000000F", 2))
+	backup := reconcileBackup(t, r, "backup-1")
+	if backup.Status.Phase != qubershipv1.PhaseSucceeded || backup.Status.Label != "20240919-000000F" {
+		t.Errorf("backup found in repository is %s, label %s", backup.Status.Phase, backup.Status.Label)
+	}
+}
+
+func TestPostgresBackupWithoutPgBackRest(t *testing.T) {
+	cr := pgBackRestPatroniCore()
+	cr.Spec.PgBackRest = nil
+	kubeClient := newPgBackRestFakeClient(t, cr, newPostgresBackup("backup-1", "uid-1"))
+	r := newTestBackupReconciler(kubeClient, newFakeBackupSidecar(t), &fakeExecutor{info: emptyInfo})
+
+	backup := reconcileBackup(t, r, "backup-1")
+	if backup.Status.Phase != qubershipv1.PhaseFailed {
+		t.Fatalf("backup is %s without pgBackRest", backup.Status.Phase)
+	}
+	assertCompleted(t, backup.Status.Conditions, metav1.ConditionFalse, "PgBackRestDisabled")
+}
@@ -0,0 +1,118 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Netcracker/pgskipper-operator-core/pkg/util"
+	qubershipv1 "github.com/Netcracker/pgskipper-operator/api/apps/v1"
+	pgClient "github.com/Netcracker/pgskipper-operator/pkg/client"
+	"github.com/Netcracker/pgskipper-operator/pkg/dbobjects"
+	"github.com/Netcracker/pgskipper-operator/pkg/helper"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const postgresDatabaseFinalizer = "qubership.org/postgres-database"
+
+// PostgresDatabaseReconciler creates databases described by PostgresDatabase and corrects their drift
+type PostgresDatabaseReconciler struct {
+	Client client.Client
+	Scheme *runtime.Scheme
+	helper *helper.Helper
+	logger zap.Logger
+	// getPgClient returns client of PostgreSQL cluster the objects are created in
+	getPgClient dbObjectsClientFunc
+}
+
+func NewPostgresDatabaseReconciler(client client.Client, scheme *runtime.Scheme) *PostgresDatabaseReconciler {
+	h := helper.GetHelper()
+	return &PostgresDatabaseReconciler{
+		Client: client,
+		Scheme: scheme,
+		helper: h,
+		logger: *util.GetLogger(),
+		getPgClient: func() (*pgClient.PostgresClient, error) {
+			return getDBObjectsClient(h)
+		},
+	}
+}
+
+//+kubebuilder:rbac:groups=qubership.org,resources=postgresdatabases,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=qubership.org,resources=postgresdatabases/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=qubership.org,resources=postgresdatabases/finalizers,verbs=update
+
+func (r *PostgresDatabaseReconciler) Reconcile(ctx context.Context, request ctrl.Request) (ctrl.Result, error) {
+	db := &qubershipv1.PostgresDatabase{}
+	if err := r.Client.Get(ctx, request.NamespacedName, db); err != nil {
+		if errors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{RequeueAfter: dbObjectsRetryInterval}, err
+	}
+	name := db.GetDatabaseName()
+
+	if !db.DeletionTimestamp.IsZero() {
+		if !controllerutil.ContainsFinalizer(db, postgresDatabaseFinalizer) {
+			return reconcile.Result{}, nil
+		}
+		if db.Spec.DeletionPolicy == qubershipv1.DeletionPolicyDrop {
+			pgC, err := r.getPgClient()
+			if err != nil {
+				return reconcile.Result{RequeueAfter: dbObjectsRetryInterval}, err
+			}
+			if err := dbobjects.DropDatabase(pgC, name); err != nil {
+				r.logger.Error(fmt.Sprintf("Cannot drop database %s", name), zap.Error(err))
+				return reconcile.Result{RequeueAfter: dbObjectsRetryInterval}, err
+			}
+		}
+		controllerutil.RemoveFinalizer(db, postgresDatabaseFinalizer)
+		return reconcile.Result{}, r.Client.Update(ctx, db)
+	}
+	if controllerutil.AddFinalizer(db, postgresDatabaseFinalizer) {
+		if err := r.Client.Update(ctx, db); err != nil {
+			return reconcile.Result{RequeueAfter: dbObjectsRetryInterval}, err
+		}
+	}
+
+	reason := reasonSynced
+	pgC, err := r.getPgClient()
+	if err != nil {
+		reason = reasonPostgreSQLUnavailable
+	} else if err = dbobjects.SyncDatabase(pgC, name, &db.Spec); err != nil {
+		reason = reasonSyncFailed
+	}
+	if err != nil {
+		r.logger.Error(fmt.Sprintf("Cannot sync database %s", name), zap.Error(err))
+	}
+	db.Status.ObservedGeneration = db.Generation
+	lastSyncTime, requeueAfter := setSyncedCondition(&db.Status.Conditions, db.Generation, reason, err)
+	db.Status.LastSyncTime = lastSyncTime
+	return reconcile.Result{RequeueAfter: requeueAfter}, r.Client.Status().Update(ctx, db)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PostgresDatabaseReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&qubershipv1.PostgresDatabase{}).
+		Complete(r)
+}
@@ -0,0 +1,243 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Netcracker/pgskipper-operator-core/pkg/util"
+	qubershipv1 "github.com/Netcracker/pgskipper-operator/api/patroni/v1"
+	"github.com/Netcracker/pgskipper-operator/pkg/helper"
+	"github.com/Netcracker/pgskipper-operator/pkg/patroni"
+	"github.com/Netcracker/pgskipper-operator/pkg/pgbackrest"
+	utils "github.com/Netcracker/pgskipper-operator/pkg/util"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// clusterMutex serializes PatroniCore reconcile and restore steps, both of them scale Patroni statefulsets
+var clusterMutex sync.Mutex
+
+// restoreStepInterval is the interval of checking the result of current restore step
+const restoreStepInterval = 10 * time.Second
+
+// PostgresRestoreReconciler restores Patroni cluster from pgBackRest repository
+type PostgresRestoreReconciler struct {
+	Client  client.Client
+	Scheme  *runtime.Scheme
+	helper  *helper.PatroniHelper
+	restore *pgbackrest.Restore
+	logger  zap.Logger
+}
+
+func NewPostgresRestoreReconciler(client client.Client, scheme *runtime.Scheme) *PostgresRestoreReconciler {
+	patroniHelper := helper.GetPatroniHelper()
+	return &PostgresRestoreReconciler{
+		Client:  client,
+		Scheme:  scheme,
+		helper:  patroniHelper,
+		restore: pgbackrest.NewRestore(patroniHelper, patroniHelper),
+		logger:  *util.GetLogger(),
+	}
+}
+
+//+kubebuilder:rbac:groups=qubership.org,resources=postgresrestores,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=qubership.org,resources=postgresrestores/status,verbs=get;update;patch
+
+func (r *PostgresRestoreReconciler) Reconcile(ctx context.Context, request ctrl.Request) (ctrl.Result, error) {
+	restore := &qubershipv1.PostgresRestore{}
+	if err := r.Client.Get(ctx, request.NamespacedName, restore); err != nil {
+		if errors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{RequeueAfter: time.Minute}, err
+	}
+	switch restore.Status.Phase {
+	case qubershipv1.PhaseSucceeded, qubershipv1.PhaseFailed:
+		return reconcile.Result{}, nil
+	case qubershipv1.PhaseRunning:
+		return r.proceed(ctx, restore)
+	}
+
+	cr, err := r.helper.GetPatroniCoreCR()
+	if err != nil {
+		return reconcile.Result{RequeueAfter: time.Minute}, err
+	}
+	if cr.Spec == nil || cr.Spec.Patroni == nil || cr.Spec.PgBackRest == nil {
+		return reconcile.Result{}, r.finish(ctx, restore, qubershipv1.PhaseFailed, "PgBackRestDisabled",
+			"pgBackRest is not enabled in PatroniCore")
+	}
+	if patroni.IsStandbyClusterConfigurationExist(cr) {
+		return reconcile.Result{}, r.finish(ctx, restore, qubershipv1.PhaseFailed, "StandbyCluster",
+			"Restore is not supported on standby cluster")
+	}
+	if err := pgbackrest.ValidateTarget(restore.Spec.Target); err != nil {
+		return reconcile.Result{}, r.finish(ctx, restore, qubershipv1.PhaseFailed, "InvalidSpec", err.Error())
+	}
+	if err := pgbackrest.ValidateLabel(restore.Spec.BackupLabel); err != nil {
+		return reconcile.Result{}, r.finish(ctx, restore, qubershipv1.PhaseFailed, "InvalidSpec", err.Error())
+	}
+	label, repo, ready, err := r.getBackupLabel(ctx, restore)
+	if err != nil {
+		return reconcile.Result{}, r.finish(ctx, restore, qubershipv1.PhaseFailed, "InvalidBackup", err.Error())
+	}
+	if !ready {
+		restore.Status.Phase = qubershipv1.PhasePending
+		r.setCondition(restore, metav1.ConditionUnknown, "WaitingForBackup",
+			fmt.Sprintf("Waiting for PostgresBackup %s to complete", restore.Spec.BackupName))
+		return reconcile.Result{RequeueAfter: backupPollInterval}, r.Client.Status().Update(ctx, restore)
+	}
+
+	startTime := metav1.Now()
+	restore.Status.Phase = qubershipv1.PhaseRunning
+	restore.Status.BackupLabel = label
+	restore.Status.Repo = repo
+	restore.Status.StartTime = &startTime
+	r.setCondition(restore, metav1.ConditionUnknown, "RestoreRunning", "Patroni cluster is being restored")
+	if err := r.Client.Status().Update(ctx, restore); err != nil {
+		return reconcile.Result{RequeueAfter: time.Minute}, err
+	}
+	r.logger.Info(fmt.Sprintf("Starting restore %s, backup: %s", restore.Name, label))
+	return r.proceed(ctx, restore)
+}
+
+// proceed performs the next step of running restore and requeues the restore until it's completed.
+// Steps don't wait for pods, so PatroniCore reconcile is locked only while the step is performed.
+func (r *PostgresRestoreReconciler) proceed(ctx context.Context, restore *qubershipv1.PostgresRestore) (ctrl.Result, error) {
+	cr, err := r.helper.GetPatroniCoreCR()
+	if err != nil {
+		return reconcile.Result{RequeueAfter: restoreStepInterval}, err
+	}
+	if cr.Spec == nil || cr.Spec.Patroni == nil {
+		return reconcile.Result{}, r.finish(ctx, restore, qubershipv1.PhaseFailed, "RestoreFailed",
+			"Patroni is not enabled in PatroniCore")
+	}
+	clusterMutex.Lock()
+	defer clusterMutex.Unlock()
+	clusterSettings := utils.GetPatroniClusterSettings(cr.Spec.Patroni.ClusterName)
+	done, err := r.restore.Proceed(cr, clusterSettings, string(restore.UID), restore.Spec.Target, &restore.Status)
+	if err != nil {
+		return reconcile.Result{}, r.finish(ctx, restore, qubershipv1.PhaseFailed, "RestoreFailed", err.Error())
+	}
+	if done {
+		return reconcile.Result{}, r.finish(ctx, restore, qubershipv1.PhaseSucceeded, "RestoreSucceeded",
+			fmt.Sprintf("Patroni cluster is restored, leader: %s", restore.Status.Leader))
+	}
+	// step is already performed, so it must not be lost, otherwise it's repeated on the next reconcile
+	return reconcile.Result{RequeueAfter: restoreStepInterval}, r.saveStatus(ctx, restore)
+}
+
+// isRestoreRunning returns true if Patroni cluster is being restored in the namespace,
+// PatroniCore reconcile must not scale the cluster back meanwhile
+func isRestoreRunning(ctx context.Context, c client.Client, namespace string) (bool, error) {
+	restores := &qubershipv1.PostgresRestoreList{}
+	if err := c.List(ctx, restores, client.InNamespace(namespace)); err != nil {
+		return false, err
+	}
+	for _, restore := range restores.Items {
+		if restore.Status.Phase == qubershipv1.PhaseRunning {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// getBackupLabel resolves backup set and repository of the restore, false is returned while referenced
+// PostgresBackup is not completed
+func (r *PostgresRestoreReconciler) getBackupLabel(ctx context.Context, restore *qubershipv1.PostgresRestore) (string, int, bool, error) {
+	if restore.Spec.BackupName == "" {
+		return restore.Spec.BackupLabel, restore.Spec.Repo, true, nil
+	}
+	if restore.Spec.BackupLabel != "" {
+		return "", 0, false, fmt.Errorf("only one of backupName and backupLabel can be set")
+	}
+	backup := &qubershipv1.PostgresBackup{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: restore.Spec.BackupName, Namespace: restore.Namespace}, backup); err != nil {
+		return "", 0, false, fmt.Errorf("cannot get PostgresBackup %s: %w", restore.Spec.BackupName, err)
+	}
+	switch backup.Status.Phase {
+	case qubershipv1.PhaseSucceeded:
+		if restore.Spec.Repo != 0 && backup.Status.Repo != 0 && restore.Spec.Repo != backup.Status.Repo {
+			return "", 0, false, fmt.Errorf("PostgresBackup %s is stored in repo%d, not in repo%d",
+				backup.Name, backup.Status.Repo, restore.Spec.Repo)
+		}
+		return backup.Status.Label, backup.Status.Repo, true, nil
+	case qubershipv1.PhaseFailed:
+		return "", 0, false, fmt.Errorf("PostgresBackup %s is failed", backup.Name)
+	}
+	return "", 0, false, nil
+}
+
+func (r *PostgresRestoreReconciler) finish(ctx context.Context, restore *qubershipv1.PostgresRestore, phase string, reason string, message string) error {
+	status := metav1.ConditionTrue
+	if phase == qubershipv1.PhaseFailed {
+		status = metav1.ConditionFalse
+		r.logger.Error(fmt.Sprintf("Restore %s failed: %s", restore.Name, message))
+	} else {
+		r.logger.Info(fmt.Sprintf("Restore %s succeeded: %s", restore.Name, message))
+	}
+	completionTime := metav1.Now()
+	restore.Status.Phase = phase
+	restore.Status.CompletionTime = &completionTime
+	r.setCondition(restore, status, reason, message)
+	return r.saveStatus(ctx, restore)
+}
+
+// saveStatus updates status of the restore with retries, the result of performed step must not be lost
+func (r *PostgresRestoreReconciler) saveStatus(ctx context.Context, restore *qubershipv1.PostgresRestore) error {
+	return wait.PollUntilContextTimeout(ctx, time.Second, time.Minute, true, func(ctx context.Context) (bool, error) {
+		current := &qubershipv1.PostgresRestore{}
+		if err := r.Client.Get(ctx, types.NamespacedName{Name: restore.Name, Namespace: restore.Namespace}, current); err != nil {
+			if errors.IsNotFound(err) {
+				return true, nil
+			}
+			return false, nil
+		}
+		current.Status = restore.Status
+		if err := r.Client.Status().Update(ctx, current); err != nil {
+			r.logger.Error("Can't update restore status, retrying", zap.Error(err))
+			return false, nil
+		}
+		return true, nil
+	})
+}
+
+func (r *PostgresRestoreReconciler) setCondition(restore *qubershipv1.PostgresRestore, status metav1.ConditionStatus, reason string, message string) {
+	meta.SetStatusCondition(&restore.Status.Conditions, metav1.Condition{
+		Type:               qubershipv1.ConditionCompleted,
+		Status:             status,
+		ObservedGeneration: restore.Generation,
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PostgresRestoreReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&qubershipv1.PostgresRestore{}).
+		Complete(r)
+}
@@ -0,0 +1,361 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	qubershipv1 "github.com/Netcracker/pgskipper-operator/api/patroni/v1"
+	"github.com/Netcracker/pgskipper-operator/pkg/helper"
+	"github.com/Netcracker/pgskipper-operator/pkg/pgbackrest"
+	"github.com/Netcracker/pgskipper-operator/pkg/util/testenv"
+	"go.uber.org/zap"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const restoreLabel = "20240919-000000F"
+
+func patroniStatefulSet(name string) *appsv1.StatefulSet {
+	replicas := int32(1)
+	return &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testenv.Namespace, Labels: map[string]string{"app": "patroni"}},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas: &replicas,
+			Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{
+				Name:  name,
+				Image: "patroni:new",
+				Env:   []corev1.EnvVar{{Name: "PGBACKREST_PG1_PATH", Value: "/var/lib/pgsql/data/postgresql_" + strings.TrimPrefix(name, "pg-patroni-")}},
+			}}}},
+		},
+	}
+}
+
+func newRestoreFakeClient(t *testing.T, restore *qubershipv1.PostgresRestore) client.Client {
+	t.Helper()
+	return newPgBackRestFakeClient(t, pgBackRestPatroniCore(),
+		patroniPod("pg-patroni-node1-0", "master", corev1.PodRunning),
+		patroniPod("pg-patroni-node2-0", "replica", corev1.PodRunning),
+		patroniStatefulSet("pg-patroni-node1"),
+		patroniStatefulSet("pg-patroni-node2"),
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "patroni-config", Namespace: testenv.Namespace,
+			Annotations: map[string]string{"initialize": "7415985452361064478"}}},
+		restore)
+}
+
+func newPostgresRestore() *qubershipv1.PostgresRestore {
+	return &qubershipv1.PostgresRestore{
+		ObjectMeta: metav1.ObjectMeta{Name: "restore-1", Namespace: testenv.Namespace, UID: "uid-r"},
+		Spec:       qubershipv1.PostgresRestoreSpec{BackupLabel: restoreLabel},
+	}
+}
+
+func newTestRestoreReconciler(kubeClient client.Client, executor *fakeExecutor) *PostgresRestoreReconciler {
+	patroniHelper := helper.NewPatroniHelper(kubeClient)
+	return &PostgresRestoreReconciler{
+		Client:  kubeClient,
+		helper:  patroniHelper,
+		restore: pgbackrest.NewRestore(patroniHelper, executor),
+		logger:  *zap.NewNop(),
+	}
+}
+
+// reconcileRestore performs one step of the restore and returns the saved restore
+func reconcileRestore(t *testing.T, r *PostgresRestoreReconciler) (*qubershipv1.PostgresRestore, ctrl.Result) {
+	t.Helper()
+	result, err := r.Reconcile(context.Background(), reconcileRequest("restore-1"))
+	if err != nil {
+		t.Fatalf("reconcile of restore failed: %v", err)
+	}
+	restore := &qubershipv1.PostgresRestore{}
+	if err := r.Client.Get(context.Background(), types.NamespacedName{Name: "restore-1", Namespace: testenv.Namespace}, restore); err != nil {
+		t.Fatal(err)
+	}
+	return restore, result
+}
+
+func assertStep(t *testing.T, restore *qubershipv1.PostgresRestore, result ctrl.Result, step string) {
+	t.Helper()
+	if restore.Status.Phase != qubershipv1.PhaseRunning || restore.Status.Step != step {
+		t.Fatalf("restore is %s on step %s, expected running on step %s: %+v",
+			restore.Status.Phase, restore.Status.Step, step, restore.Status.Conditions)
+	}
+	if result.RequeueAfter == 0 {
+		t.Errorf("running restore is not requeued")
+	}
+}
+
+func getStatefulSet(t *testing.T, kubeClient client.Client, name string) *appsv1.StatefulSet {
+	t.Helper()
+	sts := &appsv1.StatefulSet{}
+	if err := kubeClient.Get(context.Background(), types.NamespacedName{Name: name, Namespace: testenv.Namespace}, sts); err != nil {
+		t.Fatal(err)
+	}
+	return sts
+}
+
+func hasCleaner(sts *appsv1.StatefulSet) bool {
+	for _, container := range sts.Spec.Template.Spec.InitContainers {
+		if container.Name == "pg-cleaner" {
+			return true
+		}
+	}
+	return false
+}
+
+func deletePods(t *testing.T, kubeClient client.Client, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testenv.Namespace}}
+		if err := kubeClient.Delete(context.Background(), pod); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func setPodStatus(t *testing.T, kubeClient client.Client, name string, status corev1.PodStatus) {
+	t.Helper()
+	pod := &corev1.Pod{}
+	if err := kubeClient.Get(context.Background(), types.NamespacedName{Name: name, Namespace: testenv.Namespace}, pod); err != nil {
+		t.Fatal(err)
+	}
+	pod.Status = status
+	if err := kubeClient.Status().Update(context.Background(), pod); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func createPods(t *testing.T, kubeClient client.Client, pods ...*corev1.Pod) {
+	t.Helper()
+	for _, pod := range pods {
+		if err := kubeClient.Create(context.Background(), pod); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// stopCluster performs restore until the restore pod is started
+func stopCluster(t *testing.T, r *PostgresRestoreReconciler) *qubershipv1.PostgresRestore {
+	t.Helper()
+	restore, result := reconcileRestore(t, r)
+	assertStep(t, restore, result, pgbackrest.StepStopping)
+	deletePods(t, r.Client, "pg-patroni-node1-0", "pg-patroni-node2-0")
+	restore, result = reconcileRestore(t, r)
+	assertStep(t, restore, result, pgbackrest.StepRestoring)
+	return restore
+}
+
+func TestPostgresRestoreSteps(t *testing.T) {
+	kubeClient := newRestoreFakeClient(t, newPostgresRestore())
+	executor := &fakeExecutor{info: backupInfo("uid-1", restoreLabel, 1)}
+	r := newTestRestoreReconciler(kubeClient, executor)
+
+	restore, result := reconcileRestore(t, r)
+	assertStep(t, restore, result, pgbackrest.StepStopping)
+	if restore.Status.Leader != "pg-patroni-node1" || restore.Status.BackupLabel != restoreLabel {
+		t.Errorf("restore status is %+v", restore.Status)
+	}
+	for _, name := range []string{"pg-patroni-node1", "pg-patroni-node2"} {
+		if replicas := *getStatefulSet(t, kubeClient, name).Spec.Replicas; replicas != 0 {
+			t.Errorf("%s has %d replicas during restore", name, replicas)
+		}
+	}
+	if running, err := isRestoreRunning(context.Background(), kubeClient, testenv.Namespace); err != nil || !running {
+		t.Errorf("running restore is not detected: %t, %v", running, err)
+	}
+
+	// Patroni pods are still terminating
+	restore, result = reconcileRestore(t, r)
+	assertStep(t, restore, result, pgbackrest.StepStopping)
+	deletePods(t, kubeClient, "pg-patroni-node1-0", "pg-patroni-node2-0")
+
+	restore, result = reconcileRestore(t, r)
+	assertStep(t, restore, result, pgbackrest.StepRestoring)
+	if restore.Status.RestorePod != "pg-restore-uid-r" {
+		t.Fatalf("restore pod is %s", restore.Status.RestorePod)
+	}
+	restorePod := &corev1.Pod{}
+	if err := kubeClient.Get(context.Background(), types.NamespacedName{Name: "pg-restore-uid-r", Namespace: testenv.Namespace}, restorePod); err != nil {
+		t.Fatalf("restore pod is not created: %v", err)
+	}
+	if script := restorePod.Spec.Containers[0].Command[2]; !strings.Contains(script, "--set='"+restoreLabel+"'") {
+		t.Errorf("restore script doesn't restore %s: %s", restoreLabel, script)
+	}
+
+	// operator is restarted, restore is continued from the saved step
+	r = newTestRestoreReconciler(kubeClient, executor)
+	restore, result = reconcileRestore(t, r)
+	assertStep(t, restore, result, pgbackrest.StepRestoring)
+
+	setPodStatus(t, kubeClient, "pg-restore-uid-r", corev1.PodStatus{Phase: corev1.PodSucceeded})
+	restore, result = reconcileRestore(t, r)
+	assertStep(t, restore, result, pgbackrest.StepStartingLeader)
+	if err := kubeClient.Get(context.Background(), types.NamespacedName{Name: "pg-restore-uid-r", Namespace: testenv.Namespace}, restorePod); !errors.IsNotFound(err) {
+		t.Errorf("restore pod is not deleted: %v", err)
+	}
+	if replicas := *getStatefulSet(t, kubeClient, "pg-patroni-node1").Spec.Replicas; replicas != 1 {
+		t.Errorf("restored member has %d replicas", replicas)
+	}
+	if replicas := *getStatefulSet(t, kubeClient, "pg-patroni-node2").Spec.Replicas; replicas != 0 {
+		t.Errorf("replica is started before the leader")
+	}
+	cm := &corev1.ConfigMap{}
+	if err := kubeClient.Get(context.Background(), types.NamespacedName{Name: "patroni-config", Namespace: testenv.Namespace}, cm); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cm.Annotations["initialize"]; ok {
+		t.Errorf("initialize key of the cluster is not removed")
+	}
+
+	// the restored member is not the leader yet
+	createPods(t, kubeClient, patroniPod("pg-patroni-node1-0", "", corev1.PodRunning))
+	restore, result = reconcileRestore(t, r)
+	assertStep(t, restore, result, pgbackrest.StepStartingLeader)
+
+	deletePods(t, kubeClient, "pg-patroni-node1-0")
+	createPods(t, kubeClient, patroniPod("pg-patroni-node1-0", "master", corev1.PodRunning))
+	restore, result = reconcileRestore(t, r)
+	assertStep(t, restore, result, pgbackrest.StepStartingReplicas)
+	replica := getStatefulSet(t, kubeClient, "pg-patroni-node2")
+	if *replica.Spec.Replicas != 1 || !hasCleaner(replica) {
+		t.Errorf("replica is not started with cleaner: %+v", replica.Spec)
+	}
+	if hasCleaner(getStatefulSet(t, kubeClient, "pg-patroni-node1")) {
+		t.Errorf("restored member is started with cleaner")
+	}
+
+	restore, result = reconcileRestore(t, r)
+	assertStep(t, restore, result, pgbackrest.StepStartingReplicas)
+
+	createPods(t, kubeClient, patroniPod("pg-patroni-node2-0", "replica", corev1.PodRunning))
+	restore, result = reconcileRestore(t, r)
+	if restore.Status.Phase != qubershipv1.PhaseSucceeded || result.RequeueAfter != 0 {
+		t.Fatalf("restore is %s: %+v", restore.Status.Phase, restore.Status.Conditions)
+	}
+	assertCompleted(t, restore.Status.Conditions, metav1.ConditionTrue, "RestoreSucceeded")
+	if hasCleaner(getStatefulSet(t, kubeClient, "pg-patroni-node2")) {
+		t.Errorf("cleaner is not removed after restore")
+	}
+	if running, err := isRestoreRunning(context.Background(), kubeClient, testenv.Namespace); err != nil || running {
+		t.Errorf("completed restore is running: %t, %v", running, err)
+	}
+}
+
+func TestPostgresRestoreFailedPod(t *testing.T) {
+	kubeClient := newRestoreFakeClient(t, newPostgresRestore())
+	r := newTestRestoreReconciler(kubeClient, &fakeExecutor{info: backupInfo("uid-1", restoreLabel, 1)})
+	stopCluster(t, r)
+
+	setPodStatus(t, kubeClient, "pg-restore-uid-r", corev1.PodStatus{
+		Phase: corev1.PodFailed,
+		ContainerStatuses: []corev1.ContainerStatus{{State: corev1.ContainerState{
+			Terminated: &corev1.ContainerStateTerminated{ExitCode: 1, Message: "ERROR: [075]: no backup set found to restore\n"},
+		}}},
+	})
+	restore, _ := reconcileRestore(t, r)
+	if restore.Status.Phase != qubershipv1.PhaseFailed {
+		t.Fatalf("restore is %s after restore pod failure", restore.Status.Phase)
+	}
+	assertCompleted(t, restore.Status.Conditions, metav1.ConditionFalse, "RestoreFailed")
+	message := meta.FindStatusCondition(restore.Status.Conditions, qubershipv1.ConditionCompleted).Message
+	if !strings.Contains(message, "no backup set found") || !strings.Contains(message, "left stopped") {
+		t.Errorf("failure message is %s", message)
+	}
+	if replicas := *getStatefulSet(t, kubeClient, "pg-patroni-node1").Spec.Replicas; replicas != 0 {
+		t.Errorf("partially restored member is started")
+	}
+}
+
+func TestPostgresRestoreStepTimeout(t *testing.T) {
+	kubeClient := newRestoreFakeClient(t, newPostgresRestore())
+	r := newTestRestoreReconciler(kubeClient, &fakeExecutor{info: backupInfo("uid-1", restoreLabel, 1)})
+	restore, result := reconcileRestore(t, r)
+	assertStep(t, restore, result, pgbackrest.StepStopping)
+
+	stepStartTime := metav1.NewTime(time.Now().Add(-time.Hour))
+	restore.Status.StepStartTime = &stepStartTime
+	if err := kubeClient.Status().Update(context.Background(), restore); err != nil {
+		t.Fatal(err)
+	}
+	restore, _ = reconcileRestore(t, r)
+	if restore.Status.Phase != qubershipv1.PhaseFailed {
+		t.Errorf("restore is %s when Patroni pods are not stopped in time", restore.Status.Phase)
+	}
+}
+
+func TestPostgresRestoreMissingBackup(t *testing.T) {
+	kubeClient := newRestoreFakeClient(t, newPostgresRestore())
+	r := newTestRestoreReconciler(kubeClient, &fakeExecutor{info: backupInfo("uid-1", "20240920-000000F", 1)})
+
+	restore, _ := reconcileRestore(t, r)
+	if restore.Status.Phase != qubershipv1.PhaseFailed {
+		t.Fatalf("restore of missing backup is %s", restore.Status.Phase)
+	}
+	if replicas := *getStatefulSet(t, kubeClient, "pg-patroni-node1").Spec.Replicas; replicas != 1 {
+		t.Errorf("cluster is stopped for missing backup")
+	}
+}
+
+func TestPostgresRestoreWaitsForBackup(t *testing.T) {
+	restore := newPostgresRestore()
+	restore.Spec.BackupLabel = ""
+	restore.Spec.BackupName = "backup-1"
+	backup := newPostgresBackup("backup-1", "uid-1")
+	backup.Status.Phase = qubershipv1.PhaseRunning
+	kubeClient := newRestoreFakeClient(t, restore)
+	if err := kubeClient.Create(context.Background(), backup); err != nil {
+		t.Fatal(err)
+	}
+	r := newTestRestoreReconciler(kubeClient, &fakeExecutor{info: backupInfo("uid-1", restoreLabel, 2)})
+
+	restore, _ = reconcileRestore(t, r)
+	if restore.Status.Phase != qubershipv1.PhasePending {
+		t.Fatalf("restore is %s while backup is running", restore.Status.Phase)
+	}
+
+	backup.Status = qubershipv1.PostgresBackupStatus{Phase: qubershipv1.PhaseSucceeded, Label: restoreLabel, Repo: 2}
+	if err := kubeClient.Status().Update(context.Background(), backup); err != nil {
+		t.Fatal(err)
+	}
+	restore, result := reconcileRestore(t, r)
+	assertStep(t, restore, result, pgbackrest.StepStopping)
+	if restore.Status.BackupLabel != restoreLabel || restore.Status.Repo != 2 {
+		t.Errorf("backup of PostgresBackup is not used: %+v", restore.Status)
+	}
+}
+
+func TestPatroniCoreReconcileIsPostponedDuringRestore(t *testing.T) {
+	restore := newPostgresRestore()
+	restore.Status.Phase = qubershipv1.PhaseRunning
+	kubeClient := newRestoreFakeClient(t, restore)
+	pr := &PatroniCoreReconciler{Client: kubeClient, logger: *zap.NewNop()}
+
+	result, err := pr.Reconcile(context.Background(), reconcileRequest("patroni-core"))
+	if err != nil || result.RequeueAfter == 0 {
+		t.Errorf("reconcile during restore returned %+v, %v, expected requeue", result, err)
+	}
+	if replicas := *getStatefulSet(t, kubeClient, "pg-patroni-node1").Spec.Replicas; replicas != 1 {
+		t.Errorf("statefulset is changed during restore")
+	}
+}
@@ -0,0 +1,202 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Netcracker/pgskipper-operator-core/pkg/util"
+	qubershipv1 "github.com/Netcracker/pgskipper-operator/api/apps/v1"
+	pgClient "github.com/Netcracker/pgskipper-operator/pkg/client"
+	"github.com/Netcracker/pgskipper-operator/pkg/dbobjects"
+	"github.com/Netcracker/pgskipper-operator/pkg/helper"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	postgresRoleFinalizer = "qubership.org/postgres-role"
+	defaultPasswordKey    = "password"
+)
+
+// PostgresRoleReconciler creates roles described by PostgresRole and corrects their drift
+type PostgresRoleReconciler struct {
+	Client client.Client
+	Scheme *runtime.Scheme
+	helper *helper.Helper
+	logger zap.Logger
+	// getPgClient returns client of PostgreSQL cluster the objects are created in
+	getPgClient dbObjectsClientFunc
+}
+
+func NewPostgresRoleReconciler(client client.Client, scheme *runtime.Scheme) *PostgresRoleReconciler {
+	h := helper.GetHelper()
+	return &PostgresRoleReconciler{
+		Client: client,
+		Scheme: scheme,
+		helper: h,
+		logger: *util.GetLogger(),
+		getPgClient: func() (*pgClient.PostgresClient, error) {
+			return getDBObjectsClient(h)
+		},
+	}
+}
+
+//+kubebuilder:rbac:groups=qubership.org,resources=postgresroles,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=qubership.org,resources=postgresroles/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=qubership.org,resources=postgresroles/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create
+
+func (r *PostgresRoleReconciler) Reconcile(ctx context.Context, request ctrl.Request) (ctrl.Result, error) {
+	role := &qubershipv1.PostgresRole{}
+	if err := r.Client.Get(ctx, request.NamespacedName, role); err != nil {
+		if errors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{RequeueAfter: dbObjectsRetryInterval}, err
+	}
+	name := role.GetRoleName()
+
+	if !role.DeletionTimestamp.IsZero() {
+		if !controllerutil.ContainsFinalizer(role, postgresRoleFinalizer) {
+			return reconcile.Result{}, nil
+		}
+		if role.Spec.DeletionPolicy == qubershipv1.DeletionPolicyDrop {
+			pgC, err := r.getPgClient()
+			if err != nil {
+				return reconcile.Result{RequeueAfter: dbObjectsRetryInterval}, err
+			}
+			if err := dbobjects.DropRole(pgC, name); err != nil {
+				r.logger.Error(fmt.Sprintf("Cannot drop role %s", name), zap.Error(err))
+				return reconcile.Result{RequeueAfter: dbObjectsRetryInterval}, err
+			}
+		}
+		controllerutil.RemoveFinalizer(role, postgresRoleFinalizer)
+		return reconcile.Result{}, r.Client.Update(ctx, role)
+	}
+	if controllerutil.AddFinalizer(role, postgresRoleFinalizer) {
+		if err := r.Client.Update(ctx, role); err != nil {
+			return reconcile.Result{RequeueAfter: dbObjectsRetryInterval}, err
+		}
+	}
+
+	reason := reasonSynced
+	password, secretVersion, err := r.getPassword(ctx, role)
+	if err != nil {
+		reason = reasonSyncFailed
+	} else if pgC, pgErr := r.getPgClient(); pgErr != nil {
+		reason, err = reasonPostgreSQLUnavailable, pgErr
+	} else if err = dbobjects.SyncRole(pgC, name, &role.Spec, password); err != nil {
+		reason = reasonSyncFailed
+	} else {
+		role.Status.PasswordSecretVersion = secretVersion
+	}
+	if err != nil {
+		r.logger.Error(fmt.Sprintf("Cannot sync role %s", name), zap.Error(err))
+	}
+	role.Status.ObservedGeneration = role.Generation
+	lastSyncTime, requeueAfter := setSyncedCondition(&role.Status.Conditions, role.Generation, reason, err)
+	role.Status.LastSyncTime = lastSyncTime
+	return reconcile.Result{RequeueAfter: requeueAfter}, r.Client.Status().Update(ctx, role)
+}
+
+// getPassword reads password from the Secret or generates it into a new Secret,
+// the password is updated in PostgreSQL only if the Secret was changed since the last sync
+func (r *PostgresRoleReconciler) getPassword(ctx context.Context, role *qubershipv1.PostgresRole) (*dbobjects.Password, string, error) {
+	passwordSecret := role.Spec.PasswordSecret
+	if passwordSecret == nil {
+		return nil, "", nil
+	}
+	key := passwordSecret.Key
+	if key == "" {
+		key = defaultPasswordKey
+	}
+	secret := &corev1.Secret{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: passwordSecret.Name, Namespace: role.Namespace}, secret)
+	if errors.IsNotFound(err) && passwordSecret.Generate {
+		if secret, err = r.generatePasswordSecret(ctx, role, key); err != nil {
+			return nil, "", err
+		}
+	} else if err != nil {
+		return nil, "", err
+	}
+	password, ok := secret.Data[key]
+	if !ok || len(password) == 0 {
+		return nil, "", fmt.Errorf("secret %s doesn't contain key %s", secret.Name, key)
+	}
+	return &dbobjects.Password{
+		Value:  string(password),
+		Update: secret.ResourceVersion != role.Status.PasswordSecretVersion,
+	}, secret.ResourceVersion, nil
+}
+
+// generatePasswordSecret creates Secret without owner reference, so the password is kept if the role is retained
+func (r *PostgresRoleReconciler) generatePasswordSecret(ctx context.Context, role *qubershipv1.PostgresRole, key string) (*corev1.Secret, error) {
+	password, err := dbobjects.GeneratePassword()
+	if err != nil {
+		return nil, err
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      role.Spec.PasswordSecret.Name,
+			Namespace: role.Namespace,
+		},
+		Data: map[string][]byte{
+			"username": []byte(role.GetRoleName()),
+			key:        []byte(password),
+		},
+	}
+	r.logger.Info(fmt.Sprintf("Generating password of role %s into secret %s", role.GetRoleName(), secret.Name))
+	if err := r.Client.Create(ctx, secret); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// rolesForSecret enqueues roles which take password from the changed Secret
+func (r *PostgresRoleReconciler) rolesForSecret(ctx context.Context, secret client.Object) []reconcile.Request {
+	roles := &qubershipv1.PostgresRoleList{}
+	if err := r.Client.List(ctx, roles, client.InNamespace(secret.GetNamespace())); err != nil {
+		r.logger.Error("Cannot list PostgresRoles", zap.Error(err))
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, role := range roles.Items {
+		if role.Spec.PasswordSecret != nil && role.Spec.PasswordSecret.Name == secret.GetName() {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: role.Name, Namespace: role.Namespace},
+			})
+		}
+	}
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PostgresRoleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&qubershipv1.PostgresRole{}).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.rolesForSecret)).
+		Complete(r)
+}
@@ -26,6 +26,7 @@ import (
 
 	"github.com/Netcracker/pgskipper-operator/pkg/deployerrors"
 	"github.com/Netcracker/pgskipper-operator/pkg/helper"
+	"github.com/Netcracker/pgskipper-operator/pkg/metrics"
 	"github.com/Netcracker/pgskipper-operator/pkg/postgresexporter"
 	"github.com/Netcracker/pgskipper-operator/pkg/reconciler"
 	"github.com/Netcracker/pgskipper-operator/pkg/scheduler"
@@ -47,6 +48,7 @@ import (
 
 	"github.com/Netcracker/pgskipper-operator-core/pkg/util"
 	qubershipv1 "github.com/Netcracker/pgskipper-operator/api/apps/v1"
+	"github.com/Netcracker/pgskipper-operator/pkg/pooler"
 	"github.com/Netcracker/qubership-credential-manager/pkg/informer"
 	"github.com/Netcracker/qubership-credential-manager/pkg/manager"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -75,6 +77,7 @@ type PostgresServiceReconciler struct {
 	logger       zap.Logger
 	resVersions  map[string]string
 	crHash       string
+	conditions   componentConditions
 }
 
 type PatroniClusterSettings struct {
@@ -107,6 +110,7 @@ func NewPostgresServiceReconciler(client client.Client, scheme *runtime.Scheme)
 		namespace:   namespace,
 		logger:      *logger,
 		resVersions: map[string]string{},
+		conditions:  componentConditions{kind: "PatroniServices"},
 	}
 
 }
@@ -114,6 +118,7 @@ func NewPostgresServiceReconciler(client client.Client, scheme *runtime.Scheme)
 //+kubebuilder:rbac:groups=qubership.org,resources=postgresservices,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=qubership.org,resources=postgresservices/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=qubership.org,resources=postgresservices/finalizers,verbs=update
+//+kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=get;list;watch;create;update;patch;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -124,7 +129,13 @@ func NewPostgresServiceReconciler(client client.Client, scheme *runtime.Scheme)
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.10.0/pkg/reconcile
 func (r *PostgresServiceReconciler) Reconcile(ctx context.Context, request ctrl.Request) (ctrl.Result, error) {
+	start := time.Now()
+	result, err := r.reconcile(ctx, request)
+	metrics.ObserveReconcile(r.conditions.kind, metrics.ComponentAll, time.Since(start), err)
+	return result, err
+}
 
+func (r *PostgresServiceReconciler) reconcile(ctx context.Context, request ctrl.Request) (ctrl.Result, error) {
 	// Fetch the PostgresService instance
 	cr := &qubershipv1.PatroniServices{}
 	r.logger.Info("Want to get PatroniServices")
@@ -132,6 +143,12 @@ func (r *PostgresServiceReconciler) Reconcile(ctx context.Context, request ctrl.
 		if errors.IsNotFound(err) {
 			return reconcile.Result{}, nil
 		}
+		if dropped, dropErr := dropLegacyConditions(r.Client, qubershipv1.GroupVersion.WithKind("PatroniServices"), request.NamespacedName); dropErr != nil {
+			r.logger.Error("Cannot check CR for legacy status conditions", zap.Error(dropErr))
+		} else if dropped {
+			r.logger.Info("Legacy status conditions were removed from CR")
+			return reconcile.Result{Requeue: true}, nil
+		}
 		r.logger.Error("Cannot fetch CR status", zap.Error(err))
 		if err := r.updateStatus(Failed, "CannotFetchCrStatus",
 			fmt.Sprintf("Cannot fetch CR status. Error: %s", err.Error())); err != nil {
@@ -148,11 +165,18 @@ func (r *PostgresServiceReconciler) Reconcile(ctx context.Context, request ctrl.
 	if err := r.helper.SetCustomResource(cr); err != nil {
 		return reconcile.Result{}, err
 	}
+	// Patroni REST API settings are managed by PatroniCore
+	if coreCr, err := r.helper.GetPatroniCoreCR(); err == nil {
+		if err := r.helper.ConfigurePatroniClient(coreCr); err != nil {
+			r.logger.Error("Cannot configure Patroni REST API client", zap.Error(err))
+			return reconcile.Result{RequeueAfter: time.Minute}, err
+		}
+	}
 
 	newResVersion := cr.ResourceVersion
 	newCrHash := util.HashJson(cr.Spec)
 	if (r.resVersions[cr.Name] == newResVersion ||
-		r.crHash == newCrHash) && len(cr.Status.Conditions) != 0 && cr.Status.Conditions[0].Type != Failed {
+		r.crHash == newCrHash) && isReconcileSucceeded(cr.Status.Conditions) {
 		InfoMsg := "ResourceVersion didn't change, skipping reconcile loop"
 		if cr.Spec.ExternalDataBase != nil {
 			r.logger.Info(InfoMsg)
@@ -173,6 +197,7 @@ func (r *PostgresServiceReconciler) Reconcile(ctx context.Context, request ctrl.
 		r.message = "Start Patroni Services cluster reconcile cycle"
 	}
 
+	r.conditions.reset(cr.Generation)
 	r.logger.Info(fmt.Sprintf("CR newResVersion is set to: %s Local ResVersion is set to: %s", newResVersion, r.resVersions))
 	if err := r.updateStatus(InProgress, "StartPatroniServicesClusterReconcile",
 		"Start Postgres Service cluster reconcile cycle"); err != nil {
@@ -300,7 +325,12 @@ func (r *PostgresServiceReconciler) Reconcile(ctx context.Context, request ctrl.
 		}
 	}
 
-	err = informer.Watch(credentials.PostgresSecretNames, reconcFunc)
+	watchedSecrets := append([]string{}, credentials.PostgresSecretNames...)
+	if cr.Spec.Pooler.Install {
+		// password change of the pooler user is applied by RELOAD of the running pods
+		watchedSecrets = append(watchedSecrets, pooler.SecretName)
+	}
+	err = informer.Watch(watchedSecrets, reconcFunc)
 	if err != nil {
 		r.logger.Error("cannot start watcher", zap.Error(err))
 		return reconcile.Result{RequeueAfter: time.Minute}, err
@@ -320,6 +350,10 @@ func (r *PostgresServiceReconciler) Reconcile(ctx context.Context, request ctrl.
 		return reconcile.Result{RequeueAfter: time.Minute}, err
 	}
 
+	if err := scheduler.ScheduleCredentialsRotation(cr); err != nil {
+		r.logger.Error("Cannot schedule credentials rotation", zap.Error(err))
+	}
+
 	r.errorCounter = 0
 	r.logger.Info("Reconcile cycle succeeded")
 	r.resVersions[cr.Name] = newResVersion
@@ -356,92 +390,74 @@ func (r *PostgresServiceReconciler) handleTestReconcileError(err error, errMsg s
 func (r *PostgresServiceReconciler) reconcilePostgresServiceCluster(cr *qubershipv1.PatroniServices) error {
 	// reconcile ExternalDatabase
 	if r.isExternalResourcesRequired(cr) {
-		err := r.processExternalResources(cr)
-		if err != nil {
+		if err := r.conditions.track(qubershipv1.ConditionExternalDatabaseReady, func() error { return r.processExternalResources(cr) }); err != nil {
 			return err
 		}
+	} else {
+		r.conditions.skip(qubershipv1.ConditionExternalDatabaseReady)
 	}
 
 	// reconcile Pooler
 	if cr.Spec.Pooler.Install {
-		if err := r.reconcilePooler(cr); err != nil {
+		if err := r.conditions.track(qubershipv1.ConditionPoolerReady, func() error { return r.reconcilePooler(cr) }); err != nil {
 			return err
 		}
+	} else {
+		r.conditions.skip(qubershipv1.ConditionPoolerReady)
 	}
 
 	// reconcile Backup daemon
 	if cr.Spec.BackupDaemon != nil {
-		if err := r.reconcileBackupDaemon(cr); err != nil {
+		if err := r.conditions.track(qubershipv1.ConditionBackupDaemonReady, func() error { return r.reconcileBackupDaemon(cr) }); err != nil {
 			return err
 		}
+	} else {
+		r.conditions.skip(qubershipv1.ConditionBackupDaemonReady)
 	}
 
 	// reconcile Metric Collector
 	if cr.Spec.MetricCollector != nil {
-		if err := r.reconcileMetricCollector(cr); err != nil {
+		if err := r.conditions.track(qubershipv1.ConditionMetricCollectorReady, func() error { return r.reconcileMetricCollector(cr) }); err != nil {
 			return err
 		}
+	} else {
+		r.conditions.skip(qubershipv1.ConditionMetricCollectorReady)
 	}
 
 	// reconcile SiteManager
 	if cr.Spec.SiteManager != nil {
-		if err := r.reconcileSiteManager(cr); err != nil {
+		if err := r.conditions.track(qubershipv1.ConditionSiteManagerReady, func() error { return r.reconcileSiteManager(cr) }); err != nil {
 			return err
 		}
+	} else {
+		r.conditions.skip(qubershipv1.ConditionSiteManagerReady)
 	}
 
 	// reconcile Powa UI
 	if cr.Spec.PowaUI.Install {
-		if err := r.reconcilePowaUI(cr); err != nil {
+		if err := r.conditions.track(qubershipv1.ConditionPowaUIReady, func() error { return r.reconcilePowaUI(cr) }); err != nil {
 			return err
 		}
+	} else {
+		r.conditions.skip(qubershipv1.ConditionPowaUIReady)
 	}
 
-	// configure postgres-exporter user
-	if cr.Spec.PostgresExporter != nil && cr.Spec.PostgresExporter.Install {
-		if err := postgresexporter.SetUpExporter(cr.Spec.PostgresExporter); err != nil { //REWORK
-			return err
-		}
-	}
-
-	// reconcile Query Exporter
-	if cr.Spec.QueryExporter.Install {
-		if err := r.reconcileQueryExporter(cr); err != nil {
+	// reconcile postgres-exporter and Query Exporter
+	if cr.Spec.PostgresExporter != nil || cr.Spec.QueryExporter.Install {
+		if err := r.conditions.track(qubershipv1.ConditionExportersReady, func() error { return r.reconcileExporters(cr) }); err != nil {
 			return err
 		}
+	} else {
+		r.conditions.skip(qubershipv1.ConditionExportersReady)
 	}
 
 	// reconcile Replication Controller
 	if cr.Spec.ReplicationController.Install {
-		if err := r.reconcileRC(cr); err != nil {
+		if err := r.conditions.track(qubershipv1.ConditionReplicationControllerReady, func() error { return r.reconcileRC(cr) }); err != nil {
 			return err
 		}
-	}
-
-	// watch postgres exporter custom queries
-	if cr.Spec.PostgresExporter != nil {
-		customQueries := cr.Spec.PostgresExporter.CustomQueries
-		if customQueries != nil && customQueries.Enabled {
-			postgresexporter.RemoveActiveWatcher()
-			exporter := postgresexporter.NewPostgresExporterWatcher(
-				r.helper, customQueries.NamespacesList, customQueries.Labels)
-			if err := exporter.WatchCustomQueries(); err != nil {
-				return err
-			}
-		}
-	}
-
-	// watch query exporter custom queries
-	if cr.Spec.QueryExporter.Install {
-		customQueries := cr.Spec.QueryExporter.CustomQueries
-		if customQueries != nil && customQueries.Enabled {
-			queryexporter.RemoveActiveWatcher()
-			exporter := queryexporter.NewQueryExporterWatcher(
-				r.helper, customQueries.NamespacesList, customQueries.Labels)
-			if err := exporter.WatchCustomQueries(); err != nil {
-				return err
-			}
-		}
+	} else {
+		r.conditions.skip(qubershipv1.ConditionReplicationControllerReady)
 	}
 
 	// Reconcile IntegrationTests
@@ -461,13 +477,14 @@ func (r *PostgresServiceReconciler) reconcilePostgresServiceCluster(cr *qubershi
 			}
 		}
 
-		if err := r.createTestsPods(cr); err != nil {
+		if err := r.conditions.track(qubershipv1.ConditionIntegrationTestsReady, func() error { return r.createTestsPods(cr) }); err != nil {
 			r.logger.Error("Can not synchronize Tests state to cluster", zap.Error(err))
 			return err
 		}
 
 	} else {
 		r.logger.Info("Tests Spec is empty, skipping reconciliation")
+		r.conditions.skip(qubershipv1.ConditionIntegrationTestsReady)
 	}
 
 	// And delete secrets, that uploaded to vault
@@ -531,6 +548,49 @@ func (r *PostgresServiceReconciler) createTestsPods(cr *qubershipv1.PatroniServi
 	return nil
 }
 
+func (r *PostgresServiceReconciler) reconcileExporters(cr *qubershipv1.PatroniServices) error {
+	// configure postgres-exporter user
+	if cr.Spec.PostgresExporter != nil && cr.Spec.PostgresExporter.Install {
+		if err := postgresexporter.SetUpExporter(cr.Spec.PostgresExporter); err != nil { //REWORK
+			return err
+		}
+	}
+
+	// reconcile Query Exporter
+	if cr.Spec.QueryExporter.Install {
+		if err := r.reconcileQueryExporter(cr); err != nil {
+			return err
+		}
+	}
+
+	// watch postgres exporter custom queries
+	if cr.Spec.PostgresExporter != nil {
+		customQueries := cr.Spec.PostgresExporter.CustomQueries
+		if customQueries != nil && customQueries.Enabled {
+			postgresexporter.RemoveActiveWatcher()
+			exporter := postgresexporter.NewPostgresExporterWatcher(
+				r.helper, customQueries.NamespacesList, customQueries.Labels)
+			if err := exporter.WatchCustomQueries(); err != nil {
+				return err
+			}
+		}
+	}
+
+	// watch query exporter custom queries
+	if cr.Spec.QueryExporter.Install {
+		customQueries := cr.Spec.QueryExporter.CustomQueries
+		if customQueries != nil && customQueries.Enabled {
+			queryexporter.RemoveActiveWatcher()
+			exporter := queryexporter.NewQueryExporterWatcher(
+				r.helper, customQueries.NamespacesList, customQueries.Labels)
+			if err := exporter.WatchCustomQueries(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 func (r *PostgresServiceReconciler) reconcileBackupDaemon(cr *qubershipv1.PatroniServices) error {
 	r.logger.Info("Backup Daemon Spec is not empty, proceeding with reconcile")
 	bRec := reconciler.NewBackupDaemonReconciler(cr, r.helper, r.vaultClient, utils.GetPatroniClusterSettings(cr.Spec.Patroni.ClusterName))
@@ -0,0 +1,68 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	qubershipv1 "github.com/Netcracker/pgskipper-operator/api/patroni/v1"
+	"github.com/Netcracker/pgskipper-operator/pkg/readonly"
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// processReadOnlyService updates endpoints of pg-<cluster>-ro service when they are managed by the operator.
+// It returns the interval to check the cluster again, so lag changes are applied without CR changes.
+func (pr *PatroniCoreReconciler) processReadOnlyService(cr *qubershipv1.PatroniCore) time.Duration {
+	if !readonly.IsEnabled(cr) {
+		if cr.Status.ReadOnlyService != nil {
+			pr.updateReadOnlyServiceStatus(nil)
+		}
+		return 0
+	}
+	status := &qubershipv1.ReadOnlyServiceStatus{}
+	members, err := readonly.Sync(pr.helper, cr)
+	if err != nil {
+		pr.logger.Error("Cannot update endpoints of read-only service", zap.Error(err))
+		if cr.Status.ReadOnlyService != nil {
+			// endpoints are not changed, so members are kept
+			status.Members = cr.Status.ReadOnlyService.Members
+		}
+		status.Message = err.Error()
+	} else {
+		status.Members = members
+		if len(members) == 0 {
+			status.Message = "No member satisfies conditions of read-only service"
+		}
+	}
+	current := cr.Status.ReadOnlyService
+	if current == nil || !slices.Equal(current.Members, status.Members) || current.Message != status.Message {
+		pr.logger.Info(fmt.Sprintf("Read-only service members: [%s]", strings.Join(status.Members, ", ")))
+		status.LastUpdateTime = &metav1.Time{Time: time.Now()}
+		pr.updateReadOnlyServiceStatus(status)
+	}
+	return readonly.GetCheckInterval(cr.Spec.Patroni.ReadOnlyService)
+}
+
+func (pr *PatroniCoreReconciler) updateReadOnlyServiceStatus(status *qubershipv1.ReadOnlyServiceStatus) {
+	if err := pr.helper.UpdatePatroniCoreStatus(func(crStatus *qubershipv1.PatroniCoreStatus) {
+		crStatus.ReadOnlyService = status
+	}); err != nil {
+		pr.logger.Error("Can't update read-only service status", zap.Error(err))
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	qubershipv1 "github.com/Netcracker/pgskipper-operator/api/patroni/v1"
+	"github.com/Netcracker/pgskipper-operator/pkg/patroni"
+	utils "github.com/Netcracker/pgskipper-operator/pkg/util"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	switchoverTimeout      = 5 * time.Minute
+	switchoverPollInterval = 10 * time.Second
+)
+
+// processSwitchover performs switchover requested in spec.patroni.switchover.
+// Each request is performed once, to repeat it the block should be removed and added again.
+// Progress is kept in status, so reconcile isn't blocked while Patroni elects the new leader.
+func (pr *PatroniCoreReconciler) processSwitchover(cr *qubershipv1.PatroniCore) (ctrl.Result, error) {
+	if cr.Spec.Patroni == nil || cr.Spec.Patroni.Switchover == nil {
+		if cr.Status.Switchover != nil {
+			return reconcile.Result{}, pr.updateSwitchoverStatus(nil)
+		}
+		return reconcile.Result{}, nil
+	}
+	request := cr.Spec.Patroni.Switchover
+	current := cr.Status.Switchover
+	if isSameSwitchover(current, request) &&
+		(current.Phase == qubershipv1.SwitchoverSucceeded || current.Phase == qubershipv1.SwitchoverFailed) {
+		return reconcile.Result{}, nil
+	}
+
+	if request.ScheduledAt != nil {
+		if delay := time.Until(request.ScheduledAt.Time); delay > 0 {
+			if !isSameSwitchover(current, request) || current.Phase != qubershipv1.SwitchoverScheduled {
+				status := &qubershipv1.SwitchoverStatus{
+					Phase:       qubershipv1.SwitchoverScheduled,
+					Candidate:   request.Candidate,
+					ScheduledAt: request.ScheduledAt,
+					Message:     fmt.Sprintf("Switchover is scheduled at %s", request.ScheduledAt.Format(time.RFC3339)),
+				}
+				if err := pr.updateSwitchoverStatus(status); err != nil {
+					return reconcile.Result{RequeueAfter: time.Minute}, err
+				}
+			}
+			return reconcile.Result{RequeueAfter: delay}, nil
+		}
+	}
+
+	clusterSettings := utils.GetPatroniClusterSettings(cr.Spec.Patroni.ClusterName)
+	status, requeueAfter := pr.stepSwitchover(request, current, clusterSettings.PatroniUrl, func(leader string) bool {
+		pods, err := pr.helper.GetPodsByLabel(clusterSettings.PatroniMasterSelectors)
+		return err == nil && len(pods.Items) == 1 && pods.Items[0].Name == leader
+	})
+	if !equality.Semantic.DeepEqual(status, current) {
+		if err := pr.updateSwitchoverStatus(status); err != nil {
+			return reconcile.Result{RequeueAfter: time.Minute}, err
+		}
+	}
+	return reconcile.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// stepSwitchover starts the requested switchover or checks progress of the running one, it returns the new status
+// and the delay of the next check, zero delay means switchover is finished. Switchover is finished when Patroni
+// reports the new running leader and pgtype=master label is moved to it by Patroni.
+func (pr *PatroniCoreReconciler) stepSwitchover(request *qubershipv1.Switchover, current *qubershipv1.SwitchoverStatus, patroniUrl string,
+	isMasterLabeled func(leader string) bool) (*qubershipv1.SwitchoverStatus, time.Duration) {
+	if !isSameSwitchover(current, request) || current.Phase != qubershipv1.SwitchoverRunning {
+		return pr.startSwitchover(request, patroniUrl)
+	}
+	status := current.DeepCopy()
+	// switchover to the current leader only makes sure that labels are correct
+	previousLeader := status.PreviousLeader
+	if status.Candidate == previousLeader {
+		previousLeader = ""
+	}
+	leader, err := patroni.GetNewLeader(patroniUrl, previousLeader, status.Candidate)
+	if err != nil {
+		pr.logger.Info("Cannot get Patroni leader, retrying", zap.Error(err))
+	}
+	switch {
+	case leader == "":
+		status.Message = "Waiting for the new leader"
+	case !isMasterLabeled(leader):
+		status.Leader = leader
+		status.Message = fmt.Sprintf("Waiting for pgtype=master label on %s", leader)
+	default:
+		pr.logger.Info(fmt.Sprintf("Switchover succeeded, current leader: %s", leader))
+		status.Leader = leader
+		if leader == status.PreviousLeader {
+			status.Message = fmt.Sprintf("%s is already the leader", leader)
+		} else {
+			status.Message = fmt.Sprintf("Leader changed from %s to %s", status.PreviousLeader, leader)
+		}
+		return finishSwitchover(status, qubershipv1.SwitchoverSucceeded), 0
+	}
+	if status.StartTime != nil && time.Since(status.StartTime.Time) > switchoverTimeout {
+		status.Message = fmt.Sprintf("Switchover is not finished in %s: %s", switchoverTimeout, status.Message)
+		pr.logger.Error(status.Message)
+		return finishSwitchover(status, qubershipv1.SwitchoverFailed), 0
+	}
+	return status, switchoverPollInterval
+}
+
+func (pr *PatroniCoreReconciler) startSwitchover(request *qubershipv1.Switchover, patroniUrl string) (*qubershipv1.SwitchoverStatus, time.Duration) {
+	startTime := metav1.Now()
+	status := &qubershipv1.SwitchoverStatus{
+		Phase:       qubershipv1.SwitchoverRunning,
+		Candidate:   request.Candidate,
+		ScheduledAt: request.ScheduledAt,
+		StartTime:   &startTime,
+	}
+	leader, leaderUrl, err := patroni.GetLeader(patroniUrl)
+	if err != nil {
+		pr.logger.Error("Cannot start switchover", zap.Error(err))
+		status.Message = err.Error()
+		return finishSwitchover(status, qubershipv1.SwitchoverFailed), 0
+	}
+	status.PreviousLeader = leader
+	if leader == request.Candidate {
+		status.Message = fmt.Sprintf("%s is already the leader, checking labels", leader)
+		return status, switchoverPollInterval
+	}
+	if err = patroni.Switchover(leaderUrl, leader, request.Candidate); err != nil {
+		pr.logger.Error("Switchover failed", zap.Error(err))
+		status.Message = err.Error()
+		return finishSwitchover(status, qubershipv1.SwitchoverFailed), 0
+	}
+	status.Message = "Switchover is requested, waiting for the new leader"
+	return status, switchoverPollInterval
+}
+
+func finishSwitchover(status *qubershipv1.SwitchoverStatus, phase string) *qubershipv1.SwitchoverStatus {
+	completionTime := metav1.Now()
+	status.Phase = phase
+	status.CompletionTime = &completionTime
+	return status
+}
+
+// isSameSwitchover returns true if status belongs to the requested switchover
+func isSameSwitchover(status *qubershipv1.SwitchoverStatus, request *qubershipv1.Switchover) bool {
+	return status != nil && status.Candidate == request.Candidate && status.ScheduledAt.Equal(request.ScheduledAt)
+}
+
+func (pr *PatroniCoreReconciler) updateSwitchoverStatus(status *qubershipv1.SwitchoverStatus) error {
+	return pr.helper.UpdatePatroniCoreStatus(func(crStatus *qubershipv1.PatroniCoreStatus) {
+		crStatus.Switchover = status
+	})
+}
@@ -0,0 +1,250 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	qubershipv1 "github.com/Netcracker/pgskipper-operator/api/patroni/v1"
+	"github.com/Netcracker/pgskipper-operator/pkg/patroni"
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// fakePatroni is a Patroni cluster where every member has own REST API server
+// and /cluster is served by the API service of the cluster
+type fakePatroni struct {
+	mu          sync.Mutex
+	leader      string
+	names       []string
+	members     map[string]*httptest.Server
+	api         *httptest.Server
+	switchovers int
+	// noElection makes /switchover accepted without change of the leader
+	noElection bool
+}
+
+func newFakePatroni(t *testing.T, leader string, names ...string) *fakePatroni {
+	t.Helper()
+	f := &fakePatroni{leader: leader, names: names, members: map[string]*httptest.Server{}}
+	for _, name := range names {
+		server := httptest.NewServer(f.memberHandler(name))
+		t.Cleanup(server.Close)
+		f.members[name] = server
+	}
+	f.api = httptest.NewServer(http.HandlerFunc(f.serveCluster))
+	t.Cleanup(f.api.Close)
+	return f
+}
+
+func (f *fakePatroni) url() string {
+	return f.api.URL + "/"
+}
+
+func (f *fakePatroni) currentLeader() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.leader
+}
+
+func (f *fakePatroni) serveCluster(w http.ResponseWriter, _ *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	members := make([]patroni.Members, 0, len(f.names))
+	for _, name := range f.names {
+		role := "replica"
+		if name == f.leader {
+			role = "leader"
+		}
+		members = append(members, patroni.Members{"name": name, "role": role, "state": "running",
+			"host": "127.0.0.1", "api_url": f.members[name].URL + "/patroni"})
+	}
+	_ = json.NewEncoder(w).Encode(patroni.ClusterResponse{Members: members})
+}
+
+func (f *fakePatroni) memberHandler(name string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/patroni", func(w http.ResponseWriter, _ *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		role := "replica"
+		if name == f.leader {
+			role = "primary"
+		}
+		_, _ = fmt.Fprintf(w, `{"state": "running", "role": %q, "patroni": {"name": %q}}`, role, name)
+	})
+	mux.HandleFunc("/switchover", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		body := map[string]string{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if name != f.leader || body["leader"] != f.leader {
+			http.Error(w, "leader name does not match", http.StatusPreconditionFailed)
+			return
+		}
+		if _, ok := f.members[body["candidate"]]; !ok {
+			http.Error(w, fmt.Sprintf("Member %s does not exist", body["candidate"]), http.StatusPreconditionFailed)
+			return
+		}
+		f.switchovers++
+		if !f.noElection {
+			f.leader = body["candidate"]
+		}
+		_, _ = fmt.Fprintf(w, "Successfully switched over to %q", body["candidate"])
+	})
+	return mux
+}
+
+func newSwitchoverReconciler() *PatroniCoreReconciler {
+	return &PatroniCoreReconciler{logger: *zap.NewNop()}
+}
+
+func labeledPod(pod string) func(string) bool {
+	return func(leader string) bool {
+		return leader == pod
+	}
+}
+
+func assertSwitchoverPhase(t *testing.T, status *qubershipv1.SwitchoverStatus, requeueAfter time.Duration, phase string) {
+	t.Helper()
+	if status.Phase != phase {
+		t.Fatalf("phase is %s (%s), expected %s", status.Phase, status.Message, phase)
+	}
+	finished := phase == qubershipv1.SwitchoverSucceeded || phase == qubershipv1.SwitchoverFailed
+	if finished != (requeueAfter == 0) {
+		t.Errorf("%s switchover is requeued after %s", phase, requeueAfter)
+	}
+	if finished != (status.CompletionTime != nil) {
+		t.Errorf("completion time of %s switchover is %v", phase, status.CompletionTime)
+	}
+}
+
+func TestSwitchoverSucceeded(t *testing.T) {
+	fake := newFakePatroni(t, "pg-patroni-node1-0", "pg-patroni-node1-0", "pg-patroni-node2-0", "pg-patroni-node3-0")
+	pr := newSwitchoverReconciler()
+	request := &qubershipv1.Switchover{Candidate: "pg-patroni-node2-0"}
+
+	status, requeueAfter := pr.stepSwitchover(request, nil, fake.url(), labeledPod("pg-patroni-node1-0"))
+	assertSwitchoverPhase(t, status, requeueAfter, qubershipv1.SwitchoverRunning)
+	if status.PreviousLeader != "pg-patroni-node1-0" || status.StartTime == nil {
+		t.Errorf("unexpected status of started switchover: %+v", status)
+	}
+	if fake.switchovers != 1 || fake.currentLeader() != "pg-patroni-node2-0" {
+		t.Fatalf("switchover is requested %d times, leader is %s", fake.switchovers, fake.currentLeader())
+	}
+
+	// label is moved by Patroni a bit later than the leader is changed
+	status, requeueAfter = pr.stepSwitchover(request, status, fake.url(), labeledPod("pg-patroni-node1-0"))
+	assertSwitchoverPhase(t, status, requeueAfter, qubershipv1.SwitchoverRunning)
+	if !strings.Contains(status.Message, "pgtype=master") {
+		t.Errorf("message doesn't mention the label: %s", status.Message)
+	}
+
+	status, requeueAfter = pr.stepSwitchover(request, status, fake.url(), labeledPod("pg-patroni-node2-0"))
+	assertSwitchoverPhase(t, status, requeueAfter, qubershipv1.SwitchoverSucceeded)
+	if status.Leader != "pg-patroni-node2-0" || status.PreviousLeader != "pg-patroni-node1-0" {
+		t.Errorf("unexpected leaders in status: %+v", status)
+	}
+	if fake.switchovers != 1 {
+		t.Errorf("switchover is requested %d times, expected once", fake.switchovers)
+	}
+}
+
+func TestSwitchoverCandidateNotFound(t *testing.T) {
+	fake := newFakePatroni(t, "pg-patroni-node1-0", "pg-patroni-node1-0", "pg-patroni-node2-0")
+	request := &qubershipv1.Switchover{Candidate: "pg-patroni-node5-0"}
+
+	status, requeueAfter := newSwitchoverReconciler().stepSwitchover(request, nil, fake.url(), labeledPod("pg-patroni-node1-0"))
+	assertSwitchoverPhase(t, status, requeueAfter, qubershipv1.SwitchoverFailed)
+	if !strings.Contains(status.Message, "pg-patroni-node5-0 does not exist") {
+		t.Errorf("message doesn't contain Patroni response: %s", status.Message)
+	}
+	if fake.currentLeader() != "pg-patroni-node1-0" {
+		t.Errorf("leader is changed to %s", fake.currentLeader())
+	}
+}
+
+func TestSwitchoverTimeout(t *testing.T) {
+	fake := newFakePatroni(t, "pg-patroni-node1-0", "pg-patroni-node1-0", "pg-patroni-node2-0")
+	fake.noElection = true
+	pr := newSwitchoverReconciler()
+	request := &qubershipv1.Switchover{Candidate: "pg-patroni-node2-0"}
+
+	status, requeueAfter := pr.stepSwitchover(request, nil, fake.url(), labeledPod("pg-patroni-node1-0"))
+	assertSwitchoverPhase(t, status, requeueAfter, qubershipv1.SwitchoverRunning)
+
+	status, requeueAfter = pr.stepSwitchover(request, status, fake.url(), labeledPod("pg-patroni-node1-0"))
+	assertSwitchoverPhase(t, status, requeueAfter, qubershipv1.SwitchoverRunning)
+
+	startTime := metav1.NewTime(time.Now().Add(-switchoverTimeout - time.Second))
+	status.StartTime = &startTime
+	status, requeueAfter = pr.stepSwitchover(request, status, fake.url(), labeledPod("pg-patroni-node1-0"))
+	assertSwitchoverPhase(t, status, requeueAfter, qubershipv1.SwitchoverFailed)
+	if !strings.Contains(status.Message, "is not finished in") {
+		t.Errorf("message doesn't mention timeout: %s", status.Message)
+	}
+	if fake.switchovers != 1 {
+		t.Errorf("switchover is requested %d times, expected once", fake.switchovers)
+	}
+}
+
+func TestSwitchoverToCurrentLeader(t *testing.T) {
+	fake := newFakePatroni(t, "pg-patroni-node1-0", "pg-patroni-node1-0", "pg-patroni-node2-0")
+	pr := newSwitchoverReconciler()
+	request := &qubershipv1.Switchover{Candidate: "pg-patroni-node1-0"}
+
+	status, requeueAfter := pr.stepSwitchover(request, nil, fake.url(), labeledPod("pg-patroni-node1-0"))
+	assertSwitchoverPhase(t, status, requeueAfter, qubershipv1.SwitchoverRunning)
+	status, requeueAfter = pr.stepSwitchover(request, status, fake.url(), labeledPod("pg-patroni-node1-0"))
+	assertSwitchoverPhase(t, status, requeueAfter, qubershipv1.SwitchoverSucceeded)
+	if !strings.Contains(status.Message, "already the leader") {
+		t.Errorf("unexpected message: %s", status.Message)
+	}
+	if fake.switchovers != 0 {
+		t.Errorf("switchover is requested %d times for the current leader", fake.switchovers)
+	}
+}
+
+func TestSwitchoverResumedAfterRestart(t *testing.T) {
+	// switchover was requested by the previous operator pod, Patroni has already elected the candidate
+	fake := newFakePatroni(t, "pg-patroni-node2-0", "pg-patroni-node1-0", "pg-patroni-node2-0")
+	request := &qubershipv1.Switchover{Candidate: "pg-patroni-node2-0"}
+	startTime := metav1.NewTime(time.Now().Add(-time.Minute))
+	running := &qubershipv1.SwitchoverStatus{
+		Phase:          qubershipv1.SwitchoverRunning,
+		Candidate:      "pg-patroni-node2-0",
+		PreviousLeader: "pg-patroni-node1-0",
+		StartTime:      &startTime,
+	}
+
+	status, requeueAfter := newSwitchoverReconciler().stepSwitchover(request, running, fake.url(), labeledPod("pg-patroni-node2-0"))
+	assertSwitchoverPhase(t, status, requeueAfter, qubershipv1.SwitchoverSucceeded)
+	if fake.switchovers != 0 {
+		t.Errorf("switchover is requested again after restart")
+	}
+	if running.Phase != qubershipv1.SwitchoverRunning {
+		t.Errorf("current status is changed in place")
+	}
+}
@@ -26,6 +26,7 @@ import (
 
 	"k8s.io/apimachinery/pkg/util/wait"
 
+	"github.com/jackc/pgconn"
 	pgx "github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"go.uber.org/zap"
@@ -42,6 +43,13 @@ var (
 	ssl      = "off"
 )
 
+// Querier is implemented by both pooled and single connections
+type Querier interface {
+	Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
 type PostgresClient struct {
 	adapter *postgresAdapter
 }
@@ -73,6 +81,19 @@ func GetPostgresClientForHost(pgHost_ string) *PostgresClient {
 	return &PostgresClient{adapter: newAdapter(pgHost_, 5432, *pgUser, *pgPass, dbName, ssl)}
 }
 
+// GetPostgresClientForHostAndPort returns new client, which has to be closed by caller, or nil if host is not reachable
+func GetPostgresClientForHostAndPort(pgHost string, port int) *PostgresClient {
+	adapter := newAdapter(pgHost, port, *pgUser, *pgPass, dbName, ssl)
+	if adapter == nil {
+		return nil
+	}
+	return &PostgresClient{adapter: adapter}
+}
+
+func (c *PostgresClient) Close() {
+	c.adapter.Pool.Close()
+}
+
 func UpdatePostgresClientPassword(pass string) {
 	pgPass = &pass
 	instance = nil
@@ -119,6 +140,20 @@ func (c *PostgresClient) ExecuteForDB(dbName, query string) error {
 	}
 }
 
+// ExecFormat executes statement built by PostgreSQL format() function, %I arguments are quoted as identifiers
+// and %L arguments as literals by the server. It's used for utility commands, which don't accept bind parameters.
+func ExecFormat(conn Querier, format string, args ...string) error {
+	var query string
+	if args == nil {
+		args = []string{}
+	}
+	if err := conn.QueryRow(context.Background(), "SELECT format($1::text, VARIADIC $2::text[])", format, args).Scan(&query); err != nil {
+		return err
+	}
+	_, err := conn.Exec(context.Background(), query)
+	return err
+}
+
 func newAdapter(host string, port int, username string, password string, database string, ssl string) *postgresAdapter {
 
 	connectionString := getConnectionUrl(username, password, database, host, port, ssl)
@@ -21,6 +21,7 @@ import (
 
 	"github.com/Netcracker/pgskipper-operator/pkg/client"
 	"github.com/Netcracker/pgskipper-operator/pkg/helper"
+	"github.com/Netcracker/pgskipper-operator/pkg/metrics"
 	"github.com/Netcracker/pgskipper-operator/pkg/util"
 	"github.com/Netcracker/qubership-credential-manager/pkg/manager"
 	"go.uber.org/zap"
@@ -65,6 +66,7 @@ func ChangeCredsCore(newSecret, oldSecret *corev1.Secret) error {
 
 	// Apply new creds for client
 	client.UpdatePostgresClientPassword(string(newSecret.Data[passwordKey]))
+	metrics.CredentialsRotated(PostgresSecretName)
 	logger.Info("PostgreSQL credentials has been changed")
 	return nil
 }
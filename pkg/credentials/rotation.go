@@ -0,0 +1,406 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package credentials
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"time"
+
+	qubershipv1 "github.com/Netcracker/pgskipper-operator/api/apps/v1"
+	patroniv1 "github.com/Netcracker/pgskipper-operator/api/patroni/v1"
+	"github.com/Netcracker/pgskipper-operator/pkg/client"
+	"github.com/Netcracker/pgskipper-operator/pkg/helper"
+	"github.com/Netcracker/pgskipper-operator/pkg/metrics"
+	"github.com/Netcracker/pgskipper-operator/pkg/pooler"
+	"github.com/Netcracker/pgskipper-operator/pkg/util"
+	"github.com/Netcracker/qubership-credential-manager/pkg/manager"
+	credUtils "github.com/Netcracker/qubership-credential-manager/pkg/utils"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/pbkdf2"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	ReplicatorSecretName            = "replicator-credentials"
+	PostgresExporterUserSecretName  = "postgres-exporter-user-credentials"
+	QueryExporterUserSecretName     = "query-exporter-user-credentials"
+	rotatedSecretAnnotationTemplate = "checksum/%s"
+
+	alphanumericChars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+	// symbols which don't need escaping in connection strings, shell and pgbouncer configuration
+	passwordSymbols       = "-_.~!*+="
+	defaultPasswordLength = 32
+	scramIterations       = 4096
+
+	rotationRolloutTimeout = 30 * time.Minute
+)
+
+// rotationTarget describes the user stored in the Secret and workloads which read the Secret,
+// the workloads are rolled in the order they are listed, after Patroni
+type rotationTarget struct {
+	username string
+	patroni  bool
+	// pooler is set for pgbouncer-secret, the password is kept in userlist.txt and pooler pods re-read it on RELOAD
+	pooler      bool
+	deployments []string
+}
+
+var rotationTargets = map[string]rotationTarget{
+	PostgresSecretName: {
+		username: "postgres",
+		patroni:  true,
+		deployments: []string{"connection-puller", "postgres-backup-daemon", "monitoring-collector",
+			dbaasAdapterDeploymentName, postgresExporterDeploymentName, "query-exporter"},
+	},
+	ReplicatorSecretName:           {username: "replicator", patroni: true},
+	PostgresExporterUserSecretName: {username: "postgres-exporter", deployments: []string{postgresExporterDeploymentName}},
+	QueryExporterUserSecretName:    {username: "query-exporter", deployments: []string{"query-exporter"}},
+	pooler.SecretName:              {pooler: true},
+}
+
+// RotateCredentials sets a new password to the user from the Secret, stores the password in the Secret
+// and rolls workloads which use it. The password is sent to PostgreSQL as SCRAM-SHA-256 verifier.
+func RotateCredentials(policy qubershipv1.CredentialsRotationPolicy, cluster *patroniv1.PatroniClusterSettings) error {
+	target, ok := rotationTargets[policy.SecretName]
+	if !ok {
+		return fmt.Errorf("rotation of secret %s is not supported", policy.SecretName)
+	}
+	rm := &helper.GetHelper().ResourceManager
+	secret, err := rm.GetSecret(policy.SecretName)
+	if err != nil {
+		return err
+	}
+	username, oldPassword, err := readRotationCredentials(secret, target)
+	if err != nil {
+		return fmt.Errorf("cannot read credentials of secret %s: %w", policy.SecretName, err)
+	}
+
+	password, err := generatePassword(policy.PasswordLength, policy.Charset)
+	if err != nil {
+		return err
+	}
+	verifier, err := scramSHA256Verifier(password)
+	if err != nil {
+		return err
+	}
+	pgC := client.GetPostgresClient(cluster.PgHost)
+	if pgC == nil {
+		return fmt.Errorf("postgresql %s is not available", cluster.PgHost)
+	}
+	logger.Info(fmt.Sprintf("Rotating password of user %s from secret %s", username, policy.SecretName))
+	if err := alterPassword(pgC, username, verifier); err != nil {
+		return err
+	}
+	if err := updateSecretPassword(policy.SecretName, target, password); err != nil {
+		logger.Error(fmt.Sprintf("Cannot store new password in secret %s, restoring the old one", policy.SecretName), zap.Error(err))
+		// old password is not necessarily ASCII, so it's hashed by the server
+		if restoreErr := alterPassword(pgC, username, oldPassword); restoreErr != nil {
+			logger.Error(fmt.Sprintf("Cannot restore password of user %s", username), zap.Error(restoreErr))
+		}
+		return err
+	}
+	if policy.SecretName == PostgresSecretName {
+		client.UpdatePostgresClientPassword(password)
+	}
+	metrics.CredentialsRotated(policy.SecretName)
+
+	if target.pooler {
+		if err := reloadPooler(pooler.NewPgBouncerCreds(username, oldPassword), pooler.NewPgBouncerCreds(username, password)); err != nil {
+			return fmt.Errorf("password is rotated, but pooler is not reloaded: %w", err)
+		}
+	}
+	if err := rollWorkloads(rm, policy.SecretName, target, cluster); err != nil {
+		return fmt.Errorf("password is rotated, but workloads are not rolled: %w", err)
+	}
+	logger.Info(fmt.Sprintf("Password of user %s is rotated", username))
+	return nil
+}
+
+func alterPassword(pgC *client.PostgresClient, username string, password string) error {
+	conn, err := pgC.GetConnection()
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+	return client.ExecFormat(conn, "ALTER ROLE %I WITH PASSWORD %L", username, password)
+}
+
+// readRotationCredentials returns username and password from the Secret, pgbouncer-secret keeps them in userlist.txt
+func readRotationCredentials(secret *corev1.Secret, target rotationTarget) (string, string, error) {
+	if target.pooler {
+		return pooler.ParseUserList(secret.Data[pooler.UserListKey])
+	}
+	username := string(secret.Data["username"])
+	if username == "" {
+		username = target.username
+	}
+	return username, string(secret.Data[passwordKey]), nil
+}
+
+func setSecretPassword(secret *corev1.Secret, target rotationTarget, password string) error {
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	if target.pooler {
+		userList, err := pooler.SetUserListPassword(secret.Data[pooler.UserListKey], password)
+		if err != nil {
+			return err
+		}
+		secret.Data[pooler.UserListKey] = userList
+		return nil
+	}
+	secret.Data[passwordKey] = []byte(password)
+	return nil
+}
+
+// updateSecretPassword updates the copy of the Secret kept by credential manager first, so the change
+// is not applied to PostgreSQL once again with the outdated password when the Secret is updated
+func updateSecretPassword(secretName string, target rotationTarget, password string) error {
+	k8sClient, err := util.GetClient()
+	if err != nil {
+		return err
+	}
+	for _, name := range []string{credUtils.GetOldSecretName(secretName), secretName} {
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			secret := &corev1.Secret{}
+			if err := k8sClient.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: util.GetNameSpace()}, secret); err != nil {
+				return err
+			}
+			if err := setSecretPassword(secret, target, password); err != nil {
+				return err
+			}
+			return k8sClient.Update(context.TODO(), secret)
+		})
+		if errors.IsNotFound(err) && name != secretName {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reloadPooler applies the new password to running pooler pods without restart, the same way reconcile applies
+// manual change of pgbouncer-secret: pgbouncer-credentials is updated and pods re-read auth_file on RELOAD
+func reloadPooler(oldCreds *pooler.PgBouncerCreds, creds *pooler.PgBouncerCreds) error {
+	hp := helper.GetHelper()
+	cr, err := hp.GetPostgresServiceCR()
+	if err != nil {
+		return err
+	}
+	if err := hp.CreateOrUpdateSecret(pooler.GetCredentialsSecret(creds)); err != nil {
+		return err
+	}
+	return pooler.ReloadPgBouncer(hp, cr.Spec.Pooler, oldCreds, creds)
+}
+
+// rollWorkloads restarts Patroni members and then deployments which read the Secret
+func rollWorkloads(rm *helper.ResourceManager, secretName string, target rotationTarget, cluster *patroniv1.PatroniClusterSettings) error {
+	hash, err := manager.CalculateSecretDataHash(secretName)
+	if err != nil {
+		return err
+	}
+	if target.patroni {
+		if err := rollPatroni(rm, secretName, hash, cluster); err != nil {
+			return err
+		}
+	}
+	annotation := fmt.Sprintf(rotatedSecretAnnotationTemplate, secretName)
+	if secretName == PostgresSecretName {
+		// the same annotation is set by reconcile, so reconcile doesn't restart pods once again
+		annotation = manager.GetAnnotationName(0)
+	}
+	for _, name := range target.deployments {
+		deployments, err := rm.GetDeploymentsByNameRegExp(name)
+		if err != nil {
+			return err
+		}
+		for _, deployment := range deployments {
+			if err := rollDeployment(deployment, annotation, hash); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// rollPatroni waits until Patroni Core operator, which watches postgres-credentials, restarts Patroni with the new Secret,
+// other Secrets are not watched, so Patroni pods are deleted one by one, replicas first
+func rollPatroni(rm *helper.ResourceManager, secretName string, hash string, cluster *patroniv1.PatroniClusterSettings) error {
+	if secretName == PostgresSecretName {
+		logger.Info("Waiting for Patroni Core operator to restart Patroni with new credentials")
+		return wait.PollUntilContextTimeout(context.Background(), 10*time.Second, rotationRolloutTimeout, false, func(ctx context.Context) (bool, error) {
+			statefulSets, err := rm.GetStatefulsetByNameRegExp(cluster.PatroniDeploymentName)
+			if err != nil || len(statefulSets) == 0 {
+				return false, nil
+			}
+			for _, statefulSet := range statefulSets {
+				if statefulSet.Spec.Template.Annotations[manager.GetAnnotationName(0)] != hash ||
+					statefulSet.Status.ObservedGeneration < statefulSet.Generation ||
+					statefulSet.Status.ReadyReplicas != *statefulSet.Spec.Replicas ||
+					statefulSet.Status.UpdatedReplicas != *statefulSet.Spec.Replicas {
+					return false, nil
+				}
+			}
+			return true, nil
+		})
+	}
+
+	pods, err := rm.GetNamespacePodListBySelectors(cluster.PatroniCommonLabels)
+	if err != nil {
+		return err
+	}
+	var replicas, leaders []corev1.Pod
+	for _, pod := range pods.Items {
+		if hasLabels(pod.Labels, cluster.PatroniMasterSelectors) {
+			leaders = append(leaders, pod)
+		} else {
+			replicas = append(replicas, pod)
+		}
+	}
+	for _, pod := range append(replicas, leaders...) {
+		logger.Info(fmt.Sprintf("Restarting %s to apply new credentials", pod.Name))
+		if err := rm.DeletePod(&pod); err != nil {
+			return err
+		}
+		if err := waitForPodRecreated(pod); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func rollDeployment(deployment *appsv1.Deployment, annotation string, hash string) error {
+	if deployment.Spec.Template.Annotations[annotation] == hash {
+		return nil
+	}
+	k8sClient, err := util.GetClient()
+	if err != nil {
+		return err
+	}
+	logger.Info(fmt.Sprintf("Restarting %s to apply new credentials", deployment.Name))
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		current := &appsv1.Deployment{}
+		if err := k8sClient.Get(context.TODO(), types.NamespacedName{Name: deployment.Name, Namespace: deployment.Namespace}, current); err != nil {
+			return err
+		}
+		manager.AddAnnotationsToPodTemplate(&current.Spec.Template, map[string]string{annotation: hash})
+		return k8sClient.Update(context.TODO(), current)
+	})
+	if err != nil {
+		return err
+	}
+	return wait.PollUntilContextTimeout(context.Background(), 5*time.Second, rotationRolloutTimeout, false, func(ctx context.Context) (bool, error) {
+		current := &appsv1.Deployment{}
+		if err := k8sClient.Get(ctx, types.NamespacedName{Name: deployment.Name, Namespace: deployment.Namespace}, current); err != nil {
+			return false, nil
+		}
+		replicas := int32(1)
+		if current.Spec.Replicas != nil {
+			replicas = *current.Spec.Replicas
+		}
+		return current.Status.ObservedGeneration >= current.Generation &&
+			current.Status.UpdatedReplicas == replicas &&
+			current.Status.AvailableReplicas == replicas &&
+			current.Status.Replicas == replicas, nil
+	})
+}
+
+func waitForPodRecreated(pod corev1.Pod) error {
+	k8sClient, err := util.GetClient()
+	if err != nil {
+		return err
+	}
+	return wait.PollUntilContextTimeout(context.Background(), 5*time.Second, rotationRolloutTimeout, false, func(ctx context.Context) (bool, error) {
+		current := &corev1.Pod{}
+		if err := k8sClient.Get(ctx, types.NamespacedName{Name: pod.Name, Namespace: pod.Namespace}, current); err != nil {
+			return false, nil
+		}
+		if current.UID == pod.UID {
+			return false, nil
+		}
+		for _, condition := range current.Status.Conditions {
+			if condition.Type == corev1.PodReady {
+				return condition.Status == corev1.ConditionTrue, nil
+			}
+		}
+		return false, nil
+	})
+}
+
+func hasLabels(labels map[string]string, selector map[string]string) bool {
+	for key, value := range selector {
+		if labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+func generatePassword(length int, charset string) (string, error) {
+	if length <= 0 {
+		length = defaultPasswordLength
+	}
+	chars := alphanumericChars
+	if charset == "alphanumericSymbols" {
+		chars += passwordSymbols
+	}
+	password := make([]byte, length)
+	for i := range password {
+		idx, err := rand.Int(rand.Reader, big.NewInt(int64(len(chars))))
+		if err != nil {
+			return "", err
+		}
+		password[i] = chars[idx.Int64()]
+	}
+	return string(password), nil
+}
+
+// scramSHA256Verifier builds password verifier in the format of pg_authid.rolpassword, as defined in RFC 5802,
+// so the password itself is not sent to the server. Generated passwords are ASCII and don't need SASLprep.
+func scramSHA256Verifier(password string) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	return scramVerifier(password, salt), nil
+}
+
+func scramVerifier(password string, salt []byte) string {
+	saltedPassword := pbkdf2.Key([]byte(password), salt, scramIterations, sha256.Size, sha256.New)
+	clientKey := hmacSHA256(saltedPassword, "Client Key")
+	storedKey := sha256.Sum256(clientKey)
+	serverKey := hmacSHA256(saltedPassword, "Server Key")
+	encode := base64.StdEncoding.EncodeToString
+	return fmt.Sprintf("SCRAM-SHA-256$%d:%s$%s:%s", scramIterations, encode(salt), encode(storedKey[:]), encode(serverKey))
+}
+
+func hmacSHA256(key []byte, message string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(message))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,98 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package credentials
+
+import (
+	"testing"
+
+	"github.com/Netcracker/pgskipper-operator/pkg/pooler"
+	_ "github.com/Netcracker/pgskipper-operator/pkg/util/testenv"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestRotationCredentialsOfSecrets(t *testing.T) {
+	tests := []struct {
+		name         string
+		secretName   string
+		data         map[string][]byte
+		wantUsername string
+		wantPassword string
+		passwordKey  string
+	}{
+		{
+			name:         "username and password keys",
+			secretName:   PostgresExporterUserSecretName,
+			data:         map[string][]byte{"username": []byte("exporter"), "password": []byte("old")},
+			wantUsername: "exporter",
+			wantPassword: "old",
+			passwordKey:  passwordKey,
+		},
+		{
+			name:         "default username",
+			secretName:   ReplicatorSecretName,
+			data:         map[string][]byte{"password": []byte("old")},
+			wantUsername: "replicator",
+			wantPassword: "old",
+			passwordKey:  passwordKey,
+		},
+		{
+			name:         "userlist of pooler",
+			secretName:   pooler.SecretName,
+			data:         map[string][]byte{pooler.UserListKey: []byte("\"pgbouncer\" \"old\"\n\"reader\" \"secret\"\n")},
+			wantUsername: "pgbouncer",
+			wantPassword: "old",
+			passwordKey:  pooler.UserListKey,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, ok := rotationTargets[tt.secretName]
+			if !ok {
+				t.Fatalf("rotation of %s is not supported", tt.secretName)
+			}
+			secret := &corev1.Secret{Data: tt.data}
+			username, password, err := readRotationCredentials(secret, target)
+			if err != nil || username != tt.wantUsername || password != tt.wantPassword {
+				t.Fatalf("readRotationCredentials() = %q, %q, %v, want %q, %q", username, password, err, tt.wantUsername, tt.wantPassword)
+			}
+
+			if err := setSecretPassword(secret, target, "rotated"); err != nil {
+				t.Fatal(err)
+			}
+			username, password, err = readRotationCredentials(secret, target)
+			if err != nil || username != tt.wantUsername || password != "rotated" {
+				t.Errorf("credentials after rotation = %q, %q, %v, want %q, rotated", username, password, err, tt.wantUsername)
+			}
+			if _, ok := secret.Data[tt.passwordKey]; !ok {
+				t.Errorf("password is not stored in %s", tt.passwordKey)
+			}
+		})
+	}
+}
+
+func TestRotationOfPoolerSecretKeepsOtherUsers(t *testing.T) {
+	secret := &corev1.Secret{Data: map[string][]byte{
+		pooler.UserListKey: []byte("\"pgbouncer\" \"old\"\n\"reader\" \"secret\"\n"),
+	}}
+	if err := setSecretPassword(secret, rotationTargets[pooler.SecretName], "rotated"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(secret.Data[pooler.UserListKey]), "\"pgbouncer\" \"rotated\"\n\"reader\" \"secret\"\n"; got != want {
+		t.Errorf("userlist.txt = %q, want %q", got, want)
+	}
+	if _, ok := secret.Data[passwordKey]; ok {
+		t.Error("password key is added to pgbouncer-secret")
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package credentials
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"sort"
+
+	"github.com/Netcracker/pgskipper-operator/pkg/client"
+	"github.com/Netcracker/pgskipper-operator/pkg/helper"
+	"github.com/Netcracker/pgskipper-operator/pkg/pooler"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/api/errors"
+)
+
+const md5RolesQuery = "SELECT rolname, rolpassword FROM pg_authid WHERE rolpassword LIKE 'md5%' ORDER BY rolname"
+
+// scramMigrationSecrets are Secrets with passwords of users created by the operators
+var scramMigrationSecrets = []string{PostgresSecretName, ReplicatorSecretName,
+	PostgresExporterUserSecretName, QueryExporterUserSecretName, pooler.SecretName}
+
+// MigrateToScram replaces md5 hashes of the users whose passwords are stored in the operator Secrets
+// with SCRAM-SHA-256 verifiers. The hash is replaced only if it matches the password from the Secret.
+// It returns migrated roles and roles which still have md5 hashes. Standby cluster is read-only,
+// so with migrate=false roles are only checked.
+func MigrateToScram(rm *helper.ResourceManager, pgHost string, migrate bool) ([]string, []string, error) {
+	pgC := client.GetPostgresClient(pgHost)
+	if pgC == nil {
+		return nil, nil, fmt.Errorf("postgresql %s is not available", pgHost)
+	}
+	md5Roles, err := getMd5Roles(pgC)
+	if err != nil {
+		return nil, nil, err
+	}
+	var migrated []string
+	if migrate {
+		for _, secretName := range scramMigrationSecrets {
+			username, password, err := getSecretCredentials(rm, secretName)
+			if errors.IsNotFound(err) {
+				continue
+			}
+			if err != nil {
+				return migrated, nil, err
+			}
+			hash, ok := md5Roles[username]
+			if !ok {
+				continue
+			}
+			if hash != md5Hash(username, password) {
+				logger.Warn(fmt.Sprintf("md5 hash of user %s doesn't match password from secret %s, it's not migrated", username, secretName))
+				continue
+			}
+			if err := alterPassword(pgC, username, scramPassword(password)); err != nil {
+				logger.Error(fmt.Sprintf("Cannot migrate password of user %s to SCRAM", username), zap.Error(err))
+				return migrated, nil, err
+			}
+			logger.Info(fmt.Sprintf("Password of user %s is migrated to SCRAM", username))
+			migrated = append(migrated, username)
+			delete(md5Roles, username)
+		}
+	}
+	remaining := make([]string, 0, len(md5Roles))
+	for role := range md5Roles {
+		remaining = append(remaining, role)
+	}
+	sort.Strings(remaining)
+	return migrated, remaining, nil
+}
+
+func getMd5Roles(pgC *client.PostgresClient) (map[string]string, error) {
+	conn, err := pgC.GetConnection()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+	rows, err := conn.Query(context.Background(), md5RolesQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	roles := map[string]string{}
+	for rows.Next() {
+		var name, hash string
+		if err := rows.Scan(&name, &hash); err != nil {
+			return nil, err
+		}
+		roles[name] = hash
+	}
+	return roles, rows.Err()
+}
+
+func getSecretCredentials(rm *helper.ResourceManager, secretName string) (string, string, error) {
+	secret, err := rm.GetSecret(secretName)
+	if err != nil {
+		return "", "", err
+	}
+	if secretName == pooler.SecretName {
+		return pooler.ParseUserList(secret.Data[pooler.UserListKey])
+	}
+	return string(secret.Data["username"]), string(secret.Data[passwordKey]), nil
+}
+
+// md5Hash returns password hash in the format of pg_authid.rolpassword
+func md5Hash(username string, password string) string {
+	sum := md5.Sum([]byte(password + username))
+	return "md5" + hex.EncodeToString(sum[:])
+}
+
+// scramPassword returns SCRAM-SHA-256 verifier of ASCII password, other passwords need SASLprep
+// and are sent as is, so the server hashes them with password_encryption set to scram-sha-256
+func scramPassword(password string) string {
+	for i := 0; i < len(password); i++ {
+		if password[i] >= 0x80 {
+			return password
+		}
+	}
+	verifier, err := scramSHA256Verifier(password)
+	if err != nil {
+		return password
+	}
+	return verifier
+}
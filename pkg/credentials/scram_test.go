@@ -0,0 +1,80 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package credentials
+
+import (
+	"encoding/base64"
+	"regexp"
+	"strings"
+	"testing"
+
+	_ "github.com/Netcracker/pgskipper-operator/pkg/util/testenv"
+)
+
+var scramVerifierRegexp = regexp.MustCompile(`^SCRAM-SHA-256\$4096:([A-Za-z0-9+/=]+)\$[A-Za-z0-9+/=]{44}:[A-Za-z0-9+/=]{44}$`)
+
+func TestScramVerifier(t *testing.T) {
+	// salt and password of the example of RFC 7677
+	salt, err := base64.StdEncoding.DecodeString("W22ZaJ0SNY7soEsUEjb6gQ==")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "SCRAM-SHA-256$4096:W22ZaJ0SNY7soEsUEjb6gQ==$WG5d8oPm3OtcPnkdi4Uo7BkeZkBFzpcXkuLmtbsT4qY=:wfPLwcE6nTWhTAmQ7tl2KeoiWGPlZqQxSrmfPwDl2dU="
+	if verifier := scramVerifier("pencil", salt); verifier != expected {
+		t.Errorf("scramVerifier() = %s, want %s", verifier, expected)
+	}
+}
+
+func TestScramSHA256VerifierUsesRandomSalt(t *testing.T) {
+	first, err := scramSHA256Verifier("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := scramSHA256Verifier("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, verifier := range []string{first, second} {
+		match := scramVerifierRegexp.FindStringSubmatch(verifier)
+		if match == nil {
+			t.Fatalf("verifier %s doesn't match format of pg_authid", verifier)
+		}
+		if salt, err := base64.StdEncoding.DecodeString(match[1]); err != nil || len(salt) != 16 {
+			t.Errorf("salt of %s is %d bytes, %v", verifier, len(salt), err)
+		}
+	}
+	if first == second {
+		t.Errorf("verifiers of the same password are equal")
+	}
+}
+
+func TestScramPassword(t *testing.T) {
+	if password := scramPassword("secret"); !scramVerifierRegexp.MatchString(password) {
+		t.Errorf("ASCII password is sent as %s", password)
+	}
+	// non-ASCII passwords need SASLprep, they are hashed by the server
+	if password := scramPassword("sécret"); password != "sécret" {
+		t.Errorf("non-ASCII password is sent as %s", password)
+	}
+	if strings.Contains(scramPassword("secret"), "secret") {
+		t.Errorf("verifier contains the password")
+	}
+}
+
+func TestMd5Hash(t *testing.T) {
+	if hash := md5Hash("postgres", "secret"); hash != "md553f48b7c4b76a86ce72276c5755f217d" {
+		t.Errorf("md5Hash() = %s", hash)
+	}
+}
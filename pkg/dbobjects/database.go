@@ -0,0 +1,193 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dbobjects
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	v1 "github.com/Netcracker/pgskipper-operator/api/apps/v1"
+	pgClient "github.com/Netcracker/pgskipper-operator/pkg/client"
+	"github.com/Netcracker/pgskipper-operator/pkg/util"
+	pgx "github.com/jackc/pgx/v4"
+)
+
+var (
+	logger = util.GetLogger()
+	// reservedDatabases are not managed by PostgresDatabase
+	reservedDatabases = []string{"postgres", "template0", "template1"}
+)
+
+// SyncDatabase creates the database if it doesn't exist, corrects owner and creates or updates extensions
+func SyncDatabase(pgC *pgClient.PostgresClient, name string, spec *v1.PostgresDatabaseSpec) error {
+	if slices.Contains(reservedDatabases, name) {
+		return fmt.Errorf("database %s is reserved and can't be managed by PostgresDatabase", name)
+	}
+	conn, err := pgC.GetConnection()
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	var owner, encoding, lcCollate, lcCtype string
+	err = conn.QueryRow(context.Background(),
+		"SELECT pg_get_userbyid(datdba)::text, pg_encoding_to_char(encoding)::text, datcollate::text, datctype::text FROM pg_database WHERE datname = $1",
+		name).Scan(&owner, &encoding, &lcCollate, &lcCtype)
+	var immutableErr error
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		if err := createDatabase(conn, name, spec); err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	default:
+		if spec.Owner != "" && spec.Owner != owner {
+			logger.Info(fmt.Sprintf("Changing owner of database %s from %s to %s", name, owner, spec.Owner))
+			if err := pgClient.ExecFormat(conn, "ALTER DATABASE %I OWNER TO %I", name, spec.Owner); err != nil {
+				return err
+			}
+		}
+		immutableErr = checkImmutableSettings(name, spec, encoding, lcCollate, lcCtype)
+	}
+
+	if err := syncExtensions(pgC, name, spec.Extensions); err != nil {
+		return err
+	}
+	return immutableErr
+}
+
+// DropDatabase terminates connections to the database and drops it
+func DropDatabase(pgC *pgClient.PostgresClient, name string) error {
+	if slices.Contains(reservedDatabases, name) {
+		return fmt.Errorf("database %s is reserved and can't be dropped", name)
+	}
+	conn, err := pgC.GetConnection()
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	logger.Info(fmt.Sprintf("Dropping database %s", name))
+	if _, err := conn.Exec(context.Background(),
+		"SELECT pg_terminate_backend(pid) FROM pg_stat_activity WHERE datname = $1 AND pid <> pg_backend_pid()", name); err != nil {
+		return err
+	}
+	return pgClient.ExecFormat(conn, "DROP DATABASE IF EXISTS %I", name)
+}
+
+func createDatabase(conn pgClient.Querier, name string, spec *v1.PostgresDatabaseSpec) error {
+	logger.Info(fmt.Sprintf("Creating database %s", name))
+	format := "CREATE DATABASE %I"
+	args := []string{name}
+	if spec.Owner != "" {
+		format += " OWNER %I"
+		args = append(args, spec.Owner)
+	}
+	if spec.Encoding != "" || spec.LcCollate != "" || spec.LcCtype != "" {
+		// template1 can contain data in other encoding or locale
+		format += " TEMPLATE template0"
+	}
+	if spec.Encoding != "" {
+		format += " ENCODING %L"
+		args = append(args, spec.Encoding)
+	}
+	if spec.LcCollate != "" {
+		format += " LC_COLLATE %L"
+		args = append(args, spec.LcCollate)
+	}
+	if spec.LcCtype != "" {
+		format += " LC_CTYPE %L"
+		args = append(args, spec.LcCtype)
+	}
+	return pgClient.ExecFormat(conn, format, args...)
+}
+
+func checkImmutableSettings(name string, spec *v1.PostgresDatabaseSpec, encoding, lcCollate, lcCtype string) error {
+	var diffs []string
+	if spec.Encoding != "" && !strings.EqualFold(normalizeEncoding(spec.Encoding), normalizeEncoding(encoding)) {
+		diffs = append(diffs, fmt.Sprintf("encoding is %s", encoding))
+	}
+	if spec.LcCollate != "" && spec.LcCollate != lcCollate {
+		diffs = append(diffs, fmt.Sprintf("lcCollate is %s", lcCollate))
+	}
+	if spec.LcCtype != "" && spec.LcCtype != lcCtype {
+		diffs = append(diffs, fmt.Sprintf("lcCtype is %s", lcCtype))
+	}
+	if len(diffs) > 0 {
+		return fmt.Errorf("database %s can't be changed, %s", name, strings.Join(diffs, ", "))
+	}
+	return nil
+}
+
+// normalizeEncoding makes UTF-8 and UTF8 equal, PostgreSQL accepts both
+func normalizeEncoding(encoding string) string {
+	return strings.ReplaceAll(encoding, "-", "")
+}
+
+// syncExtensions creates missing extensions and updates version and schema of installed ones,
+// extensions removed from spec are kept, because objects of the database can depend on them
+func syncExtensions(pgC *pgClient.PostgresClient, dbName string, extensions []v1.DatabaseExtension) error {
+	if len(extensions) == 0 {
+		return nil
+	}
+	conn, err := pgC.GetConnectionToDb(dbName)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	for _, ext := range extensions {
+		var version, schema string
+		err := conn.QueryRow(context.Background(),
+			"SELECT e.extversion, n.nspname::text FROM pg_extension e JOIN pg_namespace n ON n.oid = e.extnamespace WHERE e.extname = $1",
+			ext.Name).Scan(&version, &schema)
+		if errors.Is(err, pgx.ErrNoRows) {
+			logger.Info(fmt.Sprintf("Creating extension %s in database %s", ext.Name, dbName))
+			format := "CREATE EXTENSION IF NOT EXISTS %I"
+			args := []string{ext.Name}
+			if ext.Schema != "" {
+				format += " SCHEMA %I"
+				args = append(args, ext.Schema)
+			}
+			if ext.Version != "" {
+				format += " VERSION %L"
+				args = append(args, ext.Version)
+			}
+			if err := pgClient.ExecFormat(conn, format, args...); err != nil {
+				return fmt.Errorf("can't create extension %s in database %s: %w", ext.Name, dbName, err)
+			}
+			continue
+		} else if err != nil {
+			return err
+		}
+		if ext.Version != "" && ext.Version != version {
+			logger.Info(fmt.Sprintf("Updating extension %s in database %s from %s to %s", ext.Name, dbName, version, ext.Version))
+			if err := pgClient.ExecFormat(conn, "ALTER EXTENSION %I UPDATE TO %L", ext.Name, ext.Version); err != nil {
+				return fmt.Errorf("can't update extension %s in database %s: %w", ext.Name, dbName, err)
+			}
+		}
+		if ext.Schema != "" && ext.Schema != schema {
+			logger.Info(fmt.Sprintf("Moving extension %s in database %s to schema %s", ext.Name, dbName, ext.Schema))
+			if err := pgClient.ExecFormat(conn, "ALTER EXTENSION %I SET SCHEMA %I", ext.Name, ext.Schema); err != nil {
+				return fmt.Errorf("can't move extension %s in database %s: %w", ext.Name, dbName, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dbobjects
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	v1 "github.com/Netcracker/pgskipper-operator/api/apps/v1"
+	pgClient "github.com/Netcracker/pgskipper-operator/pkg/client"
+)
+
+func getDatabaseOwner(t *testing.T, pgC *pgClient.PostgresClient, name string) string {
+	t.Helper()
+	return strings.Join(mustQueryStrings(t, pgC, "postgres",
+		"SELECT pg_get_userbyid(datdba)::text FROM pg_database WHERE datname = $1", name), "")
+}
+
+func TestSyncDatabaseCorrectsOwner(t *testing.T) {
+	pgC := requirePostgres(t)
+	name := "test_owned"
+	for _, role := range []string{"test_db_owner", "test_db_other"} {
+		dropRoleOnCleanup(t, pgC, role)
+		mustExec(t, pgC, "postgres", "CREATE ROLE "+role)
+	}
+	t.Cleanup(func() { _ = DropDatabase(pgC, name) })
+	spec := &v1.PostgresDatabaseSpec{Owner: "test_db_owner"}
+
+	if err := SyncDatabase(pgC, name, spec); err != nil {
+		t.Fatal(err)
+	}
+	if got := getDatabaseOwner(t, pgC, name); got != "test_db_owner" {
+		t.Fatalf("owner after creation = %q, want test_db_owner", got)
+	}
+
+	mustExec(t, pgC, "postgres", "ALTER DATABASE test_owned OWNER TO test_db_other")
+	if err := SyncDatabase(pgC, name, spec); err != nil {
+		t.Fatal(err)
+	}
+	if got := getDatabaseOwner(t, pgC, name); got != "test_db_owner" {
+		t.Errorf("owner after drift correction = %q, want test_db_owner", got)
+	}
+
+	// owner is kept when it's not set in the spec
+	if err := SyncDatabase(pgC, name, &v1.PostgresDatabaseSpec{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := getDatabaseOwner(t, pgC, name); got != "test_db_owner" {
+		t.Errorf("owner without owner in spec = %q, want test_db_owner", got)
+	}
+}
+
+func TestSyncDatabaseImmutableSettings(t *testing.T) {
+	pgC := requirePostgres(t)
+	name := "test_encoding"
+	dropRoleOnCleanup(t, pgC, "test_encoding_owner")
+	mustExec(t, pgC, "postgres", "CREATE ROLE test_encoding_owner")
+	t.Cleanup(func() { _ = DropDatabase(pgC, name) })
+
+	if err := SyncDatabase(pgC, name, &v1.PostgresDatabaseSpec{Encoding: "UTF8", LcCollate: "C", LcCtype: "C"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := SyncDatabase(pgC, name, &v1.PostgresDatabaseSpec{Encoding: "utf-8", LcCollate: "C"}); err != nil {
+		t.Errorf("equal settings are reported as changed: %v", err)
+	}
+
+	// mutable settings are synced even if immutable ones differ
+	err := SyncDatabase(pgC, name, &v1.PostgresDatabaseSpec{Owner: "test_encoding_owner", Encoding: "LATIN1"})
+	if err == nil || !strings.Contains(err.Error(), "encoding is UTF8") {
+		t.Errorf("changed encoding: error = %v", err)
+	}
+	if got := getDatabaseOwner(t, pgC, name); got != "test_encoding_owner" {
+		t.Errorf("owner = %q, want test_encoding_owner", got)
+	}
+}
+
+func TestDropDatabase(t *testing.T) {
+	pgC := requirePostgres(t)
+	name := "test_dropped_db"
+	if err := SyncDatabase(pgC, name, &v1.PostgresDatabaseSpec{}); err != nil {
+		t.Fatal(err)
+	}
+	// sessions of the database are terminated before drop
+	conn, err := pgC.GetConnectionToDb(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close(context.Background())
+
+	if err := DropDatabase(pgC, name); err != nil {
+		t.Fatal(err)
+	}
+	if got := mustQueryStrings(t, pgC, "postgres", "SELECT datname::text FROM pg_database WHERE datname = $1", name); len(got) > 0 {
+		t.Errorf("database is not dropped: %v", got)
+	}
+	if err := DropDatabase(pgC, name); err != nil {
+		t.Errorf("drop of absent database: %v", err)
+	}
+	for _, reserved := range reservedDatabases {
+		if err := DropDatabase(pgC, reserved); err == nil {
+			t.Errorf("reserved database %s is dropped", reserved)
+		}
+		if err := SyncDatabase(pgC, reserved, &v1.PostgresDatabaseSpec{}); err == nil {
+			t.Errorf("reserved database %s is managed", reserved)
+		}
+	}
+}
+
+func TestCheckImmutableSettings(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    v1.PostgresDatabaseSpec
+		wantErr []string
+	}{
+		{name: "not set", spec: v1.PostgresDatabaseSpec{}},
+		{name: "equal", spec: v1.PostgresDatabaseSpec{Encoding: "UTF-8", LcCollate: "en_US.UTF-8", LcCtype: "en_US.UTF-8"}},
+		{name: "encoding", spec: v1.PostgresDatabaseSpec{Encoding: "LATIN1"}, wantErr: []string{"encoding is UTF8"}},
+		{
+			name:    "locale",
+			spec:    v1.PostgresDatabaseSpec{LcCollate: "C", LcCtype: "C"},
+			wantErr: []string{"lcCollate is en_US.UTF-8", "lcCtype is en_US.UTF-8"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkImmutableSettings("app", &tt.spec, "UTF8", "en_US.UTF-8", "en_US.UTF-8")
+			if (err != nil) != (len(tt.wantErr) > 0) {
+				t.Fatalf("checkImmutableSettings() error = %v, want %v", err, tt.wantErr)
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q doesn't contain %q", err, want)
+				}
+			}
+		})
+	}
+	if got := normalizeEncoding("UTF-8"); got != "UTF8" {
+		t.Errorf("normalizeEncoding() = %s, want UTF8", got)
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dbobjects
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	v1 "github.com/Netcracker/pgskipper-operator/api/apps/v1"
+	pgClient "github.com/Netcracker/pgskipper-operator/pkg/client"
+)
+
+// privilege keywords can't be passed as identifiers or literals, so they are checked against these lists
+// before they are put into statements
+var schemaPrivileges = []string{"USAGE", "CREATE"}
+
+type defaultPrivilegeObject struct {
+	// keyword is used in ALTER DEFAULT PRIVILEGES, aclType is pg_default_acl.defaclobjtype
+	keyword    string
+	aclType    string
+	privileges []string
+}
+
+var defaultPrivilegeObjects = map[string]defaultPrivilegeObject{
+	"tables": {
+		keyword:    "TABLES",
+		aclType:    "r",
+		privileges: []string{"SELECT", "INSERT", "UPDATE", "DELETE", "TRUNCATE", "REFERENCES", "TRIGGER"},
+	},
+	"sequences": {keyword: "SEQUENCES", aclType: "S", privileges: []string{"USAGE", "SELECT", "UPDATE"}},
+	"functions": {keyword: "FUNCTIONS", aclType: "f", privileges: []string{"EXECUTE"}},
+	"types":     {keyword: "TYPES", aclType: "T", privileges: []string{"USAGE"}},
+}
+
+func validatePrivileges(allowed []string, privileges []string) error {
+	for _, privilege := range privileges {
+		if !slices.Contains(allowed, strings.ToUpper(privilege)) {
+			return fmt.Errorf("privilege %s is not allowed, allowed privileges are %s", privilege, strings.Join(allowed, ", "))
+		}
+	}
+	return nil
+}
+
+// syncSchemaGrant grants missing privileges on the schema and revokes privileges which are not in the spec
+func syncSchemaGrant(conn pgClient.Querier, name string, grant v1.SchemaGrant) error {
+	var schemaExists bool
+	if err := conn.QueryRow(context.Background(),
+		"SELECT EXISTS (SELECT 1 FROM pg_namespace WHERE nspname = $1)", grant.Schema).Scan(&schemaExists); err != nil {
+		return err
+	}
+	if !schemaExists {
+		return fmt.Errorf("schema %s doesn't exist", grant.Schema)
+	}
+	current, err := queryStrings(conn,
+		`SELECT a.privilege_type FROM pg_namespace n, aclexplode(n.nspacl) a
+		WHERE n.nspname = $1 AND a.grantee = (SELECT oid FROM pg_roles WHERE rolname = $2)`,
+		grant.Schema, name)
+	if err != nil {
+		return err
+	}
+	missing, extra := diffPrivileges(grant.Privileges, current)
+	if len(missing) > 0 {
+		logger.Info(fmt.Sprintf("Granting %s on schema %s to %s", strings.Join(missing, ", "), grant.Schema, name))
+		if err := pgClient.ExecFormat(conn, "GRANT "+strings.Join(missing, ", ")+" ON SCHEMA %I TO %I", grant.Schema, name); err != nil {
+			return err
+		}
+	}
+	if len(extra) > 0 {
+		logger.Info(fmt.Sprintf("Revoking %s on schema %s from %s", strings.Join(extra, ", "), grant.Schema, name))
+		if err := pgClient.ExecFormat(conn, "REVOKE "+strings.Join(extra, ", ")+" ON SCHEMA %I FROM %I", grant.Schema, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncDefaultPrivilege alters default privileges of ForRole, so the role gets exactly the privileges
+// from the spec on objects created later
+func syncDefaultPrivilege(conn pgClient.Querier, name string, defaultPrivilege v1.DefaultPrivilege) error {
+	objectType := defaultPrivilegeObjects[defaultPrivilege.ObjectType]
+	current, err := queryStrings(conn,
+		`SELECT a.privilege_type FROM pg_default_acl d, aclexplode(d.defaclacl) a
+		WHERE d.defaclrole = (SELECT oid FROM pg_roles WHERE rolname = $1)
+		AND d.defaclnamespace = coalesce((SELECT oid FROM pg_namespace WHERE nspname = $2), 0)
+		AND d.defaclobjtype = $3::"char"
+		AND a.grantee = (SELECT oid FROM pg_roles WHERE rolname = $4)`,
+		defaultPrivilege.ForRole, defaultPrivilege.Schema, objectType.aclType, name)
+	if err != nil {
+		return err
+	}
+
+	prefix := "ALTER DEFAULT PRIVILEGES FOR ROLE %I"
+	args := []string{defaultPrivilege.ForRole}
+	if defaultPrivilege.Schema != "" {
+		prefix += " IN SCHEMA %I"
+		args = append(args, defaultPrivilege.Schema)
+	}
+	missing, extra := diffPrivileges(defaultPrivilege.Privileges, current)
+	if len(missing) > 0 {
+		logger.Info(fmt.Sprintf("Granting default %s on %s of %s to %s",
+			strings.Join(missing, ", "), defaultPrivilege.ObjectType, defaultPrivilege.ForRole, name))
+		format := prefix + " GRANT " + strings.Join(missing, ", ") + " ON " + objectType.keyword + " TO %I"
+		if err := pgClient.ExecFormat(conn, format, append(args, name)...); err != nil {
+			return err
+		}
+	}
+	if len(extra) > 0 {
+		logger.Info(fmt.Sprintf("Revoking default %s on %s of %s from %s",
+			strings.Join(extra, ", "), defaultPrivilege.ObjectType, defaultPrivilege.ForRole, name))
+		format := prefix + " REVOKE " + strings.Join(extra, ", ") + " ON " + objectType.keyword + " FROM %I"
+		if err := pgClient.ExecFormat(conn, format, append(args, name)...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// diffPrivileges returns privileges which have to be granted and revoked
+func diffPrivileges(desired []string, current []string) (missing []string, extra []string) {
+	normalized := make([]string, 0, len(desired))
+	for _, privilege := range desired {
+		privilege = strings.ToUpper(privilege)
+		normalized = append(normalized, privilege)
+		if !slices.Contains(current, privilege) && !slices.Contains(missing, privilege) {
+			missing = append(missing, privilege)
+		}
+	}
+	for _, privilege := range current {
+		if !slices.Contains(normalized, privilege) && !slices.Contains(extra, privilege) {
+			extra = append(extra, privilege)
+		}
+	}
+	return missing, extra
+}
@@ -0,0 +1,281 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dbobjects
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	v1 "github.com/Netcracker/pgskipper-operator/api/apps/v1"
+	pgClient "github.com/Netcracker/pgskipper-operator/pkg/client"
+	pgx "github.com/jackc/pgx/v4"
+)
+
+// reservedRoles are created by the operator and can't be managed by PostgresRole
+var reservedRoles = []string{"postgres", "replicator", "pgbouncer", "powa", "postgres-exporter", "monitoring-user", "pgadminrole"}
+
+// Password of the role, Update is false if the password is already set and only has to be set on creation
+type Password struct {
+	Value  string
+	Update bool
+}
+
+// SyncRole creates the role or corrects its attributes, memberships and privileges
+func SyncRole(pgC *pgClient.PostgresClient, name string, spec *v1.PostgresRoleSpec, password *Password) error {
+	if err := validateRole(pgC, name, spec); err != nil {
+		return err
+	}
+	conn, err := pgC.GetConnection()
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if err := syncRoleAttributes(conn, name, spec, password); err != nil {
+		return err
+	}
+	if err := syncMembership(conn, name, spec.MemberOf); err != nil {
+		return err
+	}
+
+	for _, dbName := range grantDatabases(spec) {
+		if err := syncDatabasePrivileges(pgC, dbName, name, spec); err != nil {
+			return fmt.Errorf("can't sync privileges in database %s: %w", dbName, err)
+		}
+	}
+	return nil
+}
+
+// DropRole reassigns objects owned by the role to admin user, revokes its privileges in all databases and drops it
+func DropRole(pgC *pgClient.PostgresClient, name string) error {
+	if isReservedRole(pgC, name) {
+		return fmt.Errorf("role %s is reserved and can't be dropped", name)
+	}
+	conn, err := pgC.GetConnection()
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	exists, err := roleExists(conn, name)
+	if err != nil || !exists {
+		return err
+	}
+	databases, err := getConnectableDatabases(conn)
+	if err != nil {
+		return err
+	}
+	logger.Info(fmt.Sprintf("Dropping role %s", name))
+	for _, dbName := range databases {
+		if err := dropOwned(pgC, dbName, name); err != nil {
+			return fmt.Errorf("can't drop objects of role %s in database %s: %w", name, dbName, err)
+		}
+	}
+	return pgClient.ExecFormat(conn, "DROP ROLE IF EXISTS %I", name)
+}
+
+// GeneratePassword returns random password which can be used in connection strings without escaping
+func GeneratePassword() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func validateRole(pgC *pgClient.PostgresClient, name string, spec *v1.PostgresRoleSpec) error {
+	if isReservedRole(pgC, name) {
+		return fmt.Errorf("role %s is reserved and can't be managed by PostgresRole", name)
+	}
+	for _, grant := range spec.SchemaGrants {
+		if err := validatePrivileges(schemaPrivileges, grant.Privileges); err != nil {
+			return fmt.Errorf("schema grant on %s.%s: %w", grant.Database, grant.Schema, err)
+		}
+	}
+	for _, defaultPrivilege := range spec.DefaultPrivileges {
+		objectType, ok := defaultPrivilegeObjects[defaultPrivilege.ObjectType]
+		if !ok {
+			return fmt.Errorf("unknown object type %s of default privileges", defaultPrivilege.ObjectType)
+		}
+		if err := validatePrivileges(objectType.privileges, defaultPrivilege.Privileges); err != nil {
+			return fmt.Errorf("default privileges on %s: %w", defaultPrivilege.ObjectType, err)
+		}
+	}
+	return nil
+}
+
+func isReservedRole(pgC *pgClient.PostgresClient, name string) bool {
+	return slices.Contains(reservedRoles, name) || name == pgC.GetUser() || strings.HasPrefix(name, "pg_")
+}
+
+func syncRoleAttributes(conn pgClient.Querier, name string, spec *v1.PostgresRoleSpec, password *Password) error {
+	connectionLimit := int32(-1)
+	if spec.ConnectionLimit != nil {
+		connectionLimit = *spec.ConnectionLimit
+	}
+	// keywords and the number are not user input, so they are a part of the format
+	attributes := "NOLOGIN"
+	if spec.Login {
+		attributes = "LOGIN"
+	}
+	attributes += " CONNECTION LIMIT " + strconv.Itoa(int(connectionLimit))
+
+	var canLogin bool
+	var currentLimit int32
+	err := conn.QueryRow(context.Background(),
+		"SELECT rolcanlogin, rolconnlimit FROM pg_roles WHERE rolname = $1", name).Scan(&canLogin, &currentLimit)
+	if errors.Is(err, pgx.ErrNoRows) {
+		logger.Info(fmt.Sprintf("Creating role %s", name))
+		if password != nil {
+			return pgClient.ExecFormat(conn, "CREATE ROLE %I WITH "+attributes+" PASSWORD %L", name, password.Value)
+		}
+		return pgClient.ExecFormat(conn, "CREATE ROLE %I WITH "+attributes, name)
+	} else if err != nil {
+		return err
+	}
+
+	if canLogin != spec.Login || currentLimit != connectionLimit {
+		logger.Info(fmt.Sprintf("Changing attributes of role %s to %s", name, attributes))
+		if err := pgClient.ExecFormat(conn, "ALTER ROLE %I WITH "+attributes, name); err != nil {
+			return err
+		}
+	}
+	if password != nil && password.Update {
+		logger.Info(fmt.Sprintf("Setting password of role %s", name))
+		if err := pgClient.ExecFormat(conn, "ALTER ROLE %I WITH PASSWORD %L", name, password.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncMembership grants roles from memberOf and revokes other roles
+func syncMembership(conn pgClient.Querier, name string, memberOf []string) error {
+	current, err := queryStrings(conn,
+		`SELECT r.rolname::text FROM pg_auth_members m
+		JOIN pg_roles r ON r.oid = m.roleid
+		JOIN pg_roles u ON u.oid = m.member
+		WHERE u.rolname = $1`, name)
+	if err != nil {
+		return err
+	}
+	for _, role := range memberOf {
+		if slices.Contains(current, role) {
+			continue
+		}
+		logger.Info(fmt.Sprintf("Granting role %s to %s", role, name))
+		if err := pgClient.ExecFormat(conn, "GRANT %I TO %I", role, name); err != nil {
+			return err
+		}
+	}
+	for _, role := range current {
+		if slices.Contains(memberOf, role) {
+			continue
+		}
+		logger.Info(fmt.Sprintf("Revoking role %s from %s", role, name))
+		if err := pgClient.ExecFormat(conn, "REVOKE %I FROM %I", role, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func syncDatabasePrivileges(pgC *pgClient.PostgresClient, dbName string, name string, spec *v1.PostgresRoleSpec) error {
+	conn, err := pgC.GetConnectionToDb(dbName)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	for _, grant := range spec.SchemaGrants {
+		if grant.Database != dbName {
+			continue
+		}
+		if err := syncSchemaGrant(conn, name, grant); err != nil {
+			return err
+		}
+	}
+	for _, defaultPrivilege := range spec.DefaultPrivileges {
+		if defaultPrivilege.Database != dbName {
+			continue
+		}
+		if err := syncDefaultPrivilege(conn, name, defaultPrivilege); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func dropOwned(pgC *pgClient.PostgresClient, dbName string, name string) error {
+	conn, err := pgC.GetConnectionToDb(dbName)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	if err := pgClient.ExecFormat(conn, "REASSIGN OWNED BY %I TO %I", name, pgC.GetUser()); err != nil {
+		return err
+	}
+	return pgClient.ExecFormat(conn, "DROP OWNED BY %I", name)
+}
+
+func roleExists(conn pgClient.Querier, name string) (bool, error) {
+	var exists bool
+	err := conn.QueryRow(context.Background(), "SELECT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = $1)", name).Scan(&exists)
+	return exists, err
+}
+
+func getConnectableDatabases(conn pgClient.Querier) ([]string, error) {
+	return queryStrings(conn, "SELECT datname::text FROM pg_database WHERE datallowconn")
+}
+
+// grantDatabases returns databases mentioned in grants of the role
+func grantDatabases(spec *v1.PostgresRoleSpec) []string {
+	var databases []string
+	for _, grant := range spec.SchemaGrants {
+		if !slices.Contains(databases, grant.Database) {
+			databases = append(databases, grant.Database)
+		}
+	}
+	for _, defaultPrivilege := range spec.DefaultPrivileges {
+		if !slices.Contains(databases, defaultPrivilege.Database) {
+			databases = append(databases, defaultPrivilege.Database)
+		}
+	}
+	return databases
+}
+
+func queryStrings(conn pgClient.Querier, query string, args ...interface{}) ([]string, error) {
+	rows, err := conn.Query(context.Background(), query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var result []string
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		result = append(result, value)
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,295 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dbobjects
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	v1 "github.com/Netcracker/pgskipper-operator/api/apps/v1"
+	pgClient "github.com/Netcracker/pgskipper-operator/pkg/client"
+)
+
+func dropRoleOnCleanup(t *testing.T, pgC *pgClient.PostgresClient, name string) {
+	t.Cleanup(func() {
+		if err := DropRole(pgC, name); err != nil {
+			t.Errorf("can't drop role %s: %v", name, err)
+		}
+	})
+}
+
+func getRoleAttributes(t *testing.T, pgC *pgClient.PostgresClient, name string) string {
+	t.Helper()
+	attributes := mustQueryStrings(t, pgC, "postgres",
+		"SELECT rolcanlogin::text || ' ' || rolconnlimit::text FROM pg_roles WHERE rolname = $1", name)
+	if len(attributes) == 0 {
+		return ""
+	}
+	return attributes[0]
+}
+
+func getRolePassword(t *testing.T, pgC *pgClient.PostgresClient, name string) string {
+	t.Helper()
+	return strings.Join(mustQueryStrings(t, pgC, "postgres",
+		"SELECT coalesce(rolpassword, '') FROM pg_authid WHERE rolname = $1", name), "")
+}
+
+func getMemberships(t *testing.T, pgC *pgClient.PostgresClient, name string) []string {
+	t.Helper()
+	return mustQueryStrings(t, pgC, "postgres",
+		`SELECT r.rolname::text FROM pg_auth_members m JOIN pg_roles r ON r.oid = m.roleid
+		WHERE m.member = (SELECT oid FROM pg_roles WHERE rolname = $1) ORDER BY 1`, name)
+}
+
+func getSchemaPrivileges(t *testing.T, pgC *pgClient.PostgresClient, dbName string, schema string, name string) []string {
+	t.Helper()
+	return mustQueryStrings(t, pgC, dbName,
+		`SELECT a.privilege_type FROM pg_namespace n, aclexplode(n.nspacl) a
+		WHERE n.nspname = $1 AND a.grantee = (SELECT oid FROM pg_roles WHERE rolname = $2) ORDER BY 1`, schema, name)
+}
+
+func getDefaultPrivileges(t *testing.T, pgC *pgClient.PostgresClient, dbName string, forRole string, name string) []string {
+	t.Helper()
+	return mustQueryStrings(t, pgC, dbName,
+		`SELECT a.privilege_type FROM pg_default_acl d, aclexplode(d.defaclacl) a
+		WHERE d.defaclrole = (SELECT oid FROM pg_roles WHERE rolname = $1)
+		AND a.grantee = (SELECT oid FROM pg_roles WHERE rolname = $2) ORDER BY 1`, forRole, name)
+}
+
+func TestSyncRoleCorrectsAttributes(t *testing.T) {
+	pgC := requirePostgres(t)
+	name := "test_attributes"
+	dropRoleOnCleanup(t, pgC, name)
+	limit := int32(5)
+	spec := &v1.PostgresRoleSpec{Login: true, ConnectionLimit: &limit}
+
+	if err := SyncRole(pgC, name, spec, &Password{Value: "first"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := getRoleAttributes(t, pgC, name); got != "true 5" {
+		t.Fatalf("attributes after creation = %q, want login and limit 5", got)
+	}
+	password := getRolePassword(t, pgC, name)
+	if password == "" {
+		t.Fatal("password is not set on creation")
+	}
+
+	mustExec(t, pgC, "postgres", "ALTER ROLE test_attributes NOLOGIN CONNECTION LIMIT 1")
+	if err := SyncRole(pgC, name, spec, &Password{Value: "second"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := getRoleAttributes(t, pgC, name); got != "true 5" {
+		t.Errorf("attributes after drift correction = %q, want login and limit 5", got)
+	}
+	if getRolePassword(t, pgC, name) != password {
+		t.Error("password is changed, but the secret is not updated")
+	}
+
+	spec.Login = false
+	spec.ConnectionLimit = nil
+	if err := SyncRole(pgC, name, spec, &Password{Value: "second", Update: true}); err != nil {
+		t.Fatal(err)
+	}
+	if got := getRoleAttributes(t, pgC, name); got != "false -1" {
+		t.Errorf("attributes after spec change = %q, want nologin without limit", got)
+	}
+	if getRolePassword(t, pgC, name) == password {
+		t.Error("password is not changed after update of the secret")
+	}
+}
+
+func TestSyncRoleCorrectsMembership(t *testing.T) {
+	pgC := requirePostgres(t)
+	name := "test_member"
+	for _, role := range []string{"test_readers", "test_writers"} {
+		dropRoleOnCleanup(t, pgC, role)
+		mustExec(t, pgC, "postgres", "CREATE ROLE "+role)
+	}
+	dropRoleOnCleanup(t, pgC, name)
+	spec := &v1.PostgresRoleSpec{MemberOf: []string{"test_readers"}}
+
+	if err := SyncRole(pgC, name, spec, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := getMemberships(t, pgC, name); !reflect.DeepEqual(got, []string{"test_readers"}) {
+		t.Fatalf("memberships = %v, want [test_readers]", got)
+	}
+
+	mustExec(t, pgC, "postgres", "GRANT test_writers TO test_member")
+	mustExec(t, pgC, "postgres", "REVOKE test_readers FROM test_member")
+	if err := SyncRole(pgC, name, spec, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := getMemberships(t, pgC, name); !reflect.DeepEqual(got, []string{"test_readers"}) {
+		t.Errorf("memberships after drift correction = %v, want [test_readers]", got)
+	}
+}
+
+func TestSyncRoleCorrectsGrants(t *testing.T) {
+	pgC := requirePostgres(t)
+	name := "test_grantee"
+	dbName := "test_grants"
+	dropRoleOnCleanup(t, pgC, "test_owner")
+	dropRoleOnCleanup(t, pgC, name)
+	if err := SyncDatabase(pgC, dbName, &v1.PostgresDatabaseSpec{}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := DropDatabase(pgC, dbName); err != nil {
+			t.Errorf("can't drop database %s: %v", dbName, err)
+		}
+	})
+	mustExec(t, pgC, "postgres", "CREATE ROLE test_owner")
+	mustExec(t, pgC, dbName, "CREATE SCHEMA app")
+
+	spec := &v1.PostgresRoleSpec{
+		SchemaGrants: []v1.SchemaGrant{{Database: dbName, Schema: "app", Privileges: []string{"usage"}}},
+		DefaultPrivileges: []v1.DefaultPrivilege{{
+			Database: dbName, Schema: "app", ForRole: "test_owner", ObjectType: "tables", Privileges: []string{"select"},
+		}},
+	}
+	check := func(stage string, wantSchema []string, wantDefault []string) {
+		t.Helper()
+		if err := SyncRole(pgC, name, spec, nil); err != nil {
+			t.Fatalf("%s: %v", stage, err)
+		}
+		if got := getSchemaPrivileges(t, pgC, dbName, "app", name); !reflect.DeepEqual(got, wantSchema) {
+			t.Errorf("%s: schema privileges = %v, want %v", stage, got, wantSchema)
+		}
+		if got := getDefaultPrivileges(t, pgC, dbName, "test_owner", name); !reflect.DeepEqual(got, wantDefault) {
+			t.Errorf("%s: default privileges = %v, want %v", stage, got, wantDefault)
+		}
+	}
+
+	check("creation", []string{"USAGE"}, []string{"SELECT"})
+
+	mustExec(t, pgC, dbName, "REVOKE USAGE ON SCHEMA app FROM test_grantee")
+	mustExec(t, pgC, dbName, "GRANT CREATE ON SCHEMA app TO test_grantee")
+	mustExec(t, pgC, dbName, "ALTER DEFAULT PRIVILEGES FOR ROLE test_owner IN SCHEMA app GRANT INSERT ON TABLES TO test_grantee")
+	check("drift correction", []string{"USAGE"}, []string{"SELECT"})
+
+	spec.SchemaGrants[0].Privileges = []string{"usage", "create"}
+	spec.DefaultPrivileges[0].Privileges = []string{"update", "select"}
+	check("spec change", []string{"CREATE", "USAGE"}, []string{"SELECT", "UPDATE"})
+
+	spec.SchemaGrants[0].Schema = "missing"
+	if err := SyncRole(pgC, name, spec, nil); err == nil || !strings.Contains(err.Error(), "schema missing doesn't exist") {
+		t.Errorf("grant on missing schema: error = %v", err)
+	}
+}
+
+func TestSyncRoleRejectsInvalidSpec(t *testing.T) {
+	pgC := requirePostgres(t)
+	tests := []struct {
+		name string
+		role string
+		spec v1.PostgresRoleSpec
+	}{
+		{name: "admin user", role: "postgres"},
+		{name: "operator role", role: "pgbouncer"},
+		{name: "predefined role", role: "pg_monitor"},
+		{
+			name: "schema privilege",
+			role: "test_invalid",
+			spec: v1.PostgresRoleSpec{SchemaGrants: []v1.SchemaGrant{{Database: "postgres", Schema: "public", Privileges: []string{"SELECT"}}}},
+		},
+		{
+			name: "default privilege object",
+			role: "test_invalid",
+			spec: v1.PostgresRoleSpec{DefaultPrivileges: []v1.DefaultPrivilege{{Database: "postgres", ForRole: "postgres", ObjectType: "views"}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := SyncRole(pgC, tt.role, &tt.spec, nil); err == nil {
+				t.Error("SyncRole() error = nil, want error")
+			}
+		})
+	}
+	if got := getRoleAttributes(t, pgC, "test_invalid"); got != "" {
+		t.Errorf("role is created from invalid spec: %s", got)
+	}
+}
+
+func TestDropRole(t *testing.T) {
+	pgC := requirePostgres(t)
+	name := "test_dropped"
+	dbName := "test_drop_objects"
+	if err := SyncDatabase(pgC, dbName, &v1.PostgresDatabaseSpec{}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = DropDatabase(pgC, dbName) })
+	if err := SyncRole(pgC, name, &v1.PostgresRoleSpec{Login: true}, nil); err != nil {
+		t.Fatal(err)
+	}
+	mustExec(t, pgC, dbName, "CREATE TABLE owned_by_role (id int)")
+	mustExec(t, pgC, dbName, "ALTER TABLE owned_by_role OWNER TO test_dropped")
+	mustExec(t, pgC, dbName, "CREATE TABLE granted_to_role (id int)")
+	mustExec(t, pgC, dbName, "GRANT SELECT ON granted_to_role TO test_dropped")
+
+	if err := DropRole(pgC, name); err != nil {
+		t.Fatal(err)
+	}
+	if got := getRoleAttributes(t, pgC, name); got != "" {
+		t.Errorf("role is not dropped: %s", got)
+	}
+	owners := mustQueryStrings(t, pgC, dbName, "SELECT pg_get_userbyid(relowner)::text FROM pg_class WHERE relname = 'owned_by_role'")
+	if !reflect.DeepEqual(owners, []string{"postgres"}) {
+		t.Errorf("owner of the table of dropped role = %v, want postgres", owners)
+	}
+
+	if err := DropRole(pgC, name); err != nil {
+		t.Errorf("drop of absent role: %v", err)
+	}
+	if err := DropRole(pgC, "postgres"); err == nil {
+		t.Error("reserved role is dropped")
+	}
+}
+
+func TestDiffPrivileges(t *testing.T) {
+	tests := []struct {
+		name        string
+		desired     []string
+		current     []string
+		wantMissing []string
+		wantExtra   []string
+	}{
+		{name: "equal", desired: []string{"usage"}, current: []string{"USAGE"}},
+		{name: "missing", desired: []string{"usage", "create"}, current: []string{"USAGE"}, wantMissing: []string{"CREATE"}},
+		{name: "extra", desired: []string{"select"}, current: []string{"SELECT", "INSERT"}, wantExtra: []string{"INSERT"}},
+		{name: "both", desired: []string{"update"}, current: []string{"SELECT"}, wantMissing: []string{"UPDATE"}, wantExtra: []string{"SELECT"}},
+		{name: "revoke all", current: []string{"USAGE", "CREATE"}, wantExtra: []string{"USAGE", "CREATE"}},
+		{name: "duplicates", desired: []string{"select", "SELECT"}, wantMissing: []string{"SELECT"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			missing, extra := diffPrivileges(tt.desired, tt.current)
+			if !reflect.DeepEqual(missing, tt.wantMissing) || !reflect.DeepEqual(extra, tt.wantExtra) {
+				t.Errorf("diffPrivileges() = %v, %v, want %v, %v", missing, extra, tt.wantMissing, tt.wantExtra)
+			}
+		})
+	}
+}
+
+func TestGrantDatabases(t *testing.T) {
+	spec := &v1.PostgresRoleSpec{
+		SchemaGrants:      []v1.SchemaGrant{{Database: "orders"}, {Database: "billing"}, {Database: "orders"}},
+		DefaultPrivileges: []v1.DefaultPrivilege{{Database: "billing"}, {Database: "audit"}},
+	}
+	if got, want := grantDatabases(spec), []string{"orders", "billing", "audit"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("grantDatabases() = %v, want %v", got, want)
+	}
+}
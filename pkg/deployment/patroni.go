@@ -21,6 +21,7 @@ import (
 
 	patroniv1 "github.com/Netcracker/pgskipper-operator/api/patroni/v1"
 
+	"github.com/Netcracker/pgskipper-operator/pkg/patroni"
 	"github.com/Netcracker/pgskipper-operator/pkg/util"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -29,6 +30,13 @@ import (
 	"k8s.io/utils/ptr"
 )
 
+const (
+	ldapCAVolume = "ldap-ca"
+	ldapCAPath   = "/ldap-certs"
+
+	ldapPgHbaVolume = "ldap-pg-hba"
+)
+
 func ConfigMapForPatroni(clusterName string, patroniCM string, configMapKey string) *corev1.ConfigMap {
 	configMapName := fmt.Sprintf("%s-%s", clusterName, patroniCM)
 	return util.GetConfigMapByName(patroniCM, configMapName, configMapKey)
@@ -61,24 +69,51 @@ func GetPortsForPatroniService(clusterName string) []corev1.ServicePort {
 	return []corev1.ServicePort{
 		{Name: "pg-" + clusterName, Port: 5432},
 		{Name: clusterName + "-api", Port: 8008},
-		{Name: clusterName + "-ssh", Port: 22, TargetPort: intstr.IntOrString{IntVal: 3022}}, //TODO: remove if not required
+		{Name: clusterName + "-ssh", Port: 22, TargetPort: intstr.IntOrString{IntVal: sshPort}}, //TODO: remove if not required
 	}
 }
 
 func ExtractParamsFromCRByName(cr *patroniv1.PatroniCore, paramName string) string {
-	postgreSQLParams := cr.Spec.Patroni.PostgreSQLParams
-	paramValue := "200"
-	for _, param := range postgreSQLParams {
-		param = strings.Replace(param, "=", ":", 1)
-		splittedParam := strings.Split(param, ":")
-		splittedParam[0] = strings.TrimSpace(splittedParam[0])
-		splittedParam[1] = strings.TrimSpace(splittedParam[1])
-		if splittedParam[0] == paramName {
-			paramValue = splittedParam[1]
-			break
+	if value, ok := patroni.GetPostgreSQLParamValue(cr.Spec.Patroni, paramName); ok {
+		return value
+	}
+	return "200"
+}
+
+// getRestApiEnvs returns Patroni environment variables for REST API authentication and TLS,
+// Patroni applies them over restapi and ctl sections of the config
+func getRestApiEnvs(cr *patroniv1.PatroniCore) []corev1.EnvVar {
+	restApi := cr.Spec.Patroni.RestApi
+	var envs []corev1.EnvVar
+	if restApi.AuthSecret != "" {
+		for _, key := range []string{"username", "password"} {
+			envs = append(envs, corev1.EnvVar{
+				Name: "PATRONI_RESTAPI_" + strings.ToUpper(key),
+				ValueFrom: &corev1.EnvVarSource{
+					SecretKeyRef: &corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: restApi.AuthSecret},
+						Key:                  key,
+					},
+				},
+			})
 		}
 	}
-	return paramValue
+	if restApi.Tls && cr.Spec.Tls != nil && cr.Spec.Tls.Enabled {
+		verifyClient := restApi.VerifyClient
+		if verifyClient == "" {
+			verifyClient = "none"
+		}
+		envs = append(envs,
+			corev1.EnvVar{Name: "PATRONI_RESTAPI_CERTFILE", Value: "/certs/tls.crt"},
+			corev1.EnvVar{Name: "PATRONI_RESTAPI_KEYFILE", Value: "/certs/tls.key"},
+			corev1.EnvVar{Name: "PATRONI_RESTAPI_CAFILE", Value: "/certs/ca.crt"},
+			corev1.EnvVar{Name: "PATRONI_RESTAPI_VERIFY_CLIENT", Value: verifyClient},
+			corev1.EnvVar{Name: "PATRONI_CTL_CACERT", Value: "/certs/ca.crt"},
+			corev1.EnvVar{Name: "PATRONI_CTL_CERTFILE", Value: "/certs/tls.crt"},
+			corev1.EnvVar{Name: "PATRONI_CTL_KEYFILE", Value: "/certs/tls.key"},
+		)
+	}
+	return envs
 }
 
 func getMaxConnections(cr *patroniv1.PatroniCore) string {
@@ -309,7 +344,7 @@ func NewPatroniStatefulset(cr *patroniv1.PatroniCore, deploymentIdx int, cluster
 					DNSPolicy:                     corev1.DNSClusterFirst,
 				},
 			},
-			ServiceName:                          "backrest-headless",
+			ServiceName:                          backRestHeadlessService,
 			PodManagementPolicy:                  appsv1.OrderedReadyPodManagement,
 			UpdateStrategy:                       appsv1.StatefulSetUpdateStrategy{Type: appsv1.RollingUpdateStatefulSetStrategyType},
 			RevisionHistoryLimit:                 ptr.To[int32](10),
@@ -340,6 +375,24 @@ func NewPatroniStatefulset(cr *patroniv1.PatroniCore, deploymentIdx int, cluster
 		stSet.Spec.Template.Spec.Volumes = append(stSet.Spec.Template.Spec.Volumes, util.GetTlsSecretVolume(cr.Spec.Tls.CertificateSecretName))
 	}
 
+	if patroniSpec.RestApi != nil {
+		stSet.Spec.Template.Spec.Containers[0].Env = append(stSet.Spec.Template.Spec.Containers[0].Env, getRestApiEnvs(cr)...)
+	}
+
+	// libldap of PostgreSQL verifies certificate of LDAP server with CA bundle from LDAPTLS_CACERT
+	if cr.Spec.Ldap != nil && cr.Spec.Ldap.Enabled && cr.Spec.Ldap.CASecretName != "" {
+		logger.Info("Mount LDAP CA secret volume")
+		stSet.Spec.Template.Spec.Volumes = append(stSet.Spec.Template.Spec.Volumes, corev1.Volume{Name: ldapCAVolume, VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: cr.Spec.Ldap.CASecretName, DefaultMode: ptr.To[int32](420)}}})
+		stSet.Spec.Template.Spec.Containers[0].VolumeMounts = append(stSet.Spec.Template.Spec.Containers[0].VolumeMounts, corev1.VolumeMount{Name: ldapCAVolume, MountPath: ldapCAPath, ReadOnly: true})
+		stSet.Spec.Template.Spec.Containers[0].Env = append(stSet.Spec.Template.Spec.Containers[0].Env, corev1.EnvVar{Name: "LDAPTLS_CACERT", Value: ldapCAPath + "/ca.crt"})
+	}
+
+	// PostgreSQL 16 and newer includes LDAP entry of pg_hba from the Secret, it isn't created for older versions
+	if cr.Spec.Ldap != nil && cr.Spec.Ldap.Enabled {
+		stSet.Spec.Template.Spec.Volumes = append(stSet.Spec.Template.Spec.Volumes, corev1.Volume{Name: ldapPgHbaVolume, VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: patroni.LdapPgHbaSecretName, DefaultMode: ptr.To[int32](420), Optional: ptr.To(true)}}})
+		stSet.Spec.Template.Spec.Containers[0].VolumeMounts = append(stSet.Spec.Template.Spec.Containers[0].VolumeMounts, corev1.VolumeMount{Name: ldapPgHbaVolume, MountPath: patroni.LdapPgHbaPath, ReadOnly: true})
+	}
+
 	if patroniSpec.EnableShmVolume {
 		logger.Info("Mount tmpfs volume")
 		stSet.Spec.Template.Spec.Containers[0].VolumeMounts = append(stSet.Spec.Template.Spec.Containers[0].VolumeMounts, util.GetShmVolumeMount())
@@ -361,14 +414,15 @@ func NewPatroniStatefulset(cr *patroniv1.PatroniCore, deploymentIdx int, cluster
 	}
 
 	if cr.Spec.PgBackRest != nil {
-		stSet.Spec.Template.Spec.Containers[0].Env = append(stSet.Spec.Template.Spec.Containers[0].Env, GetPgBackrestEvs(deploymentIdx, clusterName, *cr.Spec.PgBackRest)...)
+		stSet.Spec.Template.Spec.Containers[0].Env = append(stSet.Spec.Template.Spec.Containers[0].Env, GetPgBackrestEvs(deploymentIdx, patroniSpec.Replicas, clusterName, *cr.Spec.PgBackRest)...)
 		stSet.Spec.Template.Spec.Containers = append(stSet.Spec.Template.Spec.Containers, getPgBackRestContainer(deploymentIdx, clusterName, cr.Spec))
 		stSet.Spec.Template.Spec.Volumes = append(stSet.Spec.Template.Spec.Volumes, GetPgBackRestConfVolume())
 		stSet.Spec.Template.Spec.Containers[0].VolumeMounts = append(stSet.Spec.Template.Spec.Containers[0].VolumeMounts, GetPgBackRestConfVolumeMount())
-		if strings.ToLower(cr.Spec.PgBackRest.RepoType) == "rwx" {
-			stSet.Spec.Template.Spec.Volumes = append(stSet.Spec.Template.Spec.Volumes, GetPgBackRestRWXVolume())
-			stSet.Spec.Template.Spec.Containers[0].VolumeMounts = append(stSet.Spec.Template.Spec.Containers[0].VolumeMounts, GetPgBackRestRWXVolumeMount())
-
+		for idx, repository := range GetPgBackRestRepositories(cr.Spec.PgBackRest) {
+			if repository.Type == "rwx" {
+				stSet.Spec.Template.Spec.Volumes = append(stSet.Spec.Template.Spec.Volumes, GetPgBackRestRWXVolume(idx+1))
+				stSet.Spec.Template.Spec.Containers[0].VolumeMounts = append(stSet.Spec.Template.Spec.Containers[0].VolumeMounts, GetPgBackRestRWXVolumeMount(idx+1, repository.Path))
+			}
 		}
 
 		if cr.Spec.PgBackRest.BackupFromStandby {
@@ -430,25 +484,6 @@ func GetPgBackRestConfVolume() corev1.Volume {
 	}
 }
 
-func GetPgBackRestRWXVolume() corev1.Volume {
-	return corev1.Volume{
-		Name: "pgbackrest",
-		VolumeSource: corev1.VolumeSource{
-			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
-				ClaimName: "pgbackrest-backups",
-				ReadOnly:  false,
-			},
-		},
-	}
-}
-
-func GetPgBackRestRWXVolumeMount() corev1.VolumeMount {
-	return corev1.VolumeMount{
-		MountPath: "/var/lib/pgbackrest",
-		Name:      "pgbackrest",
-	}
-}
-
 func GetPgBackRestConfVolumeMount() corev1.VolumeMount {
 	return corev1.VolumeMount{
 		MountPath: "/etc/pgbackrest",
@@ -16,16 +16,48 @@ package deployment
 
 import (
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	coreUtil "github.com/Netcracker/pgskipper-operator-core/pkg/util"
 	v1 "github.com/Netcracker/pgskipper-operator/api/patroni/v1"
 	"github.com/Netcracker/pgskipper-operator/pkg/util"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"strings"
+	"k8s.io/utils/ptr"
 )
 
 const SSHKeysSecret = "pgbackrest-keys"
 const SSHKeysPath = "/keys"
 
+const (
+	// PgBackRestCredentialsSecret is created by operator from deprecated S3 key and secret fields of CR
+	PgBackRestCredentialsSecret      = "pgbackrest-credentials"
+	PgBackRestCredentialsHash        = "checksum/pgbackrest-credentials"
+	pgBackRestCredentialsVolume      = "pgbackrest-credentials"
+	pgBackRestCredentialsPath        = "/etc/pgbackrest-credentials"
+	pgBackRestGcsKey                 = "gcs-key"
+	pgBackRestCredentialsDefaultMode = 256
+	pgBackRestRepoVolume             = "pgbackrest"
+	pgBackRestRepoPvc                = "pgbackrest-backups"
+	pgBackRestRepoPath               = "/var/lib/pgbackrest"
+	backRestHeadlessService          = "backrest-headless"
+	// sshPort is a port of sshd in Patroni container, pgBackRest connects to other members by it
+	sshPort = 3022
+)
+
+// pgBackRestCredentialEnvs maps keys of credentials Secret to pgBackRest repository options
+var pgBackRestCredentialEnvs = map[string]string{
+	"s3-key":        "S3_KEY",
+	"s3-key-secret": "S3_KEY_SECRET",
+	"s3-token":      "S3_TOKEN",
+	"azure-account": "AZURE_ACCOUNT",
+	"azure-key":     "AZURE_KEY",
+	"cipher-pass":   "CIPHER_PASS",
+}
+
 func getPgBackRestContainer(deploymentIdx int, clustername string, patroniCoreSpec *v1.PatroniCoreSpec) corev1.Container {
 	pgBackRestContainer := corev1.Container{
 		Name:            "pgbackrest-sidecar",
@@ -79,7 +111,7 @@ func getPgBackRestContainer(deploymentIdx int, clustername string, patroniCoreSp
 				Name:  "POD_IDENTITY",
 				Value: fmt.Sprintf("node%v", deploymentIdx),
 			},
-		}, GetPgBackrestEvs(deploymentIdx, clustername, *patroniCoreSpec.PgBackRest)...),
+		}, GetPgBackrestEvs(deploymentIdx, patroniCoreSpec.Patroni.Replicas, clustername, *patroniCoreSpec.PgBackRest)...),
 		Ports: []corev1.ContainerPort{
 			{ContainerPort: 3000, Name: "pgbackrest", Protocol: corev1.ProtocolTCP},
 		},
@@ -101,12 +133,10 @@ func getPgBackRestContainer(deploymentIdx int, clustername string, patroniCoreSp
 		},
 		Resources: getPgbackRestResources(patroniCoreSpec),
 	}
-	if strings.ToLower(patroniCoreSpec.PgBackRest.RepoType) == "rwx" {
-		backrestVolumeMount := corev1.VolumeMount{
-			MountPath: "/var/lib/pgbackrest",
-			Name:      "pgbackrest",
+	for repo, repository := range GetPgBackRestRepositories(patroniCoreSpec.PgBackRest) {
+		if repository.Type == "rwx" {
+			pgBackRestContainer.VolumeMounts = append(pgBackRestContainer.VolumeMounts, GetPgBackRestRWXVolumeMount(repo+1, repository.Path))
 		}
-		pgBackRestContainer.VolumeMounts = append(pgBackRestContainer.VolumeMounts, backrestVolumeMount)
 	}
 	return pgBackRestContainer
 }
@@ -168,7 +198,7 @@ func GetBackrestHeadless() *corev1.Service {
 			Kind:       "Service",
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      "backrest-headless",
+			Name:      backRestHeadlessService,
 			Namespace: util.GetNameSpace(),
 		},
 
@@ -184,31 +214,154 @@ func getPgBackRestSettings(pgBackrestSpec *v1.PgBackRest) string {
 	var listSettings []string
 	listSettings = append(listSettings, "[global]")
 
-	listSettings = append(listSettings, fmt.Sprintf("repo1-retention-full=%d", pgBackrestSpec.FullRetention))
-	listSettings = append(listSettings, fmt.Sprintf("repo1-retention-diff=%d", pgBackrestSpec.DiffRetention))
-
-	if pgBackrestSpec.RepoType == "s3" {
-		listSettings = append(listSettings, fmt.Sprintf("repo1-type=%s", pgBackrestSpec.RepoType))
-		listSettings = append(listSettings, fmt.Sprintf("repo1-path=%s", pgBackrestSpec.RepoPath))
-		listSettings = append(listSettings, fmt.Sprintf("repo1-s3-bucket=%s", pgBackrestSpec.S3.Bucket))
-		listSettings = append(listSettings, fmt.Sprintf("repo1-s3-endpoint=%s", pgBackrestSpec.S3.Endpoint))
-		listSettings = append(listSettings, fmt.Sprintf("repo1-s3-key=%s", pgBackrestSpec.S3.Key))
-		listSettings = append(listSettings, fmt.Sprintf("repo1-s3-key-secret=%s", pgBackrestSpec.S3.Secret))
-		listSettings = append(listSettings, fmt.Sprintf("repo1-s3-region=%s", pgBackrestSpec.S3.Region))
-		listSettings = append(listSettings, "repo1-s3-uri-style=path")
-		if !pgBackrestSpec.S3.VerifySsl {
-			listSettings = append(listSettings, "repo1-s3-verify-ssl=n")
+	if len(pgBackrestSpec.Repositories) == 0 {
+		listSettings = append(listSettings, fmt.Sprintf("repo1-retention-full=%d", pgBackrestSpec.FullRetention))
+		listSettings = append(listSettings, fmt.Sprintf("repo1-retention-diff=%d", pgBackrestSpec.DiffRetention))
+
+		if pgBackrestSpec.RepoType == "s3" {
+			listSettings = append(listSettings, fmt.Sprintf("repo1-type=%s", pgBackrestSpec.RepoType))
+			listSettings = append(listSettings, fmt.Sprintf("repo1-path=%s", pgBackrestSpec.RepoPath))
+			listSettings = append(listSettings, fmt.Sprintf("repo1-s3-bucket=%s", pgBackrestSpec.S3.Bucket))
+			listSettings = append(listSettings, fmt.Sprintf("repo1-s3-endpoint=%s", pgBackrestSpec.S3.Endpoint))
+			listSettings = append(listSettings, fmt.Sprintf("repo1-s3-region=%s", pgBackrestSpec.S3.Region))
+			listSettings = append(listSettings, "repo1-s3-uri-style=path")
+			if !pgBackrestSpec.S3.VerifySsl {
+				listSettings = append(listSettings, "repo1-s3-verify-ssl=n")
+			}
+		}
+		if pgBackrestSpec.RepoType == "rwx" {
+			listSettings = append(listSettings, "repo1-path=/var/lib/pgbackrest")
 		}
 	}
-	if pgBackrestSpec.RepoType == "rwx" {
-		listSettings = append(listSettings, "repo1-path=/var/lib/pgbackrest")
+	for idx, repository := range pgBackrestSpec.Repositories {
+		listSettings = append(listSettings, getPgBackRestRepoSettings(idx+1, repository)...)
 	}
 	listSettings = append(listSettings, pgBackrestSpec.ConfigParams...)
 	settings := strings.Join(listSettings[:], "\n")
 	return settings
 }
 
-func GetPgBackrestEvs(deploymentIdx int, clusterName string, pgBackRest v1.PgBackRest) []corev1.EnvVar {
+// getPgBackRestRepoSettings returns repoN-* options of the repository
+func getPgBackRestRepoSettings(repo int, repository v1.PgBackRestRepository) []string {
+	prefix := fmt.Sprintf("repo%d-", repo)
+	var listSettings []string
+	listSettings = append(listSettings, fmt.Sprintf("%stype=%s", prefix, getPgBackRestRepoType(repository.Type)))
+	listSettings = append(listSettings, fmt.Sprintf("%spath=%s", prefix, getPgBackRestRepoPath(repo, repository)))
+	listSettings = append(listSettings, fmt.Sprintf("%sretention-full=%d", prefix, repository.FullRetention))
+	listSettings = append(listSettings, fmt.Sprintf("%sretention-diff=%d", prefix, repository.DiffRetention))
+
+	switch repository.Type {
+	case "s3":
+		if repository.S3 != nil {
+			listSettings = append(listSettings, fmt.Sprintf("%ss3-bucket=%s", prefix, repository.S3.Bucket))
+			listSettings = append(listSettings, fmt.Sprintf("%ss3-endpoint=%s", prefix, repository.S3.Endpoint))
+			listSettings = append(listSettings, fmt.Sprintf("%ss3-region=%s", prefix, repository.S3.Region))
+			listSettings = append(listSettings, prefix+"s3-uri-style=path")
+			if !repository.S3.VerifySsl {
+				listSettings = append(listSettings, prefix+"s3-verify-ssl=n")
+			}
+		}
+	case "azure":
+		if repository.Azure != nil {
+			listSettings = append(listSettings, fmt.Sprintf("%sazure-container=%s", prefix, repository.Azure.Container))
+			if repository.Azure.Endpoint != "" {
+				listSettings = append(listSettings, fmt.Sprintf("%sazure-endpoint=%s", prefix, repository.Azure.Endpoint))
+			}
+			if repository.Azure.KeyType != "" {
+				listSettings = append(listSettings, fmt.Sprintf("%sazure-key-type=%s", prefix, repository.Azure.KeyType))
+			}
+		}
+	case "gcs":
+		if repository.GCS != nil {
+			listSettings = append(listSettings, fmt.Sprintf("%sgcs-bucket=%s", prefix, repository.GCS.Bucket))
+			if repository.GCS.Endpoint != "" {
+				listSettings = append(listSettings, fmt.Sprintf("%sgcs-endpoint=%s", prefix, repository.GCS.Endpoint))
+			}
+			if repository.GCS.KeyType != "" {
+				listSettings = append(listSettings, fmt.Sprintf("%sgcs-key-type=%s", prefix, repository.GCS.KeyType))
+			}
+		}
+	}
+	if repository.CipherType != "" && repository.CipherType != "none" {
+		listSettings = append(listSettings, fmt.Sprintf("%scipher-type=%s", prefix, repository.CipherType))
+	}
+	return listSettings
+}
+
+// getPgBackRestRepoType maps repository type of CR to pgBackRest repo type, rwx volume is a posix repository
+func getPgBackRestRepoType(repoType string) string {
+	if repoType == "rwx" {
+		return "posix"
+	}
+	return repoType
+}
+
+func getPgBackRestRepoPath(repo int, repository v1.PgBackRestRepository) string {
+	if repository.Path != "" {
+		return repository.Path
+	}
+	if repository.Type == "rwx" {
+		return pgBackRestRepoPath + repoSuffix(repo)
+	}
+	return "/pgbackrest"
+}
+
+// GetPgBackRestRepositories returns repositories of pgBackRest, when the list is not set
+// the repository described by deprecated top-level fields is returned as repo1
+func GetPgBackRestRepositories(pgBackrestSpec *v1.PgBackRest) []v1.PgBackRestRepository {
+	if len(pgBackrestSpec.Repositories) > 0 {
+		return pgBackrestSpec.Repositories
+	}
+	repository := v1.PgBackRestRepository{
+		Type:              strings.ToLower(pgBackrestSpec.RepoType),
+		Path:              pgBackrestSpec.RepoPath,
+		Rwx:               pgBackrestSpec.Rwx,
+		FullRetention:     pgBackrestSpec.FullRetention,
+		DiffRetention:     pgBackrestSpec.DiffRetention,
+		CredentialsSecret: GetPgBackRestCredentialsSecretName(pgBackrestSpec),
+	}
+	if repository.Type == "rwx" {
+		repository.Path = pgBackRestRepoPath
+	}
+	return []v1.PgBackRestRepository{repository}
+}
+
+// repoSuffix keeps names of the first repository resources unchanged
+func repoSuffix(repo int) string {
+	if repo == 1 {
+		return ""
+	}
+	return fmt.Sprintf("-repo%d", repo)
+}
+
+// GetPgBackRestRWXPvcName returns the name of the PVC of rwx repository
+func GetPgBackRestRWXPvcName(repo int) string {
+	return pgBackRestRepoPvc + repoSuffix(repo)
+}
+
+func GetPgBackRestRWXVolume(repo int) corev1.Volume {
+	return corev1.Volume{
+		Name: pgBackRestRepoVolume + repoSuffix(repo),
+		VolumeSource: corev1.VolumeSource{
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+				ClaimName: GetPgBackRestRWXPvcName(repo),
+				ReadOnly:  false,
+			},
+		},
+	}
+}
+
+func GetPgBackRestRWXVolumeMount(repo int, path string) corev1.VolumeMount {
+	if path == "" {
+		path = pgBackRestRepoPath + repoSuffix(repo)
+	}
+	return corev1.VolumeMount{
+		MountPath: path,
+		Name:      pgBackRestRepoVolume + repoSuffix(repo),
+	}
+}
+
+func GetPgBackrestEvs(deploymentIdx int, replicas int, clusterName string, pgBackRest v1.PgBackRest) []corev1.EnvVar {
 	resultVars := []corev1.EnvVar{
 		{
 			Name:  "PGBACKREST_PG1_PATH",
@@ -221,31 +374,127 @@ func GetPgBackrestEvs(deploymentIdx int, clusterName string, pgBackRest v1.PgBac
 	}
 
 	if pgBackRest.BackupFromStandby {
-		standbyVars := []corev1.EnvVar{
-			{
-				Name:  "PGBACKREST_PG2_PATH",
-				Value: fmt.Sprintf("/var/lib/pgsql/data/postgresql_node%v/", getOppositeIdx(deploymentIdx)),
+		resultVars = append(resultVars, getStandbyBackupEvs(deploymentIdx, replicas, clusterName)...)
+		resultVars = append(resultVars, corev1.EnvVar{Name: "PGBACKREST_BACKUP_STANDBY", Value: "prefer"})
+	}
+
+	return resultVars
+}
+
+// getStandbyBackupEvs returns pg2...pgN options for all other members of the cluster. Each member is reached by
+// its pod name in the headless service and has its own data directory, so the options stay valid whichever
+// member is the leader, pgBackRest finds the primary and the standby itself.
+func getStandbyBackupEvs(deploymentIdx int, replicas int, clusterName string) []corev1.EnvVar {
+	var envs []corev1.EnvVar
+	pgIdx := 2
+	for memberIdx := 1; memberIdx <= replicas; memberIdx++ {
+		if memberIdx == deploymentIdx {
+			continue
+		}
+		envs = append(envs,
+			corev1.EnvVar{
+				Name:  fmt.Sprintf("PGBACKREST_PG%d_HOST", pgIdx),
+				Value: fmt.Sprintf("pg-%s-node%d-0.%s", clusterName, memberIdx, backRestHeadlessService),
 			},
-			{
-				Name:  "PGBACKREST_PG2_HOST",
-				Value: fmt.Sprintf("pg-%s", clusterName),
+			corev1.EnvVar{
+				Name:  fmt.Sprintf("PGBACKREST_PG%d_HOST_PORT", pgIdx),
+				Value: strconv.Itoa(sshPort),
 			},
-			{
-				Name:  "PGBACKREST_BACKUP_STANDBY",
-				Value: "prefer",
+			corev1.EnvVar{
+				Name:  fmt.Sprintf("PGBACKREST_PG%d_PATH", pgIdx),
+				Value: fmt.Sprintf("/var/lib/pgsql/data/postgresql_node%v/", memberIdx),
 			},
-		}
+		)
+		pgIdx++
+	}
+	return envs
+}
 
-		resultVars = append(resultVars, standbyVars...)
+// GetPgBackRestCredentialsSecretName returns the name of the Secret with repository credentials,
+// empty name means that repository doesn't need credentials
+func GetPgBackRestCredentialsSecretName(pgBackrestSpec *v1.PgBackRest) string {
+	if pgBackrestSpec.CredentialsSecret != "" {
+		return pgBackrestSpec.CredentialsSecret
 	}
+	if pgBackrestSpec.S3.Key != "" || pgBackrestSpec.S3.Secret != "" {
+		return PgBackRestCredentialsSecret
+	}
+	return ""
+}
 
-	return resultVars
+// NewPgBackRestCredentialsSecret returns Secret with credentials set in deprecated S3 fields of CR
+func NewPgBackRestCredentialsSecret(pgBackrestSpec *v1.PgBackRest, labels map[string]string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      PgBackRestCredentialsSecret,
+			Namespace: util.GetNameSpace(),
+			Labels:    labels,
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			"s3-key":        []byte(pgBackrestSpec.S3.Key),
+			"s3-key-secret": []byte(pgBackrestSpec.S3.Secret),
+		},
+	}
 }
 
-func getOppositeIdx(idx int) int {
-	if idx == 1 {
-		return 2
-	} else {
-		return 1
+// AddPgBackRestCredentials exposes credentials of the repository to Patroni and pgBackRest containers
+// as environment variables, GCS key is mounted as a file. Hash of the Secret is added
+// to the pod template, so pods are rolled when the Secret is changed.
+func AddPgBackRestCredentials(stSet *appsv1.StatefulSet, repo int, secret *corev1.Secret) {
+	var keys []string
+	for key := range secret.Data {
+		if _, ok := pgBackRestCredentialEnvs[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	var envs []corev1.EnvVar
+	for _, key := range keys {
+		envs = append(envs, corev1.EnvVar{
+			Name: fmt.Sprintf("PGBACKREST_REPO%d_%s", repo, pgBackRestCredentialEnvs[key]),
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: secret.Name},
+					Key:                  key,
+				},
+			},
+		})
+	}
+	volumeName := pgBackRestCredentialsVolume + repoSuffix(repo)
+	mountPath := pgBackRestCredentialsPath + repoSuffix(repo)
+	_, hasGcsKey := secret.Data[pgBackRestGcsKey]
+	if hasGcsKey {
+		envs = append(envs, corev1.EnvVar{Name: fmt.Sprintf("PGBACKREST_REPO%d_GCS_KEY", repo), Value: mountPath + "/" + pgBackRestGcsKey})
+		stSet.Spec.Template.Spec.Volumes = append(stSet.Spec.Template.Spec.Volumes, corev1.Volume{
+			Name: volumeName,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName:  secret.Name,
+					Items:       []corev1.KeyToPath{{Key: pgBackRestGcsKey, Path: pgBackRestGcsKey}},
+					DefaultMode: ptr.To[int32](pgBackRestCredentialsDefaultMode),
+				},
+			},
+		})
+	}
+
+	containers := stSet.Spec.Template.Spec.Containers
+	for idx := range containers {
+		if idx != 0 && containers[idx].Name != "pgbackrest-sidecar" {
+			continue
+		}
+		containers[idx].Env = append(containers[idx].Env, envs...)
+		if hasGcsKey {
+			containers[idx].VolumeMounts = append(containers[idx].VolumeMounts, corev1.VolumeMount{
+				Name:      volumeName,
+				MountPath: mountPath,
+				ReadOnly:  true,
+			})
+		}
+	}
+
+	if stSet.Spec.Template.Annotations == nil {
+		stSet.Spec.Template.Annotations = map[string]string{}
 	}
+	stSet.Spec.Template.Annotations[PgBackRestCredentialsHash+repoSuffix(repo)] = coreUtil.HashJson(secret.Data)
 }
@@ -0,0 +1,139 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deployment
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	v1 "github.com/Netcracker/pgskipper-operator/api/patroni/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestGetPgBackRestSettings(t *testing.T) {
+	tests := []struct {
+		name string
+		spec *v1.PgBackRest
+	}{
+		{
+			name: "legacy-rwx",
+			spec: &v1.PgBackRest{RepoType: "rwx", RepoPath: "/var/lib/pgbackrest", FullRetention: 2, DiffRetention: 3},
+		},
+		{
+			name: "legacy-s3",
+			spec: &v1.PgBackRest{
+				RepoType:      "s3",
+				RepoPath:      "/pgbackrest",
+				FullRetention: 5,
+				S3:            v1.S3{Bucket: "pgbackrest", Endpoint: "https://minio", Region: "us-east-1"},
+				ConfigParams:  []string{"start-fast=y"},
+			},
+		},
+		{
+			name: "repositories",
+			spec: &v1.PgBackRest{
+				ConfigParams: []string{"process-max=2"},
+				Repositories: []v1.PgBackRestRepository{
+					{Type: "rwx", FullRetention: 2, DiffRetention: 7},
+					{
+						Type:          "s3",
+						FullRetention: 8,
+						CipherType:    "aes-256-cbc",
+						S3:            &v1.S3{Bucket: "offsite", Endpoint: "https://s3.eu-central-1.amazonaws.com", Region: "eu-central-1", VerifySsl: true},
+					},
+					{Type: "azure", Path: "/azure", Azure: &v1.AzureRepo{Container: "pgbackrest", KeyType: "sas"}},
+					{Type: "gcs", GCS: &v1.GCSRepo{Bucket: "pgbackrest", Endpoint: "storage.googleapis.com", KeyType: "token"}, CipherType: "none"},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := getPgBackRestSettings(tt.spec) + "\n"
+			golden := filepath.Join("testdata", tt.name+".conf.golden")
+			if *update {
+				if err := os.WriteFile(golden, []byte(settings), 0644); err != nil {
+					t.Fatalf("cannot update golden file: %v", err)
+				}
+			}
+			expected, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("cannot read golden file: %v", err)
+			}
+			if settings != string(expected) {
+				t.Errorf("settings differ from %s:\n%s\nexpected:\n%s", golden, settings, expected)
+			}
+		})
+	}
+}
+
+func TestGetPgBackrestEvsStandbyBackup(t *testing.T) {
+	for _, members := range []int{1, 2, 9, 10, 15} {
+		t.Run(fmt.Sprintf("%d members", members), func(t *testing.T) {
+			for idx := 1; idx <= members; idx++ {
+				envs := envMap(GetPgBackrestEvs(idx, members, "patroni", v1.PgBackRest{BackupFromStandby: true}))
+				if path := envs["PGBACKREST_PG1_PATH"]; path != fmt.Sprintf("/var/lib/pgsql/data/postgresql_node%d/", idx) {
+					t.Errorf("pg1 path of node%d is %s", idx, path)
+				}
+				if envs["PGBACKREST_BACKUP_STANDBY"] != "prefer" {
+					t.Errorf("backup from standby isn't enabled on node%d", idx)
+				}
+				// every other member is pgN with its own host and data directory
+				others := map[int]bool{}
+				for pgIdx := 2; pgIdx <= members; pgIdx++ {
+					var memberIdx int
+					host := envs[fmt.Sprintf("PGBACKREST_PG%d_HOST", pgIdx)]
+					if _, err := fmt.Sscanf(host, "pg-patroni-node%d-0.backrest-headless", &memberIdx); err != nil {
+						t.Fatalf("unexpected pg%d host of node%d: %s", pgIdx, idx, host)
+					}
+					if memberIdx == idx || memberIdx > members || others[memberIdx] {
+						t.Errorf("pg%d of node%d points to node%d", pgIdx, idx, memberIdx)
+					}
+					others[memberIdx] = true
+					if path := envs[fmt.Sprintf("PGBACKREST_PG%d_PATH", pgIdx)]; path != fmt.Sprintf("/var/lib/pgsql/data/postgresql_node%d/", memberIdx) {
+						t.Errorf("pg%d path of node%d is %s, host is %s", pgIdx, idx, path, host)
+					}
+					if port := envs[fmt.Sprintf("PGBACKREST_PG%d_HOST_PORT", pgIdx)]; port != "3022" {
+						t.Errorf("pg%d port of node%d is %s", pgIdx, idx, port)
+					}
+				}
+				if len(envs) != 3+3*(members-1) {
+					t.Errorf("node%d has unexpected variables: %v", idx, envs)
+				}
+			}
+		})
+	}
+}
+
+func TestGetPgBackrestEvsWithoutStandbyBackup(t *testing.T) {
+	envs := envMap(GetPgBackrestEvs(10, 15, "patroni", v1.PgBackRest{}))
+	expected := map[string]string{"PGBACKREST_PG1_PATH": "/var/lib/pgsql/data/postgresql_node10/", "PGBACKREST_STANZA": "patroni"}
+	if fmt.Sprint(envs) != fmt.Sprint(expected) {
+		t.Errorf("variables are %v, expected %v", envs, expected)
+	}
+}
+
+func envMap(envs []corev1.EnvVar) map[string]string {
+	result := map[string]string{}
+	for _, env := range envs {
+		result[env.Name] = env.Value
+	}
+	return result
+}
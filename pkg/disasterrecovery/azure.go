@@ -0,0 +1,202 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disasterrecovery
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+)
+
+const (
+	// flexible server replicas and their promotion are available in ARM API since 2024-08-01
+	azureFlexibleServerApiVersion = "2024-08-01"
+	azureFlexibleServerProvider   = "Microsoft.DBforPostgreSQL/flexibleServers"
+	azureReadyState               = "Ready"
+	azureOperationTimeout         = 60 * time.Minute
+)
+
+// azureDBClient manages Azure Flexible Servers tagged with namespace of the operator in the subscription
+type azureDBClient struct {
+	client         *arm.Client
+	subscriptionId string
+}
+
+type azureServer struct {
+	Id         string            `json:"id,omitempty"`
+	Name       string            `json:"name,omitempty"`
+	Location   string            `json:"location,omitempty"`
+	Tags       map[string]string `json:"tags,omitempty"`
+	Properties azureServerProps  `json:"properties"`
+}
+
+type azureServerProps struct {
+	State                    string              `json:"state,omitempty"`
+	FullyQualifiedDomainName string              `json:"fullyQualifiedDomainName,omitempty"`
+	ReplicationRole          string              `json:"replicationRole,omitempty"`
+	CreateMode               string              `json:"createMode,omitempty"`
+	SourceServerResourceId   string              `json:"sourceServerResourceId,omitempty"`
+	Replica                  *azureReplicaParams `json:"replica,omitempty"`
+}
+
+type azureReplicaParams struct {
+	PromoteMode   string `json:"promoteMode,omitempty"`
+	PromoteOption string `json:"promoteOption,omitempty"`
+}
+
+type azureServerList struct {
+	Value    []azureServer `json:"value"`
+	NextLink string        `json:"nextLink,omitempty"`
+}
+
+// newAzureDBClient authenticates with default Azure credential chain: environment, workload identity or managed identity
+func newAzureDBClient(subscriptionId string) (managedDBClient, error) {
+	credential, err := azidentity.NewDefaultAzureCredential(nil)
+	if err != nil {
+		return nil, err
+	}
+	client, err := arm.NewClient("pgskipper-operator/disasterrecovery", "v1.0.0", credential, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &azureDBClient{client: client, subscriptionId: subscriptionId}, nil
+}
+
+func (c *azureDBClient) listInstances(ctx context.Context) ([]managedInstance, error) {
+	var instances []managedInstance
+	url := fmt.Sprintf("%s/subscriptions/%s/providers/%s?api-version=%s",
+		c.client.Endpoint(), c.subscriptionId, azureFlexibleServerProvider, azureFlexibleServerApiVersion)
+	for url != "" {
+		resp, err := c.do(ctx, http.MethodGet, url, nil, http.StatusOK)
+		if err != nil {
+			return nil, err
+		}
+		var page azureServerList
+		if err := runtime.UnmarshalAsJSON(resp, &page); err != nil {
+			return nil, err
+		}
+		for _, server := range page.Value {
+			if server.Tags["namespace"] != namespace {
+				continue
+			}
+			instances = append(instances, managedInstance{
+				Id:       server.Id,
+				Name:     server.Name,
+				Region:   server.Location,
+				Endpoint: server.Properties.FullyQualifiedDomainName,
+				Replica:  isAzureReplica(server.Properties.ReplicationRole),
+				Ready:    server.Properties.State == azureReadyState,
+			})
+		}
+		url = page.NextLink
+	}
+	return instances, nil
+}
+
+// promoteReplica promotes replica to standalone server, planned promotion waits until replica catches up with primary
+func (c *azureDBClient) promoteReplica(ctx context.Context, replica managedInstance) error {
+	log.Info(fmt.Sprintf("Start to promote replica: %s", replica.Name))
+	start := time.Now()
+	update := azureServer{Properties: azureServerProps{
+		Replica: &azureReplicaParams{PromoteMode: "standalone", PromoteOption: "planned"},
+	}}
+	if err := c.runOperation(ctx, http.MethodPatch, replica.Id, update); err != nil {
+		return err
+	}
+	log.Info(fmt.Sprintf("PromoteReplica took %s", time.Since(start)))
+	return nil
+}
+
+// createReplica creates replica in the resource group of primary server
+func (c *azureDBClient) createReplica(ctx context.Context, primary managedInstance, region string, name string) error {
+	resourceGroup, err := getAzureResourceGroup(primary.Id)
+	if err != nil {
+		return err
+	}
+	log.Info(fmt.Sprintf("Will create replica %s of %s in region: %s", name, primary.Name, region))
+	id := fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/%s/%s", c.subscriptionId, resourceGroup, azureFlexibleServerProvider, name)
+	server := azureServer{
+		Location: region,
+		Tags:     map[string]string{"namespace": namespace},
+		Properties: azureServerProps{
+			CreateMode:             "Replica",
+			SourceServerResourceId: primary.Id,
+		},
+	}
+	return c.runOperation(ctx, http.MethodPut, id, server)
+}
+
+func (c *azureDBClient) dropInstance(ctx context.Context, instance managedInstance) error {
+	log.Info(fmt.Sprintf("Will drop instance %s", instance.Name))
+	return c.runOperation(ctx, http.MethodDelete, instance.Id, nil)
+}
+
+// runOperation sends request to the server resource and waits for completion of long-running operation
+func (c *azureDBClient) runOperation(ctx context.Context, method string, id string, body interface{}) error {
+	url := fmt.Sprintf("%s%s?api-version=%s", c.client.Endpoint(), id, azureFlexibleServerApiVersion)
+	resp, err := c.do(ctx, method, url, body, http.StatusOK, http.StatusCreated, http.StatusAccepted, http.StatusNoContent)
+	if err != nil {
+		return err
+	}
+	poller, err := runtime.NewPoller[azureServer](resp, c.client.Pipeline(), nil)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, azureOperationTimeout)
+	defer cancel()
+	_, err = poller.PollUntilDone(ctx, &runtime.PollUntilDoneOptions{Frequency: 15 * time.Second})
+	return err
+}
+
+func (c *azureDBClient) do(ctx context.Context, method string, url string, body interface{}, statusCodes ...int) (*http.Response, error) {
+	req, err := runtime.NewRequest(ctx, method, url)
+	if err != nil {
+		return nil, err
+	}
+	req.Raw().Header["Accept"] = []string{"application/json"}
+	if body != nil {
+		if err := runtime.MarshalAsJSON(req, body); err != nil {
+			return nil, err
+		}
+	}
+	resp, err := c.client.Pipeline().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if !runtime.HasStatusCode(resp, statusCodes...) {
+		return nil, runtime.NewResponseError(resp)
+	}
+	return resp, nil
+}
+
+func isAzureReplica(role string) bool {
+	return role == "AsyncReplica" || role == "GeoAsyncReplica"
+}
+
+func getAzureResourceGroup(id string) (string, error) {
+	parts := strings.Split(id, "/")
+	for idx := 0; idx < len(parts)-1; idx++ {
+		if strings.EqualFold(parts[idx], "resourceGroups") {
+			return parts[idx+1], nil
+		}
+	}
+	return "", fmt.Errorf("resource group is not found in id %s", id)
+}
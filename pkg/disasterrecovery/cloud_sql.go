@@ -16,10 +16,8 @@ package disasterrecovery
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
-	"os"
 	"strconv"
 	"time"
 
@@ -98,7 +96,7 @@ func (manager *CloudSQLDRManager) setStatus() error {
 		return err
 	}
 
-	if err := manager.setStatusForPreConfigure(mode, "done"); err != nil {
+	if err := setPreConfigureStatus(PreConfigureStatus{Mode: mode, Status: "done"}); err != nil {
 		return err
 	}
 
@@ -126,7 +124,7 @@ func (manager *CloudSQLDRManager) processSiteManagerRequest(response http.Respon
 		}
 		if err != nil {
 			log.Error("Failed to parse sm status from request", zap.Error(err))
-			http.Error(response, err.Error(), http.StatusInternalServerError)
+			http.Error(response, err.Error(), http.StatusBadRequest)
 			return
 		}
 		if err := manager.helper.UpdateSiteManagerStatus(statusRequest.Mode, "running"); err != nil {
@@ -565,109 +563,38 @@ func (sqlClient *CloudSqlClient) dropInstance(instance string) error {
 }
 
 func (manager *CloudSQLDRManager) processPreConfigureRequest(response http.ResponseWriter, req *http.Request) {
-	switch req.Method {
-	case "GET":
-		preConfigureStatus, err := manager.getPreConfigureStatus()
-		if err != nil {
-			log.Error("there is an error during pre-configure status read ", zap.Error(err))
-			sendResponse(response, http.StatusInternalServerError, preConfigureStatus)
-		}
-		sendResponse(response, http.StatusOK, preConfigureStatus)
-	case "POST":
-		statusRequest, err := parseSiteManagerStatusFromRequest(req)
-		if err != nil {
-			log.Error("Failed to parse sm status from request", zap.Error(err))
-			http.Error(response, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		preConfigureStatus, err := manager.getPreConfigureStatus()
+	processPreConfigure(response, req, manager.preConfigure)
+}
+
+func (manager *CloudSQLDRManager) preConfigure(request v1.SiteManagerStatus) (PreConfigureStatus, error) {
+	if request.Mode == "active" {
+		log.Info("Skipping Pre Configuration for standby -> active change")
+		time.Sleep(30 * time.Second)
+	} else if request.Mode == "standby" {
+		if !request.NoWait {
+			log.Info("No-Wait flag has been passed as false, doing replication check")
+			_ = manager.waitTillStandbyIsSynced()
+			// if err := manager.waitTillStandbyIsSynced(); err != nil {
+			// 	//return err
+			// }
+		}
+		cloudSQlClient := manager.sqlClient
+		dbInstance, err := cloudSQlClient.getPrimaryNotInCurrentRegion()
 		if err != nil {
-			log.Error("there is an error during pre-configure status read ", zap.Error(err))
-			sendResponse(response, http.StatusInternalServerError, preConfigureStatus)
-		}
-		if preConfigureStatus.Status == "running" {
-			log.Info("Received request during running procedure, return current state")
-			sendResponse(response, http.StatusOK, preConfigureStatus)
-		} else if statusRequest.Mode == preConfigureStatus.Mode &&
-			preConfigureStatus.Status == "done" {
-			log.Info("Desired status equals to current status, return current state")
-			sendResponse(response, http.StatusOK, preConfigureStatus)
-			return
-		}
-		log.Info(fmt.Sprintf("processPreConfigureRequest invoked with mode: %s", statusRequest.Mode))
-		if err := manager.setStatusForPreConfigure(statusRequest.Mode, "running"); err != nil {
-			log.Error("Failed to set sm status", zap.Error(err))
-			http.Error(response, err.Error(), http.StatusInternalServerError)
+			return PreConfigureStatus{}, err
 		}
-		preConfigureStatus, err = manager.getPreConfigureStatus()
-		if err != nil {
-			log.Error("there is an error during pre-configure status read ", zap.Error(err))
-			sendResponse(response, http.StatusInternalServerError, preConfigureStatus)
+		log.Info(fmt.Sprintf("Replica found: %s", dbInstance.Name))
+		// terminate connections
+		if err := manager.helper.TerminateActiveConnectionsForHost(CloudSqlProxyHost); err != nil {
+			log.Error("Failed to terminate active connections", zap.Error(err))
+			return PreConfigureStatus{}, err
 		}
-		sendResponse(response, http.StatusOK, preConfigureStatus)
 
-		// async process of request
-		go manager.doPreConfigure(statusRequest)
-	}
-}
-
-func (manager *CloudSQLDRManager) doPreConfigure(request v1.SiteManagerStatus) {
-	if err := func(mode string, noWait bool) error {
-		if mode == "active" {
-			log.Info("Skipping Pre Configuration for standby -> active change")
-			time.Sleep(30 * time.Second)
-		} else if mode == "standby" {
-			if !noWait {
-				log.Info("No-Wait flag has been passed as false, doing replication check")
-				_ = manager.waitTillStandbyIsSynced()
-				// if err := manager.waitTillStandbyIsSynced(); err != nil {
-				// 	//return err
-				// }
-			}
-			cloudSQlClient := manager.sqlClient
-			dbInstance, err := cloudSQlClient.getPrimaryNotInCurrentRegion()
-			log.Info(fmt.Sprintf("Replica found: %s", dbInstance.Name))
-			if err != nil {
-				return err
-			}
-			// terminate connections
-			if err := manager.helper.TerminateActiveConnectionsForHost(CloudSqlProxyHost); err != nil {
-				log.Error("Failed to terminate active connections", zap.Error(err))
-				return err
-			}
-
-			// reconfigure cloudsql proxy with standby
-			if err := manager.reconfigureCloudSqlProxy(dbInstance.ConnectionName); err != nil {
-				log.Error("Failed to reconfigure CloudSQL proxy", zap.Error(err))
-				return err
-			}
+		// reconfigure cloudsql proxy with standby
+		if err := manager.reconfigureCloudSqlProxy(dbInstance.ConnectionName); err != nil {
+			log.Error("Failed to reconfigure CloudSQL proxy", zap.Error(err))
+			return PreConfigureStatus{}, err
 		}
-		return nil
-	}(request.Mode, request.NoWait); err != nil {
-		log.Error("There is an error, during pre-configure call", zap.Error(err))
-		_ = manager.setStatusForPreConfigure(request.Mode, "failed")
-	} else {
-		_ = manager.setStatusForPreConfigure(request.Mode, "done")
-	}
-}
-
-func (manager *CloudSQLDRManager) setStatusForPreConfigure(mode string, status string) error {
-	currentStatus := v1.SiteManagerStatus{Mode: mode, Status: status}
-	statusAsJson, _ := json.Marshal(currentStatus)
-	if err := os.WriteFile("/tmp/.pre-configure-status.json", statusAsJson, 0644); err != nil {
-		return err
-	}
-	return nil
-}
-
-func (manager *CloudSQLDRManager) getPreConfigureStatus() (v1.SiteManagerStatus, error) {
-	status := v1.SiteManagerStatus{}
-	file, err := os.ReadFile("/tmp/.pre-configure-status.json")
-	if err != nil {
-		return status, err
-	}
-	if err := json.Unmarshal(file, &status); err != nil {
-		return status, err
 	}
-	return status, nil
+	return PreConfigureStatus{}, nil
 }
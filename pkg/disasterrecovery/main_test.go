@@ -0,0 +1,54 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disasterrecovery
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	pgClient "github.com/Netcracker/pgskipper-operator/pkg/client"
+	"github.com/Netcracker/pgskipper-operator/pkg/util/testenv"
+)
+
+// testPgC is a client of throwaway PostgreSQL started for tests of the package, it's nil if PostgreSQL is not available
+var testPgC *pgClient.PostgresClient
+
+func TestMain(m *testing.M) {
+	os.Exit(runTests(m))
+}
+
+func runTests(m *testing.M) int {
+	pg, err := testenv.StartPostgres()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Tests with PostgreSQL are skipped: %v\n", err)
+		return m.Run()
+	}
+	defer pg.Stop()
+	if testPgC = pgClient.GetPostgresClientForHostAndPort(pg.Host, pg.Port); testPgC == nil {
+		fmt.Fprintf(os.Stderr, "Can't connect to PostgreSQL on %s:%d\n", pg.Host, pg.Port)
+		return 1
+	}
+	defer testPgC.Close()
+	return m.Run()
+}
+
+func requirePostgres(t *testing.T) *pgClient.PostgresClient {
+	t.Helper()
+	if testPgC == nil {
+		t.Skip("PostgreSQL is not available")
+	}
+	return testPgC
+}
@@ -0,0 +1,301 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disasterrecovery
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "github.com/Netcracker/pgskipper-operator/api/apps/v1"
+	"github.com/Netcracker/pgskipper-operator/pkg/helper"
+	"github.com/avast/retry-go/v4"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// managedInstance is a managed PostgreSQL instance labeled with the namespace of the operator
+type managedInstance struct {
+	// Id is ARN of RDS instance or resource id of Azure server, it's used as replication source
+	Id       string
+	Name     string
+	Region   string
+	Endpoint string
+	Replica  bool
+	Ready    bool
+}
+
+// managedDBClient is a set of cloud API calls required for DR of managed PostgreSQL,
+// all calls wait for completion of cloud operation
+type managedDBClient interface {
+	// listInstances returns instances in all regions labeled with the namespace of the operator
+	listInstances(ctx context.Context) ([]managedInstance, error)
+	promoteReplica(ctx context.Context, replica managedInstance) error
+	createReplica(ctx context.Context, primary managedInstance, region string, name string) error
+	dropInstance(ctx context.Context, instance managedInstance) error
+}
+
+// serviceManager creates or updates services of the site, it's implemented by helper.Helper
+type serviceManager interface {
+	CreateOrUpdateService(service *corev1.Service) error
+}
+
+// ManagedDRManager switches DR modes of managed PostgreSQL with a replica in the other region, such as AWS RDS and
+// Azure Flexible Server. Active site has primary instance in its region, standby site has read replica of it.
+type ManagedDRManager struct {
+	helper      *helper.Helper
+	services    serviceManager
+	client      managedDBClient
+	region      string
+	clusterName string
+}
+
+func newManagedDRManager(helper *helper.Helper, client managedDBClient, region string, clusterName string) GenericPostgreSQLDRManager {
+	return &ManagedDRManager{
+		helper:      helper,
+		services:    helper,
+		client:      client,
+		region:      region,
+		clusterName: clusterName,
+	}
+}
+
+func (m *ManagedDRManager) setStatus() error {
+	mode, err := m.getCurrentMode(context.Background())
+	if err != nil {
+		log.Error("there is an error during get instance, skipping status set", zap.Error(err))
+		return err
+	}
+	if mode == "" {
+		log.Info(fmt.Sprintf("No instance found in region: %s, skipping status set", m.region))
+		return nil
+	}
+	if err := m.helper.UpdateSiteManagerStatus(mode, "done"); err != nil {
+		return err
+	}
+	return setPreConfigureStatus(PreConfigureStatus{Mode: mode, Status: "done"})
+}
+
+// getCurrentMode returns active if there is primary instance in current region and standby if there is replica
+func (m *ManagedDRManager) getCurrentMode(ctx context.Context) (string, error) {
+	instances, err := m.client.listInstances(ctx)
+	if err != nil {
+		return "", err
+	}
+	if primary := m.findInstance(instances, true, false); primary != nil {
+		log.Info(fmt.Sprintf("Active Instance: %s in region: %s found, setting Active Mode", primary.Name, m.region))
+		return "active", nil
+	}
+	if replica := m.findInstance(instances, true, true); replica != nil {
+		log.Info(fmt.Sprintf("Standby Instance: %s in region: %s found, setting Standby Mode", replica.Name, m.region))
+		return "standby", nil
+	}
+	return "", nil
+}
+
+func (m *ManagedDRManager) processSiteManagerRequest(response http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case "GET":
+		sendResponse(response, http.StatusOK, m.helper.GetCurrentSiteManagerStatus())
+	case "POST":
+		statusRequest, err := parseSiteManagerStatusFromRequest(req)
+		if err != nil {
+			log.Error("Failed to parse sm status from request", zap.Error(err))
+			http.Error(response, err.Error(), http.StatusBadRequest)
+			return
+		}
+		currentStatus := m.helper.GetCurrentSiteManagerStatus()
+		if currentStatus.Status == "running" {
+			log.Info("Received request during running procedure, return current state")
+			sendResponse(response, http.StatusOK, currentStatus)
+			return
+		} else if statusRequest.Mode == currentStatus.Mode && currentStatus.Status == "done" {
+			log.Info("Desired status equals to current status, return current state")
+			sendResponse(response, http.StatusOK, currentStatus)
+			return
+		}
+		if err := m.helper.UpdateSiteManagerStatus(statusRequest.Mode, "running"); err != nil {
+			log.Error("Failed to set sm status", zap.Error(err))
+			http.Error(response, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		sendResponse(response, http.StatusOK, m.helper.GetCurrentSiteManagerStatus())
+
+		// async process of request
+		go m.processRequest(statusRequest)
+	default:
+		_, _ = fmt.Fprintf(response, "Only GET and POST methods are supported.")
+	}
+}
+
+func (m *ManagedDRManager) processRequest(request v1.SiteManagerStatus) {
+	status := "done"
+	if err := retry.Do(func() error {
+		return m.changeMode(context.Background(), request.Mode)
+	}); err != nil {
+		log.Error("Failed to change mode", zap.Error(err))
+		status = "failed"
+	}
+	if err := m.helper.UpdateSiteManagerStatus(request.Mode, status); err != nil {
+		log.Error("Failed to update site manager status", zap.Error(err))
+	}
+}
+
+func (m *ManagedDRManager) processHealthRequest(response http.ResponseWriter, req *http.Request) {
+	instances, err := m.client.listInstances(req.Context())
+	if err != nil {
+		log.Error("Failed to get instances", zap.Error(err))
+		sendDownHealthResponse(response)
+		return
+	}
+	if instance := m.findLocalInstance(instances); instance != nil && instance.Ready {
+		sendUpHealthResponse(response)
+		return
+	}
+	sendDownHealthResponse(response)
+}
+
+func (m *ManagedDRManager) processPreConfigureRequest(response http.ResponseWriter, req *http.Request) {
+	processPreConfigure(response, req, m.preConfigure)
+}
+
+// preConfigure checks that the opposite site has primary instance to replicate from before switch to standby
+func (m *ManagedDRManager) preConfigure(request v1.SiteManagerStatus) (PreConfigureStatus, error) {
+	if request.Mode != "standby" {
+		log.Info(fmt.Sprintf("Skipping Pre Configuration for %s mode", request.Mode))
+		return PreConfigureStatus{}, nil
+	}
+	instances, err := m.client.listInstances(context.Background())
+	if err != nil {
+		return PreConfigureStatus{}, err
+	}
+	primary := m.findInstance(instances, false, false)
+	if primary == nil {
+		return PreConfigureStatus{}, fmt.Errorf("primary instance is not found outside of region %s", m.region)
+	}
+	if !primary.Ready {
+		return PreConfigureStatus{}, fmt.Errorf("primary instance %s in region %s is not ready", primary.Name, primary.Region)
+	}
+	return PreConfigureStatus{Message: fmt.Sprintf("primary instance %s in region %s is ready", primary.Name, primary.Region)}, nil
+}
+
+func (m *ManagedDRManager) changeMode(ctx context.Context, mode string) error {
+	log.Info(fmt.Sprintf("Received change to  %s, processing ...", mode))
+	instances, err := m.client.listInstances(ctx)
+	if err != nil {
+		return err
+	}
+	switch mode {
+	case "standby":
+		primary := m.findInstance(instances, false, false)
+		if primary == nil {
+			return fmt.Errorf("primary instance is not found outside of region %s", m.region)
+		}
+		if replica := m.findInstance(instances, true, true); replica == nil {
+			name := fmt.Sprintf("%s-%s-%s", namespace, strings.ReplaceAll(strings.ToLower(m.region), " ", ""),
+				strconv.Itoa(int(time.Now().Unix())))
+			log.Info(fmt.Sprintf("Read replica does not exists in current region, creating %s ...", name))
+			if err := m.client.createReplica(ctx, *primary, m.region, name); err != nil {
+				return err
+			}
+		} else {
+			log.Info(fmt.Sprintf("Read Replica in current region exists: %s", replica.Name))
+		}
+		if localPrimary := m.findInstance(instances, true, false); localPrimary != nil {
+			log.Info(fmt.Sprintf("Primary instance %s in region %s found, dropping ...", localPrimary.Name, m.region))
+			if err := m.client.dropInstance(ctx, *localPrimary); err != nil {
+				return err
+			}
+		}
+		if instances, err = m.client.listInstances(ctx); err != nil {
+			return err
+		}
+		replica := m.findInstance(instances, true, true)
+		if replica == nil {
+			return fmt.Errorf("read replica is not found in region %s after creation", m.region)
+		}
+		return m.updateServices(replica.Endpoint, primary.Endpoint)
+	case "active":
+		instance := m.findInstance(instances, true, true)
+		if instance != nil {
+			log.Info(fmt.Sprintf("Replica found: %s, promoting ...", instance.Name))
+			if err := m.client.promoteReplica(ctx, *instance); err != nil {
+				return err
+			}
+		} else {
+			log.Info("There is no read replica in current region, trying to find primary ...")
+			if instance = m.findInstance(instances, true, false); instance == nil {
+				return fmt.Errorf("neither primary nor replica instance is found in region %s", m.region)
+			}
+		}
+		return m.updateServices(instance.Endpoint, instance.Endpoint)
+	case "disabled":
+		log.Info("Managed instances are not changed in disabled mode")
+		return nil
+	default:
+		return fmt.Errorf("mode %s not supported", mode)
+	}
+}
+
+// updateServices points pg-patroni service to the instance of the current site
+// and pg-<cluster>-external service to the primary instance
+func (m *ManagedDRManager) updateServices(localEndpoint string, primaryEndpoint string) error {
+	services := map[string]string{
+		"pg-patroni": localEndpoint,
+		fmt.Sprintf("pg-%s-external", m.clusterName): primaryEndpoint,
+	}
+	for name, endpoint := range services {
+		log.Info(fmt.Sprintf("Site Manager: Update %s service with %s", name, endpoint))
+		service := &corev1.Service{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Service"},
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeExternalName, ExternalName: endpoint},
+		}
+		if err := m.services.CreateOrUpdateService(service); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *ManagedDRManager) findLocalInstance(instances []managedInstance) *managedInstance {
+	if instance := m.findInstance(instances, true, false); instance != nil {
+		return instance
+	}
+	return m.findInstance(instances, true, true)
+}
+
+// findInstance returns primary or replica instance in current region or outside of it
+func (m *ManagedDRManager) findInstance(instances []managedInstance, local bool, replica bool) *managedInstance {
+	for idx := range instances {
+		instance := &instances[idx]
+		if isSameRegion(instance.Region, m.region) == local && instance.Replica == replica {
+			return instance
+		}
+	}
+	return nil
+}
+
+// isSameRegion compares regions ignoring case and spaces, Azure returns both "westeurope" and "West Europe"
+func isSameRegion(first string, second string) bool {
+	normalize := func(region string) string {
+		return strings.ReplaceAll(strings.ToLower(region), " ", "")
+	}
+	return normalize(first) == normalize(second)
+}
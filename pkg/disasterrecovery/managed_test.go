@@ -0,0 +1,246 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disasterrecovery
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// fakeManagedCloud keeps managed instances in memory and records calls of managedDBClient and serviceManager
+// into the same journal, so the order of cloud operations and service updates can be checked
+type fakeManagedCloud struct {
+	instances []managedInstance
+	calls     []string
+	services  map[string]string
+	failOn    string
+}
+
+func (f *fakeManagedCloud) record(call string) error {
+	f.calls = append(f.calls, call)
+	if f.failOn != "" && f.failOn == call {
+		return errors.New("cloud operation failed")
+	}
+	return nil
+}
+
+func (f *fakeManagedCloud) listInstances(_ context.Context) ([]managedInstance, error) {
+	return append([]managedInstance(nil), f.instances...), nil
+}
+
+func (f *fakeManagedCloud) promoteReplica(_ context.Context, replica managedInstance) error {
+	if err := f.record("promote " + replica.Name); err != nil {
+		return err
+	}
+	for idx := range f.instances {
+		if f.instances[idx].Name == replica.Name {
+			f.instances[idx].Replica = false
+		}
+	}
+	return nil
+}
+
+func (f *fakeManagedCloud) createReplica(_ context.Context, primary managedInstance, region string, name string) error {
+	if err := f.record("create replica of " + primary.Name); err != nil {
+		return err
+	}
+	f.instances = append(f.instances, managedInstance{
+		Id: name, Name: name, Region: region, Endpoint: name + ".endpoint", Replica: true, Ready: true,
+	})
+	return nil
+}
+
+func (f *fakeManagedCloud) dropInstance(_ context.Context, instance managedInstance) error {
+	if err := f.record("drop " + instance.Name); err != nil {
+		return err
+	}
+	for idx := range f.instances {
+		if f.instances[idx].Name == instance.Name {
+			f.instances = append(f.instances[:idx], f.instances[idx+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (f *fakeManagedCloud) CreateOrUpdateService(service *corev1.Service) error {
+	if err := f.record("service " + service.Name); err != nil {
+		return err
+	}
+	if f.services == nil {
+		f.services = map[string]string{}
+	}
+	f.services[service.Name] = service.Spec.ExternalName
+	return nil
+}
+
+func newFakeManagedDRManager(cloud *fakeManagedCloud) *ManagedDRManager {
+	return &ManagedDRManager{services: cloud, client: cloud, region: "eu-west-1", clusterName: "patroni"}
+}
+
+// normalizeCalls sorts trailing service updates, they are done in map order
+func normalizeCalls(calls []string) []string {
+	result := append([]string(nil), calls...)
+	idx := len(result)
+	for idx > 0 && strings.HasPrefix(result[idx-1], "service ") {
+		idx--
+	}
+	sort.Strings(result[idx:])
+	return result
+}
+
+func TestManagedChangeModeToStandby(t *testing.T) {
+	remotePrimary := managedInstance{Name: "remote", Region: "us-east-1", Endpoint: "remote.endpoint", Ready: true}
+	localPrimary := managedInstance{Name: "local", Region: "eu-west-1", Endpoint: "local.endpoint", Ready: true}
+	localReplica := managedInstance{Name: "replica", Region: "EU-West-1", Endpoint: "replica.endpoint", Replica: true, Ready: true}
+
+	tests := []struct {
+		name      string
+		instances []managedInstance
+		failOn    string
+		wantCalls []string
+		wantErr   bool
+	}{
+		{
+			name:      "replica is created before local primary is dropped and services are repointed",
+			instances: []managedInstance{remotePrimary, localPrimary},
+			wantCalls: []string{
+				"create replica of remote",
+				"drop local",
+				"service pg-patroni",
+				"service pg-patroni-external",
+			},
+		},
+		{
+			name:      "existing replica is reused",
+			instances: []managedInstance{remotePrimary, localPrimary, localReplica},
+			wantCalls: []string{
+				"drop local",
+				"service pg-patroni",
+				"service pg-patroni-external",
+			},
+		},
+		{
+			name:      "local primary is kept when replica creation fails",
+			instances: []managedInstance{remotePrimary, localPrimary},
+			failOn:    "create replica of remote",
+			wantCalls: []string{"create replica of remote"},
+			wantErr:   true,
+		},
+		{
+			name:      "services are not repointed when local primary can't be dropped",
+			instances: []managedInstance{remotePrimary, localPrimary},
+			failOn:    "drop local",
+			wantCalls: []string{"create replica of remote", "drop local"},
+			wantErr:   true,
+		},
+		{
+			name:      "nothing is changed without primary on the other site",
+			instances: []managedInstance{localPrimary},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cloud := &fakeManagedCloud{instances: append([]managedInstance(nil), tt.instances...), failOn: tt.failOn}
+			err := newFakeManagedDRManager(cloud).changeMode(context.Background(), "standby")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("changeMode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := normalizeCalls(cloud.calls); !reflect.DeepEqual(got, tt.wantCalls) {
+				t.Fatalf("calls = %q, want %q", got, tt.wantCalls)
+			}
+			if tt.wantErr {
+				return
+			}
+			if cloud.services["pg-patroni-external"] != remotePrimary.Endpoint {
+				t.Errorf("pg-patroni-external points to %q, want %q", cloud.services["pg-patroni-external"], remotePrimary.Endpoint)
+			}
+			replica := newFakeManagedDRManager(cloud).findInstance(cloud.instances, true, true)
+			if replica == nil || cloud.services["pg-patroni"] != replica.Endpoint {
+				t.Errorf("pg-patroni points to %q, want local replica %v", cloud.services["pg-patroni"], replica)
+			}
+			if primary := newFakeManagedDRManager(cloud).findInstance(cloud.instances, true, false); primary != nil {
+				t.Errorf("local primary %s is not dropped", primary.Name)
+			}
+		})
+	}
+}
+
+func TestManagedChangeModeToActive(t *testing.T) {
+	localReplica := managedInstance{Name: "replica", Region: "eu-west-1", Endpoint: "replica.endpoint", Replica: true, Ready: true}
+	localPrimary := managedInstance{Name: "local", Region: "eu-west-1", Endpoint: "local.endpoint", Ready: true}
+
+	tests := []struct {
+		name         string
+		instances    []managedInstance
+		wantCalls    []string
+		wantEndpoint string
+		wantErr      bool
+	}{
+		{
+			name:         "replica is promoted before services are repointed",
+			instances:    []managedInstance{localReplica},
+			wantCalls:    []string{"promote replica", "service pg-patroni", "service pg-patroni-external"},
+			wantEndpoint: localReplica.Endpoint,
+		},
+		{
+			name:         "existing primary is used as is",
+			instances:    []managedInstance{localPrimary},
+			wantCalls:    []string{"service pg-patroni", "service pg-patroni-external"},
+			wantEndpoint: localPrimary.Endpoint,
+		},
+		{
+			name:    "no local instance",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cloud := &fakeManagedCloud{instances: append([]managedInstance(nil), tt.instances...)}
+			err := newFakeManagedDRManager(cloud).changeMode(context.Background(), "active")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("changeMode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := normalizeCalls(cloud.calls); !reflect.DeepEqual(got, tt.wantCalls) {
+				t.Fatalf("calls = %q, want %q", got, tt.wantCalls)
+			}
+			for _, name := range []string{"pg-patroni", "pg-patroni-external"} {
+				if !tt.wantErr && cloud.services[name] != tt.wantEndpoint {
+					t.Errorf("%s points to %q, want %q", name, cloud.services[name], tt.wantEndpoint)
+				}
+			}
+		})
+	}
+}
+
+func TestManagedChangeModeDisabled(t *testing.T) {
+	cloud := &fakeManagedCloud{instances: []managedInstance{{Name: "local", Region: "eu-west-1"}}}
+	if err := newFakeManagedDRManager(cloud).changeMode(context.Background(), "disabled"); err != nil {
+		t.Fatal(err)
+	}
+	if len(cloud.calls) != 0 {
+		t.Errorf("disabled mode must not change instances, got calls %q", cloud.calls)
+	}
+	if err := newFakeManagedDRManager(cloud).changeMode(context.Background(), "unknown"); err == nil {
+		t.Error("unknown mode must be rejected")
+	}
+}
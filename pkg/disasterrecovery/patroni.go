@@ -17,12 +17,16 @@ package disasterrecovery
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
+	"strconv"
 	"time"
 
 	qubershipv1 "github.com/Netcracker/pgskipper-operator/api/apps/v1"
 	patroniv1 "github.com/Netcracker/pgskipper-operator/api/patroni/v1"
+	pgClient "github.com/Netcracker/pgskipper-operator/pkg/client"
 	"github.com/Netcracker/pgskipper-operator/pkg/helper"
+	"github.com/Netcracker/pgskipper-operator/pkg/patroni"
 	"github.com/Netcracker/pgskipper-operator/pkg/upgrade"
 	opUtil "github.com/Netcracker/pgskipper-operator/pkg/util"
 	"go.uber.org/zap"
@@ -30,17 +34,50 @@ import (
 	"k8s.io/apimachinery/pkg/util/wait"
 )
 
+// reconcileWaiter waits until the operator reconciles the custom resource changed by DR mode switch,
+// it's implemented by helper.Helper for PatroniServices and by helper.PatroniHelper for PatroniCore
+type reconcileWaiter interface {
+	WaitUntilReconcileIsDone() error
+}
+
+// patroniCluster is a set of Patroni REST API and PostgreSQL calls required to switch DR mode,
+// it's implemented by helper.PatroniHelper
+type patroniCluster interface {
+	reconcileWaiter
+	IsHealthy(patroniUrl string, pgHost string) bool
+	IsHealthyWithTimeout(timeout time.Duration, patroniUrl string, pgHost string) (bool, error)
+	TerminateActiveConnections(pgHost string) error
+	AddStandbyClusterConfigurationConfigMap(patroniUrl string) error
+	ClearStandbyClusterConfigurationConfigMap(patroniUrl string) error
+}
+
+// dialFunc opens connection to the cluster to replicate from, it's net.DialTimeout outside of tests
+type dialFunc func(network string, address string, timeout time.Duration) (net.Conn, error)
+
+// replicationSlotChecker checks that the cluster to replicate from can serve the replication slot of standby cluster
+type replicationSlotChecker func(host string, port int, slotName string) error
+
 type PatroniDRManager struct {
-	helper        *helper.Helper
-	patroniHelper *helper.PatroniHelper
-	cluster       *patroniv1.PatroniClusterSettings
+	helper          *helper.Helper
+	patroniHelper   *helper.PatroniHelper
+	services        reconcileWaiter
+	patroni         patroniCluster
+	cluster         *patroniv1.PatroniClusterSettings
+	standbySettings *standbySettingsStore
+	dial            dialFunc
+	checkSlot       replicationSlotChecker
 }
 
 func newPatroniDRManager(helper *helper.Helper, patroniHelper *helper.PatroniHelper, cluster *patroniv1.PatroniClusterSettings) GenericPostgreSQLDRManager {
 	return &PatroniDRManager{
-		helper:        helper,
-		patroniHelper: patroniHelper,
-		cluster:       cluster,
+		helper:          helper,
+		patroniHelper:   patroniHelper,
+		services:        helper,
+		patroni:         patroniHelper,
+		cluster:         cluster,
+		standbySettings: newStandbySettingsStore(helper.GetClient(), cluster.ClusterName, cluster.PatroniCommonLabels),
+		dial:            net.DialTimeout,
+		checkSlot:       checkReplicationSlot,
 	}
 }
 
@@ -51,6 +88,14 @@ func (m *PatroniDRManager) setStatus() error {
 func (m *PatroniDRManager) processSiteManagerRequest(response http.ResponseWriter, req *http.Request) {
 	switch req.Method {
 	case "GET":
+		if isDetailsRequested(req) {
+			sendResponse(response, http.StatusOK, SiteManagerStatusDetails{
+				SiteManagerStatus: m.helper.GetCurrentSiteManagerStatus(),
+				PgVersion:         m.getPgVersion(),
+				Replication:       m.getReplicationDetails(),
+			})
+			return
+		}
 		if err := m.getStatus(response); err != nil {
 			_, _ = fmt.Fprintf(response, "Get Status error: %v", err)
 			return
@@ -59,7 +104,7 @@ func (m *PatroniDRManager) processSiteManagerRequest(response http.ResponseWrite
 		statusRequest, err := parseSiteManagerStatusFromRequest(req)
 		if err != nil {
 			log.Error("Failed to parse sm status from request", zap.Error(err))
-			http.Error(response, err.Error(), http.StatusInternalServerError)
+			http.Error(response, err.Error(), http.StatusBadRequest)
 			return
 		}
 		if err := m.helper.UpdateSiteManagerStatus(statusRequest.Mode, "running"); err != nil {
@@ -108,7 +153,11 @@ func (m *PatroniDRManager) processHealthRequest(response http.ResponseWriter, re
 			status = "degraded"
 		}
 	}
-	sendResponse(response, http.StatusOK, Health{Status: status})
+	health := Health{Status: status}
+	if isDetailsRequested(req) {
+		health.Replication = m.getReplicationDetails()
+	}
+	sendResponse(response, http.StatusOK, health)
 }
 
 func (m *PatroniDRManager) waitForClusterHealthy() (bool, error) {
@@ -134,7 +183,7 @@ func (m *PatroniDRManager) waitForClusterHealthy() (bool, error) {
 				return false, nil
 			}
 			time.Sleep(waitTimeout)
-			if m.patroniHelper.IsHealthy(m.cluster.PatroniUrl, m.cluster.PgHost) {
+			if m.patroni.IsHealthy(m.cluster.PatroniUrl, m.cluster.PgHost) {
 				log.Debug("healthy")
 				retries++
 			} else {
@@ -152,14 +201,14 @@ func (m *PatroniDRManager) processStandByMode(healthy bool) (bool, error) {
 	log.Info(fmt.Sprintf("Process Standby Mode with healty=%v", healthy))
 
 	if healthy {
-		if err := m.patroniHelper.TerminateActiveConnections(m.cluster.PgHost); err != nil {
+		if err := m.patroni.TerminateActiveConnections(m.cluster.PgHost); err != nil {
 			log.Error("Can not terminate active connections", zap.Error(err))
 			return false, err
 		}
 	} else {
 		log.Info("patroni cluster is not healthy before set mode to standby, proceeding with clean up")
 		//patch standby to init
-		if err := m.patroniHelper.AddStandbyClusterConfigurationConfigMap(m.cluster.PatroniUrl); err != nil {
+		if err := m.applyStandbySettings(); err != nil {
 			log.Error("Can not update config map with standby cluster configuration")
 			return false, err
 		}
@@ -173,7 +222,7 @@ func (m *PatroniDRManager) processStandByMode(healthy bool) (bool, error) {
 		return false, err
 	}
 	// do we really need to wait for reconcile supplementary services after process standby mode?
-	if err := m.helper.WaitUntilReconcileIsDone(); err != nil {
+	if err := m.services.WaitUntilReconcileIsDone(); err != nil {
 		return false, err
 	}
 
@@ -194,14 +243,14 @@ func (m *PatroniDRManager) changeMode(request qubershipv1.SiteManagerStatus) err
 	m.updateExternalService(mode)
 
 	// do we really need to wait for supplementary services reconcile?
-	if err := m.helper.WaitUntilReconcileIsDone(); err != nil {
+	if err := m.services.WaitUntilReconcileIsDone(); err != nil {
 		return err
 	}
 
 	switch mode {
 	case "standby":
 		// check that patroni is healthy
-		healthy, _ := m.patroniHelper.IsHealthyWithTimeout(1*time.Minute, m.cluster.PatroniUrl, m.cluster.PgHost)
+		healthy, _ := m.patroni.IsHealthyWithTimeout(1*time.Minute, m.cluster.PatroniUrl, m.cluster.PgHost)
 		if err := wait.PollUntilContextTimeout(context.Background(), 1*time.Second, 3*time.Minute, true, func(ctx context.Context) (done bool, err error) {
 			return m.processStandByMode(healthy)
 		}); err != nil {
@@ -291,8 +340,17 @@ func (m *PatroniDRManager) addStandbyClusterConfigInCR() error {
 		log.Error("Error occurred during standby configuration adding. Unable to get PatroniCore CR", zap.Error(err))
 		return err
 	}
-	host := cr.Spec.SiteManager.ActiveClusterHost
-	port := cr.Spec.SiteManager.ActiveClusterPort
+	host, port := helper.GetStandbyClusterSource(cr.Spec.SiteManager)
+	settings, err := m.standbySettings.load(context.Background())
+	if err != nil {
+		return err
+	}
+	if settings != nil {
+		if host, port, err = getStandbySource(settings); err != nil {
+			return err
+		}
+		log.Info(fmt.Sprintf("Using standby source %s:%d checked by pre-configure", host, port))
+	}
 	standbyConfig := &patroniv1.StandbyCluster{Host: host, Port: port}
 	coreCr.Spec.Patroni.StandbyCluster = standbyConfig
 
@@ -308,7 +366,7 @@ func (m *PatroniDRManager) addStandbyClusterConfigInCR() error {
 		return err
 	}
 
-	if err = m.patroniHelper.WaitUntilReconcileIsDone(); err != nil {
+	if err = m.patroni.WaitUntilReconcileIsDone(); err != nil {
 		return err
 	}
 	return err
@@ -318,21 +376,40 @@ func (m *PatroniDRManager) setDisablePatroniCluster(clusterName string) error {
 	return m.helper.UpdatePatroniReplicas(0, clusterName)
 }
 
+// applyStandbySettings sets standby_cluster settings checked by pre-configure to Patroni configuration,
+// without pre-configure they are built from siteManager section of PatroniServices
+func (m *PatroniDRManager) applyStandbySettings() error {
+	settings, err := m.standbySettings.load(context.Background())
+	if err != nil {
+		return err
+	}
+	if settings == nil {
+		return m.patroni.AddStandbyClusterConfigurationConfigMap(m.cluster.PatroniUrl)
+	}
+	return patroni.UpdateStandbyCluster(settings, m.cluster.PatroniUrl)
+}
+
 func (m *PatroniDRManager) setActivePatroniCluster(clusterName string) error {
 	if err := m.clearStandbyClusterConfigInCR(); err != nil {
 		return err
 	}
+	// settings of pre-configure are not used after the cluster is promoted
+	if err := m.standbySettings.clear(context.Background()); err != nil {
+		return err
+	}
 
-	if err := m.patroniHelper.ClearStandbyClusterConfigurationConfigMap(m.cluster.PatroniUrl); err != nil {
+	if err := m.patroni.ClearStandbyClusterConfigurationConfigMap(m.cluster.PatroniUrl); err != nil {
 		return err
 	}
 
 	if err := m.helper.UpdatePatroniReplicas(1, clusterName); err != nil {
 		return err
 	}
-	if healthy, err := m.waitForClusterHealthy(); err != nil || !healthy {
+	if healthy, err := m.waitForClusterHealthy(); err != nil {
 		log.Error("Error occurred while set active mode")
 		return err
+	} else if !healthy {
+		return fmt.Errorf("patroni cluster %s is not healthy after set mode to active", clusterName)
 	}
 	log.Info("patroni cluster is healthy after set mode to active")
 	return nil
@@ -360,7 +437,7 @@ func (m *PatroniDRManager) clearStandbyClusterConfigInCR() error {
 			log.Error("cannot create poll", zap.Error(err))
 			return err
 		}
-		if err = m.patroniHelper.WaitUntilReconcileIsDone(); err != nil {
+		if err = m.patroni.WaitUntilReconcileIsDone(); err != nil {
 			return err
 		}
 		return err
@@ -395,5 +472,85 @@ func (m *PatroniDRManager) revertExternalService(mode string) {
 }
 
 func (m *PatroniDRManager) processPreConfigureRequest(response http.ResponseWriter, req *http.Request) {
+	processPreConfigure(response, req, m.preConfigure)
+}
+
+// preConfigure checks that the site to replicate from is ready to serve this cluster in standby mode
+// and returns standby_cluster settings which will be applied on switch
+func (m *PatroniDRManager) preConfigure(request qubershipv1.SiteManagerStatus) (PreConfigureStatus, error) {
+	result := PreConfigureStatus{}
+	if request.Mode != "standby" {
+		log.Info(fmt.Sprintf("Skipping Pre Configuration for %s mode", request.Mode))
+		return result, nil
+	}
+	cr, err := m.helper.GetPostgresServiceCR()
+	if err != nil {
+		return result, err
+	}
+	if cr.Spec.SiteManager == nil {
+		return result, fmt.Errorf("siteManager is not configured in PatroniServices")
+	}
+	host, port := helper.GetStandbyClusterSource(cr.Spec.SiteManager)
+	if host == "" {
+		return result, fmt.Errorf("neither activeClusterHost nor upstreamClusterHost is specified")
+	}
+	if cr.Spec.SiteManager.UpstreamClusterHost != "" {
+		log.Info(fmt.Sprintf("Cascading standby, cluster will replicate from %s:%d", host, port))
+	}
+
+	if err := checkConnectivity(m.dial, host, port); err != nil {
+		return result, err
+	}
+	slotName := opUtil.GetPatroniClusterName(m.cluster.ClusterName)
+	if err := m.checkSlot(host, port, slotName); err != nil {
+		return result, err
+	}
+
+	coreCr, err := m.patroniHelper.GetPatroniCoreCR()
+	if err != nil {
+		return result, err
+	}
+	result.StandbyCluster = patroni.GetStandbyClusterConfigurationWithHost(coreCr, host, port)
+	if err := m.standbySettings.save(context.Background(), result.StandbyCluster); err != nil {
+		return result, fmt.Errorf("cannot save standby settings: %w", err)
+	}
+	result.Message = fmt.Sprintf("%s:%d is reachable, replication slot %s is available", host, port, slotName)
+	log.Info(result.Message)
+	return result, nil
+}
+
+func checkConnectivity(dial dialFunc, host string, port int) error {
+	address := net.JoinHostPort(host, strconv.Itoa(port))
+	conn, err := dial("tcp", address, 10*time.Second)
+	if err != nil {
+		return fmt.Errorf("cluster to replicate from %s is not reachable: %w", address, err)
+	}
+	_ = conn.Close()
+	return nil
+}
+
+// checkReplicationSlot checks that the upstream cluster either has the physical slot
+// of standby cluster or has free slots for it
+func checkReplicationSlot(host string, port int, slotName string) error {
+	pgC := pgClient.GetPostgresClientForHostAndPort(host, port)
+	if pgC == nil {
+		return fmt.Errorf("can not connect to PostgreSQL on %s:%d", host, port)
+	}
+	defer pgC.Close()
+	conn, err := pgC.GetConnection()
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
 
+	var slotExists, slotAvailable bool
+	query := "select exists(select 1 from pg_replication_slots where slot_name = $1 and slot_type = 'physical'), " +
+		"(select count(*) from pg_replication_slots) < current_setting('max_replication_slots')::int"
+	if err := conn.QueryRow(context.Background(), query, slotName).Scan(&slotExists, &slotAvailable); err != nil {
+		return fmt.Errorf("can not check replication slots on %s:%d: %w", host, port, err)
+	}
+	if !slotExists && !slotAvailable {
+		return fmt.Errorf("replication slot %s does not exist on %s:%d and max_replication_slots is reached", slotName, host, port)
+	}
+	return nil
 }
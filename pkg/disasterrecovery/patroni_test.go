@@ -0,0 +1,445 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disasterrecovery
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	qubershipv1 "github.com/Netcracker/pgskipper-operator/api/apps/v1"
+	patroniv1 "github.com/Netcracker/pgskipper-operator/api/patroni/v1"
+	"github.com/Netcracker/pgskipper-operator/pkg/helper"
+	"github.com/Netcracker/pgskipper-operator/pkg/util"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// fakePatroniCluster keeps health of Patroni cluster in memory and records calls of patroniCluster
+// and reconcileWaiter into the journal, reconcile of custom resources is always done at once
+type fakePatroniCluster struct {
+	mutex   sync.Mutex
+	healthy bool
+	calls   []string
+	failOn  string
+}
+
+func (f *fakePatroniCluster) record(call string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.calls = append(f.calls, call)
+	if f.failOn != "" && f.failOn == call {
+		return errors.New("patroni operation failed")
+	}
+	return nil
+}
+
+func (f *fakePatroniCluster) journal() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]string(nil), f.calls...)
+}
+
+func (f *fakePatroniCluster) WaitUntilReconcileIsDone() error {
+	return nil
+}
+
+func (f *fakePatroniCluster) IsHealthy(_ string, _ string) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.healthy
+}
+
+func (f *fakePatroniCluster) IsHealthyWithTimeout(_ time.Duration, patroniUrl string, pgHost string) (bool, error) {
+	return f.IsHealthy(patroniUrl, pgHost), nil
+}
+
+func (f *fakePatroniCluster) TerminateActiveConnections(_ string) error {
+	return f.record("terminate connections")
+}
+
+func (f *fakePatroniCluster) AddStandbyClusterConfigurationConfigMap(_ string) error {
+	return f.record("add standby_cluster")
+}
+
+func (f *fakePatroniCluster) ClearStandbyClusterConfigurationConfigMap(_ string) error {
+	return f.record("clear standby_cluster")
+}
+
+const activeClusterHost = "pg-patroni.cluster-1"
+
+func newFakeDRClient(t *testing.T, mode string, standbyCluster *patroniv1.StandbyCluster) client.Client {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	for _, addToScheme := range []func(*runtime.Scheme) error{
+		clientgoscheme.AddToScheme, qubershipv1.AddToScheme, patroniv1.AddToScheme,
+	} {
+		if err := addToScheme(scheme); err != nil {
+			t.Fatal(err)
+		}
+	}
+	replicas := int32(1)
+	if mode == "disabled" {
+		replicas = 0
+	}
+	objects := []client.Object{
+		&qubershipv1.PatroniServices{
+			ObjectMeta: metav1.ObjectMeta{Name: "patroni-services", Namespace: namespace},
+			Spec: &qubershipv1.PatroniServicesSpec{SiteManager: &qubershipv1.SiteManager{
+				ActiveClusterHost: activeClusterHost,
+				ActiveClusterPort: 5432,
+				StandbyClusterHealthCheck: &qubershipv1.StandbyClusterHealthCheck{
+					RetriesLimit: 1, FailureRetriesLimit: 1,
+				},
+			}},
+			Status: qubershipv1.PatroniServicesStatus{
+				SiteManagerStatus: qubershipv1.SiteManagerStatus{Mode: mode, Status: "done"},
+			},
+		},
+		&patroniv1.PatroniCore{
+			ObjectMeta: metav1.ObjectMeta{Name: "patroni-core", Namespace: namespace},
+			Spec: &patroniv1.PatroniCoreSpec{Patroni: &patroniv1.Patroni{
+				ClusterName: "patroni", Replicas: 2, StandbyCluster: standbyCluster,
+			}},
+		},
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "pg-patroni-external", Namespace: namespace},
+			Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeExternalName},
+		},
+		&appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "pg-patroni-node1", Namespace: namespace, Labels: map[string]string{"app": "patroni"}},
+			Spec:       appsv1.StatefulSetSpec{Replicas: &replicas},
+		},
+	}
+	return fake.NewClientBuilder().WithScheme(scheme).
+		WithStatusSubresource(&qubershipv1.PatroniServices{}).
+		WithObjects(objects...).Build()
+}
+
+func newTestPatroniDRManager(kubeClient client.Client, cluster *fakePatroniCluster) *PatroniDRManager {
+	settings := util.GetPatroniClusterSettings("patroni")
+	return &PatroniDRManager{
+		helper:          helper.NewHelper(kubeClient),
+		patroniHelper:   helper.NewPatroniHelper(kubeClient),
+		services:        cluster,
+		patroni:         cluster,
+		cluster:         settings,
+		standbySettings: newStandbySettingsStore(kubeClient, settings.ClusterName, settings.PatroniCommonLabels),
+		dial: func(string, string, time.Duration) (net.Conn, error) {
+			return nil, errors.New("connection refused")
+		},
+		checkSlot: func(string, int, string) error {
+			return errors.New("unexpected check of replication slot")
+		},
+	}
+}
+
+// requestMode posts requested mode to site manager endpoint and waits until the switch is finished
+func requestMode(t *testing.T, manager *PatroniDRManager, mode string) qubershipv1.SiteManagerStatus {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/sitemanager", strings.NewReader(`{"mode":"`+mode+`"}`))
+	response := httptest.NewRecorder()
+	manager.processSiteManagerRequest(response, req)
+	if response.Code != http.StatusOK || !strings.Contains(response.Body.String(), `"running"`) {
+		t.Fatalf("response on %s mode request is %d %s, expected running status", mode, response.Code, response.Body.String())
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		status := manager.helper.GetCurrentSiteManagerStatus()
+		if status.Status != "running" {
+			return *status
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("switch to %s mode is not finished", mode)
+	return qubershipv1.SiteManagerStatus{}
+}
+
+func getExternalName(t *testing.T, kubeClient client.Client) string {
+	t.Helper()
+	service := &corev1.Service{}
+	if err := kubeClient.Get(context.Background(), types.NamespacedName{Name: "pg-patroni-external", Namespace: namespace}, service); err != nil {
+		t.Fatal(err)
+	}
+	return service.Spec.ExternalName
+}
+
+func getStandbyCluster(t *testing.T, kubeClient client.Client) *patroniv1.StandbyCluster {
+	t.Helper()
+	cr := &patroniv1.PatroniCore{}
+	if err := kubeClient.Get(context.Background(), types.NamespacedName{Name: "patroni-core", Namespace: namespace}, cr); err != nil {
+		t.Fatal(err)
+	}
+	return cr.Spec.Patroni.StandbyCluster
+}
+
+func getReplicas(t *testing.T, kubeClient client.Client) int32 {
+	t.Helper()
+	statefulSet := &appsv1.StatefulSet{}
+	if err := kubeClient.Get(context.Background(), types.NamespacedName{Name: "pg-patroni-node1", Namespace: namespace}, statefulSet); err != nil {
+		t.Fatal(err)
+	}
+	return *statefulSet.Spec.Replicas
+}
+
+func TestChangeModeActiveToStandby(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings map[string]interface{}
+		expected patroniv1.StandbyCluster
+	}{
+		{
+			name:     "standby replicates from active cluster of site manager",
+			expected: patroniv1.StandbyCluster{Host: activeClusterHost, Port: 5432},
+		},
+		{
+			name:     "standby replicates from source checked by pre-configure",
+			settings: map[string]interface{}{"host": "pg-patroni.cluster-2", "port": 5433, "primary_slot_name": "patroni"},
+			expected: patroniv1.StandbyCluster{Host: "pg-patroni.cluster-2", Port: 5433},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kubeClient := newFakeDRClient(t, "active", nil)
+			cluster := &fakePatroniCluster{healthy: true}
+			manager := newTestPatroniDRManager(kubeClient, cluster)
+			if tt.settings != nil {
+				if err := manager.standbySettings.save(context.Background(), tt.settings); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			status := requestMode(t, manager, "standby")
+
+			if status != (qubershipv1.SiteManagerStatus{Mode: "standby", Status: "done"}) {
+				t.Errorf("site manager status is %v, expected standby done", status)
+			}
+			if standbyCluster := getStandbyCluster(t, kubeClient); standbyCluster == nil || *standbyCluster != tt.expected {
+				t.Errorf("standby cluster of PatroniCore is %v, expected %v", standbyCluster, tt.expected)
+			}
+			if externalName := getExternalName(t, kubeClient); externalName != activeClusterHost {
+				t.Errorf("external service points to %q, expected %q", externalName, activeClusterHost)
+			}
+			if calls := cluster.journal(); !reflect.DeepEqual(calls, []string{"terminate connections"}) {
+				t.Errorf("calls of Patroni are %v, expected only termination of connections", calls)
+			}
+		})
+	}
+}
+
+func TestChangeModeStandbyToActive(t *testing.T) {
+	kubeClient := newFakeDRClient(t, "standby", &patroniv1.StandbyCluster{Host: activeClusterHost, Port: 5432})
+	cluster := &fakePatroniCluster{healthy: true}
+	manager := newTestPatroniDRManager(kubeClient, cluster)
+	if err := manager.standbySettings.save(context.Background(), map[string]interface{}{"host": activeClusterHost, "port": 5432}); err != nil {
+		t.Fatal(err)
+	}
+
+	status := requestMode(t, manager, "active")
+
+	if status != (qubershipv1.SiteManagerStatus{Mode: "active", Status: "done"}) {
+		t.Errorf("site manager status is %v, expected active done", status)
+	}
+	if standbyCluster := getStandbyCluster(t, kubeClient); standbyCluster != nil && *standbyCluster != (patroniv1.StandbyCluster{}) {
+		t.Errorf("standby cluster of PatroniCore is %v, expected it to be cleared", standbyCluster)
+	}
+	if settings, err := manager.standbySettings.load(context.Background()); err != nil || settings != nil {
+		t.Errorf("settings of pre-configure are %v after promotion, error: %v", settings, err)
+	}
+	expectedName := "pg-patroni." + namespace + ".svc.cluster.local"
+	if externalName := getExternalName(t, kubeClient); externalName != expectedName {
+		t.Errorf("external service points to %q, expected %q", externalName, expectedName)
+	}
+	if replicas := getReplicas(t, kubeClient); replicas != 1 {
+		t.Errorf("statefulset has %d replicas, expected 1", replicas)
+	}
+	if calls := cluster.journal(); !reflect.DeepEqual(calls, []string{"clear standby_cluster"}) {
+		t.Errorf("calls of Patroni are %v, expected only clear of standby_cluster", calls)
+	}
+}
+
+func TestChangeModeToDisabled(t *testing.T) {
+	for _, mode := range []string{"active", "standby"} {
+		t.Run(mode, func(t *testing.T) {
+			kubeClient := newFakeDRClient(t, mode, nil)
+			cluster := &fakePatroniCluster{healthy: true}
+			manager := newTestPatroniDRManager(kubeClient, cluster)
+
+			status := requestMode(t, manager, "disabled")
+
+			if status != (qubershipv1.SiteManagerStatus{Mode: "disabled", Status: "done"}) {
+				t.Errorf("site manager status is %v, expected disabled done", status)
+			}
+			if replicas := getReplicas(t, kubeClient); replicas != 0 {
+				t.Errorf("statefulset has %d replicas, expected 0", replicas)
+			}
+			if calls := cluster.journal(); len(calls) != 0 {
+				t.Errorf("calls of Patroni are %v, expected none", calls)
+			}
+		})
+	}
+}
+
+func TestChangeModeFailed(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    string
+		current string
+		cluster *fakePatroniCluster
+	}{
+		{
+			name:    "active cluster is not healthy after promotion",
+			mode:    "active",
+			current: "standby",
+			cluster: &fakePatroniCluster{healthy: false},
+		},
+		{
+			name:    "connections can not be terminated before demotion",
+			mode:    "standby",
+			current: "active",
+			cluster: &fakePatroniCluster{healthy: true, failOn: "terminate connections"},
+		},
+		{
+			name:    "standby_cluster can not be cleared on promotion",
+			mode:    "active",
+			current: "standby",
+			cluster: &fakePatroniCluster{healthy: true, failOn: "clear standby_cluster"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kubeClient := newFakeDRClient(t, tt.current, nil)
+			manager := newTestPatroniDRManager(kubeClient, tt.cluster)
+
+			status := requestMode(t, manager, tt.mode)
+
+			if status != (qubershipv1.SiteManagerStatus{Mode: tt.mode, Status: "failed"}) {
+				t.Errorf("site manager status is %v, expected %s failed", status, tt.mode)
+			}
+		})
+	}
+}
+
+func TestPreConfigure(t *testing.T) {
+	previous := map[string]interface{}{"host": "pg-patroni.cluster-0", "port": float64(5432), "primary_slot_name": "patroni"}
+	tests := []struct {
+		name      string
+		dialErr   error
+		slotErr   error
+		wantErr   string
+		wantSaved map[string]interface{}
+	}{
+		{
+			name:    "active cluster is not reachable",
+			dialErr: errors.New("connection refused"),
+			wantErr: "pg-patroni.cluster-1:5432 is not reachable",
+		},
+		{
+			name:    "replication slot is missing and can not be created",
+			slotErr: errors.New("replication slot patroni does not exist"),
+			wantErr: "replication slot patroni does not exist",
+		},
+		{
+			name: "settings are saved when checks pass",
+			wantSaved: map[string]interface{}{
+				"host": activeClusterHost, "port": float64(5432), "primary_slot_name": "patroni",
+				"create_replica_methods": []interface{}{"basebackup"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kubeClient := newFakeDRClient(t, "active", nil)
+			manager := newTestPatroniDRManager(kubeClient, &fakePatroniCluster{healthy: true})
+			if err := manager.standbySettings.save(context.Background(), previous); err != nil {
+				t.Fatal(err)
+			}
+			var dialed, checkedSlot string
+			manager.dial = func(network string, address string, _ time.Duration) (net.Conn, error) {
+				dialed = address
+				if tt.dialErr != nil {
+					return nil, tt.dialErr
+				}
+				client, server := net.Pipe()
+				_ = server.Close()
+				return client, nil
+			}
+			manager.checkSlot = func(host string, port int, slotName string) error {
+				checkedSlot = slotName
+				return tt.slotErr
+			}
+
+			result, err := manager.preConfigure(qubershipv1.SiteManagerStatus{Mode: "standby"})
+
+			if dialed != "pg-patroni.cluster-1:5432" {
+				t.Errorf("dialed address is %q, expected pg-patroni.cluster-1:5432", dialed)
+			}
+			saved, loadErr := manager.standbySettings.load(context.Background())
+			if loadErr != nil {
+				t.Fatal(loadErr)
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error is %v, expected %q", err, tt.wantErr)
+				}
+				if !reflect.DeepEqual(saved, previous) {
+					t.Errorf("settings are %v after failed pre-configure, expected previous %v", saved, previous)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if checkedSlot != "patroni" {
+					t.Errorf("checked slot is %q, expected patroni", checkedSlot)
+				}
+				if !reflect.DeepEqual(saved, tt.wantSaved) {
+					t.Errorf("saved settings are %v, expected %v", saved, tt.wantSaved)
+				}
+				if result.Message == "" {
+					t.Error("message of successful pre-configure is empty")
+				}
+			}
+			if status := manager.helper.GetCurrentSiteManagerStatus(); *status != (qubershipv1.SiteManagerStatus{Mode: "active", Status: "done"}) {
+				t.Errorf("site manager status is changed by pre-configure to %v", *status)
+			}
+		})
+	}
+}
+
+func TestPreConfigureIsSkippedForActiveMode(t *testing.T) {
+	kubeClient := newFakeDRClient(t, "standby", nil)
+	manager := newTestPatroniDRManager(kubeClient, &fakePatroniCluster{healthy: true})
+	manager.dial = func(string, string, time.Duration) (net.Conn, error) {
+		t.Error("connectivity is checked for active mode")
+		return nil, errors.New("unexpected dial")
+	}
+
+	if _, err := manager.preConfigure(qubershipv1.SiteManagerStatus{Mode: "active"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
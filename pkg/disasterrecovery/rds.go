@@ -0,0 +1,180 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disasterrecovery
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	rdsAvailableStatus  = "available"
+	rdsOperationTimeout = 60 * time.Minute
+)
+
+// rdsDBClient manages RDS instances tagged with namespace of the operator in current and peer regions
+type rdsDBClient struct {
+	clients map[string]*rds.Client
+}
+
+func newRDSDBClient(regions ...string) (managedDBClient, error) {
+	cfg, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	client := &rdsDBClient{clients: map[string]*rds.Client{}}
+	for _, region := range regions {
+		if region == "" {
+			continue
+		}
+		client.clients[region] = rds.NewFromConfig(cfg, func(o *rds.Options) {
+			o.Region = region
+		})
+	}
+	return client, nil
+}
+
+func (c *rdsDBClient) listInstances(ctx context.Context) ([]managedInstance, error) {
+	var instances []managedInstance
+	for region, client := range c.clients {
+		paginator := rds.NewDescribeDBInstancesPaginator(client, &rds.DescribeDBInstancesInput{})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, err
+			}
+			for _, instance := range page.DBInstances {
+				if !hasNamespaceTag(instance.TagList) {
+					continue
+				}
+				instances = append(instances, toManagedInstance(region, instance))
+			}
+		}
+	}
+	return instances, nil
+}
+
+func (c *rdsDBClient) promoteReplica(ctx context.Context, replica managedInstance) error {
+	client, err := c.getClient(replica.Region)
+	if err != nil {
+		return err
+	}
+	log.Info(fmt.Sprintf("Start to promote replica: %s", replica.Name))
+	start := time.Now()
+	if _, err := client.PromoteReadReplica(ctx, &rds.PromoteReadReplicaInput{
+		DBInstanceIdentifier: aws.String(replica.Name),
+	}); err != nil {
+		return err
+	}
+	if err := c.waitForInstance(ctx, client, replica.Name, func(instance managedInstance) bool {
+		return instance.Ready && !instance.Replica
+	}); err != nil {
+		return err
+	}
+	log.Info(fmt.Sprintf("PromoteReplica took %s", time.Since(start)))
+	return nil
+}
+
+func (c *rdsDBClient) createReplica(ctx context.Context, primary managedInstance, region string, name string) error {
+	client, err := c.getClient(region)
+	if err != nil {
+		return err
+	}
+	log.Info(fmt.Sprintf("Will create read replica %s of %s in region: %s", name, primary.Id, region))
+	input := &rds.CreateDBInstanceReadReplicaInput{
+		DBInstanceIdentifier:       aws.String(name),
+		SourceDBInstanceIdentifier: aws.String(primary.Id),
+		Tags:                       []types.Tag{{Key: aws.String("namespace"), Value: aws.String(namespace)}},
+	}
+	if primary.Region != region {
+		input.SourceRegion = aws.String(primary.Region)
+	}
+	if _, err := client.CreateDBInstanceReadReplica(ctx, input); err != nil {
+		return err
+	}
+	return rds.NewDBInstanceAvailableWaiter(client).Wait(ctx,
+		&rds.DescribeDBInstancesInput{DBInstanceIdentifier: aws.String(name)}, rdsOperationTimeout)
+}
+
+// dropInstance deletes instance with final snapshot, so data of the former primary can be restored if needed
+func (c *rdsDBClient) dropInstance(ctx context.Context, instance managedInstance) error {
+	client, err := c.getClient(instance.Region)
+	if err != nil {
+		return err
+	}
+	snapshot := fmt.Sprintf("%s-final-%d", instance.Name, time.Now().Unix())
+	log.Info(fmt.Sprintf("Will drop instance %s with final snapshot %s", instance.Name, snapshot))
+	if _, err := client.DeleteDBInstance(ctx, &rds.DeleteDBInstanceInput{
+		DBInstanceIdentifier:      aws.String(instance.Name),
+		FinalDBSnapshotIdentifier: aws.String(snapshot),
+	}); err != nil {
+		return err
+	}
+	return rds.NewDBInstanceDeletedWaiter(client).Wait(ctx,
+		&rds.DescribeDBInstancesInput{DBInstanceIdentifier: aws.String(instance.Name)}, rdsOperationTimeout)
+}
+
+func (c *rdsDBClient) waitForInstance(ctx context.Context, client *rds.Client, name string, condition func(instance managedInstance) bool) error {
+	return wait.PollUntilContextTimeout(ctx, 15*time.Second, rdsOperationTimeout, false, func(ctx context.Context) (bool, error) {
+		output, err := client.DescribeDBInstances(ctx, &rds.DescribeDBInstancesInput{DBInstanceIdentifier: aws.String(name)})
+		if err != nil {
+			return false, err
+		}
+		if len(output.DBInstances) == 0 {
+			return false, nil
+		}
+		instance := toManagedInstance(client.Options().Region, output.DBInstances[0])
+		log.Info(fmt.Sprintf("Status of instance %s: %s", name, aws.ToString(output.DBInstances[0].DBInstanceStatus)))
+		return condition(instance), nil
+	})
+}
+
+func (c *rdsDBClient) getClient(region string) (*rds.Client, error) {
+	client, ok := c.clients[region]
+	if !ok {
+		return nil, fmt.Errorf("region %s is not configured for RDS", region)
+	}
+	return client, nil
+}
+
+func toManagedInstance(region string, instance types.DBInstance) managedInstance {
+	result := managedInstance{
+		Id:      aws.ToString(instance.DBInstanceArn),
+		Name:    aws.ToString(instance.DBInstanceIdentifier),
+		Region:  region,
+		Replica: aws.ToString(instance.ReadReplicaSourceDBInstanceIdentifier) != "",
+		Ready:   aws.ToString(instance.DBInstanceStatus) == rdsAvailableStatus,
+	}
+	if instance.Endpoint != nil {
+		result.Endpoint = aws.ToString(instance.Endpoint.Address)
+	}
+	return result
+}
+
+func hasNamespaceTag(tags []types.Tag) bool {
+	for _, tag := range tags {
+		if aws.ToString(tag.Key) == "namespace" {
+			return aws.ToString(tag.Value) == namespace
+		}
+	}
+	return false
+}
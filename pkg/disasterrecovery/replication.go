@@ -0,0 +1,250 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disasterrecovery
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	qubershipv1 "github.com/Netcracker/pgskipper-operator/api/apps/v1"
+	pgClient "github.com/Netcracker/pgskipper-operator/pkg/client"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultMaxLagOnPromotion matches default maximum_lag_on_failover of Patroni
+	defaultMaxLagOnPromotion = int64(1048576)
+
+	// maxWalReceiptDelay matches default wal_sender_timeout, the active cluster sends keepalive messages
+	// at least every half of it, so the end of its WAL is unknown if nothing is received for longer
+	maxWalReceiptDelay = 60 * time.Second
+
+	recoveryStreaming = "streaming"
+	recoveryArchive   = "archive_recovery"
+
+	// latest_end_lsn is the end of WAL of the active cluster reported by its WAL sender in the last message
+	walReceiverQuery = "select coalesce((select status from pg_stat_wal_receiver), ''), " +
+		"(select received_tli from pg_stat_wal_receiver), " +
+		"(select last_msg_receipt_time from pg_stat_wal_receiver), " +
+		"(select extract(epoch from now() - last_msg_receipt_time)::float8 from pg_stat_wal_receiver), " +
+		"coalesce(pg_wal_lsn_diff(pg_last_wal_receive_lsn(), pg_last_wal_replay_lsn()), 0)::bigint, " +
+		"case when pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() then 0 " +
+		"else coalesce(extract(epoch from now() - pg_last_xact_replay_timestamp()), 0) end::float8, " +
+		"(select greatest(pg_wal_lsn_diff(latest_end_lsn, pg_last_wal_replay_lsn()), 0)::bigint from pg_stat_wal_receiver)"
+	replicationLagQuery = "select application_name, coalesce(extract(epoch from flush_lag), 0)::float8, " +
+		"coalesce(extract(epoch from replay_lag), 0)::float8 from pg_stat_replication"
+)
+
+// ReplicationDetails describes replication state of the cluster and whether standby can be promoted
+type ReplicationDetails struct {
+	Timeline           int                 `json:"timeline,omitempty"`
+	Recovery           string              `json:"recovery,omitempty"`
+	LastWalReceiptTime *time.Time          `json:"lastWalReceiptTime,omitempty"`
+	MaxLagOnPromotion  int64               `json:"maxLagOnPromotion"`
+	SafeToPromote      bool                `json:"safeToPromote"`
+	Reason             string              `json:"reason,omitempty"`
+	Members            []MemberReplication `json:"members"`
+	// ActiveLagBytes is the lag of standby leader replay behind the end of WAL of the active cluster
+	ActiveLagBytes *int64 `json:"activeLagBytes,omitempty"`
+}
+
+// MemberReplication is the lag of cluster member, standby leader lag is calculated against received WAL
+type MemberReplication struct {
+	Name              string   `json:"name"`
+	Role              string   `json:"role"`
+	State             string   `json:"state"`
+	Timeline          int      `json:"timeline"`
+	ReceiveLagBytes   *int64   `json:"receiveLagBytes,omitempty"`
+	ReplayLagBytes    *int64   `json:"replayLagBytes,omitempty"`
+	ReceiveLagSeconds *float64 `json:"receiveLagSeconds,omitempty"`
+	ReplayLagSeconds  *float64 `json:"replayLagSeconds,omitempty"`
+}
+
+// walReceiverState is a state of WAL receiver of standby leader
+type walReceiverState struct {
+	status             string
+	receivedTimeline   *int32
+	lastMsgReceiptTime *time.Time
+	// receiptAgeSeconds is a time since the last message from the active cluster, nil if nothing is received
+	receiptAgeSeconds *float64
+	replayLagBytes    int64
+	replayLagSeconds  float64
+	// activeLagBytes is nil if nothing is received from the active cluster
+	activeLagBytes *int64
+}
+
+type memberSecondsLag struct {
+	receive float64
+	replay  float64
+}
+
+// SiteManagerStatusDetails extends site manager status with replication details keeping its fields on top level
+type SiteManagerStatusDetails struct {
+	*qubershipv1.SiteManagerStatus
+	// PgVersion is PostgreSQL major version of the cluster, standby site waits for it before major upgrade
+	PgVersion   string              `json:"pgVersion,omitempty"`
+	Replication *ReplicationDetails `json:"replication,omitempty"`
+}
+
+// isDetailsRequested returns true if client asked for details with ?details=true,
+// responses without it are the same as before for existing site manager clients
+func isDetailsRequested(req *http.Request) bool {
+	return req.URL.Query().Get("details") == "true"
+}
+
+func (m *PatroniDRManager) getReplicationDetails() *ReplicationDetails {
+	details := &ReplicationDetails{MaxLagOnPromotion: defaultMaxLagOnPromotion, Members: []MemberReplication{}}
+	if cr, err := m.helper.GetPostgresServiceCR(); err == nil && cr.Spec.SiteManager != nil && cr.Spec.SiteManager.MaxLagOnPromotion > 0 {
+		details.MaxLagOnPromotion = cr.Spec.SiteManager.MaxLagOnPromotion
+	}
+
+	config, err := m.helper.GetPatroniClusterConfig(m.cluster.PatroniUrl)
+	if err != nil {
+		details.Reason = fmt.Sprintf("can not get Patroni cluster status: %v", err)
+		return details
+	}
+	leaderIdx := -1
+	for _, member := range config.Members {
+		details.Members = append(details.Members, MemberReplication{
+			Name:            member.Name,
+			Role:            member.Role,
+			State:           member.State,
+			Timeline:        member.Timeline,
+			ReceiveLagBytes: lagBytes(member.ReceiveLag),
+			ReplayLagBytes:  lagBytes(member.ReplayLag),
+		})
+		if member.ReceiveLag == nil && member.ReplayLag == nil && member.Role != "leader" && member.Role != "standby_leader" {
+			// Patroni before 4.0 reports only total lag, which is the receive lag
+			details.Members[len(details.Members)-1].ReceiveLagBytes = lagBytes(member.Lag)
+		}
+		if member.Role == "leader" || member.Role == "standby_leader" {
+			leaderIdx = len(details.Members) - 1
+			details.Timeline = member.Timeline
+		}
+	}
+	if leaderIdx < 0 {
+		details.Reason = "cluster has no leader"
+		return details
+	}
+	leader := &details.Members[leaderIdx]
+
+	pgC := pgClient.GetPostgresClient(m.cluster.PgHost)
+	if pgC == nil {
+		details.Reason = "PostgreSQL is not available"
+		return details
+	}
+	conn, err := pgC.GetConnection()
+	if err != nil {
+		details.Reason = fmt.Sprintf("PostgreSQL is not available: %v", err)
+		return details
+	}
+	defer conn.Release()
+
+	if lags, err := getMembersSecondsLag(conn); err == nil {
+		for i := range details.Members {
+			if lag, ok := lags[details.Members[i].Name]; ok {
+				details.Members[i].ReceiveLagSeconds = &lag.receive
+				details.Members[i].ReplayLagSeconds = &lag.replay
+			}
+		}
+	} else {
+		log.Warn("can not get replication lag of members", zap.Error(err))
+	}
+
+	if leader.Role != "standby_leader" {
+		details.Reason = "cluster is not in standby mode"
+		return details
+	}
+
+	var state walReceiverState
+	if err := conn.QueryRow(context.Background(), walReceiverQuery).Scan(&state.status, &state.receivedTimeline,
+		&state.lastMsgReceiptTime, &state.receiptAgeSeconds, &state.replayLagBytes, &state.replayLagSeconds, &state.activeLagBytes); err != nil {
+		details.Reason = fmt.Sprintf("can not get WAL receiver status: %v", err)
+		return details
+	}
+	evaluatePromotion(details, leader, state)
+	return details
+}
+
+// evaluatePromotion reports whether standby leader can be promoted without losing WAL of the active cluster,
+// the lag is measured against the end of WAL of the active cluster, which is known only if it's heard recently
+func evaluatePromotion(details *ReplicationDetails, leader *MemberReplication, state walReceiverState) {
+	details.LastWalReceiptTime = state.lastMsgReceiptTime
+	details.ActiveLagBytes = state.activeLagBytes
+	leader.ReplayLagBytes = &state.replayLagBytes
+	leader.ReplayLagSeconds = &state.replayLagSeconds
+	if state.status == recoveryStreaming {
+		details.Recovery = recoveryStreaming
+	} else {
+		details.Recovery = recoveryArchive
+	}
+
+	switch {
+	case details.Recovery != recoveryStreaming:
+		details.Reason = "standby leader is not streaming from the active cluster"
+	case state.receivedTimeline != nil && int(*state.receivedTimeline) != leader.Timeline:
+		details.Reason = fmt.Sprintf("standby leader is on timeline %d, received timeline is %d", leader.Timeline, *state.receivedTimeline)
+	case state.receiptAgeSeconds == nil || state.activeLagBytes == nil:
+		details.Reason = "nothing is received from the active cluster, lag behind it is unknown"
+	case *state.receiptAgeSeconds > maxWalReceiptDelay.Seconds():
+		details.Reason = fmt.Sprintf("last message from the active cluster is received %.0fs ago, lag behind it is unknown",
+			*state.receiptAgeSeconds)
+	case *state.activeLagBytes > details.MaxLagOnPromotion:
+		details.Reason = fmt.Sprintf("lag behind the active cluster %d bytes exceeds maximum lag on promotion %d bytes",
+			*state.activeLagBytes, details.MaxLagOnPromotion)
+	default:
+		details.SafeToPromote = true
+	}
+}
+
+func (m *PatroniDRManager) getPgVersion() string {
+	masterPod, err := m.helper.GetPodsByLabel(m.cluster.PatroniMasterSelectors)
+	if err != nil || len(masterPod.Items) == 0 {
+		log.Warn("can not get leader pod to read PostgreSQL version", zap.Error(err))
+		return ""
+	}
+	return m.patroniHelper.GetPGVersion(masterPod.Items[0].Name)
+}
+
+// getMembersSecondsLag returns lag of members replicating from the leader by their names
+func getMembersSecondsLag(conn *pgxpool.Conn) (map[string]memberSecondsLag, error) {
+	rows, err := conn.Query(context.Background(), replicationLagQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := map[string]memberSecondsLag{}
+	for rows.Next() {
+		var name string
+		var lag memberSecondsLag
+		if err := rows.Scan(&name, &lag.receive, &lag.replay); err != nil {
+			return nil, err
+		}
+		result[name] = lag
+	}
+	return result, rows.Err()
+}
+
+// lagBytes converts lag from Patroni /cluster response, it's a number of bytes or "unknown"
+func lagBytes(lag interface{}) *int64 {
+	if value, ok := lag.(float64); ok {
+		result := int64(value)
+		return &result
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disasterrecovery
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/utils/ptr"
+)
+
+func TestEvaluatePromotion(t *testing.T) {
+	receiptTime := time.Date(2025, 1, 15, 10, 12, 31, 0, time.UTC)
+	streaming := func() walReceiverState {
+		return walReceiverState{
+			status:             recoveryStreaming,
+			receivedTimeline:   ptr.To[int32](3),
+			lastMsgReceiptTime: &receiptTime,
+			receiptAgeSeconds:  ptr.To(0.5),
+			replayLagBytes:     128,
+			replayLagSeconds:   0.01,
+			activeLagBytes:     ptr.To[int64](4096),
+		}
+	}
+	tests := []struct {
+		name   string
+		state  func(state *walReceiverState)
+		safe   bool
+		reason string
+	}{
+		{name: "streaming", state: func(state *walReceiverState) {}, safe: true},
+		{name: "timeline of WAL receiver is unknown", state: func(state *walReceiverState) { state.receivedTimeline = nil }, safe: true},
+		{name: "lag equals maximum", state: func(state *walReceiverState) { state.activeLagBytes = ptr.To(defaultMaxLagOnPromotion) }, safe: true},
+		{name: "receipt delay equals maximum", state: func(state *walReceiverState) { state.receiptAgeSeconds = ptr.To(60.0) }, safe: true},
+		{
+			name:   "archive recovery",
+			state:  func(state *walReceiverState) { *state = walReceiverState{replayLagBytes: 10} },
+			reason: "not streaming",
+		},
+		{
+			name:   "WAL receiver is starting",
+			state:  func(state *walReceiverState) { state.status = "startup" },
+			reason: "not streaming",
+		},
+		{
+			name:   "another timeline is received",
+			state:  func(state *walReceiverState) { state.receivedTimeline = ptr.To[int32](4) },
+			reason: "standby leader is on timeline 3, received timeline is 4",
+		},
+		{
+			name: "nothing is received",
+			state: func(state *walReceiverState) {
+				state.lastMsgReceiptTime, state.receiptAgeSeconds, state.activeLagBytes = nil, nil, nil
+			},
+			reason: "lag behind it is unknown",
+		},
+		{
+			// replay caught up with received WAL, but the active cluster isn't heard since the network is broken
+			name:   "active cluster is not heard",
+			state:  func(state *walReceiverState) { state.receiptAgeSeconds = ptr.To(95.4); state.replayLagBytes = 0 },
+			reason: "last message from the active cluster is received 95s ago",
+		},
+		{
+			// received WAL is replayed, but the active cluster is far ahead
+			name: "lag behind the active cluster",
+			state: func(state *walReceiverState) {
+				state.replayLagBytes = 0
+				state.activeLagBytes = ptr.To[int64](2 * 1048576)
+			},
+			reason: "lag behind the active cluster 2097152 bytes exceeds maximum lag on promotion 1048576 bytes",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			details := &ReplicationDetails{MaxLagOnPromotion: defaultMaxLagOnPromotion,
+				Members: []MemberReplication{{Name: "pg-patroni-node1-0", Role: "standby_leader", Timeline: 3}}}
+			state := streaming()
+			tt.state(&state)
+			evaluatePromotion(details, &details.Members[0], state)
+
+			if details.SafeToPromote != tt.safe {
+				t.Fatalf("safe to promote is %t (%s), expected %t", details.SafeToPromote, details.Reason, tt.safe)
+			}
+			if tt.safe && details.Reason != "" {
+				t.Errorf("reason is set for safe promotion: %s", details.Reason)
+			}
+			if !strings.Contains(details.Reason, tt.reason) {
+				t.Errorf("reason is %q, expected %q", details.Reason, tt.reason)
+			}
+			leader := details.Members[0]
+			if leader.ReplayLagBytes == nil || *leader.ReplayLagBytes != state.replayLagBytes ||
+				leader.ReplayLagSeconds == nil || *leader.ReplayLagSeconds != state.replayLagSeconds {
+				t.Errorf("replay lag of standby leader is not reported: %+v", leader)
+			}
+			if details.ActiveLagBytes != state.activeLagBytes || details.LastWalReceiptTime != state.lastMsgReceiptTime {
+				t.Errorf("lag behind the active cluster is not reported: %+v", details)
+			}
+			expectedRecovery := recoveryArchive
+			if state.status == recoveryStreaming {
+				expectedRecovery = recoveryStreaming
+			}
+			if details.Recovery != expectedRecovery {
+				t.Errorf("recovery is %s, expected %s", details.Recovery, expectedRecovery)
+			}
+		})
+	}
+}
+
+func TestLagBytes(t *testing.T) {
+	if lag := lagBytes(float64(1024)); lag == nil || *lag != 1024 {
+		t.Errorf("lag is %v, expected 1024", lag)
+	}
+	for _, lag := range []interface{}{"unknown", nil} {
+		if result := lagBytes(lag); result != nil {
+			t.Errorf("lag of %v is %d, expected nil", lag, *result)
+		}
+	}
+}
+
+func TestWalReceiverQueryWithoutReceiver(t *testing.T) {
+	pgC := requirePostgres(t)
+	conn, err := pgC.GetConnection()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Release()
+
+	var state walReceiverState
+	if err := conn.QueryRow(context.Background(), walReceiverQuery).Scan(&state.status, &state.receivedTimeline,
+		&state.lastMsgReceiptTime, &state.receiptAgeSeconds, &state.replayLagBytes, &state.replayLagSeconds, &state.activeLagBytes); err != nil {
+		t.Fatal(err)
+	}
+	if state.status != "" || state.receivedTimeline != nil || state.lastMsgReceiptTime != nil || state.receiptAgeSeconds != nil || state.activeLagBytes != nil {
+		t.Errorf("unexpected state of WAL receiver on primary: %+v", state)
+	}
+
+	details := &ReplicationDetails{MaxLagOnPromotion: defaultMaxLagOnPromotion, Members: []MemberReplication{{Role: "standby_leader", Timeline: 1}}}
+	evaluatePromotion(details, &details.Members[0], state)
+	if details.SafeToPromote || details.Recovery != recoveryArchive {
+		t.Errorf("cluster without WAL receiver is safe to promote: %+v", details)
+	}
+}
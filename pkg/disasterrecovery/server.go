@@ -19,10 +19,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"os"
+	"strings"
 
 	qubershipv1 "github.com/Netcracker/pgskipper-operator/api/apps/v1"
 	k8sHelper "github.com/Netcracker/pgskipper-operator/pkg/helper"
+	"github.com/Netcracker/pgskipper-operator/pkg/metrics"
 	"github.com/Netcracker/pgskipper-operator/pkg/util"
+	"github.com/Netcracker/pgskipper-operator/pkg/util/constants"
 	"go.uber.org/zap"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
@@ -34,6 +38,8 @@ var (
 	secretName = "cloudsql-instance-credentials"
 )
 
+const preConfigureStatusFile = "/tmp/.pre-configure-status.json"
+
 type GenericPostgreSQLDRManager interface {
 	processSiteManagerRequest(response http.ResponseWriter, req *http.Request)
 	processHealthRequest(response http.ResponseWriter, req *http.Request)
@@ -56,11 +62,16 @@ func InitDRManager() {
 	if err != nil {
 		log.Error("Can not init Site Manager", zap.Error(err))
 	}
-	patroniClusterSettings := util.GetPatroniClusterSettings(cr.Spec.Patroni.ClusterName)
+	clusterName := "patroni"
+	if cr.Spec.Patroni != nil && cr.Spec.Patroni.ClusterName != "" {
+		clusterName = cr.Spec.Patroni.ClusterName
+	}
 	if cloudSqlCm != nil {
 		pgManager = newCloudSQLDRManager(helper, cloudSqlCm)
+	} else if managedClient := getManagedDBClient(cr.Spec.ExternalDataBase); managedClient != nil {
+		pgManager = newManagedDRManager(helper, managedClient, cr.Spec.ExternalDataBase.Region, clusterName)
 	} else {
-		pgManager = newPatroniDRManager(helper, patroniHelper, patroniClusterSettings)
+		pgManager = newPatroniDRManager(helper, patroniHelper, util.GetPatroniClusterSettings(clusterName))
 	}
 	if err := util.ExecuteWithRetries(pgManager.setStatus); err != nil {
 		log.Warn("not able to set SM status with retries, ", zap.Error(err))
@@ -76,11 +87,38 @@ func InitDRManager() {
 		}
 	}
 
+	if metrics.IsEnabled() {
+		metrics.Register(metrics.NewSiteManagerCollector(helper.GetCurrentSiteManagerStatus))
+	}
+
 	http.Handle("/sitemanager", helper.Middleware(http.HandlerFunc(pgManager.processSiteManagerRequest)))
 	http.Handle("/health", helper.Middleware(http.HandlerFunc(pgManager.processHealthRequest)))
 	http.Handle("/pre-configure", helper.Middleware(http.HandlerFunc(pgManager.processPreConfigureRequest)))
 }
 
+// getManagedDBClient returns cloud client for RDS or Azure external database, nil means it's not a managed database
+func getManagedDBClient(externalDataBase *qubershipv1.ExternalDataBase) managedDBClient {
+	if externalDataBase == nil {
+		return nil
+	}
+	var client managedDBClient
+	var err error
+	switch strings.ToLower(externalDataBase.Type) {
+	case constants.RDS:
+		client, err = newRDSDBClient(externalDataBase.Region, externalDataBase.PeerRegion)
+	case constants.Azure:
+		client, err = newAzureDBClient(externalDataBase.Project)
+	default:
+		return nil
+	}
+	if err != nil {
+		log.Error(fmt.Sprintf("Can not create %s client, proceeding with Patroni DR Manager", externalDataBase.Type), zap.Error(err))
+		return nil
+	}
+	log.Info(fmt.Sprintf("External database of %s type found, proceeding with Managed DR Manager", externalDataBase.Type))
+	return client
+}
+
 func getCloudSqlCm(helper *k8sHelper.Helper) *corev1.ConfigMap {
 	cloudSqlCm, err := helper.GetConfigMap("cloud-sql-configuration")
 	if err != nil {
@@ -98,17 +136,118 @@ func getCloudSqlCm(helper *k8sHelper.Helper) *corev1.ConfigMap {
 	}
 }
 
+// parseSiteManagerStatusFromRequest returns mode requested by site manager, the mode is the only way
+// to switch the cluster between active, standby and disabled, so unknown modes are rejected here
 func parseSiteManagerStatusFromRequest(req *http.Request) (qubershipv1.SiteManagerStatus, error) {
 	var status qubershipv1.SiteManagerStatus
 	err := json.NewDecoder(req.Body).Decode(&status)
 	if err != nil {
 		return qubershipv1.SiteManagerStatus{}, err
 	}
-	return status, nil
+	switch status.Mode {
+	case "active", "standby", "disabled":
+		return status, nil
+	}
+	return qubershipv1.SiteManagerStatus{}, fmt.Errorf("unsupported mode %q, expected active, standby or disabled", status.Mode)
 }
 
 type Health struct {
-	Status string `json:"status"`
+	Status      string              `json:"status"`
+	Replication *ReplicationDetails `json:"replication,omitempty"`
+}
+
+// PreConfigureStatus is the result of the last pre-configure call
+type PreConfigureStatus struct {
+	Mode           string                 `json:"mode,omitempty"`
+	Status         string                 `json:"status,omitempty"`
+	Message        string                 `json:"message,omitempty"`
+	StandbyCluster map[string]interface{} `json:"standbyCluster,omitempty"`
+}
+
+// processPreConfigure returns status of the last pre-configure call on GET,
+// on POST it starts preConfigure for requested mode unless it's running or already done
+func processPreConfigure(response http.ResponseWriter, req *http.Request,
+	preConfigure func(request qubershipv1.SiteManagerStatus) (PreConfigureStatus, error)) {
+	switch req.Method {
+	case "GET":
+		preConfigureStatus, err := getPreConfigureStatus()
+		if err != nil {
+			log.Error("there is an error during pre-configure status read ", zap.Error(err))
+			sendResponse(response, http.StatusInternalServerError, preConfigureStatus)
+			return
+		}
+		sendResponse(response, http.StatusOK, preConfigureStatus)
+	case "POST":
+		statusRequest, err := parseSiteManagerStatusFromRequest(req)
+		if err != nil {
+			log.Error("Failed to parse sm status from request", zap.Error(err))
+			http.Error(response, err.Error(), http.StatusBadRequest)
+			return
+		}
+		preConfigureStatus, err := getPreConfigureStatus()
+		if err != nil {
+			log.Error("there is an error during pre-configure status read ", zap.Error(err))
+			sendResponse(response, http.StatusInternalServerError, preConfigureStatus)
+			return
+		}
+		if preConfigureStatus.Status == "running" {
+			log.Info("Received request during running procedure, return current state")
+			sendResponse(response, http.StatusOK, preConfigureStatus)
+			return
+		} else if statusRequest.Mode == preConfigureStatus.Mode &&
+			preConfigureStatus.Status == "done" {
+			log.Info("Desired status equals to current status, return current state")
+			sendResponse(response, http.StatusOK, preConfigureStatus)
+			return
+		}
+		log.Info(fmt.Sprintf("processPreConfigureRequest invoked with mode: %s", statusRequest.Mode))
+		preConfigureStatus = PreConfigureStatus{Mode: statusRequest.Mode, Status: "running"}
+		if err := setPreConfigureStatus(preConfigureStatus); err != nil {
+			log.Error("Failed to set sm status", zap.Error(err))
+			http.Error(response, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		sendResponse(response, http.StatusOK, preConfigureStatus)
+
+		// async process of request
+		go func() {
+			result, err := preConfigure(statusRequest)
+			result.Mode = statusRequest.Mode
+			if err != nil {
+				log.Error("There is an error, during pre-configure call", zap.Error(err))
+				result.Status = "failed"
+				result.Message = err.Error()
+			} else {
+				result.Status = "done"
+			}
+			if err := setPreConfigureStatus(result); err != nil {
+				log.Error("Failed to set pre-configure status", zap.Error(err))
+			}
+		}()
+	default:
+		_, _ = fmt.Fprintf(response, "Only GET and POST methods are supported.")
+	}
+}
+
+func setPreConfigureStatus(status PreConfigureStatus) error {
+	statusAsJson, _ := json.Marshal(status)
+	return os.WriteFile(preConfigureStatusFile, statusAsJson, 0644)
+}
+
+// getPreConfigureStatus returns empty status if pre-configure has never been called
+func getPreConfigureStatus() (PreConfigureStatus, error) {
+	status := PreConfigureStatus{}
+	file, err := os.ReadFile(preConfigureStatusFile)
+	if os.IsNotExist(err) {
+		return status, nil
+	}
+	if err != nil {
+		return status, err
+	}
+	if err := json.Unmarshal(file, &status); err != nil {
+		return status, err
+	}
+	return status, nil
 }
 
 func sendUpHealthResponse(w http.ResponseWriter) {
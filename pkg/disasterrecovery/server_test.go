@@ -0,0 +1,47 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disasterrecovery
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseSiteManagerStatusFromRequest(t *testing.T) {
+	tests := []struct {
+		body  string
+		mode  string
+		valid bool
+	}{
+		{body: `{"mode":"active"}`, mode: "active", valid: true},
+		{body: `{"mode":"standby","no-wait":true}`, mode: "standby", valid: true},
+		{body: `{"mode":"disabled"}`, mode: "disabled", valid: true},
+		{body: `{"mode":"Active"}`},
+		{body: `{}`},
+		{body: `not json`},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/sitemanager", strings.NewReader(tt.body))
+		status, err := parseSiteManagerStatusFromRequest(req)
+		if (err == nil) != tt.valid {
+			t.Errorf("request %s: error %v, expected valid: %t", tt.body, err, tt.valid)
+			continue
+		}
+		if status.Mode != tt.mode {
+			t.Errorf("request %s: mode is %q, expected %q", tt.body, status.Mode, tt.mode)
+		}
+	}
+}
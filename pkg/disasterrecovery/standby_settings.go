@@ -0,0 +1,113 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disasterrecovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const standbySettingsKey = "standby_cluster"
+
+// standbySettingsStore keeps standby_cluster settings checked by pre-configure in a ConfigMap,
+// so the switch to standby mode uses them even if the operator is restarted in between
+type standbySettingsStore struct {
+	client    client.Client
+	name      string
+	namespace string
+	labels    map[string]string
+}
+
+func newStandbySettingsStore(kubeClient client.Client, clusterName string, labels map[string]string) *standbySettingsStore {
+	return &standbySettingsStore{
+		client:    kubeClient,
+		name:      fmt.Sprintf("pg-%s-standby-settings", clusterName),
+		namespace: namespace,
+		labels:    labels,
+	}
+}
+
+func (s *standbySettingsStore) save(ctx context.Context, settings map[string]interface{}) error {
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	configMap := &corev1.ConfigMap{}
+	err = s.client.Get(ctx, types.NamespacedName{Name: s.name, Namespace: s.namespace}, configMap)
+	if errors.IsNotFound(err) {
+		configMap = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: s.name, Namespace: s.namespace, Labels: s.labels},
+			Data:       map[string]string{standbySettingsKey: string(data)},
+		}
+		return s.client.Create(ctx, configMap)
+	}
+	if err != nil {
+		return err
+	}
+	configMap.Data = map[string]string{standbySettingsKey: string(data)}
+	return s.client.Update(ctx, configMap)
+}
+
+// load returns nil settings if pre-configure for standby mode was not done
+func (s *standbySettingsStore) load(ctx context.Context) (map[string]interface{}, error) {
+	configMap := &corev1.ConfigMap{}
+	if err := s.client.Get(ctx, types.NamespacedName{Name: s.name, Namespace: s.namespace}, configMap); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	data, ok := configMap.Data[standbySettingsKey]
+	if !ok {
+		return nil, nil
+	}
+	var settings map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &settings); err != nil {
+		return nil, fmt.Errorf("cannot parse standby settings from %s: %w", s.name, err)
+	}
+	return settings, nil
+}
+
+func (s *standbySettingsStore) clear(ctx context.Context) error {
+	configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: s.name, Namespace: s.namespace}}
+	if err := s.client.Delete(ctx, configMap); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// getStandbySource returns host and port of standby_cluster settings
+func getStandbySource(settings map[string]interface{}) (string, int, error) {
+	host, _ := settings["host"].(string)
+	var port int
+	switch value := settings["port"].(type) {
+	case int:
+		port = value
+	case float64:
+		// numbers are float64 after JSON round trip
+		port = int(value)
+	}
+	if host == "" || port == 0 {
+		return "", 0, fmt.Errorf("standby settings have no host or port: %v", settings)
+	}
+	return host, port, nil
+}
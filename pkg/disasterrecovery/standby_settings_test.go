@@ -0,0 +1,158 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disasterrecovery
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	patroniv1 "github.com/Netcracker/pgskipper-operator/api/patroni/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newFakeStore(t *testing.T) *standbySettingsStore {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+	return newStandbySettingsStore(kubeClient, "patroni", map[string]string{"app": "patroni"})
+}
+
+// fakePatroni records PATCH requests to /config of Patroni REST API
+type fakePatroni struct {
+	mutex   sync.Mutex
+	patches []map[string]interface{}
+}
+
+func (f *fakePatroni) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch || r.URL.Path != "/config" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	patch := map[string]interface{}{}
+	if err := json.Unmarshal(body, &patch); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	f.mutex.Lock()
+	f.patches = append(f.patches, patch)
+	f.mutex.Unlock()
+	_, _ = w.Write(body)
+}
+
+func TestStandbySettingsStore(t *testing.T) {
+	ctx := context.Background()
+	store := newFakeStore(t)
+
+	settings, err := store.load(ctx)
+	if err != nil || settings != nil {
+		t.Fatalf("settings before pre-configure are %v, error: %v", settings, err)
+	}
+
+	saved := map[string]interface{}{"host": "pg-patroni.cluster-1", "port": 5432, "primary_slot_name": "patroni"}
+	if err := store.save(ctx, saved); err != nil {
+		t.Fatalf("cannot save settings: %v", err)
+	}
+	updated := map[string]interface{}{"host": "pg-patroni.cluster-2", "port": 5433, "primary_slot_name": "patroni"}
+	if err := store.save(ctx, updated); err != nil {
+		t.Fatalf("cannot update settings: %v", err)
+	}
+	settings, err = store.load(ctx)
+	if err != nil {
+		t.Fatalf("cannot load settings: %v", err)
+	}
+	host, port, err := getStandbySource(settings)
+	if err != nil || host != "pg-patroni.cluster-2" || port != 5433 {
+		t.Errorf("standby source is %s:%d, error: %v", host, port, err)
+	}
+
+	if err := store.clear(ctx); err != nil {
+		t.Fatalf("cannot clear settings: %v", err)
+	}
+	if err := store.clear(ctx); err != nil {
+		t.Fatalf("clear of absent settings failed: %v", err)
+	}
+	if settings, err = store.load(ctx); err != nil || settings != nil {
+		t.Errorf("settings after clear are %v, error: %v", settings, err)
+	}
+}
+
+func TestApplyStandbySettings(t *testing.T) {
+	patroniApi := &fakePatroni{}
+	server := httptest.NewServer(patroniApi)
+	defer server.Close()
+
+	store := newFakeStore(t)
+	settings := map[string]interface{}{
+		"host":                   "pg-patroni.cluster-1",
+		"port":                   5432,
+		"primary_slot_name":      "patroni",
+		"create_replica_methods": []string{"basebackup"},
+	}
+	if err := store.save(context.Background(), settings); err != nil {
+		t.Fatal(err)
+	}
+	manager := &PatroniDRManager{
+		cluster:         &patroniv1.PatroniClusterSettings{ClusterName: "patroni", PatroniUrl: server.URL + "/"},
+		standbySettings: store,
+	}
+	if err := manager.applyStandbySettings(); err != nil {
+		t.Fatalf("cannot apply standby settings: %v", err)
+	}
+
+	if len(patroniApi.patches) != 1 {
+		t.Fatalf("Patroni received %d patches, expected 1", len(patroniApi.patches))
+	}
+	expected := map[string]interface{}{"standby_cluster": map[string]interface{}{
+		"host":                   "pg-patroni.cluster-1",
+		"port":                   float64(5432),
+		"primary_slot_name":      "patroni",
+		"create_replica_methods": []interface{}{"basebackup"},
+	}}
+	if !reflect.DeepEqual(patroniApi.patches[0], expected) {
+		t.Errorf("Patroni config patch is %v, expected %v", patroniApi.patches[0], expected)
+	}
+}
+
+func TestGetStandbySource(t *testing.T) {
+	tests := []struct {
+		settings map[string]interface{}
+		host     string
+		port     int
+		valid    bool
+	}{
+		{settings: map[string]interface{}{"host": "pg", "port": 5432}, host: "pg", port: 5432, valid: true},
+		{settings: map[string]interface{}{"host": "pg", "port": float64(6432)}, host: "pg", port: 6432, valid: true},
+		{settings: map[string]interface{}{"host": "pg"}},
+		{settings: map[string]interface{}{"port": 5432}},
+	}
+	for _, tt := range tests {
+		host, port, err := getStandbySource(tt.settings)
+		if (err == nil) != tt.valid || host != tt.host || port != tt.port {
+			t.Errorf("source of %v is %s:%d, error: %v", tt.settings, host, port, err)
+		}
+	}
+}
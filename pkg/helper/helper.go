@@ -18,12 +18,13 @@ import (
 	"context"
 	genericerror "errors"
 	"fmt"
-	"strings"
 	"time"
 
 	qubershipv1 "github.com/Netcracker/pgskipper-operator/api/apps/v1"
 	pgClient "github.com/Netcracker/pgskipper-operator/pkg/client"
 	k8sauth "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/wait"
 
 	"github.com/Netcracker/pgskipper-operator/pkg/patroni"
@@ -57,6 +58,13 @@ type Member struct {
 	Host     string
 	Port     int
 	Timeline int
+	// Lag is a number of bytes or "unknown"
+	Lag interface{}
+	// ReceiveLag and ReplayLag are reported by Patroni 4.0 and later in the same format as Lag
+	ReceiveLag interface{} `json:"receive_lag,omitempty"`
+	ReplayLag  interface{} `json:"replay_lag,omitempty"`
+	// Tags are Patroni tags of the member, for example nofailover and noloadbalance
+	Tags map[string]interface{} `json:"tags,omitempty"`
 }
 
 type Helper struct {
@@ -78,6 +86,11 @@ func GetHelper() *Helper {
 	return helper
 }
 
+// NewHelper returns helper which uses the given client instead of the shared one
+func NewHelper(kubeClient client.Client) *Helper {
+	return &Helper{ResourceManager: ResourceManager{kubeClient: kubeClient}}
+}
+
 func (h *Helper) AddNameAndUID(name string, uid types.UID, kind string) error {
 	if helper == nil {
 		message := "cannot set Name and UID, helper has not been initialized yet"
@@ -160,11 +173,12 @@ func (h *Helper) WaitUntilReconcileIsDone() error {
 			logger.Error("Error occurred during read of CR.", zap.Error(err))
 			return false, err
 		}
-		if strings.ToLower(cr.Status.Conditions[0].Type) == "in progress" {
-			logger.Info(fmt.Sprintf("Recocile status is not done yet, current status: %s.", cr.Status.Conditions[0].Type), zap.Error(err))
+		ready := meta.FindStatusCondition(cr.Status.Conditions, qubershipv1.ConditionReady)
+		if ready == nil || ready.Status == metav1.ConditionUnknown || cr.Status.ObservedGeneration < cr.Generation {
+			logger.Info("Recocile status is not done yet", zap.Int64("generation", cr.Generation), zap.Int64("observedGeneration", cr.Status.ObservedGeneration))
 			return false, nil
 		}
-		if strings.ToLower(cr.Status.Conditions[0].Type) == "failed" {
+		if ready.Status == metav1.ConditionFalse {
 			logger.Error("Recocile status failed, please fix your cluster and try again", zap.Error(err))
 			return true, genericerror.New("Reconcile status failed")
 		}
@@ -270,6 +284,19 @@ func (h *Helper) GetCurrentSiteManagerStatus() *qubershipv1.SiteManagerStatus {
 	return nil
 }
 
+// GetStandbyClusterSource returns host and port the standby cluster replicates from:
+// the upstream standby site in cascading mode, the active site otherwise
+func GetStandbyClusterSource(siteManager *qubershipv1.SiteManager) (string, int) {
+	if siteManager.UpstreamClusterHost != "" {
+		port := siteManager.UpstreamClusterPort
+		if port == 0 {
+			port = 5432
+		}
+		return siteManager.UpstreamClusterHost, port
+	}
+	return siteManager.ActiveClusterHost, siteManager.ActiveClusterPort
+}
+
 //
 //func (h *Helper) UpdateSiteManagerStatusWithRetry(mode string, status string, clusterName string, patroniUrl string, pgHost string) error {
 //	patroniReg := fmt.Sprintf("pg-%s-node", clusterName)
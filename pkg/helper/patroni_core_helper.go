@@ -17,6 +17,9 @@ package helper
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	genericerror "errors"
 	"fmt"
 	"strconv"
@@ -24,6 +27,7 @@ import (
 	"time"
 
 	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	qubershipv1 "github.com/Netcracker/pgskipper-operator/api/patroni/v1"
@@ -33,6 +37,7 @@ import (
 	"github.com/pkg/errors"
 	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes/scheme"
@@ -42,6 +47,9 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// ldapPgHbaWait is a time to wait for kubelet to update the mounted Secret with LDAP entry of pg_hba
+const ldapPgHbaWait = 3 * time.Minute
+
 var pHelper *PatroniHelper = nil
 
 type PatroniHelper struct {
@@ -63,6 +71,11 @@ func GetPatroniHelper() *PatroniHelper {
 	return pHelper
 }
 
+// NewPatroniHelper returns helper which uses the given client instead of the shared one
+func NewPatroniHelper(kubeClient client.Client) *PatroniHelper {
+	return &PatroniHelper{ResourceManager: ResourceManager{kubeClient: kubeClient}}
+}
+
 func (ph *PatroniHelper) UpdatePatroniCore(service *qubershipv1.PatroniCore) error {
 	err := ph.kubeClient.Update(context.TODO(), service)
 	if err != nil {
@@ -237,11 +250,12 @@ func (ph *PatroniHelper) WaitUntilReconcileIsDone() error {
 			logger.Error("Error occurred during read of CR.", zap.Error(err))
 			return false, err
 		}
-		if strings.ToLower(cr.Status.Conditions[0].Type) == "in progress" {
-			logger.Info(fmt.Sprintf("Recocile status is not done yet, current status: %s.", cr.Status.Conditions[0].Type), zap.Error(err))
+		ready := meta.FindStatusCondition(cr.Status.Conditions, qubershipv1.ConditionReady)
+		if ready == nil || ready.Status == metav1.ConditionUnknown || cr.Status.ObservedGeneration < cr.Generation {
+			logger.Info("Recocile status is not done yet", zap.Int64("generation", cr.Generation), zap.Int64("observedGeneration", cr.Status.ObservedGeneration))
 			return false, nil
 		}
-		if strings.ToLower(cr.Status.Conditions[0].Type) == "failed" {
+		if ready.Status == metav1.ConditionFalse {
 			logger.Error("Recocile status failed, please fix your cluster and try again", zap.Error(err))
 			return true, genericerror.New("Reconcile status failed")
 		}
@@ -287,18 +301,25 @@ func GetAllDatabases(pgC *pgClient.PostgresClient) (databases []string) {
 
 func UpdatePreloadLibraries(cr *qubershipv1.PatroniCore, preloadLibraries []string) {
 	logger.Info(fmt.Sprintf("Shared preload libraries %v will be added to config", preloadLibraries))
-	for i, param := range cr.Spec.Patroni.PostgreSQLParams {
-		param = strings.Replace(param, "=", ":", 1)
-		splittedParam := strings.Split(param, ":")
-		splittedParam[0] = strings.TrimSpace(splittedParam[0])
-		if splittedParam[0] == "shared_preload_libraries" {
-			splittedParam[1] = strings.TrimSpace(splittedParam[1])
-			for _, l := range preloadLibraries {
-				if !strings.Contains(splittedParam[1], l) {
-					splittedParam[1] = splittedParam[1] + ", " + l
-				}
+	addLibraries := func(value string) string {
+		for _, l := range preloadLibraries {
+			if !strings.Contains(value, l) {
+				value = value + ", " + l
 			}
-			cr.Spec.Patroni.PostgreSQLParams[i] = splittedParam[0] + ": " + splittedParam[1]
+		}
+		return value
+	}
+	if value, ok := cr.Spec.Patroni.PostgreSQLParameters["shared_preload_libraries"]; ok {
+		if libraries, err := patroni.ParamValue(value); err == nil {
+			raw, _ := json.Marshal(addLibraries(libraries))
+			cr.Spec.Patroni.PostgreSQLParameters["shared_preload_libraries"] = apiextensionsv1.JSON{Raw: raw}
+			return
+		}
+	}
+	for i, param := range cr.Spec.Patroni.PostgreSQLParams {
+		name, value, err := patroni.ParseParam(param)
+		if err == nil && name == "shared_preload_libraries" {
+			cr.Spec.Patroni.PostgreSQLParams[i] = name + ": " + addLibraries(value)
 		}
 	}
 	//helper.UpdatePostgresService()
@@ -306,11 +327,12 @@ func UpdatePreloadLibraries(cr *qubershipv1.PatroniCore, preloadLibraries []stri
 
 // Execute a command in a Patroni pod's specific container
 func (ph *PatroniHelper) ExecCmdOnPatroniPod(podName string, namespace string, command string) (string, string, error) {
-	var container string
-	if strings.Contains(podName, "pg-major-upgrade-check") {
-		container = "pg-upgrade-check"
-	} else {
-		container = util.GetContainerNameForPatroniPod(podName)
+	container := "pg-upgrade-check"
+	if !strings.Contains(podName, "pg-major-upgrade-check") {
+		var err error
+		if container, err = util.GetContainerNameForPatroniPod(podName); err != nil {
+			return "", "", err
+		}
 	}
 	return ph.ExecCmdOnPod(podName, namespace, container, command)
 }
@@ -452,8 +474,7 @@ func (ph *PatroniHelper) ClearStandbyClusterConfigurationConfigMap(patroniUrl st
 func (ph *PatroniHelper) getStandbyClusterConfigurationFromSiteManager() map[string]interface{} {
 	if cr, err := ph.GetPostgresServiceCR(); err == nil {
 		if coreCr, err := ph.GetPatroniCoreCR(); err == nil {
-			host := cr.Spec.SiteManager.ActiveClusterHost
-			port := cr.Spec.SiteManager.ActiveClusterPort
+			host, port := GetStandbyClusterSource(cr.Spec.SiteManager)
 			return patroni.GetStandbyClusterConfigurationWithHost(coreCr, host, port)
 		}
 		return nil
@@ -614,12 +635,81 @@ func (ph *PatroniHelper) GetStatefulSetIds(statefulsets []*appsv1.StatefulSet) (
 	ids := []int{}
 	for _, eStatefulset := range statefulsets {
 		eStatefulSetName := eStatefulset.Name
-		statefulsetIdx, err := strconv.Atoi(eStatefulSetName[len(eStatefulSetName)-1:])
+		statefulsetIdx, err := util.GetPatroniNodeIdx(eStatefulSetName)
 		if err != nil {
-			logger.Error(fmt.Sprintf("can't parse %v last symbol as int", eStatefulSetName), zap.Error(err))
+			logger.Error(fmt.Sprintf("can't parse index of %v", eStatefulSetName), zap.Error(err))
 			return nil, err
 		}
 		ids = append(ids, statefulsetIdx)
 	}
 	return ids, nil
 }
+
+// GetLdapPgHba returns LDAP entry of pg_hba of the cluster. PostgreSQL 16 and newer includes the entry from
+// patroni.LdapPgHbaSecretName mounted into Patroni pods, so the bind password isn't stored in DCS.
+// The Secret is updated and the entry is returned when all Patroni pods see the current file.
+func (ph *PatroniHelper) GetLdapPgHba(cr *qubershipv1.PatroniCore, cluster *qubershipv1.PatroniClusterSettings) (patroni.LdapPgHba, error) {
+	bindPassword, err := ph.GetLdapBindPassword(cr.Spec.Ldap)
+	if err != nil {
+		return patroni.LdapPgHba{}, err
+	}
+	ldapPgHba := patroni.LdapPgHba{BindPassword: bindPassword}
+	if cr.Spec.Ldap == nil || !cr.Spec.Ldap.Enabled {
+		return ldapPgHba, nil
+	}
+	masterPods, err := ph.GetPodsByLabel(cluster.PatroniMasterSelectors)
+	if err != nil {
+		return ldapPgHba, err
+	}
+	if len(masterPods.Items) == 0 {
+		return ldapPgHba, fmt.Errorf("master pod of %s cluster is not found", cluster.ClusterName)
+	}
+	version, err := strconv.Atoi(ph.GetPGVersion(masterPods.Items[0].Name))
+	if err != nil {
+		return ldapPgHba, fmt.Errorf("cannot read PostgreSQL version of %s: %w", masterPods.Items[0].Name, err)
+	}
+	if version < patroni.LdapPgHbaIncludeVersion {
+		return ldapPgHba, nil
+	}
+
+	entry, err := patroni.RenderLdapPgHbaFile(cr.Spec.Ldap, bindPassword)
+	if err != nil {
+		return ldapPgHba, err
+	}
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: patroni.LdapPgHbaSecretName, Namespace: namespace},
+		Data:       map[string][]byte{patroni.LdapPgHbaKey: []byte(entry)},
+	}
+	if err := ph.CreateOrUpdateSecret(secret); err != nil {
+		return ldapPgHba, err
+	}
+	sum := sha256.Sum256([]byte(entry))
+	checksum := hex.EncodeToString(sum[:])
+	if err := ph.waitForLdapPgHbaFile(cluster, checksum); err != nil {
+		return ldapPgHba, err
+	}
+	return patroni.LdapPgHba{Checksum: checksum}, nil
+}
+
+// waitForLdapPgHbaFile waits until kubelet updates LDAP entry of pg_hba mounted into Patroni pods,
+// otherwise PostgreSQL could be reloaded with the previous entry
+func (ph *PatroniHelper) waitForLdapPgHbaFile(cluster *qubershipv1.PatroniClusterSettings, checksum string) error {
+	command := fmt.Sprintf("sha256sum %s/%s | cut -d' ' -f1", patroni.LdapPgHbaPath, patroni.LdapPgHbaKey)
+	return wait.PollUntilContextTimeout(context.Background(), 5*time.Second, ldapPgHbaWait, true, func(ctx context.Context) (bool, error) {
+		pods, err := ph.GetPodsByLabel(cluster.PatroniLabels)
+		if err != nil {
+			return false, nil
+		}
+		for _, pod := range pods.Items {
+			if pod.Status.Phase != v1.PodRunning {
+				continue
+			}
+			current, _, err := ph.ExecCmdOnPatroniPod(pod.Name, namespace, command)
+			if err != nil || strings.TrimSpace(current) != checksum {
+				logger.Info(fmt.Sprintf("LDAP entry of pg_hba is not updated in %s yet", pod.Name))
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+}
@@ -29,18 +29,22 @@ import (
 	coreUtil "github.com/Netcracker/pgskipper-operator-core/pkg/util"
 	qubershipv1 "github.com/Netcracker/pgskipper-operator/api/apps/v1"
 	patroniv1 "github.com/Netcracker/pgskipper-operator/api/patroni/v1"
+	"github.com/Netcracker/pgskipper-operator/pkg/patroni"
 	"github.com/Netcracker/pgskipper-operator/pkg/util"
 	opUtil "github.com/Netcracker/pgskipper-operator/pkg/util"
 	"go.uber.org/zap"
 	appsv1 "k8s.io/api/apps/v1"
 	k8sauth "k8s.io/api/authentication/v1"
 	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -83,6 +87,34 @@ func (rm *ResourceManager) GetPatroniCoreCR() (*patroniv1.PatroniCore, error) {
 	return cr, nil
 }
 
+// UpdatePatroniCoreStatus applies mutate to the status of the latest PatroniCore,
+// the update is retried with a fresh copy of the resource on conflict
+func (rm *ResourceManager) UpdatePatroniCoreStatus(mutate func(status *patroniv1.PatroniCoreStatus)) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cr := &patroniv1.PatroniCore{}
+		if err := rm.kubeClient.Get(context.TODO(), types.NamespacedName{Name: "patroni-core", Namespace: namespace}, cr); err != nil {
+			return err
+		}
+		mutate(&cr.Status)
+		return rm.kubeClient.Status().Update(context.TODO(), cr)
+	})
+}
+
+// UpdatePostgresServiceStatus applies mutate to the status of the latest PatroniServices,
+// the update is retried with a fresh copy of the resource on conflict
+func (rm *ResourceManager) UpdatePostgresServiceStatus(mutate func(status *qubershipv1.PatroniServicesStatus)) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cr := &qubershipv1.PatroniServices{}
+		if err := rm.kubeClient.Get(context.TODO(), types.NamespacedName{
+			Name: util.GetEnv("RESOURCE_NAME", "patroni-services"), Namespace: namespace,
+		}, cr); err != nil {
+			return err
+		}
+		mutate(&cr.Status)
+		return rm.kubeClient.Status().Update(context.TODO(), cr)
+	})
+}
+
 func (rm *ResourceManager) GetConfigMap(name string) (*corev1.ConfigMap, error) {
 	foundCm := &corev1.ConfigMap{}
 	logger.Info(fmt.Sprintf("Start to check if %s cm exists", name))
@@ -255,6 +287,20 @@ func (rm *ResourceManager) UpdateService(service *corev1.Service) error {
 	return nil
 }
 
+// DeleteService deletes the service in the operator namespace, missing service is not an error
+func (rm *ResourceManager) DeleteService(name string) error {
+	service := rm.GetService(name, util.GetNameSpace())
+	if service == nil {
+		return nil
+	}
+	logger.Info(fmt.Sprintf("Deleting %s k8s service", name))
+	if err := rm.kubeClient.Delete(context.TODO(), service); err != nil && !errors.IsNotFound(err) {
+		logger.Error(fmt.Sprintf("Failed to delete service %v", name), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
 func (rm *ResourceManager) UpdateDaemonSet(ds *appsv1.DaemonSet) (err error) {
 	ds.ObjectMeta.OwnerReferences = rm.GetOwnerReferences()
 	if err := rm.kubeClient.Update(context.TODO(), ds); err != nil {
@@ -328,6 +374,36 @@ func (rm *ResourceManager) CreateOrUpdateService(service *corev1.Service) error
 	return nil
 }
 
+func (rm *ResourceManager) CreateOrUpdatePodDisruptionBudget(pdb *policyv1.PodDisruptionBudget) error {
+	foundPdb := &policyv1.PodDisruptionBudget{}
+	err := rm.kubeClient.Get(context.TODO(), types.NamespacedName{
+		Name: pdb.Name, Namespace: pdb.Namespace,
+	}, foundPdb)
+	if err != nil && errors.IsNotFound(err) {
+		logger.Info(fmt.Sprintf("Creating %s k8s PodDisruptionBudget", pdb.ObjectMeta.Name))
+		pdb.ObjectMeta.OwnerReferences = rm.GetOwnerReferences()
+		pdb.ObjectMeta.Labels = rm.getLabels(pdb.ObjectMeta)
+		if err = rm.kubeClient.Create(context.TODO(), pdb); err != nil {
+			logger.Error(fmt.Sprintf("Failed to create PodDisruptionBudget %v", pdb.ObjectMeta.Name), zap.Error(err))
+			return err
+		}
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if !equality.Semantic.DeepEqual(foundPdb.Spec, pdb.Spec) {
+		logger.Info(fmt.Sprintf("Updating %s k8s PodDisruptionBudget", pdb.ObjectMeta.Name))
+		pdb.ObjectMeta.OwnerReferences = rm.GetOwnerReferences()
+		pdb.ObjectMeta.Labels = rm.getLabels(pdb.ObjectMeta)
+		pdb.ResourceVersion = foundPdb.ResourceVersion
+		if err = rm.kubeClient.Update(context.TODO(), pdb); err != nil {
+			logger.Error(fmt.Sprintf("Failed to update PodDisruptionBudget %v", pdb.ObjectMeta.Name), zap.Error(err))
+			return err
+		}
+	}
+	return nil
+}
+
 // This method performs delete and re-create deployment in case update was failed
 func (rm *ResourceManager) CreateOrUpdateDeploymentForce(deployment *appsv1.Deployment, waitStability bool) error {
 	if err := rm.CreateOrUpdateDeployment(deployment, true); err != nil {
@@ -577,6 +653,111 @@ func (rm *ResourceManager) CreateEndpointIfNotExists(endpoint *corev1.Endpoints)
 	return nil
 }
 
+// CreateOrUpdateEndpoints updates addresses and ports of the endpoints, it's used for services without selector
+func (rm *ResourceManager) CreateOrUpdateEndpoints(endpoints *corev1.Endpoints) error {
+	foundEndpoints := &corev1.Endpoints{}
+	err := rm.kubeClient.Get(context.TODO(), types.NamespacedName{
+		Name: endpoints.Name, Namespace: endpoints.Namespace,
+	}, foundEndpoints)
+	if err != nil && errors.IsNotFound(err) {
+		logger.Info(fmt.Sprintf("Creating %s k8s endpoints", endpoints.ObjectMeta.Name))
+		endpoints.ObjectMeta.OwnerReferences = rm.GetOwnerReferences()
+		if err = rm.kubeClient.Create(context.TODO(), endpoints); err != nil {
+			logger.Error(fmt.Sprintf("Failed to create endpoints %s", endpoints.ObjectMeta.Name), zap.Error(err))
+			return err
+		}
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if equality.Semantic.DeepEqual(foundEndpoints.Subsets, endpoints.Subsets) &&
+		maps.Equal(foundEndpoints.Labels, endpoints.Labels) {
+		return nil
+	}
+	logger.Info(fmt.Sprintf("Updating %s k8s endpoints", endpoints.ObjectMeta.Name))
+	foundEndpoints.Labels = endpoints.Labels
+	foundEndpoints.Subsets = endpoints.Subsets
+	if err = rm.kubeClient.Update(context.TODO(), foundEndpoints); err != nil {
+		logger.Error(fmt.Sprintf("Failed to update endpoints %s", endpoints.ObjectMeta.Name), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// CreateOrUpdateEndpointSlice updates endpoints and ports of EndpointSlice managed by the operator,
+// the slice is recreated if its address type is changed, as it's immutable
+func (rm *ResourceManager) CreateOrUpdateEndpointSlice(slice *discoveryv1.EndpointSlice) error {
+	foundSlice := &discoveryv1.EndpointSlice{}
+	err := rm.kubeClient.Get(context.TODO(), types.NamespacedName{
+		Name: slice.Name, Namespace: slice.Namespace,
+	}, foundSlice)
+	if err == nil && foundSlice.AddressType != slice.AddressType {
+		logger.Info(fmt.Sprintf("Address type of EndpointSlice %s is changed to %s, recreating it", slice.Name, slice.AddressType))
+		if err = rm.kubeClient.Delete(context.TODO(), foundSlice); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		err = errors.NewNotFound(discoveryv1.Resource("endpointslices"), slice.Name)
+	}
+	if err != nil && errors.IsNotFound(err) {
+		logger.Info(fmt.Sprintf("Creating %s EndpointSlice", slice.Name))
+		slice.ObjectMeta.OwnerReferences = rm.GetOwnerReferences()
+		if err = rm.kubeClient.Create(context.TODO(), slice); err != nil {
+			logger.Error(fmt.Sprintf("Failed to create EndpointSlice %s", slice.Name), zap.Error(err))
+			return err
+		}
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if equality.Semantic.DeepEqual(foundSlice.Endpoints, slice.Endpoints) &&
+		equality.Semantic.DeepEqual(foundSlice.Ports, slice.Ports) && maps.Equal(foundSlice.Labels, slice.Labels) {
+		return nil
+	}
+	logger.Info(fmt.Sprintf("Updating %s EndpointSlice", slice.Name))
+	foundSlice.Labels = slice.Labels
+	foundSlice.Endpoints = slice.Endpoints
+	foundSlice.Ports = slice.Ports
+	if err = rm.kubeClient.Update(context.TODO(), foundSlice); err != nil {
+		logger.Error(fmt.Sprintf("Failed to update EndpointSlice %s", slice.Name), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// DeleteEndpointSlice deletes EndpointSlice managed by the operator if it exists
+func (rm *ResourceManager) DeleteEndpointSlice(name string) error {
+	slice := &discoveryv1.EndpointSlice{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}}
+	if err := rm.kubeClient.Delete(context.TODO(), slice); err != nil && !errors.IsNotFound(err) {
+		logger.Error(fmt.Sprintf("Failed to delete EndpointSlice %s", name), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// DeleteControllerEndpointSlices deletes EndpointSlices made by Kubernetes for the service,
+// they are left after the selector of the service is removed
+func (rm *ResourceManager) DeleteControllerEndpointSlices(serviceName string) error {
+	sliceList := &discoveryv1.EndpointSliceList{}
+	listOpts := []client.ListOption{
+		client.InNamespace(namespace),
+		client.MatchingLabels{
+			discoveryv1.LabelServiceName: serviceName,
+			discoveryv1.LabelManagedBy:   "endpointslice-controller.k8s.io",
+		},
+	}
+	if err := rm.kubeClient.List(context.TODO(), sliceList, listOpts...); err != nil {
+		return err
+	}
+	for idx := range sliceList.Items {
+		slice := &sliceList.Items[idx]
+		logger.Info(fmt.Sprintf("Deleting EndpointSlice %s of service %s", slice.Name, serviceName))
+		if err := rm.kubeClient.Delete(context.TODO(), slice); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
 func (rm *ResourceManager) DeletePodsByLabel(selectors map[string]string) (err error) {
 	podList := &corev1.PodList{}
 	listOpts := []client.ListOption{
@@ -649,6 +830,27 @@ func (rm *ResourceManager) DeleteDeployment(deploymentName string) error {
 	return nil
 }
 
+func (rm *ResourceManager) DeleteStatefulset(statefulSet *appsv1.StatefulSet) error {
+	if err := rm.kubeClient.Delete(context.TODO(), statefulSet); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		logger.Error(fmt.Sprintf("error during StatefulSet deletion %v", statefulSet.Name), zap.Error(err))
+		return err
+	}
+	return wait.PollUntilContextTimeout(context.Background(), 10*time.Second, 10*time.Minute, true, func(ctx context.Context) (done bool, err error) {
+		found := &appsv1.StatefulSet{}
+		err = rm.kubeClient.Get(context.TODO(), types.NamespacedName{
+			Name: statefulSet.Name, Namespace: statefulSet.Namespace,
+		}, found)
+		if errors.IsNotFound(err) {
+			return true, nil
+		}
+		logger.Info(fmt.Sprintf("statefulSet %s still exists, retrying", statefulSet.Name))
+		return false, nil
+	})
+}
+
 func (rm *ResourceManager) GetOwnerReferences() []metav1.OwnerReference {
 	controller := true
 	block := true
@@ -783,6 +985,64 @@ func (rm *ResourceManager) GetSecret(secretName string) (*corev1.Secret, error)
 	return foundSecret, nil
 }
 
+// ConfigurePatroniClient configures Patroni REST API client with credentials and certificates from secrets referenced in CR
+func (rm *ResourceManager) ConfigurePatroniClient(cr *patroniv1.PatroniCore) error {
+	config := patroni.RestApiConfig{}
+	if cr.Spec.Patroni == nil || cr.Spec.Patroni.RestApi == nil {
+		return patroni.ConfigureClient(config)
+	}
+	restApi := cr.Spec.Patroni.RestApi
+	if restApi.AuthSecret != "" {
+		secret, err := rm.GetSecret(restApi.AuthSecret)
+		if err != nil {
+			return err
+		}
+		config.Username = string(secret.Data["username"])
+		config.Password = string(secret.Data["password"])
+		if config.Username == "" || config.Password == "" {
+			return fmt.Errorf("secret %s should contain username and password for Patroni REST API", restApi.AuthSecret)
+		}
+	}
+	if restApi.Tls {
+		if cr.Spec.Tls == nil || !cr.Spec.Tls.Enabled || cr.Spec.Tls.CertificateSecretName == "" {
+			return fmt.Errorf("TLS for Patroni REST API requires tls.enabled and tls.certificateSecretName")
+		}
+		secret, err := rm.GetSecret(cr.Spec.Tls.CertificateSecretName)
+		if err != nil {
+			return err
+		}
+		if len(secret.Data["ca.crt"]) == 0 {
+			return fmt.Errorf("secret %s should contain ca.crt to verify Patroni REST API certificate", cr.Spec.Tls.CertificateSecretName)
+		}
+		config.TLS = true
+		config.CACert = secret.Data["ca.crt"]
+		config.ClientCert = secret.Data["tls.crt"]
+		config.ClientKey = secret.Data["tls.key"]
+	}
+	return patroni.ConfigureClient(config)
+}
+
+// GetLdapBindPassword returns LDAP bind password from the Secret referenced in CR,
+// deprecated bindpasswd is used if the Secret isn't set
+func (rm *ResourceManager) GetLdapBindPassword(ldap *patroniv1.LdapConfig) (string, error) {
+	if ldap == nil || ldap.BindPasswordSecretName == "" {
+		if ldap != nil && ldap.BindPasswd != "" {
+			logger.Warn("ldap.bindpasswd is deprecated, store the password in a Secret and set ldap.bindPasswordSecretName")
+			return ldap.BindPasswd, nil
+		}
+		return "", nil
+	}
+	secret, err := rm.GetSecret(ldap.BindPasswordSecretName)
+	if err != nil {
+		return "", err
+	}
+	password := string(secret.Data["password"])
+	if password == "" {
+		return "", fmt.Errorf("secret %s should contain password for LDAP bind", ldap.BindPasswordSecretName)
+	}
+	return password, nil
+}
+
 func (rm *ResourceManager) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
@@ -910,9 +1170,7 @@ func (ph *PatroniHelper) IsHealthyWithTimeoutDuringUpdate(timeout time.Duration,
 }
 
 func (rm *ResourceManager) GetPatroniClusterConfig(patroniUrl string) (*ClusterStatus, error) {
-	httpC := http.Client{
-		Timeout: 5 * time.Second,
-	}
+	httpC := patroni.NewHttpClient(5 * time.Second)
 	resp, err := httpC.Get(patroniUrl + "cluster")
 	if err != nil {
 		logger.Error("Get request to patroni cluster failed, retrying")
@@ -0,0 +1,169 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helper
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	patroniv1 "github.com/Netcracker/pgskipper-operator/api/patroni/v1"
+	_ "github.com/Netcracker/pgskipper-operator/pkg/util/testenv"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func newPatroniCoreFakeClient(t *testing.T, funcs interceptor.Funcs, objects ...client.Object) client.Client {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := patroniv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).
+		WithStatusSubresource(&patroniv1.PatroniCore{}).WithInterceptorFuncs(funcs).Build()
+}
+
+func TestUpdatePatroniCoreStatusRetriesOnConflict(t *testing.T) {
+	cr := &patroniv1.PatroniCore{ObjectMeta: metav1.ObjectMeta{Name: "patroni-core", Namespace: namespace}}
+	conflicts := 0
+	kubeClient := newPatroniCoreFakeClient(t, interceptor.Funcs{
+		SubResourceUpdate: func(ctx context.Context, c client.Client, subResource string, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+			if conflicts == 0 {
+				conflicts++
+				// status is changed by another writer after the resource was read
+				concurrent := &patroniv1.PatroniCore{}
+				if err := c.Get(ctx, client.ObjectKeyFromObject(obj), concurrent); err != nil {
+					return err
+				}
+				concurrent.Status.Switchover = &patroniv1.SwitchoverStatus{Phase: "Succeeded"}
+				if err := c.Status().Update(ctx, concurrent); err != nil {
+					return err
+				}
+				return errors.NewConflict(schema.GroupResource{Resource: "patronicores"}, obj.GetName(), nil)
+			}
+			return c.SubResource(subResource).Update(ctx, obj, opts...)
+		},
+	}, cr)
+	rm := &ResourceManager{kubeClient: kubeClient}
+
+	calls := 0
+	if err := rm.UpdatePatroniCoreStatus(func(status *patroniv1.PatroniCoreStatus) {
+		calls++
+		status.ReadOnlyService = &patroniv1.ReadOnlyServiceStatus{Members: []string{"pg-patroni-node2-0"}}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Errorf("mutate is called %d times, expected 2", calls)
+	}
+
+	updated := &patroniv1.PatroniCore{}
+	if err := kubeClient.Get(context.Background(), types.NamespacedName{Name: "patroni-core", Namespace: namespace}, updated); err != nil {
+		t.Fatal(err)
+	}
+	if updated.Status.ReadOnlyService == nil || len(updated.Status.ReadOnlyService.Members) != 1 {
+		t.Errorf("read-only service status is not updated: %+v", updated.Status.ReadOnlyService)
+	}
+	if updated.Status.Switchover == nil || updated.Status.Switchover.Phase != "Succeeded" {
+		t.Errorf("concurrent switchover status is lost: %+v", updated.Status.Switchover)
+	}
+}
+
+func TestUpdatePatroniCoreStatusNotFound(t *testing.T) {
+	rm := &ResourceManager{kubeClient: newPatroniCoreFakeClient(t, interceptor.Funcs{})}
+	err := rm.UpdatePatroniCoreStatus(func(status *patroniv1.PatroniCoreStatus) {
+		t.Error("mutate is called without PatroniCore")
+	})
+	if !errors.IsNotFound(err) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+}
+
+func TestCreateOrUpdateEndpointSlice(t *testing.T) {
+	kubeClient := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build()
+	rm := &ResourceManager{kubeClient: kubeClient}
+	newSlice := func(addressType discoveryv1.AddressType, addresses ...string) *discoveryv1.EndpointSlice {
+		slice := &discoveryv1.EndpointSlice{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "pg-patroni-ro",
+				Namespace: namespace,
+				Labels:    map[string]string{discoveryv1.LabelServiceName: "pg-patroni-ro"},
+			},
+			AddressType: addressType,
+			Ports:       []discoveryv1.EndpointPort{{Name: ptr.To("pg"), Port: ptr.To[int32](5432)}},
+		}
+		for _, address := range addresses {
+			slice.Endpoints = append(slice.Endpoints, discoveryv1.Endpoint{Addresses: []string{address}})
+		}
+		return slice
+	}
+	getSlice := func() *discoveryv1.EndpointSlice {
+		slice := &discoveryv1.EndpointSlice{}
+		if err := kubeClient.Get(context.Background(), types.NamespacedName{Name: "pg-patroni-ro", Namespace: namespace}, slice); err != nil {
+			t.Fatal(err)
+		}
+		return slice
+	}
+
+	if err := rm.CreateOrUpdateEndpointSlice(newSlice(discoveryv1.AddressTypeIPv4, "10.0.0.2")); err != nil {
+		t.Fatal(err)
+	}
+	if err := rm.CreateOrUpdateEndpointSlice(newSlice(discoveryv1.AddressTypeIPv4, "10.0.0.2", "10.0.0.3")); err != nil {
+		t.Fatal(err)
+	}
+	if slice := getSlice(); len(slice.Endpoints) != 2 {
+		t.Errorf("endpoints are not updated: %+v", slice.Endpoints)
+	}
+
+	if err := rm.CreateOrUpdateEndpointSlice(newSlice(discoveryv1.AddressTypeIPv6, "fd00::2")); err != nil {
+		t.Fatal(err)
+	}
+	if slice := getSlice(); slice.AddressType != discoveryv1.AddressTypeIPv6 || len(slice.Endpoints) != 1 {
+		t.Errorf("slice is not recreated with IPv6 address type: %+v", slice)
+	}
+
+	if err := rm.DeleteEndpointSlice("pg-patroni-ro"); err != nil {
+		t.Fatal(err)
+	}
+	if err := rm.DeleteEndpointSlice("pg-patroni-ro"); err != nil {
+		t.Errorf("deletion of missing slice failed: %v", err)
+	}
+}
+
+func TestConfigurePatroniClientRequiresCA(t *testing.T) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "pg-cert", Namespace: namespace},
+		Data:       map[string][]byte{"tls.crt": []byte("cert"), "tls.key": []byte("key")},
+	}
+	rm := &ResourceManager{kubeClient: fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(secret).Build()}
+	cr := &patroniv1.PatroniCore{Spec: &patroniv1.PatroniCoreSpec{
+		Tls:     &patroniv1.Tls{Enabled: true, CertificateSecretName: "pg-cert"},
+		Patroni: &patroniv1.Patroni{RestApi: &patroniv1.RestApi{Tls: true}},
+	}}
+	err := rm.ConfigurePatroniClient(cr)
+	if err == nil || !strings.Contains(err.Error(), "ca.crt") {
+		t.Errorf("expected error about missing ca.crt, got %v", err)
+	}
+}
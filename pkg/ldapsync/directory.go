@@ -0,0 +1,167 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ldapsync
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	patroniv1 "github.com/Netcracker/pgskipper-operator/api/patroni/v1"
+	"github.com/go-ldap/ldap/v3"
+)
+
+const (
+	defaultMemberAttribute = "member"
+	// defaultUserAttribute is ldapsearchattribute default of PostgreSQL
+	defaultUserAttribute = "uid"
+	ldapTimeout          = 30 * time.Second
+)
+
+// Directory is a part of LDAP client used by the group synchronization
+type Directory interface {
+	// GroupMembers returns usernames of members of the group
+	GroupMembers(groupDN string) ([]string, error)
+	Close()
+}
+
+type ldapDirectory struct {
+	conn            *ldap.Conn
+	memberAttribute string
+	userAttribute   string
+}
+
+// Connect binds to LDAP server of the config, caCert verifies certificate of the server
+// for ldaps scheme and StartTLS. Only the first of space separated servers is used.
+func Connect(config *patroniv1.LdapConfig, bindPassword string, caCert []byte) (Directory, error) {
+	servers := strings.Fields(config.Server)
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("LDAP server is not set")
+	}
+	scheme := config.Scheme
+	if scheme == "" {
+		scheme = "ldap"
+	}
+	port := config.Port
+	if port == 0 {
+		port = 389
+		if scheme == "ldaps" {
+			port = 636
+		}
+	}
+
+	tlsConfig := &tls.Config{ServerName: servers[0], MinVersion: tls.VersionTLS12}
+	if len(caCert) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("CA bundle of LDAP server doesn't contain PEM certificates")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	url := fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(servers[0], strconv.Itoa(port)))
+	conn, err := ldap.DialURL(url, ldap.DialWithTLSConfig(tlsConfig), ldap.DialWithDialer(&net.Dialer{Timeout: ldapTimeout}))
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(ldapTimeout)
+	if config.StartTLS && scheme == "ldap" {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if config.BindDN != "" {
+		if err := conn.Bind(config.BindDN, bindPassword); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("cannot bind to LDAP as %s: %w", config.BindDN, err)
+		}
+	}
+
+	directory := &ldapDirectory{
+		conn:            conn,
+		memberAttribute: defaultMemberAttribute,
+		userAttribute:   defaultUserAttribute,
+	}
+	if config.GroupSync != nil && config.GroupSync.MemberAttribute != "" {
+		directory.memberAttribute = config.GroupSync.MemberAttribute
+	}
+	if config.LdapSearchAttr != "" {
+		directory.userAttribute = config.LdapSearchAttr
+	}
+	return directory, nil
+}
+
+func (d *ldapDirectory) GroupMembers(groupDN string) ([]string, error) {
+	entry, err := d.getEntry(groupDN, d.memberAttribute)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, fmt.Errorf("group %s is not found", groupDN)
+	}
+	var members []string
+	for _, value := range entry.GetAttributeValues(d.memberAttribute) {
+		if value == "" {
+			continue
+		}
+		if !isDN(value) {
+			// memberUid and similar attributes keep usernames
+			members = append(members, value)
+			continue
+		}
+		member, err := d.getEntry(value, d.userAttribute)
+		if err != nil {
+			return nil, err
+		}
+		// nested groups and entries without the search attribute aren't users
+		if member == nil || member.GetAttributeValue(d.userAttribute) == "" {
+			continue
+		}
+		members = append(members, member.GetAttributeValue(d.userAttribute))
+	}
+	return members, nil
+}
+
+func (d *ldapDirectory) Close() {
+	d.conn.Close()
+}
+
+// getEntry returns entry with the attribute by DN or nil if the entry doesn't exist
+func (d *ldapDirectory) getEntry(dn string, attribute string) (*ldap.Entry, error) {
+	request := ldap.NewSearchRequest(dn, ldap.ScopeBaseObject, ldap.NeverDerefAliases, 1, int(ldapTimeout.Seconds()),
+		false, "(objectClass=*)", []string{attribute}, nil)
+	result, err := d.conn.Search(request)
+	var ldapErr *ldap.Error
+	if errors.As(err, &ldapErr) && ldapErr.ResultCode == ldap.LDAPResultNoSuchObject {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Entries) == 0 {
+		return nil, nil
+	}
+	return result.Entries[0], nil
+}
+
+func isDN(value string) bool {
+	dn, err := ldap.ParseDN(value)
+	return err == nil && len(dn.RDNs) > 0
+}
@@ -0,0 +1,162 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ldapsync
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	patroniv1 "github.com/Netcracker/pgskipper-operator/api/patroni/v1"
+	pgClient "github.com/Netcracker/pgskipper-operator/pkg/client"
+	"github.com/Netcracker/pgskipper-operator/pkg/util"
+)
+
+// LdapRole is a role of LDAP users, pg_hba authenticates its members in LDAP
+const LdapRole = "pgadminrole"
+
+const (
+	roleExistsQuery = "SELECT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = $1)"
+	isMemberQuery   = `SELECT EXISTS (SELECT 1 FROM pg_auth_members am
+		JOIN pg_roles r ON r.oid = am.roleid JOIN pg_roles m ON m.oid = am.member
+		WHERE r.rolname = $1 AND m.rolname = $2)`
+	// ldapMembersQuery returns members of the role which are LDAP users
+	ldapMembersQuery = `SELECT m.rolname FROM pg_auth_members am
+		JOIN pg_roles r ON r.oid = am.roleid JOIN pg_roles m ON m.oid = am.member
+		WHERE r.rolname = $1 AND am.member IN (SELECT a.member FROM pg_auth_members a
+			JOIN pg_roles l ON l.oid = a.roleid WHERE l.rolname = $2)`
+)
+
+var logger = util.GetLogger()
+
+// SyncGroups creates roles of the mappings and login roles of group members, grants the mapped roles
+// to the members and revokes them from LDAP users which aren't members of the groups anymore.
+// Only LDAP users, i.e. members of pgadminrole, are managed, users are never dropped.
+// It returns the number of synchronized members of the mapped roles.
+func SyncGroups(directory Directory, conn pgClient.Querier, mappings []patroniv1.LdapGroupMapping) (int, error) {
+	var roles []string
+	desired := map[string]map[string]bool{}
+	for _, mapping := range mappings {
+		members, err := directory.GroupMembers(mapping.Group)
+		if err != nil {
+			return 0, fmt.Errorf("cannot get members of LDAP group %s: %w", mapping.Group, err)
+		}
+		if _, ok := desired[mapping.Role]; !ok {
+			desired[mapping.Role] = map[string]bool{}
+			roles = append(roles, mapping.Role)
+		}
+		for _, member := range members {
+			desired[mapping.Role][member] = true
+		}
+	}
+
+	synced := 0
+	for _, role := range roles {
+		if err := createRole(conn, role, "CREATE ROLE %I NOLOGIN"); err != nil {
+			return synced, err
+		}
+		current, err := getLdapMembers(conn, role)
+		if err != nil {
+			return synced, err
+		}
+		for _, user := range sortedKeys(desired[role]) {
+			managed, err := ensureLdapUser(conn, user)
+			if err != nil {
+				return synced, err
+			}
+			if !managed {
+				logger.Warn(fmt.Sprintf("Role %s exists and isn't a member of %s, %s isn't granted to it", user, LdapRole, role))
+				continue
+			}
+			if !current[user] {
+				if err := pgClient.ExecFormat(conn, "GRANT %I TO %I", role, user); err != nil {
+					return synced, err
+				}
+				logger.Info(fmt.Sprintf("Role %s is granted to LDAP user %s", role, user))
+			}
+			synced++
+		}
+		for _, user := range sortedKeys(current) {
+			if desired[role][user] {
+				continue
+			}
+			if err := pgClient.ExecFormat(conn, "REVOKE %I FROM %I", role, user); err != nil {
+				return synced, err
+			}
+			logger.Info(fmt.Sprintf("Role %s is revoked from LDAP user %s", role, user))
+		}
+	}
+	return synced, nil
+}
+
+// ensureLdapUser creates login role of LDAP user if it doesn't exist,
+// it returns false if the role exists and isn't a member of pgadminrole
+func ensureLdapUser(conn pgClient.Querier, user string) (bool, error) {
+	exists, err := queryBool(conn, roleExistsQuery, user)
+	if err != nil {
+		return false, err
+	}
+	if exists {
+		return queryBool(conn, isMemberQuery, LdapRole, user)
+	}
+	if err := createRole(conn, user, "CREATE ROLE %I WITH LOGIN"); err != nil {
+		return false, err
+	}
+	if err := pgClient.ExecFormat(conn, "GRANT %I TO %I", LdapRole, user); err != nil {
+		return false, err
+	}
+	logger.Info(fmt.Sprintf("LDAP user %s is created", user))
+	return true, nil
+}
+
+func createRole(conn pgClient.Querier, role string, format string) error {
+	exists, err := queryBool(conn, roleExistsQuery, role)
+	if err != nil || exists {
+		return err
+	}
+	return pgClient.ExecFormat(conn, format, role)
+}
+
+func getLdapMembers(conn pgClient.Querier, role string) (map[string]bool, error) {
+	rows, err := conn.Query(context.Background(), ldapMembersQuery, role, LdapRole)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	members := map[string]bool{}
+	for rows.Next() {
+		var member string
+		if err := rows.Scan(&member); err != nil {
+			return nil, err
+		}
+		members[member] = true
+	}
+	return members, rows.Err()
+}
+
+func queryBool(conn pgClient.Querier, query string, args ...interface{}) (bool, error) {
+	var result bool
+	err := conn.QueryRow(context.Background(), query, args...).Scan(&result)
+	return result, err
+}
+
+func sortedKeys(values map[string]bool) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
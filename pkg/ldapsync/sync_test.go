@@ -0,0 +1,192 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ldapsync
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"testing"
+
+	patroniv1 "github.com/Netcracker/pgskipper-operator/api/patroni/v1"
+	pgClient "github.com/Netcracker/pgskipper-operator/pkg/client"
+	"github.com/Netcracker/pgskipper-operator/pkg/util/testenv"
+)
+
+// testPgC is a client of throwaway PostgreSQL started for tests of the package, it's nil if PostgreSQL is not available
+var testPgC *pgClient.PostgresClient
+
+func TestMain(m *testing.M) {
+	os.Exit(runTests(m))
+}
+
+func runTests(m *testing.M) int {
+	pg, err := testenv.StartPostgres()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Tests with PostgreSQL are skipped: %v\n", err)
+		return m.Run()
+	}
+	defer pg.Stop()
+	if testPgC = pgClient.GetPostgresClientForHostAndPort(pg.Host, pg.Port); testPgC == nil {
+		fmt.Fprintf(os.Stderr, "Can't connect to PostgreSQL on %s:%d\n", pg.Host, pg.Port)
+		return 1
+	}
+	defer testPgC.Close()
+	return m.Run()
+}
+
+// fakeDirectory returns members of groups by DN
+type fakeDirectory struct {
+	groups map[string][]string
+}
+
+func (d *fakeDirectory) GroupMembers(groupDN string) ([]string, error) {
+	members, ok := d.groups[groupDN]
+	if !ok {
+		return nil, fmt.Errorf("group %s is not found", groupDN)
+	}
+	return members, nil
+}
+
+func (d *fakeDirectory) Close() {}
+
+func requireConnection(t *testing.T) pgClient.Querier {
+	t.Helper()
+	if testPgC == nil {
+		t.Skip("PostgreSQL is not available")
+	}
+	conn, err := testPgC.GetConnection()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(conn.Release)
+	return conn
+}
+
+func mustExec(t *testing.T, conn pgClient.Querier, query string) {
+	t.Helper()
+	if _, err := conn.Exec(context.Background(), query); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+}
+
+func memberOf(t *testing.T, conn pgClient.Querier, role string, user string) bool {
+	t.Helper()
+	member, err := queryBool(conn, isMemberQuery, role, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return member
+}
+
+func TestSyncGroups(t *testing.T) {
+	conn := requireConnection(t)
+	mustExec(t, conn, "CREATE ROLE pgadminrole NOLOGIN")
+	mustExec(t, conn, "CREATE ROLE ldap_dba NOLOGIN")
+	// LDAP user which is removed from the group
+	mustExec(t, conn, "CREATE ROLE ldap_former LOGIN IN ROLE pgadminrole, ldap_dba")
+	// local roles are not managed even if they have the same names as LDAP users
+	mustExec(t, conn, "CREATE ROLE ldap_local LOGIN")
+	mustExec(t, conn, "CREATE ROLE ldap_local_dba LOGIN IN ROLE ldap_dba")
+
+	directory := &fakeDirectory{groups: map[string][]string{
+		"cn=dba,ou=groups,dc=example,dc=com":     {"ldap_alice", "ldap_local"},
+		"cn=readers,ou=groups,dc=example,dc=com": {"ldap_alice", "ldap_bob"},
+	}}
+	mappings := []patroniv1.LdapGroupMapping{
+		{Group: "cn=dba,ou=groups,dc=example,dc=com", Role: "ldap_dba"},
+		{Group: "cn=readers,ou=groups,dc=example,dc=com", Role: "ldap_readers"},
+	}
+
+	synced, err := SyncGroups(directory, conn, mappings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if synced != 3 {
+		t.Errorf("synced %d members, expected 3", synced)
+	}
+	for _, grant := range []struct {
+		role   string
+		user   string
+		member bool
+	}{
+		{role: "pgadminrole", user: "ldap_alice", member: true},
+		{role: "pgadminrole", user: "ldap_bob", member: true},
+		{role: "ldap_dba", user: "ldap_alice", member: true},
+		{role: "ldap_readers", user: "ldap_alice", member: true},
+		{role: "ldap_readers", user: "ldap_bob", member: true},
+		{role: "ldap_dba", user: "ldap_bob", member: false},
+		{role: "ldap_dba", user: "ldap_former", member: false},
+		{role: "pgadminrole", user: "ldap_former", member: true},
+		{role: "ldap_dba", user: "ldap_local", member: false},
+		{role: "pgadminrole", user: "ldap_local", member: false},
+		{role: "ldap_dba", user: "ldap_local_dba", member: true},
+	} {
+		if member := memberOf(t, conn, grant.role, grant.user); member != grant.member {
+			t.Errorf("%s is member of %s: %t, expected %t", grant.user, grant.role, member, grant.member)
+		}
+	}
+	canLogin, err := queryBool(conn, "SELECT rolcanlogin FROM pg_roles WHERE rolname = $1", "ldap_readers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if canLogin {
+		t.Error("role of the mapping is created with LOGIN")
+	}
+
+	// the second run doesn't change anything
+	synced, err = SyncGroups(directory, conn, mappings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if synced != 3 {
+		t.Errorf("synced %d members on the second run, expected 3", synced)
+	}
+
+	directory.groups["cn=readers,ou=groups,dc=example,dc=com"] = []string{"ldap_bob"}
+	if _, err := SyncGroups(directory, conn, mappings); err != nil {
+		t.Fatal(err)
+	}
+	if memberOf(t, conn, "ldap_readers", "ldap_alice") {
+		t.Error("ldap_readers isn't revoked from ldap_alice removed from the group")
+	}
+	if !memberOf(t, conn, "ldap_dba", "ldap_alice") {
+		t.Error("ldap_dba is revoked from ldap_alice")
+	}
+}
+
+func TestSyncGroupsDirectoryError(t *testing.T) {
+	conn := requireConnection(t)
+	directory := &fakeDirectory{groups: map[string][]string{}}
+	mappings := []patroniv1.LdapGroupMapping{{Group: "cn=missing,dc=example,dc=com", Role: "ldap_missing"}}
+	if _, err := SyncGroups(directory, conn, mappings); err == nil {
+		t.Fatal("error of LDAP directory is not returned")
+	}
+	exists, err := queryBool(conn, roleExistsQuery, "ldap_missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Error("role is created when members of the group are unknown")
+	}
+}
+
+func TestSortedKeys(t *testing.T) {
+	keys := sortedKeys(map[string]bool{"b": true, "a": false, "c": true})
+	if !slices.Equal(keys, []string{"a", "b", "c"}) {
+		t.Errorf("got %v", keys)
+	}
+}
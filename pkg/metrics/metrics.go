@@ -0,0 +1,121 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"strings"
+	"time"
+
+	"github.com/Netcracker/pgskipper-operator/pkg/util"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	namespace = "pgskipper"
+
+	ResultSuccess = "success"
+	ResultError   = "error"
+
+	// ComponentAll is used for the whole reconcile cycle of CR
+	ComponentAll = "all"
+
+	// DefaultBindAddress is used by metrics endpoint if it's enabled
+	DefaultBindAddress = ":8383"
+)
+
+var (
+	logger = util.GetLogger()
+
+	reconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "operator",
+		Name:      "reconcile_duration_seconds",
+		Help:      "Duration of reconcile cycle per CR kind and component.",
+		Buckets:   []float64{0.5, 1, 5, 15, 30, 60, 120, 300, 600, 1200, 3600},
+	}, []string{"kind", "component"})
+
+	reconcileTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "operator",
+		Name:      "reconcile_total",
+		Help:      "Number of reconcile cycles per CR kind, component and result.",
+	}, []string{"kind", "component", "result"})
+
+	lastReconcileSuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "operator",
+		Name:      "reconcile_last_success_timestamp_seconds",
+		Help:      "Time of the last successful reconcile per CR kind and component.",
+	}, []string{"kind", "component"})
+
+	stanzaUpgradeLastSuccess = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "pgbackrest",
+		Name:      "stanza_upgrade_last_success_timestamp_seconds",
+		Help:      "Time of the last successful pgBackRest stanza-upgrade.",
+	})
+
+	credentialsLastRotation = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "credentials",
+		Name:      "last_rotation_timestamp_seconds",
+		Help:      "Time of the last successful credentials rotation per secret.",
+	}, []string{"secret"})
+)
+
+func init() {
+	crmetrics.Registry.MustRegister(
+		reconcileDuration,
+		reconcileTotal,
+		lastReconcileSuccess,
+		stanzaUpgradeLastSuccess,
+		credentialsLastRotation,
+	)
+}
+
+// IsEnabled returns true if operator metrics endpoint should be started
+func IsEnabled() bool {
+	return strings.ToLower(util.GetEnv("OPERATOR_METRICS_ENABLED", "false")) == "true"
+}
+
+// Register adds collector to the registry served by the manager metrics endpoint
+func Register(collector prometheus.Collector) {
+	if err := crmetrics.Registry.Register(collector); err != nil {
+		logger.Warn("Cannot register metrics collector", zap.Error(err))
+	}
+}
+
+// ObserveReconcile records duration and outcome of reconcile for CR kind and component
+func ObserveReconcile(kind, component string, duration time.Duration, err error) {
+	result := ResultSuccess
+	if err != nil {
+		result = ResultError
+	}
+	reconcileDuration.WithLabelValues(kind, component).Observe(duration.Seconds())
+	reconcileTotal.WithLabelValues(kind, component, result).Inc()
+	if err == nil {
+		lastReconcileSuccess.WithLabelValues(kind, component).SetToCurrentTime()
+	}
+}
+
+func StanzaUpgradeSucceeded() {
+	stanzaUpgradeLastSuccess.SetToCurrentTime()
+}
+
+func CredentialsRotated(secretName string) {
+	credentialsLastRotation.WithLabelValues(secretName).SetToCurrentTime()
+}
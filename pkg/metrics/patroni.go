@@ -0,0 +1,131 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"sync"
+
+	"github.com/Netcracker/pgskipper-operator/pkg/helper"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+const leaderRole = "leader"
+
+// ClusterStatusGetter reads Patroni /cluster endpoint, it's implemented by helper.ResourceManager
+type ClusterStatusGetter interface {
+	GetPatroniClusterConfig(patroniUrl string) (*helper.ClusterStatus, error)
+}
+
+// PatroniCollector exposes Patroni members state on every scrape
+type PatroniCollector struct {
+	getter      ClusterStatusGetter
+	mu          sync.RWMutex
+	clusterName string
+	patroniUrl  string
+
+	up       *prometheus.Desc
+	leader   *prometheus.Desc
+	members  *prometheus.Desc
+	role     *prometheus.Desc
+	state    *prometheus.Desc
+	lag      *prometheus.Desc
+	timeline *prometheus.Desc
+}
+
+func NewPatroniCollector(getter ClusterStatusGetter) *PatroniCollector {
+	return &PatroniCollector{
+		getter: getter,
+		up: prometheus.NewDesc(prometheus.BuildFQName(namespace, "patroni", "up"),
+			"Whether Patroni /cluster endpoint was successfully read.", []string{"cluster"}, nil),
+		leader: prometheus.NewDesc(prometheus.BuildFQName(namespace, "patroni", "leader_present"),
+			"Whether Patroni cluster has a leader.", []string{"cluster"}, nil),
+		members: prometheus.NewDesc(prometheus.BuildFQName(namespace, "patroni", "members"),
+			"Number of Patroni cluster members.", []string{"cluster"}, nil),
+		role: prometheus.NewDesc(prometheus.BuildFQName(namespace, "patroni", "member_role"),
+			"Role of Patroni member.", []string{"cluster", "member", "role"}, nil),
+		state: prometheus.NewDesc(prometheus.BuildFQName(namespace, "patroni", "member_state"),
+			"State of Patroni member.", []string{"cluster", "member", "state"}, nil),
+		lag: prometheus.NewDesc(prometheus.BuildFQName(namespace, "patroni", "member_lag_bytes"),
+			"Replication lag of Patroni member in bytes.", []string{"cluster", "member"}, nil),
+		timeline: prometheus.NewDesc(prometheus.BuildFQName(namespace, "patroni", "member_timeline"),
+			"Timeline of Patroni member.", []string{"cluster", "member"}, nil),
+	}
+}
+
+// SetCluster sets Patroni cluster which will be scraped, empty patroniUrl disables collection
+func (c *PatroniCollector) SetCluster(clusterName string, patroniUrl string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.clusterName = clusterName
+	c.patroniUrl = patroniUrl
+}
+
+func (c *PatroniCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.up
+	ch <- c.leader
+	ch <- c.members
+	ch <- c.role
+	ch <- c.state
+	ch <- c.lag
+	ch <- c.timeline
+}
+
+func (c *PatroniCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.RLock()
+	clusterName, patroniUrl := c.clusterName, c.patroniUrl
+	c.mu.RUnlock()
+	if patroniUrl == "" {
+		return
+	}
+
+	status, err := c.getter.GetPatroniClusterConfig(patroniUrl)
+	if err != nil || status == nil || len(status.Members) == 0 {
+		if err != nil {
+			logger.Debug("Cannot collect Patroni metrics", zap.Error(err))
+		}
+		ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 0, clusterName)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 1, clusterName)
+	ch <- prometheus.MustNewConstMetric(c.members, prometheus.GaugeValue, float64(len(status.Members)), clusterName)
+
+	leaderPresent := 0.0
+	for _, member := range status.Members {
+		if member.Role == leaderRole || member.Role == "standby_leader" {
+			leaderPresent = 1
+		}
+		ch <- prometheus.MustNewConstMetric(c.role, prometheus.GaugeValue, 1, clusterName, member.Name, member.Role)
+		ch <- prometheus.MustNewConstMetric(c.state, prometheus.GaugeValue, 1, clusterName, member.Name, member.State)
+		ch <- prometheus.MustNewConstMetric(c.timeline, prometheus.GaugeValue, float64(member.Timeline), clusterName, member.Name)
+		if lag, ok := memberLag(member); ok {
+			ch <- prometheus.MustNewConstMetric(c.lag, prometheus.GaugeValue, lag, clusterName, member.Name)
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(c.leader, prometheus.GaugeValue, leaderPresent, clusterName)
+}
+
+// memberLag returns lag of replica, leader has no lag in /cluster response and reported with zero
+func memberLag(member helper.Member) (float64, bool) {
+	switch lag := member.Lag.(type) {
+	case float64:
+		return lag, true
+	case nil:
+		return 0, member.Role == leaderRole
+	default:
+		// Patroni reports "unknown" if lag can't be calculated
+		return 0, false
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Netcracker/pgskipper-operator/pkg/helper"
+	_ "github.com/Netcracker/pgskipper-operator/pkg/util/testenv"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+const patroniUrl = "http://pg-patroni:8008/"
+
+// fakeClusterStatusGetter returns canned response of Patroni /cluster endpoint
+type fakeClusterStatusGetter struct {
+	response string
+	err      error
+	urls     []string
+}
+
+func (g *fakeClusterStatusGetter) GetPatroniClusterConfig(patroniUrl string) (*helper.ClusterStatus, error) {
+	g.urls = append(g.urls, patroniUrl)
+	if g.err != nil {
+		return nil, g.err
+	}
+	status := &helper.ClusterStatus{}
+	if err := json.Unmarshal([]byte(g.response), status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+func TestPatroniCollector(t *testing.T) {
+	getter := &fakeClusterStatusGetter{response: `{"members": [
+		{"name": "pg-patroni-node1-0", "role": "leader", "state": "running", "timeline": 3},
+		{"name": "pg-patroni-node2-0", "role": "replica", "state": "streaming", "timeline": 3, "lag": 1024},
+		{"name": "pg-patroni-node3-0", "role": "replica", "state": "starting", "timeline": 2, "lag": "unknown"}
+	]}`}
+	collector := NewPatroniCollector(getter)
+	collector.SetCluster("patroni", patroniUrl)
+
+	expected := `
+# HELP pgskipper_patroni_up Whether Patroni /cluster endpoint was successfully read.
+# TYPE pgskipper_patroni_up gauge
+pgskipper_patroni_up{cluster="patroni"} 1
+# HELP pgskipper_patroni_leader_present Whether Patroni cluster has a leader.
+# TYPE pgskipper_patroni_leader_present gauge
+pgskipper_patroni_leader_present{cluster="patroni"} 1
+# HELP pgskipper_patroni_members Number of Patroni cluster members.
+# TYPE pgskipper_patroni_members gauge
+pgskipper_patroni_members{cluster="patroni"} 3
+# HELP pgskipper_patroni_member_role Role of Patroni member.
+# TYPE pgskipper_patroni_member_role gauge
+pgskipper_patroni_member_role{cluster="patroni",member="pg-patroni-node1-0",role="leader"} 1
+pgskipper_patroni_member_role{cluster="patroni",member="pg-patroni-node2-0",role="replica"} 1
+pgskipper_patroni_member_role{cluster="patroni",member="pg-patroni-node3-0",role="replica"} 1
+# HELP pgskipper_patroni_member_state State of Patroni member.
+# TYPE pgskipper_patroni_member_state gauge
+pgskipper_patroni_member_state{cluster="patroni",member="pg-patroni-node1-0",state="running"} 1
+pgskipper_patroni_member_state{cluster="patroni",member="pg-patroni-node2-0",state="streaming"} 1
+pgskipper_patroni_member_state{cluster="patroni",member="pg-patroni-node3-0",state="starting"} 1
+# HELP pgskipper_patroni_member_lag_bytes Replication lag of Patroni member in bytes.
+# TYPE pgskipper_patroni_member_lag_bytes gauge
+pgskipper_patroni_member_lag_bytes{cluster="patroni",member="pg-patroni-node1-0"} 0
+pgskipper_patroni_member_lag_bytes{cluster="patroni",member="pg-patroni-node2-0"} 1024
+# HELP pgskipper_patroni_member_timeline Timeline of Patroni member.
+# TYPE pgskipper_patroni_member_timeline gauge
+pgskipper_patroni_member_timeline{cluster="patroni",member="pg-patroni-node1-0"} 3
+pgskipper_patroni_member_timeline{cluster="patroni",member="pg-patroni-node2-0"} 3
+pgskipper_patroni_member_timeline{cluster="patroni",member="pg-patroni-node3-0"} 2
+`
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+	if len(getter.urls) != 1 || getter.urls[0] != patroniUrl {
+		t.Errorf("Patroni is requested by %v, expected %s once", getter.urls, patroniUrl)
+	}
+}
+
+func TestPatroniCollectorStandbyLeader(t *testing.T) {
+	collector := NewPatroniCollector(&fakeClusterStatusGetter{response: `{"members": [
+		{"name": "pg-patroni-node1-0", "role": "standby_leader", "state": "streaming", "timeline": 3}
+	]}`})
+	collector.SetCluster("patroni", patroniUrl)
+
+	expected := `
+# HELP pgskipper_patroni_leader_present Whether Patroni cluster has a leader.
+# TYPE pgskipper_patroni_leader_present gauge
+pgskipper_patroni_leader_present{cluster="patroni"} 1
+`
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(expected),
+		"pgskipper_patroni_leader_present"); err != nil {
+		t.Error(err)
+	}
+	if count := testutil.CollectAndCount(collector, "pgskipper_patroni_member_lag_bytes"); count != 0 {
+		t.Errorf("lag of standby leader without lag is reported %d times", count)
+	}
+}
+
+func TestPatroniCollectorNoLeader(t *testing.T) {
+	collector := NewPatroniCollector(&fakeClusterStatusGetter{response: `{"members": [
+		{"name": "pg-patroni-node1-0", "role": "replica", "state": "stopped", "timeline": 3, "lag": "unknown"}
+	]}`})
+	collector.SetCluster("patroni", patroniUrl)
+
+	expected := `
+# HELP pgskipper_patroni_leader_present Whether Patroni cluster has a leader.
+# TYPE pgskipper_patroni_leader_present gauge
+pgskipper_patroni_leader_present{cluster="patroni"} 0
+`
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(expected),
+		"pgskipper_patroni_leader_present"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPatroniCollectorDown(t *testing.T) {
+	for name, getter := range map[string]*fakeClusterStatusGetter{
+		"request error": {err: errors.New("connection refused")},
+		"no members":    {response: `{"members": []}`},
+	} {
+		t.Run(name, func(t *testing.T) {
+			collector := NewPatroniCollector(getter)
+			collector.SetCluster("patroni", patroniUrl)
+
+			expected := `
+# HELP pgskipper_patroni_up Whether Patroni /cluster endpoint was successfully read.
+# TYPE pgskipper_patroni_up gauge
+pgskipper_patroni_up{cluster="patroni"} 0
+`
+			if err := testutil.CollectAndCompare(collector, strings.NewReader(expected)); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestPatroniCollectorWithoutCluster(t *testing.T) {
+	getter := &fakeClusterStatusGetter{}
+	collector := NewPatroniCollector(getter)
+	if count := testutil.CollectAndCount(collector); count != 0 {
+		t.Errorf("%d metrics are collected without cluster, expected none", count)
+	}
+	if len(getter.urls) != 0 {
+		t.Errorf("Patroni is requested without cluster: %v", getter.urls)
+	}
+}
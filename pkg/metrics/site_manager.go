@@ -0,0 +1,46 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	v1 "github.com/Netcracker/pgskipper-operator/api/apps/v1"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// SiteManagerCollector exposes site manager mode and status stored in PatroniServices CR
+type SiteManagerCollector struct {
+	getStatus func() *v1.SiteManagerStatus
+	status    *prometheus.Desc
+}
+
+func NewSiteManagerCollector(getStatus func() *v1.SiteManagerStatus) *SiteManagerCollector {
+	return &SiteManagerCollector{
+		getStatus: getStatus,
+		status: prometheus.NewDesc(prometheus.BuildFQName(namespace, "site_manager", "status"),
+			"Current site manager mode and status of the last switchover.", []string{"mode", "status"}, nil),
+	}
+}
+
+func (c *SiteManagerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.status
+}
+
+func (c *SiteManagerCollector) Collect(ch chan<- prometheus.Metric) {
+	status := c.getStatus()
+	if status == nil || status.Mode == "" {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.status, prometheus.GaugeValue, 1, status.Mode, status.Status)
+}
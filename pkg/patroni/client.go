@@ -0,0 +1,148 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patroni
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/Netcracker/pgskipper-operator/pkg/util"
+)
+
+// RestApiConfig contains credentials and certificates used to connect to Patroni REST API
+type RestApiConfig struct {
+	Username string
+	Password string
+	TLS      bool
+	// CACert is used to verify Patroni certificate, it's required if TLS is enabled
+	CACert []byte
+	// ClientCert and ClientKey are presented to Patroni if both are set
+	ClientCert []byte
+	ClientKey  []byte
+}
+
+var (
+	clientMutex  sync.RWMutex
+	clientConfig                   = RestApiConfig{}
+	transport    http.RoundTripper = &restApiTransport{base: http.DefaultTransport}
+)
+
+// ConfigureClient sets credentials and certificates for all requests to Patroni REST API
+func ConfigureClient(config RestApiConfig) error {
+	clientMutex.Lock()
+	defer clientMutex.Unlock()
+	if reflect.DeepEqual(config, clientConfig) {
+		return nil
+	}
+	base := http.DefaultTransport
+	if config.TLS {
+		tlsConfig, err := getTlsConfig(config)
+		if err != nil {
+			return err
+		}
+		httpTransport := http.DefaultTransport.(*http.Transport).Clone()
+		httpTransport.TLSClientConfig = tlsConfig
+		base = httpTransport
+	}
+	logger.Info(fmt.Sprintf("Patroni REST API client is configured, tls: %t, authentication: %t", config.TLS, config.Username != ""))
+	clientConfig = config
+	util.SetPatroniApiTLS(config.TLS)
+	transport = &restApiTransport{base: base, config: config}
+	return nil
+}
+
+// NewHttpClient returns client for Patroni REST API, timeout 0 means no timeout
+func NewHttpClient(timeout time.Duration) *http.Client {
+	clientMutex.RLock()
+	defer clientMutex.RUnlock()
+	return &http.Client{Transport: transport, Timeout: timeout}
+}
+
+func httpClient() *http.Client {
+	return NewHttpClient(0)
+}
+
+// restApiTransport adds basic authentication and switches requests to https if TLS is enabled,
+// it never falls back to http, so credentials are not sent in plain text to a member without TLS
+type restApiTransport struct {
+	base   http.RoundTripper
+	config RestApiConfig
+}
+
+func (t *restApiTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	if t.config.TLS && req.URL.Scheme == "http" {
+		req.URL.Scheme = "https"
+	}
+	if t.config.Username != "" {
+		req.SetBasicAuth(t.config.Username, t.config.Password)
+	}
+	return t.base.RoundTrip(req)
+}
+
+func getTlsConfig(config RestApiConfig) (*tls.Config, error) {
+	// Patroni certificates are issued by the cluster CA, so system roots are never trusted
+	if len(config.CACert) == 0 {
+		return nil, errors.New("CA certificate for Patroni REST API is not set")
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(config.CACert) {
+		return nil, errors.New("cannot parse CA certificate for Patroni REST API")
+	}
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		// verification is done in VerifyConnection, because members are reached by pod IP
+		// which is usually absent in the certificate
+		InsecureSkipVerify: true,
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			return verifyPatroniCertificate(cs, roots)
+		},
+	}
+	if len(config.ClientCert) > 0 && len(config.ClientKey) > 0 {
+		cert, err := tls.X509KeyPair(config.ClientCert, config.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse client certificate for Patroni REST API: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// verifyPatroniCertificate verifies certificate chain against roots, host name is verified
+// only for DNS names
+func verifyPatroniCertificate(cs tls.ConnectionState, roots *x509.CertPool) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("patroni REST API didn't present a certificate")
+	}
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	if cs.ServerName != "" && net.ParseIP(cs.ServerName) == nil {
+		opts.DNSName = cs.ServerName
+	}
+	_, err := cs.PeerCertificates[0].Verify(opts)
+	return err
+}
@@ -0,0 +1,268 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patroni
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	testUsername = "patroni"
+	testPassword = "secret"
+)
+
+func configureTestClient(t *testing.T, config RestApiConfig) {
+	t.Helper()
+	if err := ConfigureClient(config); err != nil {
+		t.Fatalf("cannot configure client: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = ConfigureClient(RestApiConfig{})
+	})
+}
+
+func serverCA(server *httptest.Server) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+}
+
+// testCA is a self-signed CA which issues certificates of test servers
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// newServer starts TLS server with certificate issued by the CA for 127.0.0.1 and given DNS names
+func (ca *testCA) newServer(t *testing.T, handler http.Handler, dnsNames ...string) *httptest.Server {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "pg-patroni"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		DNSNames:     dnsNames,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewUnstartedServer(handler)
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+func countingHandler(requests *atomic.Int32) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func TestClientUpgradesHttpUrlToTls(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != testUsername || password != testPassword {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	configureTestClient(t, RestApiConfig{Username: testUsername, Password: testPassword, TLS: true, CACert: serverCA(server)})
+
+	url := strings.Replace(server.URL, "https://", "http://", 1)
+	resp, err := httpClient().Get(url + "/patroni")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("unexpected status %s", resp.Status)
+	}
+	if resp.Request.URL.Scheme != "https" {
+		t.Errorf("request was sent with %s scheme", resp.Request.URL.Scheme)
+	}
+}
+
+func TestClientDoesNotFallBackToHttp(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(countingHandler(&requests))
+	defer server.Close()
+	configureTestClient(t, RestApiConfig{Username: testUsername, Password: testPassword, TLS: true, CACert: newTestCA(t, "patroni-ca").pem})
+
+	resp, err := httpClient().Get(server.URL + "/patroni")
+	if err == nil {
+		_ = resp.Body.Close()
+		t.Fatalf("request to member without TLS succeeded with status %s", resp.Status)
+	}
+	if requests.Load() != 0 {
+		t.Errorf("member without TLS received %d requests", requests.Load())
+	}
+}
+
+func TestClientTrustsConfiguredCA(t *testing.T) {
+	ca := newTestCA(t, "patroni-ca")
+	var requests atomic.Int32
+	server := ca.newServer(t, countingHandler(&requests), "localhost")
+	configureTestClient(t, RestApiConfig{TLS: true, CACert: ca.pem})
+
+	// members are reached by IP, service by DNS name
+	for _, url := range []string{server.URL, strings.Replace(server.URL, "127.0.0.1", "localhost", 1)} {
+		resp, err := httpClient().Get(url + "/patroni")
+		if err != nil {
+			t.Fatalf("request to %s failed: %v", url, err)
+		}
+		_ = resp.Body.Close()
+	}
+	if requests.Load() != 2 {
+		t.Errorf("server received %d requests, expected 2", requests.Load())
+	}
+}
+
+func TestClientVerifiesDnsName(t *testing.T) {
+	ca := newTestCA(t, "patroni-ca")
+	var requests atomic.Int32
+	server := ca.newServer(t, countingHandler(&requests), "pg-patroni-api")
+	configureTestClient(t, RestApiConfig{TLS: true, CACert: ca.pem})
+
+	resp, err := httpClient().Get(strings.Replace(server.URL, "127.0.0.1", "localhost", 1) + "/patroni")
+	if err == nil {
+		_ = resp.Body.Close()
+		t.Fatalf("request to localhost with certificate of pg-patroni-api succeeded with status %s", resp.Status)
+	}
+	if requests.Load() != 0 {
+		t.Errorf("server with wrong name received %d requests", requests.Load())
+	}
+}
+
+func TestClientRejectsCertificateOfForeignCA(t *testing.T) {
+	var requests atomic.Int32
+	server := newTestCA(t, "foreign-ca").newServer(t, countingHandler(&requests), "localhost")
+	configureTestClient(t, RestApiConfig{Username: testUsername, Password: testPassword, TLS: true, CACert: newTestCA(t, "patroni-ca").pem})
+
+	resp, err := httpClient().Get(server.URL + "/patroni")
+	if err == nil {
+		_ = resp.Body.Close()
+		t.Fatalf("request to server with certificate of foreign CA succeeded with status %s", resp.Status)
+	}
+	if requests.Load() != 0 {
+		t.Errorf("server with certificate of foreign CA received %d requests", requests.Load())
+	}
+}
+
+func TestConfigureClientRequiresCA(t *testing.T) {
+	configureTestClient(t, RestApiConfig{})
+	if err := ConfigureClient(RestApiConfig{Username: testUsername, Password: testPassword, TLS: true}); err == nil {
+		t.Fatal("TLS without CA certificate is accepted")
+	}
+	// previous configuration is kept, so credentials are not sent without verification
+	if NewHttpClient(0).Transport.(*restApiTransport).config.TLS {
+		t.Errorf("client is configured with TLS without CA certificate")
+	}
+}
+
+func TestConfigureClientRejectsInvalidCA(t *testing.T) {
+	if err := ConfigureClient(RestApiConfig{TLS: true, CACert: []byte("not a certificate")}); err == nil {
+		t.Errorf("invalid CA certificate is accepted")
+	}
+}
+
+func TestGetLeaderUsesConfiguredScheme(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(ClusterResponse{Members: []Members{
+			{"name": "pg-patroni-node1-0", "role": "replica", "host": "10.0.0.1"},
+			{"name": "pg-patroni-node2-0", "role": "leader", "host": "10.0.0.2"},
+		}})
+	})
+	tlsServer := httptest.NewTLSServer(handler)
+	defer tlsServer.Close()
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		url      string
+		config   RestApiConfig
+		expected string
+	}{
+		{name: "tls", url: tlsServer.URL, config: RestApiConfig{TLS: true, CACert: serverCA(tlsServer)}, expected: "https://10.0.0.2:8008/"},
+		{name: "plain", url: server.URL, config: RestApiConfig{}, expected: "http://10.0.0.2:8008/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configureTestClient(t, tt.config)
+			name, url, err := GetLeader(tt.url + "/")
+			if err != nil {
+				t.Fatalf("cannot get leader: %v", err)
+			}
+			if name != "pg-patroni-node2-0" {
+				t.Errorf("unexpected leader %s", name)
+			}
+			if url != tt.expected {
+				t.Errorf("leader url is %s, expected %s", url, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,430 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patroni
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	patroniv1 "github.com/Netcracker/pgskipper-operator/api/patroni/v1"
+	"github.com/Netcracker/pgskipper-operator/pkg/util/constants"
+)
+
+var (
+	pgHbaTypes   = []string{"local", "host", "hostssl", "hostnossl", "hostgssenc", "hostnogssenc"}
+	pgHbaMethods = []string{"trust", "reject", "scram-sha-256", "md5", "password", "gss", "sspi", "ident",
+		"peer", "ldap", "radius", "cert", "pam", "bsd"}
+	pgHbaAddressKeywords = []string{"all", "samehost", "samenet"}
+	// pgHbaIncludeKeywords are directives which include other files, such lines are not validated
+	pgHbaIncludeKeywords = []string{"include", "include_if_exists", "include_dir"}
+	// pgHbaRequiredOptions are options required by authentication methods, one option of each group must be set
+	pgHbaRequiredOptions = map[string][][]string{
+		"ldap":   {{"ldapserver", "ldapurl"}},
+		"radius": {{"radiusservers"}, {"radiussecrets"}},
+	}
+	// pgHbaDefaultNetworks are addresses of the default password entries
+	pgHbaDefaultNetworks = []string{"0.0.0.0/0", "::0/0"}
+
+	pgHbaHostnamePattern = regexp.MustCompile(`^\.?[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?(\.[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?)*$`)
+	pgHbaOptionPattern   = regexp.MustCompile(`^[a-z_]+$`)
+	pgHbaListPattern     = regexp.MustCompile(`^[^\s"#]+$`)
+	// pgHbaSecretPattern matches options with passwords, they are masked in logs
+	pgHbaSecretPattern = regexp.MustCompile(`(ldapbindpasswd|radiussecrets)=("[^"]*"|\S+)`)
+)
+
+const (
+	// LdapPgHbaSecretName is a Secret with LDAP entry of pg_hba, it's mounted into Patroni pods
+	// and included by pg_hba of PostgreSQL 16 and newer, so the bind password isn't stored in DCS
+	LdapPgHbaSecretName = "patroni-ldap-pg-hba"
+	LdapPgHbaKey        = "pg_hba_ldap.conf"
+	LdapPgHbaPath       = "/ldap-pg-hba"
+	// LdapPgHbaIncludeVersion is the first major version of PostgreSQL which supports include directives in pg_hba
+	LdapPgHbaIncludeVersion = 16
+)
+
+// LdapPgHba is LDAP entry of pg_hba, it's rendered inline with the bind password
+// or included from the file mounted from LdapPgHbaSecretName if Checksum is set
+type LdapPgHba struct {
+	BindPassword string
+	// Checksum of the mounted file is written to the include line, so Patroni reloads PostgreSQL when the file is changed
+	Checksum string
+}
+
+// pgHbaEntry is a rendered pg_hba line with its priority
+type pgHbaEntry struct {
+	priority int32
+	line     string
+}
+
+// RenderPgHba returns pg_hba of the cluster with rules of spec.patroni.pgHbaConfig, lines of spec.patroni.pgHba,
+// LDAP entry and entries generated by the operator ordered by priority. Entries with the same priority keep
+// the order they are defined in. hbaMethod is a password authentication method of the generated entries.
+func RenderPgHba(cr *patroniv1.PatroniCore, hbaMethod string, ldapPgHba LdapPgHba) ([]string, error) {
+	patroniSpec := cr.Spec.Patroni
+	config := patroniSpec.PgHbaConfig
+	if config == nil {
+		config = &patroniv1.PgHbaConfig{}
+	}
+	if err := ValidatePgHbaConfig(config); err != nil {
+		return nil, err
+	}
+
+	var entries []pgHbaEntry
+	for _, rule := range config.Rules {
+		entries = append(entries, pgHbaEntry{priority: rule.Priority, line: renderPgHbaRule(rule)})
+	}
+	for _, line := range patroniSpec.PgHba {
+		if err := ValidatePgHbaLine(line); err != nil {
+			return nil, fmt.Errorf("pg_hba entry %q is not valid: %w", line, err)
+		}
+		entries = append(entries, pgHbaEntry{priority: patroniv1.PgHbaPriorityLegacy, line: line})
+	}
+	if cr.Spec.Ldap != nil && cr.Spec.Ldap.Enabled {
+		rule := getLdapPgHbaRule(cr.Spec.Ldap, ldapPgHba.BindPassword)
+		if err := ValidatePgHbaRule(rule); err != nil {
+			return nil, fmt.Errorf("LDAP pg_hba entry is not valid: %w", err)
+		}
+		line := renderPgHbaRule(rule)
+		if ldapPgHba.Checksum != "" {
+			line = fmt.Sprintf("include_if_exists %s/%s # %s", LdapPgHbaPath, LdapPgHbaKey, ldapPgHba.Checksum)
+		}
+		entries = append(entries, pgHbaEntry{priority: rule.Priority, line: line})
+	}
+	for _, rule := range getDefaultPgHbaRules(config, hbaMethod) {
+		entries = append(entries, pgHbaEntry{priority: rule.Priority, line: renderPgHbaRule(rule)})
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].priority < entries[j].priority
+	})
+	pgHba := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		pgHba = append(pgHba, entry.line)
+	}
+	return pgHba, nil
+}
+
+// ValidatePgHbaConfig returns an error of the first invalid network or rule
+func ValidatePgHbaConfig(config *patroniv1.PgHbaConfig) error {
+	if !slices.Contains([]string{"", patroniv1.PgHbaDefaultsStandard, patroniv1.PgHbaDefaultsStrict, patroniv1.PgHbaDefaultsNone}, config.Defaults) {
+		return fmt.Errorf("defaults %q is not supported", config.Defaults)
+	}
+	if config.Defaults == patroniv1.PgHbaDefaultsNone && len(config.Rules) == 0 {
+		return fmt.Errorf("rules are required when defaults is none")
+	}
+	for _, network := range config.Networks {
+		if _, _, err := net.ParseCIDR(network); err != nil {
+			return fmt.Errorf("network %q is not valid CIDR", network)
+		}
+	}
+	for i, rule := range config.Rules {
+		if err := ValidatePgHbaRule(rule); err != nil {
+			return fmt.Errorf("rule %d is not valid: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// ValidatePgHbaRule checks type, address and method of the rule and options required by the method
+func ValidatePgHbaRule(rule patroniv1.PgHbaRule) error {
+	if !slices.Contains(pgHbaTypes, rule.Type) {
+		return fmt.Errorf("type %q is not supported", rule.Type)
+	}
+	for name, value := range map[string]string{"database": rule.Database, "user": rule.User} {
+		if value != "" && !pgHbaListPattern.MatchString(value) {
+			return fmt.Errorf("%s %q must be a comma separated list without spaces and quotes", name, value)
+		}
+	}
+	if rule.Type == "local" {
+		if rule.Address != "" {
+			return fmt.Errorf("address is not allowed for local connections")
+		}
+	} else if err := validatePgHbaAddress(rule.Address); err != nil {
+		return err
+	}
+	if !slices.Contains(pgHbaMethods, rule.Method) {
+		return fmt.Errorf("method %q is not supported", rule.Method)
+	}
+	if rule.Method == "cert" && rule.Type != "hostssl" {
+		return fmt.Errorf("method cert requires type hostssl")
+	}
+	if rule.Method == "peer" && rule.Type != "local" {
+		return fmt.Errorf("method peer requires type local")
+	}
+	for name, value := range rule.Options {
+		if !pgHbaOptionPattern.MatchString(name) {
+			return fmt.Errorf("option name %q is not valid", name)
+		}
+		if strings.ContainsAny(value, "\"\n\r") {
+			return fmt.Errorf("value of option %s must not contain quotes and line breaks", name)
+		}
+	}
+	for _, group := range pgHbaRequiredOptions[rule.Method] {
+		if !slices.ContainsFunc(group, func(name string) bool { return rule.Options[name] != "" }) {
+			return fmt.Errorf("method %s requires option %s", rule.Method, strings.Join(group, " or "))
+		}
+	}
+	if rule.Priority < 0 {
+		return fmt.Errorf("priority must be greater than or equal to 0")
+	}
+	return nil
+}
+
+// ValidatePgHbaLine parses pg_hba line and validates it as a rule, include directives are not validated
+func ValidatePgHbaLine(line string) error {
+	tokens, err := tokenizePgHbaLine(line)
+	if err != nil {
+		return err
+	}
+	if len(tokens) == 0 {
+		return fmt.Errorf("entry is empty")
+	}
+	if slices.Contains(pgHbaIncludeKeywords, tokens[0]) {
+		return nil
+	}
+	rule := patroniv1.PgHbaRule{Type: tokens[0]}
+	fields := []*string{&rule.Database, &rule.User}
+	if rule.Type != "local" {
+		fields = append(fields, &rule.Address)
+	}
+	fields = append(fields, &rule.Method)
+	if len(tokens) < len(fields)+1 {
+		return fmt.Errorf("expected type, database, user, address and method")
+	}
+	for i, field := range fields {
+		*field = tokens[i+1]
+	}
+	// address can be written as IP address and mask in separate fields
+	optionsStart := len(fields) + 1
+	if rule.Type != "local" && net.ParseIP(rule.Address) != nil && net.ParseIP(rule.Method) != nil {
+		if optionsStart >= len(tokens) {
+			return fmt.Errorf("method is missing")
+		}
+		address, err := toCIDR(rule.Address, rule.Method)
+		if err != nil {
+			return err
+		}
+		rule.Address = address
+		rule.Method = tokens[optionsStart]
+		optionsStart++
+	}
+	// database and user of the line may be quoted names, they are already split by the tokenizer
+	rule.Database, rule.User = "", ""
+	rule.Options = map[string]string{}
+	for _, token := range tokens[optionsStart:] {
+		name, value, ok := strings.Cut(token, "=")
+		if !ok {
+			return fmt.Errorf("option %q must be in name=value format", token)
+		}
+		rule.Options[name] = strings.Trim(value, "\"")
+	}
+	return ValidatePgHbaRule(rule)
+}
+
+// tokenizePgHbaLine splits the line by whitespace, quoted parts are kept in tokens with quotes
+func tokenizePgHbaLine(line string) ([]string, error) {
+	var tokens []string
+	var token strings.Builder
+	quoted := false
+	for _, char := range line {
+		switch {
+		case char == '"':
+			quoted = !quoted
+			token.WriteRune(char)
+		case char == '#' && !quoted:
+			if token.Len() > 0 {
+				tokens = append(tokens, token.String())
+			}
+			return tokens, nil
+		case (char == ' ' || char == '\t') && !quoted:
+			if token.Len() > 0 {
+				tokens = append(tokens, token.String())
+				token.Reset()
+			}
+		case char == '\n' || char == '\r':
+			return nil, fmt.Errorf("line breaks are not allowed")
+		default:
+			token.WriteRune(char)
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unterminated quoted string")
+	}
+	if token.Len() > 0 {
+		tokens = append(tokens, token.String())
+	}
+	return tokens, nil
+}
+
+// toCIDR converts IP address and mask written in separate fields of pg_hba to CIDR
+func toCIDR(address string, mask string) (string, error) {
+	ip, maskIP := net.ParseIP(address), net.ParseIP(mask)
+	ipMask := net.IPMask(maskIP.To16())
+	if ip.To4() != nil {
+		ipMask = net.IPMask(maskIP.To4())
+	}
+	ones, bits := ipMask.Size()
+	if ipMask == nil || bits == 0 {
+		return "", fmt.Errorf("mask %q is not valid", mask)
+	}
+	return fmt.Sprintf("%s/%d", address, ones), nil
+}
+
+func validatePgHbaAddress(address string) error {
+	switch {
+	case address == "":
+		return fmt.Errorf("address is required for host connections")
+	case slices.Contains(pgHbaAddressKeywords, address):
+		return nil
+	case strings.Contains(address, "/"):
+		if _, _, err := net.ParseCIDR(address); err != nil {
+			return fmt.Errorf("address %q is not valid CIDR", address)
+		}
+		return nil
+	case net.ParseIP(address) != nil:
+		return fmt.Errorf("address %q must be in CIDR format", address)
+	case pgHbaHostnamePattern.MatchString(address):
+		return nil
+	}
+	return fmt.Errorf("address %q must be CIDR, host name, all, samehost or samenet", address)
+}
+
+func renderPgHbaRule(rule patroniv1.PgHbaRule) string {
+	database, user := rule.Database, rule.User
+	if database == "" {
+		database = "all"
+	}
+	if user == "" {
+		user = "all"
+	}
+	line := fmt.Sprintf("%-7s %-15s %-20s %-18s %s", rule.Type, database, user, rule.Address, rule.Method)
+	names := make([]string, 0, len(rule.Options))
+	for name := range rule.Options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := rule.Options[name]
+		if value == "" || strings.ContainsAny(value, " \t,#=") {
+			value = "\"" + value + "\""
+		}
+		line = fmt.Sprintf("%s %s=%s", line, name, value)
+	}
+	return line
+}
+
+// getDefaultPgHbaRules returns entries generated by the operator, standard entries trust local connections
+// of postgres and replication, strict entries require password for them
+func getDefaultPgHbaRules(config *patroniv1.PgHbaConfig, hbaMethod string) []patroniv1.PgHbaRule {
+	if config.Defaults == patroniv1.PgHbaDefaultsNone {
+		return nil
+	}
+	if hbaMethod == "" {
+		hbaMethod = constants.PasswordEncryption
+	}
+	networks := config.Networks
+	if len(networks) == 0 {
+		networks = pgHbaDefaultNetworks
+	}
+	newRule := func(connType, database, user, address, method string, priority int32) patroniv1.PgHbaRule {
+		return patroniv1.PgHbaRule{Type: connType, Database: database, User: user, Address: address, Method: method, Priority: priority}
+	}
+
+	var rules []patroniv1.PgHbaRule
+	localRule := newRule("local", "all", "all", "", hbaMethod, patroniv1.PgHbaPriorityPassword)
+	if config.Defaults == patroniv1.PgHbaDefaultsStrict {
+		localRule.Priority = patroniv1.PgHbaPriorityTrust
+		rules = append(rules,
+			localRule,
+			newRule("host", "all", "all", "127.0.0.1/32", hbaMethod, patroniv1.PgHbaPriorityTrust),
+			newRule("host", "all", "all", "::1/128", hbaMethod, patroniv1.PgHbaPriorityTrust),
+			newRule("local", "replication", "all", "", hbaMethod, patroniv1.PgHbaPriorityTrust),
+			newRule("host", "replication", "all", "127.0.0.1/32", hbaMethod, patroniv1.PgHbaPriorityTrust),
+			newRule("host", "replication", "all", "::1/128", hbaMethod, patroniv1.PgHbaPriorityTrust),
+		)
+	} else {
+		rules = append(rules,
+			newRule("local", "all", "postgres", "", "trust", patroniv1.PgHbaPriorityTrust),
+			newRule("host", "all", "postgres", "127.0.0.1/32", "trust", patroniv1.PgHbaPriorityTrust),
+			newRule("host", "all", "postgres", "::1/128", "trust", patroniv1.PgHbaPriorityTrust),
+			newRule("local", "replication", "all", "", "trust", patroniv1.PgHbaPriorityTrust),
+			newRule("host", "replication", "all", "127.0.0.1/32", "trust", patroniv1.PgHbaPriorityTrust),
+			newRule("host", "replication", "all", "::1/128", "trust", patroniv1.PgHbaPriorityTrust),
+		)
+	}
+	for i, network := range networks {
+		rules = append(rules,
+			newRule("host", "replication", "replicator", network, hbaMethod, patroniv1.PgHbaPriorityPassword),
+			newRule("host", "replication", "postgres", network, hbaMethod, patroniv1.PgHbaPriorityPassword),
+			newRule("host", "all", "all", network, hbaMethod, patroniv1.PgHbaPriorityPassword),
+		)
+		// local password entry goes after the first network, as it always did
+		if i == 0 && config.Defaults != patroniv1.PgHbaDefaultsStrict {
+			rules = append(rules, localRule)
+		}
+	}
+	return rules
+}
+
+// getLdapPgHbaRule returns entry which authenticates members of pgadminrole in LDAP
+func getLdapPgHbaRule(ldap *patroniv1.LdapConfig, bindPassword string) patroniv1.PgHbaRule {
+	options := map[string]string{
+		"ldapserver":          ldap.Server,
+		"ldapscheme":          ldap.Scheme,
+		"ldapbasedn":          ldap.BaseDN,
+		"ldapbinddn":          ldap.BindDN,
+		"ldapbindpasswd":      bindPassword,
+		"ldapsearchattribute": ldap.LdapSearchAttr,
+	}
+	if ldap.Port != 0 {
+		options["ldapport"] = strconv.Itoa(ldap.Port)
+	}
+	if ldap.StartTLS {
+		options["ldaptls"] = "1"
+	}
+	for name, value := range options {
+		if value == "" {
+			delete(options, name)
+		}
+	}
+	return patroniv1.PgHbaRule{
+		Type:     "host",
+		Database: "all",
+		User:     "+pgadminrole",
+		Address:  "0.0.0.0/0",
+		Method:   "ldap",
+		Options:  options,
+		Priority: patroniv1.PgHbaPriorityLdap,
+	}
+}
+
+// RenderLdapPgHbaFile returns content of pg_hba file with LDAP entry which is included by PostgreSQL 16 and newer
+func RenderLdapPgHbaFile(ldap *patroniv1.LdapConfig, bindPassword string) (string, error) {
+	rule := getLdapPgHbaRule(ldap, bindPassword)
+	if err := ValidatePgHbaRule(rule); err != nil {
+		return "", fmt.Errorf("LDAP pg_hba entry is not valid: %w", err)
+	}
+	return renderPgHbaRule(rule) + "\n", nil
+}
+
+// maskPgHbaSecrets hides passwords of pg_hba options in the text
+func maskPgHbaSecrets(text string) string {
+	return pgHbaSecretPattern.ReplaceAllString(text, "$1=***")
+}
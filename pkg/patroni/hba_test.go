@@ -0,0 +1,354 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patroni
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	patroniv1 "github.com/Netcracker/pgskipper-operator/api/patroni/v1"
+)
+
+func TestRenderPgHbaLdapEntry(t *testing.T) {
+	cr := &patroniv1.PatroniCore{Spec: &patroniv1.PatroniCoreSpec{
+		Patroni: &patroniv1.Patroni{},
+		Ldap: &patroniv1.LdapConfig{
+			Enabled: true,
+			Server:  "ldap.example.com",
+			BaseDN:  "dc=example,dc=com",
+			BindDN:  "cn=bind,dc=example,dc=com",
+		},
+	}}
+	ldapLine := func(pgHba []string) string {
+		for _, line := range pgHba {
+			if strings.Contains(line, "pgadminrole") || strings.HasPrefix(line, "include_if_exists") {
+				return line
+			}
+		}
+		return ""
+	}
+
+	pgHba, err := RenderPgHba(cr, "scram-sha-256", LdapPgHba{BindPassword: "bind-secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line := ldapLine(pgHba); !strings.Contains(line, "ldapbindpasswd=bind-secret") {
+		t.Errorf("inline LDAP entry doesn't contain the password: %q", line)
+	}
+
+	pgHba, err = RenderPgHba(cr, "scram-sha-256", LdapPgHba{Checksum: "abc123"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "include_if_exists " + LdapPgHbaPath + "/" + LdapPgHbaKey + " # abc123"
+	if line := ldapLine(pgHba); line != expected {
+		t.Errorf("got %q, expected %q", line, expected)
+	}
+	if err := ValidatePgHbaLine(expected); err != nil {
+		t.Errorf("include line is not valid: %v", err)
+	}
+
+	file, err := RenderLdapPgHbaFile(cr.Spec.Ldap, "bind-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(file, "+pgadminrole") || !strings.Contains(file, "ldapbindpasswd=bind-secret") ||
+		!strings.HasSuffix(file, "\n") {
+		t.Errorf("unexpected file with LDAP entry: %q", file)
+	}
+}
+
+// previousDefaultPgHba is pg_hba generated by previous versions of the operator, default entries must keep its order
+var previousDefaultPgHba = []string{
+	"local   all             postgres                                trust",
+	"host    all             postgres             127.0.0.1/32       trust",
+	"host    all             postgres             ::1/128            trust",
+	"local   replication     all                                     trust",
+	"host    replication     all                  127.0.0.1/32       trust",
+	"host    replication     all                  ::1/128            trust",
+	"host    replication     replicator           0.0.0.0/0          md5",
+	"host    replication     postgres             0.0.0.0/0          md5",
+	"host    all             all                  0.0.0.0/0          md5",
+	"local   all             all                                     md5",
+	"host    replication     replicator           ::0/0              md5",
+	"host    replication     postgres             ::0/0              md5",
+	"host    all             all                  ::0/0              md5",
+}
+
+func normalizePgHba(pgHba []string) []string {
+	normalized := make([]string, 0, len(pgHba))
+	for _, line := range pgHba {
+		normalized = append(normalized, strings.Join(strings.Fields(line), " "))
+	}
+	return normalized
+}
+
+func TestRenderPgHbaOrder(t *testing.T) {
+	cr := &patroniv1.PatroniCore{Spec: &patroniv1.PatroniCoreSpec{Patroni: &patroniv1.Patroni{}}}
+	pgHba, err := RenderPgHba(cr, "md5", LdapPgHba{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(normalizePgHba(pgHba), normalizePgHba(previousDefaultPgHba)) {
+		t.Errorf("default pg_hba is\n%s\nexpected\n%s", strings.Join(pgHba, "\n"), strings.Join(previousDefaultPgHba, "\n"))
+	}
+
+	// custom lines go first and LDAP entry goes before password entries, as they did in previous versions
+	cr.Spec.Patroni.PgHba = []string{"host all app 10.0.0.0/8 md5", "host all reporter 10.0.0.0/8 reject"}
+	cr.Spec.Ldap = &patroniv1.LdapConfig{Enabled: true, Server: "ldap.example.com", Port: 389,
+		BaseDN: "dc=example,dc=com", BindDN: "cn=bind,dc=example,dc=com", LdapSearchAttr: "uid"}
+	cr.Spec.Patroni.PgHbaConfig = &patroniv1.PgHbaConfig{Rules: []patroniv1.PgHbaRule{
+		{Type: "hostssl", Database: "all", User: "app", Address: "192.168.0.0/16", Method: "scram-sha-256", Priority: 450},
+		{Type: "host", Database: "all", User: "all", Address: "172.16.0.0/12", Method: "reject", Priority: 50},
+	}}
+	pgHba, err = RenderPgHba(cr, "md5", LdapPgHba{BindPassword: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"host all all 172.16.0.0/12 reject",
+		"host all app 10.0.0.0/8 md5",
+		"host all reporter 10.0.0.0/8 reject",
+	}
+	expected = append(expected, normalizePgHba(previousDefaultPgHba[:6])...)
+	expected = append(expected,
+		`host all +pgadminrole 0.0.0.0/0 ldap ldapbasedn="dc=example,dc=com" ldapbinddn="cn=bind,dc=example,dc=com" `+
+			"ldapbindpasswd=secret ldapport=389 ldapsearchattribute=uid ldapserver=ldap.example.com",
+		"hostssl all app 192.168.0.0/16 scram-sha-256",
+	)
+	expected = append(expected, normalizePgHba(previousDefaultPgHba[6:])...)
+	if !reflect.DeepEqual(normalizePgHba(pgHba), expected) {
+		t.Errorf("pg_hba is\n%s\nexpected\n%s", strings.Join(normalizePgHba(pgHba), "\n"), strings.Join(expected, "\n"))
+	}
+
+	// options of the rules are rendered in the same order every time
+	for i := 0; i < 20; i++ {
+		again, err := RenderPgHba(cr, "md5", LdapPgHba{BindPassword: "secret"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(again, pgHba) {
+			t.Fatalf("pg_hba is rendered differently:\n%s\n%s", strings.Join(again, "\n"), strings.Join(pgHba, "\n"))
+		}
+	}
+}
+
+func TestRenderPgHbaDefaults(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   *patroniv1.PgHbaConfig
+		method   string
+		expected []string
+	}{
+		{
+			name:   "scram-sha-256 for password entries",
+			method: "scram-sha-256",
+			expected: []string{
+				"host replication replicator 0.0.0.0/0 scram-sha-256",
+				"host all all ::0/0 scram-sha-256",
+			},
+		},
+		{
+			name:   "networks",
+			config: &patroniv1.PgHbaConfig{Networks: []string{"10.0.0.0/8"}},
+			method: "md5",
+			expected: []string{
+				"host all all 10.0.0.0/8 md5",
+				"local all all md5",
+			},
+		},
+		{
+			name:   "strict",
+			config: &patroniv1.PgHbaConfig{Defaults: patroniv1.PgHbaDefaultsStrict},
+			method: "scram-sha-256",
+			expected: []string{
+				"local all all scram-sha-256",
+				"host replication all 127.0.0.1/32 scram-sha-256",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cr := &patroniv1.PatroniCore{Spec: &patroniv1.PatroniCoreSpec{Patroni: &patroniv1.Patroni{PgHbaConfig: tt.config}}}
+			pgHba, err := RenderPgHba(cr, tt.method, LdapPgHba{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			normalized := normalizePgHba(pgHba)
+			for _, line := range tt.expected {
+				if !slices.Contains(normalized, line) {
+					t.Errorf("entry %q is missing in\n%s", line, strings.Join(normalized, "\n"))
+				}
+			}
+			for _, line := range normalized {
+				if strings.HasSuffix(line, " trust") && tt.config != nil && tt.config.Defaults == patroniv1.PgHbaDefaultsStrict {
+					t.Errorf("strict defaults contain %q", line)
+				}
+				if tt.config != nil && len(tt.config.Networks) > 0 && strings.Contains(line, "0.0.0.0/0") {
+					t.Errorf("default network is used with custom networks: %q", line)
+				}
+			}
+		})
+	}
+
+	cr := &patroniv1.PatroniCore{Spec: &patroniv1.PatroniCoreSpec{Patroni: &patroniv1.Patroni{
+		PgHbaConfig: &patroniv1.PgHbaConfig{Defaults: patroniv1.PgHbaDefaultsNone},
+	}}}
+	if _, err := RenderPgHba(cr, "md5", LdapPgHba{}); err == nil {
+		t.Errorf("pg_hba without entries is rendered")
+	}
+}
+
+func TestValidatePgHbaRule(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    patroniv1.PgHbaRule
+		wantErr string
+	}{
+		{name: "host", rule: patroniv1.PgHbaRule{Type: "host", Database: "db1,db2", User: "+group", Address: "10.0.0.0/8", Method: "scram-sha-256"}},
+		{name: "local", rule: patroniv1.PgHbaRule{Type: "local", Method: "peer"}},
+		{name: "host name", rule: patroniv1.PgHbaRule{Type: "host", Address: ".example.com", Method: "md5"}},
+		{name: "keyword address", rule: patroniv1.PgHbaRule{Type: "hostnossl", Address: "samenet", Method: "reject"}},
+		{name: "ipv6", rule: patroniv1.PgHbaRule{Type: "host", Address: "fe80::/10", Method: "md5"}},
+		{name: "unknown type", rule: patroniv1.PgHbaRule{Type: "hosts", Address: "all", Method: "md5"}, wantErr: "type"},
+		{name: "bad CIDR", rule: patroniv1.PgHbaRule{Type: "host", Address: "10.0.0.0/33", Method: "md5"}, wantErr: "not valid CIDR"},
+		{name: "IP without mask", rule: patroniv1.PgHbaRule{Type: "host", Address: "10.0.0.1", Method: "md5"}, wantErr: "CIDR format"},
+		{name: "bad host name", rule: patroniv1.PgHbaRule{Type: "host", Address: "exa mple", Method: "md5"}, wantErr: "must be CIDR"},
+		{name: "missing address", rule: patroniv1.PgHbaRule{Type: "host", Method: "md5"}, wantErr: "address is required"},
+		{name: "address of local", rule: patroniv1.PgHbaRule{Type: "local", Address: "all", Method: "md5"}, wantErr: "not allowed"},
+		{name: "unknown method", rule: patroniv1.PgHbaRule{Type: "host", Address: "all", Method: "scram"}, wantErr: "method \"scram\""},
+		{name: "cert without ssl", rule: patroniv1.PgHbaRule{Type: "host", Address: "all", Method: "cert"}, wantErr: "hostssl"},
+		{name: "peer over network", rule: patroniv1.PgHbaRule{Type: "host", Address: "all", Method: "peer"}, wantErr: "local"},
+		{name: "spaces in user", rule: patroniv1.PgHbaRule{Type: "local", User: "a b", Method: "md5"}, wantErr: "user"},
+		{name: "quotes in database", rule: patroniv1.PgHbaRule{Type: "local", Database: `"db"`, Method: "md5"}, wantErr: "database"},
+		{
+			name:    "ldap without server",
+			rule:    patroniv1.PgHbaRule{Type: "host", Address: "all", Method: "ldap", Options: map[string]string{"ldapbasedn": "dc=example"}},
+			wantErr: "ldapserver or ldapurl",
+		},
+		{
+			name: "ldap with url",
+			rule: patroniv1.PgHbaRule{Type: "host", Address: "all", Method: "ldap", Options: map[string]string{"ldapurl": "ldap://ldap.example.com/dc=example"}},
+		},
+		{
+			name:    "radius without secrets",
+			rule:    patroniv1.PgHbaRule{Type: "host", Address: "all", Method: "radius", Options: map[string]string{"radiusservers": "radius.example.com"}},
+			wantErr: "radiussecrets",
+		},
+		{
+			name: "radius",
+			rule: patroniv1.PgHbaRule{Type: "host", Address: "all", Method: "radius",
+				Options: map[string]string{"radiusservers": "radius.example.com", "radiussecrets": "secret"}},
+		},
+		{
+			name:    "bad option name",
+			rule:    patroniv1.PgHbaRule{Type: "host", Address: "all", Method: "md5", Options: map[string]string{"clientcert=1 x": "1"}},
+			wantErr: "option name",
+		},
+		{
+			name:    "quote in option value",
+			rule:    patroniv1.PgHbaRule{Type: "host", Address: "all", Method: "md5", Options: map[string]string{"map": `a"b`}},
+			wantErr: "quotes",
+		},
+		{name: "negative priority", rule: patroniv1.PgHbaRule{Type: "local", Method: "md5", Priority: -1}, wantErr: "priority"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePgHbaRule(tt.rule)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidatePgHbaRule() = %v, want no error", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ValidatePgHbaRule() = %v, want error with %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidatePgHbaLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		wantErr string
+	}{
+		{name: "host", line: "host all all 0.0.0.0/0 md5"},
+		{name: "local", line: "local   replication   all   trust"},
+		{name: "address and mask", line: "host all all 192.168.1.0 255.255.255.0 scram-sha-256"},
+		{name: "ipv6 address and mask", line: "host all all fe80:: ffff:ffff:: md5"},
+		{name: "quoted names", line: `host "my db" "my user" 10.0.0.0/8 md5`},
+		{name: "comment", line: "host all all 10.0.0.0/8 md5 # office"},
+		{name: "options", line: `host all all 10.0.0.0/8 ldap ldapserver=ldap.example.com ldapprefix="cn=" ldapsuffix=", dc=example"`},
+		{name: "include", line: "include_if_exists /etc/pg_hba_extra.conf"},
+		{name: "empty", line: "   # only comment", wantErr: "empty"},
+		{name: "too short", line: "host all all md5", wantErr: "expected type"},
+		{name: "bad CIDR", line: "host all all 10.0.0.0/40 md5", wantErr: "not valid CIDR"},
+		{name: "address without method", line: "host all all 192.168.1.0 255.255.255.0", wantErr: "method is missing"},
+		{name: "bad mask", line: "host all all 192.168.1.0 255.0.255.0 md5", wantErr: "mask"},
+		{name: "unknown method", line: "host all all 10.0.0.0/8 md6", wantErr: "method \"md6\""},
+		{name: "ldap without server", line: "host all all 10.0.0.0/8 ldap ldapbasedn=dc=example", wantErr: "ldapserver"},
+		{name: "radius without servers", line: "host all all 10.0.0.0/8 radius radiussecrets=secret", wantErr: "radiusservers"},
+		{name: "option without value", line: "host all all 10.0.0.0/8 md5 clientcert", wantErr: "name=value"},
+		{name: "unterminated quote", line: `host "all all 10.0.0.0/8 md5`, wantErr: "unterminated"},
+		{name: "line break", line: "host all all 10.0.0.0/8 md5\nlocal all all trust", wantErr: "line breaks"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePgHbaLine(tt.line)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidatePgHbaLine(%q) = %v, want no error", tt.line, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ValidatePgHbaLine(%q) = %v, want error with %q", tt.line, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTokenizePgHbaLine(t *testing.T) {
+	tests := []struct {
+		line   string
+		tokens []string
+	}{
+		{line: "host  all\tall 10.0.0.0/8   md5", tokens: []string{"host", "all", "all", "10.0.0.0/8", "md5"}},
+		{line: `host "my db" all 10.0.0.0/8 md5`, tokens: []string{"host", `"my db"`, "all", "10.0.0.0/8", "md5"}},
+		{line: `host all all 10.0.0.0/8 ldap ldapprefix="cn=#1 " # comment`,
+			tokens: []string{"host", "all", "all", "10.0.0.0/8", "ldap", `ldapprefix="cn=#1 "`}},
+		{line: `local "a""b" all trust`, tokens: []string{"local", `"a""b"`, "all", "trust"}},
+		{line: "local all all trust#comment", tokens: []string{"local", "all", "all", "trust"}},
+		{line: "# comment"},
+		{line: ""},
+	}
+	for _, tt := range tests {
+		tokens, err := tokenizePgHbaLine(tt.line)
+		if err != nil {
+			t.Errorf("tokenizePgHbaLine(%q) failed: %v", tt.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(tokens, tt.tokens) {
+			t.Errorf("tokenizePgHbaLine(%q) = %q, want %q", tt.line, tokens, tt.tokens)
+		}
+	}
+	if _, err := tokenizePgHbaLine(`host "all`); err == nil {
+		t.Errorf("unterminated quote is accepted")
+	}
+}
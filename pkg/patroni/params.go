@@ -0,0 +1,443 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patroni
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	patroniv1 "github.com/Netcracker/pgskipper-operator/api/patroni/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+type ParamType int
+
+const (
+	ParamString ParamType = iota
+	ParamInteger
+	ParamReal
+	ParamMemory
+	ParamDuration
+	ParamEnum
+	ParamBool
+	ParamList
+)
+
+// ParamSpec describes value type of parameter and whether its change requires restart
+type ParamSpec struct {
+	Type ParamType
+	// Unit is applied to memory and duration values specified without unit
+	Unit    string
+	Values  []string
+	Restart bool
+}
+
+// ParamChange is a parameter which differs from the current Patroni configuration
+type ParamChange struct {
+	Name    string
+	Current string
+	Desired string
+	// Restart is true if parameter requires restart or its context is unknown
+	Restart bool
+}
+
+var (
+	paramNameRegExp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+	memoryRegExp    = regexp.MustCompile(`^(-?[0-9]+)\s*(B|kB|MB|GB|TB)?$`)
+	durationRegExp  = regexp.MustCompile(`^(-?[0-9]+(?:\.[0-9]+)?)\s*(us|ms|s|min|h|d)?$`)
+
+	memoryUnits = map[string]float64{
+		"B":   1,
+		"kB":  1 << 10,
+		"8kB": 8 << 10,
+		"MB":  1 << 20,
+		"GB":  1 << 30,
+		"TB":  1 << 40,
+	}
+	durationUnits = map[string]float64{
+		"us":  0.001,
+		"ms":  1,
+		"s":   1000,
+		"min": 60 * 1000,
+		"h":   60 * 60 * 1000,
+		"d":   24 * 60 * 60 * 1000,
+	}
+	boolValues = map[string]bool{
+		"on": true, "true": true, "yes": true, "1": true, "t": true, "y": true,
+		"off": false, "false": false, "no": false, "0": false, "f": false, "n": false,
+	}
+)
+
+var postgreSQLParamSpecs = map[string]ParamSpec{
+	// parameters with postmaster context
+	"max_connections":                 {Type: ParamInteger, Restart: true},
+	"superuser_reserved_connections":  {Type: ParamInteger, Restart: true},
+	"shared_buffers":                  {Type: ParamMemory, Unit: "8kB", Restart: true},
+	"wal_buffers":                     {Type: ParamMemory, Unit: "8kB", Restart: true},
+	"max_prepared_transactions":       {Type: ParamInteger, Restart: true},
+	"max_locks_per_transaction":       {Type: ParamInteger, Restart: true},
+	"max_pred_locks_per_transaction":  {Type: ParamInteger, Restart: true},
+	"max_worker_processes":            {Type: ParamInteger, Restart: true},
+	"max_wal_senders":                 {Type: ParamInteger, Restart: true},
+	"max_replication_slots":           {Type: ParamInteger, Restart: true},
+	"max_logical_replication_workers": {Type: ParamInteger, Restart: true},
+	"max_files_per_process":           {Type: ParamInteger, Restart: true},
+	"autovacuum_max_workers":          {Type: ParamInteger, Restart: true},
+	"track_activity_query_size":       {Type: ParamMemory, Unit: "B", Restart: true},
+	"port":                            {Type: ParamInteger, Restart: true},
+	"listen_addresses":                {Type: ParamList, Restart: true},
+	"shared_preload_libraries":        {Type: ParamList, Restart: true},
+	"wal_level":                       {Type: ParamEnum, Values: []string{"minimal", "replica", "logical", "archive", "hot_standby"}, Restart: true},
+	"archive_mode":                    {Type: ParamEnum, Values: []string{"off", "on", "always"}, Restart: true},
+	"huge_pages":                      {Type: ParamEnum, Values: []string{"off", "on", "try"}, Restart: true},
+	"hot_standby":                     {Type: ParamBool, Restart: true},
+	"wal_log_hints":                   {Type: ParamBool, Restart: true},
+	"track_commit_timestamp":          {Type: ParamBool, Restart: true},
+	"logging_collector":               {Type: ParamBool, Restart: true},
+	"cluster_name":                    {Type: ParamString, Restart: true},
+
+	// parameters which are applied on reload
+	"work_mem":                            {Type: ParamMemory, Unit: "kB"},
+	"maintenance_work_mem":                {Type: ParamMemory, Unit: "kB"},
+	"autovacuum_work_mem":                 {Type: ParamMemory, Unit: "kB"},
+	"temp_buffers":                        {Type: ParamMemory, Unit: "8kB"},
+	"effective_cache_size":                {Type: ParamMemory, Unit: "8kB"},
+	"max_wal_size":                        {Type: ParamMemory, Unit: "MB"},
+	"min_wal_size":                        {Type: ParamMemory, Unit: "MB"},
+	"wal_keep_size":                       {Type: ParamMemory, Unit: "MB"},
+	"checkpoint_timeout":                  {Type: ParamDuration, Unit: "s"},
+	"archive_timeout":                     {Type: ParamDuration, Unit: "s"},
+	"autovacuum_naptime":                  {Type: ParamDuration, Unit: "s"},
+	"tcp_keepalives_idle":                 {Type: ParamDuration, Unit: "s"},
+	"statement_timeout":                   {Type: ParamDuration, Unit: "ms"},
+	"lock_timeout":                        {Type: ParamDuration, Unit: "ms"},
+	"deadlock_timeout":                    {Type: ParamDuration, Unit: "ms"},
+	"idle_in_transaction_session_timeout": {Type: ParamDuration, Unit: "ms"},
+	"log_min_duration_statement":          {Type: ParamDuration, Unit: "ms"},
+	"log_autovacuum_min_duration":         {Type: ParamDuration, Unit: "ms"},
+	"max_standby_streaming_delay":         {Type: ParamDuration, Unit: "ms"},
+	"max_standby_archive_delay":           {Type: ParamDuration, Unit: "ms"},
+	"wal_receiver_timeout":                {Type: ParamDuration, Unit: "ms"},
+	"wal_sender_timeout":                  {Type: ParamDuration, Unit: "ms"},
+	"bgwriter_delay":                      {Type: ParamDuration, Unit: "ms"},
+	"checkpoint_completion_target":        {Type: ParamReal},
+	"random_page_cost":                    {Type: ParamReal},
+	"seq_page_cost":                       {Type: ParamReal},
+	"autovacuum_vacuum_scale_factor":      {Type: ParamReal},
+	"autovacuum_analyze_scale_factor":     {Type: ParamReal},
+	"effective_io_concurrency":            {Type: ParamInteger},
+	"max_parallel_workers":                {Type: ParamInteger},
+	"max_parallel_workers_per_gather":     {Type: ParamInteger},
+	"max_parallel_maintenance_workers":    {Type: ParamInteger},
+	"default_statistics_target":           {Type: ParamInteger},
+	"autovacuum_vacuum_cost_limit":        {Type: ParamInteger},
+	"password_encryption":                 {Type: ParamEnum, Values: []string{"md5", "scram-sha-256", "on", "off"}},
+	"synchronous_commit":                  {Type: ParamEnum, Values: []string{"on", "off", "local", "remote_write", "remote_apply"}},
+	"log_statement":                       {Type: ParamEnum, Values: []string{"none", "ddl", "mod", "all"}},
+	"default_transaction_isolation":       {Type: ParamEnum, Values: []string{"serializable", "repeatable read", "read committed", "read uncommitted"}},
+	"ssl":                                 {Type: ParamBool},
+	"autovacuum":                          {Type: ParamBool},
+	"hot_standby_feedback":                {Type: ParamBool},
+	"track_io_timing":                     {Type: ParamBool},
+	"log_connections":                     {Type: ParamBool},
+	"log_disconnections":                  {Type: ParamBool},
+	"log_checkpoints":                     {Type: ParamBool},
+	"log_lock_waits":                      {Type: ParamBool},
+	"jit":                                 {Type: ParamBool},
+	"search_path":                         {Type: ParamList},
+	"archive_command":                     {Type: ParamString},
+	"log_line_prefix":                     {Type: ParamString},
+	"timezone":                            {Type: ParamString},
+	"log_timezone":                        {Type: ParamString},
+}
+
+var patroniParamSpecs = map[string]ParamSpec{
+	"ttl":                     {Type: ParamInteger},
+	"loop_wait":               {Type: ParamInteger},
+	"retry_timeout":           {Type: ParamInteger},
+	"maximum_lag_on_failover": {Type: ParamInteger},
+	"maximum_lag_on_syncnode": {Type: ParamInteger},
+	"master_start_timeout":    {Type: ParamInteger},
+	"primary_start_timeout":   {Type: ParamInteger},
+	"synchronous_node_count":  {Type: ParamInteger},
+	"max_timelines_history":   {Type: ParamInteger},
+	"synchronous_mode":        {Type: ParamBool},
+	"synchronous_mode_strict": {Type: ParamBool},
+	"failsafe_mode":           {Type: ParamBool},
+	"check_timeline":          {Type: ParamBool},
+}
+
+// ParseParam splits parameter in the list format, "name=value" or "name: value"
+func ParseParam(param string) (string, string, error) {
+	sep := strings.IndexAny(param, "=:")
+	if sep < 0 {
+		return "", "", fmt.Errorf("parameter %q should be in name=value or name: value format", param)
+	}
+	name := strings.TrimSpace(param[:sep])
+	if !paramNameRegExp.MatchString(name) {
+		return "", "", fmt.Errorf("invalid parameter name in %q", param)
+	}
+	return name, unquote(strings.TrimSpace(param[sep+1:])), nil
+}
+
+// GetPostgreSQLParams returns validated PostgreSQL parameters from both postgreSQLParams list
+// and postgreSQLParameters map, values from the map take precedence
+func GetPostgreSQLParams(patroni *patroniv1.Patroni) (map[string]string, error) {
+	return mergeParams(patroni.PostgreSQLParams, patroni.PostgreSQLParameters, postgreSQLParamSpecs)
+}
+
+// GetPatroniParams returns validated Patroni parameters converted to JSON types expected by Patroni
+func GetPatroniParams(patroni *patroniv1.Patroni) (map[string]interface{}, error) {
+	params, err := mergeParams(patroni.PatroniParams, patroni.PatroniParameters, patroniParamSpecs)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]interface{}, len(params))
+	for name, value := range params {
+		result[name] = typedValue(patroniParamSpecs[name], value)
+	}
+	return result, nil
+}
+
+// GetPostgreSQLParamValue returns value of parameter from the CR and whether it's set
+func GetPostgreSQLParamValue(patroni *patroniv1.Patroni, paramName string) (string, bool) {
+	if value, ok := patroni.PostgreSQLParameters[paramName]; ok {
+		if value, err := ParamValue(value); err == nil {
+			return value, true
+		}
+	}
+	for _, param := range patroni.PostgreSQLParams {
+		if name, value, err := ParseParam(param); err == nil && name == paramName {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// ParamValue returns value of parameter in the map format as a string, numbers keep their text
+// representation and booleans are converted to on or off
+func ParamValue(value apiextensionsv1.JSON) (string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(value.Raw))
+	decoder.UseNumber()
+	var decoded interface{}
+	if err := decoder.Decode(&decoded); err != nil {
+		return "", fmt.Errorf("cannot parse value %s: %w", string(value.Raw), err)
+	}
+	switch v := decoded.(type) {
+	case string:
+		return unquote(v), nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return formatValue(v), nil
+	}
+	return "", fmt.Errorf("value %s should be a string, number or boolean", string(value.Raw))
+}
+
+func mergeParams(list []string, params map[string]apiextensionsv1.JSON, specs map[string]ParamSpec) (map[string]string, error) {
+	var errs []error
+	result := map[string]string{}
+	for _, param := range list {
+		name, value, err := ParseParam(param)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		result[name] = value
+	}
+	for name, value := range params {
+		if !paramNameRegExp.MatchString(name) {
+			errs = append(errs, fmt.Errorf("invalid parameter name %q", name))
+			continue
+		}
+		stringValue, err := ParamValue(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid value of %s: %w", name, err))
+			continue
+		}
+		result[name] = stringValue
+	}
+	for name, value := range result {
+		if spec, ok := specs[name]; ok {
+			if _, err := normalize(spec, value); err != nil {
+				errs = append(errs, fmt.Errorf("invalid value of %s: %w", name, err))
+			}
+		}
+	}
+	return result, errors.Join(errs...)
+}
+
+// DiffPostgreSQLParams returns parameters whose desired value differs from the current one
+func DiffPostgreSQLParams(desired map[string]string, current map[string]interface{}) []ParamChange {
+	return diffParams(desired, current, postgreSQLParamSpecs)
+}
+
+func diffParams(desired map[string]string, current map[string]interface{}, specs map[string]ParamSpec) []ParamChange {
+	var changes []ParamChange
+	for name, value := range desired {
+		spec, known := specs[name]
+		currentValue, found := current[name]
+		currentString := formatValue(currentValue)
+		if found && equalValues(spec, value, currentString) {
+			continue
+		}
+		changes = append(changes, ParamChange{
+			Name:    name,
+			Current: currentString,
+			Desired: value,
+			Restart: spec.Restart || !known,
+		})
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Name < changes[j].Name
+	})
+	return changes
+}
+
+func equalValues(spec ParamSpec, desired string, current string) bool {
+	normDesired, err := normalize(spec, desired)
+	if err != nil {
+		return false
+	}
+	normCurrent, err := normalize(spec, unquote(current))
+	if err != nil {
+		return false
+	}
+	return normDesired == normCurrent
+}
+
+// normalize validates value and returns its canonical form, memory is converted
+// to bytes and duration to milliseconds
+func normalize(spec ParamSpec, value string) (string, error) {
+	switch spec.Type {
+	case ParamInteger:
+		i, err := strconv.ParseInt(value, 0, 64)
+		if err != nil {
+			return "", fmt.Errorf("%q is not an integer", value)
+		}
+		return strconv.FormatInt(i, 10), nil
+	case ParamReal:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return "", fmt.Errorf("%q is not a number", value)
+		}
+		return strconv.FormatFloat(f, 'f', -1, 64), nil
+	case ParamMemory:
+		match := memoryRegExp.FindStringSubmatch(value)
+		if match == nil {
+			return "", fmt.Errorf("%q is not a memory value, expected number with optional B, kB, MB, GB or TB unit", value)
+		}
+		return scale(match[1], match[2], spec.Unit, memoryUnits)
+	case ParamDuration:
+		match := durationRegExp.FindStringSubmatch(value)
+		if match == nil {
+			return "", fmt.Errorf("%q is not a duration, expected number with optional us, ms, s, min, h or d unit", value)
+		}
+		return scale(match[1], match[2], spec.Unit, durationUnits)
+	case ParamEnum:
+		for _, allowed := range spec.Values {
+			if strings.EqualFold(value, allowed) {
+				return allowed, nil
+			}
+		}
+		return "", fmt.Errorf("%q is not one of %s", value, strings.Join(spec.Values, ", "))
+	case ParamBool:
+		b, ok := boolValues[strings.ToLower(value)]
+		if !ok {
+			return "", fmt.Errorf("%q is not a boolean", value)
+		}
+		if b {
+			return "on", nil
+		}
+		return "off", nil
+	case ParamList:
+		items := strings.Split(value, ",")
+		for i := range items {
+			items[i] = strings.TrimSpace(items[i])
+		}
+		return strings.Join(items, ","), nil
+	}
+	return value, nil
+}
+
+func scale(number string, unit string, defaultUnit string, units map[string]float64) (string, error) {
+	f, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return "", err
+	}
+	// -1 usually means "use default" or "disabled" and has no unit
+	if f == -1 {
+		return "-1", nil
+	}
+	if unit == "" {
+		unit = defaultUnit
+	}
+	multiplier, ok := units[unit]
+	if !ok {
+		return "", fmt.Errorf("unknown unit %q", unit)
+	}
+	return strconv.FormatFloat(math.Round(f*multiplier*1000)/1000, 'f', -1, 64), nil
+}
+
+func typedValue(spec ParamSpec, value string) interface{} {
+	switch spec.Type {
+	case ParamInteger:
+		if i, err := strconv.ParseInt(value, 0, 64); err == nil {
+			return i
+		}
+	case ParamBool:
+		if b, ok := boolValues[strings.ToLower(value)]; ok {
+			return b
+		}
+	}
+	return value
+}
+
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		if v {
+			return "on"
+		}
+		return "off"
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// unquote removes single quotes around the whole value, Patroni quotes values itself
+func unquote(value string) string {
+	if len(value) < 2 || !strings.HasPrefix(value, "'") || !strings.HasSuffix(value, "'") {
+		return value
+	}
+	inner := value[1 : len(value)-1]
+	// values like 'a', 'b' are lists of quoted items, not a quoted value
+	if strings.Contains(strings.ReplaceAll(inner, "''", ""), "'") {
+		return value
+	}
+	return strings.ReplaceAll(inner, "''", "'")
+}
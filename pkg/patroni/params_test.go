@@ -0,0 +1,348 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patroni
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	patroniv1 "github.com/Netcracker/pgskipper-operator/api/patroni/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+func jsonValue(raw string) apiextensionsv1.JSON {
+	return apiextensionsv1.JSON{Raw: []byte(raw)}
+}
+
+func TestParseParam(t *testing.T) {
+	tests := []struct {
+		param string
+		name  string
+		value string
+	}{
+		{param: "max_connections: 200", name: "max_connections", value: "200"},
+		{param: "work_mem=4MB", name: "work_mem", value: "4MB"},
+		{param: "  shared_buffers =  1GB ", name: "shared_buffers", value: "1GB"},
+		{param: "log_line_prefix: '%m [%p] '", name: "log_line_prefix", value: "%m [%p] "},
+		{param: `search_path: '"$user", public'`, name: "search_path", value: `"$user", public`},
+		{param: "archive_command=cp %p /archive/%f", name: "archive_command", value: "cp %p /archive/%f"},
+		{param: "recovery_target_time: 2024-01-01 10:00:00", name: "recovery_target_time", value: "2024-01-01 10:00:00"},
+		{param: "pg_stat_statements.max=10000", name: "pg_stat_statements.max", value: "10000"},
+		{param: "application_name=", name: "application_name", value: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.param, func(t *testing.T) {
+			name, value, err := ParseParam(tt.param)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if name != tt.name || value != tt.value {
+				t.Errorf("parsed as %q = %q, expected %q = %q", name, value, tt.name, tt.value)
+			}
+		})
+	}
+}
+
+func TestParseParamInvalid(t *testing.T) {
+	for _, param := range []string{"max_connections 200", "", ": 200", "1max: 200", "max connections: 200", "max-connections=200"} {
+		if name, value, err := ParseParam(param); err == nil {
+			t.Errorf("%q is parsed as %q = %q, expected error", param, name, value)
+		}
+	}
+}
+
+func TestUnquote(t *testing.T) {
+	tests := map[string]string{
+		"'abc'":             "abc",
+		"'it''s'":           "it's",
+		"''":                "",
+		"'":                 "'",
+		"abc":               "abc",
+		"'abc":              "'abc",
+		"'a', 'b'":          "'a', 'b'",
+		`'"$user", public'`: `"$user", public`,
+	}
+	for value, expected := range tests {
+		if unquoted := unquote(value); unquoted != expected {
+			t.Errorf("unquote(%q) = %q, expected %q", value, unquoted, expected)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		param    string
+		value    string
+		expected string
+	}{
+		// memory is converted to bytes, values without unit are in the unit of the parameter
+		{param: "shared_buffers", value: "128MB", expected: "134217728"},
+		{param: "shared_buffers", value: "16384", expected: "134217728"},
+		{param: "shared_buffers", value: "1GB", expected: "1073741824"},
+		{param: "shared_buffers", value: "-1", expected: "-1"},
+		{param: "work_mem", value: "4MB", expected: "4194304"},
+		{param: "work_mem", value: "4096", expected: "4194304"},
+		{param: "work_mem", value: "4096 kB", expected: "4194304"},
+		{param: "max_wal_size", value: "1024", expected: "1073741824"},
+		{param: "track_activity_query_size", value: "2kB", expected: "2048"},
+		// duration is converted to milliseconds
+		{param: "checkpoint_timeout", value: "5min", expected: "300000"},
+		{param: "checkpoint_timeout", value: "300", expected: "300000"},
+		{param: "checkpoint_timeout", value: "300s", expected: "300000"},
+		{param: "statement_timeout", value: "0", expected: "0"},
+		{param: "statement_timeout", value: "1.5s", expected: "1500"},
+		{param: "statement_timeout", value: "500us", expected: "0.5"},
+		{param: "statement_timeout", value: "1h", expected: "3600000"},
+		{param: "log_min_duration_statement", value: "-1", expected: "-1"},
+		{param: "archive_timeout", value: "1d", expected: "86400000"},
+		{param: "max_connections", value: "200", expected: "200"},
+		{param: "max_connections", value: "0x10", expected: "16"},
+		{param: "checkpoint_completion_target", value: "0.90", expected: "0.9"},
+		{param: "random_page_cost", value: "4", expected: "4"},
+		{param: "wal_level", value: "LOGICAL", expected: "logical"},
+		{param: "default_transaction_isolation", value: "Read Committed", expected: "read committed"},
+		{param: "hot_standby", value: "true", expected: "on"},
+		{param: "hot_standby", value: "OFF", expected: "off"},
+		{param: "ssl", value: "1", expected: "on"},
+		{param: "shared_preload_libraries", value: "pg_stat_statements , pgaudit", expected: "pg_stat_statements,pgaudit"},
+		{param: "log_line_prefix", value: " %m ", expected: " %m "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.param+"="+tt.value, func(t *testing.T) {
+			normalized, err := normalize(postgreSQLParamSpecs[tt.param], tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if normalized != tt.expected {
+				t.Errorf("normalized to %q, expected %q", normalized, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNormalizeInvalid(t *testing.T) {
+	tests := []struct {
+		param string
+		value string
+	}{
+		{param: "shared_buffers", value: "lots"},
+		{param: "shared_buffers", value: "12XB"},
+		{param: "shared_buffers", value: "1.5GB"},
+		{param: "checkpoint_timeout", value: "5 minutes"},
+		{param: "checkpoint_timeout", value: "min"},
+		{param: "max_connections", value: "2.5"},
+		{param: "max_connections", value: "many"},
+		{param: "checkpoint_completion_target", value: "high"},
+		{param: "wal_level", value: "full"},
+		{param: "hot_standby", value: "maybe"},
+	}
+	for _, tt := range tests {
+		if normalized, err := normalize(postgreSQLParamSpecs[tt.param], tt.value); err == nil {
+			t.Errorf("%s=%q is normalized to %q, expected error", tt.param, tt.value, normalized)
+		}
+	}
+}
+
+func TestParamValue(t *testing.T) {
+	tests := map[string]string{
+		`"8MB"`:                         "8MB",
+		`300`:                           "300",
+		`0.9`:                           "0.9",
+		`1e3`:                           "1e3",
+		`true`:                          "on",
+		`false`:                         "off",
+		`"'%m [%p] '"`:                  "%m [%p] ",
+		`"pg_stat_statements, pgaudit"`: "pg_stat_statements, pgaudit",
+	}
+	for raw, expected := range tests {
+		value, err := ParamValue(jsonValue(raw))
+		if err != nil {
+			t.Errorf("unexpected error for %s: %v", raw, err)
+			continue
+		}
+		if value != expected {
+			t.Errorf("value of %s is %q, expected %q", raw, value, expected)
+		}
+	}
+	for _, raw := range []string{`null`, `{"a": 1}`, `[1, 2]`, `not json`} {
+		if value, err := ParamValue(jsonValue(raw)); err == nil {
+			t.Errorf("%s is converted to %q, expected error", raw, value)
+		}
+	}
+}
+
+func TestGetPostgreSQLParams(t *testing.T) {
+	patroni := &patroniv1.Patroni{
+		PostgreSQLParams: []string{"max_connections: 200", "work_mem: 4MB", "custom.setting=abc"},
+		PostgreSQLParameters: map[string]apiextensionsv1.JSON{
+			"max_connections":              jsonValue(`300`),
+			"checkpoint_completion_target": jsonValue(`0.9`),
+			"hot_standby":                  jsonValue(`true`),
+			"search_path":                  jsonValue(`"'\"$user\", public'"`),
+		},
+	}
+	params, err := GetPostgreSQLParams(patroni)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"max_connections":              "300",
+		"work_mem":                     "4MB",
+		"custom.setting":               "abc",
+		"checkpoint_completion_target": "0.9",
+		"hot_standby":                  "on",
+		"search_path":                  `"$user", public`,
+	}
+	if !reflect.DeepEqual(params, expected) {
+		t.Errorf("parameters are %v, expected %v", params, expected)
+	}
+
+	if value, ok := GetPostgreSQLParamValue(patroni, "max_connections"); !ok || value != "300" {
+		t.Errorf("value of max_connections is %q, %t, expected value from the map", value, ok)
+	}
+	if value, ok := GetPostgreSQLParamValue(patroni, "work_mem"); !ok || value != "4MB" {
+		t.Errorf("value of work_mem is %q, %t, expected value from the list", value, ok)
+	}
+	if _, ok := GetPostgreSQLParamValue(patroni, "shared_buffers"); ok {
+		t.Errorf("shared_buffers is found but not set")
+	}
+}
+
+func TestGetPostgreSQLParamsInvalid(t *testing.T) {
+	patroni := &patroniv1.Patroni{
+		PostgreSQLParams: []string{"max_connections 200", "wal_level: full"},
+		PostgreSQLParameters: map[string]apiextensionsv1.JSON{
+			"shared_buffers": jsonValue(`"lots"`),
+			"hot_standby":    jsonValue(`{"enabled": true}`),
+			"bad name":       jsonValue(`1`),
+			"custom.setting": jsonValue(`"anything"`),
+		},
+	}
+	_, err := GetPostgreSQLParams(patroni)
+	if err == nil {
+		t.Fatal("invalid parameters are accepted")
+	}
+	for _, expected := range []string{`"max_connections 200"`, "wal_level", "shared_buffers", "hot_standby", `"bad name"`} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("error doesn't mention %s: %v", expected, err)
+		}
+	}
+	if strings.Contains(err.Error(), "custom.setting") {
+		t.Errorf("unknown parameter is validated: %v", err)
+	}
+}
+
+func TestGetPatroniParams(t *testing.T) {
+	params, err := GetPatroniParams(&patroniv1.Patroni{
+		PatroniParams: []string{"ttl: 30", "synchronous_mode: on", "loop_wait: 5"},
+		PatroniParameters: map[string]apiextensionsv1.JSON{
+			"loop_wait":     jsonValue(`10`),
+			"failsafe_mode": jsonValue(`true`),
+			"custom":        jsonValue(`"x"`),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"ttl":              int64(30),
+		"synchronous_mode": true,
+		"loop_wait":        int64(10),
+		"failsafe_mode":    true,
+		"custom":           "x",
+	}
+	if !reflect.DeepEqual(params, expected) {
+		t.Errorf("parameters are %#v, expected %#v", params, expected)
+	}
+
+	if _, err := GetPatroniParams(&patroniv1.Patroni{PatroniParams: []string{"ttl: long"}}); err == nil {
+		t.Errorf("invalid ttl is accepted")
+	}
+}
+
+func TestDiffPostgreSQLParams(t *testing.T) {
+	desired := map[string]string{
+		"max_connections":              "300",
+		"work_mem":                     "8MB",
+		"shared_buffers":               "128MB",
+		"hot_standby":                  "on",
+		"checkpoint_completion_target": "0.9",
+		"statement_timeout":            "1min",
+		"autovacuum":                   "off",
+		"custom.setting":               "abc",
+		"log_line_prefix":              "%m ",
+	}
+	// current configuration is decoded from JSON of Patroni /config
+	current := map[string]interface{}{
+		"max_connections":              float64(200),
+		"work_mem":                     "4096kB",
+		"shared_buffers":               "16384",
+		"hot_standby":                  true,
+		"checkpoint_completion_target": 0.9,
+		"statement_timeout":            "60s",
+		"log_line_prefix":              "'%m '",
+	}
+	expected := []ParamChange{
+		{Name: "autovacuum", Current: "", Desired: "off", Restart: false},
+		{Name: "custom.setting", Current: "", Desired: "abc", Restart: true},
+		{Name: "max_connections", Current: "200", Desired: "300", Restart: true},
+		{Name: "work_mem", Current: "4096kB", Desired: "8MB", Restart: false},
+	}
+	changes := DiffPostgreSQLParams(desired, current)
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("changes are %+v, expected %+v", changes, expected)
+	}
+}
+
+func TestDiffPostgreSQLParamsRestartClassification(t *testing.T) {
+	tests := []struct {
+		param   string
+		current interface{}
+		desired string
+		restart bool
+	}{
+		{param: "shared_buffers", current: "128MB", desired: "256MB", restart: true},
+		{param: "max_connections", current: float64(100), desired: "200", restart: true},
+		{param: "wal_level", current: "replica", desired: "logical", restart: true},
+		{param: "shared_preload_libraries", current: "pg_stat_statements", desired: "pg_stat_statements,pgaudit", restart: true},
+		{param: "hot_standby", current: false, desired: "on", restart: true},
+		{param: "work_mem", current: "4MB", desired: "16MB", restart: false},
+		{param: "checkpoint_timeout", current: "5min", desired: "15min", restart: false},
+		{param: "random_page_cost", current: float64(4), desired: "1.1", restart: false},
+		{param: "log_statement", current: "none", desired: "ddl", restart: false},
+		{param: "synchronous_commit", current: "on", desired: "remote_apply", restart: false},
+		{param: "unknown_extension.setting", current: "a", desired: "b", restart: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.param, func(t *testing.T) {
+			changes := DiffPostgreSQLParams(map[string]string{tt.param: tt.desired}, map[string]interface{}{tt.param: tt.current})
+			if len(changes) != 1 {
+				t.Fatalf("changes are %+v, expected one change", changes)
+			}
+			if changes[0].Restart != tt.restart {
+				t.Errorf("restart of %s is %t, expected %t", tt.param, changes[0].Restart, tt.restart)
+			}
+		})
+	}
+}
+
+func TestDiffPostgreSQLParamsInvalidCurrentValue(t *testing.T) {
+	// value which can't be normalized is always reported as a change
+	changes := DiffPostgreSQLParams(map[string]string{"work_mem": "4MB"}, map[string]interface{}{"work_mem": "unknown"})
+	if len(changes) != 1 || changes[0].Current != "unknown" {
+		t.Errorf("changes are %+v, expected change of work_mem", changes)
+	}
+}
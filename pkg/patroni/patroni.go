@@ -21,7 +21,6 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"strings"
 	"time"
 
 	qubershipv1 "github.com/Netcracker/pgskipper-operator/api/apps/v1"
@@ -190,7 +189,7 @@ func SetSslStatus(cr *patroniv1.PatroniCore, patroniUrl string) error {
 
 func GetPatroniCurrentConfig(patroniUrl string) (map[string]interface{}, error) {
 
-	resp, err := http.Get(patroniUrl + "/config")
+	resp, err := httpClient().Get(patroniUrl + "config")
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve patroni config: %w", err)
 	}
@@ -213,68 +212,6 @@ func GetPatroniCurrentConfig(patroniUrl string) (map[string]interface{}, error)
 	return config, nil
 }
 
-func SetLdapConfig(cr *patroniv1.PatroniCore, patroniUrl string) error {
-	logger.Info("Adding LDAP configuration to Patroni config")
-
-	// Generate the LDAP configuration string
-	ldapConfig := GenerateLDAPConfig(cr)
-
-	// Retrieve existing Patroni configuration
-	currentConfig, err := GetPatroniCurrentConfig(patroniUrl)
-	if err != nil {
-		logger.Error("Failed to retrieve current Patroni configuration", zap.Error(err))
-		return err
-	}
-
-	// Retrieve the existing pg_hba entries
-	currentPgHBA, ok := currentConfig["postgresql"].(map[string]interface{})["pg_hba"].([]interface{})
-	if !ok {
-		logger.Error("Failed to parse current pg_hba entries")
-		return fmt.Errorf("invalid format for pg_hba entries")
-	}
-
-	// Convert currentPgHBA to a slice of strings
-	var pgHBAEntries []string
-	for _, entry := range currentPgHBA {
-		if str, ok := entry.(string); ok {
-			pgHBAEntries = append(pgHBAEntries, str)
-		}
-	}
-
-	// Find the index of the first 'md5' entry in pg_hba to insert LDAP rules before it
-	var md5Index int
-	for i, entry := range pgHBAEntries {
-		if strings.Contains(entry, "md5") {
-			md5Index = i
-			break
-		}
-	}
-
-	// Insert LDAP config entries right before the first 'md5' entry so as to prevent failure of reconciliation cycle
-	pgHBAEntries = append(pgHBAEntries[:md5Index], append(ldapConfig, pgHBAEntries[md5Index:]...)...)
-
-	// Convert back pgHBAEntries to []interface{} to match the format of the original pg_hba
-	var updatedPgHBA []interface{}
-	for _, entry := range pgHBAEntries {
-		updatedPgHBA = append(updatedPgHBA, entry)
-	}
-
-	// Update patchData with the modified pg_hba entries
-	patchData := map[string]interface{}{
-		"postgresql": map[string]interface{}{
-			"pg_hba": updatedPgHBA,
-		},
-	}
-
-	// Updating Patroni configuration
-	if err := UpdatePatroniConfig(patchData, patroniUrl); err != nil {
-		logger.Error("Failed to update Patroni config with LDAP configuration, exiting", zap.Error(err))
-		return err
-	}
-
-	return nil
-}
-
 func IsStandbyClusterConfigurationExist(cr *patroniv1.PatroniCore) bool {
 	standbyCluster := cr.Spec.Patroni.StandbyCluster
 	emptyStandbyCluster := &patroniv1.StandbyCluster{}
@@ -293,10 +230,9 @@ func getStandbyClusterConfiguration(cr *patroniv1.PatroniCore) map[string]interf
 }
 
 func GetStandbyClusterConfigurationWithHost(cr *patroniv1.PatroniCore, host string, port int) map[string]interface{} {
-	standbyCluster := cr.Spec.Patroni.StandbyCluster
 	standbyClusterConfiguration := map[string]interface{}{
-		"host":                   standbyCluster.Host,
-		"port":                   standbyCluster.Port,
+		"host":                   host,
+		"port":                   port,
 		"primary_slot_name":      util.GetPatroniClusterName(cr.Spec.Patroni.ClusterName),
 		"create_replica_methods": []string{"basebackup"},
 	}
@@ -325,71 +261,170 @@ func updateStandbyClusterSettings(configMap *corev1.ConfigMap, settings interfac
 	return configMap
 }
 
-func UpdatePostgreSQLParams(patroni *patroniv1.Patroni, patroniUrl string) error {
-	postgreSQLParams := map[string]interface{}{}
-	for _, param := range patroni.PostgreSQLParams {
-		param = strings.Replace(param, "=", ":", 1)
-		splittedParam := strings.Split(param, ":")
-		value := strings.Replace(param, splittedParam[0]+":", "", 1)
-		splittedParam[0] = strings.TrimSpace(splittedParam[0])
-		value = strings.TrimSpace(value)
-		postgreSQLParams[splittedParam[0]] = value
+// UpdatePostgreSQLParams applies PostgreSQL parameters and pg_hba rendered by RenderPgHba
+func UpdatePostgreSQLParams(patroni *patroniv1.Patroni, patroniUrl string, pgHba []string) error {
+	postgreSQLParams, err := GetPostgreSQLParams(patroni)
+	if err != nil {
+		logger.Error("PostgreSQL parameters are not valid", zap.Error(err))
+		return err
 	}
 
-	if _, isMapContainsKey := postgreSQLParams["password_encryption"]; isMapContainsKey {
+	if patroni.AuthMethod == constants.ScramSHA256 {
+		// new passwords must be stored as SCRAM during the migration, so the value from CR is ignored
+		if value, ok := postgreSQLParams["password_encryption"]; ok && value != constants.ScramSHA256 {
+			logger.Warn(fmt.Sprintf("password_encryption %s is overridden by authMethod %s", value, patroni.AuthMethod))
+		}
+		postgreSQLParams["password_encryption"] = constants.ScramSHA256
+	} else if _, isMapContainsKey := postgreSQLParams["password_encryption"]; isMapContainsKey {
 		logger.Info("Password encryption property set by CR")
 	} else {
 		postgreSQLParams["password_encryption"] = constants.PasswordEncryption
 	}
 
-	postgreSQL := map[string]interface{}{}
+	currentConfig, err := GetPatroniCurrentConfig(patroniUrl)
+	if err != nil {
+		// apply all parameters and let Patroni decide if restart is pending
+		logger.Warn("Cannot get current Patroni config, all PostgreSQL parameters will be applied", zap.Error(err))
+		return UpdatePatroniConfig(getPostgreSQLPatch(toInterfaceMap(postgreSQLParams), pgHba), patroniUrl)
+	}
 
-	if len(postgreSQLParams) > 0 {
-		postgreSQL["parameters"] = postgreSQLParams
+	currentPostgreSQL, _ := currentConfig["postgresql"].(map[string]interface{})
+	currentParams, _ := currentPostgreSQL["parameters"].(map[string]interface{})
+	changes := DiffPostgreSQLParams(postgreSQLParams, currentParams)
+	hbaChanged := !cmp.Equal(toInterfaceSlice(pgHba), currentPostgreSQL["pg_hba"])
+	if len(changes) == 0 && !hbaChanged {
+		logger.Info("PostgreSQL parameters are up to date")
+		return nil
 	}
-	postgreSQL["pg_hba"] = getPgHba(patroni.PgHba)
 
-	patchData := map[string]interface{}{
-		"postgresql": postgreSQL,
+	changedParams := map[string]interface{}{}
+	restartRequired := false
+	for _, change := range changes {
+		logger.Info(fmt.Sprintf("PostgreSQL parameter %s will be changed from %q to %q, restart required: %t",
+			change.Name, change.Current, change.Desired, change.Restart))
+		changedParams[change.Name] = change.Desired
+		restartRequired = restartRequired || change.Restart
+	}
+	var patchHba []string
+	if hbaChanged {
+		patchHba = pgHba
 	}
 
-	if err := UpdatePatroniConfig(patchData, patroniUrl); err != nil {
+	if err := patchPatroniConfig(getPostgreSQLPatch(changedParams, patchHba), patroniUrl); err != nil {
 		logger.Error("Failed to patch postgresql params via patroni", zap.Error(err))
 		return err
 	}
-	return nil
+	if !restartRequired {
+		logger.Info("Changed PostgreSQL parameters are applied on reload, restart is not required")
+		return nil
+	}
+	return restartPendingMembers(patroniUrl)
+}
+
+func getPostgreSQLPatch(params map[string]interface{}, pgHba []string) map[string]interface{} {
+	postgreSQL := map[string]interface{}{}
+	if len(params) > 0 {
+		postgreSQL["parameters"] = params
+	}
+	if pgHba != nil {
+		postgreSQL["pg_hba"] = pgHba
+	}
+	return map[string]interface{}{
+		"postgresql": postgreSQL,
+	}
 }
 
 func UpdatePatroniParams(patroni *patroniv1.Patroni, patroniUrl string) error {
-	patroniLParams := map[string]interface{}{}
-	for _, param := range patroni.PatroniParams {
-		param = strings.Replace(param, "=", ":", 1)
-		splittedParam := strings.Split(param, ":")
-		splittedParam[0] = strings.TrimSpace(splittedParam[0])
-		splittedParam[1] = strings.TrimSpace(splittedParam[1])
-		patroniLParams[splittedParam[0]] = splittedParam[1]
+	patroniLParams, err := GetPatroniParams(patroni)
+	if err != nil {
+		logger.Error("Patroni parameters are not valid", zap.Error(err))
+		return err
+	}
+	if len(patroniLParams) == 0 {
+		return nil
 	}
 
-	if err := UpdatePatroniConfig(patroniLParams, patroniUrl); err != nil {
+	if currentConfig, err := GetPatroniCurrentConfig(patroniUrl); err == nil {
+		for name, value := range patroniLParams {
+			if current, ok := currentConfig[name]; ok && equalValues(patroniParamSpecs[name], formatValue(value), formatValue(current)) {
+				delete(patroniLParams, name)
+			}
+		}
+		if len(patroniLParams) == 0 {
+			logger.Info("Patroni parameters are up to date")
+			return nil
+		}
+	}
+
+	// Patroni parameters don't require restart of PostgreSQL
+	if err := patchPatroniConfig(patroniLParams, patroniUrl); err != nil {
 		logger.Error("Failed to patch patroni params via patroni", zap.Error(err))
 		return err
 	}
 	return nil
 }
 
-func getPgHba(newHba []string) []string {
-	if len(newHba) > 0 {
-		return append(newHba, constants.PgHba...)
+func toInterfaceMap(params map[string]string) map[string]interface{} {
+	result := make(map[string]interface{}, len(params))
+	for name, value := range params {
+		result[name] = value
+	}
+	return result
+}
+
+func toInterfaceSlice(values []string) []interface{} {
+	result := make([]interface{}, 0, len(values))
+	for _, value := range values {
+		result = append(result, value)
+	}
+	return result
+}
+
+// UpdatePgHba applies pg_hba if it differs from the current one, pg_hba is applied on reload
+func UpdatePgHba(patroniUrl string, pgHba []string) error {
+	currentConfig, err := GetPatroniCurrentConfig(patroniUrl)
+	if err != nil {
+		return err
+	}
+	currentPostgreSQL, _ := currentConfig["postgresql"].(map[string]interface{})
+	if cmp.Equal(toInterfaceSlice(pgHba), currentPostgreSQL["pg_hba"]) {
+		logger.Info("pg_hba is up to date")
+		return nil
+	}
+	return patchPatroniConfig(getPostgreSQLPatch(nil, pgHba), patroniUrl)
+}
+
+// GetHbaAuthMethod returns password authentication method of the generated pg_hba entries,
+// scram-sha-256 is used only when migration of md5 hashes is completed
+func GetHbaAuthMethod(cr *patroniv1.PatroniCore) string {
+	if cr.Spec == nil || cr.Spec.Patroni == nil || cr.Spec.Patroni.AuthMethod != constants.ScramSHA256 {
+		return constants.PasswordEncryption
 	}
-	return constants.PgHba
+	if migration := cr.Status.AuthMigration; migration != nil && migration.Phase == patroniv1.AuthMigrationCompleted {
+		return constants.ScramSHA256
+	}
+	return constants.PasswordEncryption
 }
 
 func UpdatePatroniConfig(values map[string]interface{}, patroniUrl string) error {
+	if err := patchPatroniConfig(values, patroniUrl); err != nil {
+		return err
+	}
+	return restartPendingMembers(patroniUrl)
+}
+
+// UpdateStandbyCluster sets standby_cluster section of Patroni dynamic configuration,
+// Patroni applies it without restart of the members
+func UpdateStandbyCluster(settings map[string]interface{}, patroniUrl string) error {
+	return patchPatroniConfig(map[string]interface{}{"standby_cluster": settings}, patroniUrl)
+}
+
+func patchPatroniConfig(values map[string]interface{}, patroniUrl string) error {
 	logger.Info("Will try to update PostgreSQL parameters via Patroni REST API")
-	logger.Info(fmt.Sprintf("Patch body: %s", values))
+	logger.Info(fmt.Sprintf("Patch body: %s", maskPgHbaSecrets(fmt.Sprint(values))))
 
 	jsonValue, _ := json.Marshal(values)
-	client := &http.Client{}
+	client := httpClient()
 	if retryError := wait.PollUntilContextTimeout(context.Background(), time.Second, 1*time.Minute, true, func(ctx context.Context) (done bool, err error) {
 		req, err := http.NewRequest(http.MethodPatch, patroniUrl+"config", bytes.NewBuffer(jsonValue))
 		if err != nil {
@@ -406,7 +441,11 @@ func UpdatePatroniConfig(values map[string]interface{}, patroniUrl string) error
 		logger.Error("Number of retries exceeded, giving up", zap.Error(retryError))
 		return retryError
 	}
+	return nil
+}
 
+// restartPendingMembers restarts Patroni members which have pending_restart flag
+func restartPendingMembers(patroniUrl string) error {
 	patroniHosts, err := getPatroniHosts(patroniUrl)
 	if err != nil {
 		return err
@@ -427,7 +466,7 @@ func getPatroniHosts(patroniUrl string) ([]string, error) {
 	hosts := make([]string, 0, 2)
 	response := ClusterResponse{}
 	if retryError := wait.PollUntilContextTimeout(context.Background(), time.Second, 1*time.Minute, true, func(ctx context.Context) (done bool, err error) {
-		resp, err := http.Get(patroniUrl + "cluster")
+		resp, err := httpClient().Get(patroniUrl + "cluster")
 		if err != nil {
 			logger.Error(fmt.Sprintf("cannot receive patroni hosts, get resp %v", resp), zap.Error(err))
 			return false, nil
@@ -443,7 +482,7 @@ func getPatroniHosts(patroniUrl string) ([]string, error) {
 					hosts = make([]string, 0, 2)
 					return false, nil
 				}
-				url := fmt.Sprintf("http://%s:8008/", host)
+				url := util.GetPatroniApiUrl(fmt.Sprint(host))
 				hosts = append(hosts, url)
 			}
 			return len(hosts) > 0, nil
@@ -459,7 +498,7 @@ func getPatroniHosts(patroniUrl string) ([]string, error) {
 
 func restartIfPending(patroniUrl string) error {
 	return wait.PollUntilContextTimeout(context.Background(), 10*time.Second, 120*time.Minute, true, func(ctx context.Context) (done bool, err error) {
-		resp, err := http.Get(patroniUrl + "patroni")
+		resp, err := httpClient().Get(patroniUrl + "patroni")
 		if err != nil {
 			logger.Error("Get request to patroni failed, retrying", zap.Error(err))
 			return false, nil
@@ -480,7 +519,7 @@ func restartIfPending(patroniUrl string) error {
 			pendingRestart, ok := responseAsJson["pending_restart"]
 			if ok && pendingRestart.(bool) {
 				logger.Info("restartPending, will schedule restart of patroni")
-				resp, err = http.Post(patroniUrl+"restart", "", nil)
+				resp, err = httpClient().Post(patroniUrl+"restart", "", nil)
 				defer func() {
 					_ = resp.Body.Close()
 				}()
@@ -510,6 +549,34 @@ func AddTagsSettings(cr *patroniv1.PatroniCore, configMap *corev1.ConfigMap, con
 
 }
 
+// AddRestApiSettings sets restapi authentication and certificates in patroni template config map,
+// values are taken from environment variables of Patroni pod
+func AddRestApiSettings(cr *patroniv1.PatroniCore, configMap *corev1.ConfigMap, configMapKey string) {
+	logger.Info("Apply REST API configuration in patroni template config map")
+	var config map[string]interface{}
+	if err := yaml.Unmarshal([]byte(configMap.Data[configMapKey]), &config); err != nil {
+		logger.Error("Could not unmarshal patroni config map", zap.Error(err))
+		return
+	}
+	restApi := map[interface{}]interface{}{}
+	if current, ok := config["restapi"].(map[interface{}]interface{}); ok {
+		restApi = current
+	}
+	if cr.Spec.Patroni.RestApi.AuthSecret != "" {
+		restApi["authentication"] = map[string]string{
+			"username": "${PATRONI_RESTAPI_USERNAME}",
+			"password": "${PATRONI_RESTAPI_PASSWORD}",
+		}
+	}
+	if cr.Spec.Patroni.RestApi.Tls {
+		restApi["certfile"] = "${PATRONI_RESTAPI_CERTFILE}"
+		restApi["keyfile"] = "${PATRONI_RESTAPI_KEYFILE}"
+		restApi["cafile"] = "${PATRONI_RESTAPI_CAFILE}"
+		restApi["verify_client"] = "${PATRONI_RESTAPI_VERIFY_CLIENT}"
+	}
+	UpdatePatroniConfigMap(configMap, restApi, "restapi", configMapKey)
+}
+
 func getEtcdConfiguration(cr *patroniv1.PatroniCore) map[string]interface{} {
 	etcdClusterConfiguration := map[string]interface{}{
 		"hosts": cr.Spec.Patroni.Dcs.Hosts,
@@ -538,20 +605,3 @@ func UpdatePatroniConfigMap(configMap *corev1.ConfigMap, settings interface{}, k
 	configMap.Data[configMapKey] = string(result)
 	return configMap
 }
-
-func GenerateLDAPConfig(cr *patroniv1.PatroniCore) []string {
-
-	ldapServer := cr.Spec.Ldap.Server
-	ldapPort := cr.Spec.Ldap.Port
-	ldapBasedn := cr.Spec.Ldap.BaseDN
-	ldapBinddn := cr.Spec.Ldap.BindDN
-	ldapBindpasswd := cr.Spec.Ldap.BindPasswd
-	ldapSearchAttribute := cr.Spec.Ldap.LdapSearchAttr
-
-	return []string{
-		fmt.Sprintf(
-			"host all +pgadminrole 0.0.0.0/0 ldap ldapserver=%s ldapport=%v ldapbasedn=\"%s\" ldapbinddn=\"%s\" ldapbindpasswd=\"%s\" ldapsearchattribute=\"%s\"",
-			ldapServer, ldapPort, ldapBasedn, ldapBinddn, ldapBindpasswd, ldapSearchAttribute,
-		),
-	}
-}
@@ -0,0 +1,146 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patroni
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/Netcracker/pgskipper-operator/pkg/util"
+	"go.uber.org/zap"
+)
+
+// GetLeader returns name and REST API url of the current leader (or standby leader) of Patroni cluster
+func GetLeader(patroniUrl string) (string, string, error) {
+	response, err := getClusterState(patroniUrl)
+	if err != nil {
+		return "", "", err
+	}
+	for _, m := range response.Members {
+		if role, _ := m["role"].(string); role == "leader" || role == "standby_leader" {
+			name, _ := m["name"].(string)
+			return name, memberApiUrl(m), nil
+		}
+	}
+	return "", "", fmt.Errorf("patroni cluster has no leader")
+}
+
+// Switchover asks Patroni leader to pass leadership to the candidate,
+// Patroni chooses the healthiest replica if candidate is empty
+func Switchover(leaderUrl string, leader string, candidate string) error {
+	body := map[string]string{"leader": leader}
+	if candidate != "" {
+		body["candidate"] = candidate
+	}
+	jsonValue, _ := json.Marshal(body)
+	logger.Info(fmt.Sprintf("Performing switchover via Patroni REST API, leader: %s, candidate: %s", leader, candidate))
+	resp, err := httpClient().Post(leaderUrl+"switchover", "application/json", bytes.NewBuffer(jsonValue))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	message, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("switchover failed, status: %s, response: %s", resp.Status, string(message))
+	}
+	logger.Info(fmt.Sprintf("Switchover response: %s", string(message)))
+	return nil
+}
+
+// GetNewLeader returns name of the running leader if it's not previousLeader and matches candidate (if it's set),
+// empty name means switchover isn't finished yet. State of the leader is confirmed by the member itself.
+func GetNewLeader(patroniUrl string, previousLeader string, candidate string) (string, error) {
+	response, err := getClusterState(patroniUrl)
+	if err != nil {
+		return "", err
+	}
+	for _, m := range response.Members {
+		apiUrl := memberApiUrl(m)
+		name, role, state, err := getMemberState(apiUrl)
+		if err != nil {
+			logger.Info(fmt.Sprintf("Cannot get state of Patroni member %s", apiUrl), zap.Error(err))
+			continue
+		}
+		if !isLeaderRole(role) || state != "running" {
+			continue
+		}
+		if name == previousLeader || (candidate != "" && name != candidate) {
+			logger.Info(fmt.Sprintf("Leader is %s, waiting for switchover", name))
+			return "", nil
+		}
+		return name, nil
+	}
+	return "", nil
+}
+
+func getClusterState(patroniUrl string) (*ClusterResponse, error) {
+	resp, err := httpClient().Get(patroniUrl + "cluster")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cannot get patroni cluster state, status: %s", resp.Status)
+	}
+	response := &ClusterResponse{}
+	if err = json.NewDecoder(resp.Body).Decode(response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// memberApiUrl returns REST API url of the member, address of api_url is preferred because it has
+// the port configured in Patroni, host with the default port is used if api_url isn't reported
+func memberApiUrl(member Members) string {
+	if apiUrl, ok := member["api_url"].(string); ok {
+		if parsed, err := url.Parse(apiUrl); err == nil && parsed.Host != "" {
+			return util.GetPatroniApiUrlForAddress(parsed.Host)
+		}
+	}
+	return util.GetPatroniApiUrl(fmt.Sprint(member["host"]))
+}
+
+func getMemberState(host string) (string, string, string, error) {
+	resp, err := httpClient().Get(host + "patroni")
+	if err != nil {
+		return "", "", "", err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	member := struct {
+		Role    string `json:"role"`
+		State   string `json:"state"`
+		Patroni struct {
+			Name string `json:"name"`
+		} `json:"patroni"`
+	}{}
+	if err = json.NewDecoder(resp.Body).Decode(&member); err != nil {
+		return "", "", "", err
+	}
+	return member.Patroni.Name, member.Role, member.State, nil
+}
+
+func isLeaderRole(role string) bool {
+	return role == "master" || role == "primary" || role == "standby_leader"
+}
@@ -0,0 +1,157 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pgbackrest
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/Netcracker/pgskipper-operator-core/pkg/util"
+)
+
+const (
+	Stanza        = "patroni"
+	ContainerName = "pgbackrest-sidecar"
+	// annotationKey marks backups started by operator with UID of PostgresBackup
+	annotationKey = "postgres-backup"
+)
+
+var (
+	logger    = util.GetLogger()
+	namespace = util.GetNameSpace()
+)
+
+// Executor runs commands in containers of the pods, pgBackRest commands are executed in the sidecar
+type Executor interface {
+	ExecCmdOnPod(podName string, namespace string, container string, command string) (string, string, error)
+}
+
+// StanzaInfo is the stanza section of `pgbackrest info --output=json`
+type StanzaInfo struct {
+	Name   string       `json:"name"`
+	Backup []BackupInfo `json:"backup"`
+	Status struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Lock    struct {
+			Backup struct {
+				Held bool `json:"held"`
+			} `json:"backup"`
+		} `json:"lock"`
+	} `json:"status"`
+}
+
+// BackupInfo describes a backup set in pgBackRest repository
+type BackupInfo struct {
+	Label      string            `json:"label"`
+	Type       string            `json:"type"`
+	Error      bool              `json:"error"`
+	Annotation map[string]string `json:"annotation"`
+	Database   struct {
+		RepoKey int `json:"repo-key"`
+	} `json:"database"`
+	Archive struct {
+		Start string `json:"start"`
+		Stop  string `json:"stop"`
+	} `json:"archive"`
+	Info struct {
+		Size       int64 `json:"size"`
+		Delta      int64 `json:"delta"`
+		Repository struct {
+			Size  int64 `json:"size"`
+			Delta int64 `json:"delta"`
+		} `json:"repository"`
+	} `json:"info"`
+	Lsn struct {
+		Start string `json:"start"`
+		Stop  string `json:"stop"`
+	} `json:"lsn"`
+	Timestamp struct {
+		Start int64 `json:"start"`
+		Stop  int64 `json:"stop"`
+	} `json:"timestamp"`
+}
+
+// BackupState is the state of the backup started by Sidecar.StartBackup
+type BackupState struct {
+	Finished bool
+	ExitCode int
+	Output   string
+}
+
+// ParseInfo parses `pgbackrest info --output=json` and returns operator's stanza
+func ParseInfo(data []byte) (*StanzaInfo, error) {
+	var stanzas []StanzaInfo
+	if err := json.Unmarshal(data, &stanzas); err != nil {
+		return nil, fmt.Errorf("cannot parse pgBackRest info: %w", err)
+	}
+	for idx := range stanzas {
+		if stanzas[idx].Name == Stanza {
+			return &stanzas[idx], nil
+		}
+	}
+	return nil, fmt.Errorf("stanza %s is not found in pgBackRest info", Stanza)
+}
+
+// FindBackup returns backup started for PostgresBackup with given UID
+func (s *StanzaInfo) FindBackup(id string) *BackupInfo {
+	for idx := range s.Backup {
+		if s.Backup[idx].Annotation[annotationKey] == id {
+			return &s.Backup[idx]
+		}
+	}
+	return nil
+}
+
+// HasBackup returns true if backup set with the label exists in the repository and is not marked
+// as erroneous, zero repo matches any repository
+func (s *StanzaInfo) HasBackup(label string, repo int) bool {
+	for _, backup := range s.Backup {
+		if backup.Label == label && backup.InRepo(repo) {
+			return !backup.Error
+		}
+	}
+	return false
+}
+
+// HasBackups returns true if the repository contains at least one backup set, zero repo matches any repository
+func (s *StanzaInfo) HasBackups(repo int) bool {
+	for _, backup := range s.Backup {
+		if backup.InRepo(repo) {
+			return true
+		}
+	}
+	return false
+}
+
+// InRepo returns true if the backup set is stored in the repository, zero repo matches any repository
+func (b *BackupInfo) InRepo(repo int) bool {
+	return repo == 0 || b.Database.RepoKey == repo
+}
+
+// IsBackupRunning returns true if some backup holds the stanza lock
+func (s *StanzaInfo) IsBackupRunning() bool {
+	return s.Status.Lock.Backup.Held
+}
+
+// GetInfo returns stanza info from pgBackRest sidecar of the pod
+func GetInfo(executor Executor, podName string) (*StanzaInfo, error) {
+	command := fmt.Sprintf("pgbackrest --stanza=%s info --output=json", Stanza)
+	stdout, stderr, err := executor.ExecCmdOnPod(podName, namespace, ContainerName, command)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get pgBackRest info: %w, stderr: %s", err, stderr)
+	}
+	return ParseInfo([]byte(stdout))
+}
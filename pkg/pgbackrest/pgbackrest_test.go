@@ -0,0 +1,191 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pgbackrest
+
+import (
+	"fmt"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+
+	v1 "github.com/Netcracker/pgskipper-operator/api/patroni/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const infoOutput = `[{"name":"patroni","backup":[
+{"label":"20240919-000001F","type":"full","error":false,"annotation":{"postgres-backup":"uid-1"},"database":{"repo-key":1}},
+{"label":"20240919-000001F_20240920-000001I","type":"incr","error":true,"database":{"repo-key":1}},
+{"label":"20240921-000001F","type":"full","error":false,"database":{"repo-key":2}}],
+"status":{"code":0,"message":"ok","lock":{"backup":{"held":true}}}}]`
+
+// fakeExecutor emulates exec into pgBackRest sidecar container: it records executed commands
+// and answers info command with canned output
+type fakeExecutor struct {
+	commands []string
+	err      error
+}
+
+func (f *fakeExecutor) ExecCmdOnPod(podName string, namespace string, container string, command string) (string, string, error) {
+	if container != ContainerName {
+		return "", "", fmt.Errorf("unexpected container %s", container)
+	}
+	f.commands = append(f.commands, command)
+	if f.err != nil {
+		return "", "exec failed", f.err
+	}
+	if strings.Contains(command, " info --output=json") {
+		return infoOutput, "", nil
+	}
+	return "", "", nil
+}
+
+func TestGetInfo(t *testing.T) {
+	executor := &fakeExecutor{}
+	info, err := GetInfo(executor, "pg-patroni-node1-0")
+	if err != nil {
+		t.Fatalf("cannot get info: %v", err)
+	}
+	if !info.IsBackupRunning() {
+		t.Errorf("backup lock is not detected")
+	}
+	if backup := info.FindBackup("uid-1"); backup == nil || backup.Label != "20240919-000001F" {
+		t.Errorf("backup of uid-1 is not found: %v", backup)
+	}
+	tests := []struct {
+		label    string
+		repo     int
+		expected bool
+	}{
+		{label: "20240919-000001F", repo: 0, expected: true},
+		{label: "20240919-000001F", repo: 1, expected: true},
+		{label: "20240919-000001F", repo: 2, expected: false},
+		{label: "20240919-000001F_20240920-000001I", repo: 1, expected: false},
+		{label: "20240921-000001F", repo: 2, expected: true},
+	}
+	for _, tt := range tests {
+		if has := info.HasBackup(tt.label, tt.repo); has != tt.expected {
+			t.Errorf("HasBackup(%s, %d) = %t, expected %t", tt.label, tt.repo, has, tt.expected)
+		}
+	}
+	if info.HasBackups(3) {
+		t.Errorf("repo3 has no backups")
+	}
+}
+
+func TestGetInfoExecError(t *testing.T) {
+	executor := &fakeExecutor{err: fmt.Errorf("container not found")}
+	if _, err := GetInfo(executor, "pg-patroni-node1-0"); err == nil {
+		t.Errorf("exec error is ignored")
+	}
+}
+
+func TestValidateLabel(t *testing.T) {
+	tests := []struct {
+		label string
+		valid bool
+	}{
+		{label: "", valid: true},
+		{label: "20240919-000001F", valid: true},
+		{label: "20240919-000001F_20240920-000001I", valid: true},
+		{label: "20240919-000001F_20240920-000001D", valid: true},
+		{label: "20240919-000001I", valid: false},
+		{label: "20240919-000001F_20240920-000001F", valid: false},
+		{label: "20240919-000001F; rm -rf /", valid: false},
+		{label: "latest", valid: false},
+	}
+	for _, tt := range tests {
+		if err := ValidateLabel(tt.label); (err == nil) != tt.valid {
+			t.Errorf("ValidateLabel(%q) = %v, expected valid: %t", tt.label, err, tt.valid)
+		}
+	}
+}
+
+func TestRestoreOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		label    string
+		repo     int
+		target   *v1.RestoreTarget
+		expected []string
+	}{
+		{
+			name:     "latest backup",
+			expected: []string{"--type=immediate", "--target-action=promote"},
+		},
+		{
+			name:     "backup set",
+			label:    "20240919-000001F",
+			repo:     2,
+			expected: []string{"--repo=2", "--set='20240919-000001F'", "--type=immediate", "--target-action=promote"},
+		},
+		{
+			name:   "point in time",
+			label:  "20240919-000001F",
+			target: &v1.RestoreTarget{Type: "time", Value: "2024-10-23 14:11:04+00", Exclusive: true},
+			expected: []string{"--set='20240919-000001F'", "--type=time", "--target='2024-10-23 14:11:04+00'",
+				"--target-exclusive", "--target-action=promote"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if options := RestoreOptions(tt.label, tt.repo, tt.target); !reflect.DeepEqual(options, tt.expected) {
+				t.Errorf("options are %v, expected %v", options, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRestoreOptionsAreQuoted(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+	label := "x'; echo injected; '"
+	target := &v1.RestoreTarget{Type: "name", Value: "$(echo injected)"}
+	script := "printf '%s\\n' " + strings.Join(RestoreOptions(label, 0, target), " ")
+	output, err := exec.Command("sh", "-c", script).Output()
+	if err != nil {
+		t.Fatalf("cannot run script: %v", err)
+	}
+	expected := "--set=" + label + "\n--type=name\n--target=$(echo injected)\n--target-action=promote\n"
+	if string(output) != expected {
+		t.Errorf("options are expanded by shell: %q, expected %q", output, expected)
+	}
+}
+
+func TestGetRestorePodDropsOtherMembers(t *testing.T) {
+	sts := &appsv1.StatefulSet{}
+	sts.Spec.Template.Spec.Containers = []corev1.Container{{Name: "pg-patroni-node10", Env: []corev1.EnvVar{
+		{Name: "PGBACKREST_PG1_PATH", Value: "/var/lib/pgsql/data/postgresql_node10/"},
+		{Name: "PGBACKREST_STANZA", Value: "patroni"},
+		{Name: "PGBACKREST_PG2_HOST", Value: "pg-patroni-node1-0.backrest-headless"},
+		{Name: "PGBACKREST_PG2_PATH", Value: "/var/lib/pgsql/data/postgresql_node1/"},
+		{Name: "PGBACKREST_PG10_HOST", Value: "pg-patroni-node11-0.backrest-headless"},
+		{Name: "PGBACKREST_PG10_HOST_PORT", Value: "3022"},
+		{Name: "PGBACKREST_BACKUP_STANDBY", Value: "prefer"},
+	}}}
+	pod := getRestorePod(sts, "pg-restore-uid-1", "true")
+	if pod.Name != "pg-restore-uid-1" {
+		t.Errorf("restore pod name is %s, expected pg-restore-uid-1", pod.Name)
+	}
+	var names []string
+	for _, env := range pod.Spec.Containers[0].Env {
+		names = append(names, env.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"PGBACKREST_PG1_PATH", "PGBACKREST_STANZA"}) {
+		t.Errorf("restore pod has variables %v, expected only local ones", names)
+	}
+}
@@ -0,0 +1,452 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pgbackrest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	v1 "github.com/Netcracker/pgskipper-operator/api/patroni/v1"
+	"github.com/Netcracker/pgskipper-operator/pkg/helper"
+	"github.com/Netcracker/pgskipper-operator/pkg/upgrade"
+	opUtil "github.com/Netcracker/pgskipper-operator/pkg/util"
+	"go.uber.org/zap"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var (
+	RestoreLabels     = map[string]string{"app": "pg-restore"}
+	backupLabelRegexp = regexp.MustCompile(`^\d{8}-\d{6}F(_\d{8}-\d{6}[DI])?$`)
+)
+
+// restoreScript restores data directory of the member and replays WAL up to the target
+// with standalone PostgreSQL, so Patroni starts the member from already promoted data.
+// Recovery is not left to Patroni, because it drops recovery target settings.
+const restoreScript = `set -e
+PGDATA="${PGBACKREST_PG1_PATH}"
+pgbackrest --stanza=%s --delta %s restore
+pg_ctl -D "${PGDATA}" -w -t 86400 start -o "-c listen_addresses='' -c unix_socket_directories=/tmp"
+until [ "$(psql -h /tmp -U postgres -d postgres -Atc 'select pg_is_in_recovery()')" = "f" ]; do
+  pg_ctl -D "${PGDATA}" status > /dev/null
+  sleep 5
+done
+psql -h /tmp -U postgres -d postgres -c checkpoint
+pg_ctl -D "${PGDATA}" -w -t 600 stop -m fast
+`
+
+// Steps of running restore. Each step starts an action and the next one waits for its result,
+// the step is recorded in PostgresRestore status, so restore is continued after operator restart.
+const (
+	StepStopping         = "Stopping"
+	StepRestoring        = "Restoring"
+	StepStartingLeader   = "StartingLeader"
+	StepStartingReplicas = "StartingReplicas"
+)
+
+const (
+	cleanerContainerName = "pg-cleaner"
+	// stopTimeout is the time given to Patroni pods to terminate
+	stopTimeout = 5 * time.Minute
+	// restoreTimeout is the time given to the restore pod to complete
+	restoreTimeout = 240 * time.Minute
+)
+
+type Restore struct {
+	helper   *helper.PatroniHelper
+	executor Executor
+	upgrade  *upgrade.Upgrade
+}
+
+func NewRestore(ph *helper.PatroniHelper, executor Executor) *Restore {
+	return &Restore{helper: ph, executor: executor, upgrade: upgrade.New(ph.GetClient(), ph)}
+}
+
+// ValidateTarget checks restore target before the cluster is stopped
+func ValidateTarget(target *v1.RestoreTarget) error {
+	if target == nil {
+		return nil
+	}
+	switch target.Type {
+	case "time", "lsn", "name":
+	default:
+		return fmt.Errorf("unknown restore target type %q, expected time, lsn or name", target.Type)
+	}
+	if strings.TrimSpace(target.Value) == "" {
+		return fmt.Errorf("value of %s restore target is empty", target.Type)
+	}
+	return nil
+}
+
+// ValidateLabel checks that the label is a pgBackRest backup set label, e.g. 20240919-000001F
+// or 20240919-000001F_20240920-000001I for incremental and differential backups
+func ValidateLabel(label string) error {
+	if label != "" && !backupLabelRegexp.MatchString(label) {
+		return fmt.Errorf("invalid pgBackRest backup label %q", label)
+	}
+	return nil
+}
+
+// RestoreOptions returns pgbackrest restore options for the backup set and target,
+// without target the backup is recovered to the end of the backup
+func RestoreOptions(label string, repo int, target *v1.RestoreTarget) []string {
+	var options []string
+	if repo != 0 {
+		options = append(options, fmt.Sprintf("--repo=%d", repo))
+	}
+	if label != "" {
+		options = append(options, "--set="+shellQuote(label))
+	}
+	if target == nil {
+		options = append(options, "--type=immediate")
+	} else {
+		options = append(options, "--type="+target.Type, "--target="+shellQuote(target.Value))
+		if target.Exclusive {
+			options = append(options, "--target-exclusive")
+		}
+	}
+	return append(options, "--target-action=promote")
+}
+
+// Proceed performs the next step of restore of the leader from pgBackRest repository and reinitialization
+// of other members from it, true is returned when restore is completed. It uses the same flow as major upgrade:
+// cluster is scaled down, restore pod is run on the leader volume, then the leader and the replicas are started
+// again. Proceed doesn't wait for pods, it's called again until restore is completed, progress is kept in status.
+func (r *Restore) Proceed(cr *v1.PatroniCore, cluster *v1.PatroniClusterSettings, id string, target *v1.RestoreTarget, status *v1.PostgresRestoreStatus) (bool, error) {
+	switch status.Step {
+	case "":
+		return false, r.stop(cluster, target, status)
+	case StepStopping:
+		return false, r.startRestorePod(cluster, id, target, status)
+	case StepRestoring:
+		return false, r.startLeader(cluster, status)
+	case StepStartingLeader:
+		return false, r.startReplicas(cr, cluster, status)
+	case StepStartingReplicas:
+		return r.completeStart(cr, cluster, status)
+	}
+	return false, fmt.Errorf("unknown restore step %q", status.Step)
+}
+
+// stop checks that the backup exists and scales Patroni cluster down
+func (r *Restore) stop(cluster *v1.PatroniClusterSettings, target *v1.RestoreTarget, status *v1.PostgresRestoreStatus) error {
+	if err := ValidateTarget(target); err != nil {
+		return err
+	}
+	if err := ValidateLabel(status.BackupLabel); err != nil {
+		return err
+	}
+	masterPods, err := r.helper.GetPodsByLabel(cluster.PatroniMasterSelectors)
+	if err != nil || len(masterPods.Items) == 0 {
+		logger.Error("Can't get Patroni Leader, failing restore", zap.Error(err))
+		return errors.New("patroni cluster has no leader, restore is not possible")
+	}
+	leaderPod := masterPods.Items[0]
+	if err := r.checkBackupExists(leaderPod.Name, status.BackupLabel, status.Repo); err != nil {
+		return err
+	}
+	leaderName := leaderPod.Spec.Containers[0].Name
+	if _, err := r.getStatefulset(cluster.ClusterName, leaderName); err != nil {
+		return err
+	}
+
+	logger.Info(fmt.Sprintf("Stopping Patroni cluster %s for restore", cluster.ClusterName))
+	if err := r.helper.UpdatePatroniReplicas(0, cluster.ClusterName); err != nil {
+		return err
+	}
+	status.Leader = leaderName
+	setStep(status, StepStopping)
+	return nil
+}
+
+// startRestorePod runs restore pod on the volume of the leader when all Patroni pods are stopped
+func (r *Restore) startRestorePod(cluster *v1.PatroniClusterSettings, id string, target *v1.RestoreTarget, status *v1.PostgresRestoreStatus) error {
+	patroniPods, err := r.helper.GetNamespacePodListBySelectors(cluster.PatroniCommonLabels)
+	if err != nil {
+		return err
+	}
+	if len(patroniPods.Items) > 0 {
+		logger.Info(fmt.Sprintf("Waiting for %d Patroni pods to stop", len(patroniPods.Items)))
+		return checkStepTimeout(status, stopTimeout)
+	}
+	leaderSts, err := r.getStatefulset(cluster.ClusterName, status.Leader)
+	if err != nil {
+		return err
+	}
+	script := fmt.Sprintf(restoreScript, Stanza, strings.Join(RestoreOptions(status.BackupLabel, status.Repo, target), " "))
+	// name of the pod is stable, so the pod is not created twice if status is not saved
+	restorePod := getRestorePod(leaderSts, "pg-restore-"+id, script)
+	if err := r.helper.CreatePod(restorePod); err != nil && !k8serrors.IsAlreadyExists(err) {
+		return err
+	}
+	status.RestorePod = restorePod.Name
+	setStep(status, StepRestoring)
+	return nil
+}
+
+// startLeader starts the restored member when restore pod is succeeded
+func (r *Restore) startLeader(cluster *v1.PatroniClusterSettings, status *v1.PostgresRestoreStatus) error {
+	restorePod := &corev1.Pod{}
+	if err := r.helper.GetClient().Get(context.TODO(), types.NamespacedName{Name: status.RestorePod, Namespace: namespace}, restorePod); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return fmt.Errorf("restore pod %s is not found, Patroni cluster is left stopped", status.RestorePod)
+		}
+		return err
+	}
+	switch restorePod.Status.Phase {
+	case corev1.PodSucceeded:
+	case corev1.PodFailed:
+		// data of the leader is possibly partially restored, so cluster is not started automatically
+		return fmt.Errorf("restore pod %s is not succeeded, state: %s, Patroni cluster is left stopped: %s",
+			restorePod.Name, restorePod.Status.Phase, getTerminationMessage(restorePod))
+	default:
+		logger.Info(fmt.Sprintf("Waiting for restore pod %s to complete, pod phase: %s", restorePod.Name, restorePod.Status.Phase))
+		if err := checkStepTimeout(status, restoreTimeout); err != nil {
+			return fmt.Errorf("%w, Patroni cluster is left stopped", err)
+		}
+		return nil
+	}
+	if err := r.helper.DeletePod(restorePod); err != nil {
+		logger.Error("Can't delete restore pod", zap.Error(err))
+	}
+
+	if err := r.upgrade.CleanInitializeKey(cluster.ClusterName); err != nil {
+		return err
+	}
+	leaderSts, err := r.getStatefulset(cluster.ClusterName, status.Leader)
+	if err != nil {
+		return err
+	}
+	replicas := int32(1)
+	leaderSts.Spec.Replicas = &replicas
+	if err := r.helper.CreateOrUpdateStatefulset(leaderSts, false); err != nil {
+		logger.Error("Can't start restored Patroni member", zap.Error(err))
+		return err
+	}
+	setStep(status, StepStartingLeader)
+	return nil
+}
+
+// startReplicas starts other members with cleaner init container when the restored member becomes the leader,
+// so they are reinitialized from it
+func (r *Restore) startReplicas(cr *v1.PatroniCore, cluster *v1.PatroniClusterSettings, status *v1.PostgresRestoreStatus) error {
+	leaders, err := r.countRunningPods(cluster.PatroniMasterSelectors)
+	if err != nil {
+		return err
+	}
+	if leaders != 1 {
+		logger.Info(fmt.Sprintf("Waiting for restored member %s to become the leader", status.Leader))
+		return checkStepTimeout(status, startTimeout())
+	}
+	statefulsets, err := r.helper.GetStatefulsetByNameRegExp(fmt.Sprintf("pg-%s-node", cluster.ClusterName))
+	if err != nil {
+		return err
+	}
+	for _, sts := range statefulsets {
+		if sts.Name == status.Leader {
+			continue
+		}
+		if !hasInitContainer(sts, cleanerContainerName) {
+			cleanerInitContainer := r.upgrade.GetCleanerInitContainer(cr.Spec.Patroni.DockerImage)
+			sts.Spec.Template.Spec.InitContainers = append(cleanerInitContainer, sts.Spec.Template.Spec.InitContainers...)
+		}
+		replicas := int32(1)
+		sts.Spec.Template.Spec.Containers[0].Image = cr.Spec.Patroni.DockerImage
+		sts.Spec.Replicas = &replicas
+		if err := r.helper.CreateOrUpdateStatefulset(sts, false); err != nil {
+			logger.Error("Can't start Patroni member", zap.Error(err))
+			return err
+		}
+	}
+	setStep(status, StepStartingReplicas)
+	return nil
+}
+
+// completeStart removes cleaner init container from the members when all of them are running
+func (r *Restore) completeStart(cr *v1.PatroniCore, cluster *v1.PatroniClusterSettings, status *v1.PostgresRestoreStatus) (bool, error) {
+	// members are checked by labels only with Kubernetes DCS, the same as in opUtil.WaitForPatroni
+	if cr.Spec.Patroni.Dcs.Type == "kubernetes" {
+		leaders, err := r.countRunningPods(cluster.PatroniMasterSelectors)
+		if err != nil {
+			return false, err
+		}
+		replicas, err := r.countRunningPods(cluster.PatroniReplicasSelector)
+		if err != nil {
+			return false, err
+		}
+		if leaders != 1 || replicas != cr.Spec.Patroni.Replicas-1 {
+			logger.Info(fmt.Sprintf("Waiting for Patroni members to start, leaders: %d, replicas: %d", leaders, replicas))
+			return false, checkStepTimeout(status, startTimeout())
+		}
+	}
+	statefulsets, err := r.helper.GetStatefulsetByNameRegExp(fmt.Sprintf("pg-%s-node", cluster.ClusterName))
+	if err != nil {
+		return false, err
+	}
+	for _, sts := range statefulsets {
+		if !hasInitContainer(sts, cleanerContainerName) {
+			continue
+		}
+		var initContainers []corev1.Container
+		for _, container := range sts.Spec.Template.Spec.InitContainers {
+			if container.Name != cleanerContainerName {
+				initContainers = append(initContainers, container)
+			}
+		}
+		sts.Spec.Template.Spec.InitContainers = initContainers
+		if err := r.helper.CreateOrUpdateStatefulset(sts, false); err != nil {
+			logger.Error(fmt.Sprintf("Can't delete init container %s", cleanerContainerName), zap.Error(err))
+			return false, err
+		}
+	}
+	logger.Info(fmt.Sprintf("Patroni cluster %s is restored, leader: %s", cluster.ClusterName, status.Leader))
+	return true, nil
+}
+
+func (r *Restore) countRunningPods(selectors map[string]string) (int, error) {
+	pods, err := r.helper.GetPodsByLabel(selectors)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == corev1.PodRunning {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func setStep(status *v1.PostgresRestoreStatus, step string) {
+	logger.Info(fmt.Sprintf("Restore step: %s", step))
+	now := metav1.Now()
+	status.Step = step
+	status.StepStartTime = &now
+}
+
+// checkStepTimeout returns error if the current step lasts longer than the timeout
+func checkStepTimeout(status *v1.PostgresRestoreStatus, timeout time.Duration) error {
+	if status.StepStartTime != nil && time.Since(status.StepStartTime.Time) > timeout {
+		return fmt.Errorf("%s step of restore is not completed in %v", status.Step, timeout)
+	}
+	return nil
+}
+
+// startTimeout is the time given to Patroni members to start, the same as in opUtil.WaitForPatroni
+func startTimeout() time.Duration {
+	return time.Duration(opUtil.GetEnvAsInt("WAIT_TIMEOUT", 10)) * time.Minute
+}
+
+func hasInitContainer(sts *appsv1.StatefulSet, name string) bool {
+	for _, container := range sts.Spec.Template.Spec.InitContainers {
+		if container.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Restore) checkBackupExists(podName string, label string, repo int) error {
+	info, err := GetInfo(r.executor, podName)
+	if err != nil {
+		return err
+	}
+	if label != "" && !info.HasBackup(label, repo) {
+		return fmt.Errorf("backup %s is not found in pgBackRest repository", label)
+	}
+	if !info.HasBackups(repo) {
+		return errors.New("pgBackRest repository has no backups")
+	}
+	return nil
+}
+
+func (r *Restore) getStatefulset(clusterName string, name string) (*appsv1.StatefulSet, error) {
+	statefulsets, err := r.helper.GetStatefulsetByNameRegExp(fmt.Sprintf("pg-%s-node", clusterName))
+	if err != nil {
+		return nil, err
+	}
+	for _, sts := range statefulsets {
+		if sts.Name == name {
+			return sts, nil
+		}
+	}
+	return nil, fmt.Errorf("statefulset %s is not found", name)
+}
+
+func getTerminationMessage(pod *corev1.Pod) string {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.State.Terminated != nil {
+			return strings.TrimSpace(status.State.Terminated.Message)
+		}
+	}
+	return ""
+}
+
+// getRestorePod returns pod which runs on volumes of the member with its image and environment
+func getRestorePod(sts *appsv1.StatefulSet, name string, script string) *corev1.Pod {
+	podSpec := sts.Spec.Template.Spec
+	patroniContainer := podSpec.Containers[0]
+	var env []corev1.EnvVar
+	for _, ev := range patroniContainer.Env {
+		// restore is performed only on the local data directory, options of other members are dropped
+		isOtherMember := strings.HasPrefix(ev.Name, "PGBACKREST_PG") && !strings.HasPrefix(ev.Name, "PGBACKREST_PG1_")
+		if isOtherMember || ev.Name == "PGBACKREST_BACKUP_STANDBY" {
+			continue
+		}
+		env = append(env, ev)
+	}
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    RestoreLabels,
+		},
+		Spec: corev1.PodSpec{
+			RestartPolicy:      corev1.RestartPolicyNever,
+			NodeSelector:       podSpec.NodeSelector,
+			Affinity:           podSpec.Affinity,
+			Tolerations:        podSpec.Tolerations,
+			SecurityContext:    podSpec.SecurityContext,
+			ServiceAccountName: podSpec.ServiceAccountName,
+			ImagePullSecrets:   podSpec.ImagePullSecrets,
+			PriorityClassName:  podSpec.PriorityClassName,
+			Volumes:            podSpec.Volumes,
+			Containers: []corev1.Container{
+				{
+					Name:                     "pg-restore",
+					Image:                    patroniContainer.Image,
+					ImagePullPolicy:          corev1.PullIfNotPresent,
+					SecurityContext:          patroniContainer.SecurityContext,
+					Command:                  []string{"sh", "-c", script},
+					Env:                      env,
+					VolumeMounts:             patroniContainer.VolumeMounts,
+					Resources:                patroniContainer.Resources,
+					TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+				},
+			},
+		},
+	}
+}
+
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
@@ -0,0 +1,129 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pgbackrest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// SidecarPort is the port of REST API of pgbackrest-sidecar container
+	SidecarPort = 3000
+	// headlessService resolves Patroni pods by their names
+	headlessService = "backrest-headless"
+
+	backupRunning  = "running"
+	backupFinished = "finished"
+)
+
+// BackupRequest is the body of POST /backup of sidecar API. Sidecar runs pgbackrest backup
+// of the type with the annotations in background and keeps its state by id.
+type BackupRequest struct {
+	Id         string            `json:"id"`
+	Type       string            `json:"type"`
+	Repo       int               `json:"repo,omitempty"`
+	Annotation map[string]string `json:"annotation,omitempty"`
+}
+
+// BackupStatus is the response of GET /backup/<id> of sidecar API
+type BackupStatus struct {
+	Id string `json:"id"`
+	// Status is running or finished
+	Status string `json:"status"`
+	// ExitCode is the exit code of finished pgbackrest process
+	ExitCode int `json:"exitCode"`
+	// Output is the tail of pgbackrest output
+	Output string `json:"output,omitempty"`
+}
+
+// Sidecar is a client of REST API of pgbackrest-sidecar container
+type Sidecar struct {
+	client *http.Client
+	// url returns address of sidecar API in the pod
+	url func(podName string) string
+}
+
+func NewSidecar(url func(podName string) string) *Sidecar {
+	return &Sidecar{client: &http.Client{Timeout: 30 * time.Second}, url: url}
+}
+
+// SidecarUrl returns address of sidecar API in Patroni pod, the pod is resolved by headless service
+func SidecarUrl(podName string) string {
+	return fmt.Sprintf("http://%s.%s:%d", podName, headlessService, SidecarPort)
+}
+
+// StartBackup asks sidecar of the pod to start backup and returns immediately,
+// the backup is marked with annotation, so it can be found in repository by id
+func (s *Sidecar) StartBackup(podName string, backupType string, repo int, id string) error {
+	logger.Info(fmt.Sprintf("Starting pgBackRest %s backup on %s", backupType, podName))
+	body, err := json.Marshal(BackupRequest{
+		Id:         id,
+		Type:       backupType,
+		Repo:       repo,
+		Annotation: map[string]string{annotationKey: id},
+	})
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Post(s.url(podName)+"/backup", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("cannot start backup on %s: %w", podName, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("cannot start backup on %s: %s", podName, responseError(resp))
+	}
+	return nil
+}
+
+// GetBackupState returns state of the backup started on the pod, error is returned
+// if sidecar doesn't know the backup, e.g. the pod was restarted
+func (s *Sidecar) GetBackupState(podName string, id string) (BackupState, error) {
+	resp, err := s.client.Get(s.url(podName) + "/backup/" + url.PathEscape(id))
+	if err != nil {
+		return BackupState{}, fmt.Errorf("cannot get state of backup %s on %s: %w", id, podName, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return BackupState{}, fmt.Errorf("cannot get state of backup %s on %s: %s", id, podName, responseError(resp))
+	}
+	var status BackupStatus
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return BackupState{}, fmt.Errorf("cannot parse state of backup %s: %w", id, err)
+	}
+	switch status.Status {
+	case backupRunning:
+		return BackupState{Output: status.Output}, nil
+	case backupFinished:
+		return BackupState{Finished: true, ExitCode: status.ExitCode, Output: status.Output}, nil
+	}
+	return BackupState{}, fmt.Errorf("unknown status %q of backup %s", status.Status, id)
+}
+
+func responseError(resp *http.Response) string {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return strings.TrimSpace(resp.Status + " " + string(body))
+}
@@ -0,0 +1,161 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pgbackrest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeSidecarServer emulates REST API of pgbackrest-sidecar, backups are kept by id
+type fakeSidecarServer struct {
+	mu       sync.Mutex
+	requests []BackupRequest
+	backups  map[string]BackupStatus
+}
+
+func (f *fakeSidecarServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case req.Method == http.MethodPost && req.URL.Path == "/backup":
+		var request BackupRequest
+		if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.requests = append(f.requests, request)
+		for _, backup := range f.backups {
+			if backup.Status == backupRunning {
+				http.Error(w, "backup is already running", http.StatusConflict)
+				return
+			}
+		}
+		f.backups[request.Id] = BackupStatus{Id: request.Id, Status: backupRunning}
+		w.WriteHeader(http.StatusAccepted)
+	case req.Method == http.MethodGet && strings.HasPrefix(req.URL.Path, "/backup/"):
+		backup, ok := f.backups[strings.TrimPrefix(req.URL.Path, "/backup/")]
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(backup)
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+func (f *fakeSidecarServer) finish(id string, exitCode int, output string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.backups[id] = BackupStatus{Id: id, Status: backupFinished, ExitCode: exitCode, Output: output}
+}
+
+func newTestSidecar(t *testing.T) (*Sidecar, *fakeSidecarServer, *[]string) {
+	fake := &fakeSidecarServer{backups: map[string]BackupStatus{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	var pods []string
+	sidecar := NewSidecar(func(podName string) string {
+		pods = append(pods, podName)
+		return server.URL
+	})
+	return sidecar, fake, &pods
+}
+
+func TestSidecarUrl(t *testing.T) {
+	if url := SidecarUrl("pg-patroni-node1-0"); url != "http://pg-patroni-node1-0.backrest-headless:3000" {
+		t.Errorf("sidecar url is %s", url)
+	}
+}
+
+func TestSidecarBackupLifecycle(t *testing.T) {
+	sidecar, fake, pods := newTestSidecar(t)
+	if err := sidecar.StartBackup("pg-patroni-node1-0", "full", 2, "uid-2"); err != nil {
+		t.Fatalf("cannot start backup: %v", err)
+	}
+	expectedRequest := BackupRequest{Id: "uid-2", Type: "full", Repo: 2, Annotation: map[string]string{"postgres-backup": "uid-2"}}
+	if len(fake.requests) != 1 || !reflect.DeepEqual(fake.requests[0], expectedRequest) {
+		t.Errorf("backup requests are %+v, expected %+v", fake.requests, expectedRequest)
+	}
+	if !reflect.DeepEqual(*pods, []string{"pg-patroni-node1-0"}) {
+		t.Errorf("requests are sent to %v", *pods)
+	}
+
+	state, err := sidecar.GetBackupState("pg-patroni-node1-0", "uid-2")
+	if err != nil {
+		t.Fatalf("cannot get backup state: %v", err)
+	}
+	if state.Finished {
+		t.Errorf("running backup is finished")
+	}
+
+	fake.finish("uid-2", 0, "backup command end: completed successfully")
+	state, err = sidecar.GetBackupState("pg-patroni-node1-0", "uid-2")
+	if err != nil {
+		t.Fatalf("cannot get backup state: %v", err)
+	}
+	expected := BackupState{Finished: true, ExitCode: 0, Output: "backup command end: completed successfully"}
+	if !reflect.DeepEqual(state, expected) {
+		t.Errorf("backup state is %+v, expected %+v", state, expected)
+	}
+}
+
+func TestSidecarFailedBackup(t *testing.T) {
+	sidecar, fake, _ := newTestSidecar(t)
+	if err := sidecar.StartBackup("pg-patroni-node1-0", "incr", 0, "uid-3"); err != nil {
+		t.Fatalf("cannot start backup: %v", err)
+	}
+	if fake.requests[0].Repo != 0 || fake.requests[0].Type != "incr" {
+		t.Errorf("backup request is %+v", fake.requests[0])
+	}
+	fake.finish("uid-3", 56, "ERROR: [056]: unable to find primary cluster")
+	state, err := sidecar.GetBackupState("pg-patroni-node1-0", "uid-3")
+	if err != nil {
+		t.Fatalf("cannot get backup state: %v", err)
+	}
+	if !state.Finished || state.ExitCode != 56 || !strings.Contains(state.Output, "unable to find primary") {
+		t.Errorf("backup state is %+v, expected failed backup", state)
+	}
+}
+
+func TestSidecarErrors(t *testing.T) {
+	sidecar, fake, _ := newTestSidecar(t)
+	if err := sidecar.StartBackup("pg-patroni-node1-0", "full", 0, "uid-4"); err != nil {
+		t.Fatalf("cannot start backup: %v", err)
+	}
+	err := sidecar.StartBackup("pg-patroni-node1-0", "full", 0, "uid-5")
+	if err == nil || !strings.Contains(err.Error(), "409") || !strings.Contains(err.Error(), "already running") {
+		t.Errorf("conflict is not reported: %v", err)
+	}
+	if _, err := sidecar.GetBackupState("pg-patroni-node1-0", "uid-5"); err == nil {
+		t.Errorf("state of unknown backup is returned")
+	}
+
+	fake.backups["uid-4"] = BackupStatus{Id: "uid-4", Status: "queued"}
+	if _, err := sidecar.GetBackupState("pg-patroni-node1-0", "uid-4"); err == nil {
+		t.Errorf("unknown status is accepted")
+	}
+
+	unreachable := NewSidecar(func(podName string) string { return "http://127.0.0.1:1" })
+	if err := unreachable.StartBackup("pg-patroni-node1-0", "full", 0, "uid-6"); err == nil {
+		t.Errorf("unreachable sidecar is not reported")
+	}
+}
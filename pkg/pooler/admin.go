@@ -0,0 +1,272 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pooler
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "github.com/Netcracker/pgskipper-operator/api/apps/v1"
+	"github.com/Netcracker/pgskipper-operator/pkg/helper"
+	"github.com/Netcracker/pgskipper-operator/pkg/util"
+	"github.com/jackc/pgconn"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	adminConnectTimeout = 10 * time.Second
+	// reloadTimeout covers the delay of kubelet refreshing the mounted auth_file and pgbouncer.ini
+	reloadTimeout = 3 * time.Minute
+)
+
+// GetCredentialsSecret returns Secret with username and password of the pooler user mounted to the pooler pods
+func GetCredentialsSecret(creds *PgBouncerCreds) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      CredentialsSecretName,
+			Namespace: util.GetNameSpace(),
+			Labels:    labels,
+		},
+		Data: map[string][]byte{
+			"username": []byte(creds.username),
+			"password": []byte(creds.password),
+		},
+		Type: corev1.SecretTypeOpaque,
+	}
+}
+
+// GetMountedCreds returns credentials of the Secret mounted to the pooler pods, nil if the Secret doesn't exist yet
+func GetMountedCreds(hp *helper.Helper) (*PgBouncerCreds, error) {
+	secret, err := hp.GetSecret(CredentialsSecretName)
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &PgBouncerCreds{
+		username: string(secret.Data["username"]),
+		password: string(secret.Data["password"]),
+	}, nil
+}
+
+// ReloadPgBouncer makes running pooler pods re-read auth_file with the new password by RELOAD command
+// of the admin console. kubelet refreshes the mounted Secret with a delay, so RELOAD is repeated
+// with the old password until the new one is accepted.
+func ReloadPgBouncer(hp *helper.Helper, spec v1.Pooler, oldCreds *PgBouncerCreds, creds *PgBouncerCreds) error {
+	// both poolers mount the same Secret, ReadOnly pooler has no pods when it's not installed
+	for _, instance := range []Instance{Primary, ReadOnly} {
+		if err := instance.reloadPods(hp, spec, oldCreds, func(ctx context.Context, host string) bool {
+			return execAdminCommand(ctx, host, creds, "") == nil
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReloadConfig applies the change of pgbouncer.ini classified as ConfigReload by RELOAD command. kubelet refreshes
+// the mounted ConfigMap with a delay, so RELOAD is repeated until SHOW CONFIG and SHOW DATABASES return the new values.
+func (i Instance) ReloadConfig(hp *helper.Helper, spec v1.Pooler, creds *PgBouncerCreds, oldConfig, newConfig map[string]map[string]string) error {
+	diff := diffConfig(oldConfig, newConfig)
+	return i.reloadPods(hp, spec, creds, func(ctx context.Context, host string) bool {
+		applied, err := isConfigApplied(ctx, host, creds, diff)
+		if err != nil {
+			logger.Warn(fmt.Sprintf("Cannot check config of pooler %s", host), zap.Error(err))
+		}
+		return applied
+	})
+}
+
+// reloadPods executes RELOAD in every running pooler pod until the change is applied
+func (i Instance) reloadPods(hp *helper.Helper, spec v1.Pooler, creds *PgBouncerCreds, applied func(ctx context.Context, host string) bool) error {
+	pods, err := hp.GetNamespacePodListBySelectors(util.Merge(i.labels, spec.PodLabels))
+	if err != nil {
+		return err
+	}
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != corev1.PodRunning || pod.Status.PodIP == "" || pod.DeletionTimestamp != nil {
+			continue
+		}
+		logger.Info(fmt.Sprintf("Reloading pooler pod %s", pod.Name))
+		if err := reloadPod(pod.Status.PodIP, creds, applied); err != nil {
+			return fmt.Errorf("cannot reload pooler pod %s: %w", pod.Name, err)
+		}
+	}
+	return nil
+}
+
+func reloadPod(host string, creds *PgBouncerCreds, applied func(ctx context.Context, host string) bool) error {
+	return wait.PollUntilContextTimeout(context.Background(), 10*time.Second, reloadTimeout, true, func(ctx context.Context) (bool, error) {
+		if applied(ctx, host) {
+			return true, nil
+		}
+		if err := execAdminCommand(ctx, host, creds, "RELOAD"); err != nil {
+			logger.Warn(fmt.Sprintf("RELOAD of pooler %s failed, retrying", host), zap.Error(err))
+		}
+		return false, nil
+	})
+}
+
+// isConfigApplied compares changed parameters with SHOW CONFIG and changed databases with SHOW DATABASES
+func isConfigApplied(ctx context.Context, host string, creds *PgBouncerCreds, diff configDiff) (bool, error) {
+	if len(diff.parameters) > 0 {
+		rows, err := queryAdminConsole(ctx, host, creds, "SHOW CONFIG")
+		if err != nil {
+			return false, err
+		}
+		current := make(map[string]string, len(rows))
+		for _, row := range rows {
+			current[row["key"]] = row["value"]
+		}
+		for name, value := range diff.parameters {
+			// unknown parameters are not shown, they can't be checked
+			if currentValue, ok := current[name]; ok && !isSameConfigValue(value, currentValue) {
+				return false, nil
+			}
+		}
+	}
+	if len(diff.databases) > 0 {
+		rows, err := queryAdminConsole(ctx, host, creds, "SHOW DATABASES")
+		if err != nil {
+			return false, err
+		}
+		current := make(map[string]map[string]string, len(rows))
+		for _, row := range rows {
+			current[row["name"]] = row
+		}
+		for name, connString := range diff.databases {
+			database, ok := current[name]
+			if !ok {
+				return false, nil
+			}
+			for param, value := range parseConnString(connString) {
+				column := param
+				if param == "dbname" {
+					column = "database"
+				}
+				if currentValue, ok := database[column]; ok && !isSameConfigValue(value, currentValue) {
+					return false, nil
+				}
+			}
+		}
+	}
+	return true, nil
+}
+
+// isSameConfigValue compares the value of pgbouncer.ini with the value shown by the admin console,
+// which shows booleans as 0 and 1 and numbers without quotes
+func isSameConfigValue(configValue, shownValue string) bool {
+	configValue = strings.Trim(strings.TrimSpace(configValue), "'\"")
+	shownValue = strings.TrimSpace(shownValue)
+	if strings.EqualFold(configValue, shownValue) {
+		return true
+	}
+	switch strings.ToLower(configValue) {
+	case "on", "true", "yes":
+		configValue = "1"
+	case "off", "false", "no":
+		configValue = "0"
+	}
+	if configValue == shownValue {
+		return true
+	}
+	configNumber, configErr := strconv.ParseFloat(configValue, 64)
+	shownNumber, shownErr := strconv.ParseFloat(shownValue, 64)
+	return configErr == nil && shownErr == nil && configNumber == shownNumber
+}
+
+// parseConnString parses connection string of databases section, values can be quoted by single quotes
+func parseConnString(connString string) map[string]string {
+	params := map[string]string{}
+	rest := strings.TrimSpace(connString)
+	for rest != "" {
+		name, value, found := strings.Cut(rest, "=")
+		if !found {
+			break
+		}
+		name = strings.TrimSpace(name)
+		value = strings.TrimLeft(value, " ")
+		if strings.HasPrefix(value, "'") {
+			end := strings.Index(value[1:], "'")
+			if end < 0 {
+				break
+			}
+			params[name] = value[1 : end+1]
+			rest = strings.TrimSpace(value[end+2:])
+			continue
+		}
+		value, rest, _ = strings.Cut(value, " ")
+		params[name] = value
+		rest = strings.TrimSpace(rest)
+	}
+	return params
+}
+
+// queryAdminConsole executes SHOW command in pgbouncer admin console and returns rows by column names
+func queryAdminConsole(ctx context.Context, host string, creds *PgBouncerCreds, command string) ([]map[string]string, error) {
+	conn, err := connectAdminConsole(ctx, host, creds)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close(ctx)
+	results, err := conn.Exec(ctx, command).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	var rows []map[string]string
+	for _, result := range results {
+		for _, values := range result.Rows {
+			row := make(map[string]string, len(values))
+			for i, field := range result.FieldDescriptions {
+				row[string(field.Name)] = string(values[i])
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+// execAdminCommand connects to pgbouncer admin console and executes the command, empty command only checks login
+func execAdminCommand(ctx context.Context, host string, creds *PgBouncerCreds, command string) error {
+	conn, err := connectAdminConsole(ctx, host, creds)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+	if command == "" {
+		return nil
+	}
+	_, err = conn.Exec(ctx, command).ReadAll()
+	return err
+}
+
+func connectAdminConsole(ctx context.Context, host string, creds *PgBouncerCreds) (*pgconn.PgConn, error) {
+	config, err := pgconn.ParseConfig(fmt.Sprintf("host=%s port=%d dbname=pgbouncer sslmode=prefer", host, poolerPort))
+	if err != nil {
+		return nil, err
+	}
+	config.User = creds.username
+	config.Password = creds.password
+	config.ConnectTimeout = adminConnectTimeout
+	return pgconn.ConnectConfig(ctx, config)
+}
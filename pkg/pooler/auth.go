@@ -0,0 +1,269 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pooler
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	v1 "github.com/Netcracker/pgskipper-operator/api/apps/v1"
+	pgClient "github.com/Netcracker/pgskipper-operator/pkg/client"
+	"github.com/Netcracker/pgskipper-operator/pkg/helper"
+	"github.com/Netcracker/pgskipper-operator/pkg/util"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// AuthSchema is a schema of auth_query function, only the pooler user has access to it
+	AuthSchema      = "pgbouncer"
+	AuthQuery       = "SELECT p_user, p_password FROM pgbouncer.lookup($1)"
+	legacyAuthQuery = "SELECT p_user, p_password FROM public.lookup($1)"
+
+	// authStateConfigMapName keeps OIDs of databases which have auth_query function, so it's created only in new
+	// databases. Databases are identified by OID, so a database recreated with the same name is processed again.
+	authStateConfigMapName   = "pooler-auth-state"
+	authStateUserKey         = "user"
+	authStateDatabaseOidsKey = "databaseOids"
+	// authStateLegacyKey is set when legacy public.lookup function is kept, databases are processed again
+	// when it's changed, so the function is dropped once auth_query doesn't refer to it
+	authStateLegacyKey = "legacyLookup"
+	legacyKept         = "kept"
+
+	// lookupFunction returns password hash of login roles for auth_query, search_path is pinned,
+	// so objects of other schemas can't be substituted into SECURITY DEFINER function.
+	// Superusers are never authenticated through the pooler.
+	lookupFunction = `CREATE OR REPLACE FUNCTION pgbouncer.lookup(INOUT p_user name, OUT p_password text)
+RETURNS record LANGUAGE sql STABLE SECURITY DEFINER SET search_path = pg_catalog, pg_temp AS
+$$SELECT rolname::name, rolpassword FROM pg_catalog.pg_authid
+WHERE rolname = p_user AND rolcanlogin AND NOT rolsuper
+AND (rolvaliduntil IS NULL OR rolvaliduntil > pg_catalog.now())$$`
+)
+
+// SetUpDatabase creates the pooler user or sets its password if it's changed and creates auth_query function
+// in databases which don't have it yet
+func SetUpDatabase(hp *helper.Helper, spec v1.Pooler, creds *PgBouncerCreds, newPatroniName string, passwordChanged bool) error {
+	logger.Info("Database preparation for connection pooler started")
+	client := pgClient.GetPostgresClientForHostAndPort(newPatroniName, 5432)
+	if client == nil {
+		return fmt.Errorf("postgresql %s is not available", newPatroniName)
+	}
+	defer client.Close()
+	if !helper.IsUserExist(creds.username, client) {
+		logger.Info("Pooler user is not exist, creation...")
+		if err := execFormat(client, "CREATE ROLE %I LOGIN PASSWORD %L", creds.username, creds.password); err != nil {
+			logger.Error("Cannot create Pooler user", zap.Error(err))
+			return err
+		}
+	} else if passwordChanged {
+		logger.Info("Password of Pooler user is changed, updating it in PostgreSQL")
+		if err := execFormat(client, "ALTER ROLE %I PASSWORD %L", creds.username, creds.password); err != nil {
+			logger.Error("Cannot update password of Pooler user", zap.Error(err))
+			return err
+		}
+	}
+
+	return createAuthFunctions(hp, client, creds.username, !refersLegacyLookup(spec.Config))
+}
+
+// refersLegacyLookup returns true if auth_query of the config refers to legacy public.lookup function,
+// the function is kept while it's used
+func refersLegacyLookup(config map[string]map[string]string) bool {
+	return strings.Contains(withAuthQuery(config)["pgbouncer"][authQueryParam], "public.lookup")
+}
+
+// authState is the content of pooler-auth-state ConfigMap
+type authState struct {
+	user       string
+	legacyKept bool
+	oids       []string
+}
+
+// database is a database which allows connections
+type database struct {
+	oid  string
+	name string
+}
+
+// createAuthFunctions creates auth_query function in databases which aren't in pooler-auth-state ConfigMap,
+// all databases are processed again if the pooler user is changed or legacy function is not used anymore
+func createAuthFunctions(hp *helper.Helper, client *pgClient.PostgresClient, username string, dropLegacy bool) error {
+	databases, err := getDatabases(client)
+	if err != nil {
+		logger.Error("cannot get database list", zap.Error(err))
+		return err
+	}
+	state := getAuthState()
+	processed := state.oids
+	if state.user != username || state.legacyKept == dropLegacy {
+		processed = nil
+	}
+	newState := authState{user: username, legacyKept: !dropLegacy}
+
+	var done []string
+	for _, db := range databases {
+		if slices.Contains(processed, db.oid) {
+			done = append(done, db.oid)
+			continue
+		}
+		if err := createAuthFunction(client, db.name, username, dropLegacy); err != nil {
+			logger.Error(fmt.Sprintf("cannot create auth function for db %s", db.name), zap.Error(err))
+			// databases processed so far are not processed again
+			newState.oids = done
+			if stateErr := saveAuthState(hp, newState); stateErr != nil {
+				logger.Error("cannot save databases with auth function", zap.Error(stateErr))
+			}
+			return err
+		}
+		logger.Info(fmt.Sprintf("Auth function is created in database %s", db.name))
+		done = append(done, db.oid)
+	}
+	// dropped databases are removed from the state
+	newState.oids = done
+	if slices.Equal(done, processed) && state.user == newState.user && state.legacyKept == newState.legacyKept {
+		return nil
+	}
+	return saveAuthState(hp, newState)
+}
+
+func createAuthFunction(client *pgClient.PostgresClient, database string, username string, dropLegacy bool) error {
+	conn, err := client.GetConnectionToDb(database)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+	tx, err := conn.Begin(context.Background())
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(context.Background()) }()
+
+	var legacyExists bool
+	if err := tx.QueryRow(context.Background(), "SELECT to_regprocedure('public.lookup(name)') IS NOT NULL").Scan(&legacyExists); err != nil {
+		return err
+	}
+	for _, statement := range authFunctionStatements(client.GetUser(), username, dropLegacy, legacyExists) {
+		if err := pgClient.ExecFormat(tx, statement[0], statement[1:]...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(context.Background())
+}
+
+// authFunctionStatements returns format() statements which create auth_query function executable only
+// by the pooler user. Legacy public.lookup function is dropped, or only the pooler user can execute it
+// while auth_query refers to it.
+func authFunctionStatements(owner string, username string, dropLegacy bool, legacyExists bool) [][]string {
+	statements := [][]string{
+		{"CREATE SCHEMA IF NOT EXISTS %I AUTHORIZATION %I", AuthSchema, owner},
+		{"ALTER SCHEMA %I OWNER TO %I", AuthSchema, owner},
+		{"REVOKE ALL ON SCHEMA %I FROM PUBLIC", AuthSchema},
+		{"GRANT USAGE ON SCHEMA %I TO %I", AuthSchema, username},
+		{lookupFunction},
+		{"ALTER FUNCTION %I.lookup(name) OWNER TO %I", AuthSchema, owner},
+		{"REVOKE ALL ON FUNCTION %I.lookup(name) FROM PUBLIC", AuthSchema},
+		{"GRANT EXECUTE ON FUNCTION %I.lookup(name) TO %I", AuthSchema, username},
+	}
+	switch {
+	case dropLegacy:
+		statements = append(statements, []string{"DROP FUNCTION IF EXISTS public.lookup(name)"})
+	case legacyExists:
+		statements = append(statements,
+			[]string{"REVOKE ALL ON FUNCTION public.lookup(name) FROM PUBLIC"},
+			[]string{"GRANT EXECUTE ON FUNCTION public.lookup(name) TO %I", username})
+	}
+	return statements
+}
+
+func getDatabases(client *pgClient.PostgresClient) ([]database, error) {
+	conn, err := client.GetConnection()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+	rows, err := conn.Query(context.Background(), "SELECT oid::text, datname FROM pg_database WHERE datallowconn ORDER BY datname")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var databases []database
+	for rows.Next() {
+		var db database
+		if err := rows.Scan(&db.oid, &db.name); err != nil {
+			return nil, err
+		}
+		databases = append(databases, db)
+	}
+	return databases, rows.Err()
+}
+
+func execFormat(client *pgClient.PostgresClient, format string, args ...string) error {
+	conn, err := client.GetConnection()
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+	return pgClient.ExecFormat(conn, format, args...)
+}
+
+// getAuthState returns the pooler user and OIDs of databases with auth_query function,
+// databases of the state saved by names in previous versions are processed again
+func getAuthState() authState {
+	cm, err := util.FindCmInNamespaceByName(util.GetNameSpace(), authStateConfigMapName)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			logger.Warn("Cannot get databases with auth function, all databases will be processed", zap.Error(err))
+		}
+		return authState{}
+	}
+	return parseAuthState(cm.Data)
+}
+
+func parseAuthState(data map[string]string) authState {
+	state := authState{user: data[authStateUserKey], legacyKept: data[authStateLegacyKey] == legacyKept}
+	for _, oid := range strings.Split(data[authStateDatabaseOidsKey], "\n") {
+		if oid != "" {
+			state.oids = append(state.oids, oid)
+		}
+	}
+	return state
+}
+
+func (s authState) data() map[string]string {
+	data := map[string]string{
+		authStateUserKey:         s.user,
+		authStateDatabaseOidsKey: strings.Join(s.oids, "\n"),
+	}
+	if s.legacyKept {
+		data[authStateLegacyKey] = legacyKept
+	}
+	return data
+}
+
+func saveAuthState(hp *helper.Helper, state authState) error {
+	_, err := hp.CreateOrUpdateConfigMap(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      authStateConfigMapName,
+			Namespace: util.GetNameSpace(),
+			Labels:    labels,
+		},
+		Data: state.data(),
+	})
+	return err
+}
@@ -0,0 +1,189 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pooler
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	pgClient "github.com/Netcracker/pgskipper-operator/pkg/client"
+)
+
+// legacyLookupFunction is public.lookup function created by previous versions of the operator
+const legacyLookupFunction = `CREATE OR REPLACE FUNCTION public.lookup(INOUT p_user name, OUT p_password text)
+RETURNS record LANGUAGE sql SECURITY DEFINER SET search_path = pg_catalog AS
+$$SELECT usename, passwd FROM pg_shadow WHERE usename = p_user$$`
+
+func TestAuthFunctionStatements(t *testing.T) {
+	tests := []struct {
+		name         string
+		dropLegacy   bool
+		legacyExists bool
+		legacy       [][]string
+	}{
+		{
+			name:         "legacy function is dropped",
+			dropLegacy:   true,
+			legacyExists: true,
+			legacy:       [][]string{{"DROP FUNCTION IF EXISTS public.lookup(name)"}},
+		},
+		{
+			name:         "legacy function is kept only for the pooler user",
+			legacyExists: true,
+			legacy: [][]string{
+				{"REVOKE ALL ON FUNCTION public.lookup(name) FROM PUBLIC"},
+				{"GRANT EXECUTE ON FUNCTION public.lookup(name) TO %I", "pgbouncer"},
+			},
+		},
+		{
+			name: "missing legacy function is not touched",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statements := authFunctionStatements("postgres", "pgbouncer", tt.dropLegacy, tt.legacyExists)
+			var legacy [][]string
+			for _, statement := range statements {
+				if strings.Contains(statement[0], "public.lookup") {
+					legacy = append(legacy, statement)
+				}
+			}
+			if !reflect.DeepEqual(legacy, tt.legacy) {
+				t.Errorf("statements of legacy function are %v, want %v", legacy, tt.legacy)
+			}
+			for _, expected := range [][]string{
+				{"REVOKE ALL ON SCHEMA %I FROM PUBLIC", AuthSchema},
+				{"REVOKE ALL ON FUNCTION %I.lookup(name) FROM PUBLIC", AuthSchema},
+				{"GRANT EXECUTE ON FUNCTION %I.lookup(name) TO %I", AuthSchema, "pgbouncer"},
+			} {
+				if !containsStatement(statements, expected) {
+					t.Errorf("statement %v is missing", expected)
+				}
+			}
+		})
+	}
+}
+
+func containsStatement(statements [][]string, expected []string) bool {
+	for _, statement := range statements {
+		if reflect.DeepEqual(statement, expected) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRefersLegacyLookup(t *testing.T) {
+	tests := []struct {
+		name   string
+		config map[string]map[string]string
+		want   bool
+	}{
+		{name: "no config"},
+		{name: "default auth_query", config: map[string]map[string]string{"pgbouncer": {authQueryParam: AuthQuery}}},
+		{name: "previous default auth_query is replaced", config: map[string]map[string]string{"pgbouncer": {authQueryParam: legacyAuthQuery}}},
+		{
+			name:   "custom auth_query",
+			config: map[string]map[string]string{"pgbouncer": {authQueryParam: "SELECT usename, passwd FROM public.lookup($1) WHERE usename <> 'admin'"}},
+			want:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := refersLegacyLookup(tt.config); got != tt.want {
+				t.Errorf("refersLegacyLookup() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthState(t *testing.T) {
+	state := authState{user: "pgbouncer", legacyKept: true, oids: []string{"5", "16384"}}
+	if parsed := parseAuthState(state.data()); !reflect.DeepEqual(parsed, state) {
+		t.Errorf("parsed state is %+v, want %+v", parsed, state)
+	}
+	state.legacyKept = false
+	data := state.data()
+	if _, ok := data[authStateLegacyKey]; ok {
+		t.Errorf("legacy function is saved as kept: %v", data)
+	}
+	// state of previous versions has no legacy key, the function was dropped by them
+	if parsed := parseAuthState(map[string]string{authStateUserKey: "pgbouncer", authStateDatabaseOidsKey: "5\n"}); parsed.legacyKept ||
+		!reflect.DeepEqual(parsed.oids, []string{"5"}) {
+		t.Errorf("previous state is parsed as %+v", parsed)
+	}
+}
+
+func queryBool(t *testing.T, pgC *pgClient.PostgresClient, query string, args ...interface{}) bool {
+	t.Helper()
+	conn, err := pgC.GetConnection()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Release()
+	var result bool
+	if err := conn.QueryRow(context.Background(), query, args...).Scan(&result); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	return result
+}
+
+func mustExec(t *testing.T, pgC *pgClient.PostgresClient, query string) {
+	t.Helper()
+	if err := pgC.ExecuteForDB("postgres", query); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+}
+
+func TestCreateAuthFunction(t *testing.T) {
+	pgC := requirePostgres(t)
+	mustExec(t, pgC, "CREATE ROLE auth_pooler LOGIN")
+	mustExec(t, pgC, "CREATE ROLE auth_app LOGIN PASSWORD 'app'")
+	mustExec(t, pgC, "CREATE ROLE auth_admin LOGIN SUPERUSER PASSWORD 'admin'")
+	mustExec(t, pgC, "CREATE ROLE auth_other LOGIN")
+	mustExec(t, pgC, legacyLookupFunction)
+	t.Cleanup(func() {
+		_ = pgC.ExecuteForDB("postgres", "DROP SCHEMA IF EXISTS pgbouncer CASCADE")
+		_ = pgC.ExecuteForDB("postgres", "DROP FUNCTION IF EXISTS public.lookup(name)")
+		_ = pgC.ExecuteForDB("postgres", "DROP ROLE IF EXISTS auth_pooler, auth_app, auth_admin, auth_other")
+	})
+
+	if err := createAuthFunction(pgC, "postgres", "auth_pooler", false); err != nil {
+		t.Fatalf("cannot create auth function: %v", err)
+	}
+	for _, function := range []string{"pgbouncer.lookup(name)", "public.lookup(name)"} {
+		if !queryBool(t, pgC, "SELECT has_function_privilege('auth_pooler', $1, 'EXECUTE')", function) {
+			t.Errorf("pooler user can't execute %s", function)
+		}
+		if queryBool(t, pgC, "SELECT has_function_privilege('auth_other', $1, 'EXECUTE')", function) {
+			t.Errorf("%s is executable by other users", function)
+		}
+	}
+	if !queryBool(t, pgC, "SELECT p_password IS NOT NULL FROM pgbouncer.lookup('auth_app')") {
+		t.Errorf("password of login role is not returned")
+	}
+	if queryBool(t, pgC, "SELECT p_password IS NOT NULL FROM pgbouncer.lookup('auth_admin')") {
+		t.Errorf("password of superuser is returned")
+	}
+
+	if err := createAuthFunction(pgC, "postgres", "auth_pooler", true); err != nil {
+		t.Fatalf("cannot create auth function: %v", err)
+	}
+	if queryBool(t, pgC, "SELECT to_regprocedure('public.lookup(name)') IS NOT NULL") {
+		t.Errorf("legacy function is not dropped")
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pooler
+
+import (
+	"strings"
+
+	coreUtil "github.com/Netcracker/pgskipper-operator-core/pkg/util"
+	"github.com/Netcracker/pgskipper-operator/pkg/helper"
+	"github.com/Netcracker/pgskipper-operator/pkg/util"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ConfigChange is a way to apply the change of pgbouncer.ini to the running pooler pods
+type ConfigChange int
+
+const (
+	ConfigUnchanged ConfigChange = iota
+	// ConfigReload is applied by RELOAD command of the admin console
+	ConfigReload
+	// ConfigRestart is applied by rolling update of the pooler pods
+	ConfigRestart
+)
+
+const (
+	// ConfigHashAnnotation is set to the pod template, it's changed only by changes which require restart
+	ConfigHashAnnotation = "checksum/pooler-config"
+
+	pgbouncerSection = "pgbouncer"
+	databasesSection = "databases"
+	// fallbackDatabase is used for databases without own entry, they can't be checked after RELOAD
+	fallbackDatabase = "*"
+)
+
+// restartParams are read by PgBouncer only on start, RELOAD keeps their previous values
+var restartParams = map[string]bool{
+	"listen_addr":       true,
+	"listen_port":       true,
+	"listen_backlog":    true,
+	"unix_socket_dir":   true,
+	"unix_socket_mode":  true,
+	"unix_socket_group": true,
+	"user":              true,
+	"pidfile":           true,
+	"pkt_buf":           true,
+	"so_reuseport":      true,
+	"peer_id":           true,
+	"disable_pqexec":    true,
+}
+
+// configDiff is a change of pgbouncer.ini, parameters and databases are changes which can be applied by RELOAD
+type configDiff struct {
+	restart    bool
+	parameters map[string]string
+	databases  map[string]string
+}
+
+// ClassifyConfigChange returns how the change from oldConfig to newConfig is applied. Parameters of pgbouncer section
+// and databases are applied by RELOAD except restartParams, removed entries and the fallback database,
+// other sections require restart.
+func ClassifyConfigChange(oldConfig, newConfig map[string]map[string]string) ConfigChange {
+	diff := diffConfig(oldConfig, newConfig)
+	switch {
+	case diff.restart:
+		return ConfigRestart
+	case len(diff.parameters) > 0 || len(diff.databases) > 0:
+		return ConfigReload
+	default:
+		return ConfigUnchanged
+	}
+}
+
+func diffConfig(oldConfig, newConfig map[string]map[string]string) configDiff {
+	diff := configDiff{parameters: map[string]string{}, databases: map[string]string{}}
+	for section, oldParams := range oldConfig {
+		if _, ok := newConfig[section]; !ok && len(oldParams) > 0 {
+			diff.restart = true
+		}
+	}
+	for section, newParams := range newConfig {
+		oldParams := oldConfig[section]
+		for name := range oldParams {
+			if _, ok := newParams[name]; !ok {
+				// RELOAD doesn't reset removed entries
+				diff.restart = true
+			}
+		}
+		for name, value := range newParams {
+			if oldValue, ok := oldParams[name]; ok && oldValue == value {
+				continue
+			}
+			switch {
+			case section == pgbouncerSection && !restartParams[name]:
+				diff.parameters[name] = value
+			case section == databasesSection && name != fallbackDatabase:
+				diff.databases[name] = value
+			default:
+				diff.restart = true
+			}
+		}
+	}
+	return diff
+}
+
+// ConfigHash returns the value of ConfigHashAnnotation for the pod template. The value of the current deployment
+// is kept if the change doesn't require restart, so the pods are rolled only by ConfigRestart changes.
+func (i Instance) ConfigHash(hp *helper.Helper, config map[string]map[string]string, change ConfigChange) (string, error) {
+	if change != ConfigRestart {
+		err, current := hp.FindDeployment(&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: i.DeploymentName, Namespace: util.GetNameSpace()},
+		})
+		if err != nil && !errors.IsNotFound(err) {
+			return "", err
+		}
+		if hash, ok := current.Spec.Template.Annotations[ConfigHashAnnotation]; err == nil && ok {
+			return hash, nil
+		}
+	}
+	return coreUtil.HashJson(config), nil
+}
+
+// GetCurrentConfig returns pgbouncer.ini of the pooler ConfigMap, nil if the ConfigMap doesn't exist yet
+func (i Instance) GetCurrentConfig() (map[string]map[string]string, error) {
+	cm, err := util.FindCmInNamespaceByName(util.GetNameSpace(), i.configMapName)
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseConfig(cm.Data[configName]), nil
+}
+
+// parseConfig parses pgbouncer.ini made by createPoolerConfigMapData
+func parseConfig(data string) map[string]map[string]string {
+	config := map[string]map[string]string{}
+	var section map[string]string
+	for _, line := range strings.Split(data, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, ";") || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+			section = map[string]string{}
+			config[strings.TrimSpace(trimmed[1:len(trimmed)-1])] = section
+			continue
+		}
+		// values are written as is, so they are compared with the CR without trimming
+		name, value, found := strings.Cut(line, "=")
+		if !found || section == nil {
+			continue
+		}
+		section[strings.TrimSpace(name)] = value
+	}
+	return config
+}
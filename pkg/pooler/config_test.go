@@ -0,0 +1,185 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pooler
+
+import (
+	"maps"
+	"reflect"
+	"testing"
+)
+
+func TestClassifyConfigChange(t *testing.T) {
+	base := func() map[string]map[string]string {
+		return map[string]map[string]string{
+			pgbouncerSection: {"listen_port": "6432", "max_client_conn": "100", "auth_query": "SELECT 1"},
+			databasesSection: {"*": "host=pg-patroni port=5432", "app": "host=pg-patroni dbname=app"},
+		}
+	}
+	tests := []struct {
+		name           string
+		change         func(config map[string]map[string]string)
+		want           ConfigChange
+		wantParameters map[string]string
+		wantDatabases  map[string]string
+	}{
+		{
+			name:   "unchanged",
+			change: func(config map[string]map[string]string) {},
+			want:   ConfigUnchanged,
+		},
+		{
+			name:           "reloadable parameter",
+			change:         func(config map[string]map[string]string) { config[pgbouncerSection]["max_client_conn"] = "200" },
+			want:           ConfigReload,
+			wantParameters: map[string]string{"max_client_conn": "200"},
+		},
+		{
+			name:           "added parameter",
+			change:         func(config map[string]map[string]string) { config[pgbouncerSection]["default_pool_size"] = "20" },
+			want:           ConfigReload,
+			wantParameters: map[string]string{"default_pool_size": "20"},
+		},
+		{
+			name:   "restart parameter",
+			change: func(config map[string]map[string]string) { config[pgbouncerSection]["listen_port"] = "6433" },
+			want:   ConfigRestart,
+		},
+		{
+			name:   "added restart parameter",
+			change: func(config map[string]map[string]string) { config[pgbouncerSection]["so_reuseport"] = "1" },
+			want:   ConfigRestart,
+		},
+		{
+			name:   "removed parameter",
+			change: func(config map[string]map[string]string) { delete(config[pgbouncerSection], "max_client_conn") },
+			want:   ConfigRestart,
+		},
+		{
+			name: "changed database",
+			change: func(config map[string]map[string]string) {
+				config[databasesSection]["app"] = "host=pg-patroni-ro dbname=app"
+			},
+			want:          ConfigReload,
+			wantDatabases: map[string]string{"app": "host=pg-patroni-ro dbname=app"},
+		},
+		{
+			name: "added database",
+			change: func(config map[string]map[string]string) {
+				config[databasesSection]["reports"] = "host=pg-patroni dbname=reports"
+			},
+			want:          ConfigReload,
+			wantDatabases: map[string]string{"reports": "host=pg-patroni dbname=reports"},
+		},
+		{
+			name:   "removed database",
+			change: func(config map[string]map[string]string) { delete(config[databasesSection], "app") },
+			want:   ConfigRestart,
+		},
+		{
+			name: "fallback database",
+			change: func(config map[string]map[string]string) {
+				config[databasesSection]["*"] = "host=pg-patroni-ro port=5432"
+			},
+			want: ConfigRestart,
+		},
+		{
+			name: "added section",
+			change: func(config map[string]map[string]string) {
+				config["users"] = map[string]string{"app": "pool_mode=session"}
+			},
+			want: ConfigRestart,
+		},
+		{
+			name:   "added empty section",
+			change: func(config map[string]map[string]string) { config["users"] = map[string]string{} },
+			want:   ConfigUnchanged,
+		},
+		{
+			name:   "removed section",
+			change: func(config map[string]map[string]string) { delete(config, databasesSection) },
+			want:   ConfigRestart,
+		},
+		{
+			name: "reload and restart changes",
+			change: func(config map[string]map[string]string) {
+				config[pgbouncerSection]["max_client_conn"] = "200"
+				config[pgbouncerSection]["pkt_buf"] = "8192"
+			},
+			want:           ConfigRestart,
+			wantParameters: map[string]string{"max_client_conn": "200"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oldConfig, newConfig := base(), base()
+			tt.change(newConfig)
+			if got := ClassifyConfigChange(oldConfig, newConfig); got != tt.want {
+				t.Errorf("ClassifyConfigChange() = %d, want %d", got, tt.want)
+			}
+			diff := diffConfig(oldConfig, newConfig)
+			if tt.wantParameters == nil {
+				tt.wantParameters = map[string]string{}
+			}
+			if tt.wantDatabases == nil {
+				tt.wantDatabases = map[string]string{}
+			}
+			if !maps.Equal(diff.parameters, tt.wantParameters) {
+				t.Errorf("diffConfig() parameters = %v, want %v", diff.parameters, tt.wantParameters)
+			}
+			if !maps.Equal(diff.databases, tt.wantDatabases) {
+				t.Errorf("diffConfig() databases = %v, want %v", diff.databases, tt.wantDatabases)
+			}
+		})
+	}
+}
+
+func TestClassifyConfigChangeFromEmpty(t *testing.T) {
+	newConfig := map[string]map[string]string{pgbouncerSection: {"listen_port": "6432"}}
+	if got := ClassifyConfigChange(nil, newConfig); got != ConfigRestart {
+		t.Errorf("ClassifyConfigChange() of the first config = %d, want %d", got, ConfigRestart)
+	}
+}
+
+func TestParseConfigRoundTrip(t *testing.T) {
+	config := map[string]map[string]string{
+		pgbouncerSection: {
+			"listen_port":               "6432",
+			"auth_query":                "SELECT p_user, p_password FROM pgbouncer.lookup($1)",
+			"ignore_startup_parameters": "extra_float_digits,options",
+			"server_reset_query":        " DISCARD ALL",
+		},
+		databasesSection: {
+			"*":   "host=pg-patroni port=5432",
+			"app": "host=pg-patroni port=5432 dbname=app pool_size=10",
+		},
+		"users": {"reader": "pool_mode=session"},
+	}
+	data := createPoolerConfigMapData(config)[configName]
+	parsed := parseConfig(data)
+	if !reflect.DeepEqual(parsed, config) {
+		t.Errorf("parseConfig() = %v, want %v", parsed, config)
+	}
+	if got := ClassifyConfigChange(config, parsed); got != ConfigUnchanged {
+		t.Errorf("ClassifyConfigChange() of parsed config = %d, want %d", got, ConfigUnchanged)
+	}
+}
+
+func TestParseConfigSkipsComments(t *testing.T) {
+	data := "; generated\n# comment\nlisten_port=1\n[pgbouncer]\nlisten_port=6432\n\nnot a parameter\n"
+	want := map[string]map[string]string{pgbouncerSection: {"listen_port": "6432"}}
+	if got := parseConfig(data); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseConfig() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/jackc/pgconn"
+)
+
+// PgConsole connects to the admin console of PgBouncer in the same pod. Credentials are read from the mounted
+// files on every scrape, so the rotated password is used without restart of the exporter.
+type PgConsole struct {
+	Host         string
+	Port         int
+	UsernameFile string
+	PasswordFile string
+}
+
+func (c *PgConsole) Show(ctx context.Context, commands []string) (map[string][]Row, error) {
+	conn, err := c.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close(ctx)
+	results := make(map[string][]Row, len(commands))
+	for _, command := range commands {
+		rows, err := readRows(conn.Exec(ctx, command))
+		if err != nil {
+			return nil, fmt.Errorf("cannot execute %s: %w", command, err)
+		}
+		results[command] = rows
+	}
+	return results, nil
+}
+
+func (c *PgConsole) connect(ctx context.Context) (*pgconn.PgConn, error) {
+	username, err := readCredential(c.UsernameFile)
+	if err != nil {
+		return nil, err
+	}
+	password, err := readCredential(c.PasswordFile)
+	if err != nil {
+		return nil, err
+	}
+	config, err := pgconn.ParseConfig(fmt.Sprintf("host=%s port=%d dbname=pgbouncer sslmode=prefer", c.Host, c.Port))
+	if err != nil {
+		return nil, err
+	}
+	config.User = username
+	config.Password = password
+	return pgconn.ConnectConfig(ctx, config)
+}
+
+func readRows(reader *pgconn.MultiResultReader) ([]Row, error) {
+	results, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	var rows []Row
+	for _, result := range results {
+		for _, values := range result.Rows {
+			row := make(Row, len(values))
+			for i, field := range result.FieldDescriptions {
+				row[string(field.Name)] = string(values[i])
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+func readCredential(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("cannot read credentials: %w", err)
+	}
+	// the operator writes the values without trailing newline, so they are used as is
+	return string(data), nil
+}
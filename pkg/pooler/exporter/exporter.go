@@ -0,0 +1,225 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/Netcracker/pgskipper-operator/pkg/util"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+const (
+	namespace = "pgbouncer"
+
+	// DefaultPort is used by metrics endpoint of the exporter
+	DefaultPort = 9127
+
+	ShowPools     = "SHOW POOLS"
+	ShowStats     = "SHOW STATS"
+	ShowLists     = "SHOW LISTS"
+	ShowDatabases = "SHOW DATABASES"
+
+	// microseconds converts time columns of the admin console to seconds
+	microseconds = 1e-6
+)
+
+var (
+	logger = util.GetLogger()
+
+	// Commands are executed in the admin console on every scrape
+	Commands = []string{ShowPools, ShowStats, ShowLists, ShowDatabases}
+)
+
+// Row is a row of the admin console output by column names
+type Row map[string]string
+
+// AdminConsole executes SHOW commands in PgBouncer admin console and returns rows per command
+type AdminConsole interface {
+	Show(ctx context.Context, commands []string) (map[string][]Row, error)
+}
+
+// column is a numeric column of the admin console output exported as a metric,
+// columns missing in the output of the running PgBouncer version are skipped
+type column struct {
+	name      string
+	desc      *prometheus.Desc
+	valueType prometheus.ValueType
+	scale     float64
+}
+
+func newColumn(name string, subsystem string, metric string, help string, valueType prometheus.ValueType, scale float64, labels []string) column {
+	return column{
+		name:      name,
+		desc:      prometheus.NewDesc(prometheus.BuildFQName(namespace, subsystem, metric), help, labels, nil),
+		valueType: valueType,
+		scale:     scale,
+	}
+}
+
+// Collector exposes PgBouncer admin console statistics on every scrape
+type Collector struct {
+	console AdminConsole
+	timeout time.Duration
+
+	up          *prometheus.Desc
+	pools       []column
+	poolMaxWait *prometheus.Desc
+	stats       []column
+	lists       *prometheus.Desc
+	databases   []column
+}
+
+func NewCollector(console AdminConsole, timeout time.Duration) *Collector {
+	poolLabels := []string{"database", "user"}
+	databaseLabels := []string{"database"}
+	gauge := func(name, metric, help string) column {
+		return newColumn(name, "pools", metric, help, prometheus.GaugeValue, 1, poolLabels)
+	}
+	counter := func(name, metric, help string, scale float64) column {
+		return newColumn(name, "stats", metric, help, prometheus.CounterValue, scale, databaseLabels)
+	}
+	database := func(name, metric, help string) column {
+		return newColumn(name, "databases", metric, help, prometheus.GaugeValue, 1, databaseLabels)
+	}
+	return &Collector{
+		console: console,
+		timeout: timeout,
+		up: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "up"),
+			"Whether PgBouncer admin console was successfully queried.", nil, nil),
+		pools: []column{
+			gauge("cl_active", "client_active_connections", "Client connections linked to a server connection or idle."),
+			gauge("cl_waiting", "client_waiting_connections", "Client connections waiting for a server connection."),
+			gauge("cl_active_cancel_req", "client_active_cancel_connections", "Client connections which forwarded cancel request to the server."),
+			gauge("cl_waiting_cancel_req", "client_waiting_cancel_connections", "Client connections with cancel request not forwarded to the server yet."),
+			gauge("sv_active", "server_active_connections", "Server connections linked to a client connection."),
+			gauge("sv_idle", "server_idle_connections", "Server connections available for client queries."),
+			gauge("sv_used", "server_used_connections", "Server connections idle longer than server_check_delay, they need a check before use."),
+			gauge("sv_tested", "server_testing_connections", "Server connections running server_reset_query or server_check_query."),
+			gauge("sv_login", "server_login_connections", "Server connections in the process of logging in."),
+		},
+		poolMaxWait: prometheus.NewDesc(prometheus.BuildFQName(namespace, "pools", "client_maxwait_seconds"),
+			"Waiting time of the oldest client connection in the pool.", poolLabels, nil),
+		stats: []column{
+			counter("total_xact_count", "transactions_total", "SQL transactions pooled by PgBouncer.", 1),
+			counter("total_query_count", "queries_total", "SQL queries pooled by PgBouncer.", 1),
+			counter("total_server_assignment_count", "server_assignments_total", "Times a server connection was assigned to a client.", 1),
+			counter("total_received", "received_bytes_total", "Network traffic received by PgBouncer.", 1),
+			counter("total_sent", "sent_bytes_total", "Network traffic sent by PgBouncer.", 1),
+			counter("total_xact_time", "transactions_duration_seconds_total", "Time spent by PgBouncer in transactions with the server.", microseconds),
+			counter("total_query_time", "queries_duration_seconds_total", "Time spent by PgBouncer actively querying the server.", microseconds),
+			counter("total_wait_time", "client_wait_seconds_total", "Time spent by clients waiting for a server connection.", microseconds),
+		},
+		lists: prometheus.NewDesc(prometheus.BuildFQName(namespace, "lists", "items"),
+			"Number of items in internal lists of PgBouncer.", []string{"list"}, nil),
+		databases: []column{
+			database("pool_size", "pool_size", "Maximum number of server connections per user."),
+			database("min_pool_size", "min_pool_size", "Minimum number of server connections per user."),
+			database("reserve_pool", "reserve_pool", "Maximum number of additional server connections per user."),
+			database("max_connections", "max_connections", "Maximum number of server connections to the database."),
+			database("current_connections", "current_connections", "Current number of server connections to the database."),
+			database("paused", "paused", "Whether the database is paused."),
+			database("disabled", "disabled", "Whether the database is disabled."),
+		},
+	}
+}
+
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.up
+	ch <- c.poolMaxWait
+	ch <- c.lists
+	for _, columns := range [][]column{c.pools, c.stats, c.databases} {
+		for _, col := range columns {
+			ch <- col.desc
+		}
+	}
+}
+
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	results, err := c.console.Show(ctx, Commands)
+	if err != nil {
+		logger.Warn("Cannot collect PgBouncer metrics", zap.Error(err))
+		ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 0)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 1)
+	c.collectPools(ch, results[ShowPools])
+	c.collectStats(ch, results[ShowStats])
+	c.collectLists(ch, results[ShowLists])
+	c.collectDatabases(ch, results[ShowDatabases])
+}
+
+func (c *Collector) collectPools(ch chan<- prometheus.Metric, rows []Row) {
+	for _, row := range rows {
+		labels := []string{row["database"], row["user"]}
+		collectColumns(ch, row, c.pools, labels)
+		// maxwait has seconds and maxwait_us has microseconds part of the waiting time
+		if seconds, ok := parseValue(row, "maxwait"); ok {
+			if us, ok := parseValue(row, "maxwait_us"); ok {
+				seconds += us * microseconds
+			}
+			ch <- prometheus.MustNewConstMetric(c.poolMaxWait, prometheus.GaugeValue, seconds, labels...)
+		}
+	}
+}
+
+func (c *Collector) collectStats(ch chan<- prometheus.Metric, rows []Row) {
+	for _, row := range rows {
+		collectColumns(ch, row, c.stats, []string{row["database"]})
+	}
+}
+
+func (c *Collector) collectLists(ch chan<- prometheus.Metric, rows []Row) {
+	for _, row := range rows {
+		if items, ok := parseValue(row, "items"); ok {
+			ch <- prometheus.MustNewConstMetric(c.lists, prometheus.GaugeValue, items, row["list"])
+		}
+	}
+}
+
+func (c *Collector) collectDatabases(ch chan<- prometheus.Metric, rows []Row) {
+	for _, row := range rows {
+		// newer PgBouncer versions report max_connections as max_db_connections
+		if _, ok := row["max_connections"]; !ok {
+			if value, ok := row["max_db_connections"]; ok {
+				row["max_connections"] = value
+			}
+		}
+		collectColumns(ch, row, c.databases, []string{row["name"]})
+	}
+}
+
+func collectColumns(ch chan<- prometheus.Metric, row Row, columns []column, labels []string) {
+	for _, col := range columns {
+		if value, ok := parseValue(row, col.name); ok {
+			ch <- prometheus.MustNewConstMetric(col.desc, col.valueType, value*col.scale, labels...)
+		}
+	}
+}
+
+// parseValue returns numeric value of the column, false if the column is absent or not a number
+func parseValue(row Row, name string) (float64, bool) {
+	value, ok := row[name]
+	if !ok || value == "" {
+		return 0, false
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	return number, err == nil
+}
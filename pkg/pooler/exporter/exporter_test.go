@@ -0,0 +1,219 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// fakeConsole returns canned rows of the admin console
+type fakeConsole struct {
+	results map[string][]Row
+	err     error
+}
+
+func (c *fakeConsole) Show(_ context.Context, commands []string) (map[string][]Row, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	results := make(map[string][]Row, len(commands))
+	for _, command := range commands {
+		for _, row := range c.results[command] {
+			// the collector changes rows, so every scrape gets a copy like from the real console
+			copied := make(Row, len(row))
+			for name, value := range row {
+				copied[name] = value
+			}
+			results[command] = append(results[command], copied)
+		}
+	}
+	return results, nil
+}
+
+func TestCollector(t *testing.T) {
+	console := &fakeConsole{results: map[string][]Row{
+		ShowPools: {
+			{"database": "app", "user": "app", "cl_active": "3", "cl_waiting": "1", "sv_active": "2", "sv_idle": "4",
+				"sv_used": "0", "sv_tested": "0", "sv_login": "1", "maxwait": "2", "maxwait_us": "500000", "pool_mode": "transaction"},
+			{"database": "pgbouncer", "user": "pgbouncer", "cl_active": "1", "cl_waiting": "0", "sv_active": "0", "sv_idle": "0",
+				"sv_used": "0", "sv_tested": "0", "sv_login": "0", "maxwait": "0", "maxwait_us": "0", "pool_mode": "statement"},
+		},
+		ShowStats: {
+			{"database": "app", "total_xact_count": "10", "total_query_count": "25", "total_received": "1024", "total_sent": "2048",
+				"total_xact_time": "1500000", "total_query_time": "500000", "total_wait_time": "250000", "avg_xact_count": "1"},
+		},
+		ShowLists: {
+			{"list": "databases", "items": "2"},
+			{"list": "used_clients", "items": "4"},
+		},
+		ShowDatabases: {
+			{"name": "app", "host": "pg-patroni", "port": "5432", "pool_size": "20", "min_pool_size": "0", "reserve_pool": "0",
+				"max_connections": "0", "current_connections": "6", "paused": "0", "disabled": "0", "pool_mode": ""},
+		},
+	}}
+	expected := `
+# HELP pgbouncer_up Whether PgBouncer admin console was successfully queried.
+# TYPE pgbouncer_up gauge
+pgbouncer_up 1
+# HELP pgbouncer_pools_client_active_connections Client connections linked to a server connection or idle.
+# TYPE pgbouncer_pools_client_active_connections gauge
+pgbouncer_pools_client_active_connections{database="app",user="app"} 3
+pgbouncer_pools_client_active_connections{database="pgbouncer",user="pgbouncer"} 1
+# HELP pgbouncer_pools_client_waiting_connections Client connections waiting for a server connection.
+# TYPE pgbouncer_pools_client_waiting_connections gauge
+pgbouncer_pools_client_waiting_connections{database="app",user="app"} 1
+pgbouncer_pools_client_waiting_connections{database="pgbouncer",user="pgbouncer"} 0
+# HELP pgbouncer_pools_server_active_connections Server connections linked to a client connection.
+# TYPE pgbouncer_pools_server_active_connections gauge
+pgbouncer_pools_server_active_connections{database="app",user="app"} 2
+pgbouncer_pools_server_active_connections{database="pgbouncer",user="pgbouncer"} 0
+# HELP pgbouncer_pools_server_idle_connections Server connections available for client queries.
+# TYPE pgbouncer_pools_server_idle_connections gauge
+pgbouncer_pools_server_idle_connections{database="app",user="app"} 4
+pgbouncer_pools_server_idle_connections{database="pgbouncer",user="pgbouncer"} 0
+# HELP pgbouncer_pools_server_login_connections Server connections in the process of logging in.
+# TYPE pgbouncer_pools_server_login_connections gauge
+pgbouncer_pools_server_login_connections{database="app",user="app"} 1
+pgbouncer_pools_server_login_connections{database="pgbouncer",user="pgbouncer"} 0
+# HELP pgbouncer_pools_server_testing_connections Server connections running server_reset_query or server_check_query.
+# TYPE pgbouncer_pools_server_testing_connections gauge
+pgbouncer_pools_server_testing_connections{database="app",user="app"} 0
+pgbouncer_pools_server_testing_connections{database="pgbouncer",user="pgbouncer"} 0
+# HELP pgbouncer_pools_server_used_connections Server connections idle longer than server_check_delay, they need a check before use.
+# TYPE pgbouncer_pools_server_used_connections gauge
+pgbouncer_pools_server_used_connections{database="app",user="app"} 0
+pgbouncer_pools_server_used_connections{database="pgbouncer",user="pgbouncer"} 0
+# HELP pgbouncer_pools_client_maxwait_seconds Waiting time of the oldest client connection in the pool.
+# TYPE pgbouncer_pools_client_maxwait_seconds gauge
+pgbouncer_pools_client_maxwait_seconds{database="app",user="app"} 2.5
+pgbouncer_pools_client_maxwait_seconds{database="pgbouncer",user="pgbouncer"} 0
+# HELP pgbouncer_stats_transactions_total SQL transactions pooled by PgBouncer.
+# TYPE pgbouncer_stats_transactions_total counter
+pgbouncer_stats_transactions_total{database="app"} 10
+# HELP pgbouncer_stats_queries_total SQL queries pooled by PgBouncer.
+# TYPE pgbouncer_stats_queries_total counter
+pgbouncer_stats_queries_total{database="app"} 25
+# HELP pgbouncer_stats_received_bytes_total Network traffic received by PgBouncer.
+# TYPE pgbouncer_stats_received_bytes_total counter
+pgbouncer_stats_received_bytes_total{database="app"} 1024
+# HELP pgbouncer_stats_sent_bytes_total Network traffic sent by PgBouncer.
+# TYPE pgbouncer_stats_sent_bytes_total counter
+pgbouncer_stats_sent_bytes_total{database="app"} 2048
+# HELP pgbouncer_stats_transactions_duration_seconds_total Time spent by PgBouncer in transactions with the server.
+# TYPE pgbouncer_stats_transactions_duration_seconds_total counter
+pgbouncer_stats_transactions_duration_seconds_total{database="app"} 1.5
+# HELP pgbouncer_stats_queries_duration_seconds_total Time spent by PgBouncer actively querying the server.
+# TYPE pgbouncer_stats_queries_duration_seconds_total counter
+pgbouncer_stats_queries_duration_seconds_total{database="app"} 0.5
+# HELP pgbouncer_stats_client_wait_seconds_total Time spent by clients waiting for a server connection.
+# TYPE pgbouncer_stats_client_wait_seconds_total counter
+pgbouncer_stats_client_wait_seconds_total{database="app"} 0.25
+# HELP pgbouncer_lists_items Number of items in internal lists of PgBouncer.
+# TYPE pgbouncer_lists_items gauge
+pgbouncer_lists_items{list="databases"} 2
+pgbouncer_lists_items{list="used_clients"} 4
+# HELP pgbouncer_databases_pool_size Maximum number of server connections per user.
+# TYPE pgbouncer_databases_pool_size gauge
+pgbouncer_databases_pool_size{database="app"} 20
+# HELP pgbouncer_databases_min_pool_size Minimum number of server connections per user.
+# TYPE pgbouncer_databases_min_pool_size gauge
+pgbouncer_databases_min_pool_size{database="app"} 0
+# HELP pgbouncer_databases_reserve_pool Maximum number of additional server connections per user.
+# TYPE pgbouncer_databases_reserve_pool gauge
+pgbouncer_databases_reserve_pool{database="app"} 0
+# HELP pgbouncer_databases_max_connections Maximum number of server connections to the database.
+# TYPE pgbouncer_databases_max_connections gauge
+pgbouncer_databases_max_connections{database="app"} 0
+# HELP pgbouncer_databases_current_connections Current number of server connections to the database.
+# TYPE pgbouncer_databases_current_connections gauge
+pgbouncer_databases_current_connections{database="app"} 6
+# HELP pgbouncer_databases_paused Whether the database is paused.
+# TYPE pgbouncer_databases_paused gauge
+pgbouncer_databases_paused{database="app"} 0
+# HELP pgbouncer_databases_disabled Whether the database is disabled.
+# TYPE pgbouncer_databases_disabled gauge
+pgbouncer_databases_disabled{database="app"} 0
+`
+	// columns of newer PgBouncer versions which are missing in the output are not exported
+	if err := testutil.CollectAndCompare(NewCollector(console, time.Second), strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCollectorColumnsOfNewerVersions(t *testing.T) {
+	console := &fakeConsole{results: map[string][]Row{
+		ShowPools: {
+			{"database": "app", "user": "app", "cl_active": "3", "cl_active_cancel_req": "1", "cl_waiting_cancel_req": "0",
+				"maxwait": "1", "maxwait_us": "250000", "load_balance_hosts": ""},
+			// maxwait_us is not reported by old versions, seconds are used as is
+			{"database": "reports", "user": "app", "cl_active": "0", "maxwait": "4"},
+		},
+		ShowStats: {
+			{"database": "app", "total_server_assignment_count": "7", "total_xact_count": "5"},
+		},
+		ShowDatabases: {
+			{"name": "app", "max_db_connections": "50", "max_user_connections": "10", "reserve_pool": "5"},
+			{"name": "reports", "max_connections": "30", "max_db_connections": "40"},
+			// values which aren't numbers are skipped
+			{"name": "broken", "max_db_connections": "unlimited", "reserve_pool": ""},
+		},
+	}}
+	expected := `
+# HELP pgbouncer_pools_client_active_cancel_connections Client connections which forwarded cancel request to the server.
+# TYPE pgbouncer_pools_client_active_cancel_connections gauge
+pgbouncer_pools_client_active_cancel_connections{database="app",user="app"} 1
+# HELP pgbouncer_pools_client_waiting_cancel_connections Client connections with cancel request not forwarded to the server yet.
+# TYPE pgbouncer_pools_client_waiting_cancel_connections gauge
+pgbouncer_pools_client_waiting_cancel_connections{database="app",user="app"} 0
+# HELP pgbouncer_pools_client_maxwait_seconds Waiting time of the oldest client connection in the pool.
+# TYPE pgbouncer_pools_client_maxwait_seconds gauge
+pgbouncer_pools_client_maxwait_seconds{database="app",user="app"} 1.25
+pgbouncer_pools_client_maxwait_seconds{database="reports",user="app"} 4
+# HELP pgbouncer_stats_server_assignments_total Times a server connection was assigned to a client.
+# TYPE pgbouncer_stats_server_assignments_total counter
+pgbouncer_stats_server_assignments_total{database="app"} 7
+# HELP pgbouncer_databases_max_connections Maximum number of server connections to the database.
+# TYPE pgbouncer_databases_max_connections gauge
+pgbouncer_databases_max_connections{database="app"} 50
+pgbouncer_databases_max_connections{database="reports"} 30
+# HELP pgbouncer_databases_reserve_pool Maximum number of additional server connections per user.
+# TYPE pgbouncer_databases_reserve_pool gauge
+pgbouncer_databases_reserve_pool{database="app"} 5
+`
+	if err := testutil.CollectAndCompare(NewCollector(console, time.Second), strings.NewReader(expected),
+		"pgbouncer_pools_client_active_cancel_connections", "pgbouncer_pools_client_waiting_cancel_connections",
+		"pgbouncer_pools_client_maxwait_seconds", "pgbouncer_stats_server_assignments_total",
+		"pgbouncer_databases_max_connections", "pgbouncer_databases_reserve_pool"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCollectorConsoleError(t *testing.T) {
+	console := &fakeConsole{err: errors.New("connection refused")}
+	expected := `
+# HELP pgbouncer_up Whether PgBouncer admin console was successfully queried.
+# TYPE pgbouncer_up gauge
+pgbouncer_up 0
+`
+	if err := testutil.CollectAndCompare(NewCollector(console, time.Second), strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
@@ -17,16 +17,20 @@ package pooler
 import (
 	"context"
 	"fmt"
+	"regexp"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
 
 	v1 "github.com/Netcracker/pgskipper-operator/api/apps/v1"
-	pgClient "github.com/Netcracker/pgskipper-operator/pkg/client"
 	"github.com/Netcracker/pgskipper-operator/pkg/helper"
+	"github.com/Netcracker/pgskipper-operator/pkg/pooler/exporter"
 	"github.com/Netcracker/pgskipper-operator/pkg/util"
 	"go.uber.org/zap"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
@@ -35,41 +39,98 @@ import (
 const (
 	DeploymentName = "connection-puller"
 	configMapName  = "pooler-config"
-	secretName     = "pgbouncer-secret"
+	SecretName     = "pgbouncer-secret"
+	UserListKey    = "userlist.txt"
 	configName     = "pgbouncer.ini"
-	userList       = "userlist.txt"
+	// CredentialsSecretName is a Secret with username and password of pgbouncer-secret user,
+	// it's maintained by the operator and mounted to the pooler pods
+	CredentialsSecretName = "pgbouncer-credentials"
+	credentialsPath       = "/opt/pgbouncer/credentials"
+	poolerPort            = 6432
+
+	authTypeParam   = "auth_type"
+	authTypeMD5     = "md5"
+	authTypeScram   = "scram-sha-256"
+	authQueryParam  = "auth_query"
+	adminUsersParam = "admin_users"
+)
+
+const (
+	exporterContainerName = "pgbouncer-exporter"
+	metricsPortName       = "metrics"
+)
+
+const (
+	// ReadOnlyDeploymentName is the pooler which routes connections to pg-<cluster>-ro service
+	ReadOnlyDeploymentName = "connection-puller-ro"
+	readOnlyConfigMapName  = "pooler-config-ro"
 )
 
 var (
-	labels = map[string]string{"app": "pg-bouncer"}
-	logger = util.GetLogger()
+	labels         = map[string]string{"app": "pg-bouncer"}
+	readOnlyLabels = map[string]string{"app": "pg-bouncer-ro"}
+	metricsLabels  = map[string]string{"app": "pgbouncer-exporter"}
+	logger         = util.GetLogger()
+	// hostParamRegexp matches host parameter of a connection string in databases section
+	hostParamRegexp = regexp.MustCompile(`(^|\s)host=('[^']*'|\S+)`)
+
+	// Primary is the pooler behind pg-<cluster> service
+	Primary = Instance{DeploymentName: DeploymentName, configMapName: configMapName, labels: labels}
+	// ReadOnly is the pooler in front of pg-<cluster>-ro service, its labels differ from Primary,
+	// so pg-<cluster> service doesn't route connections to it
+	ReadOnly = Instance{DeploymentName: ReadOnlyDeploymentName, configMapName: readOnlyConfigMapName, labels: readOnlyLabels}
 )
 
+// Instance is a pooler Deployment with its own ConfigMap and pod labels
+type Instance struct {
+	DeploymentName string
+	configMapName  string
+	labels         map[string]string
+}
+
+// Labels returns labels of the pooler pods without custom pod labels
+func (i Instance) Labels() map[string]string {
+	return i.labels
+}
+
 type PgBouncerCreds struct {
 	username string
 	password string
 }
 
-func NewPoolerDeployment(spec v1.Pooler, sa string, creds *PgBouncerCreds, newPatroniName string) *appsv1.Deployment {
-	deploymentName := DeploymentName
+func (c *PgBouncerCreds) Username() string {
+	return c.username
+}
+
+// NewPoolerDeployment returns the pooler Deployment, pods are rolled one by one and the old pod is stopped
+// only when the new one is ready, configHash is set to the pod template to roll pods on config changes
+func (i Instance) NewPoolerDeployment(spec v1.Pooler, sa string, postgresHost string, configHash string) *appsv1.Deployment {
+	deploymentName := i.DeploymentName
 	dockerImage := spec.Image
+	maxUnavailable := intstr.FromInt32(0)
+	maxSurge := intstr.FromInt32(1)
 	dep := &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      deploymentName,
 			Namespace: util.GetNameSpace(),
-			Labels:    util.Merge(labels, spec.PodLabels),
+			Labels:    util.Merge(i.labels, spec.PodLabels),
 		},
 		Spec: appsv1.DeploymentSpec{
 			Strategy: appsv1.DeploymentStrategy{
-				Type: appsv1.RecreateDeploymentStrategyType,
+				Type: appsv1.RollingUpdateDeploymentStrategyType,
+				RollingUpdate: &appsv1.RollingUpdateDeployment{
+					MaxUnavailable: &maxUnavailable,
+					MaxSurge:       &maxSurge,
+				},
 			},
 			Selector: &metav1.LabelSelector{
-				MatchLabels: util.Merge(labels, spec.PodLabels),
+				MatchLabels: util.Merge(i.labels, spec.PodLabels),
 			},
 			Replicas: spec.Replicas,
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
-					Labels: util.Merge(labels, spec.PodLabels),
+					Labels:      util.Merge(i.labels, spec.PodLabels),
+					Annotations: map[string]string{ConfigHashAnnotation: configHash},
 				},
 				Spec: corev1.PodSpec{
 					Volumes: []corev1.Volume{
@@ -77,7 +138,7 @@ func NewPoolerDeployment(spec v1.Pooler, sa string, creds *PgBouncerCreds, newPa
 							Name: "auth-volume",
 							VolumeSource: corev1.VolumeSource{
 								Secret: &corev1.SecretVolumeSource{
-									SecretName: secretName,
+									SecretName: SecretName,
 								},
 							},
 						},
@@ -86,11 +147,19 @@ func NewPoolerDeployment(spec v1.Pooler, sa string, creds *PgBouncerCreds, newPa
 							VolumeSource: corev1.VolumeSource{
 								ConfigMap: &corev1.ConfigMapVolumeSource{
 									LocalObjectReference: corev1.LocalObjectReference{
-										Name: configMapName,
+										Name: i.configMapName,
 									},
 								},
 							},
 						},
+						{
+							Name: "credentials-volume",
+							VolumeSource: corev1.VolumeSource{
+								Secret: &corev1.SecretVolumeSource{
+									SecretName: CredentialsSecretName,
+								},
+							},
+						},
 					},
 					ServiceAccountName: sa,
 					Affinity:           &spec.Affinity,
@@ -104,19 +173,19 @@ func NewPoolerDeployment(spec v1.Pooler, sa string, creds *PgBouncerCreds, newPa
 							Env: []corev1.EnvVar{
 								{
 									Name:  "POSTGRESQL_HOST",
-									Value: newPatroniName,
+									Value: postgresHost,
 								},
 								{
-									Name:  "POSTGRESQL_PASSWORD",
-									Value: creds.password,
+									Name:  "POSTGRESQL_PASSWORD_FILE",
+									Value: credentialsPath + "/password",
 								},
 								{
-									Name:  "POSTGRESQL_USERNAME",
-									Value: creds.username,
+									Name:  "POSTGRESQL_USERNAME_FILE",
+									Value: credentialsPath + "/username",
 								},
 							},
 							Ports: []corev1.ContainerPort{
-								{ContainerPort: 6432, Name: "pg", Protocol: corev1.ProtocolTCP},
+								{ContainerPort: poolerPort, Name: "pg", Protocol: corev1.ProtocolTCP},
 							},
 							VolumeMounts: []corev1.VolumeMount{
 								{
@@ -127,11 +196,16 @@ func NewPoolerDeployment(spec v1.Pooler, sa string, creds *PgBouncerCreds, newPa
 									MountPath: "/etc/pgbouncer/",
 									Name:      "auth-volume",
 								},
+								{
+									MountPath: credentialsPath,
+									Name:      "credentials-volume",
+									ReadOnly:  true,
+								},
 							},
 							LivenessProbe: &corev1.Probe{
 								ProbeHandler: corev1.ProbeHandler{
 									TCPSocket: &corev1.TCPSocketAction{
-										Port: intstr.IntOrString{IntVal: 6432},
+										Port: intstr.IntOrString{IntVal: poolerPort},
 									},
 								},
 								InitialDelaySeconds: 10,
@@ -143,7 +217,7 @@ func NewPoolerDeployment(spec v1.Pooler, sa string, creds *PgBouncerCreds, newPa
 							ReadinessProbe: &corev1.Probe{
 								ProbeHandler: corev1.ProbeHandler{
 									TCPSocket: &corev1.TCPSocketAction{
-										Port: intstr.IntOrString{IntVal: 6432},
+										Port: intstr.IntOrString{IntVal: poolerPort},
 									},
 								},
 								InitialDelaySeconds: 20,
@@ -160,21 +234,199 @@ func NewPoolerDeployment(spec v1.Pooler, sa string, creds *PgBouncerCreds, newPa
 			},
 		},
 	}
+	if spec.Metrics != nil && spec.Metrics.Install {
+		dep.Spec.Template.Spec.Containers = append(dep.Spec.Template.Spec.Containers, newExporterContainer(spec.Metrics))
+	}
 	return dep
 }
 
-func GetConfigMap(pooler v1.Pooler) *corev1.ConfigMap {
+// newExporterContainer returns the sidecar which exposes statistics of PgBouncer admin console,
+// it logs in as the pooler user with the mounted credentials
+func newExporterContainer(metrics *v1.PoolerMetrics) corev1.Container {
+	return corev1.Container{
+		Name:    exporterContainerName,
+		Image:   metrics.Image,
+		Command: []string{"/usr/local/bin/pgbouncer-exporter"},
+		Env: []corev1.EnvVar{
+			{
+				Name:  "PGBOUNCER_PORT",
+				Value: strconv.Itoa(poolerPort),
+			},
+			{
+				Name:  "POSTGRESQL_PASSWORD_FILE",
+				Value: credentialsPath + "/password",
+			},
+			{
+				Name:  "POSTGRESQL_USERNAME_FILE",
+				Value: credentialsPath + "/username",
+			},
+		},
+		Ports: []corev1.ContainerPort{
+			{ContainerPort: exporter.DefaultPort, Name: metricsPortName, Protocol: corev1.ProtocolTCP},
+		},
+		VolumeMounts: []corev1.VolumeMount{
+			{
+				MountPath: credentialsPath,
+				Name:      "credentials-volume",
+				ReadOnly:  true,
+			},
+		},
+		LivenessProbe: &corev1.Probe{
+			ProbeHandler: corev1.ProbeHandler{
+				HTTPGet: &corev1.HTTPGetAction{
+					Path: "/healthz",
+					Port: intstr.FromString(metricsPortName),
+				},
+			},
+			InitialDelaySeconds: 10,
+			PeriodSeconds:       10,
+			FailureThreshold:    5,
+			TimeoutSeconds:      5,
+			SuccessThreshold:    1,
+		},
+		Resources: metrics.Resources,
+	}
+}
+
+// NewMetricsService returns the service of the exporter sidecars, it's selected by ServiceMonitor of the chart
+func (i Instance) NewMetricsService() *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      i.MetricsServiceName(),
+			Namespace: util.GetNameSpace(),
+			Labels:    metricsLabels,
+		},
+		Spec: corev1.ServiceSpec{
+			Selector: i.labels,
+			Ports: []corev1.ServicePort{{
+				Name:       metricsPortName,
+				Protocol:   corev1.ProtocolTCP,
+				Port:       exporter.DefaultPort,
+				TargetPort: intstr.FromString(metricsPortName),
+			}},
+		},
+	}
+}
+
+// MetricsServiceName returns the name of the exporter service of the pooler
+func (i Instance) MetricsServiceName() string {
+	return i.DeploymentName + "-metrics"
+}
+
+// GetConfig returns pgbouncer.ini parameters, md5 auth_type is replaced with scram-sha-256
+// when authMethod of the cluster is scram-sha-256, auth_query of the public lookup function is replaced
+// with the query of the function in pgbouncer schema, adminUser is added to admin_users to reload the pooler
+func GetConfig(pooler v1.Pooler, authMethod string, adminUser string) map[string]map[string]string {
+	config := withAuthType(pooler.Config, authMethod)
+	config = withAuthQuery(config)
+	return withAdminUser(config, adminUser)
+}
+
+// GetConfigMap returns the pooler ConfigMap with pgbouncer.ini made from the config
+func (i Instance) GetConfigMap(config map[string]map[string]string) *corev1.ConfigMap {
 	return &corev1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:              configMapName,
+			Name:              i.configMapName,
 			Namespace:         util.GetNameSpace(),
 			CreationTimestamp: metav1.Time{},
-			Labels:            labels,
+			Labels:            i.labels,
 		},
-		Data: createPoolerConfigMapData(pooler.Config),
+		Data: createPoolerConfigMapData(config),
 	}
 }
 
+// NewPodDisruptionBudget returns PodDisruptionBudget which allows eviction of one pooler pod at a time
+func (i Instance) NewPodDisruptionBudget(spec v1.Pooler) *policyv1.PodDisruptionBudget {
+	maxUnavailable := intstr.FromInt32(1)
+	return &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      i.DeploymentName,
+			Namespace: util.GetNameSpace(),
+			Labels:    i.labels,
+		},
+		Spec: policyv1.PodDisruptionBudgetSpec{
+			MaxUnavailable: &maxUnavailable,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: util.Merge(i.labels, spec.PodLabels),
+			},
+		},
+	}
+}
+
+// ReadOnlyConfig returns a copy of the config for ReadOnly pooler, entries of databases section which refer
+// to one of primaryHosts are switched to readOnlyHost, entries of other hosts are kept
+func ReadOnlyConfig(config map[string]map[string]string, primaryHosts []string, readOnlyHost string) map[string]map[string]string {
+	result := make(map[string]map[string]string, len(config))
+	for section, params := range config {
+		result[section] = params
+	}
+	databases := make(map[string]string, len(config[databasesSection]))
+	for name, connString := range config[databasesSection] {
+		databases[name] = hostParamRegexp.ReplaceAllStringFunc(connString, func(param string) string {
+			prefix, host, _ := strings.Cut(param, "host=")
+			if !slices.Contains(primaryHosts, strings.Trim(host, "'")) {
+				return param
+			}
+			return prefix + "host=" + readOnlyHost
+		})
+	}
+	if len(databases) > 0 {
+		result[databasesSection] = databases
+	}
+	return result
+}
+
+func withAuthType(config map[string]map[string]string, authMethod string) map[string]map[string]string {
+	section, ok := config["pgbouncer"]
+	if !ok || authMethod != authTypeScram || section[authTypeParam] != authTypeMD5 {
+		return config
+	}
+	logger.Info(fmt.Sprintf("Pooler %s is set to %s", authTypeParam, authTypeScram))
+	return withPgBouncerParam(config, authTypeParam, authTypeScram)
+}
+
+func withAuthQuery(config map[string]map[string]string) map[string]map[string]string {
+	section, ok := config["pgbouncer"]
+	if !ok || section[authQueryParam] != legacyAuthQuery {
+		return config
+	}
+	logger.Info(fmt.Sprintf("Pooler %s is switched to %s.lookup function", authQueryParam, AuthSchema))
+	return withPgBouncerParam(config, authQueryParam, AuthQuery)
+}
+
+func withAdminUser(config map[string]map[string]string, adminUser string) map[string]map[string]string {
+	section, ok := config["pgbouncer"]
+	if !ok || adminUser == "" {
+		return config
+	}
+	var adminUsers []string
+	for _, user := range strings.Split(section[adminUsersParam], ",") {
+		if user = strings.TrimSpace(user); user != "" {
+			adminUsers = append(adminUsers, user)
+		}
+	}
+	if slices.Contains(adminUsers, adminUser) {
+		return config
+	}
+	return withPgBouncerParam(config, adminUsersParam, strings.Join(append(adminUsers, adminUser), ","))
+}
+
+// withPgBouncerParam returns a copy of the config with the parameter of pgbouncer section set,
+// the config of the CR is not changed
+func withPgBouncerParam(config map[string]map[string]string, name string, value string) map[string]map[string]string {
+	result := make(map[string]map[string]string, len(config))
+	for section, params := range config {
+		result[section] = params
+	}
+	pgbouncer := make(map[string]string, len(config["pgbouncer"]))
+	for param, paramValue := range config["pgbouncer"] {
+		pgbouncer[param] = paramValue
+	}
+	pgbouncer[name] = value
+	result["pgbouncer"] = pgbouncer
+	return result
+}
+
 func createPoolerConfigMapData(inputData map[string]map[string]string) map[string]string {
 	dataString := ""
 	sections := sortMapKeys(inputData)
@@ -207,20 +459,9 @@ func sortStringMapKeys(target map[string]string) []string {
 	return keylist
 }
 
-func SetUpDatabase(creds *PgBouncerCreds, newPatroniName string) error {
-	logger.Info("Database preparation for connection pooler started")
-	client := pgClient.GetPostgresClientForHost(newPatroniName)
-	if !helper.IsUserExist(creds.username, client) {
-		logger.Info("Pooler user is not exist, creation...")
-		if err := createPoolerUser(client, creds); err != nil {
-			return err
-		}
-	}
-
-	if err := createAuthFunctions(client, creds); err != nil {
-		return err
-	}
-	return nil
+// NewPgBouncerCreds returns credentials of the pooler user
+func NewPgBouncerCreds(username string, password string) *PgBouncerCreds {
+	return &PgBouncerCreds{username: username, password: password}
 }
 
 func GetPgBouncerCreds() (*PgBouncerCreds, error) {
@@ -231,81 +472,92 @@ func GetPgBouncerCreds() (*PgBouncerCreds, error) {
 		return nil, err
 	}
 	err = k8sClient.Get(context.TODO(), types.NamespacedName{
-		Name: secretName, Namespace: util.GetNameSpace(),
+		Name: SecretName, Namespace: util.GetNameSpace(),
 	}, foundSecret)
 	if err != nil {
-		logger.Error(fmt.Sprintf("can't find the secret %s", secretName), zap.Error(err))
+		logger.Error(fmt.Sprintf("can't find the secret %s", SecretName), zap.Error(err))
 		return nil, err
 	}
-	data := foundSecret.Data[userList]
-	// Should parse the creds from pgBouncer format
-	dataStr := strings.ReplaceAll(string(data), "\"", "")
-	parsCreds := strings.Split(dataStr, " ")
+	username, password, err := ParseUserList(foundSecret.Data[UserListKey])
+	if err != nil {
+		return nil, fmt.Errorf("can't parse %s of secret %s: %w", UserListKey, SecretName, err)
+	}
 	return &PgBouncerCreds{
-		username: parsCreds[0],
-		password: parsCreds[1],
+		username: username,
+		password: password,
 	}, nil
 }
 
-func createAuthFunctions(client *pgClient.PostgresClient, creds *PgBouncerCreds) error {
-	conn, err := client.GetConnection()
-	if err != nil {
-		return err
+// ParseUserList returns the first user of pgbouncer auth_file. Each line has quoted username and password,
+// a quote inside the value is doubled.
+func ParseUserList(data []byte) (string, string, error) {
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, ";") {
+			continue
+		}
+		var values []string
+		for len(values) < 2 {
+			line = strings.TrimLeft(line, " \t")
+			if !strings.HasPrefix(line, "\"") {
+				return "", "", fmt.Errorf("quoted username and password are expected")
+			}
+			value, rest, ok := readQuoted(line[1:])
+			if !ok {
+				return "", "", fmt.Errorf("unterminated quoted value")
+			}
+			values = append(values, value)
+			line = rest
+		}
+		return values[0], values[1], nil
 	}
-	defer conn.Release()
+	return "", "", fmt.Errorf("no users found")
+}
 
-	rows, err := conn.Query(context.Background(), "SELECT datname FROM pg_database;")
+// SetUserListPassword replaces password of the first user of pgbouncer auth_file, other lines are kept
+func SetUserListPassword(data []byte, password string) ([]byte, error) {
+	username, _, err := ParseUserList(data)
 	if err != nil {
-		logger.Error("cannot get database list", zap.Error(err))
-		return err
+		return nil, err
 	}
-	defer rows.Close()
-
-	databases := make([]string, 0)
-	for rows.Next() {
-		var db string
-		if err = rows.Scan(&db); err != nil {
-			logger.Error("cannot read database from databases list", zap.Error(err))
-			return err
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, ";") {
+			continue
 		}
-		databases = append(databases, db)
+		lines[i] = quoteUserListValue(username) + " " + quoteUserListValue(password)
+		break
 	}
-	for _, d := range databases {
-		if d == "template0" {
+	return []byte(strings.Join(lines, "\n")), nil
+}
+
+func quoteUserListValue(value string) string {
+	return "\"" + strings.ReplaceAll(value, "\"", "\"\"") + "\""
+}
+
+func readQuoted(line string) (string, string, bool) {
+	var value strings.Builder
+	for i := 0; i < len(line); i++ {
+		if line[i] != '"' {
+			value.WriteByte(line[i])
 			continue
 		}
-		if err = client.ExecuteForDB(d, "CREATE OR REPLACE FUNCTION public.lookup ("+
-			"   INOUT p_user     name,"+
-			"   OUT   p_password text"+
-			") RETURNS record "+
-			"LANGUAGE sql SECURITY DEFINER SET search_path = pg_catalog AS "+
-			"$$SELECT usename, passwd FROM pg_shadow WHERE usename = p_user$$;"); err != nil {
-			logger.Error(fmt.Sprintf("cannot create auth function for db %s", d), zap.Error(err))
-			return err
-		}
-		if err = client.ExecuteForDB(d, "REVOKE EXECUTE ON FUNCTION public.lookup(name) FROM PUBLIC;"); err != nil {
-			logger.Error(fmt.Sprintf("cannot create auth function for db %s", d), zap.Error(err))
-			return err
-		}
-		username := pgClient.EscapeString(creds.username)
-		if err = client.ExecuteForDB(d, fmt.Sprintf("GRANT EXECUTE ON FUNCTION public.lookup(name) TO \"%s\";", username)); err != nil {
-			logger.Error(fmt.Sprintf("cannot create auth function for db %s", d), zap.Error(err))
-			return err
-		}
-		if err = client.ExecuteForDB(d, fmt.Sprintf("ALTER FUNCTION public.lookup(name) OWNER TO %s;", client.GetUser())); err != nil {
-			logger.Error(fmt.Sprintf("cannot create auth function for db %s", d), zap.Error(err))
-			return err
+		if i+1 < len(line) && line[i+1] == '"' {
+			value.WriteByte('"')
+			i++
+			continue
 		}
+		return value.String(), line[i+1:], true
 	}
-	return nil
+	return "", "", false
 }
 
-func createPoolerUser(client *pgClient.PostgresClient, creds *PgBouncerCreds) error {
-	username := pgClient.EscapeString(creds.username)
-	password := pgClient.EscapeString(creds.password)
-	if err := client.ExecuteForDB(client.GetDatabase(), fmt.Sprintf("CREATE ROLE \"%s\" LOGIN PASSWORD '%s' ;", username, password)); err != nil {
-		logger.Error("Cannot create Pooler user", zap.Error(err))
-		return err
+// CheckScramCompatible returns an error if pgbouncer can't authenticate to PostgreSQL with SCRAM,
+// it requires a plain password or SCRAM secret in auth_file, md5 hash is not enough
+func (c *PgBouncerCreds) CheckScramCompatible() error {
+	if strings.HasPrefix(c.password, "md5") && len(c.password) == 35 {
+		return fmt.Errorf("password of user %s in %s is md5 hash, plain password is required for scram-sha-256", c.username, SecretName)
 	}
 	return nil
 }
@@ -0,0 +1,62 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pooler
+
+import "testing"
+
+func TestSetUserListPassword(t *testing.T) {
+	tests := []struct {
+		name     string
+		userList string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "single user",
+			userList: `"pgbouncer" "old"`,
+			want:     `"pgbouncer" "n""ew"`,
+		},
+		{
+			name:     "comments and other users are kept",
+			userList: "; pooler user\n\"pgbouncer\" \"old\"\n\"reader\" \"secret\"\n",
+			want:     "; pooler user\n\"pgbouncer\" \"n\"\"ew\"\n\"reader\" \"secret\"\n",
+		},
+		{
+			name:     "quoted username",
+			userList: `"pg""bouncer" "old"`,
+			want:     `"pg""bouncer" "n""ew"`,
+		},
+		{name: "empty", userList: "", wantErr: true},
+		{name: "unquoted", userList: "pgbouncer old", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SetUserListPassword([]byte(tt.userList), `n"ew`)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SetUserListPassword() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if string(got) != tt.want {
+				t.Errorf("SetUserListPassword() = %q, want %q", got, tt.want)
+			}
+			username, password, err := ParseUserList(got)
+			if err != nil || password != `n"ew` {
+				t.Errorf("ParseUserList() of the result = %q, %q, %v", username, password, err)
+			}
+		})
+	}
+}
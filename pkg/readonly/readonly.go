@@ -0,0 +1,267 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package readonly
+
+import (
+	"fmt"
+	"maps"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	patroniv1 "github.com/Netcracker/pgskipper-operator/api/patroni/v1"
+	"github.com/Netcracker/pgskipper-operator/pkg/deployment"
+	"github.com/Netcracker/pgskipper-operator/pkg/helper"
+	"github.com/Netcracker/pgskipper-operator/pkg/util"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+const (
+	DefaultMaxLagBytes   = int64(16 * 1024 * 1024)
+	DefaultCheckInterval = 30 * time.Second
+
+	noFailoverTag    = "nofailover"
+	noLoadBalanceTag = "noloadbalance"
+
+	// EndpointSliceManager is managed-by label of EndpointSlice of pg-<cluster>-ro service,
+	// Kubernetes controllers don't change slices of other managers
+	EndpointSliceManager = "pgskipper-operator"
+)
+
+var (
+	leaderRoles = []string{"leader", "master", "standby_leader"}
+	// runningStates are states of replicas which can serve queries, Patroni 4 reports streaming replicas as streaming
+	runningStates = []string{"running", "streaming"}
+)
+
+// IsEnabled returns true if endpoints of pg-<cluster>-ro service are managed by the operator. Services of etcd DCS
+// are headless and their endpoints are managed by Patroni, so they are not supported.
+func IsEnabled(cr *patroniv1.PatroniCore) bool {
+	if cr.Spec == nil || cr.Spec.Patroni == nil {
+		return false
+	}
+	spec := cr.Spec.Patroni
+	return spec.ReadOnlyService != nil && spec.ReadOnlyService.Enabled && !strings.HasPrefix(spec.Dcs.Type, "etcd")
+}
+
+// GetCheckInterval returns how often the endpoints are updated
+func GetCheckInterval(spec *patroniv1.ReadOnlyService) time.Duration {
+	if spec.CheckIntervalSeconds > 0 {
+		return time.Duration(spec.CheckIntervalSeconds) * time.Second
+	}
+	return DefaultCheckInterval
+}
+
+// SelectMembers returns sorted names of members which receive read-only connections: running replicas
+// without nofailover and noloadbalance tags and with known lag not greater than MaxLagBytes.
+// The leader is returned when no replica is selected and FallbackToLeader is set.
+func SelectMembers(status *helper.ClusterStatus, spec *patroniv1.ReadOnlyService) []string {
+	maxLag := DefaultMaxLagBytes
+	if spec.MaxLagBytes != nil {
+		maxLag = *spec.MaxLagBytes
+	}
+	var members []string
+	var leaders []string
+	for _, member := range status.Members {
+		if slices.Contains(leaderRoles, member.Role) {
+			if slices.Contains(runningStates, member.State) {
+				leaders = append(leaders, member.Name)
+			}
+			continue
+		}
+		if !slices.Contains(runningStates, member.State) || hasTag(member, noFailoverTag) || hasTag(member, noLoadBalanceTag) {
+			continue
+		}
+		if lag, ok := getLag(member); !ok || lag > maxLag {
+			continue
+		}
+		members = append(members, member.Name)
+	}
+	if len(members) == 0 && spec.FallbackToLeader {
+		members = leaders
+	}
+	slices.Sort(members)
+	return members
+}
+
+// NewEndpoints returns endpoints of pg-<cluster>-ro service with addresses of the member pods,
+// members without running pod are skipped. EndpointSlice is managed by the operator too,
+// so the endpoints are not mirrored to EndpointSlices by Kubernetes.
+func NewEndpoints(cluster *patroniv1.PatroniClusterSettings, pods []corev1.Pod, members []string) *corev1.Endpoints {
+	labels := map[string]string{discoveryv1.LabelSkipMirror: "true"}
+	maps.Copy(labels, cluster.PatroniLabels)
+	endpoints := &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cluster.PatroniReplicasServiceName,
+			Namespace: util.GetNameSpace(),
+			Labels:    labels,
+		},
+	}
+	memberPods := getMemberPods(pods, members)
+	if len(memberPods) == 0 {
+		return endpoints
+	}
+	var addresses []corev1.EndpointAddress
+	for _, pod := range memberPods {
+		addresses = append(addresses, corev1.EndpointAddress{
+			IP:        pod.Status.PodIP,
+			TargetRef: getPodReference(pod),
+		})
+	}
+	var ports []corev1.EndpointPort
+	for _, port := range getTargetPorts(cluster) {
+		ports = append(ports, corev1.EndpointPort{Name: port.Name, Port: port.Port, Protocol: corev1.ProtocolTCP})
+	}
+	endpoints.Subsets = []corev1.EndpointSubset{{Addresses: addresses, Ports: ports}}
+	return endpoints
+}
+
+// NewEndpointSlice returns EndpointSlice of pg-<cluster>-ro service with addresses of the member pods,
+// kube-proxy routes connections of the service by EndpointSlices
+func NewEndpointSlice(cluster *patroniv1.PatroniClusterSettings, pods []corev1.Pod, members []string) *discoveryv1.EndpointSlice {
+	labels := map[string]string{
+		discoveryv1.LabelServiceName: cluster.PatroniReplicasServiceName,
+		discoveryv1.LabelManagedBy:   EndpointSliceManager,
+	}
+	maps.Copy(labels, cluster.PatroniLabels)
+	slice := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cluster.PatroniReplicasServiceName,
+			Namespace: util.GetNameSpace(),
+			Labels:    labels,
+		},
+		AddressType: discoveryv1.AddressTypeIPv4,
+		Endpoints:   []discoveryv1.Endpoint{},
+	}
+	memberPods := getMemberPods(pods, members)
+	for _, pod := range memberPods {
+		endpoint := discoveryv1.Endpoint{
+			Addresses: []string{pod.Status.PodIP},
+			Conditions: discoveryv1.EndpointConditions{
+				Ready:       ptr.To(true),
+				Serving:     ptr.To(true),
+				Terminating: ptr.To(false),
+			},
+			TargetRef: getPodReference(pod),
+		}
+		if pod.Spec.NodeName != "" {
+			endpoint.NodeName = ptr.To(pod.Spec.NodeName)
+		}
+		slice.Endpoints = append(slice.Endpoints, endpoint)
+	}
+	// pods of single stack cluster have addresses of the same family
+	if len(memberPods) > 0 && net.ParseIP(memberPods[0].Status.PodIP).To4() == nil {
+		slice.AddressType = discoveryv1.AddressTypeIPv6
+	}
+	for _, port := range getTargetPorts(cluster) {
+		slice.Ports = append(slice.Ports, discoveryv1.EndpointPort{
+			Name:     ptr.To(port.Name),
+			Port:     ptr.To(port.Port),
+			Protocol: ptr.To(corev1.ProtocolTCP),
+		})
+	}
+	return slice
+}
+
+// getMemberPods returns pods of the members which have IP address and are not deleted
+func getMemberPods(pods []corev1.Pod, members []string) []corev1.Pod {
+	var memberPods []corev1.Pod
+	for _, member := range members {
+		idx := slices.IndexFunc(pods, func(pod corev1.Pod) bool { return pod.Name == member })
+		if idx < 0 || pods[idx].Status.PodIP == "" || pods[idx].DeletionTimestamp != nil {
+			continue
+		}
+		memberPods = append(memberPods, pods[idx])
+	}
+	return memberPods
+}
+
+func getPodReference(pod corev1.Pod) *corev1.ObjectReference {
+	return &corev1.ObjectReference{
+		Kind:      "Pod",
+		Name:      pod.Name,
+		Namespace: pod.Namespace,
+		UID:       pod.UID,
+	}
+}
+
+// getTargetPorts returns ports of the pods, services without selector use ports of the endpoints,
+// so target ports are set there
+func getTargetPorts(cluster *patroniv1.PatroniClusterSettings) []corev1.ServicePort {
+	var ports []corev1.ServicePort
+	for _, port := range deployment.GetPortsForPatroniService(cluster.ClusterName) {
+		if port.TargetPort.IntVal != 0 {
+			port.Port = port.TargetPort.IntVal
+		}
+		ports = append(ports, port)
+	}
+	return ports
+}
+
+// Sync updates EndpointSlice and endpoints of pg-<cluster>-ro service by the state of Patroni cluster
+// and returns selected members. Endpoints are not changed if Patroni is not available.
+func Sync(ph *helper.PatroniHelper, cr *patroniv1.PatroniCore) ([]string, error) {
+	cluster := util.GetPatroniClusterSettings(cr.Spec.Patroni.ClusterName)
+	status, err := ph.GetPatroniClusterConfig(cluster.PatroniUrl)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get Patroni cluster state: %w", err)
+	}
+	if len(status.Members) == 0 {
+		return nil, fmt.Errorf("patroni cluster %s has no members", cluster.ClusterName)
+	}
+	pods, err := ph.GetNamespacePodListBySelectors(cluster.PatroniLabels)
+	if err != nil {
+		return nil, err
+	}
+	members := SelectMembers(status, cr.Spec.Patroni.ReadOnlyService)
+	if err = ph.CreateOrUpdateEndpointSlice(NewEndpointSlice(cluster, pods.Items, members)); err != nil {
+		return nil, err
+	}
+	if err = ph.CreateOrUpdateEndpoints(NewEndpoints(cluster, pods.Items, members)); err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+func hasTag(member helper.Member, tag string) bool {
+	switch value := member.Tags[tag].(type) {
+	case bool:
+		return value
+	case string:
+		enabled, _ := strconv.ParseBool(value)
+		return enabled
+	}
+	return false
+}
+
+// getLag returns the largest lag of the member in bytes, false if Patroni reports it as unknown
+func getLag(member helper.Member) (int64, bool) {
+	lag, known := int64(0), false
+	for _, value := range []interface{}{member.Lag, member.ReceiveLag, member.ReplayLag} {
+		switch number := value.(type) {
+		case float64:
+			known = true
+			lag = max(lag, int64(number))
+		case string:
+			return 0, false
+		}
+	}
+	return lag, known
+}
@@ -0,0 +1,214 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package readonly
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+
+	patroniv1 "github.com/Netcracker/pgskipper-operator/api/patroni/v1"
+	"github.com/Netcracker/pgskipper-operator/pkg/helper"
+	"github.com/Netcracker/pgskipper-operator/pkg/util"
+	_ "github.com/Netcracker/pgskipper-operator/pkg/util/testenv"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+// parseClusterStatus parses response of Patroni /cluster endpoint
+func parseClusterStatus(t *testing.T, response string) *helper.ClusterStatus {
+	t.Helper()
+	status := &helper.ClusterStatus{}
+	if err := json.Unmarshal([]byte(response), status); err != nil {
+		t.Fatal(err)
+	}
+	return status
+}
+
+func TestSelectMembers(t *testing.T) {
+	tests := []struct {
+		name    string
+		cluster string
+		spec    patroniv1.ReadOnlyService
+		want    []string
+	}{
+		{
+			name: "replicas of Patroni 3 within default lag",
+			cluster: `{"members": [
+				{"name": "pg-patroni-node1-0", "role": "leader", "state": "running", "timeline": 3},
+				{"name": "pg-patroni-node3-0", "role": "replica", "state": "running", "timeline": 3, "lag": 0},
+				{"name": "pg-patroni-node2-0", "role": "sync_standby", "state": "running", "timeline": 3, "lag": 1024},
+				{"name": "pg-patroni-node4-0", "role": "replica", "state": "running", "timeline": 3, "lag": 33554432}
+			]}`,
+			want: []string{"pg-patroni-node2-0", "pg-patroni-node3-0"},
+		},
+		{
+			name: "largest lag of Patroni 4 is used",
+			cluster: `{"members": [
+				{"name": "pg-patroni-node1-0", "role": "leader", "state": "running"},
+				{"name": "pg-patroni-node2-0", "role": "replica", "state": "streaming", "lag": 0, "receive_lag": 0, "replay_lag": 2048},
+				{"name": "pg-patroni-node3-0", "role": "replica", "state": "streaming", "lag": 0, "receive_lag": 0, "replay_lag": 4096}
+			]}`,
+			spec: patroniv1.ReadOnlyService{MaxLagBytes: ptr.To[int64](3000)},
+			want: []string{"pg-patroni-node2-0"},
+		},
+		{
+			name: "unknown lag and not running replicas are skipped",
+			cluster: `{"members": [
+				{"name": "pg-patroni-node1-0", "role": "leader", "state": "running"},
+				{"name": "pg-patroni-node2-0", "role": "replica", "state": "running", "lag": "unknown"},
+				{"name": "pg-patroni-node3-0", "role": "replica", "state": "streaming", "lag": 0, "replay_lag": "unknown"},
+				{"name": "pg-patroni-node4-0", "role": "replica", "state": "starting", "lag": 0},
+				{"name": "pg-patroni-node5-0", "role": "replica", "state": "stopped"}
+			]}`,
+			want: nil,
+		},
+		{
+			name: "tagged replicas are skipped",
+			cluster: `{"members": [
+				{"name": "pg-patroni-node1-0", "role": "leader", "state": "running"},
+				{"name": "pg-patroni-node2-0", "role": "replica", "state": "running", "lag": 0, "tags": {"nofailover": true}},
+				{"name": "pg-patroni-node3-0", "role": "replica", "state": "running", "lag": 0, "tags": {"noloadbalance": "true"}},
+				{"name": "pg-patroni-node4-0", "role": "replica", "state": "running", "lag": 0, "tags": {"noloadbalance": false, "nosync": true}}
+			]}`,
+			want: []string{"pg-patroni-node4-0"},
+		},
+		{
+			name: "fallback to leader",
+			cluster: `{"members": [
+				{"name": "pg-patroni-node1-0", "role": "leader", "state": "running"},
+				{"name": "pg-patroni-node2-0", "role": "replica", "state": "running", "lag": 33554432}
+			]}`,
+			spec: patroniv1.ReadOnlyService{FallbackToLeader: true},
+			want: []string{"pg-patroni-node1-0"},
+		},
+		{
+			name: "fallback to standby leader",
+			cluster: `{"members": [
+				{"name": "pg-patroni-node1-0", "role": "standby_leader", "state": "streaming"}
+			]}`,
+			spec: patroniv1.ReadOnlyService{FallbackToLeader: true},
+			want: []string{"pg-patroni-node1-0"},
+		},
+		{
+			name: "no fallback to stopped leader",
+			cluster: `{"members": [
+				{"name": "pg-patroni-node1-0", "role": "leader", "state": "stopped"},
+				{"name": "pg-patroni-node2-0", "role": "replica", "state": "stopped"}
+			]}`,
+			spec: patroniv1.ReadOnlyService{FallbackToLeader: true},
+			want: nil,
+		},
+		{
+			name: "leader is not added when replicas are selected",
+			cluster: `{"members": [
+				{"name": "pg-patroni-node1-0", "role": "leader", "state": "running"},
+				{"name": "pg-patroni-node2-0", "role": "replica", "state": "running", "lag": 0}
+			]}`,
+			spec: patroniv1.ReadOnlyService{FallbackToLeader: true},
+			want: []string{"pg-patroni-node2-0"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SelectMembers(parseClusterStatus(t, tt.cluster), &tt.spec)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("SelectMembers() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetLag(t *testing.T) {
+	tests := []struct {
+		name      string
+		member    string
+		wantLag   int64
+		wantKnown bool
+	}{
+		{name: "no lag", member: `{"name": "node"}`, wantKnown: false},
+		{name: "lag", member: `{"lag": 1024}`, wantLag: 1024, wantKnown: true},
+		{name: "zero lag", member: `{"lag": 0}`, wantLag: 0, wantKnown: true},
+		{name: "unknown lag", member: `{"lag": "unknown"}`, wantKnown: false},
+		{name: "largest lag", member: `{"lag": 10, "receive_lag": 30, "replay_lag": 20}`, wantLag: 30, wantKnown: true},
+		{name: "only replay lag", member: `{"replay_lag": 512}`, wantLag: 512, wantKnown: true},
+		{name: "unknown replay lag", member: `{"lag": 0, "receive_lag": 0, "replay_lag": "unknown"}`, wantKnown: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var member helper.Member
+			if err := json.Unmarshal([]byte(tt.member), &member); err != nil {
+				t.Fatal(err)
+			}
+			lag, known := getLag(member)
+			if lag != tt.wantLag || known != tt.wantKnown {
+				t.Errorf("getLag() = %d, %t, want %d, %t", lag, known, tt.wantLag, tt.wantKnown)
+			}
+		})
+	}
+}
+
+func TestNewEndpointSlice(t *testing.T) {
+	cluster := util.GetPatroniClusterSettings("patroni")
+	pods := []corev1.Pod{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "pg-patroni-node2-0", Namespace: "test", UID: "uid-2"},
+			Spec:       corev1.PodSpec{NodeName: "worker-2"},
+			Status:     corev1.PodStatus{PodIP: "10.0.0.2"},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "pg-patroni-node3-0", Namespace: "test", DeletionTimestamp: &metav1.Time{}},
+			Status:     corev1.PodStatus{PodIP: "10.0.0.3"},
+		},
+		{ObjectMeta: metav1.ObjectMeta{Name: "pg-patroni-node4-0", Namespace: "test"}},
+	}
+	members := []string{"pg-patroni-node2-0", "pg-patroni-node3-0", "pg-patroni-node4-0", "pg-patroni-node5-0"}
+
+	slice := NewEndpointSlice(cluster, pods, members)
+	if slice.Name != "pg-patroni-ro" || slice.Labels[discoveryv1.LabelServiceName] != "pg-patroni-ro" ||
+		slice.Labels[discoveryv1.LabelManagedBy] != EndpointSliceManager {
+		t.Errorf("unexpected name or labels: %s %v", slice.Name, slice.Labels)
+	}
+	if slice.AddressType != discoveryv1.AddressTypeIPv4 {
+		t.Errorf("address type is %s", slice.AddressType)
+	}
+	if len(slice.Endpoints) != 1 {
+		t.Fatalf("expected one endpoint, got %+v", slice.Endpoints)
+	}
+	endpoint := slice.Endpoints[0]
+	if !slices.Equal(endpoint.Addresses, []string{"10.0.0.2"}) || endpoint.TargetRef.Name != "pg-patroni-node2-0" ||
+		ptr.Deref(endpoint.NodeName, "") != "worker-2" || !ptr.Deref(endpoint.Conditions.Ready, false) {
+		t.Errorf("unexpected endpoint %+v", endpoint)
+	}
+	if len(slice.Ports) == 0 {
+		t.Error("ports are not set")
+	}
+
+	pods[0].Status.PodIP = "fd00::2"
+	if slice := NewEndpointSlice(cluster, pods, members); slice.AddressType != discoveryv1.AddressTypeIPv6 {
+		t.Errorf("address type of IPv6 pod is %s", slice.AddressType)
+	}
+	empty := NewEndpointSlice(cluster, pods, nil)
+	if empty.Endpoints == nil || len(empty.Endpoints) != 0 {
+		t.Errorf("expected empty endpoints, got %+v", empty.Endpoints)
+	}
+
+	endpoints := NewEndpoints(cluster, pods, members)
+	if endpoints.Labels[discoveryv1.LabelSkipMirror] != "true" {
+		t.Errorf("endpoints are mirrored by Kubernetes: %v", endpoints.Labels)
+	}
+}
@@ -16,6 +16,7 @@ package reconciler
 
 import (
 	"context"
+	stderrors "errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -31,11 +32,13 @@ import (
 	"github.com/Netcracker/pgskipper-operator/pkg/patroni"
 	"github.com/Netcracker/pgskipper-operator/pkg/powa"
 	"github.com/Netcracker/pgskipper-operator/pkg/queryexporter"
+	"github.com/Netcracker/pgskipper-operator/pkg/readonly"
 	"github.com/Netcracker/pgskipper-operator/pkg/upgrade"
 	opUtil "github.com/Netcracker/pgskipper-operator/pkg/util"
 	"github.com/Netcracker/pgskipper-operator/pkg/vault"
 	"github.com/Netcracker/qubership-credential-manager/pkg/manager"
 	"go.uber.org/zap"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -80,11 +83,40 @@ func (r *PatroniReconciler) Reconcile() error {
 	patroniConfigMap := deployment.ConfigMapForPatroni(r.cluster.ClusterName, r.cluster.PatroniCM, r.cluster.ConfigMapKey)
 	isStandbyClusterPresent := patroni.IsStandbyClusterConfigurationExist(cr)
 	isPgbackrestUsed := cr.Spec.PgBackRest != nil
+	isDryRun := cr.Upgrade != nil && cr.Upgrade.DryRun
 
-	if cr.Upgrade != nil && cr.Upgrade.Enabled {
+	if cr.Upgrade != nil && cr.Upgrade.Rollback {
+		// cluster is restored from the rollback point, CR is switched to the previous image afterwards
+		logger.Info("Major upgrade rollback is requested")
+		return r.upgrade.RollbackUpgrade(cr, r.cluster)
+	}
+
+	if isDryRun {
+		// dry run takes precedence over upgrade, preflight checks run in background and the cluster
+		// is reconciled on the image it's running, so the target image is not rolled out until dry run is disabled
+		logger.Info("Major upgrade dry run is requested, running preflight checks")
+		r.upgrade.StartPreflightChecks(cr, r.cluster)
+		runningImage, err := r.upgrade.GetRunningImage(r.cluster)
+		if err != nil {
+			logger.Error("Can't get image of running Patroni cluster", zap.Error(err))
+			return err
+		}
+		if runningImage != "" {
+			cr = cr.DeepCopy()
+			cr.Spec.Patroni.DockerImage = runningImage
+			patroniSpec = cr.Spec.Patroni
+		}
+	}
+
+	if cr.Upgrade != nil && cr.Upgrade.Enabled && !isDryRun {
 		logger.Info("Starting an upgrade procedure")
 		time.Sleep(30 * time.Second)
 		if err := r.upgrade.ProceedUpgrade(cr, r.cluster); err != nil {
+			if stderrors.Is(err, upgrade.ErrStandbyWaitingForActive) {
+				// resources are not updated with the new image until standby is re-seeded
+				logger.Info("Standby upgrade is waiting for the active site, skipping reconcile of Patroni")
+				return err
+			}
 			logger.Error("Cannot upgrade patroni", zap.Error(err))
 			return err
 		}
@@ -105,6 +137,10 @@ func (r *PatroniReconciler) Reconcile() error {
 		patroni.AddTagsSettings(cr, patroniConfigMap, r.cluster.ConfigMapKey)
 	}
 
+	if patroniSpec.RestApi != nil {
+		patroni.AddRestApiSettings(cr, patroniConfigMap, r.cluster.ConfigMapKey)
+	}
+
 	if isPgbackrestUsed {
 		err := r.preparePgbackRest(cr, patroniConfigMap)
 		if err != nil {
@@ -161,7 +197,7 @@ func (r *PatroniReconciler) Reconcile() error {
 			return err
 		}
 
-		isUpgrade := r.upgrade.CheckUpgrade(cr, r.cluster)
+		isUpgrade := !isDryRun && r.upgrade.CheckUpgrade(cr, r.cluster)
 		if isUpgrade {
 			logger.Info("Proceed Major Upgrade")
 			cr.Upgrade.Enabled = true
@@ -209,7 +245,14 @@ func (r *PatroniReconciler) Reconcile() error {
 			}
 			for _, replica := range replicaPods.Items {
 				statefulsetName := replica.Spec.Containers[0].Name
-				statefulsetIdx, _ := strconv.Atoi(statefulsetName[len(statefulsetName)-1:])
+				statefulsetIdx, err := opUtil.GetPatroniNodeIdx(statefulsetName)
+				if err != nil {
+					return err
+				}
+				if statefulsetIdx > cr.Spec.Patroni.Replicas {
+					logger.Info(fmt.Sprintf("Skip update of replica deployment %s, it will be removed", statefulsetName))
+					continue
+				}
 				logger.Info(fmt.Sprintf("Update replica deployment: %s", statefulsetName))
 				if err = r.processPatroniStatefulset(cr, statefulsetIdx); err != nil {
 					return err
@@ -223,7 +266,10 @@ func (r *PatroniReconciler) Reconcile() error {
 			}
 			// update master deployment
 			statefulsetName := masterPod.Items[0].Spec.Containers[0].Name
-			masterStatefulsetIdx, _ := strconv.Atoi(statefulsetName[len(statefulsetName)-1:])
+			masterStatefulsetIdx, err := opUtil.GetPatroniNodeIdx(statefulsetName)
+			if err != nil {
+				return err
+			}
 			logger.Debug(fmt.Sprintf("Update master deployment: %s", statefulsetName))
 			if err = r.processPatroniStatefulset(cr, masterStatefulsetIdx); err != nil {
 				return err
@@ -261,6 +307,12 @@ func (r *PatroniReconciler) Reconcile() error {
 				}
 			}
 
+			if len(existingStatefulsets) > cr.Spec.Patroni.Replicas {
+				if err := r.removeExcessStatefulsets(existingStatefulsets, cr.Spec.Patroni.Replicas); err != nil {
+					return err
+				}
+			}
+
 			if _, err := r.helper.IsHealthyWithTimeout(3*time.Minute, r.cluster.PatroniUrl, r.cluster.PgHost); err != nil {
 				logger.Error("Patroni cluster is not healthy after master update")
 				return err
@@ -327,19 +379,28 @@ func (r *PatroniReconciler) Reconcile() error {
 		logger.Error("Failed to update Patroni Params, exiting", zap.Error(err))
 		return err
 	}
-	if err := patroni.UpdatePostgreSQLParams(patroniSpec, r.cluster.PatroniUrl); err != nil {
+	ldapPgHba, err := r.helper.GetLdapPgHba(cr, r.cluster)
+	if err != nil {
+		logger.Error("Cannot get LDAP entry of pg_hba, exiting", zap.Error(err))
+		return err
+	}
+	pgHba, err := patroni.RenderPgHba(cr, patroni.GetHbaAuthMethod(cr), ldapPgHba)
+	if err != nil {
+		logger.Error("pg_hba configuration is not valid, exiting", zap.Error(err))
+		return err
+	}
+	if err := patroni.UpdatePostgreSQLParams(patroniSpec, r.cluster.PatroniUrl, pgHba); err != nil {
 		logger.Error("Failed to update PostgreSQL Params, exiting", zap.Error(err))
 		return err
 	}
 
-	//ldap integration settings
+	// ldap integration settings, pg_hba entry of LDAP is rendered with the other entries
 	if cr.Spec.Ldap != nil && cr.Spec.Ldap.Enabled {
 		logger.Info("setting up ldap params")
 		if err := r.helper.CreatePgAdminRole(r.cluster.PgHost); err != nil {
 			logger.Error("Can not create pgadminrole for ldap", zap.Error(err))
 			return err
 		}
-		_ = patroni.SetLdapConfig(cr, r.cluster.PatroniUrl)
 	}
 
 	if err := opUtil.WaitForPatroni(cr, r.cluster.PatroniMasterSelectors, r.cluster.PatroniReplicasSelector); err != nil {
@@ -423,10 +484,7 @@ func (r *PatroniReconciler) processPatroniServices(cr *v1.PatroniCore, patroniSp
 			logger.Error(fmt.Sprintf("Cannot create service %s", pgService.Name), zap.Error(err))
 			return err
 		}
-		pgReadOnlyService := reconcileService(r.cluster.PostgresServiceName+"-ro", r.cluster.PatroniLabels,
-			r.cluster.PatroniReplicasSelector, deployment.GetPortsForPatroniService(r.cluster.ClusterName), false)
-		if err := r.helper.ResourceManager.CreateServiceIfNotExists(pgReadOnlyService); err != nil {
-			logger.Error(fmt.Sprintf("Cannot create service %s", pgReadOnlyService.Name), zap.Error(err))
+		if err := r.reconcileReadOnlyService(cr); err != nil {
 			return err
 		}
 		patroniApiService := reconcileService(r.cluster.PostgresServiceName+"-api", r.cluster.PatroniLabels,
@@ -480,13 +538,18 @@ func (r *PatroniReconciler) processPatroniStatefulset(cr *v1.PatroniCore, deploy
 			return err
 		}
 	}
-	if cr.Spec.PgBackRest != nil && strings.ToLower(cr.Spec.PgBackRest.RepoType) == "rwx" {
-		pgBackrestStorage := cr.Spec.PgBackRest.Rwx
-		pgBackrestStorage.AccessModes = []string{"ReadWriteMany"}
-		pvc = storage.NewPvc("pgbackrest-backups", pgBackrestStorage, 1)
-		if err := r.helper.ResourceManager.CreatePvcIfNotExists(pvc); err != nil {
-			logger.Error(fmt.Sprintf("Cannot create pvc %s", pvc.Name), zap.Error(err))
-			return err
+	if cr.Spec.PgBackRest != nil {
+		for idx, repository := range deployment.GetPgBackRestRepositories(cr.Spec.PgBackRest) {
+			if repository.Type != "rwx" || repository.Rwx == nil {
+				continue
+			}
+			pgBackrestStorage := repository.Rwx
+			pgBackrestStorage.AccessModes = []string{"ReadWriteMany"}
+			pvc = storage.NewPvc(deployment.GetPgBackRestRWXPvcName(idx+1), pgBackrestStorage, 1)
+			if err := r.helper.ResourceManager.CreatePvcIfNotExists(pvc); err != nil {
+				logger.Error(fmt.Sprintf("Cannot create pvc %s", pvc.Name), zap.Error(err))
+				return err
+			}
 		}
 	}
 
@@ -511,6 +574,21 @@ func (r *PatroniReconciler) processPatroniStatefulset(cr *v1.PatroniCore, deploy
 		return err
 	}
 
+	if cr.Spec.PgBackRest != nil {
+		for idx, repository := range deployment.GetPgBackRestRepositories(cr.Spec.PgBackRest) {
+			secretName := repository.CredentialsSecret
+			if secretName == "" {
+				continue
+			}
+			secret, err := r.helper.ResourceManager.GetSecret(secretName)
+			if err != nil {
+				logger.Error(fmt.Sprintf("Cannot get pgBackRest credentials secret %s", secretName), zap.Error(err))
+				return err
+			}
+			deployment.AddPgBackRestCredentials(patroniDeployment, idx+1, secret)
+		}
+	}
+
 	// Vault Section
 	// For DbEngine case this section processed later for patroni
 	if vaultRolesExist || (cr.Spec.VaultRegistration.Enabled && !cr.Spec.VaultRegistration.DbEngine.Enabled) {
@@ -524,6 +602,38 @@ func (r *PatroniReconciler) processPatroniStatefulset(cr *v1.PatroniCore, deploy
 	return nil
 }
 
+// reconcileReadOnlyService creates pg-<cluster>-ro service. When spec.patroni.readOnlyService is enabled, the service
+// has no selector and its EndpointSlice is managed by the operator, otherwise replicas are selected by pgtype label.
+// EndpointSlices of Kubernetes are deleted only after the slice of the operator is created, so the service keeps backends.
+func (r *PatroniReconciler) reconcileReadOnlyService(cr *v1.PatroniCore) error {
+	pgReadOnlyService := reconcileService(r.cluster.PostgresServiceName+"-ro", r.cluster.PatroniLabels,
+		r.cluster.PatroniReplicasSelector, deployment.GetPortsForPatroniService(r.cluster.ClusterName), false)
+	current := r.helper.ResourceManager.GetService(pgReadOnlyService.Name, pgReadOnlyService.Namespace)
+	var err error
+	switch {
+	case readonly.IsEnabled(cr):
+		pgReadOnlyService.Spec.Selector = nil
+		if err = r.helper.ResourceManager.CreateOrUpdateService(pgReadOnlyService); err == nil {
+			_, err = readonly.Sync(r.helper, cr)
+		}
+		if err == nil {
+			err = r.helper.ResourceManager.DeleteControllerEndpointSlices(pgReadOnlyService.Name)
+		}
+	case current != nil && len(current.Spec.Selector) == 0:
+		// selector is returned when management of endpoints is disabled
+		logger.Info(fmt.Sprintf("Restoring selector of service %s", pgReadOnlyService.Name))
+		if err = r.helper.ResourceManager.CreateOrUpdateService(pgReadOnlyService); err == nil {
+			err = r.helper.ResourceManager.DeleteEndpointSlice(pgReadOnlyService.Name)
+		}
+	default:
+		err = r.helper.ResourceManager.CreateServiceIfNotExists(pgReadOnlyService)
+	}
+	if err != nil {
+		logger.Error(fmt.Sprintf("Cannot create service %s", pgReadOnlyService.Name), zap.Error(err))
+	}
+	return err
+}
+
 func (r *PatroniReconciler) createEndpointsForEtcdAsDcs() error {
 	pgEndpoint := reconcileEndpoint(r.cluster.PostgresServiceName, r.cluster.PatroniLabels)
 	if err := r.helper.ResourceManager.CreateEndpointIfNotExists(pgEndpoint); err != nil {
@@ -657,6 +767,36 @@ func (r *PatroniReconciler) getCollationsForRefresh(pgClient *pgClient.PostgresC
 	return cForRefresh, nil
 }
 
+// removeExcessStatefulsets removes members with the highest indexes when replicas count is decreased,
+// PVCs of removed members are kept
+func (r *PatroniReconciler) removeExcessStatefulsets(statefulsets []*appsv1.StatefulSet, replicas int) error {
+	masterPod, err := r.helper.ResourceManager.GetPodsByLabel(r.cluster.PatroniMasterSelectors)
+	if err != nil {
+		return err
+	}
+	masterIdx := 0
+	if len(masterPod.Items) != 0 {
+		if masterIdx, err = opUtil.GetPatroniNodeIdx(masterPod.Items[0].Name); err != nil {
+			return err
+		}
+	}
+	toRemove, err := opUtil.GetExcessNodes(statefulsets, replicas)
+	if err != nil {
+		return err
+	}
+	for _, statefulset := range toRemove {
+		nodeIdx, _ := opUtil.GetPatroniNodeIdx(statefulset.Name)
+		if nodeIdx == masterIdx {
+			return fmt.Errorf("can't remove %s, it's the leader of Patroni cluster, perform switchover first", statefulset.Name)
+		}
+		logger.Info(fmt.Sprintf("Replicas count is decreased to %d, removing StatefulSet %s", replicas, statefulset.Name))
+		if err := r.helper.ResourceManager.DeleteStatefulset(statefulset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (r *PatroniReconciler) processPgWalStorageExternal() error {
 
 	logger.Info("Start pg_wal copying process")
@@ -667,7 +807,11 @@ func (r *PatroniReconciler) processPgWalStorageExternal() error {
 	}
 
 	for _, replica := range replicaPods.Items {
-		replicaIdx := replica.Spec.Containers[0].Name[len(replica.Spec.Containers[0].Name)-1:]
+		nodeIdx, err := opUtil.GetPatroniNodeIdx(replica.Spec.Containers[0].Name)
+		if err != nil {
+			return err
+		}
+		replicaIdx := strconv.Itoa(nodeIdx)
 		if !r.checkSymlinkAlreadyExist(replica.Name, replicaIdx) {
 			if err = r.execWalMovingForNode(replica.Name, replicaIdx); err != nil {
 				logger.Error("Can not copy pg_wal files", zap.Error(err))
@@ -684,7 +828,11 @@ func (r *PatroniReconciler) processPgWalStorageExternal() error {
 		return err
 	}
 
-	masterIdx := masterPod.Items[0].Spec.Containers[0].Name[len(masterPod.Items[0].Spec.Containers[0].Name)-1:]
+	nodeIdx, err := opUtil.GetPatroniNodeIdx(masterPod.Items[0].Spec.Containers[0].Name)
+	if err != nil {
+		return err
+	}
+	masterIdx := strconv.Itoa(nodeIdx)
 	if !r.checkSymlinkAlreadyExist(masterPod.Items[0].Name, masterIdx) {
 		if err = r.execWalMovingForNode(masterPod.Items[0].Name, masterIdx); err != nil {
 			logger.Error("Can not copy pg_wal files", zap.Error(err))
@@ -730,6 +878,17 @@ func (r *PatroniReconciler) preparePgbackRest(cr *v1.PatroniCore, patroniConfigM
 		return err
 	}
 
+	// Keys set directly in CR are kept in the Secret managed by operator
+	if len(cr.Spec.PgBackRest.Repositories) == 0 && cr.Spec.PgBackRest.CredentialsSecret == "" &&
+		deployment.GetPgBackRestCredentialsSecretName(cr.Spec.PgBackRest) != "" {
+		logger.Warn("pgBackRest S3 key and secret in CR are deprecated, use credentialsSecret instead")
+		credentialsSecret := deployment.NewPgBackRestCredentialsSecret(cr.Spec.PgBackRest, r.cluster.PatroniLabels)
+		if err := r.helper.CreateOrUpdateSecret(credentialsSecret); err != nil {
+			logger.Error(fmt.Sprintf("Cannot create or update secret %s", credentialsSecret.Name), zap.Error(err))
+			return err
+		}
+	}
+
 	// Add pgbackrest section to patroni CM
 	pgbackrest := map[string]string{
 		"command":   "pgbackrest --stanza=patroni --delta --log-level-file=detail restore",
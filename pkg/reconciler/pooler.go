@@ -22,11 +22,17 @@ import (
 	"github.com/Netcracker/pgskipper-operator/pkg/credentials"
 	"github.com/Netcracker/pgskipper-operator/pkg/deployment"
 	"github.com/Netcracker/pgskipper-operator/pkg/helper"
+	"github.com/Netcracker/pgskipper-operator/pkg/patroni"
 	"github.com/Netcracker/pgskipper-operator/pkg/pooler"
 	opUtil "github.com/Netcracker/pgskipper-operator/pkg/util"
+	"github.com/Netcracker/pgskipper-operator/pkg/util/constants"
 	"github.com/Netcracker/qubership-credential-manager/pkg/manager"
 	"go.uber.org/zap"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 type PoolerReconciler struct {
@@ -46,12 +52,43 @@ func NewPoolerReconciler(cr *qubershipv1.PatroniServices, helper *helper.Helper,
 func (r *PoolerReconciler) Reconcile() error {
 	cr := r.cr
 	poolerSpec := cr.Spec.Pooler
-	updated, err := r.helper.CreateOrUpdateConfigMap(pooler.GetConfigMap(poolerSpec))
+	authMethod := r.getAuthMethod()
+	creds, err := pooler.GetPgBouncerCreds()
 	if err != nil {
-		logger.Error("error during Pooler CM creation", zap.Error(err))
 		return err
 	}
+	if authMethod == constants.ScramSHA256 {
+		if err = creds.CheckScramCompatible(); err != nil {
+			return err
+		}
+	}
+
+	// mounted credentials are compared with pgbouncer-secret to find out password change
+	mountedCreds, err := pooler.GetMountedCreds(r.helper)
+	if err != nil {
+		return err
+	}
+	credsChanged := mountedCreds == nil || *mountedCreds != *creds
+
+	usernameChanged := mountedCreds != nil && mountedCreds.Username() != creds.Username()
 	newPatroniName := fmt.Sprintf("pg-%s-direct", r.cluster.ClusterName)
+	readOnlyInstalled := poolerSpec.ReadOnly != nil && poolerSpec.ReadOnly.Install
+
+	config := pooler.GetConfig(poolerSpec, authMethod, creds.Username())
+	primary, err := r.newPoolerInstance(pooler.Primary, poolerSpec, config, newPatroniName, usernameChanged)
+	if err != nil {
+		return err
+	}
+	instances := []*poolerInstance{primary}
+	if readOnlyInstalled {
+		readOnlyConfig := pooler.ReadOnlyConfig(config, []string{newPatroniName, r.cluster.PostgresServiceName}, r.cluster.PatroniReplicasServiceName)
+		readOnly, err := r.newPoolerInstance(pooler.ReadOnly, r.getReadOnlySpec(), readOnlyConfig, r.cluster.PatroniReplicasServiceName, usernameChanged)
+		if err != nil {
+			return err
+		}
+		instances = append(instances, readOnly)
+	}
+
 	pgService := reconcileService(newPatroniName, r.cluster.PatroniLabels,
 		r.cluster.PatroniMasterSelectors, deployment.GetPortsForPatroniService(r.cluster.ClusterName), false)
 	if err = r.helper.CreateOrUpdateService(pgService); err != nil {
@@ -59,32 +96,94 @@ func (r *PoolerReconciler) Reconcile() error {
 		return err
 	}
 
-	if updated {
-		// Scale deployment for applying new configuration
-		depl, err := r.helper.GetDeploymentsByNameRegExp(pooler.DeploymentName)
-		if err != nil {
+	// Create Super User for authentication check
+	if err = pooler.SetUpDatabase(r.helper, poolerSpec, creds, newPatroniName, credsChanged); err != nil {
+		return err
+	}
+	if credsChanged {
+		if err = r.helper.CreateOrUpdateSecret(pooler.GetCredentialsSecret(creds)); err != nil {
 			return err
 		}
-		for _, d := range depl {
-			zeroCount := int32(0)
-			d.Spec.Replicas = &zeroCount
-			if err = r.helper.CreateOrUpdateDeployment(d, false); err != nil {
-				return err
-			}
+	}
+
+	for _, instance := range instances {
+		if err = r.updateDeployment(instance); err != nil {
+			return err
 		}
 	}
 
-	creds, err := pooler.GetPgBouncerCreds()
-	if err != nil {
-		return err
+	// running pods re-read auth_file on RELOAD, password change doesn't restart them
+	if credsChanged && mountedCreds != nil && !usernameChanged {
+		if err = pooler.ReloadPgBouncer(r.helper, poolerSpec, mountedCreds, creds); err != nil {
+			logger.Error("Cannot reload Pooler with the new password", zap.Error(err))
+			return err
+		}
 	}
 
-	// Create Super User for authentication check
-	if err = pooler.SetUpDatabase(creds, newPatroniName); err != nil {
+	for _, instance := range instances {
+		if err = r.applyConfigChange(instance, creds); err != nil {
+			return err
+		}
+		if err = r.helper.CreateOrUpdatePodDisruptionBudget(instance.NewPodDisruptionBudget(instance.spec)); err != nil {
+			logger.Error(fmt.Sprintf("Cannot create PodDisruptionBudget %s", instance.DeploymentName), zap.Error(err))
+			return err
+		}
+		if err = r.reconcileMetricsService(instance.Instance); err != nil {
+			return err
+		}
+	}
+
+	if err = pooler.UpdatePatroniService(r.helper, r.cluster.PostgresServiceName); err != nil {
 		return err
 	}
 
-	poolerDeployment := pooler.NewPoolerDeployment(poolerSpec, cr.Spec.ServiceAccountName, creds, newPatroniName)
+	return r.reconcileReadOnlyService(readOnlyInstalled)
+}
+
+// poolerInstance is the state of one pooler Deployment during reconcile
+type poolerInstance struct {
+	pooler.Instance
+	spec          qubershipv1.Pooler
+	postgresHost  string
+	config        map[string]map[string]string
+	currentConfig map[string]map[string]string
+	configChange  pooler.ConfigChange
+	deployment    *appsv1.Deployment
+}
+
+// newPoolerInstance classifies the config change of the pooler and updates its ConfigMap
+func (r *PoolerReconciler) newPoolerInstance(instance pooler.Instance, spec qubershipv1.Pooler,
+	config map[string]map[string]string, postgresHost string, usernameChanged bool) (*poolerInstance, error) {
+	currentConfig, err := instance.GetCurrentConfig()
+	if err != nil {
+		return nil, err
+	}
+	configChange := pooler.ClassifyConfigChange(currentConfig, config)
+	if usernameChanged {
+		// running pods don't accept the new admin user until restart
+		configChange = pooler.ConfigRestart
+	}
+	if _, err = r.helper.CreateOrUpdateConfigMap(instance.GetConfigMap(config)); err != nil {
+		logger.Error(fmt.Sprintf("error during %s CM creation", instance.DeploymentName), zap.Error(err))
+		return nil, err
+	}
+	return &poolerInstance{
+		Instance:      instance,
+		spec:          spec,
+		postgresHost:  postgresHost,
+		config:        config,
+		currentConfig: currentConfig,
+		configChange:  configChange,
+	}, nil
+}
+
+func (r *PoolerReconciler) updateDeployment(instance *poolerInstance) error {
+	cr := r.cr
+	configHash, err := instance.ConfigHash(r.helper, instance.config, instance.configChange)
+	if err != nil {
+		return err
+	}
+	poolerDeployment := instance.NewPoolerDeployment(instance.spec, cr.Spec.ServiceAccountName, instance.postgresHost, configHash)
 
 	if cr.Spec.PrivateRegistry.Enabled {
 		for _, name := range cr.Spec.PrivateRegistry.Names {
@@ -105,15 +204,101 @@ func (r *PoolerReconciler) Reconcile() error {
 	}
 
 	//Adding SecurityContext
-	poolerDeployment.Spec.Template.Spec.Containers[0].SecurityContext = opUtil.GetDefaultSecurityContext()
+	for i := range poolerDeployment.Spec.Template.Spec.Containers {
+		poolerDeployment.Spec.Template.Spec.Containers[i].SecurityContext = opUtil.GetDefaultSecurityContext()
+	}
 
 	if err = r.helper.CreateOrUpdateDeploymentForce(poolerDeployment, false); err != nil {
-		logger.Error("error during creation of the Pooler deployment", zap.Error(err))
+		logger.Error(fmt.Sprintf("error during creation of the %s deployment", poolerDeployment.Name), zap.Error(err))
 	}
+	instance.deployment = poolerDeployment
+	return nil
+}
 
-	if err = pooler.UpdatePatroniService(r.helper, r.cluster.PostgresServiceName); err != nil {
+// applyConfigChange applies ConfigReload change by RELOAD, pods are rolled if RELOAD doesn't apply it
+func (r *PoolerReconciler) applyConfigChange(instance *poolerInstance, creds *pooler.PgBouncerCreds) error {
+	if instance.configChange != pooler.ConfigReload {
+		return nil
+	}
+	logger.Info(fmt.Sprintf("Config of %s is changed, applying it by RELOAD", instance.DeploymentName))
+	if err := instance.ReloadConfig(r.helper, instance.spec, creds, instance.currentConfig, instance.config); err != nil {
+		// pods which didn't apply the config get it on start
+		logger.Error(fmt.Sprintf("Cannot apply config of %s by RELOAD, pods will be rolled", instance.DeploymentName), zap.Error(err))
+		configHash, _ := instance.ConfigHash(r.helper, instance.config, pooler.ConfigRestart)
+		instance.deployment.Spec.Template.Annotations[pooler.ConfigHashAnnotation] = configHash
+		if err = r.helper.CreateOrUpdateDeploymentForce(instance.deployment, false); err != nil {
+			logger.Error(fmt.Sprintf("error during rolling update of the %s deployment", instance.DeploymentName), zap.Error(err))
+			return err
+		}
+	}
+	return nil
+}
+
+// reconcileMetricsService creates the service of the exporter sidecars when metrics are enabled
+func (r *PoolerReconciler) reconcileMetricsService(instance pooler.Instance) error {
+	metrics := r.cr.Spec.Pooler.Metrics
+	if metrics == nil || !metrics.Install {
+		return r.helper.DeleteService(instance.MetricsServiceName())
+	}
+	service := instance.NewMetricsService()
+	if err := r.helper.CreateOrUpdateService(service); err != nil {
+		logger.Error(fmt.Sprintf("Cannot create service %s", service.Name), zap.Error(err))
 		return err
 	}
+	return nil
+}
 
+// getReadOnlySpec returns the spec of ReadOnly pooler, it differs from the primary one only by replicas
+func (r *PoolerReconciler) getReadOnlySpec() qubershipv1.Pooler {
+	spec := r.cr.Spec.Pooler
+	if spec.ReadOnly.Replicas != nil {
+		spec.Replicas = spec.ReadOnly.Replicas
+	}
+	return spec
+}
+
+// reconcileReadOnlyService creates pg-<cluster>-ro-pooler service of ReadOnly pooler,
+// the service and the Deployment are deleted when ReadOnly pooler is not installed
+func (r *PoolerReconciler) reconcileReadOnlyService(installed bool) error {
+	serviceName := r.cluster.PatroniReplicasServiceName + "-pooler"
+	if !installed {
+		err, readOnly := r.helper.FindDeployment(&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: pooler.ReadOnlyDeploymentName, Namespace: opUtil.GetNameSpace()},
+		})
+		if err == nil {
+			logger.Info(fmt.Sprintf("Deleting %s deployment", readOnly.Name))
+			if err = r.helper.DeleteDeployment(readOnly); err != nil {
+				return err
+			}
+		} else if !errors.IsNotFound(err) {
+			return err
+		}
+		if err = r.helper.DeleteService(pooler.ReadOnly.MetricsServiceName()); err != nil {
+			return err
+		}
+		return r.helper.DeleteService(serviceName)
+	}
+	ports := []corev1.ServicePort{{
+		Name:       "pg",
+		Protocol:   corev1.ProtocolTCP,
+		Port:       5432,
+		TargetPort: intstr.IntOrString{IntVal: 6432},
+	}}
+	service := reconcileService(serviceName, r.cluster.PatroniLabels, pooler.ReadOnly.Labels(), ports, false)
+	if err := r.helper.CreateOrUpdateService(service); err != nil {
+		logger.Error(fmt.Sprintf("Cannot create service %s", serviceName), zap.Error(err))
+		return err
+	}
 	return nil
 }
+
+// getAuthMethod returns password authentication method used in pg_hba of Patroni cluster,
+// pooler follows it when migration to scram-sha-256 is completed
+func (r *PoolerReconciler) getAuthMethod() string {
+	patroniCore, err := r.helper.GetPatroniCoreCR()
+	if err != nil {
+		logger.Info("Cannot get PatroniCore, pooler will use auth_type from its config", zap.Error(err))
+		return ""
+	}
+	return patroni.GetHbaAuthMethod(patroniCore)
+}
@@ -0,0 +1,148 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	qubershipv1 "github.com/Netcracker/pgskipper-operator/api/patroni/v1"
+	"github.com/Netcracker/pgskipper-operator/pkg/util"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// scheduledBackupLabel marks PostgresBackup created by repository schedule
+const scheduledBackupLabel = "qubership.org/scheduled-backup"
+
+const (
+	defaultSuccessfulBackupsHistoryLimit = 3
+	defaultFailedBackupsHistoryLimit     = 1
+)
+
+// historyLimits is the number of completed scheduled PostgresBackups of the repository kept by phase
+type historyLimits map[string]int
+
+func getHistoryLimits(repository qubershipv1.PgBackRestRepository) historyLimits {
+	limits := historyLimits{
+		qubershipv1.PhaseSucceeded: defaultSuccessfulBackupsHistoryLimit,
+		qubershipv1.PhaseFailed:    defaultFailedBackupsHistoryLimit,
+	}
+	if repository.SuccessfulBackupsHistoryLimit != nil {
+		limits[qubershipv1.PhaseSucceeded] = int(*repository.SuccessfulBackupsHistoryLimit)
+	}
+	if repository.FailedBackupsHistoryLimit != nil {
+		limits[qubershipv1.PhaseFailed] = int(*repository.FailedBackupsHistoryLimit)
+	}
+	return limits
+}
+
+// ScheduleRepositoryBackups creates cron jobs for schedules of pgBackRest repositories,
+// every job creates PostgresBackup of its type for the repository
+func ScheduleRepositoryBackups(cr *qubershipv1.PatroniCore) error {
+	if cr.Spec.PgBackRest == nil {
+		return nil
+	}
+	scheduled := false
+	for idx, repository := range cr.Spec.PgBackRest.Repositories {
+		repo := idx + 1
+		schedules := map[string]string{
+			"full": repository.FullSchedule,
+			"diff": repository.DiffSchedule,
+			"incr": repository.IncrSchedule,
+		}
+		for backupType, schedule := range schedules {
+			if schedule == "" {
+				continue
+			}
+			if _, err := s.Cron(schedule).Do(createScheduledBackup, backupType, repo, getHistoryLimits(repository)); err != nil {
+				return fmt.Errorf("cannot schedule %s backups of repo%d by %q: %w", backupType, repo, schedule, err)
+			}
+			logger.Info(fmt.Sprintf("%s backups of repo%d are scheduled by %q", backupType, repo, schedule))
+			scheduled = true
+		}
+	}
+	if scheduled && !s.IsRunning() {
+		logger.Info("Starting scheduler")
+		s.StartAsync()
+	}
+	return nil
+}
+
+func createScheduledBackup(backupType string, repo int, limits historyLimits) {
+	kubeClient, err := util.GetClient()
+	if err != nil {
+		logger.Error("Cannot get k8s client for scheduled backup", zap.Error(err))
+		return
+	}
+	backup := &qubershipv1.PostgresBackup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("scheduled-repo%d-%s-%s", repo, backupType, time.Now().UTC().Format("20060102-150405")),
+			Namespace: util.GetNameSpace(),
+			Labels:    map[string]string{scheduledBackupLabel: fmt.Sprintf("repo%d", repo)},
+		},
+		Spec: qubershipv1.PostgresBackupSpec{
+			Type: backupType,
+			Repo: repo,
+		},
+	}
+	if err := kubeClient.Create(context.TODO(), backup); err != nil {
+		logger.Error(fmt.Sprintf("Cannot create scheduled backup %s", backup.Name), zap.Error(err))
+		return
+	}
+	logger.Info(fmt.Sprintf("Scheduled backup %s is created", backup.Name))
+	if err := pruneScheduledBackups(context.TODO(), kubeClient, repo, limits); err != nil {
+		logger.Error(fmt.Sprintf("Cannot prune scheduled backups of repo%d", repo), zap.Error(err))
+	}
+}
+
+// pruneScheduledBackups deletes completed scheduled PostgresBackups of the repository above history limits,
+// the oldest are deleted first. Backup sets in the repository are not affected, they are expired by retention.
+func pruneScheduledBackups(ctx context.Context, kubeClient client.Client, repo int, limits historyLimits) error {
+	backups := &qubershipv1.PostgresBackupList{}
+	if err := kubeClient.List(ctx, backups, client.InNamespace(util.GetNameSpace()),
+		client.MatchingLabels{scheduledBackupLabel: fmt.Sprintf("repo%d", repo)}); err != nil {
+		return err
+	}
+	byPhase := map[string][]qubershipv1.PostgresBackup{}
+	for _, backup := range backups.Items {
+		if _, ok := limits[backup.Status.Phase]; ok {
+			byPhase[backup.Status.Phase] = append(byPhase[backup.Status.Phase], backup)
+		}
+	}
+	for phase, completed := range byPhase {
+		if len(completed) <= limits[phase] {
+			continue
+		}
+		sort.Slice(completed, func(i, j int) bool {
+			if completed[i].CreationTimestamp.Equal(&completed[j].CreationTimestamp) {
+				return completed[i].Name > completed[j].Name
+			}
+			return completed[j].CreationTimestamp.Before(&completed[i].CreationTimestamp)
+		})
+		for idx := range completed[limits[phase]:] {
+			backup := &completed[limits[phase]+idx]
+			if err := kubeClient.Delete(ctx, backup); err != nil && !errors.IsNotFound(err) {
+				return err
+			}
+			logger.Info(fmt.Sprintf("%s scheduled backup %s is deleted by history limit", phase, backup.Name))
+		}
+	}
+	return nil
+}
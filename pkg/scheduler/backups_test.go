@@ -0,0 +1,95 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	qubershipv1 "github.com/Netcracker/pgskipper-operator/api/patroni/v1"
+	"github.com/Netcracker/pgskipper-operator/pkg/util"
+	_ "github.com/Netcracker/pgskipper-operator/pkg/util/testenv"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newScheduledBackup(repo int, idx int, phase string) *qubershipv1.PostgresBackup {
+	return &qubershipv1.PostgresBackup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              fmt.Sprintf("scheduled-repo%d-full-%02d", repo, idx),
+			Namespace:         util.GetNameSpace(),
+			Labels:            map[string]string{scheduledBackupLabel: fmt.Sprintf("repo%d", repo)},
+			CreationTimestamp: metav1.NewTime(time.Date(2024, 9, 19, 0, idx, 0, 0, time.UTC)),
+		},
+		Status: qubershipv1.PostgresBackupStatus{Phase: phase},
+	}
+}
+
+func TestPruneScheduledBackups(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := qubershipv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	phases := []string{
+		qubershipv1.PhaseSucceeded, qubershipv1.PhaseFailed, qubershipv1.PhaseSucceeded, qubershipv1.PhaseFailed,
+		qubershipv1.PhaseSucceeded, qubershipv1.PhaseSucceeded, qubershipv1.PhaseRunning, qubershipv1.PhasePending,
+	}
+	var objects []client.Object
+	for idx, phase := range phases {
+		objects = append(objects, newScheduledBackup(1, idx, phase))
+	}
+	// backups of other repositories are not counted
+	objects = append(objects, newScheduledBackup(2, 0, qubershipv1.PhaseSucceeded))
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+
+	limits := historyLimits{qubershipv1.PhaseSucceeded: 2, qubershipv1.PhaseFailed: 0}
+	if err := pruneScheduledBackups(context.Background(), kubeClient, 1, limits); err != nil {
+		t.Fatalf("cannot prune backups: %v", err)
+	}
+
+	backups := &qubershipv1.PostgresBackupList{}
+	if err := kubeClient.List(context.Background(), backups); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, backup := range backups.Items {
+		names = append(names, backup.Name)
+	}
+	sort.Strings(names)
+	expected := []string{
+		"scheduled-repo1-full-04", "scheduled-repo1-full-05", "scheduled-repo1-full-06", "scheduled-repo1-full-07",
+		"scheduled-repo2-full-00",
+	}
+	if fmt.Sprint(names) != fmt.Sprint(expected) {
+		t.Errorf("backups after pruning are %v, expected %v", names, expected)
+	}
+}
+
+func TestGetHistoryLimits(t *testing.T) {
+	limits := getHistoryLimits(qubershipv1.PgBackRestRepository{})
+	if limits[qubershipv1.PhaseSucceeded] != defaultSuccessfulBackupsHistoryLimit || limits[qubershipv1.PhaseFailed] != defaultFailedBackupsHistoryLimit {
+		t.Errorf("default limits are %v", limits)
+	}
+	successful, failed := int32(0), int32(5)
+	limits = getHistoryLimits(qubershipv1.PgBackRestRepository{SuccessfulBackupsHistoryLimit: &successful, FailedBackupsHistoryLimit: &failed})
+	if limits[qubershipv1.PhaseSucceeded] != 0 || limits[qubershipv1.PhaseFailed] != 5 {
+		t.Errorf("limits are %v, expected 0 and 5", limits)
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scheduler
+
+import (
+	"fmt"
+	"time"
+
+	qubershipv1 "github.com/Netcracker/pgskipper-operator/api/apps/v1"
+	"github.com/Netcracker/pgskipper-operator/pkg/credentials"
+	"github.com/Netcracker/pgskipper-operator/pkg/helper"
+	"github.com/Netcracker/pgskipper-operator/pkg/util"
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// rotationCheckSchedule is how often due rotation policies are looked for
+const rotationCheckSchedule = "*/5 * * * *"
+
+// ScheduleCredentialsRotation adds a job which rotates passwords when their time comes,
+// the time of the next rotation is kept in PatroniServices status, so it's not reset by operator restart
+func ScheduleCredentialsRotation(cr *qubershipv1.PatroniServices) error {
+	if cr.Spec.CredentialsRotation == nil || len(cr.Spec.CredentialsRotation.Policies) == 0 {
+		return nil
+	}
+	if _, err := s.Cron(rotationCheckSchedule).SingletonMode().Do(rotateDueCredentials); err != nil {
+		return fmt.Errorf("cannot schedule credentials rotation: %w", err)
+	}
+	logger.Info(fmt.Sprintf("Credentials rotation is scheduled for %d secrets", len(cr.Spec.CredentialsRotation.Policies)))
+	if !s.IsRunning() {
+		logger.Info("Starting scheduler")
+		s.StartAsync()
+	}
+	return nil
+}
+
+// NextRotationTime returns time of the rotation after the given one by cron schedule or interval of the policy
+func NextRotationTime(policy qubershipv1.CredentialsRotationPolicy, after time.Time) (time.Time, error) {
+	if policy.Schedule != "" {
+		schedule, err := cron.ParseStandard(policy.Schedule)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return schedule.Next(after), nil
+	}
+	if policy.Interval != nil && policy.Interval.Duration > 0 {
+		return after.Add(policy.Interval.Duration), nil
+	}
+	return time.Time{}, fmt.Errorf("schedule or interval is required")
+}
+
+func rotateDueCredentials() {
+	cr, err := helper.GetHelper().GetPostgresServiceCR()
+	if err != nil {
+		logger.Error("Cannot get PatroniServices for credentials rotation", zap.Error(err))
+		return
+	}
+	if cr.Spec.CredentialsRotation == nil {
+		return
+	}
+	clusterName := "patroni"
+	if cr.Spec.Patroni != nil && cr.Spec.Patroni.ClusterName != "" {
+		clusterName = cr.Spec.Patroni.ClusterName
+	}
+	cluster := util.GetPatroniClusterSettings(clusterName)
+
+	for _, policy := range cr.Spec.CredentialsRotation.Policies {
+		status := getRotationStatus(cr, policy.SecretName)
+		now := time.Now()
+		if status.NextRotationTime == nil || status.Policy != rotationPolicyKey(policy) {
+			// the first rotation is planned from now, not from creation of the Secret
+			next, err := NextRotationTime(policy, now)
+			if err != nil {
+				status.Result = qubershipv1.RotationFailed
+				status.Message = err.Error()
+			} else {
+				status.NextRotationTime = &metav1.Time{Time: next}
+				status.Policy = rotationPolicyKey(policy)
+			}
+			updateRotationStatus(status)
+			continue
+		}
+		if now.Before(status.NextRotationTime.Time) {
+			continue
+		}
+
+		status.LastRotationTime = &metav1.Time{Time: now}
+		if err := rotationAllowed(cr); err != nil {
+			status.Result = qubershipv1.RotationFailed
+			status.Message = err.Error()
+		} else if err := credentials.RotateCredentials(policy, cluster); err != nil {
+			logger.Error(fmt.Sprintf("Rotation of secret %s failed", policy.SecretName), zap.Error(err))
+			status.Result = qubershipv1.RotationFailed
+			status.Message = err.Error()
+		} else {
+			status.Result = qubershipv1.RotationSucceeded
+			status.Message = ""
+		}
+		if next, err := NextRotationTime(policy, time.Now()); err == nil {
+			status.NextRotationTime = &metav1.Time{Time: next}
+		}
+		updateRotationStatus(status)
+	}
+}
+
+func rotationAllowed(cr *qubershipv1.PatroniServices) error {
+	if cr.Spec.ExternalDataBase != nil {
+		return fmt.Errorf("credentials rotation is not supported for external database")
+	}
+	if cr.Spec.VaultRegistration != nil && cr.Spec.VaultRegistration.DbEngine.Enabled {
+		return fmt.Errorf("credentials are rotated by Vault database engine")
+	}
+	return nil
+}
+
+func rotationPolicyKey(policy qubershipv1.CredentialsRotationPolicy) string {
+	if policy.Schedule != "" {
+		return policy.Schedule
+	}
+	if policy.Interval != nil {
+		return policy.Interval.Duration.String()
+	}
+	return ""
+}
+
+func getRotationStatus(cr *qubershipv1.PatroniServices, secretName string) qubershipv1.CredentialsRotationStatus {
+	for _, status := range cr.Status.CredentialsRotation {
+		if status.SecretName == secretName {
+			return *status.DeepCopy()
+		}
+	}
+	return qubershipv1.CredentialsRotationStatus{SecretName: secretName}
+}
+
+func updateRotationStatus(status qubershipv1.CredentialsRotationStatus) {
+	if err := helper.GetHelper().UpdatePostgresServiceStatus(func(crStatus *qubershipv1.PatroniServicesStatus) {
+		updated := false
+		for i := range crStatus.CredentialsRotation {
+			if crStatus.CredentialsRotation[i].SecretName == status.SecretName {
+				crStatus.CredentialsRotation[i] = status
+				updated = true
+			}
+		}
+		if !updated {
+			crStatus.CredentialsRotation = append(crStatus.CredentialsRotation, status)
+		}
+	}); err != nil {
+		logger.Error(fmt.Sprintf("Cannot update rotation status of secret %s", status.SecretName), zap.Error(err))
+	}
+}
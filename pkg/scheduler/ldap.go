@@ -0,0 +1,120 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scheduler
+
+import (
+	"fmt"
+	"time"
+
+	qubershipv1 "github.com/Netcracker/pgskipper-operator/api/patroni/v1"
+	pgClient "github.com/Netcracker/pgskipper-operator/pkg/client"
+	"github.com/Netcracker/pgskipper-operator/pkg/helper"
+	"github.com/Netcracker/pgskipper-operator/pkg/ldapsync"
+	"github.com/Netcracker/pgskipper-operator/pkg/patroni"
+	"github.com/Netcracker/pgskipper-operator/pkg/util"
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DefaultLdapSyncSchedule is a schedule of LDAP group synchronization if it isn't set in CR
+const DefaultLdapSyncSchedule = "*/15 * * * *"
+
+// ScheduleLdapGroupSync adds a job which synchronizes members of LDAP groups to PostgreSQL roles
+func ScheduleLdapGroupSync(cr *qubershipv1.PatroniCore) error {
+	ldap := cr.Spec.Ldap
+	if ldap == nil || !ldap.Enabled || ldap.GroupSync == nil || !ldap.GroupSync.Enabled {
+		return nil
+	}
+	schedule := ldap.GroupSync.Schedule
+	if schedule == "" {
+		schedule = DefaultLdapSyncSchedule
+	}
+	if _, err := s.Cron(schedule).SingletonMode().Do(syncLdapGroups); err != nil {
+		return fmt.Errorf("cannot schedule LDAP group sync by %q: %w", schedule, err)
+	}
+	logger.Info(fmt.Sprintf("LDAP group sync of %d mappings is scheduled by %q", len(ldap.GroupSync.Mappings), schedule))
+	if !s.IsRunning() {
+		logger.Info("Starting scheduler")
+		s.StartAsync()
+	}
+	return nil
+}
+
+func syncLdapGroups() {
+	ph := helper.GetPatroniHelper()
+	cr, err := ph.GetPatroniCoreCR()
+	if err != nil {
+		logger.Error("Cannot get PatroniCore for LDAP group sync", zap.Error(err))
+		return
+	}
+	if cr.Spec == nil || cr.Spec.Ldap == nil || cr.Spec.Ldap.GroupSync == nil {
+		return
+	}
+	if patroni.IsStandbyClusterConfigurationExist(cr) {
+		logger.Info("It's standby cluster, roles are synchronized on the active cluster, LDAP group sync is skipped")
+		return
+	}
+	status := &qubershipv1.LdapGroupSyncStatus{LastSyncTime: &metav1.Time{Time: time.Now()}}
+	members, err := runLdapGroupSync(ph, cr)
+	status.Members = int32(members)
+	if err != nil {
+		logger.Error("LDAP group sync failed", zap.Error(err))
+		status.Message = err.Error()
+	} else {
+		logger.Info(fmt.Sprintf("LDAP group sync succeeded, %d members of mapped roles", members))
+	}
+	updateLdapGroupSyncStatus(status)
+}
+
+func runLdapGroupSync(ph *helper.PatroniHelper, cr *qubershipv1.PatroniCore) (int, error) {
+	ldap := cr.Spec.Ldap
+	bindPassword, err := ph.GetLdapBindPassword(ldap)
+	if err != nil {
+		return 0, err
+	}
+	var caCert []byte
+	if ldap.CASecretName != "" {
+		secret, err := ph.GetSecret(ldap.CASecretName)
+		if err != nil {
+			return 0, err
+		}
+		caCert = secret.Data["ca.crt"]
+	}
+	directory, err := ldapsync.Connect(ldap, bindPassword, caCert)
+	if err != nil {
+		return 0, err
+	}
+	defer directory.Close()
+
+	pgHost := util.GetPatroniClusterSettings(cr.Spec.Patroni.ClusterName).PgHost
+	pgC := pgClient.GetPostgresClient(pgHost)
+	if pgC == nil {
+		return 0, fmt.Errorf("postgresql %s is not available", pgHost)
+	}
+	conn, err := pgC.GetConnection()
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Release()
+	return ldapsync.SyncGroups(directory, conn, ldap.GroupSync.Mappings)
+}
+
+func updateLdapGroupSyncStatus(status *qubershipv1.LdapGroupSyncStatus) {
+	if err := helper.GetPatroniHelper().UpdatePatroniCoreStatus(func(crStatus *qubershipv1.PatroniCoreStatus) {
+		crStatus.LdapGroupSync = status
+	}); err != nil {
+		logger.Error("Cannot update LDAP group sync status", zap.Error(err))
+	}
+}
@@ -0,0 +1,564 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package upgrade
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Netcracker/pgskipper-operator-core/pkg/util"
+	v1 "github.com/Netcracker/pgskipper-operator/api/patroni/v1"
+	pgClient "github.com/Netcracker/pgskipper-operator/pkg/client"
+	"github.com/Netcracker/pgskipper-operator/pkg/helper"
+	opUtil "github.com/Netcracker/pgskipper-operator/pkg/util"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// Names of preflight checks reported in status.upgradeCheck
+const (
+	checkClusterHealth          = "ClusterHealth"
+	checkTargetVersion          = "TargetVersion"
+	checkSharedPreloadLibraries = "SharedPreloadLibraries"
+	checkSchemaDump             = "SchemaDump"
+	checkPreparedTransactions   = "PreparedTransactions"
+	checkAbsTimeColumns         = "AbsTimeColumns"
+	checkRegTypeColumns         = "RegTypeColumns"
+	checkUnknownTypeColumns     = "UnknownTypeColumns"
+	checkExtensions             = "Extensions"
+	checkDiskSpace              = "DiskSpace"
+	checkPgUpgrade              = "PgUpgradeCheck"
+)
+
+const (
+	userColumnsQuery = "SELECT n.nspname || '.' || c.relname || '.' || a.attname FROM pg_catalog.pg_attribute a " +
+		"JOIN pg_catalog.pg_class c ON c.oid = a.attrelid JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace " +
+		"WHERE NOT a.attisdropped AND c.relkind IN ('r', 'm', 'p') AND n.nspname NOT IN ('pg_catalog', 'information_schema') "
+	// reg* types reference OIDs, which are not preserved by pg_upgrade, regclass, regrole and regtype are allowed
+	regTypeColumnsQuery = userColumnsQuery + "AND a.atttypid::pg_catalog.regtype::text IN " +
+		"('regcollation', 'regconfig', 'regdictionary', 'regnamespace', 'regoper', 'regoperator', 'regproc', 'regprocedure')"
+	unknownTypeColumnsQuery = userColumnsQuery + "AND a.atttypid = 'pg_catalog.unknown'::pg_catalog.regtype"
+	extensionsQuery         = "SELECT extname, extversion FROM pg_catalog.pg_extension"
+	catalogSizeQuery        = "SELECT coalesce(sum(pg_catalog.pg_total_relation_size(c.oid)), 0)::bigint FROM pg_catalog.pg_class c " +
+		"JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace WHERE n.nspname IN ('pg_catalog', 'information_schema') AND c.relkind = 'r'"
+
+	// newClusterOverhead is a size of empty cluster created by initdb with its WAL
+	newClusterOverhead = int64(256 << 20)
+
+	targetExtensionsCommand = "for f in $(pg_config --sharedir)/extension/*.control; do " +
+		"echo \"$(basename $f .control) $(grep -E '^\\s*default_version' $f | sed -E \"s/.*=\\s*'?([^']*)'?.*/\\1/\")\"; done"
+	availableSpaceCommand = "df -Pk /var/lib/pgsql/data | tail -1 | awk '{print $4}'"
+
+	// pgUpgradeCheckScript restores schema of the cluster into temporary cluster of the current version
+	// and runs pg_upgrade --check against it, so running cluster is not touched
+	pgUpgradeCheckScript = `exec 1>&2
+set -e
+find_bin() {
+  for dir in /usr/lib/postgresql/$1/bin /usr/pgsql-$1/bin /usr/local/pgsql-$1/bin; do
+    if [ -x "$dir/pg_ctl" ]; then echo "$dir"; return 0; fi
+  done
+  echo "binaries of PostgreSQL $1 are not found in upgrade image"
+  return 1
+}
+OLD_BIN=$(find_bin "$OLD_VERSION")
+NEW_BIN=$(find_bin "$NEW_VERSION")
+WORK_DIR=/var/lib/pgsql/data/upgrade-check
+rm -rf $WORK_DIR && mkdir -p $WORK_DIR && cd $WORK_DIR
+$OLD_BIN/pg_dumpall -h "$PGHOST" -U postgres -w --schema-only --file=schema.sql
+$OLD_BIN/initdb -D old -U postgres $INITDB_PARAMS > initdb_old.log
+$OLD_BIN/pg_ctl -D old -o "-c listen_addresses='' -c unix_socket_directories=$WORK_DIR -p 50432" -l old.log -w start
+$OLD_BIN/psql -h $WORK_DIR -p 50432 -U postgres -d postgres -q -f schema.sql > restore.log 2>&1 || true
+echo "Schema restore errors: $(grep -c ERROR restore.log || true)"
+$OLD_BIN/pg_ctl -D old -w stop
+$NEW_BIN/initdb -D new -U postgres $INITDB_PARAMS > initdb_new.log
+$NEW_BIN/pg_upgrade --check -b $OLD_BIN -B $NEW_BIN -d old -D new -U postgres`
+
+	preflightMessageLimit = 1024
+)
+
+// preflightReport collects results of preflight checks into status.upgradeCheck
+type preflightReport struct {
+	status *v1.UpgradeCheckStatus
+}
+
+func (r *preflightReport) add(name string, result string, message string) {
+	logger.Info(fmt.Sprintf("Major upgrade preflight check %s: %s. %s", name, result, message))
+	r.status.Checks = append(r.status.Checks, v1.UpgradeCheck{Name: name, Result: result, Message: truncateMessage(message)})
+}
+
+func (r *preflightReport) addError(name string, err error, passMessage string) {
+	if err != nil {
+		r.add(name, v1.UpgradeCheckFail, err.Error())
+	} else {
+		r.add(name, v1.UpgradeCheckPass, passMessage)
+	}
+}
+
+// result returns the worst result of checks
+func (r *preflightReport) result() string {
+	result := v1.UpgradeCheckPass
+	for _, check := range r.status.Checks {
+		if check.Result == v1.UpgradeCheckFail {
+			return v1.UpgradeCheckFail
+		}
+		if check.Result == v1.UpgradeCheckWarn {
+			result = v1.UpgradeCheckWarn
+		}
+	}
+	return result
+}
+
+// preflightState tracks preflight checks running in background of reconcile
+type preflightState struct {
+	mu      sync.Mutex
+	running bool
+	// started identifies the spec the last checks are started for, the checks are not repeated for it
+	started string
+	// run is replaced in tests
+	run func(cr *v1.PatroniCore, cluster *v1.PatroniClusterSettings) error
+}
+
+// StartPreflightChecks runs preflight checks in background once for each generation of PatroniCore spec,
+// so reconcile of the cluster is not blocked while the report is produced
+func (u *Upgrade) StartPreflightChecks(cr *v1.PatroniCore, cluster *v1.PatroniClusterSettings) {
+	u.checks.mu.Lock()
+	defer u.checks.mu.Unlock()
+	spec := fmt.Sprintf("%s/%d", cr.Spec.Patroni.DockerImage, cr.Generation)
+	if u.checks.running || u.checks.started == spec {
+		return
+	}
+	u.checks.running = true
+	u.checks.started = spec
+	run := u.checks.run
+	if run == nil {
+		run = u.RunPreflightChecks
+	}
+	cr = cr.DeepCopy()
+	go func() {
+		err := run(cr, cluster)
+		u.checks.mu.Lock()
+		defer u.checks.mu.Unlock()
+		u.checks.running = false
+		if err != nil {
+			// report is not stored, checks are repeated on the next reconcile
+			logger.Error("Can't store major upgrade preflight report", zap.Error(err))
+			u.checks.started = ""
+		}
+	}()
+}
+
+// GetRunningImage returns Patroni image of the deployed cluster, it's empty if the cluster is not deployed yet
+func (u *Upgrade) GetRunningImage(cluster *v1.PatroniClusterSettings) (string, error) {
+	statefulsets, err := u.helper.ResourceManager.GetStatefulsetByNameRegExp(fmt.Sprintf("pg-%s-node", cluster.ClusterName))
+	if err != nil {
+		return "", err
+	}
+	if len(statefulsets) == 0 {
+		return "", nil
+	}
+	return statefulsets[0].Spec.Template.Spec.Containers[0].Image, nil
+}
+
+// RunPreflightChecks runs checks of major upgrade to the image of spec.patroni.image without changes of the cluster
+// and stores the report in status.upgradeCheck, failed checks are not returned as error
+func (u *Upgrade) RunPreflightChecks(cr *v1.PatroniCore, cluster *v1.PatroniClusterSettings) error {
+	startTime := metav1.Now()
+	report := &preflightReport{status: &v1.UpgradeCheckStatus{TargetImage: cr.Spec.Patroni.DockerImage, StartTime: &startTime}}
+	u.runPreflightChecks(cr, cluster, report)
+
+	completionTime := metav1.Now()
+	report.status.CompletionTime = &completionTime
+	report.status.Result = report.result()
+	logger.Info(fmt.Sprintf("Major upgrade preflight checks completed with result: %s", report.status.Result))
+	return u.updateUpgradeCheckStatus(report.status)
+}
+
+func (u *Upgrade) runPreflightChecks(cr *v1.PatroniCore, cluster *v1.PatroniClusterSettings, report *preflightReport) {
+	status := report.status
+	masterPod, err := u.helper.ResourceManager.GetPodsByLabel(cluster.PatroniMasterSelectors)
+	if err != nil || len(masterPod.Items) == 0 {
+		report.add(checkClusterHealth, v1.UpgradeCheckFail, "Patroni leader pod is not found")
+		return
+	}
+	masterPodName := masterPod.Items[0].Name
+
+	if config, err := u.helper.GetPatroniClusterConfig(cluster.PatroniUrl); err != nil {
+		report.add(checkClusterHealth, v1.UpgradeCheckFail, fmt.Sprintf("can't get Patroni cluster status: %v", err))
+	} else if !u.helper.IsPatroniClusterHealthy(config) {
+		report.add(checkClusterHealth, v1.UpgradeCheckFail, "Patroni cluster is not healthy enough for upgrade procedure")
+	} else {
+		report.add(checkClusterHealth, v1.UpgradeCheckPass, "all members are running and the leader exists")
+	}
+
+	status.CurrentVersion = u.helper.GetPGVersionFromPod(masterPodName)
+	var targetExtensions map[string]string
+	if targetPod, err := u.RunUpgradePatroniPod(cr, cluster); err != nil {
+		report.add(checkTargetVersion, v1.UpgradeCheckFail, fmt.Sprintf("can't run pod with target image: %v", err))
+	} else {
+		status.TargetVersion = u.helper.GetPGVersionFromPod(targetPod.Name)
+		targetExtensions = u.getTargetExtensions(targetPod.Name)
+		if err := u.helper.ResourceManager.DeletePod(targetPod); err != nil {
+			logger.Warn("Can't delete pg-upgrade-check-pod", zap.Error(err))
+		}
+		result, message := compareVersions(status.CurrentVersion, status.TargetVersion)
+		report.add(checkTargetVersion, result, message)
+	}
+
+	report.addError(checkSharedPreloadLibraries, u.checkSharedPreloadLibraries(masterPodName), "shared_preload_libraries is set")
+	report.addError(checkSchemaDump, u.checkSchemaDump(masterPodName), "schema of all databases is dumped")
+	report.addError(checkPreparedTransactions, u.CheckForPreparedTransactions(cluster.PgHost), "there are no prepared transactions")
+	report.addError(checkAbsTimeColumns, u.CheckForAbsTimeUsage(cluster.PgHost), "abstime data type is not used")
+
+	pgC := pgClient.GetPostgresClient(cluster.PgHost)
+	databases := helper.GetAllDatabases(pgC)
+	for _, check := range []struct{ name, query, description string }{
+		{checkRegTypeColumns, regTypeColumnsQuery, "reg* data types referencing OIDs"},
+		{checkUnknownTypeColumns, unknownTypeColumnsQuery, "unknown data type"},
+	} {
+		columns, err := findColumns(pgC, databases, check.query)
+		switch {
+		case err != nil:
+			report.add(check.name, v1.UpgradeCheckFail, fmt.Sprintf("can't check columns: %v", err))
+		case len(columns) > 0:
+			report.add(check.name, v1.UpgradeCheckFail, fmt.Sprintf("columns with %s are not supported by pg_upgrade: %s",
+				check.description, strings.Join(columns, ", ")))
+		default:
+			report.add(check.name, v1.UpgradeCheckPass, fmt.Sprintf("there are no columns with %s", check.description))
+		}
+	}
+
+	result, message := checkExtensionsAvailability(pgC, databases, targetExtensions)
+	report.add(checkExtensions, result, message)
+	result, message = u.checkDiskSpace(masterPodName, pgC, databases)
+	report.add(checkDiskSpace, result, message)
+
+	if status.CurrentVersion == "" || status.TargetVersion == "" || status.CurrentVersion == status.TargetVersion {
+		report.add(checkPgUpgrade, v1.UpgradeCheckWarn, "pg_upgrade --check is skipped, versions of the upgrade are unknown or equal")
+	} else {
+		result, message = u.runPgUpgradeCheck(cr, cluster, status.CurrentVersion, status.TargetVersion)
+		report.add(checkPgUpgrade, result, message)
+	}
+}
+
+func compareVersions(current string, target string) (string, string) {
+	currentVersion, currentErr := strconv.Atoi(current)
+	targetVersion, targetErr := strconv.Atoi(target)
+	switch {
+	case currentErr != nil || targetErr != nil:
+		return v1.UpgradeCheckFail, fmt.Sprintf("can't compare current version %q with target version %q", current, target)
+	case targetVersion < currentVersion:
+		return v1.UpgradeCheckFail, fmt.Sprintf("downgrade from %d to %d is not supported", currentVersion, targetVersion)
+	case targetVersion == currentVersion:
+		return v1.UpgradeCheckWarn, fmt.Sprintf("target image has the current major version %d, upgrade is not required", currentVersion)
+	default:
+		return v1.UpgradeCheckPass, fmt.Sprintf("upgrade from %d to %d", currentVersion, targetVersion)
+	}
+}
+
+// getTargetExtensions returns default versions of extensions available in target image
+func (u *Upgrade) getTargetExtensions(podName string) map[string]string {
+	output, errMsg, err := u.helper.ExecCmdOnPatroniPod(podName, namespace, targetExtensionsCommand)
+	if err != nil {
+		logger.Warn(fmt.Sprintf("Can't get extensions of target image. errMsg: %s", errMsg), zap.Error(err))
+		return nil
+	}
+	extensions := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		extensions[fields[0]] = ""
+		if len(fields) > 1 {
+			extensions[fields[0]] = fields[1]
+		}
+	}
+	return extensions
+}
+
+// findColumns runs the query in all databases and returns found columns prefixed with database name
+func findColumns(pgC *pgClient.PostgresClient, databases []string, query string) ([]string, error) {
+	var columns []string
+	for _, db := range databases {
+		dbColumns, err := queryStrings(pgC, db, query)
+		if err != nil {
+			return nil, fmt.Errorf("database %s: %w", db, err)
+		}
+		for _, column := range dbColumns {
+			columns = append(columns, fmt.Sprintf("%s.%s", db, column))
+		}
+	}
+	return columns, nil
+}
+
+func queryStrings(pgC *pgClient.PostgresClient, db string, query string) ([]string, error) {
+	conn, err := pgC.GetConnectionToDb(db)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close(context.Background())
+
+	rows, err := conn.Query(context.Background(), query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var result []string
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		result = append(result, value)
+	}
+	return result, rows.Err()
+}
+
+// checkExtensionsAvailability fails if installed extension is absent in target image
+// and warns if it has to be updated after the upgrade
+func checkExtensionsAvailability(pgC *pgClient.PostgresClient, databases []string, targetExtensions map[string]string) (string, string) {
+	if targetExtensions == nil {
+		return v1.UpgradeCheckFail, "can't get extensions available in target image"
+	}
+	installed := make(map[string]map[string]string)
+	for _, db := range databases {
+		extensions, err := getInstalledExtensions(pgC, db)
+		if err != nil {
+			return v1.UpgradeCheckFail, fmt.Sprintf("can't get extensions of database %s: %v", db, err)
+		}
+		installed[db] = extensions
+	}
+	return compareExtensions(installed, targetExtensions)
+}
+
+// compareExtensions checks extensions installed in databases against default versions of target image
+func compareExtensions(installed map[string]map[string]string, targetExtensions map[string]string) (string, string) {
+	var missing, outdated []string
+	for db, extensions := range installed {
+		for name, version := range extensions {
+			targetVersion, ok := targetExtensions[name]
+			if !ok {
+				missing = append(missing, fmt.Sprintf("%s.%s", db, name))
+			} else if targetVersion != "" && targetVersion != version {
+				outdated = append(outdated, fmt.Sprintf("%s.%s (%s -> %s)", db, name, version, targetVersion))
+			}
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(outdated)
+	switch {
+	case len(missing) > 0:
+		return v1.UpgradeCheckFail, fmt.Sprintf("extensions are not available in target image: %s", strings.Join(missing, ", "))
+	case len(outdated) > 0:
+		return v1.UpgradeCheckWarn, fmt.Sprintf("extensions have to be updated after upgrade with update_extensions.sql: %s",
+			strings.Join(outdated, ", "))
+	default:
+		return v1.UpgradeCheckPass, "all installed extensions are available in target image"
+	}
+}
+
+func getInstalledExtensions(pgC *pgClient.PostgresClient, db string) (map[string]string, error) {
+	conn, err := pgC.GetConnectionToDb(db)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close(context.Background())
+
+	rows, err := conn.Query(context.Background(), extensionsQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	extensions := make(map[string]string)
+	for rows.Next() {
+		var name, version string
+		if err := rows.Scan(&name, &version); err != nil {
+			return nil, err
+		}
+		extensions[name] = version
+	}
+	return extensions, rows.Err()
+}
+
+// checkDiskSpace estimates space required by the upgrade in link mode: catalogs are copied
+// into the new cluster, while data files are linked
+func (u *Upgrade) checkDiskSpace(masterPodName string, pgC *pgClient.PostgresClient, databases []string) (string, string) {
+	output, errMsg, err := u.helper.ExecCmdOnPatroniPod(masterPodName, namespace, availableSpaceCommand)
+	if err != nil {
+		return v1.UpgradeCheckFail, fmt.Sprintf("can't get available space of data volume: %s", errMsg)
+	}
+	availableKb, err := strconv.ParseInt(strings.TrimSpace(output), 10, 64)
+	if err != nil {
+		return v1.UpgradeCheckFail, fmt.Sprintf("can't parse available space of data volume %q", output)
+	}
+	available := availableKb << 10
+
+	required := newClusterOverhead
+	for _, db := range databases {
+		conn, err := pgC.GetConnectionToDb(db)
+		if err != nil {
+			return v1.UpgradeCheckFail, fmt.Sprintf("can't connect to database %s: %v", db, err)
+		}
+		var catalogSize int64
+		err = conn.QueryRow(context.Background(), catalogSizeQuery).Scan(&catalogSize)
+		_ = conn.Close(context.Background())
+		if err != nil {
+			return v1.UpgradeCheckFail, fmt.Sprintf("can't get catalog size of database %s: %v", db, err)
+		}
+		required += catalogSize
+	}
+
+	message := fmt.Sprintf("estimated space required: %d MiB, available: %d MiB", required>>20, available>>20)
+	switch {
+	case available < required:
+		return v1.UpgradeCheckFail, message
+	case available < 2*required:
+		return v1.UpgradeCheckWarn, message
+	default:
+		return v1.UpgradeCheckPass, message
+	}
+}
+
+// runPgUpgradeCheck runs pg_upgrade --check in a temporary pod with the upgrade image
+func (u *Upgrade) runPgUpgradeCheck(cr *v1.PatroniCore, cluster *v1.PatroniClusterSettings, currentVersion string, targetVersion string) (string, string) {
+	initDbArgs, err := u.decideInitDbArgs(cr, cluster)
+	if err != nil {
+		return v1.UpgradeCheckFail, fmt.Sprintf("can't get initdb params: %v", err)
+	}
+	pod := u.getPgUpgradeCheckPod(cr, cluster, initDbArgs, currentVersion, targetVersion)
+	if err := u.helper.ResourceManager.CreatePod(pod); err != nil {
+		return v1.UpgradeCheckFail, fmt.Sprintf("can't create pod with upgrade image: %v", err)
+	}
+	defer func() {
+		if err := u.helper.ResourceManager.DeletePod(pod); err != nil {
+			logger.Warn(fmt.Sprintf("Can't delete %s pod", pod.Name), zap.Error(err))
+		}
+	}()
+	if err := wait.PollUntilContextTimeout(context.Background(), 5*time.Second, 10*time.Minute, true, func(ctx context.Context) (bool, error) {
+		state, err := opUtil.GetPodPhase(pod)
+		return state == string(corev1.PodRunning), err
+	}); err != nil {
+		return v1.UpgradeCheckFail, fmt.Sprintf("pod with upgrade image is not running: %v", err)
+	}
+
+	// all output of the script is written to stderr, it's returned by exec both on success and failure
+	_, output, err := u.helper.ExecCmdOnPod(pod.Name, namespace, pod.Spec.Containers[0].Name, pgUpgradeCheckScript)
+	if err != nil {
+		return v1.UpgradeCheckFail, lastLines(output)
+	}
+	return v1.UpgradeCheckPass, lastLines(output)
+}
+
+func (u *Upgrade) getPgUpgradeCheckPod(cr *v1.PatroniCore, cluster *v1.PatroniClusterSettings, initDbArgs string,
+	currentVersion string, targetVersion string) *corev1.Pod {
+	patroniSpec := cr.Spec.Patroni
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pg-major-upgrade-dry-run-" + strconv.Itoa(int(time.Now().Unix())),
+			Labels:    patroniSpec.PodLabels,
+			Namespace: util.GetNameSpace(),
+		},
+		Spec: corev1.PodSpec{
+			RestartPolicy:   corev1.RestartPolicyNever,
+			SecurityContext: patroniSpec.SecurityContext,
+			Containers: []corev1.Container{
+				{
+					Name:            "pg-upgrade-dry-run",
+					Image:           cr.Upgrade.DockerUpgradeImage,
+					SecurityContext: opUtil.GetDefaultSecurityContext(),
+					ImagePullPolicy: "IfNotPresent",
+					Command:         []string{"sleep", "infinity"},
+					VolumeMounts: []corev1.VolumeMount{
+						{
+							MountPath: "/var/lib/pgsql/data",
+							Name:      "data",
+						},
+					},
+					Env: []corev1.EnvVar{
+						{
+							Name:  "OLD_VERSION",
+							Value: currentVersion,
+						},
+						{
+							Name:  "NEW_VERSION",
+							Value: targetVersion,
+						},
+						{
+							Name:  "INITDB_PARAMS",
+							Value: initDbArgs,
+						},
+						{
+							Name:  "PGHOST",
+							Value: cluster.PgHost,
+						},
+						{
+							Name: "PGPASSWORD",
+							ValueFrom: &corev1.EnvVarSource{
+								SecretKeyRef: &corev1.SecretKeySelector{
+									LocalObjectReference: corev1.LocalObjectReference{Name: "postgres-credentials"},
+									Key:                  "password",
+								},
+							},
+						},
+					},
+				},
+			},
+			Volumes: []corev1.Volume{
+				{
+					Name:         "data",
+					VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+				},
+			},
+		},
+	}
+	if patroniSpec.Resources != nil {
+		pod.Spec.Containers[0].Resources = *patroniSpec.Resources
+	}
+	if cr.Spec.PrivateRegistry.Enabled {
+		for _, name := range cr.Spec.PrivateRegistry.Names {
+			pod.Spec.ImagePullSecrets = append(pod.Spec.ImagePullSecrets, corev1.LocalObjectReference{Name: name})
+		}
+	}
+	return pod
+}
+
+func (u *Upgrade) updateUpgradeCheckStatus(status *v1.UpgradeCheckStatus) error {
+	return u.helper.UpdatePatroniCoreStatus(func(crStatus *v1.PatroniCoreStatus) {
+		crStatus.UpgradeCheck = status
+	})
+}
+
+// lastLines keeps the end of command output, where the reason of failure is printed
+func lastLines(output string) string {
+	output = strings.TrimSpace(output)
+	if len(output) > preflightMessageLimit {
+		output = "..." + output[len(output)-preflightMessageLimit:]
+	}
+	return output
+}
+
+func truncateMessage(message string) string {
+	if len(message) > preflightMessageLimit {
+		return message[:preflightMessageLimit] + "..."
+	}
+	return message
+}
@@ -0,0 +1,291 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package upgrade
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "github.com/Netcracker/pgskipper-operator/api/patroni/v1"
+	"github.com/Netcracker/pgskipper-operator/pkg/helper"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		current, target string
+		result          string
+		message         string
+	}{
+		{current: "15", target: "16", result: v1.UpgradeCheckPass, message: "upgrade from 15 to 16"},
+		{current: "13", target: "17", result: v1.UpgradeCheckPass, message: "upgrade from 13 to 17"},
+		{current: "16", target: "16", result: v1.UpgradeCheckWarn, message: "upgrade is not required"},
+		{current: "16", target: "15", result: v1.UpgradeCheckFail, message: "downgrade from 16 to 15"},
+		{current: "9", target: "10", result: v1.UpgradeCheckPass, message: "upgrade from 9 to 10"},
+		{current: "", target: "16", result: v1.UpgradeCheckFail, message: "can't compare"},
+		{current: "15", target: "16beta1", result: v1.UpgradeCheckFail, message: "can't compare"},
+	}
+	for _, tt := range tests {
+		result, message := compareVersions(tt.current, tt.target)
+		if result != tt.result || !strings.Contains(message, tt.message) {
+			t.Errorf("compareVersions(%q, %q) = %s, %q, want %s, %q", tt.current, tt.target, result, message, tt.result, tt.message)
+		}
+	}
+}
+
+func TestCheckExtensionsAvailability(t *testing.T) {
+	target := map[string]string{"pg_stat_statements": "1.10", "plpgsql": "1.0", "pgcrypto": "1.3", "custom": ""}
+	tests := []struct {
+		name      string
+		installed map[string]map[string]string
+		target    map[string]string
+		result    string
+		message   string
+	}{
+		{
+			name:      "all available",
+			installed: map[string]map[string]string{"postgres": {"plpgsql": "1.0"}, "app": {"plpgsql": "1.0", "pgcrypto": "1.3"}},
+			target:    target,
+			result:    v1.UpgradeCheckPass,
+		},
+		{
+			name:      "no databases",
+			installed: map[string]map[string]string{},
+			target:    target,
+			result:    v1.UpgradeCheckPass,
+		},
+		{
+			name:      "unknown target version",
+			installed: map[string]map[string]string{"app": {"custom": "2.0"}},
+			target:    target,
+			result:    v1.UpgradeCheckPass,
+		},
+		{
+			name:      "outdated",
+			installed: map[string]map[string]string{"app": {"pg_stat_statements": "1.9", "plpgsql": "1.0"}, "db2": {"pgcrypto": "1.2"}},
+			target:    target,
+			result:    v1.UpgradeCheckWarn,
+			message:   "app.pg_stat_statements (1.9 -> 1.10), db2.pgcrypto (1.2 -> 1.3)",
+		},
+		{
+			name: "missing",
+			installed: map[string]map[string]string{
+				"db2": {"postgis": "3.4", "pg_stat_statements": "1.9"}, "app": {"timescaledb": "2.14", "plpgsql": "1.0"},
+			},
+			target:  target,
+			result:  v1.UpgradeCheckFail,
+			message: "app.timescaledb, db2.postgis",
+		},
+		{
+			name:      "target extensions are unknown",
+			installed: map[string]map[string]string{"app": {"plpgsql": "1.0"}},
+			result:    v1.UpgradeCheckFail,
+			message:   "can't get extensions",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var result, message string
+			if tt.target == nil {
+				result, message = checkExtensionsAvailability(nil, []string{"app"}, nil)
+			} else {
+				result, message = compareExtensions(tt.installed, tt.target)
+			}
+			if result != tt.result || !strings.Contains(message, tt.message) {
+				t.Errorf("result is %s, %q, want %s, %q", result, message, tt.result, tt.message)
+			}
+		})
+	}
+}
+
+func TestPreflightMessages(t *testing.T) {
+	long := strings.Repeat("a", preflightMessageLimit) + "end"
+	tests := []struct {
+		name   string
+		format func(string) string
+		input  string
+		output string
+	}{
+		{name: "short message", format: truncateMessage, input: "message", output: "message"},
+		{name: "message of limit", format: truncateMessage, input: long[:preflightMessageLimit], output: long[:preflightMessageLimit]},
+		{name: "long message", format: truncateMessage, input: long, output: long[:preflightMessageLimit] + "..."},
+		{name: "short output", format: lastLines, input: "\n*Clusters are compatible*\n", output: "*Clusters are compatible*"},
+		{name: "long output", format: lastLines, input: "start" + long + "\n", output: "..." + long[len(long)-preflightMessageLimit:]},
+	}
+	for _, tt := range tests {
+		if output := tt.format(tt.input); output != tt.output {
+			t.Errorf("%s: output is %q, want %q", tt.name, output, tt.output)
+		}
+	}
+}
+
+func TestPreflightReport(t *testing.T) {
+	tests := []struct {
+		name    string
+		results []string
+		result  string
+	}{
+		{name: "no checks", result: v1.UpgradeCheckPass},
+		{name: "passed", results: []string{v1.UpgradeCheckPass, v1.UpgradeCheckPass}, result: v1.UpgradeCheckPass},
+		{name: "warning", results: []string{v1.UpgradeCheckPass, v1.UpgradeCheckWarn, v1.UpgradeCheckPass}, result: v1.UpgradeCheckWarn},
+		{name: "failure after warning", results: []string{v1.UpgradeCheckWarn, v1.UpgradeCheckFail}, result: v1.UpgradeCheckFail},
+		{name: "warning after failure", results: []string{v1.UpgradeCheckFail, v1.UpgradeCheckWarn}, result: v1.UpgradeCheckFail},
+	}
+	names := []string{checkClusterHealth, checkTargetVersion, checkSharedPreloadLibraries}
+	for _, tt := range tests {
+		report := &preflightReport{status: &v1.UpgradeCheckStatus{}}
+		for i, result := range tt.results {
+			report.add(names[i], result, "message")
+		}
+		if result := report.result(); result != tt.result {
+			t.Errorf("%s: result is %s, want %s", tt.name, result, tt.result)
+		}
+	}
+
+	report := &preflightReport{status: &v1.UpgradeCheckStatus{}}
+	report.addError(checkPreparedTransactions, nil, "there are no prepared transactions")
+	report.addError(checkAbsTimeColumns, errors.New(strings.Repeat("x", 2*preflightMessageLimit)), "abstime data type is not used")
+	checks := report.status.Checks
+	if len(checks) != 2 {
+		t.Fatalf("report has %d checks, want 2", len(checks))
+	}
+	if checks[0] != (v1.UpgradeCheck{Name: checkPreparedTransactions, Result: v1.UpgradeCheckPass, Message: "there are no prepared transactions"}) {
+		t.Errorf("passed check is %+v", checks[0])
+	}
+	if checks[1].Name != checkAbsTimeColumns || checks[1].Result != v1.UpgradeCheckFail || len(checks[1].Message) != preflightMessageLimit+3 {
+		t.Errorf("failed check is %s %s with message of %d bytes", checks[1].Name, checks[1].Result, len(checks[1].Message))
+	}
+	if report.result() != v1.UpgradeCheckFail {
+		t.Errorf("result is %s, want %s", report.result(), v1.UpgradeCheckFail)
+	}
+}
+
+func TestStartPreflightChecks(t *testing.T) {
+	started := make(chan string, 10)
+	release := make(chan error)
+	u := &Upgrade{}
+	u.checks.run = func(cr *v1.PatroniCore, cluster *v1.PatroniClusterSettings) error {
+		started <- cr.Spec.Patroni.DockerImage
+		return <-release
+	}
+	cr := &v1.PatroniCore{
+		ObjectMeta: metav1.ObjectMeta{Generation: 1},
+		Spec:       &v1.PatroniCoreSpec{Patroni: &v1.Patroni{DockerImage: "patroni:16"}},
+	}
+	expectStarted := func(image string) {
+		t.Helper()
+		select {
+		case started := <-started:
+			if started != image {
+				t.Fatalf("checks are started for %s, want %s", started, image)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("checks are not started for %s", image)
+		}
+	}
+	expectNotStarted := func() {
+		t.Helper()
+		select {
+		case image := <-started:
+			t.Fatalf("checks are started again for %s", image)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	waitFinished := func() {
+		t.Helper()
+		for i := 0; i < 100; i++ {
+			u.checks.mu.Lock()
+			running := u.checks.running
+			u.checks.mu.Unlock()
+			if !running {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatal("checks are not finished")
+	}
+
+	u.StartPreflightChecks(cr, &v1.PatroniClusterSettings{})
+	expectStarted("patroni:16")
+	// reconcile is not blocked, running checks are not started twice
+	u.StartPreflightChecks(cr, &v1.PatroniClusterSettings{})
+	expectNotStarted()
+	release <- nil
+	waitFinished()
+
+	// the report of the spec is ready
+	u.StartPreflightChecks(cr, &v1.PatroniClusterSettings{})
+	expectNotStarted()
+
+	// changed spec is checked again
+	cr.Generation = 2
+	u.StartPreflightChecks(cr, &v1.PatroniClusterSettings{})
+	expectStarted("patroni:16")
+	release <- errors.New("conflict")
+	waitFinished()
+
+	// report is not stored, checks are repeated
+	u.StartPreflightChecks(cr, &v1.PatroniClusterSettings{})
+	expectStarted("patroni:16")
+	release <- nil
+	waitFinished()
+
+	cr.Spec.Patroni.DockerImage = "patroni:17"
+	u.StartPreflightChecks(cr, &v1.PatroniClusterSettings{})
+	expectStarted("patroni:17")
+	release <- nil
+	waitFinished()
+}
+
+func TestGetRunningImage(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	statefulset := func(name string, image string) *appsv1.StatefulSet {
+		return &appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Spec: appsv1.StatefulSetSpec{
+				Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: name, Image: image}}}},
+			},
+		}
+	}
+	cluster := &v1.PatroniClusterSettings{ClusterName: "patroni"}
+
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+	u := New(kubeClient, helper.NewPatroniHelper(kubeClient))
+	image, err := u.GetRunningImage(cluster)
+	if err != nil || image != "" {
+		t.Errorf("image of not deployed cluster is %q, %v", image, err)
+	}
+
+	kubeClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		statefulset("pg-other-node1", "other:14"),
+		statefulset("pg-patroni-node1", "patroni:15"),
+		statefulset("pg-patroni-node2", "patroni:15"),
+	).Build()
+	u = New(kubeClient, helper.NewPatroniHelper(kubeClient))
+	image, err = u.GetRunningImage(cluster)
+	if err != nil || image != "patroni:15" {
+		t.Errorf("image of running cluster is %q, %v, want patroni:15", image, err)
+	}
+}
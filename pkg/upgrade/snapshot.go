@@ -0,0 +1,338 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package upgrade
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Netcracker/pgskipper-operator-core/pkg/storage"
+	v1 "github.com/Netcracker/pgskipper-operator/api/patroni/v1"
+	"github.com/Netcracker/pgskipper-operator/pkg/deployment"
+	opUtil "github.com/Netcracker/pgskipper-operator/pkg/util"
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/utils/ptr"
+)
+
+const (
+	snapshotReadyTimeout = 30 * time.Minute
+	pvcDeleteTimeout     = 5 * time.Minute
+)
+
+var snapshotPollInterval = 5 * time.Second
+
+// createRollbackPoint takes snapshots of the leader volumes, the cluster has to be stopped.
+// Snapshots of the previous rollback point are deleted when new ones are ready.
+func (u *Upgrade) createRollbackPoint(cr *v1.PatroniCore, cluster *v1.PatroniClusterSettings, leaderName string, leaderIdx int, pgVersion string) error {
+	oldImage, err := u.getStatefulsetImage(leaderName)
+	if err != nil {
+		return err
+	}
+	pvcNames := []string{fmt.Sprintf("%s-data-%v", cluster.ClusterName, leaderIdx)}
+	if cr.Spec.Patroni.PgWalStorage != nil {
+		pvcNames = append(pvcNames, fmt.Sprintf("%s-wals-data-%v", cluster.ClusterName, leaderIdx))
+	}
+
+	suffix := time.Now().Unix()
+	var refs []v1.VolumeSnapshotRef
+	for _, pvcName := range pvcNames {
+		ref := v1.VolumeSnapshotRef{
+			PersistentVolumeClaim: pvcName,
+			VolumeSnapshot:        fmt.Sprintf("%s-upgrade-%d", pvcName, suffix),
+		}
+		if err := u.createVolumeSnapshot(ref, cr.Upgrade.Snapshot.VolumeSnapshotClassName, cluster.PatroniLabels); err != nil {
+			return err
+		}
+		refs = append(refs, ref)
+	}
+	for _, ref := range refs {
+		if err := u.waitForVolumeSnapshot(ref.VolumeSnapshot); err != nil {
+			return err
+		}
+	}
+
+	var previous []v1.VolumeSnapshotRef
+	if cr.Status.UpgradeSnapshot != nil {
+		previous = cr.Status.UpgradeSnapshot.Snapshots
+	}
+	if err := u.updateUpgradeSnapshotStatus(&v1.UpgradeSnapshotStatus{
+		Phase:        v1.UpgradeSnapshotReady,
+		Image:        oldImage,
+		Version:      pgVersion,
+		Leader:       leaderName,
+		Snapshots:    refs,
+		CreationTime: &metav1.Time{Time: time.Now()},
+	}); err != nil {
+		return err
+	}
+	for _, ref := range previous {
+		u.deleteVolumeSnapshot(ref.VolumeSnapshot)
+	}
+	logger.Info(fmt.Sprintf("Rollback point for major upgrade is created: %v", refs))
+	return nil
+}
+
+// RollbackUpgrade recreates the leader volumes from snapshots of the last rollback point
+// and starts the cluster on the image used before the upgrade. Replicas are reinitialized from the leader.
+func (u *Upgrade) RollbackUpgrade(cr *v1.PatroniCore, cluster *v1.PatroniClusterSettings) error {
+	status := cr.Status.UpgradeSnapshot
+	if status == nil || len(status.Snapshots) == 0 || status.Image == "" {
+		return errors.New("there is no rollback point for major upgrade, check status.upgradeSnapshot of PatroniCore")
+	}
+	// volumes already restored by the interrupted rollback are kept
+	resume := status.Phase == v1.UpgradeSnapshotRollingBack || status.Phase == v1.UpgradeSnapshotRollbackFailed
+	err := u.rollbackToSnapshots(cr, cluster, status, resume)
+	if err == nil {
+		err = u.completeRollback(status.Image)
+	}
+	if err != nil {
+		logger.Error("Major upgrade rollback failed", zap.Error(err))
+		status.Phase = v1.UpgradeSnapshotRollbackFailed
+		status.Message = err.Error()
+		if err := u.updateUpgradeSnapshotStatus(status); err != nil {
+			logger.Error("Can't update major upgrade rollback status", zap.Error(err))
+		}
+		return err
+	}
+	status.Phase = v1.UpgradeSnapshotRolledBack
+	return u.updateUpgradeSnapshotStatus(status)
+}
+
+func (u *Upgrade) rollbackToSnapshots(cr *v1.PatroniCore, cluster *v1.PatroniClusterSettings, status *v1.UpgradeSnapshotStatus, resume bool) error {
+	logger.Info(fmt.Sprintf("Rolling back major upgrade to image %s from snapshots %v", status.Image, status.Snapshots))
+	status.Phase = v1.UpgradeSnapshotRollingBack
+	status.Message = ""
+	if err := u.updateUpgradeSnapshotStatus(status); err != nil {
+		return err
+	}
+
+	if err := u.ScalePowaDeployment(0); err != nil {
+		return err
+	}
+	if err := u.helper.ResourceManager.DeletePodsByLabel(powaUILabels); err != nil {
+		return err
+	}
+	patroniPods, err := u.helper.ResourceManager.GetNamespacePodListBySelectors(cluster.PatroniCommonLabels)
+	if err != nil {
+		return err
+	}
+	if err = u.helper.UpdatePatroniReplicas(0, cluster.ClusterName); err != nil {
+		return err
+	}
+	for _, patroniPod := range patroniPods.Items {
+		if err = opUtil.WaitDeletePod(&patroniPod); err != nil {
+			logger.Error("waiting for Patroni deployment delete failed", zap.Error(err))
+			return err
+		}
+	}
+
+	leaderIdx, err := opUtil.GetPatroniNodeIdx(status.Leader)
+	if err != nil {
+		return err
+	}
+	for _, ref := range status.Snapshots {
+		if err := u.restorePvcFromSnapshot(ref, newLeaderPvc(cr, cluster.ClusterName, leaderIdx, ref.PersistentVolumeClaim), resume); err != nil {
+			return err
+		}
+	}
+
+	// restored data has system identifier of the cluster before the upgrade
+	if err := u.CleanInitializeKey(cluster.ClusterName); err != nil {
+		return err
+	}
+
+	oldCr := cr.DeepCopy()
+	oldCr.Spec.Patroni.DockerImage = status.Image
+	patroniDeployment := deployment.NewPatroniStatefulset(oldCr, leaderIdx, cluster.ClusterName,
+		cluster.PatroniTemplate, cluster.PostgreSQLUserConf, cluster.PatroniLabels)
+	if err := u.helper.ResourceManager.CreateOrUpdateStatefulset(patroniDeployment, true); err != nil {
+		logger.Error("Can't update Patroni deployment", zap.Error(err))
+		return err
+	}
+	if err := opUtil.WaitForLeader(cluster.PatroniMasterSelectors); err != nil {
+		return err
+	}
+	if err := u.ApplyCleanerInitContainer(status.Leader, oldCr.Spec.Patroni, cluster); err != nil {
+		return err
+	}
+	if err := opUtil.WaitForPatroni(oldCr, cluster.PatroniMasterSelectors, cluster.PatroniReplicasSelector); err != nil {
+		return err
+	}
+
+	masterPod, err := u.helper.ResourceManager.GetPodsByLabel(cluster.PatroniMasterSelectors)
+	if err == nil && len(masterPod.Items) > 0 {
+		u.helper.StoreDataToCM("pg-version", u.helper.GetPGVersionFromPod(masterPod.Items[0].Name))
+	} else {
+		logger.Info("Can not get master pod")
+	}
+	return u.ScalePowaDeployment(1)
+}
+
+// completeRollback switches PatroniCore to the image used before the upgrade,
+// otherwise the next reconcile detects version mismatch and starts the upgrade again
+func (u *Upgrade) completeRollback(image string) error {
+	return wait.PollUntilContextTimeout(context.Background(), time.Second, 1*time.Minute, true, func(ctx context.Context) (done bool, err error) {
+		cr, err := u.helper.GetPatroniCoreCR()
+		if err != nil {
+			return false, nil
+		}
+		cr.Spec.Patroni.DockerImage = image
+		cr.Upgrade.Rollback = false
+		cr.Upgrade.Enabled = false
+		if err := u.helper.UpdatePatroniCore(cr); err != nil {
+			logger.Error("Can't disable major upgrade rollback, retrying", zap.Error(err))
+			return false, nil
+		}
+		logger.Info(fmt.Sprintf("Major upgrade is rolled back, Patroni image is set to %s", image))
+		return true, nil
+	})
+}
+
+func (u *Upgrade) getStatefulsetImage(name string) (string, error) {
+	statefulsets, err := u.helper.ResourceManager.GetStatefulsetByNameRegExp(name)
+	if err != nil {
+		return "", err
+	}
+	for _, statefulset := range statefulsets {
+		if statefulset.Name == name {
+			return statefulset.Spec.Template.Spec.Containers[0].Image, nil
+		}
+	}
+	return "", fmt.Errorf("statefulset %s is not found", name)
+}
+
+func (u *Upgrade) createVolumeSnapshot(ref v1.VolumeSnapshotRef, className string, labels map[string]string) error {
+	snapshot := &snapshotv1.VolumeSnapshot{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ref.VolumeSnapshot,
+			Namespace: namespace,
+			Labels:    labels,
+		},
+		Spec: snapshotv1.VolumeSnapshotSpec{
+			Source: snapshotv1.VolumeSnapshotSource{PersistentVolumeClaimName: ptr.To(ref.PersistentVolumeClaim)},
+		},
+	}
+	if className != "" {
+		snapshot.Spec.VolumeSnapshotClassName = ptr.To(className)
+	}
+	logger.Info(fmt.Sprintf("Creating VolumeSnapshot %s of %s", ref.VolumeSnapshot, ref.PersistentVolumeClaim))
+	if err := u.client.Create(context.TODO(), snapshot); err != nil {
+		logger.Error(fmt.Sprintf("Can't create VolumeSnapshot %s", ref.VolumeSnapshot), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (u *Upgrade) waitForVolumeSnapshot(name string) error {
+	return wait.PollUntilContextTimeout(context.Background(), snapshotPollInterval, snapshotReadyTimeout, true, func(ctx context.Context) (done bool, err error) {
+		snapshot := &snapshotv1.VolumeSnapshot{}
+		if err := u.client.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, snapshot); err != nil {
+			logger.Error(fmt.Sprintf("Can't get VolumeSnapshot %s, retrying", name), zap.Error(err))
+			return false, nil
+		}
+		if snapshot.Status == nil {
+			return false, nil
+		}
+		if snapshot.Status.Error != nil && snapshot.Status.Error.Message != nil {
+			return false, fmt.Errorf("VolumeSnapshot %s failed: %s", name, *snapshot.Status.Error.Message)
+		}
+		return ptr.Deref(snapshot.Status.ReadyToUse, false), nil
+	})
+}
+
+func (u *Upgrade) deleteVolumeSnapshot(name string) {
+	snapshot := &snapshotv1.VolumeSnapshot{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}}
+	if err := u.client.Delete(context.TODO(), snapshot); err != nil && !k8serrors.IsNotFound(err) {
+		logger.Warn(fmt.Sprintf("Can't delete VolumeSnapshot %s of previous rollback point", name), zap.Error(err))
+	}
+}
+
+// newLeaderPvc returns PVC of the leader as it's created by reconcile
+func newLeaderPvc(cr *v1.PatroniCore, clusterName string, leaderIdx int, pvcName string) *corev1.PersistentVolumeClaim {
+	storageEntity := cr.Spec.Patroni.Storage
+	if pvcName == fmt.Sprintf("%s-wals-data-%v", clusterName, leaderIdx) {
+		storageEntity = cr.Spec.Patroni.PgWalStorage
+	}
+	return storage.NewPvc(pvcName, storageEntity, leaderIdx)
+}
+
+// restorePvcFromSnapshot recreates PVC with the same spec and VolumeSnapshot as a data source,
+// PVC restored from the same snapshot is skipped only when the interrupted rollback is resumed.
+// PVC deleted by the interrupted rollback is created from the spec of defaultPvc.
+func (u *Upgrade) restorePvcFromSnapshot(ref v1.VolumeSnapshotRef, defaultPvc *corev1.PersistentVolumeClaim, resume bool) error {
+	pvc := &corev1.PersistentVolumeClaim{}
+	if err := u.client.Get(context.TODO(), types.NamespacedName{Name: ref.PersistentVolumeClaim, Namespace: namespace}, pvc); err != nil {
+		if !resume || !k8serrors.IsNotFound(err) {
+			logger.Error(fmt.Sprintf("Can't get PVC %s for restore", ref.PersistentVolumeClaim), zap.Error(err))
+			return err
+		}
+		logger.Info(fmt.Sprintf("PVC %s is deleted by the interrupted rollback, it's created from the spec", ref.PersistentVolumeClaim))
+		pvc = defaultPvc
+	}
+	if resume && pvc.Spec.DataSource != nil && pvc.Spec.DataSource.Name == ref.VolumeSnapshot {
+		logger.Info(fmt.Sprintf("PVC %s is already restored from %s", pvc.Name, ref.VolumeSnapshot))
+		return nil
+	}
+	restored := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      pvc.Name,
+			Namespace: namespace,
+			Labels:    pvc.Labels,
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes:      pvc.Spec.AccessModes,
+			Resources:        pvc.Spec.Resources,
+			StorageClassName: pvc.Spec.StorageClassName,
+			VolumeMode:       pvc.Spec.VolumeMode,
+			DataSource: &corev1.TypedLocalObjectReference{
+				APIGroup: ptr.To(snapshotv1.GroupName),
+				Kind:     "VolumeSnapshot",
+				Name:     ref.VolumeSnapshot,
+			},
+		},
+	}
+
+	logger.Info(fmt.Sprintf("Recreating PVC %s from VolumeSnapshot %s", pvc.Name, ref.VolumeSnapshot))
+	if err := u.client.Delete(context.TODO(), pvc); err != nil && !k8serrors.IsNotFound(err) {
+		logger.Error(fmt.Sprintf("Can't delete PVC %s", pvc.Name), zap.Error(err))
+		return err
+	}
+	if err := wait.PollUntilContextTimeout(context.Background(), snapshotPollInterval, pvcDeleteTimeout, true, func(ctx context.Context) (done bool, err error) {
+		err = u.client.Get(ctx, types.NamespacedName{Name: pvc.Name, Namespace: namespace}, &corev1.PersistentVolumeClaim{})
+		return k8serrors.IsNotFound(err), nil
+	}); err != nil {
+		logger.Error(fmt.Sprintf("PVC %s is not deleted", pvc.Name), zap.Error(err))
+		return err
+	}
+	if err := u.client.Create(context.TODO(), restored); err != nil {
+		logger.Error(fmt.Sprintf("Can't create PVC %s from VolumeSnapshot %s", pvc.Name, ref.VolumeSnapshot), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (u *Upgrade) updateUpgradeSnapshotStatus(status *v1.UpgradeSnapshotStatus) error {
+	return u.helper.UpdatePatroniCoreStatus(func(crStatus *v1.PatroniCoreStatus) {
+		crStatus.UpgradeSnapshot = status
+	})
+}
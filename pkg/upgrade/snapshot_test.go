@@ -0,0 +1,432 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package upgrade
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	types "github.com/Netcracker/pgskipper-operator-core/api/v1"
+	v1 "github.com/Netcracker/pgskipper-operator/api/patroni/v1"
+	"github.com/Netcracker/pgskipper-operator/pkg/helper"
+	opUtil "github.com/Netcracker/pgskipper-operator/pkg/util"
+	_ "github.com/Netcracker/pgskipper-operator/pkg/util/testenv"
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+const (
+	testLeader    = "pg-patroni-node2"
+	oldImage      = "patroni:15"
+	dataPvc       = "patroni-data-2"
+	walPvc        = "patroni-wals-data-2"
+	dataSnapshot  = "patroni-data-2-upgrade-1700000000"
+	walSnapshot   = "patroni-wals-data-2-upgrade-1700000000"
+	storageClass  = "csi-rbd"
+	snapshotClass = "csi-rbd-snapclass"
+)
+
+func init() {
+	snapshotPollInterval = 10 * time.Millisecond
+}
+
+// snapshotCluster is a fake cluster with the leader volumes, VolumeSnapshots become ready after readyAfter polls
+type snapshotCluster struct {
+	t          *testing.T
+	client     client.Client
+	readyAfter int
+	// failPvcCreate fails creation of PVC with the given name once
+	failPvcCreate string
+
+	mu         sync.Mutex
+	polls      map[string]int
+	pvcDeletes map[string]int
+}
+
+func newSnapshotCluster(t *testing.T, cr *v1.PatroniCore, objects ...client.Object) *snapshotCluster {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	for _, add := range []func(*runtime.Scheme) error{clientgoscheme.AddToScheme, v1.AddToScheme, snapshotv1.AddToScheme} {
+		if err := add(scheme); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sc := &snapshotCluster{t: t, polls: map[string]int{}, pvcDeletes: map[string]int{}}
+	objects = append(objects, cr,
+		&appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: testLeader, Namespace: namespace},
+			Spec: appsv1.StatefulSetSpec{
+				Replicas: ptr.To[int32](1),
+				Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "patroni", Image: oldImage}}}},
+			},
+		},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+			Name: "patroni-config", Namespace: namespace, Annotations: map[string]string{"initialize": "7300000000000000000"},
+		}},
+	)
+	sc.client = fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).
+		WithStatusSubresource(&v1.PatroniCore{}).WithInterceptorFuncs(interceptor.Funcs{
+		Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+			if err := c.Get(ctx, key, obj, opts...); err != nil {
+				return err
+			}
+			if snapshot, ok := obj.(*snapshotv1.VolumeSnapshot); ok {
+				sc.mu.Lock()
+				defer sc.mu.Unlock()
+				sc.polls[key.Name]++
+				if sc.polls[key.Name] >= sc.readyAfter {
+					snapshot.Status = &snapshotv1.VolumeSnapshotStatus{ReadyToUse: ptr.To(true)}
+				}
+			}
+			return nil
+		},
+		Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			if obj.GetName() == sc.failPvcCreate {
+				sc.failPvcCreate = ""
+				return errors.New("operator is restarted")
+			}
+			if stSet, ok := obj.(*appsv1.StatefulSet); ok && ptr.Deref(stSet.Spec.Replicas, 0) > 0 {
+				return errors.New("cluster can't be started in test")
+			}
+			return c.Create(ctx, obj, opts...)
+		},
+		Update: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.UpdateOption) error {
+			if stSet, ok := obj.(*appsv1.StatefulSet); ok && ptr.Deref(stSet.Spec.Replicas, 0) > 0 {
+				return errors.New("cluster can't be started in test")
+			}
+			return c.Update(ctx, obj, opts...)
+		},
+		Delete: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.DeleteOption) error {
+			if _, ok := obj.(*corev1.PersistentVolumeClaim); ok {
+				sc.mu.Lock()
+				sc.pvcDeletes[obj.GetName()]++
+				sc.mu.Unlock()
+			}
+			return c.Delete(ctx, obj, opts...)
+		},
+	}).Build()
+	return sc
+}
+
+func (sc *snapshotCluster) upgrade() *Upgrade {
+	return &Upgrade{client: sc.client, helper: helper.NewPatroniHelper(sc.client)}
+}
+
+func (sc *snapshotCluster) cr() *v1.PatroniCore {
+	sc.t.Helper()
+	cr := &v1.PatroniCore{}
+	if err := sc.client.Get(context.Background(), k8stypes.NamespacedName{Name: "patroni-core", Namespace: namespace}, cr); err != nil {
+		sc.t.Fatal(err)
+	}
+	return cr
+}
+
+func (sc *snapshotCluster) pvc(name string) *corev1.PersistentVolumeClaim {
+	sc.t.Helper()
+	pvc := &corev1.PersistentVolumeClaim{}
+	if err := sc.client.Get(context.Background(), k8stypes.NamespacedName{Name: name, Namespace: namespace}, pvc); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil
+		}
+		sc.t.Fatal(err)
+	}
+	return pvc
+}
+
+func newSnapshotCR(status *v1.UpgradeSnapshotStatus) *v1.PatroniCore {
+	return &v1.PatroniCore{
+		ObjectMeta: metav1.ObjectMeta{Name: "patroni-core", Namespace: namespace},
+		Spec: &v1.PatroniCoreSpec{
+			Patroni: &v1.Patroni{
+				DockerImage:  "patroni:16",
+				Replicas:     2,
+				Dcs:          v1.Dcs{Type: "kubernetes"},
+				Storage:      &types.Storage{Type: "provisioned", Size: "10Gi", StorageClass: storageClass},
+				PgWalStorage: &types.Storage{Type: "provisioned", Size: "2Gi", StorageClass: storageClass},
+				Resources:    &corev1.ResourceRequirements{},
+			},
+		},
+		Upgrade: &v1.Upgrade{Enabled: true, Snapshot: &v1.UpgradeSnapshot{Enabled: true, VolumeSnapshotClassName: snapshotClass}},
+		Status:  v1.PatroniCoreStatus{UpgradeSnapshot: status},
+	}
+}
+
+func newPvc(name, size, dataSource string) *corev1.PersistentVolumeClaim {
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: map[string]string{"app": "patroni"}},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			Resources:        corev1.VolumeResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(size)}},
+			StorageClassName: ptr.To(storageClass),
+			VolumeName:       "pv-" + name,
+		},
+	}
+	if dataSource != "" {
+		pvc.Spec.DataSource = &corev1.TypedLocalObjectReference{APIGroup: ptr.To(snapshotv1.GroupName), Kind: "VolumeSnapshot", Name: dataSource}
+	}
+	return pvc
+}
+
+func newRollbackPoint(phase string) *v1.UpgradeSnapshotStatus {
+	return &v1.UpgradeSnapshotStatus{
+		Phase:   phase,
+		Image:   oldImage,
+		Version: "15",
+		Leader:  testLeader,
+		Snapshots: []v1.VolumeSnapshotRef{
+			{PersistentVolumeClaim: dataPvc, VolumeSnapshot: dataSnapshot},
+			{PersistentVolumeClaim: walPvc, VolumeSnapshot: walSnapshot},
+		},
+	}
+}
+
+func assertRestored(t *testing.T, pvc *corev1.PersistentVolumeClaim, snapshot string, size string) {
+	t.Helper()
+	if pvc == nil {
+		t.Fatalf("PVC restored from %s doesn't exist", snapshot)
+	}
+	source := pvc.Spec.DataSource
+	if source == nil || source.Kind != "VolumeSnapshot" || ptr.Deref(source.APIGroup, "") != snapshotv1.GroupName || source.Name != snapshot {
+		t.Errorf("data source of %s is %+v, expected VolumeSnapshot %s", pvc.Name, source, snapshot)
+	}
+	if ptr.Deref(pvc.Spec.StorageClassName, "") != storageClass {
+		t.Errorf("storage class of %s is %v", pvc.Name, pvc.Spec.StorageClassName)
+	}
+	if requested := pvc.Spec.Resources.Requests[corev1.ResourceStorage]; requested.String() != size {
+		t.Errorf("size of %s is %s, expected %s", pvc.Name, requested.String(), size)
+	}
+	if !reflect.DeepEqual(pvc.Spec.AccessModes, []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}) {
+		t.Errorf("access modes of %s are %v", pvc.Name, pvc.Spec.AccessModes)
+	}
+	// restored volume is provisioned from the snapshot, not bound to the old one
+	if pvc.Spec.VolumeName != "" {
+		t.Errorf("%s is bound to the old volume %s", pvc.Name, pvc.Spec.VolumeName)
+	}
+}
+
+func TestCreateRollbackPoint(t *testing.T) {
+	previous := newRollbackPoint(v1.UpgradeSnapshotReady)
+	sc := newSnapshotCluster(t, newSnapshotCR(previous),
+		&snapshotv1.VolumeSnapshot{ObjectMeta: metav1.ObjectMeta{Name: dataSnapshot, Namespace: namespace}},
+		&snapshotv1.VolumeSnapshot{ObjectMeta: metav1.ObjectMeta{Name: walSnapshot, Namespace: namespace}},
+	)
+	sc.readyAfter = 3
+	cluster := opUtil.GetPatroniClusterSettings("patroni")
+
+	if err := sc.upgrade().createRollbackPoint(sc.cr(), cluster, testLeader, 2, "15"); err != nil {
+		t.Fatal(err)
+	}
+
+	status := sc.cr().Status.UpgradeSnapshot
+	if status == nil || status.Phase != v1.UpgradeSnapshotReady || status.Image != oldImage || status.Version != "15" ||
+		status.Leader != testLeader || status.CreationTime == nil {
+		t.Fatalf("unexpected status of rollback point: %+v", status)
+	}
+	if len(status.Snapshots) != 2 {
+		t.Fatalf("snapshots in status are %+v, expected snapshots of data and WAL volumes", status.Snapshots)
+	}
+	for i, pvcName := range []string{dataPvc, walPvc} {
+		ref := status.Snapshots[i]
+		if ref.PersistentVolumeClaim != pvcName || !strings.HasPrefix(ref.VolumeSnapshot, pvcName+"-upgrade-") {
+			t.Errorf("unexpected snapshot of %s in status: %+v", pvcName, ref)
+			continue
+		}
+		snapshot := &snapshotv1.VolumeSnapshot{}
+		if err := sc.client.Get(context.Background(), k8stypes.NamespacedName{Name: ref.VolumeSnapshot, Namespace: namespace}, snapshot); err != nil {
+			t.Fatalf("snapshot %s from status doesn't exist: %v", ref.VolumeSnapshot, err)
+		}
+		if ptr.Deref(snapshot.Spec.Source.PersistentVolumeClaimName, "") != pvcName {
+			t.Errorf("source of %s is %v, expected %s", snapshot.Name, snapshot.Spec.Source.PersistentVolumeClaimName, pvcName)
+		}
+		if ptr.Deref(snapshot.Spec.VolumeSnapshotClassName, "") != snapshotClass {
+			t.Errorf("class of %s is %v", snapshot.Name, snapshot.Spec.VolumeSnapshotClassName)
+		}
+		if !reflect.DeepEqual(snapshot.Labels, cluster.PatroniLabels) {
+			t.Errorf("labels of %s are %v", snapshot.Name, snapshot.Labels)
+		}
+		// rollback point is recorded only when the snapshot is ready
+		if sc.polls[ref.VolumeSnapshot] < sc.readyAfter {
+			t.Errorf("snapshot %s is polled %d times, it's ready after %d", ref.VolumeSnapshot, sc.polls[ref.VolumeSnapshot], sc.readyAfter)
+		}
+	}
+
+	for _, ref := range previous.Snapshots {
+		err := sc.client.Get(context.Background(), k8stypes.NamespacedName{Name: ref.VolumeSnapshot, Namespace: namespace}, &snapshotv1.VolumeSnapshot{})
+		if !k8serrors.IsNotFound(err) {
+			t.Errorf("snapshot %s of previous rollback point is not deleted: %v", ref.VolumeSnapshot, err)
+		}
+	}
+}
+
+func TestCreateRollbackPointSnapshotFailed(t *testing.T) {
+	previous := newRollbackPoint(v1.UpgradeSnapshotReady)
+	sc := newSnapshotCluster(t, newSnapshotCR(previous),
+		&snapshotv1.VolumeSnapshot{ObjectMeta: metav1.ObjectMeta{Name: dataSnapshot, Namespace: namespace}},
+	)
+	sc.client = interceptor.NewClient(sc.client.(client.WithWatch), interceptor.Funcs{
+		Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+			if err := c.Get(ctx, key, obj, opts...); err != nil {
+				return err
+			}
+			if snapshot, ok := obj.(*snapshotv1.VolumeSnapshot); ok {
+				snapshot.Status = &snapshotv1.VolumeSnapshotStatus{Error: &snapshotv1.VolumeSnapshotError{Message: ptr.To("quota exceeded")}}
+			}
+			return nil
+		},
+	})
+
+	err := sc.upgrade().createRollbackPoint(sc.cr(), opUtil.GetPatroniClusterSettings("patroni"), testLeader, 2, "15")
+	if err == nil || !strings.Contains(err.Error(), "quota exceeded") {
+		t.Fatalf("expected snapshot error, got %v", err)
+	}
+	// the previous rollback point is kept
+	if status := sc.cr().Status.UpgradeSnapshot; !reflect.DeepEqual(status.Snapshots, previous.Snapshots) {
+		t.Errorf("rollback point is changed to %+v", status.Snapshots)
+	}
+	if err := sc.client.Get(context.Background(), k8stypes.NamespacedName{Name: dataSnapshot, Namespace: namespace}, &snapshotv1.VolumeSnapshot{}); err != nil {
+		t.Errorf("snapshot of previous rollback point is deleted: %v", err)
+	}
+}
+
+func TestRestorePvcFromSnapshot(t *testing.T) {
+	tests := []struct {
+		name       string
+		dataSource string
+		resume     bool
+		recreated  bool
+	}{
+		{name: "new rollback", recreated: true},
+		{name: "new rollback from the same snapshot", dataSource: dataSnapshot, recreated: true},
+		{name: "resumed rollback", dataSource: dataSnapshot, resume: true},
+		{name: "resumed rollback of not restored volume", dataSource: "", resume: true, recreated: true},
+		{name: "resumed rollback of volume restored from another snapshot", dataSource: "patroni-data-2-upgrade-1600000000", resume: true, recreated: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc := newSnapshotCluster(t, newSnapshotCR(nil), newPvc(dataPvc, "10Gi", tt.dataSource))
+			ref := v1.VolumeSnapshotRef{PersistentVolumeClaim: dataPvc, VolumeSnapshot: dataSnapshot}
+			if err := sc.upgrade().restorePvcFromSnapshot(ref, newPvc(dataPvc, "1Gi", ""), tt.resume); err != nil {
+				t.Fatal(err)
+			}
+			if recreated := sc.pvcDeletes[dataPvc] > 0; recreated != tt.recreated {
+				t.Fatalf("PVC is recreated: %t, expected %t", recreated, tt.recreated)
+			}
+			pvc := sc.pvc(dataPvc)
+			if tt.recreated {
+				assertRestored(t, pvc, dataSnapshot, "10Gi")
+				if pvc.Labels["app"] != "patroni" {
+					t.Errorf("labels are not kept: %v", pvc.Labels)
+				}
+			} else if pvc.Spec.VolumeName != "pv-"+dataPvc {
+				t.Errorf("restored PVC is changed: %+v", pvc.Spec)
+			}
+		})
+	}
+}
+
+func TestRestorePvcFromSnapshotNotFound(t *testing.T) {
+	sc := newSnapshotCluster(t, newSnapshotCR(nil))
+	ref := v1.VolumeSnapshotRef{PersistentVolumeClaim: dataPvc, VolumeSnapshot: dataSnapshot}
+
+	// missing PVC isn't created by a new rollback, its spec is unknown
+	if err := sc.upgrade().restorePvcFromSnapshot(ref, newPvc(dataPvc, "10Gi", ""), false); !k8serrors.IsNotFound(err) {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+	if pvc := sc.pvc(dataPvc); pvc != nil {
+		t.Fatalf("PVC is created by a new rollback")
+	}
+
+	// PVC deleted by the interrupted rollback is created from the spec
+	if err := sc.upgrade().restorePvcFromSnapshot(ref, newPvc(dataPvc, "10Gi", ""), true); err != nil {
+		t.Fatal(err)
+	}
+	assertRestored(t, sc.pvc(dataPvc), dataSnapshot, "10Gi")
+}
+
+func TestRollbackUpgradeWithoutRollbackPoint(t *testing.T) {
+	for _, status := range []*v1.UpgradeSnapshotStatus{nil, {Phase: v1.UpgradeSnapshotReady, Image: oldImage}, {Snapshots: newRollbackPoint("").Snapshots}} {
+		sc := newSnapshotCluster(t, newSnapshotCR(status), newPvc(dataPvc, "10Gi", ""))
+		if err := sc.upgrade().RollbackUpgrade(sc.cr(), opUtil.GetPatroniClusterSettings("patroni")); err == nil {
+			t.Errorf("rollback is started without rollback point: %+v", status)
+		}
+		if sc.pvcDeletes[dataPvc] != 0 {
+			t.Errorf("PVC is deleted without rollback point: %+v", status)
+		}
+	}
+}
+
+func TestRollbackUpgradeResumedAfterRestart(t *testing.T) {
+	sc := newSnapshotCluster(t, newSnapshotCR(newRollbackPoint(v1.UpgradeSnapshotReady)),
+		newPvc(dataPvc, "10Gi", ""), newPvc(walPvc, "2Gi", ""))
+	cluster := opUtil.GetPatroniClusterSettings("patroni")
+
+	// operator is restarted when the WAL volume is deleted, but not created from the snapshot yet
+	sc.failPvcCreate = walPvc
+	if err := sc.upgrade().RollbackUpgrade(sc.cr(), cluster); err == nil {
+		t.Fatal("interrupted rollback succeeded")
+	}
+	status := sc.cr().Status.UpgradeSnapshot
+	if status.Phase != v1.UpgradeSnapshotRollbackFailed || !strings.Contains(status.Message, "operator is restarted") {
+		t.Fatalf("unexpected status of interrupted rollback: %+v", status)
+	}
+	assertRestored(t, sc.pvc(dataPvc), dataSnapshot, "10Gi")
+	if sc.pvc(walPvc) != nil {
+		t.Fatal("WAL volume is created")
+	}
+	stSet := &appsv1.StatefulSet{}
+	if err := sc.client.Get(context.Background(), k8stypes.NamespacedName{Name: testLeader, Namespace: namespace}, stSet); err != nil {
+		t.Fatal(err)
+	}
+	if ptr.Deref(stSet.Spec.Replicas, 1) != 0 {
+		t.Errorf("cluster is not stopped before restore, replicas: %d", *stSet.Spec.Replicas)
+	}
+
+	// resumed rollback keeps the restored volume and restores the rest
+	if err := sc.upgrade().RollbackUpgrade(sc.cr(), cluster); err == nil || !strings.Contains(err.Error(), "cluster can't be started") {
+		t.Fatalf("expected error of cluster start, got %v", err)
+	}
+	if sc.pvcDeletes[dataPvc] != 1 {
+		t.Errorf("restored data volume is deleted %d times, expected once", sc.pvcDeletes[dataPvc])
+	}
+	assertRestored(t, sc.pvc(dataPvc), dataSnapshot, "10Gi")
+	assertRestored(t, sc.pvc(walPvc), walSnapshot, "2Gi")
+
+	cm := &corev1.ConfigMap{}
+	if err := sc.client.Get(context.Background(), k8stypes.NamespacedName{Name: "patroni-config", Namespace: namespace}, cm); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cm.Annotations["initialize"]; ok {
+		t.Errorf("initialize key of the upgraded cluster is kept")
+	}
+	if status := sc.cr().Status.UpgradeSnapshot; status.Phase != v1.UpgradeSnapshotRollbackFailed || status.Image != oldImage {
+		t.Errorf("unexpected status after cluster start failure: %+v", status)
+	}
+}
@@ -0,0 +1,262 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package upgrade
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	v1 "github.com/Netcracker/pgskipper-operator/api/patroni/v1"
+	"github.com/Netcracker/pgskipper-operator/pkg/deployment"
+	"github.com/Netcracker/pgskipper-operator/pkg/patroni"
+	opUtil "github.com/Netcracker/pgskipper-operator/pkg/util"
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// activeSiteStatus is a part of site manager response with details
+type activeSiteStatus struct {
+	Mode      string `json:"mode"`
+	Status    string `json:"status"`
+	PgVersion string `json:"pgVersion"`
+}
+
+// IsStandbyCluster returns true if the cluster replicates from the other site,
+// either standbyCluster is set in PatroniCore or site manager switched the site to standby
+func (u *Upgrade) IsStandbyCluster(cr *v1.PatroniCore) bool {
+	if patroni.IsStandbyClusterConfigurationExist(cr) {
+		return true
+	}
+	if servicesCr, err := u.helper.GetPostgresServiceCR(); err == nil {
+		return servicesCr.Status.SiteManagerStatus.Mode == "standby"
+	}
+	return false
+}
+
+// ErrStandbyWaitingForActive is returned while standby upgrade waits for the active site,
+// reconcile is requeued without failure of the cluster
+var ErrStandbyWaitingForActive = errors.New("standby upgrade is waiting for the active site")
+
+// ProceedStandbyUpgrade upgrades standby cluster of DR scheme. pg_upgrade can't be used on standby,
+// because the upgraded active cluster has a new system identifier, so standby waits until the active site
+// reports the target version and then is re-seeded from it with the new image.
+func (u *Upgrade) ProceedStandbyUpgrade(cr *v1.PatroniCore, cluster *v1.PatroniClusterSettings) error {
+	status := &v1.StandbyUpgradeStatus{Source: u.getStandbySource(cr)}
+	if cr.Status.StandbyUpgrade != nil {
+		status.Phase = cr.Status.StandbyUpgrade.Phase
+		status.LastTransitionTime = cr.Status.StandbyUpgrade.LastTransitionTime
+		if cr.Status.StandbyUpgrade.TargetImage == cr.Spec.Patroni.DockerImage {
+			status.TargetImage = cr.Status.StandbyUpgrade.TargetImage
+			status.TargetVersion = cr.Status.StandbyUpgrade.TargetVersion
+		}
+	}
+
+	if status.TargetVersion == "" {
+		targetVersion, err := u.getTargetVersion(cr, cluster)
+		if err != nil {
+			return u.failStandbyUpgrade(status, err)
+		}
+		status.TargetVersion = targetVersion
+		status.TargetImage = cr.Spec.Patroni.DockerImage
+	}
+
+	if cr.Upgrade.Standby == nil || cr.Upgrade.Standby.ActiveSiteManagerUrl == "" {
+		return u.failStandbyUpgrade(status, fmt.Errorf("majorUpgrade.standby.activeSiteManagerUrl is required to upgrade standby cluster"))
+	}
+	activeStatus, err := u.getActiveSiteStatus(cr.Upgrade.Standby)
+	if err != nil {
+		return u.failStandbyUpgrade(status, fmt.Errorf("can't get status of the active site: %w", err))
+	}
+	status.ActiveVersion = activeStatus.PgVersion
+	if activeStatus.Mode != "active" || activeStatus.PgVersion != status.TargetVersion {
+		// reconcile is requeued, so the upgrade continues when the active site is upgraded
+		message := fmt.Sprintf("standby upgrade to %s is deferred until the active site is upgraded, active site reports mode %s, version %s",
+			status.TargetVersion, activeStatus.Mode, activeStatus.PgVersion)
+		logger.Info(message)
+		u.setStandbyUpgradeStatus(status, v1.StandbyUpgradeWaitingForActive, message)
+		return fmt.Errorf("%w: %s", ErrStandbyWaitingForActive, message)
+	}
+
+	logger.Info(fmt.Sprintf("Active site is upgraded to %s, re-seeding standby cluster from %s", status.TargetVersion, status.Source))
+	u.setStandbyUpgradeStatus(status, v1.StandbyUpgradeReseeding, "")
+	if err := u.reseedStandby(cr, cluster); err != nil {
+		return u.failStandbyUpgrade(status, err)
+	}
+
+	masterPod, err := u.helper.ResourceManager.GetPodsByLabel(cluster.PatroniMasterSelectors)
+	if err == nil && len(masterPod.Items) > 0 {
+		u.helper.StoreDataToCM("pg-version", u.helper.GetPGVersionFromPod(masterPod.Items[0].Name))
+	} else {
+		logger.Info("Can not get master pod")
+	}
+	if err := u.ScalePowaDeployment(1); err != nil {
+		return err
+	}
+
+	u.setStandbyUpgradeStatus(status, v1.StandbyUpgradeCompleted, fmt.Sprintf("standby is re-seeded from %s", status.Source))
+	if err := u.UpdateUpgradeToDone(); err != nil {
+		logger.Error("Can't update CR", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// getTargetVersion reads PostgreSQL major version from a temporary pod with the target image
+func (u *Upgrade) getTargetVersion(cr *v1.PatroniCore, cluster *v1.PatroniClusterSettings) (string, error) {
+	targetPod, err := u.RunUpgradePatroniPod(cr, cluster)
+	if err != nil {
+		return "", fmt.Errorf("can't run pod with target image: %w", err)
+	}
+	targetVersion := u.helper.GetPGVersionFromPod(targetPod.Name)
+	if err := u.helper.ResourceManager.DeletePod(targetPod); err != nil {
+		logger.Warn("Can't delete pg-upgrade-check-pod", zap.Error(err))
+	}
+	if targetVersion == "" {
+		return "", fmt.Errorf("can't read PostgreSQL version of image %s", cr.Spec.Patroni.DockerImage)
+	}
+	return targetVersion, nil
+}
+
+// reseedStandby wipes data of all members and starts them with the new image,
+// standby leader takes a base backup from the active cluster and replicas from standby leader
+func (u *Upgrade) reseedStandby(cr *v1.PatroniCore, cluster *v1.PatroniClusterSettings) error {
+	if err := u.ScalePowaDeployment(0); err != nil {
+		return err
+	}
+	if err := u.helper.ResourceManager.DeletePodsByLabel(powaUILabels); err != nil {
+		return err
+	}
+	leaderName, err := u.getLeaderName()
+	if err != nil {
+		logger.Error("Can't get Patroni Leader, failing major upgrade", zap.Error(err))
+		return err
+	}
+	leaderIdx, err := opUtil.GetPatroniNodeIdx(leaderName)
+	if err != nil {
+		return err
+	}
+
+	patroniPods, err := u.helper.ResourceManager.GetNamespacePodListBySelectors(cluster.PatroniCommonLabels)
+	if err != nil {
+		return err
+	}
+	if err = u.helper.UpdatePatroniReplicas(0, cluster.ClusterName); err != nil {
+		return err
+	}
+	for _, patroniPod := range patroniPods.Items {
+		if err = opUtil.WaitDeletePod(&patroniPod); err != nil {
+			logger.Error("waiting for Patroni deployment delete failed", zap.Error(err))
+			return err
+		}
+	}
+
+	// system identifier of the upgraded active cluster differs from the current one
+	if err := u.CleanInitializeKey(cluster.ClusterName); err != nil {
+		return err
+	}
+
+	patroniDeployment := deployment.NewPatroniStatefulset(cr, leaderIdx, cluster.ClusterName,
+		cluster.PatroniTemplate, cluster.PostgreSQLUserConf, cluster.PatroniLabels)
+	patroniDeployment.Spec.Template.Spec.InitContainers = append(u.GetCleanerInitContainer(cr.Spec.Patroni.DockerImage),
+		patroniDeployment.Spec.Template.Spec.InitContainers...)
+	if err := u.helper.ResourceManager.CreateOrUpdateStatefulset(patroniDeployment, true); err != nil {
+		logger.Error("Can't update Patroni deployment", zap.Error(err))
+		return err
+	}
+	if err := opUtil.WaitForLeader(cluster.PatroniMasterSelectors); err != nil {
+		return err
+	}
+	if err := u.ApplyCleanerInitContainer(leaderName, cr.Spec.Patroni, cluster); err != nil {
+		return err
+	}
+	if err := opUtil.WaitForPatroni(cr, cluster.PatroniMasterSelectors, cluster.PatroniReplicasSelector); err != nil {
+		return err
+	}
+	// data of the leader must not be wiped on its next restart
+	if err := u.helper.DeleteCleanerInitContainer(cluster.ClusterName); err != nil {
+		return err
+	}
+	return u.restoreStandbyConfiguration(cr, cluster)
+}
+
+// restoreStandbyConfiguration sets standby_cluster in Patroni dynamic configuration again,
+// it's taken from PatroniCore or from site manager settings if the site was switched by site manager
+func (u *Upgrade) restoreStandbyConfiguration(cr *v1.PatroniCore, cluster *v1.PatroniClusterSettings) error {
+	if patroni.IsStandbyClusterConfigurationExist(cr) {
+		return patroni.AddStandbyClusterConfigurationConfigMap(cr, cluster.PatroniUrl)
+	}
+	return u.helper.AddStandbyClusterConfigurationConfigMap(cluster.PatroniUrl)
+}
+
+func (u *Upgrade) getActiveSiteStatus(standby *v1.StandbyUpgrade) (*activeSiteStatus, error) {
+	url := strings.TrimSuffix(standby.ActiveSiteManagerUrl, "/") + "/sitemanager?details=true"
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if standby.TokenSecretName != "" {
+		secret, err := u.helper.ResourceManager.GetSecret(standby.TokenSecretName)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(secret.Data["token"])))
+	}
+	resp, err := opUtil.GetSiteManagerHttpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("site manager %s responded with %s", url, resp.Status)
+	}
+	status := &activeSiteStatus{}
+	if err := json.NewDecoder(resp.Body).Decode(status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+func (u *Upgrade) getStandbySource(cr *v1.PatroniCore) string {
+	if patroni.IsStandbyClusterConfigurationExist(cr) {
+		return fmt.Sprintf("%s:%d", cr.Spec.Patroni.StandbyCluster.Host, cr.Spec.Patroni.StandbyCluster.Port)
+	}
+	if servicesCr, err := u.helper.GetPostgresServiceCR(); err == nil && servicesCr.Spec.SiteManager != nil {
+		return fmt.Sprintf("%s:%d", servicesCr.Spec.SiteManager.ActiveClusterHost, servicesCr.Spec.SiteManager.ActiveClusterPort)
+	}
+	return ""
+}
+
+func (u *Upgrade) failStandbyUpgrade(status *v1.StandbyUpgradeStatus, err error) error {
+	logger.Error("Major upgrade of standby cluster failed", zap.Error(err))
+	u.setStandbyUpgradeStatus(status, v1.StandbyUpgradeFailed, err.Error())
+	return err
+}
+
+func (u *Upgrade) setStandbyUpgradeStatus(status *v1.StandbyUpgradeStatus, phase string, message string) {
+	if status.Phase != phase || status.LastTransitionTime == nil {
+		status.LastTransitionTime = &metav1.Time{Time: time.Now()}
+	}
+	status.Phase = phase
+	status.Message = message
+	if err := u.helper.UpdatePatroniCoreStatus(func(crStatus *v1.PatroniCoreStatus) {
+		crStatus.StandbyUpgrade = status
+	}); err != nil {
+		logger.Error("Can't update standby upgrade status", zap.Error(err))
+	}
+}
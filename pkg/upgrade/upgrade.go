@@ -50,12 +50,18 @@ var (
 )
 
 func Init(client client.Client) *Upgrade {
-	return &Upgrade{client: client, helper: helper.GetPatroniHelper()}
+	return New(client, helper.GetPatroniHelper())
+}
+
+// New returns Upgrade which uses the given helper instead of the shared one
+func New(client client.Client, patroniHelper *helper.PatroniHelper) *Upgrade {
+	return &Upgrade{client: client, helper: patroniHelper}
 }
 
 type Upgrade struct {
 	client client.Client
 	helper *helper.PatroniHelper
+	checks preflightState
 }
 
 func (u *Upgrade) GetCleanerInitContainer(dockerImage string) []corev1.Container {
@@ -210,7 +216,9 @@ func (u *Upgrade) IfAbsTimeIsUsed(pgC *pgClient.PostgresClient, db string) bool
 	rows, err := conn.Query(context.Background(), checkForAbsTimeQuery)
 	if err != nil {
 		logger.Warn(fmt.Sprintf("Cannot check incompatible data type 'abstime' on database %s", db), zap.Error(err))
+		return false
 	}
+	defer rows.Close()
 
 	if rows.Next() {
 		return true
@@ -233,7 +241,9 @@ func (u *Upgrade) decideInitDbArgs(cr *v1.PatroniCore, cluster *v1.PatroniCluste
 	return
 }
 
-func (u *Upgrade) applyCleanerInitContainer(leaderName string, patroniSpec *v1.Patroni, cluster *v1.PatroniClusterSettings) error {
+// ApplyCleanerInitContainer scales up all members except the leader with data directory cleanup,
+// so they are reinitialized from the leader
+func (u *Upgrade) ApplyCleanerInitContainer(leaderName string, patroniSpec *v1.Patroni, cluster *v1.PatroniClusterSettings) error {
 	var deploymentList []*appsv1.StatefulSet
 	var err error
 	cleanerInitContainer := u.GetCleanerInitContainer(patroniSpec.DockerImage)
@@ -271,13 +281,7 @@ func (u *Upgrade) pgUpgradeCheckFailed(upgradePod *corev1.Pod, cluster *v1.Patro
 	}
 	if code == 13 {
 		logger.Error("Can't upgrade Patroni cluster. Rollback.")
-		if err = u.helper.UpdatePatroniReplicas(1, cluster.ClusterName); err != nil {
-			return false, err
-		}
-		if err = u.ScalePowaDeployment(1); err != nil {
-			return false, err
-		}
-		if err = opUtil.WaitForPatroni(cr, cluster.PatroniMasterSelectors, cluster.PatroniReplicasSelector); err != nil {
+		if err = u.restartCluster(cr, cluster); err != nil {
 			return false, err
 		}
 		return true, nil
@@ -285,6 +289,17 @@ func (u *Upgrade) pgUpgradeCheckFailed(upgradePod *corev1.Pod, cluster *v1.Patro
 	return false, nil
 }
 
+// restartCluster starts the cluster stopped for the upgrade on the current image
+func (u *Upgrade) restartCluster(cr *v1.PatroniCore, cluster *v1.PatroniClusterSettings) error {
+	if err := u.helper.UpdatePatroniReplicas(1, cluster.ClusterName); err != nil {
+		return err
+	}
+	if err := u.ScalePowaDeployment(1); err != nil {
+		return err
+	}
+	return opUtil.WaitForPatroni(cr, cluster.PatroniMasterSelectors, cluster.PatroniReplicasSelector)
+}
+
 func (u *Upgrade) CheckForPreparedTransactions(pgHost string) error {
 	pgC := pgClient.GetPostgresClient(pgHost)
 	checkForPreparedTxQuery := "SELECT DISTINCT database FROM pg_prepared_xacts;"
@@ -321,16 +336,8 @@ func (u *Upgrade) CheckForPreparedTransactions(pgHost string) error {
 	return nil
 }
 
-func (u *Upgrade) ProceedUpgrade(cr *v1.PatroniCore, cluster *v1.PatroniClusterSettings) error {
-
-	masterPod, err := u.helper.ResourceManager.GetPodsByLabel(cluster.PatroniMasterSelectors)
-	if err != nil || len(masterPod.Items) == 0 {
-		logger.Error("Can't get Patroni Leader for pg_dumpall execution, failing major upgrade", zap.Error(err))
-		return err
-	}
-	masterPodName := masterPod.Items[0].Name
-	namespace := util.GetNameSpace()
-
+// checkSharedPreloadLibraries makes sure shared_preload_libraries is set in PostgreSQL config of the leader
+func (u *Upgrade) checkSharedPreloadLibraries(masterPodName string) error {
 	command := "grep \"shared_preload_libraries\" /var/lib/pgsql/data/postgresql_${POD_IDENTITY}/postgresql.conf || echo \"not found\""
 	result, _, err := u.helper.ExecCmdOnPatroniPod(masterPodName, namespace, command)
 	if err != nil {
@@ -342,9 +349,13 @@ func (u *Upgrade) ProceedUpgrade(cr *v1.PatroniCore, cluster *v1.PatroniClusterS
 		logger.Error(errMsg, zap.Error(err))
 		return errors.New(errMsg)
 	}
+	return nil
+}
 
-	command = "pg_dumpall -v -U postgres -w --file=/tmp/test_db_dumpall.custom --schema-only"
-	_, _, err = u.helper.ExecCmdOnPatroniPod(masterPodName, namespace, command)
+// checkSchemaDump makes sure schema of all databases can be dumped, pg_upgrade uses the same dump
+func (u *Upgrade) checkSchemaDump(masterPodName string) error {
+	command := "pg_dumpall -v -U postgres -w --file=/tmp/test_db_dumpall.custom --schema-only"
+	_, _, err := u.helper.ExecCmdOnPatroniPod(masterPodName, namespace, command)
 	if err != nil {
 		logger.Error("Can't execute pg_dumpall command, failing major upgrade", zap.Error(err))
 		return err
@@ -358,6 +369,28 @@ func (u *Upgrade) ProceedUpgrade(cr *v1.PatroniCore, cluster *v1.PatroniClusterS
 	} else {
 		logger.Info("Dump file removed successfully")
 	}
+	return nil
+}
+
+func (u *Upgrade) ProceedUpgrade(cr *v1.PatroniCore, cluster *v1.PatroniClusterSettings) error {
+	if u.IsStandbyCluster(cr) {
+		logger.Info("Cluster is a standby of DR scheme, upgrade is coordinated with the active site")
+		return u.ProceedStandbyUpgrade(cr, cluster)
+	}
+
+	masterPod, err := u.helper.ResourceManager.GetPodsByLabel(cluster.PatroniMasterSelectors)
+	if err != nil || len(masterPod.Items) == 0 {
+		logger.Error("Can't get Patroni Leader for pg_dumpall execution, failing major upgrade", zap.Error(err))
+		return err
+	}
+	masterPodName := masterPod.Items[0].Name
+
+	if err := u.checkSharedPreloadLibraries(masterPodName); err != nil {
+		return err
+	}
+	if err := u.checkSchemaDump(masterPodName); err != nil {
+		return err
+	}
 
 	// Check for prepared transactions before upgrade
 	if err := u.CheckForPreparedTransactions(cluster.PgHost); err != nil {
@@ -375,6 +408,12 @@ func (u *Upgrade) ProceedUpgrade(cr *v1.PatroniCore, cluster *v1.PatroniClusterS
 		return errors.New("patroni cluster is not healthy enough for upgrade procedure. Exiting")
 	}
 
+	snapshotEnabled := cr.Upgrade.Snapshot != nil && cr.Upgrade.Snapshot.Enabled
+	var oldPgVersion string
+	if snapshotEnabled {
+		oldPgVersion = u.helper.GetPGVersionFromPod(masterPodName)
+	}
+
 	//Scaling down powa deployment before upgrade
 	if err := u.ScalePowaDeployment(0); err != nil {
 		return err
@@ -413,10 +452,24 @@ func (u *Upgrade) ProceedUpgrade(cr *v1.PatroniCore, cluster *v1.PatroniClusterS
 	}
 
 	logger.Info(fmt.Sprintf("Leader name is %s", leaderName))
-	deploymentIdx, _ := strconv.Atoi(leaderName[len(leaderName)-1:])
+	deploymentIdx, err := opUtil.GetPatroniNodeIdx(leaderName)
+	if err != nil {
+		return err
+	}
+
+	// volumes are consistent only when the cluster is stopped
+	if snapshotEnabled {
+		if err := u.createRollbackPoint(cr, cluster, leaderName, deploymentIdx, oldPgVersion); err != nil {
+			logger.Error("Can't create rollback point, cancelling major upgrade", zap.Error(err))
+			if err := u.restartCluster(cr, cluster); err != nil {
+				logger.Error("Can't start Patroni cluster after failed snapshot", zap.Error(err))
+			}
+			return err
+		}
+	}
 	patroniDeployment := deployment.NewPatroniStatefulset(cr, deploymentIdx, cluster.ClusterName,
 		cluster.PatroniTemplate, cluster.PostgreSQLUserConf, cluster.PatroniLabels)
-	upgradePod := u.getUpgradePod(patroniSpec, leaderName, initDbArgs, cr.Upgrade.DockerUpgradeImage)
+	upgradePod := u.getUpgradePod(patroniSpec, deploymentIdx, initDbArgs, cr.Upgrade.DockerUpgradeImage)
 
 	// copy nodeSelector, Volumes, SecurityContext from Deployment
 	upgradePod.Spec.NodeSelector = patroniDeployment.Spec.Template.Spec.NodeSelector
@@ -452,7 +505,7 @@ func (u *Upgrade) ProceedUpgrade(cr *v1.PatroniCore, cluster *v1.PatroniClusterS
 		return err
 	}
 
-	if err := u.applyCleanerInitContainer(leaderName, patroniSpec, cluster); err != nil {
+	if err := u.ApplyCleanerInitContainer(leaderName, patroniSpec, cluster); err != nil {
 		return err
 	}
 
@@ -482,8 +535,7 @@ func (u *Upgrade) ProceedUpgrade(cr *v1.PatroniCore, cluster *v1.PatroniClusterS
 	return nil
 }
 
-func (u *Upgrade) getUpgradePod(patroniSpec *v1.Patroni, leaderName string, initDbArgs string, upgradeImage string) *corev1.Pod {
-	patroniIdx := leaderName[len(leaderName)-1:]
+func (u *Upgrade) getUpgradePod(patroniSpec *v1.Patroni, patroniIdx int, initDbArgs string, upgradeImage string) *corev1.Pod {
 	upgradePod := &corev1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "pg-major-upgrade-" + strconv.Itoa(int(time.Now().Unix())),
@@ -509,7 +561,7 @@ func (u *Upgrade) getUpgradePod(patroniSpec *v1.Patroni, leaderName string, init
 					Env: []corev1.EnvVar{
 						{
 							Name:  "DATA_DIR",
-							Value: fmt.Sprintf("postgresql_node%s", patroniIdx),
+							Value: fmt.Sprintf("postgresql_node%d", patroniIdx),
 						},
 						{
 							Name:  "TYPE",
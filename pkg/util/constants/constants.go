@@ -18,6 +18,7 @@ const (
 	ArchiveModeOff           = "off"
 	ArchiveModeOn            = "on"
 	PasswordEncryption       = "md5"
+	ScramSHA256              = "scram-sha-256"
 	ArchiveCommand           = `/opt/scripts/archive_wal.sh "%p" "%f"`
 	PgBackRestArchiveCommand = `pgbackrest --stanza=patroni archive-push "%p"`
 	PgBackRestRestoreCommand = `pgbackrest --stanza=patroni archive-get "%f" "%p"`
@@ -28,19 +29,3 @@ const (
 	RDS                      = "rds"
 	Azure                    = "azure"
 )
-
-var PgHba = []string{
-	"local   all             postgres                                trust",
-	"host    all             postgres             127.0.0.1/32       trust",
-	"host    all             postgres             ::1/128            trust",
-	"local   replication     all                                     trust",
-	"host    replication     all                  127.0.0.1/32       trust",
-	"host    replication     all                  ::1/128            trust",
-	"host    replication     replicator           0.0.0.0/0          md5",
-	"host    replication     postgres             0.0.0.0/0          md5",
-	"host    all             all                  0.0.0.0/0          md5",
-	"local   all             all                                     md5",
-	"host    replication     replicator           ::0/0              md5",
-	"host    replication     postgres             ::0/0              md5",
-	"host    all             all                  ::0/0              md5",
-}
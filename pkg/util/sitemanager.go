@@ -0,0 +1,66 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// siteManagerCAPath is CA of the operator certificate, it's mounted from tls.certificateSecretName
+	siteManagerCAPath         = "/certs/ca.crt"
+	siteManagerRequestTimeout = 30 * time.Second
+)
+
+var (
+	siteManagerClient     *http.Client
+	siteManagerClientOnce sync.Once
+)
+
+// GetSiteManagerHttpClient returns client shared by requests to site manager of other sites of DR scheme.
+// CA of the operator certificate is trusted in addition to system CAs, sites are usually issued by the same CA.
+func GetSiteManagerHttpClient() *http.Client {
+	siteManagerClientOnce.Do(func() {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12, RootCAs: getSiteManagerRootCAs()}
+		siteManagerClient = &http.Client{Transport: transport, Timeout: siteManagerRequestTimeout}
+	})
+	return siteManagerClient
+}
+
+func getSiteManagerRootCAs() *x509.CertPool {
+	roots, err := x509.SystemCertPool()
+	if err != nil {
+		roots = x509.NewCertPool()
+	}
+	caCert, err := os.ReadFile(siteManagerCAPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			uLog.Warn("Can't read CA certificate for site manager client", zap.Error(err))
+		}
+		return roots
+	}
+	if !roots.AppendCertsFromPEM(caCert) {
+		uLog.Warn("Can't parse CA certificate for site manager client, only system CAs are trusted")
+	}
+	return roots
+}
@@ -0,0 +1,143 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testenv
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// ErrNoPostgres is returned if PostgreSQL binaries are not found, tests which need PostgreSQL are skipped then
+var ErrNoPostgres = errors.New("PostgreSQL binaries are not found, set PG_BIN_DIR to run tests with PostgreSQL")
+
+const postgresStartTimeout = 30 * time.Second
+
+// Postgres is a throwaway PostgreSQL server in a temporary directory, admin user is postgres with trust authentication
+type Postgres struct {
+	Host    string
+	Port    int
+	dataDir string
+	cmd     *exec.Cmd
+}
+
+// StartPostgres initializes and starts PostgreSQL from PG_BIN_DIR, PATH or /usr/lib/postgresql/*/bin
+func StartPostgres() (*Postgres, error) {
+	binDir, err := findPostgresBinDir()
+	if err != nil {
+		return nil, err
+	}
+	if os.Geteuid() == 0 {
+		return nil, fmt.Errorf("PostgreSQL can't be started by root")
+	}
+	dataDir, err := os.MkdirTemp("", "pgskipper-test-pg-")
+	if err != nil {
+		return nil, err
+	}
+	pg := &Postgres{Host: "127.0.0.1", dataDir: dataDir}
+	initdb := exec.Command(filepath.Join(binDir, "initdb"), "-D", dataDir, "-U", "postgres", "-A", "trust", "-E", "UTF8", "--no-locale")
+	if output, err := initdb.CombinedOutput(); err != nil {
+		pg.Stop()
+		return nil, fmt.Errorf("initdb failed: %w, output: %s", err, output)
+	}
+	if pg.Port, err = getFreePort(); err != nil {
+		pg.Stop()
+		return nil, err
+	}
+	logFile, err := os.Create(filepath.Join(dataDir, "postgres.log"))
+	if err != nil {
+		pg.Stop()
+		return nil, err
+	}
+	defer logFile.Close()
+	pg.cmd = exec.Command(filepath.Join(binDir, "postgres"), "-D", dataDir, "-p", strconv.Itoa(pg.Port), "-k", dataDir,
+		"-c", "listen_addresses="+pg.Host, "-c", "fsync=off")
+	pg.cmd.Stdout = logFile
+	pg.cmd.Stderr = logFile
+	if err := pg.cmd.Start(); err != nil {
+		pg.Stop()
+		return nil, err
+	}
+	if err := pg.waitReady(); err != nil {
+		log, _ := os.ReadFile(logFile.Name())
+		pg.Stop()
+		return nil, fmt.Errorf("%w, log: %s", err, log)
+	}
+	return pg, nil
+}
+
+// Stop shuts PostgreSQL down and removes its data directory
+func (pg *Postgres) Stop() {
+	if pg.cmd != nil && pg.cmd.Process != nil {
+		// SIGINT is a fast shutdown, sessions of tests are terminated
+		_ = pg.cmd.Process.Signal(syscall.SIGINT)
+		_ = pg.cmd.Wait()
+	}
+	_ = os.RemoveAll(pg.dataDir)
+}
+
+func (pg *Postgres) waitReady() error {
+	address := net.JoinHostPort(pg.Host, strconv.Itoa(pg.Port))
+	deadline := time.Now().Add(postgresStartTimeout)
+	for time.Now().Before(deadline) {
+		if conn, err := net.DialTimeout("tcp", address, time.Second); err == nil {
+			_ = conn.Close()
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return fmt.Errorf("PostgreSQL is not started on %s in %s", address, postgresStartTimeout)
+}
+
+func findPostgresBinDir() (string, error) {
+	if binDir := os.Getenv("PG_BIN_DIR"); binDir != "" {
+		return binDir, nil
+	}
+	if initdb, err := exec.LookPath("initdb"); err == nil {
+		return filepath.Dir(initdb), nil
+	}
+	// Debian packages don't add binaries to PATH, the latest version is used
+	binDirs, _ := filepath.Glob("/usr/lib/postgresql/*/bin")
+	sort.Slice(binDirs, func(i, j int) bool {
+		return versionOfBinDir(binDirs[i]) > versionOfBinDir(binDirs[j])
+	})
+	for _, binDir := range binDirs {
+		if _, err := os.Stat(filepath.Join(binDir, "initdb")); err == nil {
+			return binDir, nil
+		}
+	}
+	return "", ErrNoPostgres
+}
+
+func versionOfBinDir(binDir string) int {
+	version, _ := strconv.Atoi(filepath.Base(filepath.Dir(binDir)))
+	return version
+}
+
+func getFreePort() (int, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
@@ -0,0 +1,57 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package testenv prepares environment of the operator for unit tests, it has to be imported by tests
+// of packages which depend on credential manager, because the manager reads namespace on initialization,
+// or create Kubernetes client on initialization. It also starts throwaway PostgreSQL for tests of SQL.
+// Packages are initialized in order of import paths, so it's initialized before the credential manager.
+package testenv
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Namespace is used by tests instead of the namespace of the operator pod
+const Namespace = "test"
+
+// kubeconfig points to unreachable server, packages which create Kubernetes client on initialization
+// don't fail without cluster, tests use fake clients instead
+const kubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: test
+  cluster:
+    server: https://127.0.0.1:1
+contexts:
+- name: test
+  context:
+    cluster: test
+    namespace: test
+current-context: test
+`
+
+func init() {
+	for _, name := range []string{"NAMESPACE", "WATCH_NAMESPACE"} {
+		if os.Getenv(name) == "" {
+			_ = os.Setenv(name, Namespace)
+		}
+	}
+	if os.Getenv("KUBECONFIG") == "" {
+		path := filepath.Join(os.TempDir(), "pgskipper-test-kubeconfig")
+		if err := os.WriteFile(path, []byte(kubeconfig), 0600); err == nil {
+			_ = os.Setenv("KUBECONFIG", path)
+		}
+	}
+}
@@ -26,15 +26,19 @@ import (
 	"os"
 	"reflect"
 	r "runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Netcracker/pgskipper-operator-core/pkg/util"
 	qubershipv1 "github.com/Netcracker/pgskipper-operator/api/apps/v1"
 	patroniv1 "github.com/Netcracker/pgskipper-operator/api/patroni/v1"
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
 	"golang.org/x/crypto/ssh"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -60,6 +64,7 @@ var (
 	namespace      = GetNameSpace()
 	k8sClient      crclient.Client
 	reconcileMutex sync.Mutex
+	patroniApiTLS  atomic.Bool
 )
 
 func GetNameSpace() string {
@@ -158,6 +163,7 @@ func createClient() (crclient.Client, error) {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 	utilruntime.Must(qubershipv1.AddToScheme(scheme))
 	utilruntime.Must(patroniv1.AddToScheme(scheme))
+	utilruntime.Must(snapshotv1.AddToScheme(scheme))
 
 	client, err := crclient.New(clientConfig, crclient.Options{Scheme: scheme})
 	if err != nil {
@@ -200,6 +206,25 @@ func GetPatroniClusterName(patroniClusterName string) string {
 	return ClusterName
 }
 
+// SetPatroniApiTLS switches urls of Patroni REST API to https, it's called when Patroni client is configured
+func SetPatroniApiTLS(enabled bool) {
+	patroniApiTLS.Store(enabled)
+}
+
+// GetPatroniApiUrl returns url of Patroni REST API on the host with the configured scheme
+func GetPatroniApiUrl(host string) string {
+	return GetPatroniApiUrlForAddress(host + ":8008")
+}
+
+// GetPatroniApiUrlForAddress returns url of Patroni REST API on host:port with the configured scheme
+func GetPatroniApiUrlForAddress(address string) string {
+	scheme := "http"
+	if patroniApiTLS.Load() {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s/", scheme, address)
+}
+
 func GetPatroniClusterSettings(patroniClusterName string) *patroniv1.PatroniClusterSettings {
 	clusterName := ClusterName
 	if patroniClusterName != "" {
@@ -207,7 +232,7 @@ func GetPatroniClusterSettings(patroniClusterName string) *patroniv1.PatroniClus
 	}
 	pgServiceName := fmt.Sprintf("pg-%s", clusterName)
 	pgReplicasServiceName := fmt.Sprintf("pg-%s-ro", clusterName)
-	patroniUrl := fmt.Sprintf("http://pg-%s-api:8008/", clusterName)
+	patroniUrl := GetPatroniApiUrl(fmt.Sprintf("pg-%s-api", clusterName))
 	patroniTemplate := fmt.Sprintf("%s-patroni.config.yaml", clusterName)
 	postgreSQLUserConf := fmt.Sprintf("postgres-%s.properties", clusterName)
 	patroniDeploymentName := fmt.Sprintf("pg-%s-node", clusterName)
@@ -385,11 +410,52 @@ func FindCmInNamespaceByName(namespace string, name string) (*corev1.ConfigMap,
 	return foundConfigMap, err
 }
 
-func GetContainerNameForPatroniPod(podName string) string {
-	if strings.Contains(podName, "node1") {
-		return "pg-patroni-node1"
+// GetContainerNameForPatroniPod returns name of Patroni container, it's the same as StatefulSet name
+// (pg-<cluster>-node<idx>) and is derived from the pod name (pg-<cluster>-node<idx>-0)
+func GetContainerNameForPatroniPod(podName string) (string, error) {
+	nodeIdx, err := GetPatroniNodeIdx(podName)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%snode%d", podName[:strings.LastIndex(podName, "node")], nodeIdx), nil
+}
+
+// GetPatroniNodeIdx returns index of Patroni member from name of its StatefulSet,
+// container (pg-<cluster>-node<idx>) or pod (pg-<cluster>-node<idx>-0)
+func GetPatroniNodeIdx(name string) (int, error) {
+	pos := strings.LastIndex(name, "node")
+	if pos < 0 {
+		return 0, fmt.Errorf("can't find node index in %s", name)
+	}
+	idx := name[pos+len("node"):]
+	if end := strings.Index(idx, "-"); end >= 0 {
+		idx = idx[:end]
+	}
+	nodeIdx, err := strconv.Atoi(idx)
+	if err != nil || nodeIdx < 1 {
+		return 0, fmt.Errorf("can't parse node index in %s", name)
 	}
-	return "pg-patroni-node2"
+	return nodeIdx, nil
+}
+
+// GetExcessNodes returns Patroni StatefulSets with index greater than replicas, the highest index goes first
+func GetExcessNodes(statefulsets []*appsv1.StatefulSet, replicas int) ([]*appsv1.StatefulSet, error) {
+	var excess []*appsv1.StatefulSet
+	indexes := map[string]int{}
+	for _, statefulset := range statefulsets {
+		nodeIdx, err := GetPatroniNodeIdx(statefulset.Name)
+		if err != nil {
+			return nil, err
+		}
+		if nodeIdx > replicas {
+			indexes[statefulset.Name] = nodeIdx
+			excess = append(excess, statefulset)
+		}
+	}
+	sort.Slice(excess, func(i, j int) bool {
+		return indexes[excess[i].Name] > indexes[excess[j].Name]
+	})
+	return excess, nil
 }
 
 func SliceContains[T comparable](slice []T, value T) bool {
@@ -0,0 +1,155 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetContainerNameForPatroniPod(t *testing.T) {
+	for _, members := range []int{1, 2, 9, 10, 15} {
+		t.Run(fmt.Sprintf("%d members", members), func(t *testing.T) {
+			for idx := 1; idx <= members; idx++ {
+				podName := fmt.Sprintf("pg-patroni-node%d-0", idx)
+				expected := fmt.Sprintf("pg-patroni-node%d", idx)
+				container, err := GetContainerNameForPatroniPod(podName)
+				if err != nil {
+					t.Fatalf("unexpected error for %s: %v", podName, err)
+				}
+				if container != expected {
+					t.Errorf("container of %s is %s, expected %s", podName, container, expected)
+				}
+			}
+		})
+	}
+}
+
+func TestGetContainerNameForPatroniPodCustomCluster(t *testing.T) {
+	tests := []struct {
+		podName  string
+		expected string
+	}{
+		{podName: "pg-node-cluster-node1-0", expected: "pg-node-cluster-node1"},
+		{podName: "pg-main-node12-0", expected: "pg-main-node12"},
+		{podName: "pg-main-node3", expected: "pg-main-node3"},
+	}
+	for _, tt := range tests {
+		container, err := GetContainerNameForPatroniPod(tt.podName)
+		if err != nil {
+			t.Fatalf("unexpected error for %s: %v", tt.podName, err)
+		}
+		if container != tt.expected {
+			t.Errorf("container of %s is %s, expected %s", tt.podName, container, tt.expected)
+		}
+	}
+}
+
+func TestGetContainerNameForPatroniPodInvalid(t *testing.T) {
+	for _, podName := range []string{"pg-patroni-0", "pg-patroni-nodeX-0", "pg-patroni-node0-0"} {
+		if container, err := GetContainerNameForPatroniPod(podName); err == nil {
+			t.Errorf("expected error for %s, got container %s", podName, container)
+		}
+	}
+}
+
+func TestGetPatroniNodeIdx(t *testing.T) {
+	for _, members := range []int{1, 2, 9, 10, 15} {
+		t.Run(fmt.Sprintf("%d members", members), func(t *testing.T) {
+			for idx := 1; idx <= members; idx++ {
+				for _, name := range []string{
+					fmt.Sprintf("pg-patroni-node%d", idx),
+					fmt.Sprintf("pg-patroni-node%d-0", idx),
+					fmt.Sprintf("pg-node-cluster-node%d-0", idx),
+				} {
+					nodeIdx, err := GetPatroniNodeIdx(name)
+					if err != nil {
+						t.Fatalf("unexpected error for %s: %v", name, err)
+					}
+					if nodeIdx != idx {
+						t.Errorf("index of %s is %d, expected %d", name, nodeIdx, idx)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestGetPatroniNodeIdxInvalid(t *testing.T) {
+	for _, name := range []string{"pg-patroni", "pg-patroni-node", "pg-patroni-nodeX-0", "pg-patroni-node0-0", "pg-patroni-node-1-0"} {
+		if nodeIdx, err := GetPatroniNodeIdx(name); err == nil {
+			t.Errorf("expected error for %s, got index %d", name, nodeIdx)
+		}
+	}
+}
+
+func TestGetExcessNodes(t *testing.T) {
+	statefulsets := func(count int) []*appsv1.StatefulSet {
+		var result []*appsv1.StatefulSet
+		for idx := 1; idx <= count; idx++ {
+			result = append(result, &appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("pg-patroni-node%d", idx)}})
+		}
+		// the order of the list from Kubernetes is alphabetical, so node10 goes before node2
+		slices.SortFunc(result, func(a, b *appsv1.StatefulSet) int {
+			return strings.Compare(a.Name, b.Name)
+		})
+		return result
+	}
+	tests := []struct {
+		existing int
+		replicas int
+		expected []string
+	}{
+		{existing: 1, replicas: 1},
+		{existing: 2, replicas: 1, expected: []string{"pg-patroni-node2"}},
+		{existing: 2, replicas: 2},
+		{existing: 9, replicas: 2, expected: []string{"pg-patroni-node9", "pg-patroni-node8", "pg-patroni-node7",
+			"pg-patroni-node6", "pg-patroni-node5", "pg-patroni-node4", "pg-patroni-node3"}},
+		{existing: 10, replicas: 9, expected: []string{"pg-patroni-node10"}},
+		{existing: 10, replicas: 1, expected: []string{"pg-patroni-node10", "pg-patroni-node9", "pg-patroni-node8",
+			"pg-patroni-node7", "pg-patroni-node6", "pg-patroni-node5", "pg-patroni-node4", "pg-patroni-node3", "pg-patroni-node2"}},
+		{existing: 15, replicas: 10, expected: []string{"pg-patroni-node15", "pg-patroni-node14", "pg-patroni-node13",
+			"pg-patroni-node12", "pg-patroni-node11"}},
+		{existing: 15, replicas: 15},
+		{existing: 9, replicas: 15},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d to %d members", tt.existing, tt.replicas), func(t *testing.T) {
+			excess, err := GetExcessNodes(statefulsets(tt.existing), tt.replicas)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, statefulset := range excess {
+				names = append(names, statefulset.Name)
+			}
+			if !slices.Equal(names, tt.expected) {
+				t.Errorf("excess nodes are %v, expected %v", names, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGetExcessNodesInvalidName(t *testing.T) {
+	statefulsets := []*appsv1.StatefulSet{{ObjectMeta: metav1.ObjectMeta{Name: "pg-patroni-nodeX"}}}
+	if _, err := GetExcessNodes(statefulsets, 1); err == nil {
+		t.Errorf("expected error for statefulset without node index")
+	}
+}
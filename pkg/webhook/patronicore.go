@@ -0,0 +1,378 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+
+	patroniv1 "github.com/Netcracker/pgskipper-operator/api/patroni/v1"
+	"github.com/Netcracker/pgskipper-operator/pkg/patroni"
+	"github.com/Netcracker/pgskipper-operator/pkg/util/constants"
+	"github.com/robfig/cron/v3"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// Defaults of PatroniCore documented in installation guide
+const (
+	defaultClusterName       = "patroni"
+	defaultPatroniReplicas   = 2
+	defaultDcsType           = "kubernetes"
+	defaultIgnoreSlotsPrefix = "cdc_rs_"
+	defaultVerifyClient      = "none"
+	defaultLdapPort          = 389
+	defaultLdapsPort         = 636
+)
+
+var (
+	dcsTypes            = []string{"kubernetes", "etcd", "etcd3"}
+	pgBackRestRepoTypes = []string{"rwx", "s3", "azure", "gcs"}
+	pgHbaDefaults       = []string{patroniv1.PgHbaDefaultsStandard, patroniv1.PgHbaDefaultsStrict, patroniv1.PgHbaDefaultsNone}
+	ldapSchemes         = []string{"ldap", "ldaps"}
+	// ldapReservedRoles are roles of the operator, they can't be granted by LDAP group sync
+	ldapReservedRoles = []string{"postgres", "replicator", "pgadminrole"}
+)
+
+//+kubebuilder:webhook:path=/mutate-qubership-org-v1-patronicore,mutating=true,failurePolicy=fail,sideEffects=None,groups=qubership.org,resources=patronicores,verbs=create;update,versions=v1,name=mpatronicore.qubership.org,admissionReviewVersions=v1
+//+kubebuilder:webhook:path=/validate-qubership-org-v1-patronicore,mutating=false,failurePolicy=fail,sideEffects=None,groups=qubership.org,resources=patronicores,verbs=create;update,versions=v1,name=vpatronicore.qubership.org,admissionReviewVersions=v1
+
+// PatroniCoreWebhook applies defaults to PatroniCore and rejects invalid specs before they reach reconcile
+type PatroniCoreWebhook struct{}
+
+var _ admission.CustomDefaulter = &PatroniCoreWebhook{}
+var _ admission.CustomValidator = &PatroniCoreWebhook{}
+
+// SetupPatroniCoreWebhook registers defaulting and validating webhooks of PatroniCore in the manager
+func SetupPatroniCoreWebhook(mgr ctrl.Manager) error {
+	w := &PatroniCoreWebhook{}
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&patroniv1.PatroniCore{}).
+		WithDefaulter(w).
+		WithValidator(w).
+		Complete()
+}
+
+func (w *PatroniCoreWebhook) Default(_ context.Context, obj runtime.Object) error {
+	cr, ok := obj.(*patroniv1.PatroniCore)
+	if !ok {
+		return fmt.Errorf("expected PatroniCore, got %T", obj)
+	}
+	DefaultPatroniCore(cr)
+	return nil
+}
+
+func (w *PatroniCoreWebhook) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	cr, ok := obj.(*patroniv1.PatroniCore)
+	if !ok {
+		return nil, fmt.Errorf("expected PatroniCore, got %T", obj)
+	}
+	return nil, toInvalid(cr, ValidatePatroniCore(cr))
+}
+
+func (w *PatroniCoreWebhook) ValidateUpdate(_ context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	oldCr, ok := oldObj.(*patroniv1.PatroniCore)
+	if !ok {
+		return nil, fmt.Errorf("expected PatroniCore, got %T", oldObj)
+	}
+	cr, ok := newObj.(*patroniv1.PatroniCore)
+	if !ok {
+		return nil, fmt.Errorf("expected PatroniCore, got %T", newObj)
+	}
+	errs := ValidatePatroniCore(cr)
+	errs = append(errs, ValidatePatroniCoreUpdate(oldCr, cr)...)
+	return nil, toInvalid(cr, errs)
+}
+
+func (w *PatroniCoreWebhook) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// DefaultPatroniCore sets documented defaults to fields which are not set
+func DefaultPatroniCore(cr *patroniv1.PatroniCore) {
+	if cr.Spec == nil || cr.Spec.Patroni == nil {
+		return
+	}
+	patroniSpec := cr.Spec.Patroni
+	if patroniSpec.ClusterName == "" {
+		patroniSpec.ClusterName = defaultClusterName
+	}
+	// zero replicas of existing cluster is a valid way to stop it, so default is applied only on creation
+	if patroniSpec.Replicas == 0 && cr.CreationTimestamp.IsZero() {
+		patroniSpec.Replicas = defaultPatroniReplicas
+	}
+	if patroniSpec.Dcs.Type == "" {
+		patroniSpec.Dcs.Type = defaultDcsType
+	}
+	if patroniSpec.IgnoreSlots && patroniSpec.IgnoreSlotsPrefix == "" {
+		patroniSpec.IgnoreSlotsPrefix = defaultIgnoreSlotsPrefix
+	}
+	if patroniSpec.RestApi != nil && patroniSpec.RestApi.VerifyClient == "" {
+		patroniSpec.RestApi.VerifyClient = defaultVerifyClient
+	}
+	if cr.Spec.Ldap != nil && cr.Spec.Ldap.Enabled && cr.Spec.Ldap.Port == 0 {
+		cr.Spec.Ldap.Port = defaultLdapPort
+		if cr.Spec.Ldap.Scheme == "ldaps" {
+			cr.Spec.Ldap.Port = defaultLdapsPort
+		}
+	}
+}
+
+// ValidatePatroniCore returns errors of the spec which make the cluster impossible to reconcile
+func ValidatePatroniCore(cr *patroniv1.PatroniCore) field.ErrorList {
+	var errs field.ErrorList
+	specPath := field.NewPath("spec")
+	if cr.Spec == nil {
+		return append(errs, field.Required(specPath, "spec is required"))
+	}
+	if upgrade := cr.Upgrade; upgrade != nil && (upgrade.Enabled || upgrade.DryRun) && upgrade.DockerUpgradeImage == "" {
+		errs = append(errs, field.Required(field.NewPath("majorUpgrade", "dockerUpgradeImage"),
+			"image is required when major upgrade or its dry run is enabled"))
+	}
+	if upgrade := cr.Upgrade; upgrade != nil && upgrade.Rollback && (upgrade.Enabled || upgrade.DryRun) {
+		errs = append(errs, field.Invalid(field.NewPath("majorUpgrade", "rollback"), upgrade.Rollback,
+			"rollback can't be requested together with major upgrade or its dry run"))
+	}
+	if cr.Spec.Patroni != nil {
+		errs = append(errs, validatePatroni(cr.Spec, specPath.Child("patroni"))...)
+	}
+	if cr.Spec.PgBackRest != nil {
+		errs = append(errs, validatePgBackRest(cr.Spec.PgBackRest, specPath.Child("pgBackRest"))...)
+	}
+	if cr.Spec.Ldap != nil && cr.Spec.Ldap.Enabled {
+		errs = append(errs, validateLdap(cr.Spec.Ldap, specPath.Child("ldap"))...)
+	}
+	return errs
+}
+
+func validateLdap(ldap *patroniv1.LdapConfig, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if ldap.Server == "" {
+		errs = append(errs, field.Required(path.Child("server"), "LDAP server is required"))
+	}
+	if err := validateEnum(path.Child("scheme"), ldap.Scheme, ldapSchemes...); err != nil {
+		errs = append(errs, err)
+	}
+	if ldap.StartTLS && ldap.Scheme == "ldaps" {
+		errs = append(errs, field.Invalid(path.Child("startTLS"), ldap.StartTLS, "StartTLS can't be used with ldaps scheme"))
+	}
+	if ldap.BindPasswd != "" && ldap.BindPasswordSecretName != "" {
+		errs = append(errs, field.Invalid(path.Child("bindpasswd"), "<hidden>",
+			"bindpasswd and bindPasswordSecretName are mutually exclusive"))
+	}
+	groupSync := ldap.GroupSync
+	if groupSync == nil || !groupSync.Enabled {
+		return errs
+	}
+	syncPath := path.Child("groupSync")
+	if groupSync.Schedule != "" {
+		if _, err := cron.ParseStandard(groupSync.Schedule); err != nil {
+			errs = append(errs, field.Invalid(syncPath.Child("schedule"), groupSync.Schedule, err.Error()))
+		}
+	}
+	if len(groupSync.Mappings) == 0 {
+		errs = append(errs, field.Required(syncPath.Child("mappings"), "at least one mapping is required"))
+	}
+	for i, mapping := range groupSync.Mappings {
+		mappingPath := syncPath.Child("mappings").Index(i)
+		if mapping.Group == "" {
+			errs = append(errs, field.Required(mappingPath.Child("group"), "DN of LDAP group is required"))
+		}
+		if mapping.Role == "" {
+			errs = append(errs, field.Required(mappingPath.Child("role"), "PostgreSQL role is required"))
+		} else if slices.Contains(ldapReservedRoles, mapping.Role) {
+			errs = append(errs, field.Invalid(mappingPath.Child("role"), mapping.Role, "role is managed by the operator"))
+		}
+	}
+	return errs
+}
+
+func validatePatroni(spec *patroniv1.PatroniCoreSpec, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	patroniSpec := spec.Patroni
+	if patroniSpec.Replicas < 0 {
+		errs = append(errs, field.Invalid(path.Child("replicas"), patroniSpec.Replicas, "must be greater than or equal to 0"))
+	}
+	if err := validateEnum(path.Child("dcs", "type"), patroniSpec.Dcs.Type, dcsTypes...); err != nil {
+		errs = append(errs, err)
+	}
+	if strings.HasPrefix(patroniSpec.Dcs.Type, "etcd") && len(patroniSpec.Dcs.Hosts) == 0 {
+		errs = append(errs, field.Required(path.Child("dcs", "hosts"), "hosts are required for etcd"))
+	}
+	if _, err := patroni.GetPostgreSQLParams(patroniSpec); err != nil {
+		errs = append(errs, field.Invalid(path.Child("postgreSQLParams"), patroniSpec.PostgreSQLParams, err.Error()))
+	}
+	if _, err := patroni.GetPatroniParams(patroniSpec); err != nil {
+		errs = append(errs, field.Invalid(path.Child("patroniParams"), patroniSpec.PatroniParams, err.Error()))
+	}
+	if patroniSpec.Storage != nil {
+		if err := validateStorageSize(path.Child("storage", "size"), "", patroniSpec.Storage.Size); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if patroniSpec.PgWalStorage != nil {
+		if err := validateStorageSize(path.Child("pgWalStorage", "size"), "", patroniSpec.PgWalStorage.Size); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if standby := patroniSpec.StandbyCluster; standby != nil {
+		// standbyCluster starts the cluster in standby mode, replicating from the active cluster
+		if standby.Host == "" {
+			errs = append(errs, field.Required(path.Child("standbyCluster", "host"), "host of active cluster is required"))
+		}
+		if standby.Port < 0 || standby.Port > 65535 {
+			errs = append(errs, field.Invalid(path.Child("standbyCluster", "port"), standby.Port, "must be a valid port number"))
+		}
+	}
+	if patroniSpec.RestApi != nil && patroniSpec.RestApi.Tls && (spec.Tls == nil || !spec.Tls.Enabled) {
+		errs = append(errs, field.Invalid(path.Child("restApi", "tls"), true, "requires spec.tls.enabled"))
+	}
+	if err := validateEnum(path.Child("authMethod"), patroniSpec.AuthMethod, constants.PasswordEncryption, constants.ScramSHA256); err != nil {
+		errs = append(errs, err)
+	}
+	if patroniSpec.AuthMigration != nil && patroniSpec.AuthMethod != constants.ScramSHA256 {
+		errs = append(errs, field.Invalid(path.Child("authMigration"), patroniSpec.AuthMigration.Strategy, "requires authMethod scram-sha-256"))
+	}
+	for i, line := range patroniSpec.PgHba {
+		if err := patroni.ValidatePgHbaLine(line); err != nil {
+			errs = append(errs, field.Invalid(path.Child("pgHba").Index(i), line, err.Error()))
+		}
+	}
+	if patroniSpec.PgHbaConfig != nil {
+		errs = append(errs, validatePgHbaConfig(patroniSpec.PgHbaConfig, path.Child("pgHbaConfig"))...)
+	}
+	if readOnly := patroniSpec.ReadOnlyService; readOnly != nil && readOnly.Enabled {
+		readOnlyPath := path.Child("readOnlyService")
+		if strings.HasPrefix(patroniSpec.Dcs.Type, "etcd") {
+			errs = append(errs, field.Invalid(readOnlyPath.Child("enabled"), true, "is not supported with etcd DCS"))
+		}
+		if readOnly.MaxLagBytes != nil && *readOnly.MaxLagBytes < 0 {
+			errs = append(errs, field.Invalid(readOnlyPath.Child("maxLagBytes"), *readOnly.MaxLagBytes, "must be greater than or equal to 0"))
+		}
+		if readOnly.CheckIntervalSeconds != 0 && readOnly.CheckIntervalSeconds < 5 {
+			errs = append(errs, field.Invalid(readOnlyPath.Child("checkIntervalSeconds"), readOnly.CheckIntervalSeconds, "must be at least 5"))
+		}
+	}
+	return errs
+}
+
+func validatePgHbaConfig(config *patroniv1.PgHbaConfig, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if err := validateEnum(path.Child("defaults"), config.Defaults, pgHbaDefaults...); err != nil {
+		errs = append(errs, err)
+	}
+	if config.Defaults == patroniv1.PgHbaDefaultsNone && len(config.Rules) == 0 {
+		errs = append(errs, field.Required(path.Child("rules"), "rules are required when defaults is none"))
+	}
+	for i, network := range config.Networks {
+		if _, _, err := net.ParseCIDR(network); err != nil {
+			errs = append(errs, field.Invalid(path.Child("networks").Index(i), network, "must be a valid CIDR"))
+		}
+	}
+	for i, rule := range config.Rules {
+		if err := patroni.ValidatePgHbaRule(rule); err != nil {
+			errs = append(errs, field.Invalid(path.Child("rules").Index(i), rule.Method, err.Error()))
+		}
+	}
+	return errs
+}
+
+func validatePgBackRest(spec *patroniv1.PgBackRest, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if len(spec.Repositories) == 0 {
+		if err := validateEnum(path.Child("repoType"), strings.ToLower(spec.RepoType), pgBackRestRepoTypes...); err != nil {
+			errs = append(errs, err)
+		}
+		if strings.EqualFold(spec.RepoType, "rwx") && spec.Rwx == nil {
+			errs = append(errs, field.Required(path.Child("rwx"), "storage is required for rwx repository"))
+		}
+		return errs
+	}
+	for idx, repository := range spec.Repositories {
+		repoPath := path.Child("repositories").Index(idx)
+		if err := validateEnum(repoPath.Child("type"), repository.Type, pgBackRestRepoTypes...); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		var missing string
+		switch repository.Type {
+		case "rwx":
+			if repository.Rwx == nil {
+				missing = "rwx"
+			}
+		case "s3":
+			if repository.S3 == nil {
+				missing = "s3"
+			}
+		case "azure":
+			if repository.Azure == nil {
+				missing = "azure"
+			}
+		case "gcs":
+			if repository.GCS == nil {
+				missing = "gcs"
+			}
+		}
+		if missing != "" {
+			errs = append(errs, field.Required(repoPath.Child(missing), fmt.Sprintf("%s settings are required for %s repository", missing, repository.Type)))
+		}
+		if repository.CipherType != "" && repository.CipherType != "none" && repository.CredentialsSecret == "" {
+			errs = append(errs, field.Required(repoPath.Child("credentialsSecret"), "cipher-pass is taken from credentials Secret"))
+		}
+	}
+	return errs
+}
+
+// ValidatePatroniCoreUpdate returns errors of the changes which can't be applied to running cluster
+func ValidatePatroniCoreUpdate(oldCr *patroniv1.PatroniCore, cr *patroniv1.PatroniCore) field.ErrorList {
+	var errs field.ErrorList
+	if oldCr.Spec == nil || oldCr.Spec.Patroni == nil || cr.Spec == nil || cr.Spec.Patroni == nil {
+		return errs
+	}
+	path := field.NewPath("spec", "patroni")
+	oldPatroni, newPatroni := oldCr.Spec.Patroni, cr.Spec.Patroni
+	if oldPatroni.Dcs.Type != "" && oldPatroni.Dcs.Type != newPatroni.Dcs.Type {
+		errs = append(errs, field.Forbidden(path.Child("dcs", "type"),
+			fmt.Sprintf("DCS of running cluster can't be changed from %s to %s", oldPatroni.Dcs.Type, newPatroni.Dcs.Type)))
+	}
+	if oldPatroni.ClusterName != "" && oldPatroni.ClusterName != newPatroni.ClusterName {
+		errs = append(errs, field.Forbidden(path.Child("clusterName"), "cluster name of running cluster can't be changed"))
+	}
+	if oldPatroni.Storage != nil && newPatroni.Storage != nil {
+		if err := validateStorageSize(path.Child("storage", "size"), oldPatroni.Storage.Size, newPatroni.Storage.Size); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if oldPatroni.PgWalStorage != nil && newPatroni.PgWalStorage != nil {
+		if err := validateStorageSize(path.Child("pgWalStorage", "size"), oldPatroni.PgWalStorage.Size, newPatroni.PgWalStorage.Size); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+func toInvalid(cr *patroniv1.PatroniCore, errs field.ErrorList) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	logger.Info(fmt.Sprintf("PatroniCore %s is rejected: %s", cr.Name, errs.ToAggregate().Error()))
+	return apierrors.NewInvalid(patroniv1.GroupVersion.WithKind("PatroniCore").GroupKind(), cr.Name, errs)
+}
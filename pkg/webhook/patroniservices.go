@@ -0,0 +1,218 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	qubershipv1 "github.com/Netcracker/pgskipper-operator/api/apps/v1"
+	"github.com/Netcracker/pgskipper-operator/pkg/util/constants"
+	"github.com/robfig/cron/v3"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+var externalDataBaseTypes = []string{constants.CloudSQL, constants.RDS, constants.Azure}
+
+//+kubebuilder:webhook:path=/mutate-qubership-org-v1-patroniservices,mutating=true,failurePolicy=fail,sideEffects=None,groups=qubership.org,resources=patroniservices,verbs=create;update,versions=v1,name=mpatroniservices.qubership.org,admissionReviewVersions=v1
+//+kubebuilder:webhook:path=/validate-qubership-org-v1-patroniservices,mutating=false,failurePolicy=fail,sideEffects=None,groups=qubership.org,resources=patroniservices,verbs=create;update,versions=v1,name=vpatroniservices.qubership.org,admissionReviewVersions=v1
+
+// PatroniServicesWebhook applies defaults to PatroniServices and rejects invalid specs before they reach reconcile
+type PatroniServicesWebhook struct{}
+
+var _ admission.CustomDefaulter = &PatroniServicesWebhook{}
+var _ admission.CustomValidator = &PatroniServicesWebhook{}
+
+// SetupPatroniServicesWebhook registers defaulting and validating webhooks of PatroniServices in the manager
+func SetupPatroniServicesWebhook(mgr ctrl.Manager) error {
+	w := &PatroniServicesWebhook{}
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&qubershipv1.PatroniServices{}).
+		WithDefaulter(w).
+		WithValidator(w).
+		Complete()
+}
+
+func (w *PatroniServicesWebhook) Default(_ context.Context, obj runtime.Object) error {
+	cr, ok := obj.(*qubershipv1.PatroniServices)
+	if !ok {
+		return fmt.Errorf("expected PatroniServices, got %T", obj)
+	}
+	DefaultPatroniServices(cr)
+	return nil
+}
+
+func (w *PatroniServicesWebhook) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	cr, ok := obj.(*qubershipv1.PatroniServices)
+	if !ok {
+		return nil, fmt.Errorf("expected PatroniServices, got %T", obj)
+	}
+	return nil, servicesToInvalid(cr, ValidatePatroniServices(cr))
+}
+
+func (w *PatroniServicesWebhook) ValidateUpdate(_ context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	oldCr, ok := oldObj.(*qubershipv1.PatroniServices)
+	if !ok {
+		return nil, fmt.Errorf("expected PatroniServices, got %T", oldObj)
+	}
+	cr, ok := newObj.(*qubershipv1.PatroniServices)
+	if !ok {
+		return nil, fmt.Errorf("expected PatroniServices, got %T", newObj)
+	}
+	errs := ValidatePatroniServices(cr)
+	errs = append(errs, ValidatePatroniServicesUpdate(oldCr, cr)...)
+	return nil, servicesToInvalid(cr, errs)
+}
+
+func (w *PatroniServicesWebhook) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// DefaultPatroniServices sets documented defaults to fields which are not set
+func DefaultPatroniServices(cr *qubershipv1.PatroniServices) {
+	if cr.Spec == nil {
+		return
+	}
+	if cr.Spec.ExternalDataBase == nil {
+		if cr.Spec.Patroni == nil {
+			cr.Spec.Patroni = &qubershipv1.Patroni{}
+		}
+		if cr.Spec.Patroni.ClusterName == "" {
+			cr.Spec.Patroni.ClusterName = defaultClusterName
+		}
+	}
+}
+
+// ValidatePatroniServices returns errors of the spec which make services impossible to reconcile
+func ValidatePatroniServices(cr *qubershipv1.PatroniServices) field.ErrorList {
+	var errs field.ErrorList
+	specPath := field.NewPath("spec")
+	if cr.Spec == nil {
+		return append(errs, field.Required(specPath, "spec is required"))
+	}
+	if external := cr.Spec.ExternalDataBase; external != nil {
+		path := specPath.Child("externalDataBase")
+		if external.Type == "" {
+			errs = append(errs, field.Required(path.Child("type"), "type of external database is required"))
+		} else if err := validateEnum(path.Child("type"), strings.ToLower(external.Type), externalDataBaseTypes...); err != nil {
+			errs = append(errs, err)
+		}
+		if external.Port < 0 || external.Port > 65535 {
+			errs = append(errs, field.Invalid(path.Child("port"), external.Port, "must be a valid port number"))
+		}
+	}
+	if siteManager := cr.Spec.SiteManager; siteManager != nil {
+		path := specPath.Child("siteManager")
+		if siteManager.ActiveClusterPort < 0 || siteManager.ActiveClusterPort > 65535 {
+			errs = append(errs, field.Invalid(path.Child("activeClusterPort"), siteManager.ActiveClusterPort, "must be a valid port number"))
+		}
+		if siteManager.UpstreamClusterPort < 0 || siteManager.UpstreamClusterPort > 65535 {
+			errs = append(errs, field.Invalid(path.Child("upstreamClusterPort"), siteManager.UpstreamClusterPort, "must be a valid port number"))
+		}
+		if siteManager.UpstreamClusterPort != 0 && siteManager.UpstreamClusterHost == "" {
+			errs = append(errs, field.Required(path.Child("upstreamClusterHost"), "host is required when upstreamClusterPort is set"))
+		}
+		if siteManager.MaxLagOnPromotion < 0 {
+			errs = append(errs, field.Invalid(path.Child("maxLagOnPromotion"), siteManager.MaxLagOnPromotion, "must be greater than or equal to 0"))
+		}
+		if check := siteManager.StandbyClusterHealthCheck; check != nil {
+			checkPath := path.Child("standbyClusterHealthCheck")
+			if check.RetriesLimit < 0 {
+				errs = append(errs, field.Invalid(checkPath.Child("retriesLimit"), check.RetriesLimit, "must be greater than or equal to 0"))
+			}
+			if check.FailureRetriesLimit < 0 {
+				errs = append(errs, field.Invalid(checkPath.Child("failureRetriesLimit"), check.FailureRetriesLimit, "must be greater than or equal to 0"))
+			}
+			if check.RetriesWaitTimeout < 0 {
+				errs = append(errs, field.Invalid(checkPath.Child("retriesWaitTimeout"), check.RetriesWaitTimeout, "must be greater than or equal to 0"))
+			}
+		}
+	}
+	if replicas := cr.Spec.Pooler.Replicas; replicas != nil && *replicas < 0 {
+		errs = append(errs, field.Invalid(specPath.Child("connectionPooler", "replicas"), *replicas, "must be greater than or equal to 0"))
+	}
+	if readOnly := cr.Spec.Pooler.ReadOnly; readOnly != nil {
+		path := specPath.Child("connectionPooler", "readOnly")
+		if readOnly.Install && !cr.Spec.Pooler.Install {
+			errs = append(errs, field.Invalid(path.Child("install"), readOnly.Install, "read-only pooler requires connectionPooler.install"))
+		}
+		if readOnly.Replicas != nil && *readOnly.Replicas < 0 {
+			errs = append(errs, field.Invalid(path.Child("replicas"), *readOnly.Replicas, "must be greater than or equal to 0"))
+		}
+	}
+	if metrics := cr.Spec.Pooler.Metrics; metrics != nil && metrics.Install && metrics.Image == "" {
+		errs = append(errs, field.Required(specPath.Child("connectionPooler", "metrics", "image"), "image of the exporter is required"))
+	}
+	if rotation := cr.Spec.CredentialsRotation; rotation != nil {
+		errs = append(errs, validateCredentialsRotation(specPath.Child("credentialsRotation"), rotation)...)
+	}
+	return errs
+}
+
+func validateCredentialsRotation(path *field.Path, rotation *qubershipv1.CredentialsRotation) field.ErrorList {
+	var errs field.ErrorList
+	secrets := map[string]bool{}
+	for i, policy := range rotation.Policies {
+		policyPath := path.Child("policies").Index(i)
+		if secrets[policy.SecretName] {
+			errs = append(errs, field.Duplicate(policyPath.Child("secretName"), policy.SecretName))
+		}
+		secrets[policy.SecretName] = true
+		hasInterval := policy.Interval != nil && policy.Interval.Duration > 0
+		switch {
+		case policy.Schedule == "" && !hasInterval:
+			errs = append(errs, field.Required(policyPath, "schedule or interval is required"))
+		case policy.Schedule != "" && hasInterval:
+			errs = append(errs, field.Invalid(policyPath, policy.SecretName, "only one of schedule and interval can be set"))
+		case policy.Schedule != "":
+			if _, err := cron.ParseStandard(policy.Schedule); err != nil {
+				errs = append(errs, field.Invalid(policyPath.Child("schedule"), policy.Schedule, err.Error()))
+			}
+		}
+	}
+	return errs
+}
+
+// ValidatePatroniServicesUpdate returns errors of the changes which can't be applied to installed services
+func ValidatePatroniServicesUpdate(oldCr *qubershipv1.PatroniServices, cr *qubershipv1.PatroniServices) field.ErrorList {
+	var errs field.ErrorList
+	if oldCr.Spec == nil || cr.Spec == nil {
+		return errs
+	}
+	path := field.NewPath("spec", "externalDataBase", "type")
+	oldExternal, newExternal := oldCr.Spec.ExternalDataBase, cr.Spec.ExternalDataBase
+	if oldExternal != nil && newExternal != nil && !strings.EqualFold(oldExternal.Type, newExternal.Type) {
+		errs = append(errs, field.Forbidden(path,
+			fmt.Sprintf("type of external database can't be changed from %s to %s", oldExternal.Type, newExternal.Type)))
+	}
+	if oldCr.Spec.Patroni != nil && cr.Spec.Patroni != nil && oldCr.Spec.Patroni.ClusterName != "" &&
+		oldCr.Spec.Patroni.ClusterName != cr.Spec.Patroni.ClusterName {
+		errs = append(errs, field.Forbidden(field.NewPath("spec", "patroni", "clusterName"), "cluster name can't be changed"))
+	}
+	return errs
+}
+
+func servicesToInvalid(cr *qubershipv1.PatroniServices, errs field.ErrorList) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	logger.Info(fmt.Sprintf("PatroniServices %s is rejected: %s", cr.Name, errs.ToAggregate().Error()))
+	return apierrors.NewInvalid(qubershipv1.GroupVersion.WithKind("PatroniServices").GroupKind(), cr.Name, errs)
+}
@@ -0,0 +1,75 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Netcracker/pgskipper-operator/pkg/util"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+const (
+	// Port and CertDir are used by webhook server of the manager,
+	// certificate is mounted from the Secret issued by cert-manager
+	Port    = 9443
+	CertDir = "/tmp/k8s-webhook-server/serving-certs"
+)
+
+var logger = util.GetLogger()
+
+// IsEnabled returns true if admission webhooks should be served by operator
+func IsEnabled() bool {
+	return strings.ToLower(util.GetEnv("OPERATOR_WEBHOOKS_ENABLED", "false")) == "true"
+}
+
+// NewServer returns webhook server for the manager
+func NewServer() webhook.Server {
+	return webhook.NewServer(webhook.Options{Port: Port, CertDir: CertDir})
+}
+
+// validateEnum returns error if value is not empty and isn't one of allowed values
+func validateEnum(path *field.Path, value string, allowed ...string) *field.Error {
+	if value == "" || slices.Contains(allowed, value) {
+		return nil
+	}
+	return field.NotSupported(path, value, allowed)
+}
+
+// validateStorageSize returns error if size is not a quantity or the new size is less than the old one
+func validateStorageSize(path *field.Path, oldSize string, newSize string) *field.Error {
+	if newSize == "" {
+		return nil
+	}
+	newQuantity, err := resource.ParseQuantity(newSize)
+	if err != nil {
+		return field.Invalid(path, newSize, err.Error())
+	}
+	if oldSize == "" {
+		return nil
+	}
+	oldQuantity, err := resource.ParseQuantity(oldSize)
+	if err != nil {
+		return nil
+	}
+	if newQuantity.Cmp(oldQuantity) < 0 {
+		return field.Forbidden(path, fmt.Sprintf("storage can't be shrunk from %s to %s", oldSize, newSize))
+	}
+	return nil
+}
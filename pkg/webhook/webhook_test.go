@@ -0,0 +1,214 @@
+// Copyright 2024-2025 NetCracker Technology Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	qubershipv1 "github.com/Netcracker/pgskipper-operator/api/apps/v1"
+	patroniv1 "github.com/Netcracker/pgskipper-operator/api/patroni/v1"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func newScheme(t *testing.T) *runtime.Scheme {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := qubershipv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := patroniv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return scheme
+}
+
+// admissionRequest returns create or update request with the objects serialized as API server sends them
+func admissionRequest(t *testing.T, oldObj runtime.Object, obj runtime.Object) admission.Request {
+	t.Helper()
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Operation: admissionv1.Create,
+		Object:    runtime.RawExtension{Raw: raw},
+	}}
+	if oldObj != nil {
+		oldRaw, err := json.Marshal(oldObj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		request.Operation = admissionv1.Update
+		request.OldObject = runtime.RawExtension{Raw: oldRaw}
+	}
+	return request
+}
+
+func newPatroniServices(spec *qubershipv1.PatroniServicesSpec) *qubershipv1.PatroniServices {
+	return &qubershipv1.PatroniServices{
+		TypeMeta:   metav1.TypeMeta{APIVersion: qubershipv1.GroupVersion.String(), Kind: "PatroniServices"},
+		ObjectMeta: metav1.ObjectMeta{Name: "patroni-services", Namespace: "postgres"},
+		Spec:       spec,
+	}
+}
+
+func newPatroniCore(patroni *patroniv1.Patroni) *patroniv1.PatroniCore {
+	return &patroniv1.PatroniCore{
+		TypeMeta:   metav1.TypeMeta{APIVersion: patroniv1.GroupVersion.String(), Kind: "PatroniCore"},
+		ObjectMeta: metav1.ObjectMeta{Name: "patroni-core", Namespace: "postgres"},
+		Spec:       &patroniv1.PatroniCoreSpec{Patroni: patroni},
+	}
+}
+
+func TestPatroniServicesAdmission(t *testing.T) {
+	validator := admission.WithCustomValidator(newScheme(t), &qubershipv1.PatroniServices{}, &PatroniServicesWebhook{})
+	tests := []struct {
+		name    string
+		old     *qubershipv1.PatroniServices
+		cr      *qubershipv1.PatroniServices
+		allowed bool
+	}{
+		{
+			name:    "empty spec",
+			cr:      newPatroniServices(&qubershipv1.PatroniServicesSpec{}),
+			allowed: true,
+		},
+		{
+			name: "site manager",
+			cr: newPatroniServices(&qubershipv1.PatroniServicesSpec{SiteManager: &qubershipv1.SiteManager{
+				ActiveClusterHost: "pg-patroni.postgres.svc", ActiveClusterPort: 5432,
+				StandbyClusterHealthCheck: &qubershipv1.StandbyClusterHealthCheck{RetriesLimit: 3},
+			}}),
+			allowed: true,
+		},
+		{
+			name: "site manager port out of range",
+			cr: newPatroniServices(&qubershipv1.PatroniServicesSpec{SiteManager: &qubershipv1.SiteManager{
+				ActiveClusterHost: "pg-patroni.postgres.svc", ActiveClusterPort: 70000,
+			}}),
+		},
+		{
+			name: "upstream port without host",
+			cr: newPatroniServices(&qubershipv1.PatroniServicesSpec{SiteManager: &qubershipv1.SiteManager{
+				UpstreamClusterPort: 5432,
+			}}),
+		},
+		{
+			name: "negative health check retries",
+			cr: newPatroniServices(&qubershipv1.PatroniServicesSpec{SiteManager: &qubershipv1.SiteManager{
+				StandbyClusterHealthCheck: &qubershipv1.StandbyClusterHealthCheck{FailureRetriesLimit: -1},
+			}}),
+		},
+		{
+			name: "unknown external database",
+			cr: newPatroniServices(&qubershipv1.PatroniServicesSpec{ExternalDataBase: &qubershipv1.ExternalDataBase{
+				Type: "oracle",
+			}}),
+		},
+		{
+			name: "cluster name change",
+			old:  newPatroniServices(&qubershipv1.PatroniServicesSpec{Patroni: &qubershipv1.Patroni{ClusterName: "patroni"}}),
+			cr:   newPatroniServices(&qubershipv1.PatroniServicesSpec{Patroni: &qubershipv1.Patroni{ClusterName: "other"}}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var old runtime.Object
+			if tt.old != nil {
+				old = tt.old
+			}
+			response := validator.Handle(context.Background(), admissionRequest(t, old, tt.cr))
+			if response.Allowed != tt.allowed {
+				t.Errorf("allowed is %t, expected %t, result: %v", response.Allowed, tt.allowed, response.Result)
+			}
+		})
+	}
+}
+
+func TestPatroniServicesAdmissionIgnoresStatus(t *testing.T) {
+	validator := admission.WithCustomValidator(newScheme(t), &qubershipv1.PatroniServices{}, &PatroniServicesWebhook{})
+	cr := newPatroniServices(&qubershipv1.PatroniServicesSpec{})
+	// status is set by the operator and is not validated
+	cr.Status.SiteManagerStatus.Mode = "unknown"
+	if response := validator.Handle(context.Background(), admissionRequest(t, nil, cr)); !response.Allowed {
+		t.Errorf("PatroniServices is rejected by status: %v", response.Result)
+	}
+}
+
+func TestPatroniCoreAdmission(t *testing.T) {
+	validator := admission.WithCustomValidator(newScheme(t), &patroniv1.PatroniCore{}, &PatroniCoreWebhook{})
+	tests := []struct {
+		name    string
+		patroni *patroniv1.Patroni
+		allowed bool
+	}{
+		{
+			name:    "active cluster",
+			patroni: &patroniv1.Patroni{Replicas: 2},
+			allowed: true,
+		},
+		{
+			name:    "standby cluster",
+			patroni: &patroniv1.Patroni{Replicas: 2, StandbyCluster: &patroniv1.StandbyCluster{Host: "pg-patroni.active", Port: 5432}},
+			allowed: true,
+		},
+		{
+			name:    "standby cluster without host",
+			patroni: &patroniv1.Patroni{Replicas: 2, StandbyCluster: &patroniv1.StandbyCluster{Port: 5432}},
+		},
+		{
+			name:    "standby cluster port out of range",
+			patroni: &patroniv1.Patroni{Replicas: 2, StandbyCluster: &patroniv1.StandbyCluster{Host: "pg-patroni.active", Port: -1}},
+		},
+		{
+			name:    "unknown dcs",
+			patroni: &patroniv1.Patroni{Replicas: 2, Dcs: patroniv1.Dcs{Type: "zookeeper"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := validator.Handle(context.Background(), admissionRequest(t, nil, newPatroniCore(tt.patroni)))
+			if response.Allowed != tt.allowed {
+				t.Errorf("allowed is %t, expected %t, result: %v", response.Allowed, tt.allowed, response.Result)
+			}
+		})
+	}
+}
+
+func TestPatroniCoreDefaults(t *testing.T) {
+	defaulter := admission.WithCustomDefaulter(newScheme(t), &patroniv1.PatroniCore{}, &PatroniCoreWebhook{})
+	response := defaulter.Handle(context.Background(), admissionRequest(t, nil, newPatroniCore(&patroniv1.Patroni{})))
+	if !response.Allowed {
+		t.Fatalf("defaulting is rejected: %v", response.Result)
+	}
+	patches := map[string]interface{}{}
+	for _, patch := range response.Patches {
+		patches[patch.Path] = patch.Value
+	}
+	expected := map[string]interface{}{
+		"/spec/patroni/clusterName": defaultClusterName,
+		"/spec/patroni/replicas":    float64(defaultPatroniReplicas),
+	}
+	for path, value := range expected {
+		if patches[path] != value {
+			t.Errorf("patch of %s is %v, expected %v", path, patches[path], value)
+		}
+	}
+}
@@ -183,15 +183,17 @@ def execute_auth_check(self):
             config_map = self.pl_lib.get_config_map(config_map_name, self._namespace)
         config_map_yaml = (config_map.to_dict())
         config_map = config_map_yaml["data"]["patroni-config-template.yaml"]
-        rest_api_auth = "authentication" in yaml.safe_load(config_map)["restapi"]
+        rest_api = yaml.safe_load(config_map)["restapi"]
+        rest_api_auth = "authentication" in rest_api
+        scheme = "https" if "certfile" in rest_api else "http"
         rest_api_auth_configured = False
         status_code = 0
         if rest_api_auth:
             rest_api_auth_configured = True
             master_service = self.get_master_service()
             response = requests.patch(
-                "http://{}:8008/config".format(master_service),
-                data=json.dumps("{\"pause\": false}"))
+                "{}://{}:8008/config".format(scheme, master_service),
+                data=json.dumps("{\"pause\": false}"), verify=False)
             status_code = response.status_code
 
         if rest_api_auth_configured and status_code != 401: